@@ -0,0 +1,116 @@
+package langserver
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+)
+
+// registeredAnalyzer is an analyzer which may run over the packages of the
+// workspace.
+type registeredAnalyzer struct {
+	analyzer *analysis.Analyzer
+	// enabled is whether the analyzer runs unless the "analyses"
+	// initialization option disables it.
+	enabled bool
+}
+
+var (
+	analyzersMu sync.Mutex
+	// analyzers are the registered analyzers, in registration order.
+	analyzers []registeredAnalyzer
+)
+
+func init() {
+	for _, a := range source.VetAnalyzers {
+		RegisterAnalyzer(a, true)
+	}
+	RegisterAnalyzer(nilness.Analyzer, false)
+	RegisterAnalyzer(shadow.Analyzer, false)
+}
+
+// RegisterAnalyzer registers the analyzer a, which runs over the package
+// of a document when it is saved if enabled is set or the "analyses"
+// initialization option enables it. Its findings are published as
+// warnings, and the fixes it suggests are offered as quick fixes.
+//
+// RegisterAnalyzer panics if an analyzer with the same name is already
+// registered.
+func RegisterAnalyzer(a *analysis.Analyzer, enabled bool) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	for _, r := range analyzers {
+		if r.analyzer.Name == a.Name {
+			panic(fmt.Sprintf("analyzer %s registered twice", a.Name))
+		}
+	}
+	analyzers = append(analyzers, registeredAnalyzer{analyzer: a, enabled: enabled})
+}
+
+// enabledAnalyzers returns the registered analyzers the configuration
+// enables, nil if there are none. VetAnalyzers restricts the analyzers
+// enabled by default, and Analyses enables or disables any of them.
+func enabledAnalyzers(c *Config) []*analysis.Analyzer {
+	if !c.VetOnSave {
+		return nil
+	}
+
+	if unknown := unknownAnalyzers(c); len(unknown) > 0 {
+		log.Printf("unknown analyzers: %s", strings.Join(unknown, ", "))
+	}
+
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+
+	vet := make(map[string]bool)
+	for _, name := range c.VetAnalyzers {
+		vet[name] = true
+	}
+	var enabled []*analysis.Analyzer
+	for _, r := range analyzers {
+		name := r.analyzer.Name
+		on := r.enabled
+		if on && len(vet) > 0 {
+			on = vet[name]
+		}
+		if v, ok := c.Analyses[name]; ok {
+			on = v
+		}
+		if on {
+			enabled = append(enabled, r.analyzer)
+		}
+	}
+	return enabled
+}
+
+// unknownAnalyzers returns the sorted names of the analyzers c refers to
+// which are not registered.
+func unknownAnalyzers(c *Config) []string {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+
+	known := make(map[string]bool)
+	for _, r := range analyzers {
+		known[r.analyzer.Name] = true
+	}
+	var unknown []string
+	for _, name := range c.VetAnalyzers {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	for name := range c.Analyses {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
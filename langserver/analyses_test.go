@@ -0,0 +1,37 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnabledAnalyzers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		config Config
+		want   []string
+	}{
+		{Config{VetOnSave: false, Analyses: map[string]bool{"shadow": true}}, nil},
+		{Config{VetOnSave: true, VetAnalyzers: []string{"printf"}}, []string{"printf"}},
+		{Config{VetOnSave: true, VetAnalyzers: []string{"printf", "unreachable"}, Analyses: map[string]bool{"printf": false, "shadow": true}}, []string{"unreachable", "shadow"}},
+		{Config{VetOnSave: true, VetAnalyzers: []string{"nilness"}, Analyses: map[string]bool{"nosuchanalyzer": true}}, nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, a := range enabledAnalyzers(&test.config) {
+			got = append(got, a.Name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %v, want %v", test.config, got, test.want)
+		}
+	}
+
+	var all []string
+	for _, a := range enabledAnalyzers(&Config{VetOnSave: true, Analyses: map[string]bool{"nilness": true}}) {
+		all = append(all, a.Name)
+	}
+	if len(all) == 0 || all[len(all)-1] != "nilness" {
+		t.Errorf("got %v, want the vet analyzers and nilness", all)
+	}
+}
@@ -42,6 +42,25 @@ func goRangeToLSPLocation(fSet *token.FileSet, pos token.Pos, name string) lsp.L
 	}
 }
 
+// goRangeToLSPLocationFor is like goRangeToLSPLocation, but with adjusted
+// set to false it ignores //line directives and reports the location in
+// the file pos was actually parsed from.
+func goRangeToLSPLocationFor(fSet *token.FileSet, pos token.Pos, name string, adjusted bool) lsp.Location {
+	start := fSet.PositionFor(pos, false)
+	if adjusted || start.Filename == "" {
+		return goRangeToLSPLocation(fSet, pos, name)
+	}
+
+	end := fSet.PositionFor(pos+token.Pos(len([]byte(name))), false)
+	return lsp.Location{
+		URI: lsp.DocumentURI(source.ToURI(start.Filename)),
+		Range: lsp.Range{
+			Start: lsp.Position{Line: start.Line - 1, Character: start.Column - 1},
+			End:   lsp.Position{Line: end.Line - 1, Character: end.Column - 1},
+		},
+	}
+}
+
 func createLocationFromRange(fSet *token.FileSet, pos token.Pos, end token.Pos) lsp.Location {
 	return lsp.Location{
 		URI:   lsp.DocumentURI(source.ToURI(fSet.Position(pos).Filename)),
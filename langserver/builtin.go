@@ -0,0 +1,97 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// builtinFile is the parsed $GOROOT/src/builtin/builtin.go. Builtin
+// objects have no position in the type checker, so we use the
+// declarations in this file as their definitions.
+type builtinFile struct {
+	once sync.Once
+	fset *token.FileSet
+	file *ast.File
+
+	// embedded is set if GOROOT could not be resolved and file is
+	// builtinSource instead, which has no location to navigate to.
+	embedded bool
+}
+
+// load parses builtin.go on first use. If GOROOT can not be resolved or
+// the file can not be parsed, the embedded builtinSource is used instead.
+func (b *builtinFile) load() *ast.File {
+	b.once.Do(func() {
+		b.fset = token.NewFileSet()
+		root := os.Getenv("GOROOT")
+		if root == "" {
+			root = runtime.GOROOT()
+		}
+		if root != "" {
+			filename := filepath.Join(root, "src", "builtin", "builtin.go")
+			if file, err := parser.ParseFile(b.fset, filename, nil, parser.ParseComments); err == nil {
+				b.file = file
+				return
+			}
+		}
+
+		file, err := parser.ParseFile(b.fset, "builtin.go", builtinSource, parser.ParseComments)
+		if err != nil {
+			return
+		}
+		b.file = file
+		b.embedded = true
+	})
+	return b.file
+}
+
+// lookup returns the identifier declaring name in builtin.go, or nil. It
+// returns nil for the embedded builtinSource, whose declarations can not
+// be navigated to.
+func (b *builtinFile) lookup(name string) *ast.Ident {
+	ident, _, _ := b.lookupDecl(name)
+	if b.embedded {
+		return nil
+	}
+	return ident
+}
+
+// lookupDecl returns the identifier declaring name, along with its
+// declaration, which is either a *ast.FuncDecl or a spec of the returned
+// *ast.GenDecl.
+func (b *builtinFile) lookupDecl(name string) (*ast.Ident, ast.Node, *ast.GenDecl) {
+	file := b.load()
+	if file == nil {
+		return nil, nil, nil
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && decl.Name.Name == name {
+				return decl.Name, decl, nil
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name == name {
+						return spec.Name, spec, decl
+					}
+				case *ast.ValueSpec:
+					for _, n := range spec.Names {
+						if n.Name == name {
+							return n, spec, decl
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,305 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// handleTextDocumentPrepareCallHierarchy returns the item of the function
+// or method declared or used at the position.
+func (h *LangHandler) handleTextDocumentPrepareCallHierarchy(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]protocol.CallHierarchyItem, error) {
+	pkg, fn, err := h.callHierarchyFunc(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		// Invalid nodes means we tried to click on something which is
+		// not an ident (eg comment/string/etc). Return no information.
+		if _, ok := err.(*source.InvalidNodeError); ok {
+			return []protocol.CallHierarchyItem{}, nil
+		}
+		return nil, err
+	}
+	if fn == nil {
+		return []protocol.CallHierarchyItem{}, nil
+	}
+	return []protocol.CallHierarchyItem{callHierarchyItem(pkg, fn, h.utf16Ranges(ctx))}, nil
+}
+
+// handleCallHierarchyIncomingCalls returns the functions and methods
+// calling the function or method of the item, along with the ranges of
+// their calls, found among its references. The calls outside of functions,
+// eg. in the initializers of package variables, have no caller and are
+// left out.
+func (h *LangHandler) handleCallHierarchyIncomingCalls(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	calls := []protocol.CallHierarchyIncomingCall{}
+	_, fn, err := h.callHierarchyFunc(ctx, params.Item.URI, params.Item.SelectionRange.Start)
+	if err != nil || fn == nil {
+		return calls, err
+	}
+
+	interfaces := h.getConfig().CallHierarchyInterfaceCalls
+	toUTF16 := h.utf16Ranges(ctx)
+	// The callers are indexed by the location of their name, and the
+	// calls are found again in the test variants of the packages.
+	callers := make(map[string]int)
+	seen := make(map[string]bool)
+	err = h.findRelatedReferences(ctx, fn, false, interfaces, func(pkg source.Package, refs []*ast.Ident) error {
+		info, fset := pkg.GetTypesInfo(), pkg.GetFileSet()
+		for _, id := range refs {
+			callee, ok := info.Uses[id].(*types.Func)
+			if !ok {
+				continue
+			}
+			decl, called := callerOf(pkg, id)
+			// The calls of an interface method are those of its item.
+			if decl == nil || !isCall(callee, called, interfaces || sameObj(fset, callee, fn)) {
+				continue
+			}
+			caller, ok := info.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+
+			loc := goRangeToLSPLocation(fset, id.Pos(), id.Name)
+			if seen[formatLocation(loc)] {
+				continue
+			}
+			seen[formatLocation(loc)] = true
+			key := formatLocation(goRangeToLSPLocation(fset, decl.Name.Pos(), decl.Name.Name))
+			i, ok := callers[key]
+			if !ok {
+				i = len(calls)
+				callers[key] = i
+				calls = append(calls, protocol.CallHierarchyIncomingCall{
+					From:       callHierarchyItem(pkg, caller, toUTF16),
+					FromRanges: []lsp.Range{},
+				})
+			}
+			calls[i].FromRanges = append(calls[i].FromRanges, toUTF16(loc.URI, loc.Range))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, call := range calls {
+		sortRanges(call.FromRanges)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		x, y := calls[i].From, calls[j].From
+		return lessLocation(lsp.Location{URI: x.URI, Range: x.SelectionRange}, lsp.Location{URI: y.URI, Range: y.SelectionRange})
+	})
+	return calls, nil
+}
+
+// handleCallHierarchyOutgoingCalls returns the functions and methods the
+// function or method of the item calls, in the order of their first call,
+// along with the ranges of their calls.
+func (h *LangHandler) handleCallHierarchyOutgoingCalls(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	calls := []protocol.CallHierarchyOutgoingCall{}
+	pkg, fn, err := h.callHierarchyFunc(ctx, params.Item.URI, params.Item.SelectionRange.Start)
+	if err != nil || fn == nil {
+		return calls, err
+	}
+	decl := funcDecl(pkg, fn)
+	if decl == nil || decl.Body == nil {
+		return calls, nil
+	}
+
+	info, fset := pkg.GetTypesInfo(), pkg.GetFileSet()
+	interfaces := h.getConfig().CallHierarchyInterfaceCalls
+	toUTF16 := h.utf16Ranges(ctx)
+	called := calledIdents(decl.Body)
+	callees := make(map[*types.Func]int)
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		callee, ok := info.Uses[id].(*types.Func)
+		if !ok || !isCall(callee, called[id], interfaces) {
+			return true
+		}
+
+		i, ok := callees[callee]
+		if !ok {
+			i = len(calls)
+			callees[callee] = i
+			calls = append(calls, protocol.CallHierarchyOutgoingCall{
+				To:         callHierarchyItem(pkg, callee, toUTF16),
+				FromRanges: []lsp.Range{},
+			})
+		}
+		loc := goRangeToLSPLocation(fset, id.Pos(), id.Name)
+		calls[i].FromRanges = append(calls[i].FromRanges, toUTF16(loc.URI, loc.Range))
+		return true
+	})
+	return calls, nil
+}
+
+// callHierarchyFunc returns the function or method declared or used at the
+// position of the document uri, along with the package of the document. The
+// function is nil if there is none there.
+func (h *LangHandler) callHierarchyFunc(ctx context.Context, uri lsp.DocumentURI, position lsp.Position) (source.Package, *types.Func, error) {
+	pkg, pos, err := h.typeCheck(ctx, uri, position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ident *ast.Ident
+	switch node := pathNodes[0].(type) {
+	case *ast.Ident:
+		ident = node
+	case *ast.FuncDecl:
+		ident = node.Name
+	default:
+		return pkg, nil, nil
+	}
+	fn, _ := pkg.GetTypesInfo().ObjectOf(ident).(*types.Func)
+	return pkg, fn, nil
+}
+
+// callHierarchyItem returns the item of the function or method fn. Its
+// range is that of the declaration of fn if it is one of pkg, otherwise
+// that of its name.
+func callHierarchyItem(pkg source.Package, fn *types.Func, toUTF16 func(lsp.DocumentURI, lsp.Range) lsp.Range) protocol.CallHierarchyItem {
+	fset := pkg.GetFileSet()
+	loc := goRangeToLSPLocation(fset, fn.Pos(), fn.Name())
+	rng := loc.Range
+	if decl := funcDecl(pkg, fn); decl != nil {
+		rng = rangeForNode(fset, decl)
+	}
+
+	item := protocol.CallHierarchyItem{
+		Name:           fn.Name(),
+		Kind:           lsp.SKFunction,
+		URI:            loc.URI,
+		Range:          toUTF16(loc.URI, rng),
+		SelectionRange: toUTF16(loc.URI, loc.Range),
+	}
+	if fn.Pkg() != nil {
+		item.Detail = fn.Pkg().Path()
+	}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		item.Kind = lsp.SKMethod
+		item.Detail = types.TypeString(recv.Type(), nil)
+	}
+	return item
+}
+
+// funcDecl returns the declaration of the function or method fn among the
+// files of pkg, or nil if it is declared in another package.
+func funcDecl(pkg source.Package, fn *types.Func) *ast.FuncDecl {
+	for _, file := range pkg.GetSyntax() {
+		if fn.Pos() < file.Pos() || file.End() <= fn.Pos() {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Pos() == fn.Pos() {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// callerOf returns the declaration of the function or method of pkg in
+// which the identifier id is used, or nil if it is used outside of the
+// functions, and whether id is called there, eg. f in f() or s.f().
+func callerOf(pkg source.Package, id *ast.Ident) (*ast.FuncDecl, bool) {
+	var file *ast.File
+	for _, f := range pkg.GetSyntax() {
+		if f.Pos() <= id.Pos() && id.End() <= f.End() {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return nil, false
+	}
+
+	path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+	if len(path) == 0 || path[0] != id {
+		return nil, false
+	}
+	var node ast.Node = id
+	i := 1
+	if sel, ok := path[i].(*ast.SelectorExpr); ok && sel.Sel == id {
+		node = sel
+		i++
+	}
+	for i < len(path) {
+		paren, ok := path[i].(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		node = paren
+		i++
+	}
+	called := false
+	if i < len(path) {
+		call, ok := path[i].(*ast.CallExpr)
+		called = ok && call.Fun == node
+	}
+
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			return decl, called
+		}
+	}
+	return nil, called
+}
+
+// calledIdents returns the identifiers of the functions and methods called
+// in node, eg. f in f() or s.f().
+func calledIdents(node ast.Node) map[*ast.Ident]bool {
+	called := make(map[*ast.Ident]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		switch fun := astutil.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			called[fun] = true
+		case *ast.SelectorExpr:
+			called[fun.Sel] = true
+		}
+		return true
+	})
+	return called
+}
+
+// isCall reports whether a use of the function or method fn is a call of
+// it for the call hierarchy. The method values, eg. s.M in f(s.M), count
+// as calls, unlike the function values. The interface methods, whose calls
+// are dynamic, only count if interfaces is set.
+func isCall(fn *types.Func, called, interfaces bool) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return called
+	}
+	return interfaces || !types.IsInterface(recv.Type())
+}
+
+// sortRanges sorts the ranges of a document by their start.
+func sortRanges(ranges []lsp.Range) {
+	sort.Slice(ranges, func(i, j int) bool {
+		a, b := ranges[i].Start, ranges[j].Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Character < b.Character
+	})
+}
@@ -3,10 +3,15 @@ package langserver
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// codeRequestCancelled is the error code of the responses to the requests
+// cancelled by $/cancelRequest.
+const codeRequestCancelled int64 = -32800
+
 // cancel manages $/cancelRequest by keeping track of running commands
 type cancel struct {
 	mu *sync.Mutex
@@ -52,3 +57,31 @@ func (c *cancel) Cancel(id jsonrpc2.ID) {
 		cancel()
 	}
 }
+
+// wait waits until none of the tracked requests is running anymore. Once
+// ctx is done, it cancels those still running and returns.
+func (c *cancel) wait(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		c.mu.Lock()
+		running := len(c.m)
+		c.mu.Unlock()
+		if running == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			for id, cancel := range c.m {
+				delete(c.m, id)
+				cancel()
+			}
+			c.mu.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,6 +3,8 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"go/token"
+	"regexp"
 
 	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -23,24 +25,228 @@ func (h *LangHandler) handleCodeAction(ctx context.Context, conn jsonrpc2.JSONRP
 		return []protocol.CodeAction{}, nil
 	}
 
-	edits, err := organizeImports(ctx, h.View(), fileURI)
+	actions, err := h.quickFixes(ctx, fileURI, params.Context.Diagnostics)
 	if err != nil {
 		return nil, err
 	}
-	return []protocol.CodeAction{
-		{
-			Title: "Organize Imports",
-			Kind:  protocol.SourceOrganizeImports,
-			Edit: lsp.WorkspaceEdit{
-				Changes: map[string][]lsp.TextEdit{
-					string(params.TextDocument.URI): edits,
-				},
-			},
-		},
-	}, nil
+
+	refactorings, err := h.refactorings(ctx, fileURI, params.Range)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range refactorings {
+		// The fixes of a diagnostic may also apply at the range.
+		if !hasCodeAction(actions, action) {
+			actions = append(actions, action)
+		}
+	}
+
+	edits, err := organizeImports(ctx, h.View(), fileURI, h.packageIndex(), h.localPrefixes(fileURI))
+	if err != nil {
+		return nil, err
+	}
+	return append(actions, protocol.CodeAction{
+		Title: "Organize Imports",
+		Kind:  protocol.SourceOrganizeImports,
+		Edit: h.workspaceEdit(map[string][]lsp.TextEdit{
+			string(params.TextDocument.URI): edits,
+		}),
+	}), nil
+}
+
+var (
+	// unusedImport matches the message of the type checker for an unused
+	// import, eg. `"os" imported but not used`.
+	unusedImport = regexp.MustCompile(`^".+" imported (?:as \w+ )?(?:and|but) not used`)
+
+	// unusedVariable matches the message of the type checker for an
+	// unused variable, eg. "x declared but not used".
+	unusedVariable = regexp.MustCompile(`^(?:(\w+) declared (?:and|but) not used|declared (?:and|but) not used: (\w+))$`)
+
+	// notImplemented matches the message of the compiler for a type which
+	// does not implement an interface, eg. "*Server does not implement
+	// http.Handler (missing ServeHTTP method)", capturing both.
+	notImplemented = regexp.MustCompile(`(\*?[\w.]+) does not implement (\S+) \(missing (?:method )?\w+`)
+
+	// missingMethod matches the message of the type checker for a value
+	// which does not implement an interface, eg. "cannot use s (variable
+	// of type *Server) as net/http.Handler value in argument to
+	// http.Handle: missing method ServeHTTP", capturing the type of the
+	// value and the interface.
+	missingMethod = regexp.MustCompile(`^cannot use .+ \(.*type (\*?[\w.]+)\) as (\S+) value in .+: missing method \w+`)
+)
+
+// quickFixes returns the code actions fixing the diagnostics of the
+// document uri they apply to.
+func (h *LangHandler) quickFixes(ctx context.Context, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) ([]protocol.CodeAction, error) {
+	actions := []protocol.CodeAction{}
+	if len(diagnostics) == 0 {
+		return actions, nil
+	}
+
+	sourceURI, err := fromProtocolURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	f, err := h.View().GetFile(ctx, sourceURI)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.GetToken(ctx)
+	if tok == nil {
+		return nil, fmt.Errorf("token file does not exist for file %s", uri)
+	}
+	content := f.GetContent(ctx)
+	filename, err := sourceURI.Filename()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range diagnostics {
+		for _, fix := range h.overlay.suggestedFixes(filename, d) {
+			actions = append(actions, protocol.CodeAction{
+				Title:       fix.title,
+				Kind:        protocol.QuickFix,
+				Diagnostics: []lsp.Diagnostic{d},
+				Edit:        h.workspaceEdit(fix.edits),
+			})
+		}
+
+		pos := fromProtocolPosition(tok, fromUTF16Position(content, d.Range.Start))
+		if !pos.IsValid() {
+			continue
+		}
+		var fixes []source.QuickFix
+		if unusedImport.MatchString(d.Message) {
+			fixes = source.UnusedImportFixes(ctx, f, pos)
+		} else if m := unusedVariable.FindStringSubmatch(d.Message); m != nil {
+			fixes = source.UnusedVariableFixes(ctx, f, pos, m[1]+m[2])
+			fixes = append(fixes, source.ErrorCheckFixes(ctx, f, pos)...)
+		} else if m := notImplemented.FindStringSubmatch(d.Message); m != nil {
+			fixes = source.ImplementFixes(ctx, h.View(), f, m[1], m[2])
+		} else if m := missingMethod.FindStringSubmatch(d.Message); m != nil {
+			fixes = source.ImplementFixes(ctx, h.View(), f, m[1], m[2])
+		}
+		for _, fix := range fixes {
+			actions = append(actions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        protocol.QuickFix,
+				Diagnostics: []lsp.Diagnostic{d},
+				Edit:        h.workspaceEdit(h.fixChanges(ctx, uri, f, fix.Edits)),
+			})
+		}
+	}
+	return actions, nil
+}
+
+// refactorings returns the code actions rewriting the code of the document
+// uri at the range rng, and fixing the code there without a diagnostic.
+func (h *LangHandler) refactorings(ctx context.Context, uri lsp.DocumentURI, rng lsp.Range) ([]protocol.CodeAction, error) {
+	sourceURI, err := fromProtocolURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	f, err := h.View().GetFile(ctx, sourceURI)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.GetToken(ctx)
+	if tok == nil {
+		return nil, fmt.Errorf("token file does not exist for file %s", uri)
+	}
+	content := f.GetContent(ctx)
+
+	var actions []protocol.CodeAction
+	pos := fromProtocolPosition(tok, fromUTF16Position(content, rng.Start))
+	if !pos.IsValid() {
+		return actions, nil
+	}
+	add := func(kind protocol.CodeActionKind, fixes []source.QuickFix) {
+		for _, fix := range fixes {
+			actions = append(actions, protocol.CodeAction{
+				Title: fix.Title,
+				Kind:  kind,
+				Edit:  h.workspaceEdit(h.fixChanges(ctx, uri, f, fix.Edits)),
+			})
+		}
+	}
+	add(protocol.QuickFix, source.ErrorCheckFixes(ctx, f, pos))
+	add(protocol.RefactorRewrite, source.FillStruct(ctx, f, pos))
+	add(protocol.RefactorRewrite, source.ImplementInterfaces(ctx, h.View(), f, pos))
+
+	end := fromProtocolPosition(tok, fromUTF16Position(content, rng.End))
+	if !end.IsValid() || end < pos {
+		end = pos
+	}
+	add(protocol.RefactorRewrite, source.StructTagFixes(ctx, f, pos, end, h.getConfig().StructTagCase))
+
+	// The selections which can't be extracted have no extract actions.
+	if end == pos {
+		return actions, nil
+	}
+	for _, extract := range []func(context.Context, source.File, token.Pos, token.Pos) (source.QuickFix, error){
+		source.ExtractVariable,
+		source.ExtractFunction,
+	} {
+		if fix, err := extract(ctx, f, pos, end); err == nil {
+			add(protocol.RefactorExtract, []source.QuickFix{fix})
+		}
+	}
+	return actions, nil
+}
+
+// hasCodeAction reports whether actions has an action of the same kind and
+// title as action.
+func hasCodeAction(actions []protocol.CodeAction, action protocol.CodeAction) bool {
+	for _, a := range actions {
+		if a.Kind == action.Kind && a.Title == action.Title {
+			return true
+		}
+	}
+	return false
+}
+
+// fixChanges returns the edits of a fix by document. The edits of the file
+// f of the document uri are those of uri, the others those of the
+// documents of the files they change.
+func (h *LangHandler) fixChanges(ctx context.Context, uri lsp.DocumentURI, f source.File, edits []source.TextEdit) map[string][]lsp.TextEdit {
+	changes := make(map[string][]lsp.TextEdit)
+	for _, edit := range edits {
+		file, doc := f, string(uri)
+		if edit.Span.URI() != f.URI() {
+			other, err := h.View().GetFile(ctx, edit.Span.URI())
+			if err != nil {
+				continue
+			}
+			file, doc = other, string(edit.Span.URI())
+		}
+		changes[doc] = append(changes[doc], toProtocolEdits(ctx, file, []source.TextEdit{edit})...)
+	}
+	return changes
+}
+
+// handleTextDocumentWillSaveWaitUntil returns the edits organizing the
+// imports of the document about to be saved, if the imports are organized
+// on save.
+func (h *LangHandler) handleTextDocumentWillSaveWaitUntil(ctx context.Context, conn jsonrpc2.JSONRPC2,
+	req *jsonrpc2.Request, params protocol.WillSaveTextDocumentParams) ([]lsp.TextEdit, error) {
+	fileURI := params.TextDocument.URI
+
+	if err := checkFileURI(fileURI); err != nil {
+		return nil, err
+	}
+
+	if !h.getConfig().OrganizeImportsOnSave || !h.project.Contain(fileURI) {
+		return []lsp.TextEdit{}, nil
+	}
+
+	return organizeImports(ctx, h.View(), fileURI, h.packageIndex(), h.localPrefixes(fileURI))
 }
 
-func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([]lsp.TextEdit, error) {
+// organizeImports returns the edits adding the missing imports of the
+// document uri and removing the unused ones, grouped like source.Imports
+// does with the prefixes of local.
+func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI, index source.PackageIndex, local []string) ([]lsp.TextEdit, error) {
 	sourceURI, err := fromProtocolURI(uri)
 	if err != nil {
 		return nil, err
@@ -58,7 +264,7 @@ func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([
 		Start: tok.Pos(0),
 		End:   tok.Pos(tok.Size()),
 	}
-	edits, err := source.Imports(ctx, f, r)
+	edits, err := source.Imports(ctx, f, r, index, local)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,246 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// showReferencesCommand is the command of the clients showing the
+// references of a position, whose arguments are the URI of its document,
+// the position and the locations of the references.
+const showReferencesCommand = "editor.action.showReferences"
+
+// handleTextDocumentCodeLens returns the lenses running the go:generate
+// directives of the document and the tests of a test file, and the
+// unresolved lenses counting the references of the exported declarations
+// of the document if they are enabled.
+func (h *LangHandler) handleTextDocumentCodeLens(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CodeLensParams) ([]protocol.CodeLens, error) {
+	uri := params.TextDocument.URI
+	if err := checkFileURI(uri); err != nil {
+		return nil, err
+	}
+
+	lenses := []protocol.CodeLens{}
+	filename := util.UriToRealPath(uri)
+	content, err := h.project.FileContent(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if file == nil {
+		return nil, err
+	}
+
+	lenses = append(lenses, generateLenses(fset, file, content, filename)...)
+	if strings.HasSuffix(filename, "_test.go") {
+		lenses = append(lenses, testLenses(fset, file, content, filepath.Dir(filename))...)
+	}
+	if h.getConfig().CodeLensReferences {
+		lenses = append(lenses, referencesLenses(uri, fset, file, content)...)
+	}
+	return lenses, nil
+}
+
+// generateLenses returns the lenses running go generate for the file
+// filename and for its package, on each go:generate directive of the file.
+func generateLenses(fset *token.FileSet, file *ast.File, content []byte, filename string) []protocol.CodeLens {
+	var lenses []protocol.CodeLens
+	dir := filepath.Dir(filename)
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			// go generate only runs the directives starting a line.
+			if !isGenerateDirective(c.Text) || fset.Position(c.Pos()).Column != 1 {
+				continue
+			}
+			rng := toUTF16Range(content, rangeForNode(fset, c))
+			lenses = append(lenses, protocol.CodeLens{
+				Range: rng,
+				Command: &lsp.Command{
+					Title:     "run go generate",
+					Command:   generateCommand,
+					Arguments: []interface{}{dir, filename},
+				},
+			}, protocol.CodeLens{
+				Range: rng,
+				Command: &lsp.Command{
+					Title:     "run go generate for package",
+					Command:   generateCommand,
+					Arguments: []interface{}{dir},
+				},
+			})
+		}
+	}
+	return lenses
+}
+
+// isGenerateDirective reports whether the comment text is a go:generate
+// directive.
+func isGenerateDirective(text string) bool {
+	return strings.HasPrefix(text, "//go:generate ") || strings.HasPrefix(text, "//go:generate\t")
+}
+
+// testLenses returns the lenses running the tests of the package in dir,
+// above the package clause of its test file, and each test, benchmark and
+// example function of the file, above the function.
+func testLenses(fset *token.FileSet, file *ast.File, content []byte, dir string) []protocol.CodeLens {
+	var lenses []protocol.CodeLens
+	add := func(node ast.Node, title string, args ...interface{}) {
+		lenses = append(lenses, protocol.CodeLens{
+			Range: toUTF16Range(content, rangeForNode(fset, node)),
+			Command: &lsp.Command{
+				Title:     title,
+				Command:   testCommand,
+				Arguments: append([]interface{}{dir}, args...),
+			},
+		})
+	}
+	add(fakeNode{file.Package, file.Name.End()}, "run package tests")
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		pattern := "^" + fn.Name.Name + "$"
+		switch testFuncKind(fn) {
+		case "Test":
+			add(fn.Name, "run test", "-run", pattern)
+		case "Benchmark":
+			add(fn.Name, "run benchmark", "-bench", pattern)
+		case "Example":
+			add(fn.Name, "run example", "-run", pattern)
+		}
+	}
+	return lenses
+}
+
+// referencesLensData is the data of a references lens, identifying the
+// declaration whose references it counts once resolved.
+type referencesLensData struct {
+	URI      lsp.DocumentURI `json:"uri"`
+	Position lsp.Position    `json:"position"`
+}
+
+// referencesLenses returns the unresolved lenses counting the references
+// of the exported functions, methods and types of the document uri.
+func referencesLenses(uri lsp.DocumentURI, fset *token.FileSet, file *ast.File, content []byte) []protocol.CodeLens {
+	var lenses []protocol.CodeLens
+	add := func(name *ast.Ident) {
+		if !name.IsExported() {
+			return
+		}
+		rng := toUTF16Range(content, rangeForNode(fset, name))
+		lenses = append(lenses, protocol.CodeLens{
+			Range: rng,
+			Data:  referencesLensData{URI: uri, Position: rng.Start},
+		})
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			add(decl.Name)
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				add(spec.(*ast.TypeSpec).Name)
+			}
+		}
+	}
+	return lenses
+}
+
+// handleCodeLensResolve resolves a references lens, with the command
+// showing the references it counts.
+func (h *LangHandler) handleCodeLensResolve(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, lens protocol.CodeLens) (protocol.CodeLens, error) {
+	// The data is decoded as a map.
+	var data referencesLensData
+	raw, err := json.Marshal(lens.Data)
+	if err != nil {
+		return lens, err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil || data.URI == "" {
+		return lens, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "code lens without references data"}
+	}
+
+	locs, err := h.handleTextDocumentReferences(ctx, conn, req, lsp.ReferenceParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: data.URI},
+			Position:     data.Position,
+		},
+	})
+	if err != nil {
+		return lens, err
+	}
+	title := fmt.Sprintf("%d references", len(locs))
+	if len(locs) == 1 {
+		title = "1 reference"
+	}
+	lens.Command = &lsp.Command{
+		Title:     title,
+		Command:   showReferencesCommand,
+		Arguments: []interface{}{data.URI, data.Position, locs},
+	}
+	return lens, nil
+}
+
+// testFuncKind returns the kind of function go test runs fn as, "Test",
+// "Benchmark" or "Example", or "" if it does not run it.
+func testFuncKind(fn *ast.FuncDecl) string {
+	if fn.Recv != nil || fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
+		return ""
+	}
+	params := fn.Type.Params.List
+	for _, kind := range []string{"Test", "Benchmark", "Example"} {
+		if !isTestName(fn.Name.Name, kind) {
+			continue
+		}
+		if kind == "Example" {
+			if len(params) == 0 {
+				return kind
+			}
+			return ""
+		}
+		// The parameter is a *testing.T, or *testing.B.
+		if len(params) != 1 || len(params[0].Names) > 1 {
+			return ""
+		}
+		star, ok := params[0].Type.(*ast.StarExpr)
+		if !ok {
+			return ""
+		}
+		sel, ok := star.X.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != kind[:1] {
+			return ""
+		}
+		return kind
+	}
+	return ""
+}
+
+// isTestName reports whether name is prefix followed by a name which does
+// not start with a lower case letter, as go test requires.
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
@@ -0,0 +1,204 @@
+package langserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// testCommand runs go test in the directory of its first argument. The
+// optional other arguments are the flag selecting the functions to run,
+// -run or -bench, and its regexp.
+const testCommand = "bingo.test"
+
+// generateCommand runs go generate in the directory of its first argument,
+// for the package there, or the file of its optional second argument.
+const generateCommand = "bingo.generate"
+
+// commands are the commands of workspace/executeCommand.
+var commands = []string{testCommand, generateCommand}
+
+func (h *LangHandler) handleWorkspaceExecuteCommand(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ExecuteCommandParams) (interface{}, error) {
+	args := make([]string, len(params.Arguments))
+	for i, arg := range params.Arguments {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid argument %v of %s", arg, params.Command)}
+		}
+		args[i] = s
+	}
+
+	switch params.Command {
+	case testCommand:
+		return nil, h.runTests(ctx, conn, args)
+	case generateCommand:
+		return nil, h.runGenerate(ctx, conn, args)
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("command not supported: %s", params.Command)}
+}
+
+// runTests runs go test as testCommand does with args. The output is sent
+// to the client as it is written, and the result is shown to the user. A
+// run cancels the previous one.
+func (h *LangHandler) runTests(ctx context.Context, conn jsonrpc2.JSONRPC2, args []string) error {
+	if len(args) != 1 && len(args) != 3 {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("%s takes a directory and optionally -run or -bench with a regexp", testCommand)}
+	}
+	goArgs := append([]string{"test"}, h.getConfig().buildFlags()...)
+	if len(args) == 3 {
+		switch args[1] {
+		case "-run":
+			goArgs = append(goArgs, "-run", args[2])
+		case "-bench":
+			// Benchmarks run without the tests.
+			goArgs = append(goArgs, "-run", "^$", "-bench", args[2])
+		default:
+			return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid flag %s of %s", args[1], testCommand)}
+		}
+	}
+
+	ctx, done := h.runs.start(ctx, testCommand)
+	defer done()
+
+	name := "go " + strings.Join(goArgs, " ")
+	err := runCommand(ctx, args[0], h.project.Env(args[0]), goArgs, logOutput(conn))
+	switch {
+	case ctx.Err() != nil:
+		_ = conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: fmt.Sprintf("%s in %s canceled", name, args[0])})
+	case err != nil:
+		_ = conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{Type: lsp.MTError, Message: fmt.Sprintf("%s in %s failed: %s", name, args[0], err)})
+	default:
+		_ = conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: fmt.Sprintf("%s in %s passed", name, args[0])})
+	}
+	return nil
+}
+
+// runGenerate runs go generate as generateCommand does with args. The
+// output is sent to the client as it is written, and a failure is shown to
+// the user. The packages of the directory are loaded again afterwards, and
+// the diagnostics of its open documents published again, so that they use
+// the generated code.
+func (h *LangHandler) runGenerate(ctx context.Context, conn jsonrpc2.JSONRPC2, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("%s takes a directory and optionally a file of it", generateCommand)}
+	}
+	dir := args[0]
+	goArgs := append([]string{"generate"}, h.getConfig().buildFlags()...)
+	if len(args) == 2 {
+		if filepath.Dir(args[1]) != filepath.Clean(dir) {
+			return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("%s is not a file of %s", args[1], dir)}
+		}
+		goArgs = append(goArgs, filepath.Base(args[1]))
+	}
+
+	ctx, done := h.runs.start(ctx, generateCommand)
+	defer done()
+
+	name := "go " + strings.Join(goArgs, " ")
+	err := runCommand(ctx, dir, h.project.Env(dir), goArgs, logOutput(conn))
+	// The files generated before a failure are used all the same.
+	h.project.Invalidate(dir)
+	h.overlay.didGenerate(context.Background(), dir)
+	switch {
+	case ctx.Err() != nil:
+		_ = conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: fmt.Sprintf("%s in %s canceled", name, dir)})
+	case err != nil:
+		_ = conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{Type: lsp.MTError, Message: fmt.Sprintf("%s in %s failed: %s", name, dir, err)})
+	default:
+		_ = conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: fmt.Sprintf("%s in %s succeeded", name, dir)})
+	}
+	return nil
+}
+
+// logOutput returns the function sending the lines of the output of a
+// command to the client as log messages.
+func logOutput(conn jsonrpc2.JSONRPC2) func(string) {
+	return func(line string) {
+		_ = conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Log, Message: line})
+	}
+}
+
+// runCommand runs go with args in dir and the environment env, calling
+// output with each line it writes to its standard output and error. If ctx
+// is done, go is killed, and the output of the processes it started, eg. a
+// test binary, is not waited for.
+func runCommand(ctx context.Context, dir string, env, args []string, output func(string)) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout, cmd.Stderr = w, w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		r.Close()
+		return err
+	}
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			output(scanner.Text())
+		}
+	}()
+	select {
+	case <-read:
+	case <-ctx.Done():
+	}
+	r.Close()
+	return cmd.Wait()
+}
+
+// commandRuns tracks the runs of the commands by name, so that running a
+// command again cancels its previous run.
+type commandRuns struct {
+	mu   sync.Mutex
+	runs map[string]*commandRun
+}
+
+type commandRun struct {
+	cancel func()
+	done   chan struct{}
+}
+
+// start returns the context of a new run of the command name, derived from
+// ctx, along with the function ending the run. The previous run is
+// canceled, and has ended when start returns.
+func (r *commandRuns) start(ctx context.Context, name string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	run := &commandRun{cancel: cancel, done: make(chan struct{})}
+	r.mu.Lock()
+	if r.runs == nil {
+		r.runs = make(map[string]*commandRun)
+	}
+	prev := r.runs[name]
+	r.runs[name] = run
+	r.mu.Unlock()
+
+	if prev != nil {
+		prev.cancel()
+		<-prev.done
+	}
+	return ctx, func() {
+		r.mu.Lock()
+		if r.runs[name] == run {
+			delete(r.runs, name)
+		}
+		r.mu.Unlock()
+		cancel()
+		close(run.done)
+	}
+}
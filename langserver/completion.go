@@ -7,17 +7,24 @@ package langserver
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/fuzzy"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*lsp.CompletionList, error) {
+func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*protocol.CompletionList, error) {
 	fileURI := params.TextDocument.URI
 	if err := checkFileURI(fileURI); err != nil {
 		return nil, nil
@@ -32,7 +39,14 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", fileURI))
 	}
 
-	pos := fromProtocolPosition(tok, params.Position)
+	pos := fromProtocolPosition(tok, fromUTF16Position(f.GetContent(ctx), params.Position))
+	if file := f.GetAST(ctx); file != nil {
+		if lit := importPathAt(file, pos); lit != nil {
+			typed := string(f.GetContent(ctx)[tok.Offset(lit.Pos())+1 : tok.Offset(pos)])
+			return h.completeImportPath(typed, f.GetPackage(ctx).GetPkgPath(), params.Position), nil
+		}
+	}
+
 	items, prefix, err := source.Completion(ctx, f, pos, h.project.Cache())
 	if err != nil {
 		return nil, err
@@ -42,43 +56,192 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 		return nil, ctx.Err()
 	}
 
-	useSnippets := h.clientSupportsSnippets() && !h.config.DisableFuncSnippet
-	result := &lsp.CompletionList{
+	dataOf := func(candidate source.CompletionItem) *completionData {
+		obj := candidate.Object
+		if _, ok := obj.(*types.PkgName); ok || obj == nil {
+			return nil
+		}
+		data := &completionData{
+			URI:        fileURI,
+			Name:       obj.Name(),
+			Filename:   candidate.Declaration.Filename,
+			Offset:     candidate.Declaration.Offset,
+			ImportPath: candidate.ImportPath,
+		}
+		if obj.Pkg() != nil {
+			data.PkgPath = obj.Pkg().Path()
+		}
+		return data
+	}
+
+	useSnippets := h.clientSupportsSnippets() && !h.getConfig().DisableFuncSnippet
+	result := &protocol.CompletionList{
 		IsIncomplete: false,
-		Items:        toProtocolCompletionItems(items, prefix, params.Position, useSnippets, false),
+		Items:        toProtocolCompletionItems(items, prefix, params.Position, useSnippets, false, dataOf),
 	}
 	return result, nil
 }
 
+// completionData is the data of a completion item, which tells
+// completionItem/resolve which object to describe: the object Name,
+// declared at Offset in Filename in the package PkgPath, completed in the
+// document URI. PkgPath is empty for predeclared objects.
+type completionData struct {
+	URI      lsp.DocumentURI `json:"uri"`
+	PkgPath  string          `json:"pkgPath,omitempty"`
+	Name     string          `json:"name"`
+	Filename string          `json:"filename,omitempty"`
+	Offset   int             `json:"offset,omitempty"`
+
+	// ImportPath is the path of the package the document must import to
+	// use the item, if it may not import it yet.
+	ImportPath string `json:"importPath,omitempty"`
+}
+
+// handleCompletionItemResolve completes the item with its detail, its
+// documentation and the edit importing its package, which the completion
+// leaves out to answer quickly.
+func (h *LangHandler) handleCompletionItemResolve(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.CompletionItem) (protocol.CompletionItem, error) {
+	if params.Data == nil {
+		return params, nil
+	}
+	// The data was decoded as a generic JSON value.
+	b, err := json.Marshal(params.Data)
+	if err != nil {
+		return params, err
+	}
+	var data completionData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return params, err
+	}
+
+	f, err := h.View().GetFile(ctx, span.FromDocumentURI(data.URI))
+	if err != nil {
+		return params, err
+	}
+	pos := token.Position{Filename: data.Filename, Offset: data.Offset}
+	detail, doc, err := source.ResolveCompletionItem(ctx, f, data.PkgPath, data.Name, pos, h.project.Cache())
+	if err != nil {
+		return params, err
+	}
+	params.Detail, params.Documentation = detail, doc
+
+	if file := f.GetAST(ctx); data.ImportPath != "" && file != nil && !importsPath(file, data.ImportPath) {
+		edit := importEdit(f.GetFileSet(ctx), file, data.ImportPath)
+		edit.Range = toUTF16Range(f.GetContent(ctx), edit.Range)
+		params.AdditionalTextEdits = []lsp.TextEdit{edit}
+		// The import path tells apart the members of packages of the
+		// same name.
+		params.Detail = strings.TrimSpace(fmt.Sprintf("%s (from %q)", params.Detail, data.ImportPath))
+	}
+	return params, nil
+}
+
+// importsPath reports whether file imports the package path.
+func importsPath(file *ast.File, path string) bool {
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// importEdit returns the edit adding the import of the package path to
+// file: in sorted position in its first import declaration, which is
+// parenthesized if needed, or in a new declaration after the package
+// clause if there is none.
+func importEdit(fset *token.FileSet, file *ast.File, path string) lsp.TextEdit {
+	var decl *ast.GenDecl
+	for _, d := range file.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			decl = d
+			break
+		}
+	}
+
+	quoted := strconv.Quote(path)
+	if decl == nil {
+		at := createLocationFromRange(fset, file.Name.End(), file.Name.End()).Range
+		return lsp.TextEdit{Range: at, NewText: "\n\nimport (\n\t" + quoted + "\n)"}
+	}
+
+	if !decl.Lparen.IsValid() {
+		specs := []string{"\t" + quoted}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ImportSpec)
+			line := spec.Path.Value
+			if spec.Name != nil {
+				line = spec.Name.Name + " " + line
+			}
+			specs = append(specs, "\t"+line)
+		}
+		sort.Slice(specs, func(i, j int) bool {
+			return importSpecPath(specs[i]) < importSpecPath(specs[j])
+		})
+		return lsp.TextEdit{
+			Range:   rangeForNode(fset, decl),
+			NewText: "import (\n" + strings.Join(specs, "\n") + "\n)",
+		}
+	}
+
+	// Insert the import at the start of the line of the first import
+	// sorted after it, or of the closing parenthesis.
+	at := decl.Rparen
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ImportSpec)
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p > path {
+			at = spec.Pos()
+			break
+		}
+	}
+	start := fset.Position(at)
+	pos := lsp.Position{Line: start.Line - 1}
+	return lsp.TextEdit{Range: lsp.Range{Start: pos, End: pos}, NewText: "\t" + quoted + "\n"}
+}
+
+// importSpecPath returns the path of the formatted import spec line.
+func importSpecPath(line string) string {
+	path, _ := strconv.Unquote(line[strings.Index(line, `"`):])
+	return path
+}
+
 func (h *LangHandler) clientSupportsSnippets() bool {
 	return h.init != nil && h.init.Capabilities.TextDocument.Completion.CompletionItem.SnippetSupport
 }
 
-func getLspRange(pos lsp.Position, rangeLen int) lsp.Range {
+// getLspRange returns the range of the text typed before the protocol
+// position pos, whose character offset counts UTF-16 code units.
+func getLspRange(pos lsp.Position, typed string) lsp.Range {
 	return lsp.Range{
-		Start: lsp.Position{Line: pos.Line, Character: pos.Character - rangeLen},
+		Start: lsp.Position{Line: pos.Line, Character: pos.Character - utf16Count(typed)},
 		End:   lsp.Position{Line: pos.Line, Character: pos.Character},
 	}
 }
 
-func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool) []lsp.CompletionItem {
+// toProtocolCompletionItems converts the candidates matching prefix to
+// completion items. dataOf returns the data resolving the candidates
+// completing an object, which are sent without their detail and
+// documentation.
+func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool,
+	dataOf func(source.CompletionItem) *completionData) []protocol.CompletionItem {
 	insertTextFormat := lsp.ITFPlainText
 	if snippetsSupported {
 		insertTextFormat = lsp.ITFSnippet
 	}
-	sort.SliceStable(candidates, func(i, j int) bool {
-		return candidates[i].Score > candidates[j].Score
-	})
-	items := []lsp.CompletionItem{}
+	candidates, names := rankCompletionItems(candidates, prefix)
+	items := []protocol.CompletionItem{}
 	for i, candidate := range candidates {
-		// Matching against the label.
-		if !strings.HasPrefix(candidate.Label, prefix) {
-			continue
-		}
 		// InsertText is deprecated in favor of TextEdits.
 		// TODO(rstambler): Remove this logic when we are confident that we no
 		// longer need to support it.
 		insertText, _ := labelToProtocolSnippets(candidate.Label, candidate.Kind, insertTextFormat, signatureHelpEnabled)
+		if candidate.InsertText != "" {
+			insertText = candidate.InsertText
+		}
+		if candidate.Snippet != "" && snippetsSupported {
+			insertText = candidate.Snippet
+		}
 		//if strings.HasPrefix(insertText, prefix) {
 		//	insertText = insertText[len(prefix):]
 		//}
@@ -88,7 +251,7 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 			Kind:             toProtocolCompletionItemKind(candidate.Kind),
 			TextEdit: &lsp.TextEdit{
 				NewText: insertText,
-				Range:   getLspRange(pos, len(prefix)),
+				Range:   getLspRange(pos, prefix),
 			},
 			
 			InsertTextFormat: insertTextFormat,
@@ -98,6 +261,7 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 			// according to their score. This can be removed upon the resolution of
 			// https://github.com/Microsoft/language-server-protocol/issues/348.
 			SortText:   fmt.Sprintf("%05d", i),
+			FilterText: names[i],
 			Documentation: candidate.Documentation,
 		}
 		// If we are completing a function, we should trigger signature help if possible.
@@ -106,11 +270,66 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 		//		Command: "editor.action.triggerParameterHints",
 		//	}
 		//}
-		items = append(items, item)
+		if dataOf != nil {
+			if data := dataOf(candidate); data != nil {
+				item.Detail, item.Documentation, item.Data = "", "", data
+			}
+		}
+		items = append(items, protocol.CompletionItem{CompletionItem: item})
 	}
 	return items
 }
 
+// rankCompletionItems returns the candidates whose name fuzzily matches
+// prefix, along with their names, ordered by the score of the match, then
+// by the relevance of the candidates, like the match of their type with the
+// expected one, then by the locality of their declaration, then by label.
+func rankCompletionItems(candidates []source.CompletionItem, prefix string) ([]source.CompletionItem, []string) {
+	type match struct {
+		candidate source.CompletionItem
+		name      string
+		score     float64
+	}
+	var matches []match
+	for _, candidate := range candidates {
+		name := completionName(candidate.Label)
+		if score, ok := fuzzy.Score(prefix, name); ok {
+			matches = append(matches, match{candidate: candidate, name: name, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case a.score != b.score:
+			return a.score > b.score
+		case a.candidate.Score != b.candidate.Score:
+			return a.candidate.Score > b.candidate.Score
+		case a.candidate.Locality != b.candidate.Locality:
+			return a.candidate.Locality < b.candidate.Locality
+		}
+		return a.candidate.Label < b.candidate.Label
+	})
+
+	ranked := make([]source.CompletionItem, len(matches))
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		ranked[i], names[i] = m.candidate, m.name
+	}
+	return ranked, names
+}
+
+// completionName returns the name of the completion item labeled label,
+// without the parameters of functions, the values of constants or the
+// operator and the braces of composite literals.
+func completionName(label string) string {
+	label = strings.TrimPrefix(label, "&")
+	if i := strings.IndexAny(label, "( {"); i >= 0 {
+		return label[:i]
+	}
+	return label
+}
+
 func toProtocolCompletionItemKind(kind source.CompletionItemKind) lsp.CompletionItemKind {
 	switch kind {
 	case source.InterfaceCompletionItem:
@@ -131,6 +350,8 @@ func toProtocolCompletionItemKind(kind source.CompletionItemKind) lsp.Completion
 		return lsp.CIKMethod
 	case source.PackageCompletionItem:
 		return lsp.CIKModule // ??
+	case source.KeywordCompletionItem:
+		return lsp.CIKKeyword
 	default:
 		return lsp.CIKText
 	}
@@ -177,8 +398,14 @@ func labelToProtocolSnippets(label string, kind source.CompletionItemKind, inser
 			if i != 0 {
 				b.WriteString(", ")
 			}
-			paramName := strings.Split(strings.Trim(p, " "), " ")[0]
-			fmt.Fprintf(b, "${%v:%v}", i+1, r.Replace(paramName))
+			fields := strings.Fields(p)
+			if strings.HasPrefix(fields[0], "...") || len(fields) > 1 && strings.HasPrefix(fields[1], "...") {
+				// A variadic parameter gets an empty tab stop, as any
+				// number of arguments may be passed to it.
+				fmt.Fprintf(b, "${%v}", i+1)
+				continue
+			}
+			fmt.Fprintf(b, "${%v:%v}", i+1, r.Replace(fields[0]))
 		}
 		fmt.Fprintf(b, ")$0")
 		return b.String(), false
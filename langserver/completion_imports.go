@@ -0,0 +1,199 @@
+package langserver
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// maxImportPathItems is the maximum number of import paths completed at
+// once. The completion list is incomplete beyond, so that the client asks
+// for the next ones as the user types.
+const maxImportPathItems = 100
+
+// importPathIndex indexes the packages the workspace may import to
+// complete import paths. It is built on first use, and rebuilt when the
+// roots of the packages change, like after an edit of go.mod.
+type importPathIndex struct {
+	mu    sync.Mutex
+	roots []cache.ImportRoot
+	pkgs  []importablePackage
+}
+
+// importablePackage is a package of the index, in the directory dir.
+type importablePackage struct {
+	path, dir string
+}
+
+// packages returns the packages of roots, sorted by import path.
+func (x *importPathIndex) packages(roots []cache.ImportRoot) []importablePackage {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.pkgs != nil && reflect.DeepEqual(x.roots, roots) {
+		return x.pkgs
+	}
+
+	seen := make(map[string]bool)
+	pkgs := []importablePackage{}
+	for _, root := range roots {
+		for _, pkg := range walkImportRoot(root) {
+			if !seen[pkg.path] {
+				seen[pkg.path] = true
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].path < pkgs[j].path
+	})
+	x.roots, x.pkgs = roots, pkgs
+	return pkgs
+}
+
+// walkImportRoot returns the packages of root, skipping the directories
+// ignored by the go command, the nested modules, the commands of the
+// standard library and the internal packages the workspace cannot import.
+func walkImportRoot(root cache.ImportRoot) []importablePackage {
+	var pkgs []importablePackage
+	seen := make(map[string]bool)
+	_ = filepath.Walk(root.Dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := fi.Name()
+		if fi.IsDir() {
+			if path == root.Dir {
+				return nil
+			}
+			switch {
+			case name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_"),
+				name == "internal" && !root.Main,
+				root.Path == "" && path == filepath.Join(root.Dir, "cmd"):
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+
+		// The files of a directory and its subdirectories are walked in
+		// lexical order, so they may be interleaved.
+		dir := filepath.Dir(path)
+		if seen[dir] {
+			return nil
+		}
+		seen[dir] = true
+		rel, err := filepath.Rel(root.Dir, dir)
+		if err != nil {
+			return nil
+		}
+		importPath := root.Path
+		switch {
+		case rel == ".":
+		case importPath == "":
+			importPath = filepath.ToSlash(rel)
+		default:
+			importPath += "/" + filepath.ToSlash(rel)
+		}
+		if importPath != "" {
+			pkgs = append(pkgs, importablePackage{path: importPath, dir: dir})
+		}
+		return nil
+	})
+	return pkgs
+}
+
+// importPathAt returns the path of the import spec of file enclosing pos,
+// if any.
+func importPathAt(file *ast.File, pos token.Pos) *ast.BasicLit {
+	for _, spec := range file.Imports {
+		if lit := spec.Path; lit.Pos() < pos && pos <= lit.End() {
+			if pos == lit.End() && len(lit.Value) > 1 && strings.HasSuffix(lit.Value, lit.Value[:1]) {
+				// The position follows the closing quote.
+				return nil
+			}
+			return lit
+		}
+	}
+	return nil
+}
+
+// completeImportPath returns the completions of an import path of the
+// package pkgPath, of which typed is the text before the cursor at
+// position. The import paths match typed segment by segment, like
+// encoding/json matches "enc/js", preferably from their first segment.
+func (h *LangHandler) completeImportPath(typed string, pkgPath string, position lsp.Position) *protocol.CompletionList {
+	type match struct {
+		pkg      importablePackage
+		anchored bool
+	}
+	var matches []match
+	for _, pkg := range h.importPaths.packages(h.project.ImportRoots()) {
+		if pkg.path == pkgPath {
+			continue
+		}
+		if anchored, ok := matchImportPath(typed, pkg.path); ok {
+			matches = append(matches, match{pkg: pkg, anchored: anchored})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.anchored != b.anchored {
+			return a.anchored
+		}
+		return strings.Count(a.pkg.path, "/") < strings.Count(b.pkg.path, "/")
+	})
+
+	list := &protocol.CompletionList{IsIncomplete: len(matches) > maxImportPathItems, Items: []protocol.CompletionItem{}}
+	if len(matches) > maxImportPathItems {
+		matches = matches[:maxImportPathItems]
+	}
+	for i, m := range matches {
+		list.Items = append(list.Items, protocol.CompletionItem{CompletionItem: lsp.CompletionItem{
+			Label:    m.pkg.path,
+			Kind:     lsp.CIKModule,
+			Detail:   h.project.PackageSynopsis(m.pkg.dir),
+			SortText: fmt.Sprintf("%05d", i),
+			TextEdit: &lsp.TextEdit{
+				NewText: m.pkg.path,
+				Range:   getLspRange(position, typed),
+			},
+		}})
+	}
+	return list
+}
+
+// matchImportPath reports whether the segments of typed are prefixes of
+// consecutive segments of the import path, and whether they match from
+// its first segment.
+func matchImportPath(typed, path string) (anchored, ok bool) {
+	want, segments := strings.Split(typed, "/"), strings.Split(path, "/")
+	for start := 0; start+len(want) <= len(segments); start++ {
+		ok := true
+		for i, w := range want {
+			if !strings.HasPrefix(segments[start+i], w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return start == 0, true
+		}
+	}
+	return false, false
+}
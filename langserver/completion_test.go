@@ -0,0 +1,42 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestCompletionSnippets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		label   string
+		kind    source.CompletionItemKind
+		snippet string
+		plain   string
+	}{
+		{"Foo(ctx context.Context, n int)", source.FunctionCompletionItem, "Foo(${1:ctx}, ${2:n})$0", "Foo"},
+		{"Printf(format string, a ...interface{})", source.FunctionCompletionItem, "Printf(${1:format}, ${2})$0", "Printf"},
+		{"Append(...int)", source.MethodCompletionItem, "Append(${1})$0", "Append"},
+		{"Walk(fn func(a int, b int) error)", source.MethodCompletionItem, "Walk(${1:fn})$0", "Walk"},
+		{"Close()", source.MethodCompletionItem, "Close()", "Close()"},
+		{"s1 = 42", source.ConstantCompletionItem, "s1", "s1"},
+	}
+	for _, test := range tests {
+		candidates := []source.CompletionItem{{Label: test.label, Kind: test.kind}}
+		for _, snippets := range []bool{true, false} {
+			want, wantFormat := test.plain, lsp.ITFPlainText
+			if snippets {
+				want, wantFormat = test.snippet, lsp.ITFSnippet
+			}
+			items := toProtocolCompletionItems(candidates, "", lsp.Position{}, snippets, false, nil)
+			if len(items) != 1 {
+				t.Fatalf("%s: got %d items, want 1", test.label, len(items))
+			}
+			if got := items[0]; got.InsertText != want || got.TextEdit.NewText != want || got.InsertTextFormat != wantFormat {
+				t.Errorf("%s (snippets: %t): got %q (%v), want %q (%v)", test.label, snippets, got.InsertText, got.InsertTextFormat, want, wantFormat)
+			}
+		}
+	}
+}
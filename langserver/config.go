@@ -1,17 +1,24 @@
 package langserver
 
 import (
+	"fmt"
+	"os"
 	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
 )
 
 // Config adjusts the behaviour of go-langserver. Please keep in sync with
 // InitializationOptions in the README.
 type Config struct {
-	// DisableFuncSnippet enables the returning of argument snippets on `func`
-	// completions, eg. func(foo string, arg2 bar). Requires code complete
-	// to be enabled.
+	// DisableFuncSnippet disables the snippets of `func` completions, which
+	// insert the call with a placeholder for each parameter, eg.
+	// Foo(${1:ctx}, ${2:n}), when the client supports snippets. Can be
+	// overridden by InitializationOptions.
 	//
-	// Defaults to true if not specified.
+	// Defaults to false if not specified.
 	DisableFuncSnippet bool
 
 	// EnableGlobalCache enable global cache when hover, reference, definition. Can be overridden by InitializationOptions.
@@ -24,16 +31,68 @@ type Config struct {
 	// Defaults to false if not specified.
 	DiagnosticsStyle string
 
-	// FormatStyle format style
+	// VetOnSave runs the vet analyzers over the package of a document when
+	// it is saved, and publishes their findings as warnings along with the
+	// diagnostics of the compiler. Can be overridden by
+	// InitializationOptions.
+	//
+	// Defaults to true if not specified.
+	VetOnSave bool
+
+	// VetAnalyzers are the names of the vet analyzers VetOnSave runs, eg.
+	// "printf" or "structtag".
+	//
+	// Defaults to the whole vet suite if empty.
+	VetAnalyzers []string
+
+	// Analyses enables or disables the registered analyzers by name, eg.
+	// {"shadow": true, "printf": false}. The analyzers not named run if
+	// they are enabled by default, see RegisterAnalyzer.
+	Analyses map[string]bool
+
+	// FormatStyle is the tool formatting the documents: "gofmt", or
+	// "goimports" which also adds and removes imports. Can be overridden
+	// by InitializationOptions.
 	//
 	// Defaults to "gofmt" if not secified
 	FormatStyle string
 
-	// GoimportsLocalPrefix sets the local prefix (comma-separated string) that goimports will use
+	// GoimportsLocalPrefix sets the local prefix (comma-separated string) that goimports will use.
+	// Organizing imports groups the packages whose path starts with one of
+	// the prefixes after the other packages, along with those of the module
+	// of the document. Can be overridden by InitializationOptions.
 	//
 	// Defaults to empty string if not specified.
 	GoimportsLocalPrefix string
 
+	// OrganizeImportsOnSave organizes the imports of a document before it
+	// is saved, for the clients sending textDocument/willSaveWaitUntil.
+	//
+	// Defaults to false if not specified.
+	OrganizeImportsOnSave bool
+
+	// CodeLensReferences shows the number of references of the exported
+	// functions, methods and types in code lenses, which are resolved
+	// lazily.
+	//
+	// Defaults to false if not specified.
+	CodeLensReferences bool
+
+	// StructTagCase is the case of the names of the tags the code actions
+	// add to struct fields: "snakecase", eg. field_name, "camelcase", eg.
+	// fieldName, "lispcase", eg. field-name, "pascalcase", eg. FieldName,
+	// or "keep" for the name of the field.
+	//
+	// Defaults to "snakecase" if not specified.
+	StructTagCase string
+
+	// DocumentLinkTarget is the base URL of the documentation the import
+	// paths of the documents link to, eg. a private godoc instance. The
+	// link of an import path is the URL followed by the path.
+	//
+	// Defaults to "https://pkg.go.dev" if not specified.
+	DocumentLinkTarget string
+
 	// MaxParallelism controls the maximum number of goroutines that should be used
 	// to fulfill requests. This is useful in editor environments where users do
 	// not want results ASAP, but rather just semi quickly without eating all of
@@ -51,6 +110,79 @@ type Config struct {
 	//
 	// Defaults to empty
 	BuildTags []string
+
+	// Env are environment variables the go command loads the packages
+	// with, in addition to those of the server, eg. GOFLAGS or GOOS.
+	//
+	// Defaults to empty
+	Env map[string]string
+
+	// FollowLineDirectives makes navigation follow //line directives to the
+	// file they refer to, if it exists, instead of the generated file.
+	//
+	// Defaults to false
+	FollowLineDirectives bool
+
+	// HoverShowStructInfo shows the size of struct types and the offsets
+	// of their fields on hover, as laid out for the GOARCH packages are
+	// type checked for.
+	//
+	// Defaults to false
+	HoverShowStructInfo bool
+
+	// InterfaceReferences extends the references of a method to the
+	// methods related to it through an interface: the references of an
+	// interface method include those of the methods implementing it, and
+	// the references of a concrete method include the calls through the
+	// interfaces it implements. Related methods may be declared and used
+	// anywhere, so this searches all cached packages rather than only the
+	// dependents of the package of the method, which is slower on large
+	// workspaces.
+	//
+	// Defaults to false
+	InterfaceReferences bool
+
+	// CallHierarchyInterfaceCalls includes the dynamic calls through
+	// interfaces in the call hierarchy: the outgoing calls of a function
+	// include the calls of interface methods, and the incoming calls of a
+	// method include the calls of the interface methods it implements. The
+	// methods are matched by their method sets, a best-effort match which
+	// may include methods the calls never reach.
+	//
+	// Defaults to false
+	CallHierarchyInterfaceCalls bool
+
+	// MaxReferences is the maximum number of locations returned by a
+	// references request. Once it is exceeded the search stops, and the
+	// user is warned that the results are truncated. 0 means unlimited.
+	//
+	// Defaults to 5000
+	MaxReferences int
+
+	// MaxWorkspaceSymbols is the maximum number of symbols returned by a
+	// workspace/symbol request which does not set its own limit.
+	//
+	// Defaults to 100
+	MaxWorkspaceSymbols int
+
+	// WatchFiles watches the Go files, go.mod and go.sum files of the
+	// workspace with the file system notifications of the server when the
+	// client cannot be asked to watch them, so that the packages changed
+	// outside of the editor, eg. by a git checkout, are loaded again.
+	//
+	// Defaults to false
+	WatchFiles bool
+
+	// MaxCacheMemoryMB is the memory budget of the cache of the packages
+	// in megabytes, as estimated from the size of their source. Beyond it
+	// the syntax of the least recently used packages is evicted, then the
+	// packages themselves, except those of the open files. The evictions
+	// are logged along with the memory of the cache. The references load
+	// the evicted packages they search again, the other searches skip them
+	// and warn the user. 0 means unlimited.
+	//
+	// Defaults to 2048
+	MaxCacheMemoryMB int
 }
 
 // Apply sets the corresponding field in c for each non-nil field in o.
@@ -66,6 +198,18 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.DiagnosticsStyle = *o.DiagnosticsStyle
 	}
 
+	if o.VetOnSave != nil {
+		c.VetOnSave = *o.VetOnSave
+	}
+
+	if o.VetAnalyzers != nil {
+		c.VetAnalyzers = o.VetAnalyzers
+	}
+
+	if o.Analyses != nil {
+		c.Analyses = o.Analyses
+	}
+
 	if o.GlobalCacheStyle != nil {
 		c.GlobalCacheStyle = *o.GlobalCacheStyle
 	}
@@ -74,6 +218,10 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.FormatStyle = *o.FormatStyle
 	}
 
+	if o.FormatTool != nil {
+		c.FormatStyle = *o.FormatTool
+	}
+
 	if o.EnhanceSignatureHelp != nil {
 		c.EnhanceSignatureHelp = *o.EnhanceSignatureHelp
 	}
@@ -82,6 +230,26 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.GoimportsLocalPrefix = *o.GoimportsLocalPrefix
 	}
 
+	if o.Local != nil {
+		c.GoimportsLocalPrefix = strings.Join(o.Local, ",")
+	}
+
+	if o.OrganizeImportsOnSave != nil {
+		c.OrganizeImportsOnSave = *o.OrganizeImportsOnSave
+	}
+
+	if o.CodeLensReferences != nil {
+		c.CodeLensReferences = *o.CodeLensReferences
+	}
+
+	if o.StructTagCase != nil {
+		c.StructTagCase = *o.StructTagCase
+	}
+
+	if o.DocumentLinkTarget != nil {
+		c.DocumentLinkTarget = *o.DocumentLinkTarget
+	}
+
 	if o.MaxParallelism != nil {
 		c.MaxParallelism = *o.MaxParallelism
 	}
@@ -90,9 +258,122 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.BuildTags = o.BuildTags
 	}
 
+	if o.Env != nil {
+		c.Env = o.Env
+	}
+
+	if o.FollowLineDirectives != nil {
+		c.FollowLineDirectives = *o.FollowLineDirectives
+	}
+
+	if o.HoverShowStructInfo != nil {
+		c.HoverShowStructInfo = *o.HoverShowStructInfo
+	}
+
+	if o.InterfaceReferences != nil {
+		c.InterfaceReferences = *o.InterfaceReferences
+	}
+
+	if o.CallHierarchyInterfaceCalls != nil {
+		c.CallHierarchyInterfaceCalls = *o.CallHierarchyInterfaceCalls
+	}
+
+	if o.MaxReferences != nil {
+		c.MaxReferences = *o.MaxReferences
+	}
+
+	if o.MaxWorkspaceSymbols != nil {
+		c.MaxWorkspaceSymbols = *o.MaxWorkspaceSymbols
+	}
+
+	if o.WatchFiles != nil {
+		c.WatchFiles = *o.WatchFiles
+	}
+
+	if o.MaxCacheMemoryMB != nil {
+		c.MaxCacheMemoryMB = *o.MaxCacheMemoryMB
+	}
+
 	return c
 }
 
+// validate returns an error describing the first invalid setting of c.
+func (c *Config) validate() error {
+	switch DiagnosticsStyleEnum(c.DiagnosticsStyle) {
+	case "", noneDiagnostics, onsaveDiagnostics, instantDiagnostics:
+	default:
+		return fmt.Errorf("invalid diagnosticsStyle %q", c.DiagnosticsStyle)
+	}
+	switch cache.CacheStyle(c.GlobalCacheStyle) {
+	case "", cache.None, cache.Ondemand, cache.Always:
+	default:
+		return fmt.Errorf("invalid globalCacheStyle %q", c.GlobalCacheStyle)
+	}
+	switch c.FormatStyle {
+	case "", "gofmt", goimportsStyle:
+	default:
+		return fmt.Errorf("invalid formatTool %q", c.FormatStyle)
+	}
+	switch c.StructTagCase {
+	case "", "snakecase", "camelcase", "lispcase", "pascalcase", "keep":
+	default:
+		return fmt.Errorf("invalid structTagCase %q", c.StructTagCase)
+	}
+	if c.MaxParallelism < 1 {
+		return fmt.Errorf("invalid maxParallelism %d", c.MaxParallelism)
+	}
+	if c.MaxReferences < 0 {
+		return fmt.Errorf("invalid maxReferences %d", c.MaxReferences)
+	}
+	if c.MaxWorkspaceSymbols < 0 {
+		return fmt.Errorf("invalid maxWorkspaceSymbols %d", c.MaxWorkspaceSymbols)
+	}
+	if c.MaxCacheMemoryMB < 0 {
+		return fmt.Errorf("invalid maxCacheMemoryMB %d", c.MaxCacheMemoryMB)
+	}
+	for _, tag := range c.BuildTags {
+		if tag == "" || strings.ContainsAny(tag, " \t,\"'") {
+			return fmt.Errorf("invalid build tag %q", tag)
+		}
+	}
+	for key := range c.Env {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("invalid environment variable %q", key)
+		}
+	}
+	if unknown := unknownAnalyzers(c); len(unknown) > 0 {
+		return fmt.Errorf("unknown analyzers: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// buildFlags returns the flags of the go command loading the packages.
+func (c *Config) buildFlags() []string {
+	buildFlags := []string{}
+	if len(c.BuildTags) > 0 {
+		buildFlags = append(buildFlags, "-tags", strings.Join(c.BuildTags, " "))
+	}
+	return buildFlags
+}
+
+// env returns the environment of the go command loading the packages, nil
+// for the environment of the server.
+func (c *Config) env() []string {
+	if len(c.Env) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(c.Env))
+	for key := range c.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := os.Environ()
+	for _, key := range keys {
+		env = append(env, key+"="+c.Env[key])
+	}
+	return env
+}
+
 // NewDefaultConfig returns the default config. See the field comments for the
 // defaults.
 func NewDefaultConfig() Config {
@@ -103,7 +384,12 @@ func NewDefaultConfig() Config {
 	}
 
 	return Config{
-		DisableFuncSnippet: false,
-		MaxParallelism:     maxparallelism,
+		DisableFuncSnippet:  false,
+		VetOnSave:           true,
+		DocumentLinkTarget:  "https://pkg.go.dev",
+		MaxParallelism:      maxparallelism,
+		MaxReferences:       5000,
+		MaxWorkspaceSymbols: 100,
+		MaxCacheMemoryMB:    2048,
 	}
 }
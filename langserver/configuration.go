@@ -0,0 +1,105 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// configurationSection is the section of the settings of the client which
+// holds the settings of the server, in the form of InitializationOptions.
+const configurationSection = "bingo"
+
+// getConfig returns the configuration of the handler, which the client may
+// change as the requests are handled.
+func (h *LangHandler) getConfig() *Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.config
+}
+
+// didChangeConfiguration applies the settings of the client. The clients
+// supporting workspace/configuration are asked for them, as they may not
+// send them with the notification.
+func (h *LangHandler) didChangeConfiguration(ctx context.Context, conn jsonrpc2.JSONRPC2, params *protocol.DidChangeConfigurationParams) error {
+	if h.init.ClientCapabilities.Workspace.Configuration {
+		return h.fetchConfiguration(ctx, conn)
+	}
+	if params.Settings == nil {
+		return nil
+	}
+
+	settings := params.Settings
+	if m, ok := settings.(map[string]interface{}); ok {
+		if section, ok := m[configurationSection]; ok {
+			settings = section
+		}
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	h.applySettings(ctx, data)
+	return nil
+}
+
+// fetchConfiguration asks the client for the settings of the workspace with
+// workspace/configuration, and applies them.
+func (h *LangHandler) fetchConfiguration(ctx context.Context, conn jsonrpc2.JSONRPC2) error {
+	params := protocol.ConfigurationParams{Items: []protocol.ConfigurationItem{{
+		ScopeURI: h.init.Root(),
+		Section:  configurationSection,
+	}}}
+	var result []json.RawMessage
+	if err := conn.Call(ctx, "workspace/configuration", params, &result); err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	h.applySettings(ctx, result[0])
+	return nil
+}
+
+// applySettings replaces the configuration with the one of initialize
+// overridden by the settings data. Invalid settings are rejected with a
+// warning, and the configuration is kept. The settings changing how the
+// packages are loaded load them again, the others apply to the next
+// requests.
+func (h *LangHandler) applySettings(ctx context.Context, data []byte) {
+	var options InitializationOptions
+	if err := json.Unmarshal(data, &options); err != nil {
+		h.notifyWarning(fmt.Sprintf("invalid settings, the previous ones are kept: %s", err))
+		return
+	}
+
+	h.mu.Lock()
+	old := h.config
+	config := h.DefaultConfig.Apply(h.init.InitializationOptions).Apply(&options)
+	if err := config.validate(); err != nil {
+		h.mu.Unlock()
+		h.notifyWarning(fmt.Sprintf("invalid settings, the previous ones are kept: %s", err))
+		return
+	}
+	// The cache and the watching of the files are set up by initialize.
+	config.GlobalCacheStyle = old.GlobalCacheStyle
+	config.WatchFiles = old.WatchFiles
+	h.config = &config
+	h.mu.Unlock()
+
+	h.overlay.configure(DiagnosticsStyleEnum(config.DiagnosticsStyle), enabledAnalyzers(&config))
+	if config.MaxCacheMemoryMB != old.MaxCacheMemoryMB {
+		h.project.SetMaxCacheMemory(config.MaxCacheMemoryMB)
+	}
+	if !reflect.DeepEqual(old.buildFlags(), config.buildFlags()) || !reflect.DeepEqual(old.env(), config.env()) {
+		h.project.Configure(config.buildFlags(), config.env())
+	}
+	h.overlay.scheduleOpenDiagnostics(ctx, func(filename string) bool {
+		return strings.HasSuffix(filename, ".go")
+	})
+}
@@ -0,0 +1,125 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *LangHandler) handleDeclaration(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (interface{}, error) {
+	res, err := h.handleXDeclaration(ctx, conn, req, params)
+	if err != nil {
+		return nil, err
+	}
+	return h.toLocations(ctx, params.TextDocument.URI, res, h.init.ClientCapabilities.TextDocument.Declaration.LinkSupport), nil
+}
+
+func (h *LangHandler) handleXDeclaration(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
+	return lookupNearPosition(params, func(params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
+		return h.doHandleXDeclaration(ctx, conn, req, params)
+	})
+}
+
+// doHandleXDeclaration returns the interface methods implemented by the
+// method under the cursor. For everything else, the declaration is the
+// definition.
+func (h *LangHandler) doHandleXDeclaration(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
+	pkg, pathNodes, err := h.pathNodesAt(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := pathNodes[0].(*ast.BasicLit); !ok {
+		ident, err := identFromPathNodes(pkg, pathNodes)
+		if err != nil {
+			return nil, err
+		}
+		if fn, ok := source.FindIdentObject(pkg, ident).(*types.Func); ok {
+			if locs := h.lookupInterfaceMethods(pkg, ident, fn); len(locs) > 0 {
+				return locs, nil
+			}
+		}
+	}
+	return h.doHandleXDefinition(ctx, conn, req, params)
+}
+
+// lookupInterfaceMethods returns the methods of the interfaces visible
+// from the package of fn which fn implements, if fn is a method.
+func (h *LangHandler) lookupInterfaceMethods(pkg source.Package, ident *ast.Ident, fn *types.Func) []symbolLocationInformation {
+	methods := implementedMethods(fn)
+	if len(methods) == 0 {
+		return nil
+	}
+
+	fset := pkg.GetFileSet()
+	origin := rangeForNode(fset, ident)
+	locs := make([]symbolLocationInformation, 0, len(methods))
+	for _, m := range methods {
+		locs = append(locs, symbolLocationInformation{
+			Location:    h.navigationLocation(fset, m.Pos(), m.Name()),
+			OriginRange: &origin,
+		})
+	}
+	return locs
+}
+
+// implementedMethods returns the interface methods implemented by the
+// method fn. Only the named interfaces of the package of fn and of its
+// imports are considered.
+func implementedMethods(fn *types.Func) []*types.Func {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil || fn.Pkg() == nil {
+		return nil
+	}
+	typ := recv.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return nil
+	}
+	if _, ok := named.Underlying().(*types.Interface); ok {
+		// fn is already an interface method.
+		return nil
+	}
+
+	scopes := []*types.Scope{fn.Pkg().Scope()}
+	for _, imp := range fn.Pkg().Imports() {
+		scopes = append(scopes, imp.Scope())
+	}
+
+	var methods []*types.Func
+	seen := make(map[*types.Func]bool)
+	for _, scope := range scopes {
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			iface, ok := tn.Type().Underlying().(*types.Interface)
+			if !ok || iface.Empty() {
+				continue
+			}
+			if !types.Implements(named, iface) && !types.Implements(types.NewPointer(named), iface) {
+				continue
+			}
+			for i := 0; i < iface.NumMethods(); i++ {
+				m := iface.Method(i)
+				if m.Name() == fn.Name() && m.Pos().IsValid() && !seen[m] {
+					seen[m] = true
+					methods = append(methods, m)
+				}
+			}
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Pos() < methods[j].Pos()
+	})
+	return methods
+}
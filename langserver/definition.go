@@ -5,140 +5,439 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/refs"
 	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]lsp.Location, error) {
+func (h *LangHandler) handleDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (interface{}, error) {
 	res, err := h.handleXDefinition(ctx, conn, req, params)
 	if err != nil {
 		return nil, err
 	}
+	return h.toLocations(ctx, params.TextDocument.URI, res, h.init.ClientCapabilities.TextDocument.Definition.LinkSupport), nil
+}
+
+// toLocations converts the results of a definition or declaration lookup
+// at a position of the document uri into locations, or into location links
+// if the client supports them. Their character offsets count UTF-16 code
+// units.
+func (h *LangHandler) toLocations(ctx context.Context, uri lsp.DocumentURI, res []symbolLocationInformation, linkSupport bool) interface{} {
+	toUTF16 := h.utf16Ranges(ctx)
+	if linkSupport {
+		links := make([]protocol.LocationLink, 0, len(res))
+		for _, li := range res {
+			links = append(links, utf16LocationLink(toUTF16, uri, h.toLocationLink(ctx, li.OriginRange, li.Location)))
+		}
+		return links
+	}
 	locs := make([]lsp.Location, 0, len(res))
 	for _, li := range res {
-		locs = append(locs, li.Location)
+		locs = append(locs, utf16Location(toUTF16, li.Location))
 	}
-	return locs, nil
+	return locs
 }
 
-func (h *LangHandler) handleTypeDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]lsp.Location, error) {
+func (h *LangHandler) handleTypeDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (interface{}, error) {
 	res, err := h.handleXDefinition(ctx, conn, req, params)
 	if err != nil {
 		return nil, err
 	}
+	toUTF16 := h.utf16Ranges(ctx)
+	if h.init.ClientCapabilities.TextDocument.TypeDefinition.LinkSupport {
+		links := make([]protocol.LocationLink, 0, len(res))
+		for _, li := range res {
+			for _, loc := range li.TypeLocations {
+				links = append(links, utf16LocationLink(toUTF16, params.TextDocument.URI, h.toLocationLink(ctx, li.OriginRange, loc)))
+			}
+		}
+		return links, nil
+	}
 	locs := make([]lsp.Location, 0, len(res))
 	for _, li := range res {
 		// not everything we find a definition for also has a type definition
-		if li.TypeLocation.URI != "" {
-			locs = append(locs, li.TypeLocation)
+		for _, loc := range li.TypeLocations {
+			locs = append(locs, utf16Location(toUTF16, loc))
 		}
 	}
 	return locs, nil
 }
 
+// toLocationLink converts the location of a definition into a link whose
+// target range spans the whole declaration. The declaration is only parsed
+// here, so that clients without link support do not pay for it.
+func (h *LangHandler) toLocationLink(ctx context.Context, origin *lsp.Range, loc lsp.Location) protocol.LocationLink {
+	return protocol.LocationLink{
+		OriginSelectionRange: origin,
+		TargetURI:            loc.URI,
+		TargetRange:          h.declarationRange(ctx, loc),
+		TargetSelectionRange: loc.Range,
+	}
+}
+
+// declarationRange returns the range of the declaration enclosing loc,
+// e.g. the whole function or type declaration for the location of its
+// name. It falls back to the range of loc itself.
+func (h *LangHandler) declarationRange(ctx context.Context, loc lsp.Location) lsp.Range {
+	fset, file := h.declarationFile(ctx, loc.URI)
+	if file == nil {
+		return loc.Range
+	}
+	tok := fset.File(file.Pos())
+	if tok == nil {
+		return loc.Range
+	}
+
+	pos := fromProtocolPosition(tok, loc.Range.Start)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if decl := enclosingDecl(path); decl != nil {
+		return rangeForNode(fset, decl)
+	}
+	return loc.Range
+}
+
+// declarationFile returns the syntax of the file at uri.
+func (h *LangHandler) declarationFile(ctx context.Context, uri lsp.DocumentURI) (*token.FileSet, *ast.File) {
+	if file := h.builtin.load(); file != nil {
+		if util.PathEqual(h.builtin.fset.File(file.Pos()).Name(), util.UriToRealPath(uri)) {
+			return h.builtin.fset, file
+		}
+	}
+
+	fset, file, err := h.project.ParseFile(ctx, uri)
+	if err != nil {
+		return nil, nil
+	}
+	return fset, file
+}
+
+// enclosingDecl returns the innermost declaration in path, which is
+// ordered from the innermost node outwards.
+func enclosingDecl(path []ast.Node) ast.Node {
+	for i, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl, *ast.Field, *ast.ImportSpec, *ast.LabeledStmt, *ast.AssignStmt:
+			return n
+		case *ast.TypeSpec, *ast.ValueSpec:
+			// Prefer the spec inside of a parenthesized declaration.
+			if i+1 < len(path) {
+				if decl, ok := path[i+1].(*ast.GenDecl); ok && !decl.Lparen.IsValid() {
+					return decl
+				}
+			}
+			return n
+		}
+	}
+	return nil
+}
+
 var testOSToVFSPath func(osPath string) string
 
 type foundNode struct {
-	ident *ast.Ident      // the lookup in Uses[] or Defs[]
-	typ   *types.TypeName // the object for a named type, if present
+	ident *ast.Ident        // the lookup in Uses[] or Defs[]
+	typs  []*types.TypeName // the objects for the named types, if present
 }
 
 func (h *LangHandler) handleXDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
-	symbols, err := h.doHandleXDefinition(ctx, conn, req, params)
-	if err != nil {
+	return lookupNearPosition(params, func(params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
+		return h.doHandleXDefinition(ctx, conn, req, params)
+	})
+}
+
+// utf16SymbolLocations converts the locations of the results of a lookup
+// with toUTF16, in place.
+func utf16SymbolLocations(toUTF16 func(uri lsp.DocumentURI, r lsp.Range) lsp.Range, res []symbolLocationInformation) []symbolLocationInformation {
+	for i := range res {
+		res[i].Location = utf16Location(toUTF16, res[i].Location)
+	}
+	return res
+}
+
+// lookupNearPosition calls lookup for the position of params, or for the
+// position before it if that fails, since the cursor may be right after an
+// identifier. Positions with nothing to look up yield an empty result.
+func lookupNearPosition(params lsp.TextDocumentPositionParams, lookup func(lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error)) ([]symbolLocationInformation, error) {
+	symbols, err := lookup(params)
+	if err != nil && params.Position.Character > 0 {
 		params.Position.Character--
-		symbols, err = h.doHandleXDefinition(ctx, conn, req, params)
+		symbols, err = lookup(params)
 	}
 
+	if isEmptyResult(err) {
+		return []symbolLocationInformation{}, nil
+	}
 	return symbols, err
 }
 
 func (h *LangHandler) doHandleXDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
-	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	pkg, pathNodes, err := h.pathNodesAt(ctx, params)
 	if err != nil {
-		// Invalid nodes means we tried to click on something which is
-		// not an ident (eg comment/string/etc). Return no locations.
-		if _, ok := err.(*source.InvalidNodeError); ok {
-			return []symbolLocationInformation{}, nil
-		}
 		return nil, err
 	}
 
-	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if lit, ok := pathNodes[0].(*ast.BasicLit); ok {
+		return h.lookupImportDefinition(pkg, pathNodes, lit)
+	}
+
+	ident, err := identFromPathNodes(pkg, pathNodes)
 	if err != nil {
 		return nil, err
 	}
+	return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, ident)
+}
+
+// pathNodesAt type checks the package of the document of params and
+// returns the path of nodes enclosing its position.
+func (h *LangHandler) pathNodesAt(ctx context.Context, params lsp.TextDocumentPositionParams) (source.Package, []ast.Node, error) {
+	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, errNoPosition
+	}
+	return pkg, pathNodes, nil
+}
 
-	firstNode := pathNodes[0]
-	switch node := firstNode.(type) {
+// identFromPathNodes returns the identifier to look up for the innermost
+// node of pathNodes.
+func identFromPathNodes(pkg source.Package, pathNodes []ast.Node) (*ast.Ident, error) {
+	switch node := pathNodes[0].(type) {
 	case *ast.Ident:
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node)
+		return node, nil
 	case *ast.TypeSpec:
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node.Name)
+		return node.Name, nil
 	case *ast.CallExpr:
-		return h.lookupCallExprDefinition(ctx, conn, pkg, pathNodes, node)
+		switch fun := node.Fun.(type) {
+		case *ast.Ident:
+			return fun, nil
+		case *ast.SelectorExpr:
+			return fun.Sel, nil
+		}
 	case *ast.SelectorExpr:
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node.Sel)
-	default:
-		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), firstNode)
+		return node.Sel, nil
 	}
+	return nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
 }
 
-func (h *LangHandler) lookupCallExprDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, pkg source.Package, pathNodes []ast.Node, call *ast.CallExpr) ([]symbolLocationInformation, error) {
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, ident)
+// lookupImportDefinition resolves the string literal of an import spec to
+// the primary file of the imported package.
+func (h *LangHandler) lookupImportDefinition(pkg source.Package, pathNodes []ast.Node, lit *ast.BasicLit) ([]symbolLocationInformation, error) {
+	if len(pathNodes) < 2 {
+		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), lit)
+	}
+	spec, ok := pathNodes[1].(*ast.ImportSpec)
+	if !ok {
+		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), lit)
 	}
 
-	if selExpr, ok := call.Fun.(*ast.SelectorExpr); ok {
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, selExpr.Sel)
+	// Prefer the path recorded by the type checker, it has vendor
+	// directories already resolved.
+	importPath := strings.Trim(spec.Path.Value, `"`)
+	obj := pkg.GetTypesInfo().Implicits[spec]
+	if obj == nil && spec.Name != nil {
+		obj = pkg.GetTypesInfo().Defs[spec.Name]
+	}
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		importPath = pkgName.Imported().Path()
 	}
 
-	return nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	importPkg, err := h.importedPackage(pkg, importPath)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := packageLocation(importPkg)
+	if !ok {
+		return []symbolLocationInformation{}, nil
+	}
+	origin := rangeForNode(pkg.GetFileSet(), spec.Path)
+	l.OriginRange = &origin
+	return []symbolLocationInformation{l}, nil
+}
+
+// lookupPkgNameDefinition returns the import spec declaring pkgName in the
+// current file, followed by the entry point of the imported package.
+func (h *LangHandler) lookupPkgNameDefinition(pkg source.Package, pkgName *types.PkgName) ([]symbolLocationInformation, error) {
+	importPkg, err := h.importedPackage(pkg, pkgName.Imported().Path())
+	if err != nil {
+		return nil, err
+	}
+
+	symbol := packageSymbolDescriptor(pkgName.Imported().Path(), pkgName.Imported().Name())
+	var locs []symbolLocationInformation
+	if spec := findImportSpec(pkg, pkgName.Pos()); spec != nil {
+		locs = append(locs, symbolLocationInformation{
+			Location: createLocationFromRange(pkg.GetFileSet(), spec.Pos(), spec.End()),
+			Symbol:   symbol,
+		})
+	}
+	if l, ok := packageLocation(importPkg); ok {
+		locs = append(locs, l)
+	}
+	if len(locs) == 0 {
+		return []symbolLocationInformation{}, nil
+	}
+	return locs, nil
+}
+
+// importedPackage resolves importPath as seen from pkg.
+func (h *LangHandler) importedPackage(pkg source.Package, importPath string) (source.Package, error) {
+	if importPkg := pkg.GetImport(importPath); importPkg != nil {
+		return importPkg, nil
+	}
+	return h.getFindPackageFunc()(h.project, importPath)
+}
+
+// findImportSpec returns the import spec of pkg starting at pos.
+func findImportSpec(pkg source.Package, pos token.Pos) *ast.ImportSpec {
+	for _, file := range pkg.GetSyntax() {
+		if pos < file.Pos() || pos > file.End() {
+			continue
+		}
+		for _, spec := range file.Imports {
+			if spec.Pos() == pos {
+				return spec
+			}
+		}
+	}
+	return nil
+}
+
+// packageLocation returns the location of the primary file of pkg at line 1.
+func packageLocation(pkg source.Package) (symbolLocationInformation, bool) {
+	if pkg == nil {
+		return symbolLocationInformation{}, false
+	}
+	filename := packagePrimaryFile(pkg.GetFilenames())
+	if filename == "" {
+		return symbolLocationInformation{}, false
+	}
+	return symbolLocationInformation{
+		Location: lsp.Location{URI: lsp.DocumentURI(source.ToURI(filename))},
+		Symbol:   packageSymbolDescriptor(pkg.GetPkgPath(), pkg.GetName()),
+	}, true
+}
+
+// packageSymbolDescriptor describes a reference to just a package.
+func packageSymbolDescriptor(pkgPath, name string) *symbolDescriptor {
+	return &symbolDescriptor{
+		Package:     pkgPath,
+		PackageName: name,
+		ID:          pkgPath,
+	}
+}
+
+// packagePrimaryFile returns doc.go if the package has one, otherwise the
+// first non-test file.
+func packagePrimaryFile(filenames []string) string {
+	files := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, "_test.go") {
+			files = append(files, filename)
+		}
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	sort.Strings(files)
+
+	for _, filename := range files {
+		if filepath.Base(filename) == "doc.go" {
+			return filename
+		}
+	}
+	return files[0]
 }
 
 func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) ([]symbolLocationInformation, error) {
+	locs, err := h.findIdentDefinition(ctx, conn, pkg, pathNodes, ident)
+	if err != nil {
+		return nil, err
+	}
+	origin := rangeForNode(pkg.GetFileSet(), ident)
+	for i := range locs {
+		if locs[i].OriginRange == nil {
+			locs[i].OriginRange = &origin
+		}
+	}
+	return locs, nil
+}
+
+func (h *LangHandler) findIdentDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) ([]symbolLocationInformation, error) {
+	if ident.Name == "_" {
+		// The blank identifier declares nothing to navigate to.
+		return []symbolLocationInformation{}, nil
+	}
 	var nodes []foundNode
 	obj := source.FindIdentObject(pkg, ident)
-	if obj != nil {
-		if typeVar, ok := obj.(*types.Var); ok && typeVar.Embedded() {
-			if t, ok := typeVar.Type().(*types.Named); ok {
-				obj = t.Obj()
+	if obj == nil {
+		obj = compositeLitField(pkg, pathNodes, ident)
+	}
+	if obj == nil {
+		obj = h.dotImportedObject(pkg, pathNodes, ident)
+	}
+	if obj == nil {
+		// The symbolic variable of a type switch has no object itself,
+		// only one per case clause.
+		if implicits := typeSwitchImplicits(pkg, pathNodes, ident); len(implicits) > 0 {
+			var typs []*types.TypeName
+			for _, implicit := range implicits {
+				typs = appendTypeNames(typs, source.TypeLookup(implicit.Type())...)
 			}
+			nodes = append(nodes, foundNode{ident: ident, typs: typs})
 		}
-
+	}
+	switch o := obj.(type) {
+	case *types.PkgName:
+		return h.lookupPkgNameDefinition(pkg, o)
+	case *types.Label:
+		return lookupLabelDefinition(pkg, o), nil
+	case nil:
+		if isLabelIdent(pathNodes, ident) {
+			// The label is undefined, most likely because of a compile error.
+			return []symbolLocationInformation{}, nil
+		}
+	}
+	if isCgoObject(pkg, obj) {
+		// C.xxx refers to declarations generated by cgo, which are not
+		// part of the user's source.
+		return []symbolLocationInformation{}, nil
+	}
+	if obj != nil {
 		pos := obj.Pos()
 		isBuiltIn := !pos.IsValid()
-		if !isBuiltIn {
+		if typeVar, ok := obj.(*types.Var); ok && typeVar.Embedded() && !isBuiltIn {
+			// The field is declared by its type name, which is also
+			// where its type is defined.
 			nodes = append(nodes, foundNode{
-				ident: &ast.Ident{NamePos: pos, Name: obj.Name()},
-				typ:   source.TypeLookup(pkg.GetTypesInfo().TypeOf(ident)),
+				ident: &ast.Ident{NamePos: embeddedFieldPos(pkg, typeVar), Name: obj.Name()},
+				typs:  source.TypeLookup(typeVar.Type()),
 			})
-		} else {
-			// Builtins have an invalid Pos. Just don't emit a definition for
-			// them, for now. It's not that valuable to jump to their def.
-			//
-			// TODO(sqs): find a way to actually emit builtin locations
-			// (pointing to builtin/builtin.go).
-			pkg = h.project.GetBuiltinPackage()
-			if pkg == nil {
-				return []symbolLocationInformation{}, nil
-			}
-			obj = source.FindObject(pkg, obj)
-			if obj == nil {
-				return []symbolLocationInformation{}, nil
-			}
-
-			// re-look up position in `builtin` package
-			pos = obj.Pos()
+		} else if !isBuiltIn {
 			nodes = append(nodes, foundNode{
 				ident: &ast.Ident{NamePos: pos, Name: obj.Name()},
-				typ:   source.TypeLookup(obj.Type()),
+				typs:  source.TypeLookup(pkg.GetTypesInfo().TypeOf(ident)),
 			})
-			pathNodes, _, _ = source.GetObjectPathNode(pkg, pkg.GetFileSet(), obj)
+		} else {
+			// Builtins have an invalid Pos, so resolve them against the
+			// declarations in builtin/builtin.go instead.
+			return h.lookupBuiltinDefinition(obj), nil
 		}
 	}
 	if len(nodes) == 0 {
@@ -149,12 +448,16 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 	for _, found := range nodes {
 		// Determine location information for the ident.
 		l := symbolLocationInformation{
-			Location: goRangeToLSPLocation(pkg.GetFileSet(), found.ident.Pos(), found.ident.Name),
+			Location: h.navigationLocation(pkg.GetFileSet(), found.ident.Pos(), found.ident.Name),
 		}
-		if found.typ != nil {
-			// We don't get an end position, but we can assume it's comparable to
-			// the length of the name, I hope.
-			l.TypeLocation = goRangeToLSPLocation(pkg.GetFileSet(), found.typ.Pos(), found.typ.Name())
+		for _, typ := range found.typs {
+			if typ.Pos().IsValid() {
+				// We don't get an end position, but we can assume it's comparable to
+				// the length of the name, I hope.
+				l.TypeLocations = append(l.TypeLocations, h.navigationLocation(pkg.GetFileSet(), typ.Pos(), typ.Name()))
+			} else if loc, ok := h.builtinLocation(typ.Name()); ok && typ.Pkg() == nil {
+				l.TypeLocations = append(l.TypeLocations, loc)
+			}
 		}
 
 		// Determine metadata information for the ident.
@@ -173,5 +476,305 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 		}
 		locs = append(locs, l)
 	}
+	if fn, ok := obj.(*types.Func); ok && len(locs) > 0 {
+		if l, ok := h.lookupAsmDefinition(ctx, pkg.GetFileSet(), fn, locs[0]); ok {
+			locs = append(locs, l)
+		}
+	}
 	return locs, nil
 }
+
+// lookupAsmDefinition returns the TEXT directive implementing fn, if fn is
+// declared without a body and implemented in an assembly file of its
+// package directory.
+func (h *LangHandler) lookupAsmDefinition(ctx context.Context, fset *token.FileSet, fn *types.Func, decl symbolLocationInformation) (symbolLocationInformation, bool) {
+	if fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+		return symbolLocationInformation{}, false
+	}
+	if !h.isBodylessFunc(ctx, decl.Location) {
+		return symbolLocationInformation{}, false
+	}
+
+	dir := filepath.Dir(fset.PositionFor(fn.Pos(), false).Filename)
+	for _, sym := range h.project.AsmSymbols(dir) {
+		if sym.Name != fn.Name() || (sym.Pkg != "" && sym.Pkg != fn.Pkg().Path()) {
+			continue
+		}
+		start := lsp.Position{Line: sym.Line - 1, Character: sym.Column - 1}
+		end := lsp.Position{Line: start.Line, Character: start.Character + len(sym.Name)}
+		return symbolLocationInformation{
+			Location: lsp.Location{
+				URI:   lsp.DocumentURI(source.ToURI(sym.Filename)),
+				Range: lsp.Range{Start: start, End: end},
+			},
+			Symbol: decl.Symbol,
+		}, true
+	}
+	return symbolLocationInformation{}, false
+}
+
+// isBodylessFunc reports whether loc is the name of a function declared
+// without a body.
+func (h *LangHandler) isBodylessFunc(ctx context.Context, loc lsp.Location) bool {
+	fset, file := h.declarationFile(ctx, loc.URI)
+	if file == nil {
+		return false
+	}
+	tok := fset.File(file.Pos())
+	if tok == nil {
+		return false
+	}
+
+	pos := fromProtocolPosition(tok, loc.Range.Start)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	decl, ok := enclosingDecl(path).(*ast.FuncDecl)
+	return ok && decl.Body == nil
+}
+
+// navigationLocation returns the location to navigate to for name at pos.
+// //line directives are only followed if Config.FollowLineDirectives is
+// set and the file they refer to exists. The output of cgo always maps
+// back to the file importing "C", since it is not a file of the user.
+func (h *LangHandler) navigationLocation(fset *token.FileSet, pos token.Pos, name string) lsp.Location {
+	if !pos.IsValid() {
+		return goRangeToLSPLocation(fset, pos, name)
+	}
+
+	adjusted := fset.PositionFor(pos, true)
+	unadjusted := fset.PositionFor(pos, false)
+	if adjusted == unadjusted {
+		return goRangeToLSPLocation(fset, pos, name)
+	}
+
+	follow := h.getConfig().FollowLineDirectives || isCgoOutput(unadjusted.Filename)
+	if _, err := os.Stat(adjusted.Filename); follow && err == nil {
+		return goRangeToLSPLocation(fset, pos, name)
+	}
+	return goRangeToLSPLocationFor(fset, pos, name, false)
+}
+
+// isCgoOutput reports whether filename, which has //line directives, was
+// generated by cgo for a file importing "C". The go command hands the
+// output of cgo out of its build cache, under names of its own.
+func isCgoOutput(filename string) bool {
+	return strings.HasSuffix(filename, ".cgo1.go") || filepath.Ext(filename) != ".go"
+}
+
+// isCgoObject reports whether obj is declared by cgo for a C.xxx reference.
+func isCgoObject(pkg source.Package, obj types.Object) bool {
+	if obj == nil || !obj.Pos().IsValid() {
+		return false
+	}
+	fset := pkg.GetFileSet()
+	position := fset.PositionFor(obj.Pos(), false)
+	if filepath.Base(position.Filename) == "_cgo_gotypes.go" {
+		return true
+	}
+	// The go command hands the output of cgo out of its build cache, under
+	// names of its own. Unlike the declarations of the files importing "C",
+	// those cgo generates are not mapped back to any source file.
+	return filepath.Ext(position.Filename) != ".go" && fset.Position(obj.Pos()) == position
+}
+
+// lookupLabelDefinition returns the location of the labeled statement
+// declaring label. Labels are local to their function, so there is no
+// symbol describing them.
+func lookupLabelDefinition(pkg source.Package, label *types.Label) []symbolLocationInformation {
+	if !label.Pos().IsValid() {
+		return []symbolLocationInformation{}
+	}
+	return []symbolLocationInformation{{
+		Location: goRangeToLSPLocation(pkg.GetFileSet(), label.Pos(), label.Name()),
+	}}
+}
+
+// isLabelIdent reports whether ident is the label of a branch or labeled
+// statement.
+func isLabelIdent(pathNodes []ast.Node, ident *ast.Ident) bool {
+	if len(pathNodes) < 2 {
+		return false
+	}
+	switch stmt := pathNodes[1].(type) {
+	case *ast.BranchStmt:
+		return stmt.Label == ident
+	case *ast.LabeledStmt:
+		return stmt.Label == ident
+	}
+	return false
+}
+
+// lookupBuiltinDefinition returns the location of a builtin object in
+// builtin/builtin.go. If GOROOT can not be resolved an empty slice is
+// returned.
+func (h *LangHandler) lookupBuiltinDefinition(obj types.Object) []symbolLocationInformation {
+	loc, ok := h.builtinLocation(obj.Name())
+	if !ok {
+		return []symbolLocationInformation{}
+	}
+
+	l := symbolLocationInformation{
+		Location: loc,
+		Symbol: &symbolDescriptor{
+			Package:     cache.BuiltinPkg,
+			PackageName: cache.BuiltinPkg,
+			Name:        obj.Name(),
+			ID:          cache.BuiltinPkg + "/-/" + obj.Name(),
+		},
+	}
+	if _, ok := obj.(*types.TypeName); ok {
+		l.TypeLocations = []lsp.Location{loc}
+	} else {
+		for _, t := range source.TypeLookup(obj.Type()) {
+			if typeLoc, ok := h.builtinLocation(t.Name()); ok && t.Pkg() == nil {
+				l.TypeLocations = append(l.TypeLocations, typeLoc)
+			}
+		}
+	}
+	return []symbolLocationInformation{l}
+}
+
+// builtinLocation returns the location of the declaration of name in
+// builtin/builtin.go.
+func (h *LangHandler) builtinLocation(name string) (lsp.Location, bool) {
+	ident := h.builtin.lookup(name)
+	if ident == nil {
+		return lsp.Location{}, false
+	}
+	return goRangeToLSPLocation(h.builtin.fset, ident.Pos(), ident.Name), true
+}
+
+// dotImportedObject returns the object ident refers to in the packages
+// dot-imported by its file. It is used when the type checker did not
+// record the use of ident, e.g. because of errors in the file.
+func (h *LangHandler) dotImportedObject(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) types.Object {
+	if !ident.IsExported() || len(pathNodes) == 0 {
+		return nil
+	}
+	file, ok := pathNodes[len(pathNodes)-1].(*ast.File)
+	if !ok {
+		return nil
+	}
+
+	for _, spec := range file.Imports {
+		if spec.Name == nil || spec.Name.Name != "." {
+			continue
+		}
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		importPkg, err := h.importedPackage(pkg, importPath)
+		if err != nil || importPkg == nil || importPkg.GetTypes() == nil {
+			continue
+		}
+		if obj := importPkg.GetTypes().Scope().Lookup(ident.Name); obj != nil {
+			return obj
+		}
+	}
+	return nil
+}
+
+// typeSwitchImplicits returns the objects the case clauses of a type
+// switch declare when ident is the symbolic variable in the switch header,
+// e.g. v in "switch v := x.(type)".
+func typeSwitchImplicits(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) []types.Object {
+	if len(pathNodes) < 3 {
+		return nil
+	}
+	assign, ok := pathNodes[1].(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || assign.Lhs[0] != ident {
+		return nil
+	}
+	sw, ok := pathNodes[2].(*ast.TypeSwitchStmt)
+	if !ok || sw.Assign != assign {
+		return nil
+	}
+
+	var objs []types.Object
+	for _, stmt := range sw.Body.List {
+		if obj := pkg.GetTypesInfo().Implicits[stmt]; obj != nil {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// appendTypeNames appends the type names not yet in typs.
+func appendTypeNames(typs []*types.TypeName, names ...*types.TypeName) []*types.TypeName {
+	for _, name := range names {
+		found := false
+		for _, typ := range typs {
+			if typ == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			typs = append(typs, name)
+		}
+	}
+	return typs
+}
+
+// compositeLitField returns the struct field named by ident when ident is
+// the key of a struct composite literal.
+func compositeLitField(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) types.Object {
+	if len(pathNodes) < 3 {
+		return nil
+	}
+	kv, ok := pathNodes[1].(*ast.KeyValueExpr)
+	if !ok || kv.Key != ident {
+		return nil
+	}
+	lit, ok := pathNodes[2].(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+
+	tv, ok := pkg.GetTypesInfo().Types[lit]
+	if !ok {
+		return nil
+	}
+	st, ok := source.Deref(tv.Type).Underlying().(*types.Struct)
+	if !ok {
+		// map and slice literal keys are ordinary expressions.
+		return nil
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if f := st.Field(i); f.Name() == ident.Name {
+			return f
+		}
+	}
+	return nil
+}
+
+// embeddedFieldPos returns the position of the type name declaring the
+// embedded field v, e.g. Reader in "io.Reader" or Buffer in "*bytes.Buffer".
+func embeddedFieldPos(pkg source.Package, v *types.Var) token.Pos {
+	declPkg := pkg
+	if v.Pkg() != nil && v.Pkg().Path() != pkg.GetPkgPath() {
+		if ip := pkg.GetImport(v.Pkg().Path()); ip != nil {
+			declPkg = ip
+		}
+	}
+
+	nodes, _ := source.PathEnclosingInterval(declPkg, declPkg.GetFileSet(), v.Pos(), v.Pos())
+	for _, n := range nodes {
+		field, ok := n.(*ast.Field)
+		if !ok {
+			continue
+		}
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		switch typ := typ.(type) {
+		case *ast.Ident:
+			return typ.Pos()
+		case *ast.SelectorExpr:
+			return typ.Sel.Pos()
+		}
+		break
+	}
+	return v.Pos()
+}
@@ -6,28 +6,28 @@ package langserver
 
 import (
 	"context"
-	"fmt"
+	"go/scanner"
 	"go/token"
+	"log"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
 // NOTICE: Code adapted from https://github.com/golang/tools/blob/master/internal/lsp/diagnostics.go.
 
-func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnostic, error) {
-	pkg := f.GetPackage(ctx)
-	if pkg == nil {
-		return nil, fmt.Errorf("package is null for file")
-	}
-
-	reports := make(map[string][]lsp.Diagnostic)
+func diagnostics(ctx context.Context, v source.View, pkg source.Package, related bool) map[string][]protocol.Diagnostic {
+	reports := make(map[string][]protocol.Diagnostic)
 	for _, filename := range pkg.GetFilenames() {
-		reports[filename] = []lsp.Diagnostic{}
+		reports[filename] = []protocol.Diagnostic{}
 	}
 	var parseErrors, typeErrors []packages.Error
 	for _, err := range pkg.GetErrors() {
@@ -46,31 +46,168 @@ func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnosti
 	if len(parseErrors) > 0 {
 		errors = parseErrors
 	}
+	errorDiagnostics(reports, errors, related, func(filename string) []byte {
+		return fileContent(ctx, v, filename)
+	})
+	return reports
+}
+
+// declaredAt matches the position of another declaration in the message
+// of an error, eg. "method T.M already declared at a.go:5:15".
+var declaredAt = regexp.MustCompile(`\bat (\S+\.go:\d+(?::\d+)?)`)
+
+// errorDiagnostics appends the diagnostics of the errors to the reports
+// of their files, which content returns. If related is true, the other
+// positions of an error, which go/types reports as errors with a message
+// starting with a tab following the error, eg. "\tother declaration of x",
+// or which are mentioned by its message, are attached to its diagnostic as
+// related information.
+func errorDiagnostics(reports map[string][]protocol.Diagnostic, errors []packages.Error, related bool, content func(filename string) []byte) {
+	relatedInformation := func(pos token.Position, message string) protocol.DiagnosticRelatedInformation {
+		return protocol.DiagnosticRelatedInformation{
+			Location: lsp.Location{
+				URI:   lsp.DocumentURI(source.ToURI(pos.Filename)),
+				Range: diagnosticRange(content(pos.Filename), pos),
+			},
+			Message: message,
+		}
+	}
+
+	// last is the diagnostic of the previous error, if it is reported.
+	var last *protocol.Diagnostic
 	for _, err := range errors {
 		pos := parseErrorPos(err)
-		line := pos.Line - 1
-		col := pos.Column - 1
-		diagnostic := lsp.Diagnostic{
-			// TODO(rstambler): Add support for diagnostic ranges.
-			Range: lsp.Range{
-				Start: lsp.Position{
-					Line:      line,
-					Character: col,
-				},
-				End: lsp.Position{
-					Line:      line,
-					Character: col,
-				},
+		if related && strings.HasPrefix(err.Msg, "\t") {
+			if last != nil {
+				last.RelatedInformation = append(last.RelatedInformation, relatedInformation(pos, strings.TrimSpace(err.Msg)))
+			}
+			continue
+		}
+		last = nil
+		if _, ok := reports[pos.Filename]; !ok {
+			continue
+		}
+		diagnostic := protocol.Diagnostic{
+			Diagnostic: lsp.Diagnostic{
+				Range:    diagnosticRange(content(pos.Filename), pos),
+				Severity: lsp.Error,
+				Source:   "LSP: Go compiler",
+				Message:  err.Msg,
 			},
-			Severity: lsp.Error,
-			Source:   "LSP: Go compiler",
-			Message:  err.Msg,
 		}
-		if _, ok := reports[pos.Filename]; ok {
-			reports[pos.Filename] = append(reports[pos.Filename], diagnostic)
+		if m := declaredAt.FindStringSubmatch(err.Msg); related && m != nil {
+			other := parseErrorPos(packages.Error{Pos: m[1]})
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, relatedInformation(other, "other declaration"))
 		}
+		reports[pos.Filename] = append(reports[pos.Filename], diagnostic)
+		last = &reports[pos.Filename][len(reports[pos.Filename])-1]
+	}
+}
+
+// fileContent returns the content of the file filename of the view, nil
+// if it cannot be read.
+func fileContent(ctx context.Context, v source.View, filename string) []byte {
+	f, err := v.GetFile(ctx, span.FileURI(filename))
+	if err != nil {
+		return nil
+	}
+	return f.GetContent(ctx)
+}
+
+// suggestedFix is a fix an analyzer suggests for one of its findings.
+type suggestedFix struct {
+	// diagnostic is the finding the fix is for.
+	diagnostic lsp.Diagnostic
+	title      string
+	// edits are the edits of the fix, by document URI.
+	edits map[string][]lsp.TextEdit
+}
+
+// vetDiagnostics runs the analyzers over pkg, which must be well typed,
+// and returns their findings as warnings along with the fixes they
+// suggest, by file.
+func vetDiagnostics(ctx context.Context, v source.View, pkg source.Package, analyzers []*analysis.Analyzer) (map[string][]protocol.Diagnostic, map[string][]suggestedFix) {
+	reports := make(map[string][]protocol.Diagnostic)
+	fixes := make(map[string][]suggestedFix)
+	fset := pkg.GetFileSet()
+	err := source.RunAnalyses(ctx, v, pkg, analyzers, func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+		pos := fset.Position(diag.Pos)
+		if !pos.IsValid() {
+			return
+		}
+		d := lsp.Diagnostic{
+			Range:    diagnosticRange(fileContent(ctx, v, pos.Filename), pos),
+			Severity: lsp.Warning,
+			Code:     a.Name,
+			Source:   a.Name,
+			Message:  diag.Message,
+		}
+		reports[pos.Filename] = append(reports[pos.Filename], protocol.Diagnostic{Diagnostic: d})
+
+		for _, fix := range diag.SuggestedFixes {
+			edits := make(map[string][]lsp.TextEdit)
+			for _, edit := range fix.TextEdits {
+				start, end := fset.Position(edit.Pos), fset.Position(edit.End)
+				if !edit.End.IsValid() {
+					end = start
+				}
+				if !start.IsValid() || end.Filename != start.Filename {
+					edits = nil
+					break
+				}
+				r := lsp.Range{
+					Start: lsp.Position{Line: start.Line - 1, Character: start.Column - 1},
+					End:   lsp.Position{Line: end.Line - 1, Character: end.Column - 1},
+				}
+				uri := string(source.ToURI(start.Filename))
+				edits[uri] = append(edits[uri], lsp.TextEdit{
+					Range:   toUTF16Range(fileContent(ctx, v, start.Filename), r),
+					NewText: string(edit.NewText),
+				})
+			}
+			if len(edits) > 0 {
+				fixes[pos.Filename] = append(fixes[pos.Filename], suggestedFix{diagnostic: d, title: fix.Message, edits: edits})
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("vet %s: %v", pkg.GetPkgPath(), err)
+	}
+	return reports, fixes
+}
+
+// diagnosticRange returns the range of the token an error at pos of a file
+// with the given content points to, in UTF-16 code units. The range is
+// empty if there is no token at pos, e.g. at the end of a line.
+func diagnosticRange(content []byte, pos token.Position) lsp.Range {
+	start := lsp.Position{Line: pos.Line - 1, Character: pos.Column - 1}
+	if start.Character < 0 {
+		start.Character = 0
+	}
+	end := start
+	if line, ok := lineContent(content, start.Line); ok && start.Character <= len(line) {
+		end.Character += tokenLength(line[start.Character:])
+	}
+	return toUTF16Range(content, lsp.Range{Start: start, End: end})
+}
+
+// tokenLength returns the length in bytes of the Go token src starts with.
+func tokenLength(src []byte) int {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+	pos, tok, lit := s.Scan()
+	if fset.Position(pos).Offset != 0 {
+		// src starts with white space or a comment.
+		return 0
+	}
+	switch {
+	case tok == token.EOF || tok == token.SEMICOLON && lit != ";":
+		return 0
+	case lit != "":
+		return len(lit)
 	}
-	return reports, nil
+	return len(tok.String())
 }
 
 func parseErrorPos(pkgErr packages.Error) (pos token.Position) {
@@ -0,0 +1,81 @@
+package langserver
+
+import (
+	"fmt"
+	"go/token"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestDiagnosticRange(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("package p\n\nvar x = undefined + 1\nvar s = \"héllo\" + y\nvar 𝛼, z = 1,\n")
+	tests := []struct {
+		line, column int
+		want         lsp.Range
+	}{
+		{3, 9, lsp.Range{Start: lsp.Position{Line: 2, Character: 8}, End: lsp.Position{Line: 2, Character: 17}}},
+		{3, 19, lsp.Range{Start: lsp.Position{Line: 2, Character: 18}, End: lsp.Position{Line: 2, Character: 19}}},
+		{4, 9, lsp.Range{Start: lsp.Position{Line: 3, Character: 8}, End: lsp.Position{Line: 3, Character: 15}}},
+		{4, 20, lsp.Range{Start: lsp.Position{Line: 3, Character: 18}, End: lsp.Position{Line: 3, Character: 19}}},
+		{5, 5, lsp.Range{Start: lsp.Position{Line: 4, Character: 4}, End: lsp.Position{Line: 4, Character: 6}}},
+		{5, 11, lsp.Range{Start: lsp.Position{Line: 4, Character: 8}, End: lsp.Position{Line: 4, Character: 9}}},
+		{5, 17, lsp.Range{Start: lsp.Position{Line: 4, Character: 14}, End: lsp.Position{Line: 4, Character: 14}}},
+	}
+	for _, test := range tests {
+		pos := token.Position{Filename: "p.go", Line: test.line, Column: test.column}
+		if got := diagnosticRange(content, pos); got != test.want {
+			t.Errorf("%d:%d: got %v, want %v", test.line, test.column, got, test.want)
+		}
+	}
+}
+
+func TestErrorDiagnosticsRelatedInformation(t *testing.T) {
+	t.Parallel()
+
+	content := func(string) []byte {
+		return []byte("package p\n\nvar x int\n\nfunc (t T) M() {}\n")
+	}
+	errors := []packages.Error{
+		{Pos: "/src/p/a.go:3:5", Msg: "x redeclared in this block", Kind: packages.TypeError},
+		{Pos: "/src/p/b.go:3:5", Msg: "\tother declaration of x", Kind: packages.TypeError},
+		{Pos: "/src/p/a.go:5:12", Msg: "method T.M already declared at /src/p/b.go:5:12", Kind: packages.TypeError},
+	}
+	format := func(reports map[string][]protocol.Diagnostic) string {
+		var s string
+		for _, filename := range []string{"/src/p/a.go", "/src/p/b.go"} {
+			for _, d := range reports[filename] {
+				s += fmt.Sprintf("%s:%d:%d %q", filename, d.Range.Start.Line+1, d.Range.Start.Character+1, d.Message)
+				for _, r := range d.RelatedInformation {
+					s += fmt.Sprintf(" (%s:%d:%d-%d %q)", r.Location.URI, r.Location.Range.Start.Line+1, r.Location.Range.Start.Character+1, r.Location.Range.End.Character+1, r.Message)
+				}
+				s += "\n"
+			}
+		}
+		return s
+	}
+
+	tests := []struct {
+		related bool
+		want    string
+	}{
+		{true, `/src/p/a.go:3:5 "x redeclared in this block" (file:///src/p/b.go:3:5-6 "other declaration of x")
+/src/p/a.go:5:12 "method T.M already declared at /src/p/b.go:5:12" (file:///src/p/b.go:5:12-13 "other declaration")
+`},
+		{false, `/src/p/a.go:3:5 "x redeclared in this block"
+/src/p/a.go:5:12 "method T.M already declared at /src/p/b.go:5:12"
+/src/p/b.go:3:5 "\tother declaration of x"
+`},
+	}
+	for _, test := range tests {
+		reports := map[string][]protocol.Diagnostic{"/src/p/a.go": {}, "/src/p/b.go": {}}
+		errorDiagnostics(reports, errors, test.related, content)
+		if got := format(reports); got != test.want {
+			t.Errorf("related %t: got\n%s\nwant\n%s", test.related, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// documentLinkData is the data of the link of an import path, whose target
+// is resolved lazily.
+type documentLinkData struct {
+	ImportPath string `json:"importPath"`
+}
+
+// handleTextDocumentDocumentLink returns the unresolved links of the import
+// paths of the document to their documentation, and the links of the file
+// arguments of its go:generate and go:embed directives to the files, if
+// they exist. The document is parsed on its own, so that the links of the
+// files outside of the workspace, eg. in the module cache or a vendor
+// directory, resolve as well.
+func (h *LangHandler) handleTextDocumentDocumentLink(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
+	uri := params.TextDocument.URI
+	if err := checkFileURI(uri); err != nil {
+		return nil, err
+	}
+
+	filename := util.UriToRealPath(uri)
+	content, err := h.project.FileContent(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if file == nil {
+		return nil, err
+	}
+
+	links := []protocol.DocumentLink{}
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		// The pseudo package of cgo has no documentation.
+		if err != nil || importPath == "C" {
+			continue
+		}
+		// The link excludes the quotes.
+		path := fakeNode{spec.Path.Pos() + 1, spec.Path.End() - 1}
+		links = append(links, protocol.DocumentLink{
+			Range: toUTF16Range(content, rangeForNode(fset, path)),
+			Data:  documentLinkData{ImportPath: importPath},
+		})
+	}
+	links = append(links, directiveLinks(fset, file, content, filepath.Dir(filename))...)
+	return links, nil
+}
+
+// handleDocumentLinkResolve resolves the link of an import path, with the
+// target of its documentation under Config.DocumentLinkTarget.
+func (h *LangHandler) handleDocumentLinkResolve(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, link protocol.DocumentLink) (protocol.DocumentLink, error) {
+	if link.Target != "" {
+		return link, nil
+	}
+
+	// The data is decoded as a map.
+	var data documentLinkData
+	raw, err := json.Marshal(link.Data)
+	if err != nil {
+		return link, err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil || data.ImportPath == "" {
+		return link, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "document link without import path data"}
+	}
+	link.Target = strings.TrimSuffix(h.getConfig().DocumentLinkTarget, "/") + "/" + data.ImportPath
+	return link, nil
+}
+
+// directiveLinks returns the links of the arguments of the go:generate and
+// go:embed directives of file naming the files of dir, the directory of
+// file. The flags and the patterns matching several files are left out.
+func directiveLinks(fset *token.FileSet, file *ast.File, content []byte, dir string) []protocol.DocumentLink {
+	var links []protocol.DocumentLink
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			var args []directiveArg
+			switch {
+			case isGenerateDirective(c.Text):
+				// go generate only runs the directives starting a line.
+				if fset.Position(c.Pos()).Column != 1 {
+					continue
+				}
+				args = directiveArgs(c.Text, len("//go:generate"))
+			case isEmbedDirective(c.Text):
+				args = directiveArgs(c.Text, len("//go:embed"))
+			default:
+				continue
+			}
+
+			for _, arg := range args {
+				if arg.value == "" || strings.HasPrefix(arg.value, "-") {
+					continue
+				}
+				name := filepath.FromSlash(arg.value)
+				if !filepath.IsAbs(name) {
+					name = filepath.Join(dir, name)
+				}
+				if info, err := os.Stat(name); err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				start := c.Pos() + token.Pos(arg.offset)
+				links = append(links, protocol.DocumentLink{
+					Range:  toUTF16Range(content, rangeForNode(fset, fakeNode{start, start + token.Pos(arg.length)})),
+					Target: string(util.PathToURI(name)),
+				})
+			}
+		}
+	}
+	return links
+}
+
+// isEmbedDirective reports whether the comment text is a go:embed
+// directive.
+func isEmbedDirective(text string) bool {
+	return strings.HasPrefix(text, "//go:embed ") || strings.HasPrefix(text, "//go:embed\t")
+}
+
+// directiveArg is an argument of a directive.
+type directiveArg struct {
+	value string
+
+	// offset and length are those of the argument in the directive, which
+	// exclude its quotes.
+	offset, length int
+}
+
+// directiveArgs returns the arguments of the directive text from the
+// offset start, which are separated by spaces and tabs. An argument may be
+// a double quoted string or, like the patterns of go:embed, a raw string.
+func directiveArgs(text string, start int) []directiveArg {
+	var args []directiveArg
+	i := start
+	for i < len(text) {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+
+		j := i
+		switch text[i] {
+		case '"', '`':
+			quote := text[i]
+			j++
+			for j < len(text) && text[j] != quote {
+				if quote == '"' && text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(text) {
+				// The quote is not closed.
+				return args
+			}
+			j++
+			value, err := strconv.Unquote(text[i:j])
+			if err != nil {
+				return args
+			}
+			args = append(args, directiveArg{value: value, offset: i + 1, length: j - i - 2})
+		default:
+			for j < len(text) && text[j] != ' ' && text[j] != '\t' {
+				j++
+			}
+			args = append(args, directiveArg{value: text[i:j], offset: i, length: j - i})
+		}
+		i = j
+	}
+	return args
+}
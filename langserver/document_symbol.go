@@ -0,0 +1,248 @@
+package langserver
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// documentSymbols returns the symbols declared in file as a tree: the
+// fields and methods of a type are its children, the types declared in a
+// function are the children of the function, and the constants and
+// variables of a grouped declaration are the children of the group.
+func documentSymbols(fset *token.FileSet, file *ast.File) []protocol.DocumentSymbol {
+	local := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				local[spec.(*ast.TypeSpec).Name.Name] = true
+			}
+		}
+	}
+
+	symbols := []protocol.DocumentSymbol{}
+	// typeIndex holds the index in symbols of the types of the file.
+	typeIndex := make(map[string]int)
+	var methods []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && local[methodTypeName(decl)] {
+				methods = append(methods, decl)
+				continue
+			}
+			symbols = append(symbols, funcSymbol(fset, decl))
+
+		case *ast.GenDecl:
+			switch decl.Tok {
+			case token.TYPE:
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.TypeSpec)
+					if spec.Name.Name == "_" {
+						continue
+					}
+					typeIndex[spec.Name.Name] = len(symbols)
+					symbols = append(symbols, typeSymbol(fset, decl, spec))
+				}
+			case token.CONST, token.VAR:
+				symbols = append(symbols, valueSymbols(fset, decl)...)
+			}
+		}
+	}
+
+	// The methods follow the fields of their type, wherever they are
+	// declared in the file.
+	for _, method := range methods {
+		i := typeIndex[methodTypeName(method)]
+		symbols[i].Children = append(symbols[i].Children, funcSymbol(fset, method))
+	}
+	return symbols
+}
+
+// utf16DocumentSymbols converts the ranges of symbols and of their
+// children, whose character offsets count the bytes of content, to
+// protocol ranges, in place.
+func utf16DocumentSymbols(content []byte, symbols []protocol.DocumentSymbol) []protocol.DocumentSymbol {
+	for i := range symbols {
+		symbols[i].Range = toUTF16Range(content, symbols[i].Range)
+		symbols[i].SelectionRange = toUTF16Range(content, symbols[i].SelectionRange)
+		utf16DocumentSymbols(content, symbols[i].Children)
+	}
+	return symbols
+}
+
+// methodTypeName returns the name of the receiver type of the method fun.
+func methodTypeName(fun *ast.FuncDecl) string {
+	if len(fun.Recv.List) != 1 {
+		return ""
+	}
+	return strings.TrimPrefix(recvString(fun.Recv.List[0].Type), "*")
+}
+
+// funcSymbol returns the symbol of the function or method fun, with the
+// types declared in its body as children.
+func funcSymbol(fset *token.FileSet, fun *ast.FuncDecl) protocol.DocumentSymbol {
+	symbol := protocol.DocumentSymbol{
+		Name:           fun.Name.Name,
+		Detail:         types.ExprString(fun.Type),
+		Kind:           lsp.SKFunction,
+		Range:          declRange(fset, fun.Doc, fun),
+		SelectionRange: rangeForNode(fset, fun.Name),
+	}
+	if fun.Recv != nil {
+		symbol.Kind = lsp.SKMethod
+		if len(fun.Recv.List) == 1 {
+			symbol.Detail = "(" + recvString(fun.Recv.List[0].Type) + ") " + symbol.Detail
+		}
+	}
+	if fun.Body == nil {
+		return symbol
+	}
+
+	ast.Inspect(fun.Body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.DeclStmt)
+		if !ok {
+			return true
+		}
+		if gen, ok := stmt.Decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if spec.Name.Name != "_" {
+					symbol.Children = append(symbol.Children, typeSymbol(fset, gen, spec))
+				}
+			}
+		}
+		return false
+	})
+	return symbol
+}
+
+// typeSymbol returns the symbol of the type spec of the declaration gen,
+// with the fields of a struct or the methods of an interface as children.
+func typeSymbol(fset *token.FileSet, gen *ast.GenDecl, spec *ast.TypeSpec) protocol.DocumentSymbol {
+	symbol := protocol.DocumentSymbol{
+		Name:           spec.Name.Name,
+		Detail:         types.ExprString(spec.Type),
+		Kind:           lsp.SKClass,
+		Range:          specRange(fset, gen, spec.Doc, spec),
+		SelectionRange: rangeForNode(fset, spec.Name),
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		symbol.Detail = "struct"
+		symbol.Children = fieldSymbols(fset, t.Fields, lsp.SKField)
+	case *ast.InterfaceType:
+		symbol.Kind = lsp.SKInterface
+		symbol.Detail = "interface"
+		symbol.Children = fieldSymbols(fset, t.Methods, lsp.SKMethod)
+	}
+	return symbol
+}
+
+// fieldSymbols returns the symbols of the fields of a struct, or of the
+// methods of an interface if kind is lsp.SKMethod. Embedded fields are
+// named after their type.
+func fieldSymbols(fset *token.FileSet, fields *ast.FieldList, kind lsp.SymbolKind) []protocol.DocumentSymbol {
+	var symbols []protocol.DocumentSymbol
+	for _, field := range fields.List {
+		r := declRange(fset, field.Doc, field)
+		if len(field.Names) == 0 {
+			name := strings.TrimPrefix(types.ExprString(field.Type), "*")
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+			embeddedKind := lsp.SKField
+			if kind == lsp.SKMethod {
+				embeddedKind = lsp.SKInterface
+			}
+			symbols = append(symbols, protocol.DocumentSymbol{
+				Name:           name,
+				Detail:         types.ExprString(field.Type),
+				Kind:           embeddedKind,
+				Range:          r,
+				SelectionRange: rangeForNode(fset, field.Type),
+			})
+			continue
+		}
+		for _, name := range field.Names {
+			if name.Name == "_" {
+				continue
+			}
+			symbols = append(symbols, protocol.DocumentSymbol{
+				Name:           name.Name,
+				Detail:         types.ExprString(field.Type),
+				Kind:           kind,
+				Range:          r,
+				SelectionRange: rangeForNode(fset, name),
+			})
+		}
+	}
+	return symbols
+}
+
+// valueSymbols returns the symbols of the constants or variables of the
+// declaration gen. Those of a grouped declaration are the children of a
+// single symbol for the group.
+func valueSymbols(fset *token.FileSet, gen *ast.GenDecl) []protocol.DocumentSymbol {
+	kind := lsp.SKVariable
+	if gen.Tok == token.CONST {
+		kind = lsp.SKConstant
+	}
+
+	var symbols []protocol.DocumentSymbol
+	for _, spec := range gen.Specs {
+		spec := spec.(*ast.ValueSpec)
+		var detail string
+		if spec.Type != nil {
+			detail = types.ExprString(spec.Type)
+		}
+		for _, name := range spec.Names {
+			if name.Name == "_" {
+				continue
+			}
+			symbols = append(symbols, protocol.DocumentSymbol{
+				Name:           name.Name,
+				Detail:         detail,
+				Kind:           kind,
+				Range:          specRange(fset, gen, spec.Doc, spec),
+				SelectionRange: rangeForNode(fset, name),
+			})
+		}
+	}
+	if !gen.Lparen.IsValid() || len(symbols) == 0 {
+		return symbols
+	}
+
+	keyword := gen.Tok.String()
+	return []protocol.DocumentSymbol{{
+		Name:           keyword,
+		Kind:           kind,
+		Range:          declRange(fset, gen.Doc, gen),
+		SelectionRange: rangeForNode(fset, fakeNode{gen.TokPos, gen.TokPos + token.Pos(len(keyword))}),
+		Children:       symbols,
+	}}
+}
+
+// specRange returns the range of the spec of the declaration gen with the
+// given doc comment: the range of the whole declaration if it is not
+// grouped.
+func specRange(fset *token.FileSet, gen *ast.GenDecl, doc *ast.CommentGroup, spec ast.Spec) lsp.Range {
+	if !gen.Lparen.IsValid() {
+		return declRange(fset, gen.Doc, gen)
+	}
+	return declRange(fset, doc, spec)
+}
+
+// declRange returns the range of the declaration node, including its doc
+// comment.
+func declRange(fset *token.FileSet, doc *ast.CommentGroup, node ast.Node) lsp.Range {
+	start := node.Pos()
+	if doc != nil {
+		start = doc.Pos()
+	}
+	return rangeForNode(fset, fakeNode{start, node.End()})
+}
@@ -0,0 +1,35 @@
+package langserver
+
+import (
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// workspaceEdit returns the workspace edit of the text edits changes, by
+// document URI. If the client supports them, the edits are sent as
+// document changes carrying the versions of the open documents, so that
+// the client can refuse to apply them to documents changed since. The
+// version of the documents which are not open is null.
+func (h *LangHandler) workspaceEdit(changes map[string][]lsp.TextEdit) protocol.WorkspaceEdit {
+	if h.init == nil || !h.init.ClientCapabilities.Workspace.WorkspaceEdit.DocumentChanges {
+		return protocol.WorkspaceEdit{Changes: changes}
+	}
+
+	uris := make([]string, 0, len(changes))
+	for uri := range changes {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	documentChanges := make([]protocol.TextDocumentEdit, 0, len(uris))
+	for _, uri := range uris {
+		doc := protocol.VersionedTextDocumentIdentifier{URI: lsp.DocumentURI(uri)}
+		if version, ok := h.overlay.version(doc.URI); ok {
+			doc.Version = &version
+		}
+		documentChanges = append(documentChanges, protocol.TextDocumentEdit{TextDocument: doc, Edits: changes[uri]})
+	}
+	return protocol.WorkspaceEdit{DocumentChanges: documentChanges}
+}
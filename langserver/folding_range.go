@@ -0,0 +1,89 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleTextDocumentFoldingRange returns the folding ranges of the blocks,
+// the bodies of the struct and interface types, the composite literals and
+// the declaration groups of the document spanning several lines, and those
+// of its comment groups. The document is parsed on its own, so that its
+// ranges do not depend on its package being well typed.
+func (h *LangHandler) handleTextDocumentFoldingRange(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
+	uri := params.TextDocument.URI
+	if err := checkFileURI(uri); err != nil {
+		return nil, err
+	}
+
+	content, err := h.project.FileContent(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, util.UriToRealPath(uri), content, parser.ParseComments)
+	if file == nil {
+		return nil, err
+	}
+	return foldingRanges(fset, file), nil
+}
+
+// foldingRanges returns the folding ranges of file, ordered by their start.
+// The ranges of the brackets end on the line before the closing one, which
+// stays visible.
+func foldingRanges(fset *token.FileSet, file *ast.File) []protocol.FoldingRange {
+	ranges := []protocol.FoldingRange{}
+	add := func(open, close token.Pos, kind protocol.FoldingRangeKind) {
+		// The brackets of a node with syntax errors may be missing.
+		if !open.IsValid() || !close.IsValid() {
+			return
+		}
+		start, end := fset.Position(open).Line-1, fset.Position(close).Line-2
+		if end > start {
+			ranges = append(ranges, protocol.FoldingRange{StartLine: start, EndLine: end, Kind: kind})
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			add(n.Lbrace, n.Rbrace, "")
+		case *ast.StructType:
+			add(n.Fields.Opening, n.Fields.Closing, "")
+		case *ast.InterfaceType:
+			add(n.Methods.Opening, n.Methods.Closing, "")
+		case *ast.CompositeLit:
+			add(n.Lbrace, n.Rbrace, "")
+		case *ast.GenDecl:
+			if !n.Lparen.IsValid() {
+				break
+			}
+			var kind protocol.FoldingRangeKind
+			if n.Tok == token.IMPORT {
+				kind = protocol.ImportsFoldingRange
+			}
+			add(n.Lparen, n.Rparen, kind)
+		}
+		return true
+	})
+
+	for _, group := range file.Comments {
+		// The comment group ends on its last line.
+		start, end := fset.Position(group.Pos()).Line-1, fset.Position(group.End()).Line-1
+		if end > start {
+			ranges = append(ranges, protocol.FoldingRange{StartLine: start, EndLine: end, Kind: protocol.CommentFoldingRange})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].StartLine < ranges[j].StartLine
+	})
+	return ranges
+}
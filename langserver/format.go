@@ -9,9 +9,13 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -21,15 +25,70 @@ const (
 )
 
 func (h *LangHandler) handleTextDocumentFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentFormattingParams) ([]lsp.TextEdit, error) {
-	return formatRange(ctx, h.View(), params.TextDocument.URI, nil, h.DefaultConfig.FormatStyle == goimportsStyle)
+	uri := params.TextDocument.URI
+	return formatRange(ctx, h.View(), uri, nil, h.getConfig().FormatStyle == goimportsStyle, h.packageIndex(), h.localPrefixes(uri))
 }
 
+// handleTextDocumentRangeFormatting formats the declarations overlapping
+// the range. The imports are left as they are even with goimports, which
+// would change the rest of the file.
 func (h *LangHandler) handleTextDocumentRangeFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentRangeFormattingParams) ([]lsp.TextEdit, error) {
-	return formatRange(ctx, h.View(), params.TextDocument.URI, &params.Range, h.DefaultConfig.FormatStyle == goimportsStyle)
+	return formatRange(ctx, h.View(), params.TextDocument.URI, &params.Range, false, nil, nil)
 }
 
-// formatRange formats a document with a given range.
-func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *lsp.Range, imports bool) ([]lsp.TextEdit, error) {
+func (h *LangHandler) handleTextDocumentOnTypeFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.DocumentOnTypeFormattingParams) ([]lsp.TextEdit, error) {
+	uri, err := fromProtocolURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	f, err := h.View().GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.GetToken(ctx)
+	if tok == nil {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", params.TextDocument.URI))
+	}
+	pos := fromProtocolPosition(tok, fromUTF16Position(f.GetContent(ctx), params.Position))
+	if !pos.IsValid() {
+		return []lsp.TextEdit{}, nil
+	}
+	edits, err := source.OnTypeFormat(ctx, f, pos, params.Ch)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolEdits(ctx, f, edits), nil
+}
+
+// packageIndex returns the index of the packages goimports adds imports
+// of: the global cache, or nil if it is disabled and goimports has to scan
+// GOPATH.
+func (h *LangHandler) packageIndex() source.PackageIndex {
+	if h.project.Cache() == nil {
+		return nil
+	}
+	return h.project
+}
+
+// localPrefixes returns the prefixes of the paths of the packages whose
+// imports are grouped last in the document uri: the configured ones, and
+// the path of the module of the document.
+func (h *LangHandler) localPrefixes(uri lsp.DocumentURI) []string {
+	var local []string
+	for _, prefix := range strings.Split(h.getConfig().GoimportsLocalPrefix, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			local = append(local, prefix)
+		}
+	}
+	if modulePath := cache.ModulePath(util.UriToRealPath(uri)); modulePath != "" {
+		local = append(local, modulePath)
+	}
+	return local
+}
+
+// formatRange formats a document with a given range, with goimports if
+// imports is set.
+func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *lsp.Range, imports bool, index source.PackageIndex, local []string) ([]lsp.TextEdit, error) {
 	sourceURI, err := fromProtocolURI(uri)
 	if err != nil {
 		return nil, err
@@ -47,12 +106,12 @@ func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *l
 		r.Start = tok.Pos(0)
 		r.End = tok.Pos(tok.Size())
 	} else {
-		r = fromProtocolRange(tok, *rng)
+		r = fromProtocolRange(tok, fromUTF16Range(f.GetContent(ctx), *rng))
 	}
 
 	var edits []source.TextEdit
 	if imports {
-		edits, err = source.Imports(ctx, f, r)
+		edits, err = source.Imports(ctx, f, r, index, local)
 	} else {
 		edits, err = source.Format(ctx, f, r)
 	}
@@ -62,15 +121,18 @@ func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *l
 	return toProtocolEdits(ctx, f, edits), nil
 }
 
+// toProtocolEdits converts the edits of the file f to protocol edits, whose
+// character offsets count UTF-16 code units.
 func toProtocolEdits(ctx context.Context, f source.File, edits []source.TextEdit) []lsp.TextEdit {
 	if edits == nil {
 		return []lsp.TextEdit{}
 	}
 
+	content := f.GetContent(ctx)
 	result := make([]lsp.TextEdit, len(edits))
 	for i, edit := range edits {
 		result[i] = lsp.TextEdit{
-			Range:   toProtocolRange(edit.Span),
+			Range:   toUTF16Range(content, toProtocolRange(edit.Span)),
 			NewText: edit.NewText,
 		}
 	}
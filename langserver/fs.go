@@ -5,13 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/analysis"
 )
 
 // isFileSystemRequest returns if this is an LSP method whose sole
@@ -20,7 +28,8 @@ func isFileSystemRequest(method string) bool {
 	return method == "textDocument/didOpen" ||
 		method == "textDocument/didChange" ||
 		method == "textDocument/didClose" ||
-		method == "textDocument/didSave"
+		method == "textDocument/didSave" ||
+		method == "workspace/didChangeWatchedFiles"
 }
 
 // handleFileSystemRequest handles textDocument/did* requests. The URI the
@@ -80,6 +89,15 @@ func (h *HandlerShared) handleFileSystemRequest(ctx context.Context, req *jsonrp
 		overlay.didSave(ctx, &params)
 		return nil
 
+	case "workspace/didChangeWatchedFiles":
+		var params protocol.DidChangeWatchedFilesParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return err
+		}
+
+		overlay.didChangeWatchedFiles(ctx, &params)
+		return nil
+
 	default:
 		panic("unexpected file system request method: " + req.Method)
 	}
@@ -88,13 +106,122 @@ func (h *HandlerShared) handleFileSystemRequest(ctx context.Context, req *jsonrp
 // overlay owns the overlay filesystem, as well as handling LSP filesystem
 // requests.
 type overlay struct {
-	conn             *jsonrpc2.Conn
-	project          *cache.Project
+	conn    *jsonrpc2.Conn
+	project *cache.Project
+	// symlinks maps the real paths of the files the diagnostics are
+	// published for to the paths the client sees them with.
+	symlinks *symlinks
+	// relatedInformation is whether the client accepts diagnostics with
+	// related information.
+	relatedInformation bool
+
+	mu               sync.Mutex
 	diagnosticsStyle DiagnosticsStyleEnum
+	// vet are the analyzers run over the package of a document when it is
+	// saved, if any.
+	vet []*analysis.Analyzer
+	// versions holds the versions of the open documents, as sent by the
+	// client.
+	versions map[span.URI]int
+	// pending holds the timers of the diagnostics scheduled for the
+	// documents changed during the last diagnosticsDelay.
+	pending map[span.URI]*time.Timer
+	// errored maps the path of every package whose last published
+	// diagnostics were not empty to one of its files.
+	errored map[string]span.URI
+	// findings holds the findings of the analyzers for the files of the
+	// saved packages, until the files change.
+	findings map[string][]protocol.Diagnostic
+	// fixes holds the fixes the analyzers suggest for their findings, by
+	// file, as long as the findings.
+	fixes map[string][]suggestedFix
+	// modErrors holds the go.mod files with published diagnostics.
+	modErrors map[string]bool
+	// published maps the files with published diagnostics to the path of
+	// their package, so that the diagnostics are cleared once the files
+	// are deleted or leave the package.
+	published map[span.URI]string
+}
+
+// diagnosticsDelay is how long the diagnostics of a document wait for the
+// next change before being published, so that a burst of keystrokes only
+// type checks its package once.
+const diagnosticsDelay = 200 * time.Millisecond
+
+func newOverlay(conn *jsonrpc2.Conn, project *cache.Project, symlinks *symlinks, diagnosticsStyle DiagnosticsStyleEnum, vet []*analysis.Analyzer, relatedInformation bool) *overlay {
+	return &overlay{
+		conn:               conn,
+		project:            project,
+		symlinks:           symlinks,
+		diagnosticsStyle:   diagnosticsStyle,
+		vet:                vet,
+		relatedInformation: relatedInformation,
+		versions:           make(map[span.URI]int),
+		pending:            make(map[span.URI]*time.Timer),
+		errored:            make(map[string]span.URI),
+		findings:           make(map[string][]protocol.Diagnostic),
+		fixes:              make(map[string][]suggestedFix),
+		modErrors:          make(map[string]bool),
+		published:          make(map[span.URI]string),
+	}
 }
 
-func newOverlay(conn *jsonrpc2.Conn, project *cache.Project, diagnosticsStyle DiagnosticsStyleEnum) *overlay {
-	return &overlay{conn: conn, project: project, diagnosticsStyle: diagnosticsStyle}
+// configure sets how the diagnostics of the documents are published, after
+// the configuration changed.
+func (h *overlay) configure(diagnosticsStyle DiagnosticsStyleEnum, vet []*analysis.Analyzer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.diagnosticsStyle = diagnosticsStyle
+	h.vet = vet
+}
+
+func (h *overlay) style() DiagnosticsStyleEnum {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.diagnosticsStyle
+}
+
+func (h *overlay) analyzers() []*analysis.Analyzer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.vet
+}
+
+// version returns the version of the document uri, if it is open.
+func (h *overlay) version(uri lsp.DocumentURI) (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	open, ok := h.openURI(span.FromDocumentURI(uri))
+	if !ok {
+		return 0, false
+	}
+	return h.versions[open], true
+}
+
+func (h *overlay) setVersion(uri lsp.DocumentURI, version int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	open, ok := h.openURI(span.FromDocumentURI(uri))
+	if !ok {
+		open = span.FromDocumentURI(uri)
+	}
+	h.versions[open] = version
+}
+
+// openURI returns the URI under which the document uri was opened, if it
+// is open. The URIs of the files of Windows are matched regardless of case,
+// as the clients do not agree on the case of their paths. h.mu must be
+// held.
+func (h *overlay) openURI(uri span.URI) (span.URI, bool) {
+	if _, ok := h.versions[uri]; ok {
+		return uri, true
+	}
+	for open := range h.versions {
+		if util.URIEqual(lsp.DocumentURI(open), lsp.DocumentURI(uri)) {
+			return open, true
+		}
+	}
+	return "", false
 }
 
 func (h *overlay) view() source.View {
@@ -102,6 +229,7 @@ func (h *overlay) view() source.View {
 }
 
 func (h *overlay) didOpen(ctx context.Context, params *lsp.DidOpenTextDocumentParams) {
+	h.setVersion(params.TextDocument.URI, params.TextDocument.Version)
 	h.cacheAndDiagnose(ctx, params.TextDocument.URI, []byte(params.TextDocument.Text))
 }
 
@@ -115,29 +243,222 @@ func (h *overlay) didChange(ctx context.Context, params *lsp.DidChangeTextDocume
 		return err
 	}
 
+	h.setVersion(params.TextDocument.URI, params.TextDocument.Version)
+	if filename, err := span.FromDocumentURI(params.TextDocument.URI).Filename(); err == nil {
+		// The findings of the analyzers are out of date.
+		h.mu.Lock()
+		delete(h.findings, filename)
+		delete(h.fixes, filename)
+		h.mu.Unlock()
+	}
 	h.cacheAndDiagnose(ctx, params.TextDocument.URI, text)
 	return nil
 }
 
 func (h *overlay) didClose(ctx context.Context, params *lsp.DidCloseTextDocumentParams) {
 	uri := span.FromDocumentURI(params.TextDocument.URI)
+	h.mu.Lock()
+	if open, ok := h.openURI(uri); ok {
+		uri = open
+	}
+	delete(h.versions, uri)
+	if timer, ok := h.pending[uri]; ok {
+		timer.Stop()
+		delete(h.pending, uri)
+	}
+	h.mu.Unlock()
 	h.setContent(ctx, uri, nil)
+
+	// A document which was never saved has no file to report on anymore.
+	if filename, err := uri.Filename(); err == nil {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			h.clearDiagnostics(ctx, uri)
+		}
+	}
 }
 
-func (h *overlay) didSave(ctx context.Context, param *lsp.DidSaveTextDocumentParams) {
-	if h.diagnosticsStyle != onsaveDiagnostics {
+// didChangeWatchedFiles forgets the packages of the files changed outside
+// of the editor, and publishes the diagnostics of the open documents again,
+// as they may depend on them. A created or deleted Go file invalidates the
+// packages of its directory, and the diagnostics of a deleted one are
+// cleared. A changed Go file invalidates its packages, unless it is open, as
+// the editor owns its content then. A changed go.mod or go.sum file reloads
+// all the packages, and go.mod is checked again.
+func (h *overlay) didChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) {
+	changed := false
+	gomods := make(map[string]bool)
+	for _, change := range params.Changes {
+		uri := span.FromDocumentURI(change.URI)
+		filename, err := uri.Filename()
+		if err != nil {
+			continue
+		}
+		switch filepath.Base(filename) {
+		case "go.mod", "go.sum":
+			gomods[filepath.Join(filepath.Dir(filename), "go.mod")] = true
+			continue
+		}
+		if !strings.HasSuffix(filename, ".go") {
+			continue
+		}
+
+		switch change.Type {
+		case protocol.Created:
+			h.project.Invalidate(filepath.Dir(filename))
+		case protocol.Changed:
+			h.mu.Lock()
+			_, open := h.openURI(uri)
+			h.mu.Unlock()
+			if open {
+				continue
+			}
+			h.project.InvalidateFile(filename)
+		case protocol.Deleted:
+			h.mu.Lock()
+			if timer, ok := h.pending[uri]; ok {
+				timer.Stop()
+				delete(h.pending, uri)
+			}
+			delete(h.findings, filename)
+			delete(h.fixes, filename)
+			h.mu.Unlock()
+			h.setContent(ctx, uri, nil)
+			h.clearDiagnostics(ctx, uri)
+			h.project.Invalidate(filepath.Dir(filename))
+		default:
+			continue
+		}
+		changed = true
+	}
+
+	if len(gomods) > 0 {
+		h.project.Reload()
+		for gomod := range gomods {
+			if _, err := os.Stat(gomod); err == nil {
+				h.didSaveGoMod(ctx, gomod)
+			}
+		}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	h.scheduleOpenDiagnostics(ctx, func(filename string) bool {
+		return strings.HasSuffix(filename, ".go")
+	})
+}
+
+// didGenerate publishes the diagnostics of the open documents of dir again
+// once go generate has run there, as they may use the generated code.
+func (h *overlay) didGenerate(ctx context.Context, dir string) {
+	dir = filepath.Clean(dir)
+	h.scheduleOpenDiagnostics(ctx, func(filename string) bool {
+		return filepath.Dir(filename) == dir
+	})
+}
+
+// scheduleOpenDiagnostics schedules the diagnostics of the open documents
+// whose file names match.
+func (h *overlay) scheduleOpenDiagnostics(ctx context.Context, match func(filename string) bool) {
+	if h.style() == noneDiagnostics {
 		return
 	}
 
+	h.mu.Lock()
+	var open []span.URI
+	for uri := range h.versions {
+		if filename, err := uri.Filename(); err == nil && match(filename) {
+			open = append(open, uri)
+		}
+	}
+	h.mu.Unlock()
+	for _, uri := range open {
+		if f, err := h.view().GetFile(ctx, uri); err == nil {
+			h.scheduleDiagnostics(f)
+		}
+	}
+}
+
+func (h *overlay) didSave(ctx context.Context, param *lsp.DidSaveTextDocumentParams) {
 	sourceURI := span.FromDocumentURI(param.TextDocument.URI)
+	if filename, err := sourceURI.Filename(); err == nil && filepath.Base(filename) == "go.mod" {
+		h.didSaveGoMod(ctx, filename)
+		return
+	}
+
+	if h.style() != onsaveDiagnostics && len(h.analyzers()) == 0 {
+		return
+	}
+
 	f, err := h.view().GetFile(ctx, sourceURI)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
+	if len(h.analyzers()) > 0 {
+		h.runVet(ctx, f)
+	}
 	h.diagnosetics(ctx, f)
 }
 
+// didSaveGoMod checks the saved go.mod file, which reloads the packages
+// of the workspace if it has been fixed, then publishes its diagnostics and
+// those of the open documents again.
+func (h *overlay) didSaveGoMod(ctx context.Context, filename string) {
+	h.project.CheckGoMod(ctx, filename)
+	if h.style() == noneDiagnostics {
+		return
+	}
+
+	h.publishModuleDiagnostics(ctx)
+	h.scheduleOpenDiagnostics(ctx, func(filename string) bool {
+		return strings.HasSuffix(filename, ".go")
+	})
+}
+
+// runVet runs the analyzers over the package of f, if it is well typed,
+// and records their findings for its files.
+func (h *overlay) runVet(ctx context.Context, f source.File) {
+	pkg := f.GetPackage(ctx)
+	if pkg == nil {
+		return
+	}
+	var findings map[string][]protocol.Diagnostic
+	var fixes map[string][]suggestedFix
+	if !pkg.IsIllTyped() && len(pkg.GetErrors()) == 0 {
+		findings, fixes = vetDiagnostics(ctx, h.view(), pkg, h.analyzers())
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, filename := range pkg.GetFilenames() {
+		if diagnostics, ok := findings[filename]; ok {
+			h.findings[filename] = diagnostics
+		} else {
+			delete(h.findings, filename)
+		}
+		if fixes, ok := fixes[filename]; ok {
+			h.fixes[filename] = fixes
+		} else {
+			delete(h.fixes, filename)
+		}
+	}
+}
+
+// suggestedFixes returns the fixes suggested for the finding d of an
+// analyzer in the file filename.
+func (h *overlay) suggestedFixes(filename string, d lsp.Diagnostic) []suggestedFix {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var fixes []suggestedFix
+	for _, fix := range h.fixes[filename] {
+		if fix.diagnostic.Range == d.Range && fix.diagnostic.Message == d.Message && fix.diagnostic.Source == d.Source {
+			fixes = append(fixes, fix)
+		}
+	}
+	return fixes
+}
+
 func (h *overlay) cacheAndDiagnose(ctx context.Context, uri lsp.DocumentURI, text []byte) {
 	sourceURI := span.FromDocumentURI(uri)
 	h.setContent(ctx, sourceURI, text)
@@ -145,11 +466,38 @@ func (h *overlay) cacheAndDiagnose(ctx context.Context, uri lsp.DocumentURI, tex
 	if err != nil {
 		return
 	}
-	if h.diagnosticsStyle != instantDiagnostics {
+	if h.style() != instantDiagnostics {
 		return
 	}
 
-	go h.diagnosetics(ctx, f)
+	h.scheduleDiagnostics(f)
+}
+
+// scheduleDiagnostics publishes the diagnostics of f once it has not
+// changed for diagnosticsDelay, postponing the ones already scheduled.
+func (h *overlay) scheduleDiagnostics(f source.File) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	uri := f.URI()
+	if timer, ok := h.pending[uri]; ok {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(diagnosticsDelay, func() {
+		h.mu.Lock()
+		if h.pending[uri] != timer {
+			// Superseded by a later change.
+			h.mu.Unlock()
+			return
+		}
+		delete(h.pending, uri)
+		h.mu.Unlock()
+
+		// The context of the request is done by now.
+		h.diagnosetics(context.Background(), f)
+	})
+	h.pending[uri] = timer
 }
 
 func (h *overlay) setContent(ctx context.Context, uri span.URI, content []byte) error {
@@ -164,19 +512,176 @@ const (
 	instantDiagnostics DiagnosticsStyleEnum = "instant"
 )
 
+// diagnosetics publishes the diagnostics of every file of the package of
+// f, then those of the packages importing it which had errors, as they may
+// have been caused by the exported API of the package.
 func (h *overlay) diagnosetics(ctx context.Context, f source.File) {
-	reports, err := diagnostics(ctx, f)
-	if err == nil {
-		for filename, diagnostics := range reports {
-			fileURI := source.ToURI(filename)
-			params := &lsp.PublishDiagnosticsParams{
-				URI:         lsp.DocumentURI(fileURI),
-				Diagnostics: diagnostics,
+	pkg := h.publishDiagnostics(ctx, f)
+	h.publishModuleDiagnostics(ctx)
+	if pkg == nil {
+		return
+	}
+
+	h.mu.Lock()
+	importers := make(map[string]span.URI)
+	for pkgPath, uri := range h.errored {
+		if pkgPath != pkg.GetPkgPath() {
+			importers[pkgPath] = uri
+		}
+	}
+	h.mu.Unlock()
+
+	for _, uri := range importers {
+		importer, err := h.view().GetFile(ctx, uri)
+		if err != nil {
+			continue
+		}
+		if p := importer.GetPackage(ctx); p != nil && p.GetImport(pkg.GetPkgPath()) != nil {
+			h.publishDiagnostics(ctx, importer)
+		}
+	}
+}
+
+// publishDiagnostics publishes the diagnostics of every file of the package
+// of f, including the empty ones which clear the diagnostics previously
+// published for the file. The findings of the analyzers follow the errors
+// of the compiler. It returns the package.
+func (h *overlay) publishDiagnostics(ctx context.Context, f source.File) source.Package {
+	if ctx.Err() != nil {
+		return nil
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil {
+		// The file is part of no package, eg. a build constraint
+		// excludes it.
+		h.clearDiagnostics(ctx, f.URI())
+		return nil
+	}
+
+	reports := make(map[string][]protocol.Diagnostic)
+	if h.style() != noneDiagnostics {
+		reports = diagnostics(ctx, h.view(), pkg, h.relatedInformation)
+	}
+	errored := false
+	for _, filename := range pkg.GetFilenames() {
+		errored = errored || len(reports[filename]) > 0
+		h.mu.Lock()
+		reports[filename] = append(reports[filename], h.findings[filename]...)
+		h.mu.Unlock()
+		if reports[filename] == nil {
+			reports[filename] = []protocol.Diagnostic{}
+		}
+	}
+	// Clear the files which have left the package.
+	h.mu.Lock()
+	for uri, pkgPath := range h.published {
+		if pkgPath != pkg.GetPkgPath() {
+			continue
+		}
+		if filename, err := uri.Filename(); err == nil {
+			if _, ok := reports[filename]; !ok {
+				reports[filename] = []protocol.Diagnostic{}
 			}
+		}
+	}
+	h.mu.Unlock()
+	for filename, diagnostics := range reports {
+		fileURI := source.ToURI(filename)
+		params := &protocol.PublishDiagnosticsParams{
+			URI:         lsp.DocumentURI(fileURI),
+			Diagnostics: diagnostics,
+		}
+
+		h.publish(ctx, params)
+		h.setPublished(span.FileURI(filename), pkg.GetPkgPath(), len(diagnostics) > 0)
+	}
+
+	h.mu.Lock()
+	if errored {
+		h.errored[pkg.GetPkgPath()] = f.URI()
+	} else {
+		delete(h.errored, pkg.GetPkgPath())
+	}
+	h.mu.Unlock()
+	return pkg
+}
 
-			h.conn.Notify(ctx, "textDocument/publishDiagnostics", params)
+// setPublished records whether diagnostics of the file uri of the package
+// pkgPath are published.
+func (h *overlay) setPublished(uri span.URI, pkgPath string, published bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if published {
+		h.published[uri] = pkgPath
+	} else {
+		delete(h.published, uri)
+	}
+}
+
+// clearDiagnostics publishes an empty set of diagnostics for the file uri,
+// if its diagnostics are published.
+func (h *overlay) clearDiagnostics(ctx context.Context, uri span.URI) {
+	h.mu.Lock()
+	_, ok := h.published[uri]
+	delete(h.published, uri)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	h.publish(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         lsp.DocumentURI(uri),
+		Diagnostics: []protocol.Diagnostic{},
+	})
+}
+
+// publish sends the diagnostics of params to the client, with the paths the
+// client sees their files with.
+func (h *overlay) publish(ctx context.Context, params *protocol.PublishDiagnosticsParams) {
+	h.conn.Notify(ctx, "textDocument/publishDiagnostics", h.symlinks.outgoing(params))
+}
+
+// publishModuleDiagnostics publishes the errors of the module system the
+// packages failed to load with on the lines of the go.mod files causing
+// them, and clears the diagnostics of the go.mod files which are fixed.
+func (h *overlay) publishModuleDiagnostics(ctx context.Context) {
+	reports := make(map[string][]protocol.Diagnostic)
+	for _, e := range h.project.ModuleErrors() {
+		content := fileContent(ctx, h.view(), e.GoMod)
+		line, _ := lineContent(content, e.Line-1)
+		r := lsp.Range{
+			Start: lsp.Position{Line: e.Line - 1},
+			End:   lsp.Position{Line: e.Line - 1, Character: len(line)},
+		}
+		reports[e.GoMod] = append(reports[e.GoMod], protocol.Diagnostic{
+			Diagnostic: lsp.Diagnostic{
+				Range:    toUTF16Range(content, r),
+				Severity: lsp.Error,
+				Source:   "go.mod",
+				Message:  e.Msg,
+			},
+		})
+	}
+
+	h.mu.Lock()
+	for gomod := range h.modErrors {
+		if _, ok := reports[gomod]; !ok {
+			reports[gomod] = []protocol.Diagnostic{}
 		}
 	}
+	h.modErrors = make(map[string]bool)
+	for gomod, diagnostics := range reports {
+		if len(diagnostics) > 0 {
+			h.modErrors[gomod] = true
+		}
+	}
+	h.mu.Unlock()
+
+	for gomod, diagnostics := range reports {
+		h.publish(ctx, &protocol.PublishDiagnosticsParams{
+			URI:         lsp.DocumentURI(source.ToURI(gomod)),
+			Diagnostics: diagnostics,
+		})
+	}
 }
 
 func bytesOffset(content []byte, pos lsp.Position) int {
@@ -6,12 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"sync"
 
-	"golang.org/x/tools/imports"
-
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/go-lsp/lspext"
 	"github.com/sourcegraph/jsonrpc2"
@@ -21,10 +19,14 @@ import (
 
 // NewHandler creates a Go language server handler.
 func NewHandler(defaultCfg Config) jsonrpc2.Handler {
-	return lspHandler{jsonrpc2.HandlerWithError((&LangHandler{
+	return lspHandler{jsonrpc2.HandlerWithError(newLangHandler(defaultCfg).handle)}
+}
+
+func newLangHandler(defaultCfg Config) *LangHandler {
+	return &LangHandler{
 		DefaultConfig: defaultCfg,
 		HandlerShared: &HandlerShared{},
-	}).handle)}
+	}
 }
 
 // lspHandler wraps LangHandler to correctly handle requests in the correct
@@ -62,16 +64,46 @@ type LangHandler struct {
 
 	cancel *cancel
 
+	// runs tracks the runs of the commands of workspace/executeCommand.
+	runs *commandRuns
+
+	// builtin is the parsed builtin.go, used to resolve builtin definitions.
+	builtin builtinFile
+
+	// importPaths indexes the packages completed in import declarations.
+	importPaths *importPathIndex
+
 	// DefaultConfig is the default values used for configuration. It is
 	// combined with InitializationOptions after initialize. This should be
 	// set by LangHandler creators. Please read config instead.
 	DefaultConfig Config
 
 	// config is the language handler configuration. It is a combination of
-	// DefaultConfig and InitializationOptions.
+	// DefaultConfig, InitializationOptions and the settings of the client.
+	// Read it with getConfig, as workspace/didChangeConfiguration replaces
+	// it.
 	config *Config // pointer so we panic if someone reads before we set it.
 }
 
+// watchedFiles are the globs of the files the client is asked to watch, as
+// their changes outside of the editor change the packages.
+var watchedFiles = []string{"**/*.go", "**/go.mod", "**/go.sum"}
+
+// registerWatchedFiles asks the client to notify the creations, changes and
+// deletions of the watchedFiles with workspace/didChangeWatchedFiles.
+func registerWatchedFiles(ctx context.Context, conn jsonrpc2.JSONRPC2) error {
+	var watchers []protocol.FileSystemWatcher
+	for _, glob := range watchedFiles {
+		watchers = append(watchers, protocol.FileSystemWatcher{GlobPattern: glob})
+	}
+	params := protocol.RegistrationParams{Registrations: []protocol.Registration{{
+		ID:              "workspace/didChangeWatchedFiles",
+		Method:          "workspace/didChangeWatchedFiles",
+		RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{Watchers: watchers},
+	}}}
+	return conn.Call(ctx, "client/registerCapability", params, nil)
+}
+
 // doInit clears all internal state in h.
 func (h *LangHandler) doInit(ctx context.Context, conn *jsonrpc2.Conn, init *InitializeParams) error {
 	if util.IsURI(lsp.DocumentURI(init.InitializeParams.RootPath)) {
@@ -83,17 +115,24 @@ func (h *LangHandler) doInit(ctx context.Context, conn *jsonrpc2.Conn, init *Ini
 
 	config := h.DefaultConfig.Apply(init.InitializationOptions)
 	h.config = &config
-	imports.LocalPrefix = h.config.GoimportsLocalPrefix
 	h.init = init
 	h.cancel = NewCancel()
+	h.runs = &commandRuns{}
+	h.importPaths = &importPathIndex{}
 
+	h.symlinks = newSymlinks()
 	rootPath := h.FilePath(init.Root())
-	buildFlags := []string{}
-	if len(h.config.BuildTags) > 0 {
-		buildFlags = append(buildFlags, "-tags", strings.Join(h.config.BuildTags, " "))
+	h.project = cache.NewProject(ctx, conn, rootPath, h.config.buildFlags(), h.config.env())
+	h.project.SetWorkDoneProgress(init.ClientCapabilities.Window.WorkDoneProgress, init.WorkDoneToken)
+	h.project.SetMaxCacheMemory(h.config.MaxCacheMemoryMB)
+	relatedInformation := init.ClientCapabilities.TextDocument.PublishDiagnostics.RelatedInformation
+	h.overlay = newOverlay(conn, h.project, h.symlinks, DiagnosticsStyleEnum(h.config.DiagnosticsStyle), enabledAnalyzers(h.config), relatedInformation)
+	if h.config.WatchFiles && !init.ClientCapabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration {
+		overlay := h.overlay
+		h.project.WatchFiles(func(change protocol.FileEvent) {
+			overlay.didChangeWatchedFiles(ctx, &protocol.DidChangeWatchedFilesParams{Changes: []protocol.FileEvent{change}})
+		})
 	}
-	h.project = cache.NewProject(ctx, conn, rootPath, buildFlags)
-	h.overlay = newOverlay(conn, h.project, DiagnosticsStyleEnum(h.DefaultConfig.DiagnosticsStyle))
 	if err := h.project.Init(ctx, cache.CacheStyle(h.DefaultConfig.GlobalCacheStyle)); err != nil {
 		return err
 	}
@@ -120,6 +159,7 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 	var cancelManager *cancel
 	h.mu.Lock()
 	cancelManager = h.cancel
+	symlinks := h.symlinks
 	if req.Method != "initialize" && h.init == nil {
 		h.mu.Unlock()
 		return nil, errors.New("server must be initialized")
@@ -137,6 +177,27 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		var cancel func()
 		ctx, cancel = cancelManager.WithCancel(ctx, req.ID)
 		defer cancel()
+		// The work of a cancelled request stops at the next check of its
+		// context, and whatever it returns then is replaced by the error
+		// the protocol defines.
+		defer func() {
+			if ctx.Err() == context.Canceled {
+				result, err = nil, &jsonrpc2.Error{Code: codeRequestCancelled, Message: fmt.Sprintf("%s request cancelled", req.Method)}
+			}
+		}()
+	}
+
+	// The requests are handled with the real paths of the files, and their
+	// results carry the paths the client sees the files with.
+	if symlinks != nil {
+		if params := symlinks.incoming(req.Params); params != req.Params {
+			r := *req
+			r.Params = params
+			req = &r
+		}
+		defer func() {
+			result = symlinks.outgoing(result)
+		}()
 	}
 
 	switch req.Method {
@@ -163,35 +224,83 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 
 		kind := lsp.TDSKIncremental
-		completionOp := &lsp.CompletionOptions{TriggerCharacters: []string{"."}}
-
-		return lsp.InitializeResult{
-			Capabilities: lsp.ServerCapabilities{
-				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-					Kind:    &kind,
-					Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
+		var renameProvider interface{} = true
+		if params.ClientCapabilities.TextDocument.Rename.PrepareSupport {
+			renameProvider = protocol.RenameOptions{PrepareProvider: true}
+		}
+		var semanticTokensProvider *protocol.SemanticTokensOptions
+		if params.ClientCapabilities.TextDocument.SemanticTokens != nil {
+			semanticTokensProvider = &protocol.SemanticTokensOptions{Legend: semanticTokensLegend, Range: true, Full: true}
+		}
+		completionOp := &lsp.CompletionOptions{ResolveProvider: true, TriggerCharacters: []string{"."}}
+
+		// The kind of the synchronization takes precedence over its options,
+		// so willSaveWaitUntil requires the options alone.
+		sync := &lsp.TextDocumentSyncOptionsOrKind{
+			Kind:    &kind,
+			Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
+		}
+		if h.getConfig().OrganizeImportsOnSave {
+			sync = &lsp.TextDocumentSyncOptionsOrKind{
+				Options: &lsp.TextDocumentSyncOptions{
+					OpenClose:         true,
+					Change:            kind,
+					WillSaveWaitUntil: true,
+					Save:              &lsp.SaveOptions{},
+				},
+			}
+		}
+
+		return protocol.InitializeResult{
+			Capabilities: protocol.ServerCapabilities{
+				ServerCapabilities: lsp.ServerCapabilities{
+					TextDocumentSync:                sync,
+					CodeActionProvider:              true,
+					CodeLensProvider:                &lsp.CodeLensOptions{ResolveProvider: h.getConfig().CodeLensReferences},
+					CompletionProvider:              completionOp,
+					DefinitionProvider:              true,
+					TypeDefinitionProvider:          true,
+					DocumentFormattingProvider:      true,
+					DocumentHighlightProvider:       true,
+					DocumentRangeFormattingProvider: true,
+					DocumentSymbolProvider:          true,
+					HoverProvider:                   true,
+					ReferencesProvider:              true,
+					WorkspaceSymbolProvider:         true,
+					ImplementationProvider:          true,
+					XWorkspaceReferencesProvider:    true,
+					XDefinitionProvider:             true,
+					XWorkspaceSymbolByProperties:    true,
+					SignatureHelpProvider:           &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
+					ExecuteCommandProvider:          &lsp.ExecuteCommandOptions{Commands: commands},
+				},
+				DeclarationProvider:        true,
+				RenameProvider:             renameProvider,
+				CallHierarchyProvider:      true,
+				FoldingRangeProvider:       true,
+				SelectionRangeProvider:     true,
+				SemanticTokensProvider:     semanticTokensProvider,
+				DocumentLinkProvider:       &protocol.DocumentLinkOptions{ResolveProvider: true},
+				LinkedEditingRangeProvider: true,
+				DocumentOnTypeFormattingProvider: &protocol.DocumentOnTypeFormattingOptions{
+					FirstTriggerCharacter: "}",
+					MoreTriggerCharacter:  []string{"\n"},
 				},
-				CodeActionProvider:              false,
-				CompletionProvider:              completionOp,
-				DefinitionProvider:              true,
-				TypeDefinitionProvider:          true,
-				DocumentFormattingProvider:      true,
-				DocumentRangeFormattingProvider: true,
-				DocumentSymbolProvider:          true,
-				HoverProvider:                   true,
-				ReferencesProvider:              true,
-				RenameProvider:                  true,
-				WorkspaceSymbolProvider:         true,
-				ImplementationProvider:          true,
-				XWorkspaceReferencesProvider:    true,
-				XDefinitionProvider:             true,
-				XWorkspaceSymbolByProperties:    true,
-				SignatureHelpProvider:           &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
 			},
 		}, nil
 
 	case "initialized":
-		// A notification that the client is ready to receive requests. Ignore
+		// A notification that the client is ready to receive requests.
+		if h.init.ClientCapabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration {
+			if err := registerWatchedFiles(ctx, conn); err != nil {
+				log.Printf("langserver-go: registering the watched files failed: %s", err)
+			}
+		}
+		if h.init.ClientCapabilities.Workspace.Configuration {
+			if err := h.fetchConfiguration(ctx, conn); err != nil {
+				log.Printf("langserver-go: fetching the configuration failed: %s", err)
+			}
+		}
 		return nil, nil
 
 	case "shutdown":
@@ -243,6 +352,16 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleDefinition(ctx, conn, req, params)
 
+	case "textDocument/declaration":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDeclaration(ctx, conn, req, params)
+
 	case "textDocument/typeDefinition":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -261,7 +380,11 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
-		return h.handleXDefinition(ctx, conn, req, params)
+		res, err := h.handleXDefinition(ctx, conn, req, params)
+		if err != nil {
+			return nil, err
+		}
+		return utf16SymbolLocations(h.utf16Ranges(ctx), res), nil
 
 	case "textDocument/completion":
 		if req.Params == nil {
@@ -273,6 +396,16 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentCompletion(ctx, conn, req, params)
 
+	case "completionItem/resolve":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CompletionItem
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCompletionItemResolve(ctx, conn, req, params)
+
 	case "textDocument/references":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -283,6 +416,16 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentReferences(ctx, conn, req, params)
 
+	case "textDocument/documentHighlight":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentHighlight(ctx, conn, req, params)
+
 	case "textDocument/implementation":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -293,6 +436,106 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentImplementation(ctx, conn, req, params)
 
+	case "textDocument/prepareCallHierarchy":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentPrepareCallHierarchy(ctx, conn, req, params)
+
+	case "callHierarchy/incomingCalls":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CallHierarchyIncomingCallsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCallHierarchyIncomingCalls(ctx, conn, req, params)
+
+	case "callHierarchy/outgoingCalls":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CallHierarchyOutgoingCallsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCallHierarchyOutgoingCalls(ctx, conn, req, params)
+
+	case "textDocument/foldingRange":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.FoldingRangeParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentFoldingRange(ctx, conn, req, params)
+
+	case "textDocument/selectionRange":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.SelectionRangeParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentSelectionRange(ctx, conn, req, params)
+
+	case "textDocument/linkedEditingRange":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentLinkedEditingRange(ctx, conn, req, params)
+
+	case "textDocument/semanticTokens/full":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.SemanticTokensParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentSemanticTokensFull(ctx, conn, req, params)
+
+	case "textDocument/semanticTokens/range":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.SemanticTokensRangeParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentSemanticTokensRange(ctx, conn, req, params)
+
+	case "textDocument/documentLink":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.DocumentLinkParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentDocumentLink(ctx, conn, req, params)
+
+	case "documentLink/resolve":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.DocumentLink
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDocumentLinkResolve(ctx, conn, req, params)
+
 	case "textDocument/documentSymbol":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -333,6 +576,26 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentRangeFormatting(ctx, conn, req, params)
 
+	case "textDocument/willSaveWaitUntil":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.WillSaveTextDocumentParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentWillSaveWaitUntil(ctx, conn, req, params)
+
+	case "textDocument/onTypeFormatting":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.DocumentOnTypeFormattingParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentOnTypeFormatting(ctx, conn, req, params)
+
 	case "workspace/symbol":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -363,6 +626,16 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleRename(ctx, conn, req, params)
 
+	case "textDocument/prepareRename":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handlePrepareRename(ctx, conn, req, params)
+
 	case "textDocument/codeAction":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -374,6 +647,46 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 
 		return h.handleCodeAction(ctx, conn, req, params)
 
+	case "textDocument/codeLens":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.CodeLensParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentCodeLens(ctx, conn, req, params)
+
+	case "codeLens/resolve":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.CodeLens
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCodeLensResolve(ctx, conn, req, params)
+
+	case "workspace/executeCommand":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.ExecuteCommandParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleWorkspaceExecuteCommand(ctx, conn, req, params)
+
+	case "workspace/didChangeConfiguration":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params protocol.DidChangeConfigurationParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, h.didChangeConfiguration(ctx, conn, &params)
+
 	default:
 		if isFileSystemRequest(req.Method) {
 			err := h.handleFileSystemRequest(ctx, req)
@@ -14,15 +14,18 @@ import (
 // HandlerShared contains data structures that a build server and its
 // wrapped lang server may share in memory.
 type HandlerShared struct {
-	overlay *overlay // files to overlay
+	overlay  *overlay  // files to overlay
+	symlinks *symlinks // real paths of the files of the client
 }
 
+// FilePath returns the real path of the file uri, with the symbolic links
+// of its directories evaluated.
 func (h *HandlerShared) FilePath(uri lsp.DocumentURI) string {
 	path := util.UriToPath(uri)
 	if !strings.HasPrefix(path, "/") {
 		panic(fmt.Sprintf("bad uri %q (path %q MUST have leading slash; it can't be relative)", uri, path))
 	}
-	return util.GetRealPath(path)
+	return h.symlinks.realPath(util.UriToRealPath(uri))
 }
 
 func (h *HandlerShared) notifyError(message string) {
@@ -34,6 +37,11 @@ func (h *HandlerShared) notifyInfo(message string) {
 	_ = h.overlay.conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: message})
 }
 
+// notifyWarning notify warning to lsp client
+func (h *HandlerShared) notifyWarning(message string) {
+	_ = h.overlay.conn.Notify(context.Background(), "window/showMessage", &lsp.ShowMessageParams{Type: lsp.MTWarning, Message: message})
+}
+
 // NotifyLog notify log to lsp client
 func (h *HandlerShared) notifyLog(message string) {
 	_ = h.overlay.conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: message})
@@ -52,5 +60,5 @@ func defaultFindPackageFunc(project *cache.Project, importPath string) (source.P
 		return nil, fmt.Errorf("import %q: cannot import absolute path", importPath)
 	}
 
-	return project.GetFromPkgPath(importPath), nil
+	return project.GetFromImportPath(importPath), nil
 }
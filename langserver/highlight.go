@@ -0,0 +1,107 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *LangHandler) handleTextDocumentHighlight(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]protocol.DocumentHighlight, error) {
+	highlights, err := h.doHandleTextDocumentHighlight(ctx, params)
+	if err != nil && params.Position.Character > 0 {
+		// The cursor may be right after an identifier.
+		params.Position.Character--
+		highlights, err = h.doHandleTextDocumentHighlight(ctx, params)
+	}
+
+	if isEmptyResult(err) {
+		return []protocol.DocumentHighlight{}, nil
+	}
+	return highlights, err
+}
+
+// doHandleTextDocumentHighlight returns the occurrences of the object at
+// the position of params in its file. Only the file is walked, since
+// clients send this request whenever the cursor moves.
+func (h *LangHandler) doHandleTextDocumentHighlight(ctx context.Context, params lsp.TextDocumentPositionParams) ([]protocol.DocumentHighlight, error) {
+	pkg, pathNodes, err := h.pathNodesAt(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ident, err := identFromPathNodes(pkg, pathNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	obj := info.ObjectOf(ident)
+	file, ok := pathNodes[len(pathNodes)-1].(*ast.File)
+	if obj == nil || !ok {
+		return []protocol.DocumentHighlight{}, nil
+	}
+
+	content, contentErr := h.project.FileContent(ctx, params.TextDocument.URI)
+	writes := writtenIdents(file)
+	highlights := []protocol.DocumentHighlight{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || info.ObjectOf(id) != obj {
+			return true
+		}
+
+		kind := protocol.HighlightRead
+		if _, isDef := info.Defs[id]; isDef || writes[id] {
+			kind = protocol.HighlightWrite
+		}
+
+		// The character offsets of the client count UTF-16 code units.
+		r := rangeForNode(pkg.GetFileSet(), id)
+		if contentErr == nil {
+			r = toUTF16Range(content, r)
+		}
+		highlights = append(highlights, protocol.DocumentHighlight{Range: r, Kind: kind})
+		return true
+	})
+	return highlights, nil
+}
+
+// writtenIdents returns the identifiers of file which are assigned to by
+// assignments, increments, decrements and range clauses. For an assigned
+// selector, like x.f, the selected identifier is returned.
+func writtenIdents(file *ast.File) map[*ast.Ident]bool {
+	writes := make(map[*ast.Ident]bool)
+	add := func(expr ast.Expr) {
+		switch x := astutil.Unparen(expr).(type) {
+		case *ast.Ident:
+			writes[x] = true
+		case *ast.SelectorExpr:
+			writes[x.Sel] = true
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				add(lhs)
+			}
+		case *ast.IncDecStmt:
+			add(n.X)
+		case *ast.RangeStmt:
+			if n.Key != nil {
+				add(n.Key)
+			}
+			if n.Value != nil {
+				add(n.Value)
+			}
+		}
+		return true
+	})
+	return writes
+}
@@ -6,14 +6,21 @@ import (
 	"fmt"
 	"go/ast"
 	"go/build"
+	"go/constant"
+	"go/doc"
 	"go/format"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
-	doc "github.com/slimsag/godocmd"
+	"golang.org/x/tools/go/types/typeutil"
 
+	"github.com/saibing/bingo/langserver/internal/markdown"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
 
@@ -21,7 +28,72 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleHover(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (*lsp.Hover, error) {
+func (h *LangHandler) handleHover(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (interface{}, error) {
+	hover, err := h.hover(ctx, params)
+	if hover == nil || err != nil {
+		return nil, err
+	}
+
+	if h.supportsMarkdownHover() {
+		return &protocol.Hover{
+			Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: contentsToMarkdown(hover.Contents)},
+			Range:    hover.Range,
+		}, nil
+	}
+
+	var contents []lsp.MarkedString
+	for _, ms := range hover.Contents {
+		if ms.Language == docLanguage {
+			contents = maybeAddComments(ms.Value, contents)
+		} else {
+			contents = append(contents, ms)
+		}
+	}
+	hover.Contents = contents
+	return hover, nil
+}
+
+// supportsMarkdownHover reports whether the client renders hover contents
+// as Markdown.
+func (h *LangHandler) supportsMarkdownHover() bool {
+	for _, kind := range h.init.ClientCapabilities.TextDocument.Hover.ContentFormat {
+		if kind == protocol.Markdown {
+			return true
+		}
+	}
+	return false
+}
+
+// docLanguage marks the doc comments in the contents of a hover, which are
+// converted according to the content format of the client.
+const docLanguage = "godoc"
+
+// addDoc appends the doc comment to contents, if it is not empty.
+func addDoc(comments string, contents []lsp.MarkedString) []lsp.MarkedString {
+	if comments == "" {
+		return contents
+	}
+	return append(contents, lsp.MarkedString{Language: docLanguage, Value: comments})
+}
+
+// contentsToMarkdown renders the contents of a hover as a single Markdown
+// document, with code in fenced blocks.
+func contentsToMarkdown(contents []lsp.MarkedString) string {
+	var parts []string
+	for _, ms := range contents {
+		switch ms.Language {
+		case docLanguage:
+			parts = append(parts, markdown.FromDoc(ms.Value))
+		case "":
+			parts = append(parts, ms.Value)
+		default:
+			parts = append(parts, "```"+ms.Language+"\n"+ms.Value+"\n```")
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (h *LangHandler) hover(ctx context.Context, params lsp.TextDocumentPositionParams) (*lsp.Hover, error) {
 	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
 	if err != nil {
 		// Invalid nodes means we tried to click on something which is
@@ -44,17 +116,30 @@ func (h *LangHandler) handleHover(ctx context.Context, conn jsonrpc2.JSONRPC2, r
 		return nil, err
 	}
 
+	hover, err := h.hoverNode(pkg, pathNodes, params.Position)
+	if hover == nil || hover.Range == nil {
+		return hover, err
+	}
+	// The range is in the hovered file, with byte offsets as characters.
+	if content, err := h.project.FileContent(ctx, params.TextDocument.URI); err == nil {
+		r := toUTF16Range(content, *hover.Range)
+		hover.Range = &r
+	}
+	return hover, err
+}
+
+func (h *LangHandler) hoverNode(pkg source.Package, pathNodes []ast.Node, position lsp.Position) (*lsp.Hover, error) {
 	switch node := pathNodes[0].(type) {
 	case *ast.Ident:
-		return h.hoverIdent(pkg, pathNodes, node, params.Position)
+		return h.hoverIdent(pkg, pathNodes, node, position)
 	case *ast.BasicLit:
-		return h.hoverBasicLit(pkg, pathNodes, node, params.Position)
+		return h.hoverBasicLit(pkg, pathNodes, node, position)
 	case *ast.TypeSpec:
-		return h.hoverIdent(pkg, pathNodes, node.Name, params.Position)
+		return h.hoverIdent(pkg, pathNodes, node.Name, position)
 	case *ast.CallExpr:
-		return h.hoverCallExpr(pkg, pathNodes, node, params.Position)
+		return h.hoverCallExpr(pkg, pathNodes, node, position)
 	case *ast.SelectorExpr:
-		return h.hoverIdent(pkg, pathNodes, node.Sel, params.Position)
+		return h.hoverIdent(pkg, pathNodes, node.Sel, position)
 	}
 
 	return nil, nil
@@ -82,7 +167,7 @@ func (h *LangHandler) hoverBasicLit(pkg source.Package, nodes []ast.Node, basicL
 		comments := source.PackageDoc(importPkg.GetSyntax(), importPkg.GetName())
 		r := rangeForNode(pkg.GetFileSet(), node)
 		return &lsp.Hover{
-			Contents: maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: "package " + importPkg.GetName()}}),
+			Contents: addDoc(comments, []lsp.MarkedString{{Language: "go", Value: "package " + importPkg.GetName()}}),
 			Range:    &r,
 		}, nil
 	}
@@ -96,24 +181,18 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 
 	if o == nil && t == nil {
 		if ident.Obj != nil {
-			contents := maybeAddComments("", []lsp.MarkedString{{Language: "go", Value: ident.String()}})
+			contents := addDoc("", []lsp.MarkedString{{Language: "go", Value: ident.String()}})
 			r := rangeForNode(pkg.GetFileSet(), ident)
 			return &lsp.Hover{Contents: contents, Range: &r}, nil
 		}
 		return h.packageStatement(pkg, ident, position)
 	}
 
-	isBuiltIn, builtInObject := o != nil && !o.Pos().IsValid(), o
-	if isBuiltIn {
+	if o != nil && !o.Pos().IsValid() {
 		// Only builtins have invalid position, and don't have useful info.
-		pkg = h.project.GetBuiltinPackage()
-		if pkg == nil {
-			return nil, nil
-		}
-		o = source.FindObject(pkg, o)
-		if o == nil {
-			return nil, nil
-		}
+		contents := h.builtinHover(o)
+		r := rangeForNode(pkg.GetFileSet(), ident)
+		return &lsp.Hover{Contents: contents, Range: &r}, nil
 	}
 	// Don't package-qualify the string output.
 	qf := func(*types.Package) string { return "" }
@@ -125,23 +204,10 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 		s = "struct " + o.String()
 	} else if o != nil {
 		if obj, ok := o.(*types.TypeName); ok {
-			typ := obj.Type().Underlying()
-			if _, ok := typ.(*types.Struct); ok {
-				s = "type " + obj.Name() + " struct"
-				if !isBuiltIn {
-					extra = prettyPrintTypesString(types.TypeString(typ, qf))
-				} else {
-					extra = prettyPrintTypesString(builtInObject.String())
-				}
-			}
-			if _, ok := typ.(*types.Interface); ok {
-				s = "type " + obj.Name() + " interface"
-				extra = prettyPrintTypesString(types.TypeString(typ, qf))
-				if !isBuiltIn {
-					extra = prettyPrintTypesString(types.TypeString(typ, qf))
-				} else {
-					extra = prettyPrintTypesString(builtInObject.String())
-				}
+			s = typeNameString(obj, qf)
+			switch obj.Type().Underlying().(type) {
+			case *types.Struct, *types.Interface:
+				extra = typeDetails(obj, qf)
 			}
 		} else if _, ok := o.(*types.PkgName); ok {
 			s = types.ObjectString(o, qf)
@@ -156,21 +222,363 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 		s = types.TypeString(t, qf)
 	}
 
-	comments, err := source.FindComments(pkg, pkg.GetFileSet(), o, ident.Name)
-	if err != nil {
-		return nil, err
+	if c, ok := o.(*types.Const); ok {
+		s = constString(c, qf)
+	}
+
+	var comments string
+	if pkgName, ok := o.(*types.PkgName); ok {
+		comments = h.packageSynopsis(pkg, pkgName)
+	} else {
+		var err error
+		comments, err = source.FindComments(pkg, pkg.GetFileSet(), o, ident.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	contents := []lsp.MarkedString{{Language: "go", Value: s}}
+	if notice := deprecationNotice(comments); notice != "" {
+		contents = append(contents, lsp.RawMarkedString("**Deprecated:** "+notice))
 	}
-	contents := maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: s}})
+	contents = addDoc(comments, contents)
 	if extra != "" {
 		// If we have extra info, ensure it comes after the usually
 		// more useful documentation
 		contents = append(contents, lsp.MarkedString{Language: "go", Value: extra})
 	}
+	if h.getConfig().HoverShowStructInfo {
+		if info := structInfo(h.project.Sizes(), pkg, pathNodes, o); info != "" {
+			contents = append(contents, lsp.MarkedString{Language: "go", Value: info})
+		}
+	}
 
-	r := rangeForNode(pkg.GetFileSet(), ident)
+	r := rangeForNode(pkg.GetFileSet(), hoverRangeNode(pkg, pathNodes, ident))
 	return &lsp.Hover{Contents: contents, Range: &r}, nil
 }
 
+// hoverRangeNode returns the node the hover of ident covers: the whole
+// selector expression if ident selects a field, otherwise ident itself.
+func hoverRangeNode(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) ast.Node {
+	for _, n := range pathNodes {
+		if call, ok := n.(*ast.CallExpr); ok {
+			n = call.Fun
+		}
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || sel.Sel != ident {
+			continue
+		}
+		if s, ok := pkg.GetTypesInfo().Selections[sel]; ok && s.Kind() == types.FieldVal {
+			return sel
+		}
+		break
+	}
+	return ident
+}
+
+// builtinHover returns the hover contents of the builtin object o, using
+// its declaration and doc comment in builtin.go. The predeclared
+// constants are shown with their untyped types, since their declarations
+// in builtin.go only serve documentation.
+func (h *LangHandler) builtinHover(o types.Object) []lsp.MarkedString {
+	qf := func(*types.Package) string { return "" }
+	s := types.ObjectString(o, qf)
+
+	var doc *ast.CommentGroup
+	if o.Pkg() == nil {
+		if _, decl, gen := h.builtin.lookupDecl(o.Name()); decl != nil {
+			fset := h.builtin.fset
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				cpy := *decl
+				cpy.Doc, cpy.Body = nil, nil
+				s, doc = fmtNode(fset, &cpy), decl.Doc
+			case *ast.TypeSpec:
+				cpy := *decl
+				cpy.Doc, cpy.Comment = nil, nil
+				s, doc = "type "+fmtNode(fset, &cpy), decl.Doc
+			case *ast.ValueSpec:
+				if _, ok := o.(*types.Const); !ok && decl.Type != nil {
+					s = gen.Tok.String() + " " + o.Name() + " " + fmtNode(fset, decl.Type)
+				}
+				doc = decl.Doc
+			}
+			if doc == nil && gen != nil {
+				doc = gen.Doc
+			}
+		}
+	}
+
+	contents := []lsp.MarkedString{{Language: "go", Value: s}}
+	if doc != nil {
+		contents = addDoc(doc.Text(), contents)
+	}
+	return contents
+}
+
+// structInfo returns the size of the struct type obj and the offsets of
+// its fields, or the offset, size and trailing padding of the field obj.
+func structInfo(sizes types.Sizes, pkg source.Package, pathNodes []ast.Node, obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok {
+			return ""
+		}
+		lines := []string{fmt.Sprintf("// size %d, align %d", sizes.Sizeof(st), sizes.Alignof(st))}
+		for i := 0; i < st.NumFields(); i++ {
+			lines = append(lines, fmt.Sprintf("// %s: %s", st.Field(i).Name(), fieldLayout(sizes, st, i)))
+		}
+		return strings.Join(lines, "\n")
+
+	case *types.Var:
+		if !obj.IsField() {
+			return ""
+		}
+		st := fieldStruct(pkg, pathNodes, obj)
+		if st == nil {
+			return ""
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i) == obj {
+				return "// " + fieldLayout(sizes, st, i)
+			}
+		}
+	}
+	return ""
+}
+
+// fieldLayout describes the offset and size of the i-th field of st and
+// the padding which follows it.
+func fieldLayout(sizes types.Sizes, st *types.Struct, i int) string {
+	fields := make([]*types.Var, st.NumFields())
+	for j := range fields {
+		fields[j] = st.Field(j)
+	}
+	offsets := sizes.Offsetsof(fields)
+
+	size := sizes.Sizeof(fields[i].Type())
+	next := sizes.Sizeof(st)
+	if i+1 < len(fields) {
+		next = offsets[i+1]
+	}
+
+	s := fmt.Sprintf("offset %d, size %d", offsets[i], size)
+	if padding := next - offsets[i] - size; padding > 0 {
+		s += fmt.Sprintf(", padding %d", padding)
+	}
+	return s
+}
+
+// fieldStruct returns the struct type declaring field, as found from the
+// struct type, selector or composite literal enclosing the field.
+func fieldStruct(pkg source.Package, pathNodes []ast.Node, field *types.Var) *types.Struct {
+	info := pkg.GetTypesInfo()
+	declares := func(typ types.Type) *types.Struct {
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i) == field {
+				return st
+			}
+		}
+		return nil
+	}
+
+	for _, n := range pathNodes {
+		switch n := n.(type) {
+		case *ast.StructType:
+			if typ := info.TypeOf(n); typ != nil {
+				return declares(typ)
+			}
+		case *ast.CompositeLit:
+			if typ := info.TypeOf(n); typ != nil {
+				return declares(typ)
+			}
+		case *ast.SelectorExpr:
+			sel, ok := info.Selections[n]
+			if !ok {
+				continue
+			}
+			// Walk the embedded fields the field is promoted through.
+			typ := sel.Recv()
+			for _, index := range sel.Index()[:len(sel.Index())-1] {
+				if ptr, ok := typ.(*types.Pointer); ok {
+					typ = ptr.Elem()
+				}
+				st, ok := typ.Underlying().(*types.Struct)
+				if !ok {
+					return nil
+				}
+				typ = st.Field(index).Type()
+			}
+			return declares(typ)
+		}
+	}
+	return nil
+}
+
+// deprecationNotice returns the text of the paragraph of the doc comment
+// which starts with "Deprecated: ", the godoc convention for deprecated
+// identifiers, or "" if there is none.
+func deprecationNotice(comments string) string {
+	const prefix = "Deprecated: "
+	for _, para := range strings.Split(comments, "\n\n") {
+		para = strings.TrimSpace(para)
+		if strings.HasPrefix(para, prefix) {
+			return strings.Join(strings.Fields(para[len(prefix):]), " ")
+		}
+	}
+	return ""
+}
+
+// typeNameString formats the declaration of the type obj with its
+// underlying type, or the aliased type for aliases. Struct and interface
+// types are summarized, since typeDetails expands them. Aliased types of
+// other packages are qualified with the package name.
+func typeNameString(obj *types.TypeName, qf types.Qualifier) string {
+	if obj.IsAlias() {
+		aliasQf := func(p *types.Package) string {
+			if p == obj.Pkg() {
+				return ""
+			}
+			return p.Name()
+		}
+		return "type " + obj.Name() + " = " + summarizedTypeString(aliasedType(obj), aliasQf)
+	}
+
+	switch obj.Type().Underlying().(type) {
+	case *types.Struct:
+		return "type " + obj.Name() + " struct"
+	case *types.Interface:
+		return "type " + obj.Name() + " interface"
+	}
+	return "type " + obj.Name() + " " + summarizedTypeString(obj.Type().Underlying(), qf)
+}
+
+// aliasedType returns the type the alias obj stands for. Newer versions of
+// go/types give aliases a type of their own, which refers to the aliased
+// type as its right-hand side.
+func aliasedType(obj *types.TypeName) types.Type {
+	if alias, ok := obj.Type().(interface{ Rhs() types.Type }); ok {
+		return alias.Rhs()
+	}
+	return obj.Type()
+}
+
+// summarizedTypeString is like types.TypeString, but prints non-empty
+// struct and interface literals as struct{...} and interface{...}.
+func summarizedTypeString(typ types.Type, qf types.Qualifier) string {
+	switch typ := typ.(type) {
+	case *types.Struct:
+		if typ.NumFields() > 0 {
+			return "struct{...}"
+		}
+	case *types.Interface:
+		if typ.NumMethods() > 0 {
+			return "interface{...}"
+		}
+	}
+	return types.TypeString(typ, qf)
+}
+
+// maxTypeDetailsLines is the number of lines after which the expansion of
+// a type on hover is cut off.
+const maxTypeDetailsLines = 30
+
+// typeDetails expands the struct or interface type of obj. Structs are
+// followed by their method set, exported methods first, and interfaces
+// list all of their methods, including the embedded ones.
+func typeDetails(obj *types.TypeName, qf types.Qualifier) string {
+	var lines []string
+	switch typ := obj.Type().Underlying().(type) {
+	case *types.Struct:
+		if s := prettyPrintTypesString(types.TypeString(typ, qf)); s != "" {
+			lines = append(lines, strings.Split(s, "\n")...)
+		}
+
+		methods := typeutil.IntuitiveMethodSet(obj.Type(), nil)
+		sort.SliceStable(methods, func(i, j int) bool {
+			return methods[i].Obj().Exported() && !methods[j].Obj().Exported()
+		})
+		for i, sel := range methods {
+			if i == 0 && len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, types.ObjectString(sel.Obj(), qf))
+		}
+
+	case *types.Interface:
+		if typ.NumMethods() == 0 {
+			return ""
+		}
+		lines = append(lines, "interface {")
+		for i := 0; i < typ.NumMethods(); i++ {
+			m := typ.Method(i)
+			lines = append(lines, "    "+m.Name()+strings.TrimPrefix(types.TypeString(m.Type(), qf), "func"))
+		}
+		lines = append(lines, "}")
+	}
+
+	if len(lines) > maxTypeDetailsLines {
+		more := len(lines) - maxTypeDetailsLines
+		lines = append(lines[:maxTypeDetailsLines], fmt.Sprintf("// … %d more", more))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxConstStringLen is the number of runes after which the value of a
+// string constant is truncated.
+const maxConstStringLen = 80
+
+// constString formats the declaration of c along with its value, e.g.
+// "const size = 4096" or "const Monday Weekday = 1". The type is left out
+// for untyped constants.
+func constString(c *types.Const, qf types.Qualifier) string {
+	s := "const " + c.Name()
+	typ, ok := c.Type().(*types.Basic)
+	if !ok || typ.Info()&types.IsUntyped == 0 {
+		s += " " + types.TypeString(c.Type(), qf)
+	}
+	return s + " = " + constValueString(c.Val(), typ)
+}
+
+func constValueString(val constant.Value, typ *types.Basic) string {
+	switch val.Kind() {
+	case constant.String:
+		str := constant.StringVal(val)
+		if runes := []rune(str); len(runes) > maxConstStringLen {
+			str = string(runes[:maxConstStringLen]) + "…"
+		}
+		return strconv.Quote(str)
+	case constant.Int:
+		if typ != nil && typ.Kind() == types.UntypedRune {
+			if r, ok := constant.Int64Val(val); ok && utf8.ValidRune(rune(r)) {
+				return strconv.QuoteRune(rune(r))
+			}
+		}
+	}
+	return val.String()
+}
+
+// packageSynopsis returns the synopsis of the doc comment of the package
+// imported by pkgName.
+func (h *LangHandler) packageSynopsis(pkg source.Package, pkgName *types.PkgName) string {
+	importPath := pkgName.Imported().Path()
+	importPkg, _ := h.getFindPackageFunc()(h.project, importPath)
+	if importPkg == nil {
+		importPkg = pkg.GetImport(importPath)
+	}
+	if importPkg == nil || len(importPkg.GetFilenames()) == 0 {
+		return ""
+	}
+	return h.project.PackageSynopsis(filepath.Dir(importPkg.GetFilenames()[0]))
+}
+
 func (h *LangHandler) packageStatement(pkg source.Package, ident *ast.Ident, position lsp.Position) (*lsp.Hover, error) {
 	comments := source.PackageDoc(pkg.GetSyntax(), ident.Name)
 
@@ -178,7 +586,7 @@ func (h *LangHandler) packageStatement(pkg source.Package, ident *ast.Ident, pos
 	r := rangeForNode(pkg.GetFileSet(), ident)
 	if pkgName := packageStatementName(pkg.GetFileSet(), pkg.GetSyntax(), ident); pkgName != "" {
 		return &lsp.Hover{
-			Contents: maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: "package " + pkgName}}),
+			Contents: addDoc(comments, []lsp.MarkedString{{Language: "go", Value: "package " + pkgName}}),
 			Range:    &r,
 		}, nil
 	}
@@ -202,9 +610,7 @@ func maybeAddComments(comments string, contents []lsp.MarkedString) []lsp.Marked
 	if comments == "" {
 		return contents
 	}
-	var b bytes.Buffer
-	doc.ToMarkdown(&b, comments, nil)
-	return append(contents, lsp.RawMarkedString(b.String()))
+	return append(contents, lsp.RawMarkedString(markdown.FromDoc(comments)))
 }
 
 // commentsToText converts a slice of []*ast.CommentGroup to a flat string,
@@ -31,12 +31,42 @@ func (h *LangHandler) handleTextDocumentImplementation(ctx context.Context, conn
 	pathNodes, _ := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
 	pathNodes, action := findInterestingNode(pkg, pathNodes)
 
-	return implements(h.project, pkg, pathNodes, action)
+	locs, err := implements(pkg, pathNodes, action, h.project.Cache().ImplementationCandidates)
+	if err != nil {
+		return nil, err
+	}
+	toUTF16 := h.utf16Ranges(ctx)
+	for _, loc := range locs {
+		loc.Location = utf16Location(toUTF16, loc.Location)
+	}
+	return locs, nil
 }
 
+// allNamedTypes returns all the named types of the cached packages, even
+// local types (which can have methods due to promotion). We ignore aliases
+// 'type M = N' to avoid duplicate reporting of the Named type N.
+func allNamedTypes(project *cache.Project) []*types.Named {
+	var allNamed []*types.Named
+	_ = project.Search(func(p source.Package) error {
+		for _, obj := range p.GetTypesInfo().Defs {
+			if obj, ok := obj.(*types.TypeName); ok && !isAlias(obj) {
+				if named, ok := obj.Type().(*types.Named); ok {
+					allNamed = append(allNamed, named)
+				}
+			}
+		}
+		return nil
+	})
+	return allNamed
+}
+
+// implements returns the implementation relations of the type, method or
+// value at path with the named types returned by candidates, which must
+// include all the named types related to its type.
+//
 // Adapted from golang.org/x/tools/cmd/guru (Copyright (c) 2013 The Go Authors). All rights
 // reserved. See NOTICE for full license.
-func implements(project *cache.Project, pkg source.Package, path []ast.Node, action action) ([]*lspext.ImplementationLocation, error) {
+func implements(pkg source.Package, path []ast.Node, action action, candidates func(T types.Type) []*types.Named) ([]*lspext.ImplementationLocation, error) {
 	var method *types.Func
 	var T types.Type // selected type (receiver if method != nil)
 
@@ -66,30 +96,8 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 		return nil, errors.New("not a type, method, or value")
 	}
 
-	// Find all named types, even local types (which can have
-	// methods due to promotion) and the built-in "error".
-	// We ignore aliases 'type M = N' to avoid duplicate
-	// reporting of the Named type N.
-	var allNamed []*types.Named
-
-	f := func(p source.Package) error {
-		for _, obj := range p.GetTypesInfo().Defs {
-			if obj, ok := obj.(*types.TypeName); ok && !isAlias(obj) {
-				if named, ok := obj.Type().(*types.Named); ok {
-					allNamed = append(allNamed, named)
-				}
-			}
-		}
-
-		return nil
-	}
-
-	err := project.Search(f)
-	if err != nil {
-		return nil, err
-	}
-
-	allNamed = append(allNamed, types.Universe.Lookup("error").Type().(*types.Named))
+	// The built-in "error" is not in any package.
+	allNamed := append(candidates(T), types.Universe.Lookup("error").Type().(*types.Named))
 
 	var msets typeutil.MethodSetCache
 
@@ -158,6 +166,9 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 				return nil // method not found
 			}
 			obj = tm.Obj()
+			if obj == method {
+				return nil // method itself, embedded in another interface
+			}
 			if _, seen := seen[obj]; seen {
 				return nil // already saw this method, via other embedding path
 			}
@@ -1,6 +1,11 @@
 package langserver
 
-import lsp "github.com/sourcegraph/go-lsp"
+import (
+	"encoding/json"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	lsp "github.com/sourcegraph/go-lsp"
+)
 
 // This file contains Go-specific extensions to LSP types.
 //
@@ -19,6 +24,15 @@ type InitializationOptions struct {
 	// Defaults to false if not specified.
 	DiagnosticsStyle *string `json:"diagnosticsStyle"`
 
+	// VetOnSave is an optional version of Config.VetOnSave
+	VetOnSave *bool `json:"vetOnSave"`
+
+	// VetAnalyzers is an optional version of Config.VetAnalyzers
+	VetAnalyzers []string `json:"vetAnalyzers"`
+
+	// Analyses is an optional version of Config.Analyses
+	Analyses map[string]bool `json:"analyses"`
+
 	// EnableGlobalCache enable global cache when hover, reference, definition. Can be overridden by InitializationOptions.
 	//
 	// Defaults to false if not specified
@@ -26,9 +40,13 @@ type InitializationOptions struct {
 
 	// FormatStyle format style
 	//
-	// Defaults to "gofmt" if not specified
+	// Deprecated: use FormatTool.
 	FormatStyle *string `json:"formatStyle"`
 
+	// FormatTool is an optional version of Config.FormatStyle: "gofmt" or
+	// "goimports". It overrides FormatStyle.
+	FormatTool *string `json:"formatTool"`
+
 	// Enhance sigature help
 	//
 	// Defaults to false if not specified
@@ -38,11 +56,58 @@ type InitializationOptions struct {
 	// Config.GoimportsLocalPrefix
 	GoimportsLocalPrefix *string `json:"goimportsLocalPrefix"`
 
+	// Local is an optional version of Config.GoimportsLocalPrefix as a
+	// list of prefixes. It overrides GoimportsLocalPrefix.
+	Local []string `json:"local"`
+
+	// OrganizeImportsOnSave is an optional version of
+	// Config.OrganizeImportsOnSave
+	OrganizeImportsOnSave *bool `json:"organizeImportsOnSave"`
+
+	// CodeLensReferences is an optional version of
+	// Config.CodeLensReferences
+	CodeLensReferences *bool `json:"codeLensReferences"`
+
+	// StructTagCase is an optional version of Config.StructTagCase
+	StructTagCase *string `json:"structTagCase"`
+
+	// DocumentLinkTarget is an optional version of Config.DocumentLinkTarget
+	DocumentLinkTarget *string `json:"documentLinkTarget"`
+
 	// MaxParallelism is an optional version of Config.MaxParallelism
 	MaxParallelism *int `json:"maxParallelism"`
 
 	// BuildTags is an optional version of Config.BuildTags
 	BuildTags []string `json:"buildTags"`
+
+	// Env is an optional version of Config.Env
+	Env map[string]string `json:"env"`
+
+	// FollowLineDirectives is an optional version of Config.FollowLineDirectives
+	FollowLineDirectives *bool `json:"followLineDirectives"`
+
+	// HoverShowStructInfo is an optional version of Config.HoverShowStructInfo
+	HoverShowStructInfo *bool `json:"hoverShowStructInfo"`
+
+	// InterfaceReferences is an optional version of Config.InterfaceReferences
+	InterfaceReferences *bool `json:"interfaceReferences"`
+
+	// CallHierarchyInterfaceCalls is an optional version of
+	// Config.CallHierarchyInterfaceCalls
+	CallHierarchyInterfaceCalls *bool `json:"callHierarchyInterfaceCalls"`
+
+	// MaxReferences is an optional version of Config.MaxReferences
+	MaxReferences *int `json:"maxReferences"`
+
+	// MaxWorkspaceSymbols is an optional version of
+	// Config.MaxWorkspaceSymbols
+	MaxWorkspaceSymbols *int `json:"maxWorkspaceSymbols"`
+
+	// WatchFiles is an optional version of Config.WatchFiles
+	WatchFiles *bool `json:"watchFiles"`
+
+	// MaxCacheMemoryMB is an optional version of Config.MaxCacheMemoryMB
+	MaxCacheMemoryMB *int `json:"maxCacheMemoryMB"`
 }
 
 type InitializeParams struct {
@@ -50,10 +115,37 @@ type InitializeParams struct {
 
 	InitializationOptions *InitializationOptions `json:"initializationOptions,omitempty"`
 
+	// WorkDoneProgressParams holds the token the client may provide to
+	// report the progress of the initialization on.
+	protocol.WorkDoneProgressParams
+
 	// TODO these should be InitializationOptions
 	// RootImportPath is the root Go import path for this
 	// workspace. For example,
 	// "golang.org/x/tools" is the root import
 	// path for "github.com/golang/tools".
 	RootImportPath string
+
+	// ClientCapabilities are the capabilities missing from
+	// lsp.ClientCapabilities. They are decoded from the same
+	// "capabilities" field.
+	ClientCapabilities protocol.ClientCapabilities `json:"-"`
+}
+
+// UnmarshalJSON decodes the initialize params, including the client
+// capabilities lsp.ClientCapabilities does not know about.
+func (p *InitializeParams) UnmarshalJSON(data []byte) error {
+	type params InitializeParams
+	if err := json.Unmarshal(data, (*params)(p)); err != nil {
+		return err
+	}
+
+	var extra struct {
+		Capabilities protocol.ClientCapabilities `json:"capabilities"`
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+	p.ClientCapabilities = extra.Capabilities
+	return nil
 }
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bufio"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AsmSymbol is a function defined by a TEXT directive in an assembly file.
+type AsmSymbol struct {
+	// Pkg is the package qualifier of the symbol, e.g. "runtime" in
+	// "runtime·memmove". It is empty for the usual "·memmove".
+	Pkg  string
+	Name string
+
+	Filename string
+	Line     int // 1-based
+	Column   int // 1-based byte offset of Name
+}
+
+type asmEntry struct {
+	modTimes map[string]time.Time
+	symbols  []AsmSymbol
+}
+
+// asmTextRe matches the TEXT directive of an assembly function, e.g.
+// "TEXT ·add(SB),NOSPLIT,$0-24" or "TEXT runtime·memmove<ABIInternal>(SB)".
+var asmTextRe = regexp.MustCompile(`^\s*TEXT\s+([^\s(·]*)·([^\s(<·]+)(<[^>]*>)?\(SB\)`)
+
+// AsmSymbols returns the functions defined by the assembly files of dir
+// which match the build context of the project.
+func (p *Project) AsmSymbols(dir string) []AsmSymbol {
+	return p.getView().asmSymbols(dir)
+}
+
+func (v *View) asmSymbols(dir string) []AsmSymbol {
+	files := v.asmFiles(dir)
+
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	if e, ok := v.pcache.asm[dir]; ok && sameModTimes(e.modTimes, files) {
+		return e.symbols
+	}
+
+	e := &asmEntry{modTimes: files}
+	var names []string
+	for filename := range files {
+		names = append(names, filename)
+	}
+	sort.Strings(names)
+	for _, filename := range names {
+		e.symbols = append(e.symbols, scanAsmFile(filename)...)
+	}
+
+	v.pcache.asm[dir] = e
+	return e.symbols
+}
+
+// asmFiles returns the assembly files of dir which are part of the build,
+// along with their modification times.
+func (v *View) asmFiles(dir string) map[string]time.Time {
+	return v.buildFiles(dir, ".s")
+}
+
+// buildFiles returns the files of dir with the extension ext which are part
+// of the build, along with their modification times.
+func (v *View) buildFiles(dir, ext string) map[string]time.Time {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	ctxt := v.buildContext()
+	files := make(map[string]time.Time)
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ext {
+			continue
+		}
+		if match, err := ctxt.MatchFile(dir, info.Name()); err != nil || !match {
+			continue
+		}
+		files[filepath.Join(dir, info.Name())] = info.ModTime()
+	}
+	return files
+}
+
+// buildContext returns the build context of the view, which honors the
+// build tags passed in the build flags.
+func (v *View) buildContext() build.Context {
+	ctxt := build.Default
+	flags := v.Config.BuildFlags
+	for i, flag := range flags {
+		if (flag == "-tags" || flag == "--tags") && i+1 < len(flags) {
+			ctxt.BuildTags = strings.Fields(flags[i+1])
+		} else if strings.HasPrefix(flag, "-tags=") {
+			ctxt.BuildTags = strings.Fields(strings.TrimPrefix(flag, "-tags="))
+		}
+	}
+	return ctxt
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for filename, modTime := range a {
+		if !b[filename].Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanAsmFile returns the functions defined by the TEXT directives in
+// filename.
+func scanAsmFile(filename string) []AsmSymbol {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var symbols []AsmSymbol
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		m := asmTextRe.FindStringSubmatchIndex(scanner.Text())
+		if m == nil {
+			continue
+		}
+		text := scanner.Text()
+		symbols = append(symbols, AsmSymbol{
+			// The assembler writes "/" in package paths as "∕".
+			Pkg:      strings.Replace(text[m[2]:m[3]], "∕", "/", -1),
+			Name:     text[m[4]:m[5]],
+			Filename: filename,
+			Line:     line,
+			Column:   m[4] + 1,
+		})
+	}
+	return symbols
+}
@@ -1,8 +1,10 @@
 package cache
 
 import (
+	"go/types"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -24,6 +26,13 @@ const (
 type GlobalPackage struct {
 	pkg     *Package
 	modTime time.Time
+
+	// used is the tick of the clock of the cache the package was last
+	// looked up at.
+	used uint64
+
+	// evicted is set once the syntax of the package was evicted.
+	evicted bool
 }
 
 func (p *GlobalPackage) Package() *Package {
@@ -43,6 +52,7 @@ func (p *GlobalPackage) ModTime() time.Time {
 type id2Package map[string]*GlobalPackage
 type file2Package map[string]*GlobalPackage
 type path2Package map[string]*GlobalPackage
+type dir2Package map[string][]*GlobalPackage
 
 func getPackageModTime(pkg *Package) time.Time {
 	if pkg == nil || len(pkg.files) == 0 {
@@ -58,12 +68,72 @@ func getPackageModTime(pkg *Package) time.Time {
 	return fi.ModTime()
 }
 
+// packageDir returns the directory holding the files of a package.
+func packageDir(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	return dirKey(filepath.Dir(files[0]))
+}
+
+// dirKey returns the form of the directory dir the cache indexes packages
+// by, with forward slashes and a lower case drive letter, so that the
+// directories of the loaded files and those go.mod resolves import paths
+// to compare equal on Windows as well.
+func dirKey(dir string) string {
+	return util.LowerDriver(filepath.ToSlash(dir))
+}
+
+// cacheKey identifies a package in the cache. The package ID alone is not
+// enough: with go.mod replace directives the same ID may be loaded from a
+// local replacement in one module and from the module cache in another.
+func cacheKey(id string, files []string) string {
+	dir := packageDir(files)
+	if dir == "" {
+		return id
+	}
+	return id + "@" + dir
+}
+
 // PackageCache package cache
 type GlobalCache struct {
 	mu      sync.RWMutex
 	idMap   id2Package
 	pathMap path2Package
+	dirMap  dir2Package
 	fileMap file2Package
+
+	// methods indexes the named types of the cached packages by the
+	// methods of their method sets.
+	methods *methodIndex
+
+	// symbols indexes the symbols of the cached packages.
+	symbols *symbolIndex
+
+	// versions tracks the changes of the content of the open files. The
+	// packages type checked before the content of the files they depend
+	// on changed are stale: they are not returned by the lookups, and are
+	// replaced when they are loaded again.
+	versions *overlayVersions
+
+	// clock ticks at each lookup of a package, to evict the least recently
+	// used packages first.
+	clock uint64
+
+	// memory is the estimated memory of the packages the cache holds, and
+	// maxMemory the budget the packages are evicted beyond, 0 for no
+	// budget. See evict.
+	memory, maxMemory int64
+
+	// refs counts the references of the cache to each package it holds:
+	// from its entries and from the imports of the packages it holds, and
+	// typesRefs those to their types, which the copies of the packages
+	// whose syntax was evicted share. See hold.
+	refs      map[*Package]int
+	typesRefs map[*types.Package]int
+
+	// syntaxEvictions and packageEvictions count the evictions.
+	syntaxEvictions, packageEvictions int
 }
 
 // debugCache trace package cache
@@ -71,7 +141,7 @@ var debugCache = false
 
 // NewCache new a package cache
 func NewCache() *GlobalCache {
-	return &GlobalCache{idMap: id2Package{}, pathMap: path2Package{}, fileMap: file2Package{}}
+	return &GlobalCache{idMap: id2Package{}, pathMap: path2Package{}, dirMap: dir2Package{}, fileMap: file2Package{}, methods: newMethodIndex(), symbols: newSymbolIndex(), refs: map[*Package]int{}, typesRefs: map[*types.Package]int{}}
 }
 
 func (c *GlobalCache) put(pkg *Package) {
@@ -83,48 +153,102 @@ func (c *GlobalCache) put(pkg *Package) {
 		log.Printf("cache %s = %p\n", pkg.id, pkg)
 	}
 
-	c.delete(pkg.id)
+	key := cacheKey(pkg.id, pkg.files)
+	c.delete(key)
 	p := &GlobalPackage{pkg: pkg, modTime: getPackageModTime(pkg)}
-	c.idMap[pkg.id] = p
-	c.pathMap[pkg.pkgPath] = p
+	c.hold(pkg)
+	c.touch(p)
+	c.idMap[key] = p
+	c.methods.add(key, pkg)
+	c.symbols.add(key, pkg)
+
+	// Test variants share the package path and the non-test files of the
+	// plain package, which is preferred for those. Test files only belong
+	// to test variants.
+	testVariant := isTestVariant(pkg.id, pkg.pkgPath)
+	if old := c.pathMap[pkg.pkgPath]; old == nil || !testVariant || isTestVariant(old.pkg.id, old.pkg.pkgPath) {
+		c.pathMap[pkg.pkgPath] = p
+	}
+
+	// The test variants and the external test package share the directory
+	// of the plain package, so all of them are indexed by it.
+	if dir := packageDir(pkg.files); dir != "" {
+		c.dirMap[dir] = append(c.dirMap[dir], p)
+	}
 
 	for _, file := range pkg.files {
-		c.fileMap[util.LowerDriver(file)] = p
+		key := util.LowerDriver(file)
+		if old := c.fileMap[key]; old != nil && testVariant && !isTestVariant(old.pkg.id, old.pkg.pkgPath) {
+			continue
+		}
+		c.fileMap[key] = p
+	}
+
+	// Also index files importing "C" by their own name rather than only
+	// by the name of the cgo output compiled in their place.
+	for _, file := range pkg.syntax {
+		if pkg.fset == nil || !file.Package.IsValid() {
+			continue
+		}
+		key := util.LowerDriver(source.OriginalFilename(pkg.fset, file))
+		if _, ok := c.fileMap[key]; !ok {
+			c.fileMap[key] = p
+		}
 	}
 }
 
-func (c *GlobalCache) get(id string) *Package {
+func (c *GlobalCache) get(key string) *Package {
 	if c == nil {
 		return nil
 	}
 
-	pkg := c.idMap[id]
+	pkg := c.idMap[key]
 
 	if debugCache {
-		log.Printf("get %s = %p\n", id, pkg)
+		log.Printf("get %s = %p\n", key, pkg)
 	}
 	return pkg.Package()
 }
 
-func (c *GlobalCache) delete(id string) {
+func (c *GlobalCache) delete(key string) {
 	if c == nil {
 		return
 	}
 
 	if debugCache {
-		log.Printf("delete %s %p\n", id, c.idMap[id])
+		log.Printf("delete %s %p\n", key, c.idMap[key])
 	}
 
-	p := c.idMap[id]
+	p := c.idMap[key]
 	if p == nil {
 		return
 	}
 
-	delete(c.idMap, id)
-	delete(c.pathMap, p.pkg.pkgPath)
+	delete(c.idMap, key)
+	c.release(p.pkg)
+	c.methods.remove(key)
+	c.symbols.remove(key)
+	if c.pathMap[p.pkg.pkgPath] == p {
+		delete(c.pathMap, p.pkg.pkgPath)
+	}
+
+	c.dirMap.replace(packageDir(p.pkg.files), p, nil)
 
 	for _, file := range p.pkg.files {
-		delete(c.fileMap, util.LowerDriver(file))
+		key := util.LowerDriver(file)
+		if c.fileMap[key] == p {
+			delete(c.fileMap, key)
+		}
+	}
+
+	for _, file := range p.pkg.syntax {
+		if p.pkg.fset == nil || !file.Package.IsValid() {
+			continue
+		}
+		key := util.LowerDriver(source.OriginalFilename(p.pkg.fset, file))
+		if c.fileMap[key] == p {
+			delete(c.fileMap, key)
+		}
 	}
 }
 
@@ -160,16 +284,16 @@ func (c *GlobalCache) Unlock() {
 	c.mu.Unlock()
 }
 
-func (c *GlobalCache) clean(idList []string) {
-	if c == nil || len(idList) == 0 {
+func (c *GlobalCache) clean(keyList []string) {
+	if c == nil || len(keyList) == 0 {
 		return
 	}
 
 	c.Lock()
 	defer c.Unlock()
 
-	for _, id := range idList {
-		c.delete(id)
+	for _, key := range keyList {
+		c.delete(key)
 	}
 }
 
@@ -182,9 +306,61 @@ func (c *GlobalCache) Get(pkgPath string) *GlobalPackage {
 	c.RLock()
 	p := c.pathMap[pkgPath]
 	c.RUnlock()
+	return c.fresh(p)
+}
+
+// fresh returns p, or nil if it is stale or its syntax was evicted.
+func (c *GlobalCache) fresh(p *GlobalPackage) *GlobalPackage {
+	if !c.usable(p) {
+		return nil
+	}
+	c.touch(p)
 	return p
 }
 
+// GetByDir get package by the directory of its files and its package path
+// from global cache. The plain package is preferred to its test variants.
+func (c *GlobalCache) GetByDir(dir, pkgPath string) *GlobalPackage {
+	if c == nil {
+		return nil
+	}
+
+	c.RLock()
+	var p *GlobalPackage
+	for _, dp := range c.dirMap[dirKey(dir)] {
+		if dp.pkg.pkgPath != pkgPath {
+			continue
+		}
+		if p == nil || !isTestVariant(dp.pkg.id, dp.pkg.pkgPath) {
+			p = dp
+		}
+	}
+	c.RUnlock()
+	return c.fresh(p)
+}
+
+// replace replaces the package old of the directory dir by new, or removes
+// it if new is nil.
+func (m dir2Package) replace(dir string, old, new *GlobalPackage) {
+	pkgs := m[dir]
+	for i, p := range pkgs {
+		if p != old {
+			continue
+		}
+		if new != nil {
+			pkgs[i] = new
+			return
+		}
+		pkgs = append(pkgs[:i], pkgs[i+1:]...)
+		if len(pkgs) == 0 {
+			delete(m, dir)
+		} else {
+			m[dir] = pkgs
+		}
+		return
+	}
+}
+
 func (c *GlobalCache) Put(pkg *Package) {
 	if c == nil {
 		return
@@ -193,16 +369,17 @@ func (c *GlobalCache) Put(pkg *Package) {
 	c.Lock()
 	defer c.Unlock()
 	c.put(pkg)
+	c.evict()
 }
 
-func (c *GlobalCache) Delete(id string) {
-	if c == nil {
+func (c *GlobalCache) Delete(pkg *Package) {
+	if c == nil || pkg == nil {
 		return
 	}
 
 	c.Lock()
 	defer c.Unlock()
-	c.delete(id)
+	c.delete(cacheKey(pkg.id, pkg.files))
 }
 
 // GetByURI get package by filename from global cache
@@ -213,7 +390,7 @@ func (c *GlobalCache) GetByURI(filename string) *Package {
 	c.RLock()
 	p := c.fileMap[util.LowerDriver(filename)]
 	c.RUnlock()
-	return p.Package()
+	return c.fresh(p).Package()
 }
 
 // Walk walk the global package cache
@@ -225,12 +402,71 @@ func (c *GlobalCache) Walk(walkFunc source.WalkFunc, ranks []string) error {
 	c.RLock()
 	defer c.RUnlock()
 
+	return c.walk(c.rankedKeys(ranks), walkFunc)
+}
+
+// walkSyntax calls walkFunc with each cached package whose path is
+// included, in the order of Walk, except those whose syntax was evicted,
+// which have no type information to search. It returns their number.
+func (c *GlobalCache) walkSyntax(include func(pkgPath string) bool, walkFunc source.WalkFunc, ranks []string) (evicted int, err error) {
+	if c == nil {
+		return 0, nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, key := range c.rankedKeys(ranks) {
+		p := c.idMap[key]
+		if !include(p.pkg.pkgPath) {
+			continue
+		}
+		if p.evicted {
+			evicted++
+			continue
+		}
+		if err := walkFunc(p.pkg); err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, nil
+}
+
+// WalkSymbols calls walkFunc with the indexed symbols of each cached
+// package declaring some, in the order of Walk.
+func (c *GlobalCache) WalkSymbols(walkFunc func(pkg source.Package, symbols []Symbol) error, ranks []string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, key := range c.rankedKeys(ranks) {
+		symbols := c.symbols.symbols[key]
+		if len(symbols) == 0 {
+			continue
+		}
+		if err := walkFunc(c.get(key), symbols); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rankedKeys returns the keys of the cached packages, those of the
+// packages whose ID starts with ranks[0] first, then ranks[1] and so on,
+// then those of the other packages outside of the standard library, and
+// those of the standard library last. The keys hold the directories of the
+// packages as well, so the packages are ranked and sorted by their ID.
+func (c *GlobalCache) rankedKeys(ranks []string) []string {
 	var idList []string
 	for id := range c.idMap {
 		idList = append(idList, id)
 	}
 
-	getRank := func(id string) int {
+	getRank := func(key string) int {
+		id := c.idMap[key].pkg.id
 		var i int
 		for i = 0; i < len(ranks); i++ {
 			if strings.HasPrefix(id, ranks[i]) {
@@ -253,13 +489,17 @@ func (c *GlobalCache) Walk(walkFunc source.WalkFunc, ranks []string) error {
 		}
 
 		if r1 == r2 {
-			return idList[i] <= idList[j]
+			id1, id2 := c.idMap[idList[i]].pkg.id, c.idMap[idList[j]].pkg.id
+			if id1 != id2 {
+				return id1 < id2
+			}
+			return idList[i] < idList[j]
 		}
 
 		return false
 	})
 
-	return c.walk(idList, walkFunc)
+	return idList
 }
 
 func (c *GlobalCache) walk(idList []string, walkFunc source.WalkFunc) error {
@@ -273,7 +513,9 @@ func (c *GlobalCache) walk(idList []string, walkFunc source.WalkFunc) error {
 	return nil
 }
 
-func (c *GlobalCache) Add(pkg *packages.Package) {
+// Add caches pkg and its imports, which were loaded with the overlay of
+// the count overlaySeq of changes of the content of the open files.
+func (c *GlobalCache) Add(pkg *packages.Package, overlaySeq uint64) {
 	if c == nil {
 		return
 	}
@@ -281,11 +523,18 @@ func (c *GlobalCache) Add(pkg *packages.Package) {
 	c.Lock()
 	defer c.Unlock()
 
-	c.recusiveAdd(pkg, nil)
+	c.recusiveAdd(pkg, nil, overlaySeq)
+	c.evict()
 }
 
-func (c *GlobalCache) recusiveAdd(pkg *packages.Package, parent *Package) {
-	if p, _ := c.idMap[pkg.ID]; p != nil {
+func (c *GlobalCache) recusiveAdd(pkg *packages.Package, parent *Package, overlaySeq uint64) {
+	if isTestMain(pkg) {
+		return
+	}
+
+	// The cached imports type checked against the previous types of their
+	// own imports are replaced as well.
+	if p, _ := c.idMap[cacheKey(pkg.ID, pkg.CompiledGoFiles)]; c.usable(p) && !c.versions.isOutdated(p.pkg) {
 		if parent != nil {
 			parent.imports[pkg.PkgPath] = p.pkg
 		}
@@ -293,9 +542,10 @@ func (c *GlobalCache) recusiveAdd(pkg *packages.Package, parent *Package) {
 	}
 
 	p := create(pkg)
+	p.overlaySeq = overlaySeq
 
 	for _, ip := range pkg.Imports {
-		c.recusiveAdd(ip, p)
+		c.recusiveAdd(ip, p, overlaySeq)
 	}
 
 	c.put(p)
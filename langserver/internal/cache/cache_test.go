@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankedKeys(t *testing.T) {
+	c := NewCache()
+	// The directories of the standard library may contain dots as well.
+	for id, dir := range map[string]string{
+		"fmt":             "/usr/local/go1.12/src/fmt",
+		"errors":          "/usr/local/go1.12/src/errors",
+		"example.com/x":   "/w/x",
+		"example.com/m/a": "/w/m/a",
+	} {
+		key := cacheKey(id, []string{dir + "/a.go"})
+		c.idMap[key] = &GlobalPackage{pkg: &Package{id: id}}
+	}
+
+	var got []string
+	for _, key := range c.rankedKeys([]string{"example.com/m"}) {
+		got = append(got, c.idMap[key].pkg.id)
+	}
+	want := []string{"example.com/m/a", "example.com/x", "errors", "fmt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGetByDir(t *testing.T) {
+	c := NewCache()
+	for _, pkg := range []*Package{
+		{id: "example.com/p", pkgPath: "example.com/p", files: []string{"/w/p/p.go"}},
+		{id: "example.com/p [example.com/p.test]", pkgPath: "example.com/p", files: []string{"/w/p/p.go", "/w/p/p_test.go"}},
+		{id: "example.com/p_test [example.com/p.test]", pkgPath: "example.com/p_test", files: []string{"/w/p/x_test.go"}},
+	} {
+		c.Put(pkg)
+	}
+
+	for pkgPath, want := range map[string]string{
+		"example.com/p":      "example.com/p",
+		"example.com/p_test": "example.com/p_test [example.com/p.test]",
+	} {
+		p := c.GetByDir("/w/p", pkgPath)
+		if p == nil {
+			t.Errorf("%s: got no package", pkgPath)
+			continue
+		}
+		if p.Package().id != want {
+			t.Errorf("%s: got package %s, want %s", pkgPath, p.Package().id, want)
+		}
+	}
+	if p := c.GetByDir("/w/p", "example.com/q"); p != nil {
+		t.Errorf("got package %s of another package path", p.Package().id)
+	}
+
+	c.Delete(c.GetByDir("/w/p", "example.com/p").Package())
+	if p := c.GetByDir("/w/p", "example.com/p"); p == nil || p.Package().id != "example.com/p [example.com/p.test]" {
+		t.Error("got no test variant once the plain package was deleted")
+	}
+}
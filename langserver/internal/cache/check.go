@@ -11,9 +11,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
@@ -23,6 +25,10 @@ func (v *View) parse(ctx context.Context, uri span.URI) ([]packages.Error, error
 	v.mcache.mu.Lock()
 	defer v.mcache.mu.Unlock()
 
+	// The count is taken before the content changes are applied, so that
+	// the packages are never considered fresher than they are.
+	overlaySeq := v.versions.current()
+
 	// Apply any queued-up content changes.
 	if err := v.applyContentChanges(ctx); err != nil {
 		return nil, err
@@ -48,8 +54,9 @@ func (v *View) parse(ctx context.Context, uri span.URI) ([]packages.Error, error
 		return nil, fmt.Errorf("no metadata found for %v", uri)
 	}
 	imp := &importer{
-		view:     v,
-		circular: make(map[string]struct{}),
+		view:       v,
+		circular:   make(map[string]struct{}),
+		overlaySeq: overlaySeq,
 	}
 	// Start prefetching direct imports.
 	for importPath := range f.meta.children {
@@ -87,7 +94,19 @@ func (v *View) cachePackage(pkg *Package) {
 		f.token = tok
 		f.ast = file
 		f.imports = f.ast.Imports
+		f.constraints = buildConstraints(file)
 		f.pkg = pkg
+
+		// The output of cgo maps back to the file importing "C", which is
+		// the one the user actually edits.
+		if original := source.OriginalFilename(v.Config.Fset, file); original != tok.Name() {
+			f := v.getFile(span.FileURI(original))
+			f.token = tok
+			f.ast = file
+			f.imports = f.ast.Imports
+			f.constraints = buildConstraints(file)
+			f.pkg = pkg
+		}
 	}
 }
 
@@ -97,17 +116,41 @@ func (v *View) checkMetadata(ctx context.Context, f *File) ([]packages.Error, er
 		return nil, err
 	}
 	if v.reparseImports(ctx, f, filename) {
-		cfg := v.Config
+		cfg, _ := v.overlayConfig()
+		cfg.Context = ctx
 		cfg.Mode = packages.LoadImports
 		cfg.Dir = filepath.Dir(filename)
+		cfg.Env = v.loadEnv(cfg.Dir)
 		pkgs, err := packages.Load(&cfg, fmt.Sprintf("file=%s", filename))
+		// A cancelled load says nothing about the file, which is loaded
+		// again by the next request.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if gomod := findGoMod(cfg.Dir); gomod != "" {
+			v.setModuleError(gomod, moduleErrorOf(gomod, err, pkgs))
+		}
 		if len(pkgs) == 0 {
+			// A build constraint may exclude the file from the package
+			// it was part of.
+			v.unlink(f, filename)
 			if err == nil {
 				err = fmt.Errorf("no packages found for %s", filename)
 			}
 			return nil, err
 		}
+		// Metadata is keyed by package path, so link the test variants
+		// last. They are a superset of the plain packages, which lets
+		// files of external test packages see the internal test files.
+		sort.SliceStable(pkgs, func(i, j int) bool {
+			return !isTestVariant(pkgs[i].ID, pkgs[i].PkgPath) && isTestVariant(pkgs[j].ID, pkgs[j].PkgPath)
+		})
 		for _, pkg := range pkgs {
+			// The synthesized main package of the test binary is not
+			// something a user file ever belongs to.
+			if isTestMain(pkg) {
+				continue
+			}
 			// If the package comes back with errors from `go list`, don't bother
 			// type-checking it.
 			if len(pkg.Errors) > 0 {
@@ -119,6 +162,18 @@ func (v *View) checkMetadata(ctx context.Context, f *File) ([]packages.Error, er
 	return nil, nil
 }
 
+// isTestMain reports whether pkg is the main package synthesized by
+// go list for a test binary.
+func isTestMain(pkg *packages.Package) bool {
+	return pkg.Name == "main" && strings.HasSuffix(pkg.PkgPath, ".test")
+}
+
+// isTestVariant reports whether the package with the given ID was
+// recompiled for a test binary, e.g. "p [p.test]" or "p_test [p.test]".
+func isTestVariant(id, pkgPath string) bool {
+	return id != pkgPath
+}
+
 // reparseImports reparses a file's import declarations to determine if they
 // have changed.
 func (v *View) reparseImports(ctx context.Context, f *File, filename string) bool {
@@ -127,10 +182,14 @@ func (v *View) reparseImports(ctx context.Context, f *File, filename string) boo
 	}
 	// Get file content in case we don't already have it?
 	f.read(ctx)
-	parsed, _ := parser.ParseFile(v.Config.Fset, filename, f.content, parser.ImportsOnly)
+	parsed, _ := parser.ParseFile(v.Config.Fset, filename, f.content, parser.ImportsOnly|parser.ParseComments)
 	if parsed == nil {
 		return true
 	}
+	// The build constraints decide which package the file is part of.
+	if strings.Join(buildConstraints(parsed), "\n") != strings.Join(f.constraints, "\n") {
+		return true
+	}
 	if len(f.imports) != len(parsed.Imports) {
 		return true
 	}
@@ -142,6 +201,47 @@ func (v *View) reparseImports(ctx context.Context, f *File, filename string) boo
 	return false
 }
 
+// buildConstraints returns the build constraint lines of file, those of
+// the comments preceding its package clause.
+func buildConstraints(file *ast.File) []string {
+	var constraints []string
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, c := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if strings.HasPrefix(text, "+build") || strings.HasPrefix(text, "go:build") {
+				constraints = append(constraints, text)
+			}
+		}
+	}
+	return constraints
+}
+
+// unlink removes the file f, which belongs to no package anymore, from the
+// package it was part of, so that the package is type checked without it.
+// It assumes that the caller is holding the mutex of the mcache.
+func (v *View) unlink(f *File, filename string) {
+	m := f.meta
+	if m == nil {
+		return
+	}
+	f.meta = nil
+	f.pkg = nil
+	var files []string
+	for _, name := range m.files {
+		if !sameFile(name, filename) {
+			files = append(files, name)
+		}
+	}
+	m.files = files
+
+	v.pcache.mu.Lock()
+	v.remove(m.pkgPath, map[string]bool{})
+	v.pcache.mu.Unlock()
+}
+
 func (v *View) link(pkgPath string, pkg *packages.Package, parent *metadata) *metadata {
 	m, ok := v.mcache.packages[pkgPath]
 	if !ok {
@@ -161,6 +261,13 @@ func (v *View) link(pkgPath string, pkg *packages.Package, parent *metadata) *me
 			f.meta = m
 		}
 	}
+	// Files importing "C" are not compiled themselves, but they belong to
+	// the package all the same.
+	for _, filename := range pkg.GoFiles {
+		if f, ok := v.files[span.FileURI(filename)]; ok {
+			f.meta = m
+		}
+	}
 	// Connect the import graph.
 	if parent != nil {
 		m.parents[parent.pkgPath] = true
@@ -189,6 +296,10 @@ type importer struct {
 	// circular maintains the set of previously imported packages.
 	// If we have seen a package that is already in this map, we have a circular import.
 	circular map[string]struct{}
+
+	// overlaySeq is the count of the changes of the content of the open
+	// files the packages are type checked with.
+	overlaySeq uint64
 }
 
 func (imp *importer) Import(pkgPath string) (*types.Package, error) {
@@ -197,13 +308,13 @@ func (imp *importer) Import(pkgPath string) (*types.Package, error) {
 	}
 	imp.view.pcache.mu.Lock()
 	e, ok := imp.view.pcache.packages[pkgPath]
-	if ok {
+	if ok && !e.outdated {
 		// cache hit
 		imp.view.pcache.mu.Unlock()
 		// wait for entry to become ready
 		<-e.ready
 	} else {
-		// cache miss
+		// cache miss, or an outdated package
 		e = &entry{ready: make(chan struct{})}
 		imp.view.pcache.packages[pkgPath] = e
 		imp.view.pcache.mu.Unlock()
@@ -247,10 +358,14 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 			Selections: make(map[*ast.SelectorExpr]*types.Selection),
 			Scopes:     make(map[ast.Node]*types.Scope),
 		},
-		analyses: make(map[*analysis.Analyzer]*analysisEntry),
+		analyses:   make(map[*analysis.Analyzer]*analysisEntry),
+		overlaySeq: imp.overlaySeq,
 	}
 
 	if isImport && imp.cloneFromCache(pkg) {
+		imp.view.pcache.mu.Lock()
+		imp.view.linkImports(pkgPath, meta.children)
+		imp.view.pcache.mu.Unlock()
 		return pkg, nil
 	}
 
@@ -270,9 +385,11 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	cfg := &types.Config{
 		Error: appendError,
 		Importer: &importer{
-			view:     imp.view,
-			circular: newCircular,
+			view:       imp.view,
+			circular:   newCircular,
+			overlaySeq: imp.overlaySeq,
 		},
+		Sizes: imp.view.sizes(),
 	}
 	check := types.NewChecker(cfg, imp.view.Config.Fset, pkg.types, pkg.typesInfo)
 	check.Files(pkg.syntax)
@@ -288,17 +405,26 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 			pkg.imports[importPath] = importEntry.pkg
 		}
 	}
+	imp.view.linkImports(pkgPath, meta.children)
 
 	imp.view.gcache.Put(pkg)
 	return pkg, nil
 }
 
 func (imp *importer) cloneFromCache(pkg *Package) bool {
-	clone := imp.view.gcache.Get(pkg.pkgPath)
+	// Look the package up by directory, as go.mod replace directives may
+	// point the same package path at different source.
+	clone := imp.view.gcache.GetByDir(packageDir(pkg.files), pkg.pkgPath)
 	if clone == nil {
 		return false
 	}
 
+	// The clone does not have the unsaved content of the open files, or
+	// was type checked against the previous types of its imports.
+	if imp.view.versions.isOutdated(clone.Package()) {
+		return false
+	}
+
 	if len(pkg.files) != len(clone.Package().files) {
 		return false
 	}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+	"time"
+)
+
+type docEntry struct {
+	modTimes map[string]time.Time
+	synopsis string
+}
+
+// PackageSynopsis returns the synopsis of the doc comment of the package in
+// dir, as built for the build context of the project.
+func (p *Project) PackageSynopsis(dir string) string {
+	return p.getView().packageSynopsis(dir)
+}
+
+func (v *View) packageSynopsis(dir string) string {
+	files := v.buildFiles(dir, goext)
+
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	if e, ok := v.pcache.docs[dir]; ok && sameModTimes(e.modTimes, files) {
+		return e.synopsis
+	}
+
+	e := &docEntry{modTimes: files}
+	var names []string
+	for filename := range files {
+		if !strings.HasSuffix(filename, "_test.go") {
+			names = append(names, filename)
+		}
+	}
+	sort.Strings(names)
+	for _, filename := range names {
+		f, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || f.Doc == nil {
+			continue
+		}
+		if text := f.Doc.Text(); strings.TrimSpace(text) != "" {
+			e.synopsis = doc.Synopsis(text)
+			break
+		}
+	}
+
+	v.pcache.docs[dir] = e
+	return e.synopsis
+}
@@ -0,0 +1,378 @@
+package cache
+
+import (
+	"go/types"
+	"log"
+	"sort"
+	"sync/atomic"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/analysis"
+)
+
+// The memory a cached package takes is estimated from the size of its
+// source: its syntax trees, along with the type information recorded for
+// them, take about syntaxFactor bytes per byte of source, and its types
+// about typesFactor. Loading net/http, go/types and bingo itself with all
+// their dependencies, the heap grew by 18 to 20 bytes per byte of source
+// for the syntax and type information, and by about 3 for the types and
+// the file sets. The factors are rounded up to cover the analyses and the
+// indexes of the cache.
+const (
+	syntaxFactor = 24
+	typesFactor  = 4
+)
+
+// evictionTarget is the percentage of the memory budget an eviction frees
+// the cache down to, so that the cache is not evicted again by the next
+// package it caches.
+const evictionTarget = 90
+
+// CacheStats are the estimated memory of the global cache and the counts
+// of its evictions.
+type CacheStats struct {
+	// Packages is the number of cached packages, and Evicted the number of
+	// those whose syntax was evicted.
+	Packages, Evicted int
+	// Memory is the estimated memory of the packages the cache holds in
+	// bytes, the cached packages and those they import, and MaxMemory the
+	// budget of the cache, 0 if it is unlimited.
+	Memory, MaxMemory int64
+	// SyntaxEvictions counts the evictions of the syntax of a package, and
+	// PackageEvictions the evictions of whole packages.
+	SyntaxEvictions, PackageEvictions int
+}
+
+// Stats returns the estimated memory of the cache and the counts of its
+// evictions.
+func (c *GlobalCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	stats := CacheStats{
+		Packages:         len(c.idMap),
+		Memory:           c.memory,
+		MaxMemory:        c.maxMemory,
+		SyntaxEvictions:  c.syntaxEvictions,
+		PackageEvictions: c.packageEvictions,
+	}
+	for _, p := range c.idMap {
+		if p.evicted {
+			stats.Evicted++
+		}
+	}
+	return stats
+}
+
+// SetMaxMemory sets the memory budget of the cache in bytes, 0 for no
+// budget, and evicts the packages exceeding it.
+func (c *GlobalCache) SetMaxMemory(maxMemory int64) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.maxMemory = maxMemory
+	c.evict()
+}
+
+// estimate sets the estimated memory of the syntax and of the types of
+// pkg, unless it was already estimated or has no syntax to estimate it
+// from, like the copies whose syntax was evicted.
+func (pkg *Package) estimate() {
+	if pkg.syntax == nil || pkg.syntaxMemory != 0 || pkg.typesMemory != 0 {
+		return
+	}
+	var size int64
+	for _, file := range pkg.syntax {
+		if pkg.fset == nil || !file.Pos().IsValid() {
+			continue
+		}
+		if tok := pkg.fset.File(file.Pos()); tok != nil {
+			size += int64(tok.Size())
+		}
+	}
+	pkg.syntaxMemory = size * syntaxFactor
+	pkg.typesMemory = size * typesFactor
+}
+
+// hold records a reference of c to pkg, from an entry of c or from the
+// imports of a package c holds. The memory of pkg is counted from its
+// first reference on, along with the references to the packages it
+// imports. It assumes that the caller is holding the write lock of c.
+func (c *GlobalCache) hold(pkg *Package) {
+	if pkg == nil {
+		return
+	}
+	c.refs[pkg]++
+	if c.refs[pkg] > 1 {
+		return
+	}
+
+	pkg.estimate()
+	if pkg.syntax != nil {
+		c.memory += pkg.syntaxMemory
+	}
+	if pkg.types == nil {
+		c.memory += pkg.typesMemory
+	} else if c.typesRefs[pkg.types]++; c.typesRefs[pkg.types] == 1 {
+		c.memory += pkg.typesMemory
+	}
+	for _, imp := range pkg.imports {
+		c.hold(imp)
+	}
+}
+
+// release drops a reference of c to pkg recorded by hold. The memory of
+// pkg is only freed once c no longer references it at all, and the
+// references to the packages it imports are dropped then. It assumes that
+// the caller is holding the write lock of c.
+func (c *GlobalCache) release(pkg *Package) {
+	if pkg == nil {
+		return
+	}
+	c.refs[pkg]--
+	if c.refs[pkg] > 0 {
+		return
+	}
+	delete(c.refs, pkg)
+
+	if pkg.syntax != nil {
+		c.memory -= pkg.syntaxMemory
+	}
+	if pkg.types == nil {
+		c.memory -= pkg.typesMemory
+	} else if c.typesRefs[pkg.types]--; c.typesRefs[pkg.types] == 0 {
+		delete(c.typesRefs, pkg.types)
+		c.memory -= pkg.typesMemory
+	}
+	for _, imp := range pkg.imports {
+		c.release(imp)
+	}
+}
+
+// touch marks p as the most recently used package of c.
+func (c *GlobalCache) touch(p *GlobalPackage) {
+	atomic.StoreUint64(&p.used, atomic.AddUint64(&c.clock, 1))
+}
+
+// usable reports whether p can be returned by the lookups: it must have
+// been type checked with the current content of the open files, and still
+// have its syntax.
+func (c *GlobalCache) usable(p *GlobalPackage) bool {
+	return p != nil && !p.evicted && !c.versions.isStale(p.pkg)
+}
+
+// evict frees the cache down to evictionTarget percent of its budget if it
+// exceeds it. The syntax of the least recently used packages is evicted
+// first, and their types are kept, so that the definitions of the objects
+// they declare are still found from the packages importing them. Then the
+// least recently used packages are evicted altogether. Either way, the
+// memory of a package is only freed once no package the cache holds
+// imports it any more, so the packages importing it may have to be
+// evicted as well. The packages of the open files, and the packages they
+// import, are never evicted. The recently used packages, which requests
+// may still hold, are the last to be evicted. It assumes that the caller
+// is holding the write lock of c.
+func (c *GlobalCache) evict() {
+	if c.maxMemory <= 0 || c.memory <= c.maxMemory {
+		return
+	}
+
+	pinned := c.pinned()
+	var candidates []*GlobalPackage
+	keys := make(map[*GlobalPackage]string)
+	for key, p := range c.idMap {
+		if !pinned[p.pkg] {
+			candidates = append(candidates, p)
+			keys[p] = key
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return atomic.LoadUint64(&candidates[i].used) < atomic.LoadUint64(&candidates[j].used)
+	})
+
+	target := c.maxMemory / 100 * evictionTarget
+	syntaxEvictions, packageEvictions := 0, 0
+	for _, p := range candidates {
+		if c.memory <= target {
+			break
+		}
+		if !p.evicted {
+			c.evictSyntax(keys[p], p)
+			syntaxEvictions++
+		}
+	}
+	for _, p := range candidates {
+		if c.memory <= target {
+			break
+		}
+		c.delete(keys[p])
+		packageEvictions++
+	}
+	if syntaxEvictions == 0 && packageEvictions == 0 {
+		return
+	}
+	c.syntaxEvictions += syntaxEvictions
+	c.packageEvictions += packageEvictions
+
+	log.Printf("cache: evicted the syntax of %d packages and %d whole packages (%d and %d in total), %d MB of %d MB used by %d packages",
+		syntaxEvictions, packageEvictions, c.syntaxEvictions, c.packageEvictions, c.memory>>20, c.maxMemory>>20, len(c.idMap))
+}
+
+// pinned returns the packages of the open files and the packages they
+// import, directly or indirectly.
+func (c *GlobalCache) pinned() map[*Package]bool {
+	pinned := make(map[*Package]bool)
+	var pin func(pkg *Package)
+	pin = func(pkg *Package) {
+		if pkg == nil || pinned[pkg] {
+			return
+		}
+		pinned[pkg] = true
+		for _, imp := range pkg.imports {
+			pin(imp)
+		}
+	}
+	for _, p := range c.idMap {
+		if c.versions.isOpen(packageDir(p.pkg.files)) {
+			pin(p.pkg)
+		}
+	}
+	return pinned
+}
+
+// evictSyntax replaces the package p, cached under key, with a copy
+// without its syntax trees and the type information recorded for them,
+// keeping its types. p itself is left as it is, since the requests which
+// looked it up may still use it, and so are the packages importing it.
+// The copy imports the copies of the packages whose syntax was evicted
+// already. It assumes that the caller is holding the write lock of c.
+func (c *GlobalCache) evictSyntax(key string, p *GlobalPackage) {
+	pkg := p.pkg
+	imports := make(map[string]*Package, len(pkg.imports))
+	for importPath, imp := range pkg.imports {
+		imports[importPath] = c.evictedImport(imp)
+	}
+	c.replace(key, p, evictedCopy(p, imports))
+
+	// The files importing "C" are no longer indexed by their own name, as
+	// only the syntax maps them to it.
+	for _, file := range pkg.syntax {
+		if pkg.fset == nil || !file.Package.IsValid() {
+			continue
+		}
+		key := util.LowerDriver(source.OriginalFilename(pkg.fset, file))
+		if c.fileMap[key] == p && !containsFile(pkg.files, key) {
+			delete(c.fileMap, key)
+		}
+	}
+}
+
+// evictedCopy returns a copy of the package p without its syntax trees and
+// the type information recorded for them, importing imports.
+func evictedCopy(p *GlobalPackage, imports map[string]*Package) *GlobalPackage {
+	pkg := p.pkg
+	return &GlobalPackage{
+		pkg: &Package{
+			id:           pkg.id,
+			pkgPath:      pkg.pkgPath,
+			name:         pkg.name,
+			files:        pkg.files,
+			errors:       pkg.errors,
+			imports:      imports,
+			types:        pkg.types,
+			typesInfo:    &types.Info{},
+			fset:         pkg.fset,
+			overlaySeq:   pkg.overlaySeq,
+			syntaxMemory: pkg.syntaxMemory,
+			typesMemory:  pkg.typesMemory,
+			analyses:     make(map[*analysis.Analyzer]*analysisEntry),
+		},
+		modTime: p.modTime,
+		used:    atomic.LoadUint64(&p.used),
+		evicted: true,
+	}
+}
+
+// replace caches the copy q of the package p, cached under key, in its
+// place. The copies importing p are replaced in turn by copies importing
+// q, so that p is only held by the packages whose syntax was not evicted.
+// The imports of a cached package are never changed, since the requests
+// which looked it up may still walk them. It assumes that the caller is
+// holding the write lock of c.
+func (c *GlobalCache) replace(key string, p, q *GlobalPackage) {
+	c.hold(q.pkg)
+	c.idMap[key] = q
+	if c.pathMap[p.pkg.pkgPath] == p {
+		c.pathMap[p.pkg.pkgPath] = q
+	}
+	c.dirMap.replace(packageDir(p.pkg.files), p, q)
+	for _, file := range p.pkg.files {
+		if key := util.LowerDriver(file); c.fileMap[key] == p {
+			c.fileMap[key] = q
+		}
+	}
+
+	importers := make(map[string]*GlobalPackage)
+	for k, r := range c.idMap {
+		if !r.evicted {
+			continue
+		}
+		for _, imp := range r.pkg.imports {
+			if imp == p.pkg {
+				importers[k] = r
+				break
+			}
+		}
+	}
+	for k, r := range importers {
+		imports := make(map[string]*Package, len(r.pkg.imports))
+		for importPath, imp := range r.pkg.imports {
+			if imp == p.pkg {
+				imp = q.pkg
+			}
+			imports[importPath] = imp
+		}
+		c.replace(k, r, evictedCopy(r, imports))
+	}
+	c.release(p.pkg)
+}
+
+// evictedImport returns the copy of the imported package imp cached in its
+// place if its syntax was evicted, or imp itself. It assumes that the
+// caller is holding the lock of c.
+func (c *GlobalCache) evictedImport(imp *Package) *Package {
+	if imp == nil {
+		return nil
+	}
+	if q := c.idMap[cacheKey(imp.id, imp.files)]; q != nil && q.evicted && q.pkg.types == imp.types {
+		return q.pkg
+	}
+	return imp
+}
+
+// containsFile reports whether the filename key, as indexed by the cache,
+// is one of files.
+func containsFile(files []string, key string) bool {
+	for _, file := range files {
+		if util.LowerDriver(file) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// evictedPackages reports whether whole packages were evicted from c, so
+// that it may miss some packages of the workspace.
+func (c *GlobalCache) evictedPackages() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.packageEvictions > 0
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestEvict(t *testing.T) {
+	fset := token.NewFileSet()
+	c := NewCache()
+	c.versions = newOverlayVersions()
+	for _, name := range []string{"a", "b", "c"} {
+		filename := "/w/" + name + "/" + name + ".go"
+		f, err := parser.ParseFile(fset, filename, "package "+name+"\n", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Put(&Package{id: name, pkgPath: name, files: []string{filename}, syntax: []*ast.File{f}, fset: fset})
+	}
+	// Each package takes 10 bytes of source.
+	const size = 10
+	if stats := c.Stats(); stats.Memory != 3*size*(syntaxFactor+typesFactor) {
+		t.Fatalf("got memory %d, want %d", stats.Memory, 3*size*(syntaxFactor+typesFactor))
+	}
+	c.Get("a")
+	b := c.pathMap["b"].pkg
+
+	// The syntax of the least recently used package is evicted first.
+	c.SetMaxMemory(80 * size)
+	if c.Get("b") != nil {
+		t.Error("got package b with its syntax evicted")
+	}
+	if c.Get("a") == nil || c.Get("c") == nil {
+		t.Error("got packages a and c evicted, want b only")
+	}
+	if p := c.pathMap["b"]; p == nil || p.pkg.syntax != nil {
+		t.Error("got package b removed, want its syntax only evicted")
+	}
+	if b.syntax == nil {
+		t.Error("got the syntax of the package b looked up before evicted from it, want it evicted from a copy")
+	}
+
+	// Then whole packages, but never those of the open files.
+	c.versions.setOpen("/w/c/c.go", true)
+	c.SetMaxMemory(10 * size)
+	stats := c.Stats()
+	want := CacheStats{
+		Packages:         1,
+		Memory:           size * (syntaxFactor + typesFactor),
+		MaxMemory:        10 * size,
+		SyntaxEvictions:  2,
+		PackageEvictions: 2,
+	}
+	if stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+	if c.Get("c") == nil {
+		t.Error("got package c of an open file evicted")
+	}
+}
+
+func TestEvictImports(t *testing.T) {
+	// Each package takes 10 bytes of source.
+	const size = 10
+	for _, evictFirst := range []string{"b", "a"} {
+		fset := token.NewFileSet()
+		c := NewCache()
+		c.versions = newOverlayVersions()
+		pkgs := make(map[string]*Package)
+		for _, name := range []string{"b", "a", "c"} {
+			filename := "/w/" + name + "/" + name + ".go"
+			f, err := parser.ParseFile(fset, filename, "package "+name+"\n", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg := &Package{id: name, pkgPath: name, files: []string{filename}, syntax: []*ast.File{f}, types: types.NewPackage(name, name), fset: fset, imports: make(map[string]*Package)}
+			if name == "a" {
+				pkg.imports["b"] = pkgs["b"]
+			}
+			pkgs[name] = pkg
+			c.Put(pkg)
+		}
+		// The package imported by a is cached once.
+		if stats := c.Stats(); stats.Memory != 3*size*(syntaxFactor+typesFactor) {
+			t.Fatalf("got memory %d, want %d", stats.Memory, 3*size*(syntaxFactor+typesFactor))
+		}
+		if evictFirst == "b" {
+			c.Get("a")
+		} else {
+			c.Get("b")
+		}
+		c.Get("c")
+
+		// Evicting the syntax of a and b frees theirs whichever is evicted
+		// first, as a no longer holds the original b.
+		c.SetMaxMemory(40 * size)
+		stats := c.Stats()
+		want := size * (syntaxFactor + 3*typesFactor)
+		if stats.Memory != int64(want) || stats.Evicted != 2 {
+			t.Errorf("evicting %s first: got memory %d and %d packages evicted, want %d and 2", evictFirst, stats.Memory, stats.Evicted, want)
+		}
+		if got := reachableMemory(c); got != stats.Memory {
+			t.Errorf("evicting %s first: got memory %d, want the memory %d of the packages the cache holds", evictFirst, stats.Memory, got)
+		}
+		for _, name := range []string{"a", "b"} {
+			if reachable(c)[pkgs[name]] {
+				t.Errorf("evicting %s first: got the package %s with its syntax still held by the cache", evictFirst, name)
+			}
+		}
+		if imp := c.pathMap["a"].pkg.imports["b"]; imp != c.pathMap["b"].pkg {
+			t.Errorf("evicting %s first: got a importing %p, want the copy %p of b", evictFirst, imp, c.pathMap["b"].pkg)
+		}
+
+		// Deleting a package only frees what no other package imports.
+		c.Lock()
+		c.delete(cacheKey("b", pkgs["b"].files))
+		c.Unlock()
+		if got := c.Stats().Memory; got != stats.Memory || got != reachableMemory(c) {
+			t.Errorf("evicting %s first: got memory %d once b was deleted, want %d as a still imports it", evictFirst, got, stats.Memory)
+		}
+	}
+}
+
+func TestEvictKeepsImports(t *testing.T) {
+	fset := token.NewFileSet()
+	c := NewCache()
+	c.versions = newOverlayVersions()
+	for _, name := range []string{"b", "a"} {
+		filename := "/w/" + name + "/" + name + ".go"
+		f, err := parser.ParseFile(fset, filename, "package "+name+"\n", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := &Package{id: name, pkgPath: name, files: []string{filename}, syntax: []*ast.File{f}, types: types.NewPackage(name, name), fset: fset, imports: make(map[string]*Package)}
+		if name == "a" {
+			pkg.imports["b"] = c.pathMap["b"].pkg
+		}
+		c.Put(pkg)
+	}
+	b := c.pathMap["b"].pkg
+
+	c.Lock()
+	c.evictSyntax(cacheKey("a", []string{"/w/a/a.go"}), c.pathMap["a"])
+	c.Unlock()
+	a := c.pathMap["a"].pkg
+	c.Lock()
+	c.evictSyntax(cacheKey("b", []string{"/w/b/b.go"}), c.pathMap["b"])
+	c.Unlock()
+
+	// The copy of a looked up before b was evicted still imports b, and is
+	// replaced by a copy importing the copy of b.
+	if a.imports["b"] != b {
+		t.Errorf("got the copy of a looked up before importing %p, want b %p", a.imports["b"], b)
+	}
+	if imp := c.pathMap["a"].pkg.imports["b"]; c.pathMap["a"].pkg == a || imp != c.pathMap["b"].pkg {
+		t.Errorf("got a importing %p, want a new copy importing the copy %p of b", imp, c.pathMap["b"].pkg)
+	}
+	if got, want := c.Stats().Memory, reachableMemory(c); got != want {
+		t.Errorf("got memory %d, want the memory %d of the packages the cache holds", got, want)
+	}
+	if reachable(c)[b] {
+		t.Error("got the package b with its syntax still held by the cache")
+	}
+}
+
+// reachable returns the packages held by c: those of its entries and those
+// they import, directly or indirectly.
+func reachable(c *GlobalCache) map[*Package]bool {
+	seen := make(map[*Package]bool)
+	var visit func(pkg *Package)
+	visit = func(pkg *Package) {
+		if pkg == nil || seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, imp := range pkg.imports {
+			visit(imp)
+		}
+	}
+	for _, p := range c.idMap {
+		visit(p.pkg)
+	}
+	return seen
+}
+
+// reachableMemory returns the estimated memory of the packages held by c,
+// counting the types the copies share with their originals once.
+func reachableMemory(c *GlobalCache) int64 {
+	var memory int64
+	seenTypes := make(map[*types.Package]bool)
+	for pkg := range reachable(c) {
+		if pkg.syntax != nil {
+			memory += pkg.syntaxMemory
+		}
+		if !seenTypes[pkg.types] {
+			seenTypes[pkg.types] = true
+			memory += pkg.typesMemory
+		}
+	}
+	return memory
+}
@@ -25,6 +25,12 @@ type File struct {
 	pkg     *Package
 	meta    *metadata
 	imports []*ast.ImportSpec
+	// constraints are the build constraints of the file when its package
+	// was loaded.
+	constraints []string
+	// signature is the signature of the declarations of the content of the
+	// file, if it is open. See declarations.
+	signature string
 }
 
 func (f *File) URI() span.URI {
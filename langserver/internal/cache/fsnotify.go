@@ -5,9 +5,12 @@ package cache
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
 )
 
 type fsSubject struct {
@@ -33,6 +36,8 @@ func (s *fsSubject) notify() {
 
 		for {
 			select {
+			case <-s.observer.getContext().Done():
+				return
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
@@ -41,6 +46,15 @@ func (s *fsSubject) notify() {
 				if event.Op&fsnotify.Write == fsnotify.Write {
 					s.observer.update(event.Name)
 				}
+				if event.Op&fsnotify.Create == fsnotify.Create {
+					// The directories are not watched recursively.
+					if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() && !isExclude(fi.Name()) {
+						s.watch(event.Name, watcher)
+					}
+				}
+				if change, ok := fileChangeType(event.Op); ok {
+					s.observer.fileChanged(event.Name, change)
+				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
@@ -51,6 +65,19 @@ func (s *fsSubject) notify() {
 	}()
 }
 
+// fileChangeType returns the type of the change of a file reported with op.
+func fileChangeType(op fsnotify.Op) (protocol.FileChangeType, bool) {
+	switch {
+	case op&fsnotify.Create == fsnotify.Create:
+		return protocol.Created, true
+	case op&fsnotify.Remove == fsnotify.Remove, op&fsnotify.Rename == fsnotify.Rename:
+		return protocol.Deleted, true
+	case op&fsnotify.Write == fsnotify.Write:
+		return protocol.Changed, true
+	}
+	return 0, false
+}
+
 func (s *fsSubject) watch(rootDir string, watcher *fsnotify.Watcher) {
 	err := watcher.Add(rootDir)
 	if err != nil {
@@ -3,9 +3,12 @@
 package cache
 
 import (
+	"os"
 	"time"
 
 	"github.com/fsnotify/fsevents"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
 )
 
 type fsSubject struct {
@@ -48,8 +51,29 @@ func (o *fsSubject) notify() {
 						event.Flags&fsevents.ItemRenamed != 0 {
 						o.observer.update("/" + event.Path)
 					}
+					if event.Flags&fsevents.ItemIsFile != 0 {
+						o.observer.fileChanged("/"+event.Path, fileChangeType(event))
+					}
 				}
 			}
 		}
 	}()
 }
+
+// fileChangeType returns the type of the change of the file of event. A
+// renamed file is either created or deleted, depending on whether it is
+// still there.
+func fileChangeType(event fsevents.Event) protocol.FileChangeType {
+	switch {
+	case event.Flags&fsevents.ItemRemoved != 0:
+		return protocol.Deleted
+	case event.Flags&fsevents.ItemRenamed != 0:
+		if _, err := os.Stat("/" + event.Path); err != nil {
+			return protocol.Deleted
+		}
+		return protocol.Created
+	case event.Flags&fsevents.ItemCreated != 0:
+		return protocol.Created
+	}
+	return protocol.Changed
+}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ModuleError is an error of the module system, such as a syntax error in
+// go.mod or a requirement which cannot be found. Such an error fails the
+// loading of every package of the module, so it is attributed to the line
+// of go.mod causing it rather than to the files of the packages.
+type ModuleError struct {
+	// GoMod is the path of the go.mod file.
+	GoMod string
+	// Line is the 1-based line of go.mod causing the error.
+	Line int
+	Msg  string
+}
+
+var (
+	// goModLine matches the line of go.mod a syntax error is reported at,
+	// eg. "/src/m/go.mod:3: unknown directive: requir".
+	goModLine = regexp.MustCompile(`go\.mod:(\d+)(?::\d+)?:\s*(.*)`)
+
+	// moduleVersion matches the module version an error is about, eg.
+	// "example.com/m@v1.2.3: unknown revision v1.2.3".
+	moduleVersion = regexp.MustCompile(`(\S+)@\S+: `)
+
+	// moduleMessages are the messages of the errors of the module system.
+	moduleMessages = []string{
+		"errors parsing go.mod",
+		"unknown revision",
+		"invalid version",
+		"cannot find module providing package",
+		"missing go.sum entry",
+		"module declares its path as",
+		"malformed module path",
+		"go.mod has non-",
+		"no matching versions",
+		"error loading module requirements",
+	}
+)
+
+// parseModuleError returns the error of the module system with the given
+// message for the go.mod file gomod, nil if the message is about another
+// kind of error.
+func parseModuleError(gomod string, content []byte, msg string) *ModuleError {
+	if m := goModLine.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &ModuleError{GoMod: gomod, Line: line, Msg: m[2]}
+	}
+
+	lines := strings.Split(msg, "\n")
+	if !isModuleMessage(lines) {
+		return nil
+	}
+	// Prefer the line about a module version to the summary of the go
+	// command, eg. "go: error loading module requirements".
+	var line string
+	for _, l := range lines {
+		if moduleVersion.MatchString(l) {
+			line = l
+			break
+		}
+	}
+	if line == "" {
+		for _, l := range lines {
+			if isModuleMessage([]string{l}) {
+				line = l
+				break
+			}
+		}
+	}
+	// Drop the prefixes of the go command, eg. "go: " or
+	// "go [list -e -json ...]: exit status 1: go: ".
+	if i := strings.LastIndex(line, "go: "); i >= 0 {
+		line = line[i+len("go: "):]
+	}
+
+	e := &ModuleError{GoMod: gomod, Line: 1, Msg: strings.TrimSpace(line)}
+	if m := moduleVersion.FindStringSubmatch(line); m != nil {
+		if n := requirementLine(content, m[1]); n > 0 {
+			e.Line = n
+		}
+	}
+	return e
+}
+
+// isModuleMessage reports whether one of the lines of a message is about
+// an error of the module system.
+func isModuleMessage(lines []string) bool {
+	for _, l := range lines {
+		for _, message := range moduleMessages {
+			if strings.Contains(l, message) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requirementLine returns the 1-based line of the go.mod file with the
+// given content which requires or replaces the module modulePath, 0 if
+// there is none.
+func requirementLine(content []byte, modulePath string) int {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		for _, field := range strings.Fields(text) {
+			if field == modulePath {
+				return line
+			}
+		}
+	}
+	return 0
+}
+
+// findGoMod returns the path of the go.mod file of the module dir belongs
+// to, "" if there is none.
+func findGoMod(dir string) string {
+	for dir = filepath.Clean(dir); ; {
+		if _, err := os.Stat(filepath.Join(dir, gomod)); err == nil {
+			return filepath.Join(dir, gomod)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ModulePath returns the path of the module the file filename belongs to,
+// as declared by its go.mod file, "" if there is none.
+func ModulePath(filename string) string {
+	gomod := findGoMod(filepath.Dir(filename))
+	if gomod == "" {
+		return ""
+	}
+	content, err := ioutil.ReadFile(gomod)
+	if err != nil {
+		return ""
+	}
+	return modulePath(content)
+}
+
+// modulePath returns the path declared by the module directive of the
+// go.mod file with the given content, "" if there is none.
+func modulePath(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if path, err := strconv.Unquote(fields[1]); err == nil {
+			return path
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// moduleErrorOf returns the error of the module system which failed the
+// loading of packages with the go.mod file gomod, if any.
+func moduleErrorOf(gomod string, err error, pkgs []*packages.Package) *ModuleError {
+	content, _ := ioutil.ReadFile(gomod)
+	if err != nil {
+		if e := parseModuleError(gomod, content, err.Error()); e != nil {
+			return e
+		}
+	}
+	for _, pkg := range pkgs {
+		for _, pkgErr := range pkg.Errors {
+			if e := parseModuleError(gomod, content, pkgErr.Msg); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// setModuleError records the error of the module system of the go.mod
+// file gomod, or clears it if e is nil. It assumes that the caller is
+// holding the mutex of the mcache.
+func (v *View) setModuleError(gomod string, e *ModuleError) {
+	if e == nil {
+		delete(v.mcache.modErrors, gomod)
+		return
+	}
+	v.mcache.modErrors[gomod] = e
+}
+
+// ModuleErrors returns the errors of the module system the last loads of
+// packages failed with, sorted by go.mod file.
+func (v *View) ModuleErrors() []*ModuleError {
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+
+	errs := make([]*ModuleError, 0, len(v.mcache.modErrors))
+	for _, e := range v.mcache.modErrors {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].GoMod < errs[j].GoMod
+	})
+	return errs
+}
+
+// ModuleErrors returns the errors of the module system the last loads of
+// packages failed with.
+func (p *Project) ModuleErrors() []*ModuleError {
+	return p.getView().ModuleErrors()
+}
+
+// CheckGoMod checks the go.mod file gomod by listing the modules it
+// requires, and records the error of the module system this fails with.
+// If the go.mod file becomes valid, the packages of the workspace are
+// reloaded. It returns the error, nil if gomod is valid.
+func (p *Project) CheckGoMod(ctx context.Context, gomod string) *ModuleError {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "list", "-m", "all")
+	cmd.Dir = filepath.Dir(gomod)
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var e *ModuleError
+	if runErr != nil {
+		content, _ := ioutil.ReadFile(gomod)
+		e = parseModuleError(gomod, content, stderr.String())
+	}
+
+	v := p.getView()
+	v.mcache.mu.Lock()
+	_, errored := v.mcache.modErrors[gomod]
+	v.setModuleError(gomod, e)
+	v.mcache.mu.Unlock()
+
+	if errored && e == nil {
+		v.reload()
+		p.update(gomod)
+	}
+	return e
+}
+
+// reload forgets the metadata and the type information of all the
+// packages of the view, so that they are loaded again when requested.
+func (v *View) reload() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	for _, f := range v.files {
+		f.meta = nil
+		f.pkg = nil
+	}
+	v.mcache.packages = make(map[string]*metadata)
+	v.pcache.packages = make(map[string]*entry)
+	v.pcache.importedBy = make(map[string]map[string]bool)
+	v.forgetWorkspace()
+}
@@ -0,0 +1,77 @@
+package cache
+
+import "testing"
+
+func TestParseModuleError(t *testing.T) {
+	content := []byte(`module example.com/m
+
+require (
+	example.com/a v1.0.0 // indirect
+	example.com/b v1.2.3
+)
+
+replace example.com/c => ../c
+`)
+	tests := []struct {
+		msg  string
+		line int
+		want string
+	}{
+		{
+			msg:  "go [list -e -json -compiled=false -test=false -export=false -deps=false -find=true -- file=/src/m/a.go]: exit status 1: go: errors parsing go.mod:\n/src/m/go.mod:3: unknown directive: requir\n",
+			line: 3,
+			want: "unknown directive: requir",
+		},
+		{
+			msg:  "go: finding example.com/b v1.2.3\ngo: example.com/b@v1.2.3: unknown revision v1.2.3\ngo: error loading module requirements\n",
+			line: 5,
+			want: "example.com/b@v1.2.3: unknown revision v1.2.3",
+		},
+		{
+			msg:  "go: example.com/c@v0.0.0-00010101000000-000000000000: parsing ../c/go.mod: open ../c/go.mod: no such file or directory\ngo: error loading module requirements\n",
+			line: 8,
+			want: "example.com/c@v0.0.0-00010101000000-000000000000: parsing ../c/go.mod: open ../c/go.mod: no such file or directory",
+		},
+		{
+			msg:  "cannot find module providing package example.com/d",
+			line: 1,
+			want: "cannot find module providing package example.com/d",
+		},
+		{
+			msg:  "undeclared name: x",
+			line: 0,
+		},
+	}
+	for _, test := range tests {
+		e := parseModuleError("/src/m/go.mod", content, test.msg)
+		if test.line == 0 {
+			if e != nil {
+				t.Errorf("%q: got error %+v, want none", test.msg, e)
+			}
+			continue
+		}
+		if e == nil {
+			t.Errorf("%q: got no error, want %d: %s", test.msg, test.line, test.want)
+			continue
+		}
+		if e.GoMod != "/src/m/go.mod" || e.Line != test.line || e.Msg != test.want {
+			t.Errorf("%q: got %+v, want %d: %s", test.msg, e, test.line, test.want)
+		}
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"module example.com/m\n\nrequire example.com/a v1.0.0\n", "example.com/m"},
+		{"// comment\nmodule \"example.com/q\" // quoted\n", "example.com/q"},
+		{"go 1.12\n", ""},
+	}
+	for _, test := range tests {
+		if got := modulePath([]byte(test.content)); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.content, got, test.want)
+		}
+	}
+}
@@ -12,6 +12,10 @@ type gopath struct {
 	rootDir     string
 	importPath  string
 	underGoroot bool
+
+	// env is the environment the packages are loaded with, if it is not
+	// the one of the view.
+	env []string
 }
 
 func newGopath(project *Project, rootDir string, importPath string, underGoroot bool) *gopath {
@@ -45,9 +49,12 @@ func (p *gopath) buildCache() error {
 	p.project.view.mu.Lock()
 	defer p.project.view.mu.Unlock()
 
-	cfg := p.project.view.Config
+	cfg, overlaySeq := p.project.view.loadConfig()
 	cfg.Dir = p.rootDir
 	cfg.Mode = packages.LoadAllSyntax
+	if p.env != nil {
+		cfg.Env = p.env
+	}
 
 	var pattern string
 	if p.underGoroot {
@@ -55,12 +62,13 @@ func (p *gopath) buildCache() error {
 	} else {
 		pattern = p.importPath + "/..."
 	}
+	p.project.progress.track(&cfg, pattern)
 
 	pkgs, err := packages.Load(&cfg, pattern)
 	if err != nil {
 		return err
 	}
 
-	p.project.setCache(pkgs)
+	p.project.setCache(pkgs, overlaySeq)
 	return nil
 }
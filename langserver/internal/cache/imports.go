@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// ImportRoot is a directory holding the packages whose import paths start
+// with Path, which is empty for the standard library.
+type ImportRoot struct {
+	Path string
+	Dir  string
+
+	// Main is set for the roots of the workspace, whose internal packages
+	// may be imported.
+	Main bool
+}
+
+// ImportRoots returns the roots of the packages the workspace may import:
+// the standard library, the workspace, and the modules of its build lists
+// which are downloaded to the module cache.
+func (p *Project) ImportRoots() []ImportRoot {
+	roots := []ImportRoot{{Dir: goroot}}
+	if p.gopath != nil && !p.gopath.underGoroot {
+		roots = append(roots, ImportRoot{Path: p.gopath.importPath, Dir: p.gopath.rootDir, Main: true})
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range p.modules {
+		m.mu.RLock()
+		for _, mi := range m.moduleMap {
+			dir := mi.dir()
+			if seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			roots = append(roots, ImportRoot{Path: mi.Path, Dir: dir, Main: mi.Main})
+		}
+		m.mu.RUnlock()
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Dir < roots[j].Dir
+	})
+	return roots
+}
+
+// PackageName returns the name of the cached package importPath, "" if it
+// is not cached.
+func (p *Project) PackageName(importPath string) string {
+	if pkg := p.getCache().Get(importPath).Package(); pkg != nil {
+		return pkg.name
+	}
+	return ""
+}
+
+// FindPackage returns the path of the cached package named name which
+// exports all of exports and may be imported by the package pkgPath, ""
+// if there is none. The paths with the fewest elements are preferred, like
+// those of the standard library.
+func (p *Project) FindPackage(pkgPath, name string, exports []string) string {
+	c := p.getCache()
+	if c == nil {
+		return ""
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	var best string
+	for importPath, gp := range c.pathMap {
+		pkg := gp.pkg
+		if pkg.name != name || pkg.types == nil || importPath == pkgPath || !canImport(pkgPath, importPath) {
+			continue
+		}
+		if !exportsAll(pkg.types.Scope(), exports) {
+			continue
+		}
+		if best == "" || shorterPath(importPath, best) {
+			best = importPath
+		}
+	}
+	return best
+}
+
+// canImport reports whether the package pkgPath may import the package
+// importPath, which is not the case of a vendored package or of an
+// internal package of another tree.
+func canImport(pkgPath, importPath string) bool {
+	segments := strings.Split(importPath, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		switch segments[i] {
+		case "vendor":
+			return false
+		case "internal":
+			parent := strings.Join(segments[:i], "/")
+			return parent != "" && (pkgPath == parent || strings.HasPrefix(pkgPath, parent+"/"))
+		}
+	}
+	return true
+}
+
+func exportsAll(scope *types.Scope, exports []string) bool {
+	for _, name := range exports {
+		if obj := scope.Lookup(name); obj == nil || !obj.Exported() {
+			return false
+		}
+	}
+	return true
+}
+
+// shorterPath reports whether the import path a has fewer elements than b,
+// or as many and sorts first.
+func shorterPath(a, b string) bool {
+	if na, nb := strings.Count(a, "/"), strings.Count(b, "/"); na != nb {
+		return na < nb
+	}
+	return a < b
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loadEnv returns the environment to load the package in dir with.
+//
+// A workspace may be a module which contains a GOPATH-style project
+// without a go.mod of its own. Loading such a project in module mode
+// resolves its imports against the module, so its directories are loaded
+// in GOPATH mode instead, with the GOPATH workspace they are laid out in
+// first in GOPATH. The decision is cached per directory; the caller must
+// hold v.mcache.mu.
+func (v *View) loadEnv(dir string) []string {
+	root, ok := v.mcache.gopathRoots[dir]
+	if !ok {
+		root = gopathRoot(dir)
+		v.mcache.gopathRoots[dir] = root
+	}
+
+	if root == "" {
+		return v.Config.Env
+	}
+
+	env := v.Config.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	gopath := root
+	if path := v.getenv(gopathEnv); path != "" {
+		gopath += string(filepath.ListSeparator) + path
+	}
+	return append(env[:len(env):len(env)], gopathEnv+"="+gopath, go111module+"=off")
+}
+
+// gopathRoot returns the root of the GOPATH workspace the package in dir
+// is laid out in, or "" if it is loaded in module mode. It walks up from
+// dir: a go.mod found before any directory named src makes dir part of a
+// module. Otherwise the parent of the nearest src directory is the root of
+// a GOPATH workspace, unless a go.mod is found above it as well. In that
+// case dir is only a GOPATH-style project nested in the module if its
+// layout says so: the directory below src is named after a domain, as in
+// src/example.com/project, or the files of dir import packages of the src
+// directory.
+func gopathRoot(dir string) string {
+	dir = filepath.Clean(dir)
+	src := ""
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, gomod)); err == nil {
+			break
+		}
+		if src == "" && filepath.Base(d) == "src" && d != dir {
+			src = d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			// No module contains dir.
+			if src == "" {
+				return ""
+			}
+			return filepath.Dir(src)
+		}
+		d = parent
+	}
+
+	if src == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(src, dir)
+	if err != nil {
+		return ""
+	}
+	if first := strings.Split(filepath.ToSlash(rel), "/")[0]; strings.Contains(first, ".") || importsFrom(dir, src) {
+		return filepath.Dir(src)
+	}
+	return ""
+}
+
+// importsFrom reports whether the Go files of dir import a package of the
+// src directory of a GOPATH workspace.
+func importsFrom(dir, src string) bool {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*"+goext))
+	if err != nil {
+		return false
+	}
+	fset := token.NewFileSet()
+	for _, filename := range filenames {
+		f, err := parser.ParseFile(fset, filename, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(src, filepath.FromSlash(path))); err == nil && info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getenv returns the value of the environment variable key the view's
+// packages are loaded with.
+func (v *View) getenv(key string) string {
+	env := v.Config.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	value := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			value = kv[len(key)+1:]
+		}
+	}
+	if value == "" && key == gopathEnv {
+		value = build.Default.GOPATH
+	}
+	return value
+}
+
+// cgoEnv returns env, the environment of the server if it is nil, with cgo
+// enabled if the go command supports it in dir. Files importing "C" are
+// left out of packages unless cgo is enabled, but forcing it on without a
+// C compiler breaks go list for every package, so env is left as is if it
+// sets CGO_ENABLED already or the go command does not enable it.
+func cgoEnv(dir string, env []string) []string {
+	base := env
+	if base == nil {
+		base = os.Environ()
+	}
+	for _, kv := range base {
+		if strings.HasPrefix(kv, cgoEnabled+"=") && kv != cgoEnabled+"=" {
+			return env
+		}
+	}
+
+	cmd := exec.Command("go", "env", cgoEnabled)
+	cmd.Dir = dir
+	cmd.Env = base
+	out, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(out)) != "1" {
+		return env
+	}
+	return append(base[:len(base):len(base)], cgoEnabled+"=1")
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGopathRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "TestGopathRoot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for name, content := range map[string]string{
+		"m/go.mod":                          "module example.com/m\n",
+		"m/a/a.go":                          `package a`,
+		"m/src/b/b.go":                      `package b; import "example.com/m/a"`,
+		"m/gopath/src/example.com/old/x.go": `package old`,
+		"m/gopath/src/project/cmd/cmd.go":   `package main; import "project/lib"`,
+		"m/gopath/src/project/lib/lib.go":   `package lib`,
+		"m/gopath/src/project/mod/go.mod":   "module project/mod\n",
+		"m/gopath/src/project/mod/mod.go":   `package mod`,
+		"gopath/src/example.com/plain/p.go": `package plain`,
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		dir, want string
+	}{
+		{"m/a", ""},
+		{"m/src/b", ""},
+		{"m/gopath/src/example.com/old", "m/gopath"},
+		{"m/gopath/src/project/cmd", "m/gopath"},
+		{"m/gopath/src/project/lib", ""},
+		{"m/gopath/src/project/mod", ""},
+		{"gopath/src/example.com/plain", "gopath"},
+	} {
+		want := test.want
+		if want != "" {
+			want = filepath.Join(root, filepath.FromSlash(want))
+		}
+		if got := gopathRoot(filepath.Join(root, filepath.FromSlash(test.dir))); got != want {
+			t.Errorf("gopathRoot(%q) = %q, want %q", test.dir, got, want)
+		}
+	}
+}
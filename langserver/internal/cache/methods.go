@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"go/types"
+)
+
+// methodIndex maps the key of each method to the named types of the cached
+// packages whose method set, or the method set of their pointer, holds it.
+// It answers implementation queries without checking every named type
+// against every interface.
+type methodIndex struct {
+	types map[string]map[*types.Named]bool
+
+	// pkgTypes holds the named types indexed for each cache key, so they
+	// can be removed when the package is.
+	pkgTypes map[string][]*types.Named
+}
+
+func newMethodIndex() *methodIndex {
+	return &methodIndex{
+		types:    make(map[string]map[*types.Named]bool),
+		pkgTypes: make(map[string][]*types.Named),
+	}
+}
+
+// methodKey returns the key of the method fn: its name, qualified by its
+// package path if it is unexported, followed by its signature without
+// receiver. Types are qualified by their package path, so the keys of
+// methods of different type checks of a package are the same.
+func methodKey(fn *types.Func) string {
+	name := fn.Name()
+	if !fn.Exported() && fn.Pkg() != nil {
+		name = fn.Pkg().Path() + "." + name
+	}
+	sig := fn.Type().(*types.Signature)
+	return name + types.TypeString(types.NewSignature(nil, sig.Params(), sig.Results(), sig.Variadic()), nil)
+}
+
+// methodKeys returns the keys of the methods of the method set of T, or of
+// its pointer if T is not an interface.
+func methodKeys(T types.Type) []string {
+	if !types.IsInterface(T) {
+		if _, ok := T.(*types.Pointer); !ok {
+			T = types.NewPointer(T)
+		}
+	}
+
+	mset := types.NewMethodSet(T)
+	keys := make([]string, 0, mset.Len())
+	for i := 0; i < mset.Len(); i++ {
+		if fn, ok := mset.At(i).Obj().(*types.Func); ok {
+			keys = append(keys, methodKey(fn))
+		}
+	}
+	return keys
+}
+
+// add indexes the named types defined by pkg, even local ones, under key.
+// Aliases are ignored to avoid indexing a named type twice.
+func (x *methodIndex) add(key string, pkg *Package) {
+	if pkg.typesInfo == nil {
+		return
+	}
+
+	for _, obj := range pkg.typesInfo.Defs {
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+
+		for _, k := range methodKeys(named) {
+			if x.types[k] == nil {
+				x.types[k] = make(map[*types.Named]bool)
+			}
+			x.types[k][named] = true
+		}
+		x.pkgTypes[key] = append(x.pkgTypes[key], named)
+	}
+}
+
+// remove removes the named types indexed under key.
+func (x *methodIndex) remove(key string) {
+	for _, named := range x.pkgTypes[key] {
+		for _, k := range methodKeys(named) {
+			delete(x.types[k], named)
+			if len(x.types[k]) == 0 {
+				delete(x.types, k)
+			}
+		}
+	}
+	delete(x.pkgTypes, key)
+}
+
+// withMethods returns the indexed types having all the methods of keys.
+func (x *methodIndex) withMethods(keys []string) []*types.Named {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// Start from the rarest method.
+	rarest := x.types[keys[0]]
+	for _, k := range keys[1:] {
+		if len(x.types[k]) < len(rarest) {
+			rarest = x.types[k]
+		}
+	}
+
+	var result []*types.Named
+	for named := range rarest {
+		if hasMethods(x.types, named, keys) {
+			result = append(result, named)
+		}
+	}
+	return result
+}
+
+// interfacesWithin returns the indexed non-empty interfaces whose methods
+// are all among the methods of keys.
+func (x *methodIndex) interfacesWithin(keys []string) []*types.Named {
+	seen := make(map[*types.Named]bool)
+	var result []*types.Named
+	for _, k := range keys {
+		for named := range x.types[k] {
+			if seen[named] || !types.IsInterface(named) {
+				continue
+			}
+			seen[named] = true
+
+			within := true
+			for _, ik := range methodKeys(named) {
+				if !contains(keys, ik) {
+					within = false
+					break
+				}
+			}
+			if within {
+				result = append(result, named)
+			}
+		}
+	}
+	return result
+}
+
+func hasMethods(index map[string]map[*types.Named]bool, named *types.Named, keys []string) bool {
+	for _, k := range keys {
+		if !index[k][named] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ImplementationCandidates returns the named types of the cached packages
+// which may be related to T by implementation: the interfaces whose
+// methods are all methods of T, or of *T, and if T is an interface, the
+// types having all the methods of T as well. The candidates still have to
+// be checked with types.AssignableTo, since the method keys ignore the
+// identity of the types in the signatures.
+func (c *GlobalCache) ImplementationCandidates(T types.Type) []*types.Named {
+	if c == nil {
+		return nil
+	}
+
+	keys := methodKeys(T)
+	c.RLock()
+	defer c.RUnlock()
+	candidates := c.methods.interfacesWithin(keys)
+	if !types.IsInterface(T) {
+		return candidates
+	}
+	// The interfaces with the same methods as T are in both.
+	within := make(map[*types.Named]bool, len(candidates))
+	for _, named := range candidates {
+		within[named] = true
+	}
+	for _, named := range c.methods.withMethods(keys) {
+		if !within[named] {
+			candidates = append(candidates, named)
+		}
+	}
+	return candidates
+}
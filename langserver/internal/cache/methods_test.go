@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"testing"
+)
+
+const methodIndexSrc = `package p
+
+type I interface{ M() }
+type J interface{ M(); N(int) string }
+type K interface{ m() }
+type E interface{}
+
+type A struct{}
+
+func (A) M() {}
+
+type B struct{ A }
+
+func (*B) N(int) string { return "" }
+
+type C struct{}
+
+func (C) m() {}
+
+type D struct{}
+
+func (D) M(int) {}
+`
+
+func checkPackage(t *testing.T, src string) *Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	pkg, err := (&types.Config{}).Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Package{id: "p", pkgPath: "p", types: pkg, typesInfo: info, fset: fset}
+}
+
+func typeNames(named []*types.Named) []string {
+	var names []string
+	for _, n := range named {
+		names = append(names, n.Obj().Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestMethodIndex(t *testing.T) {
+	pkg := checkPackage(t, methodIndexSrc)
+	x := newMethodIndex()
+	x.add("p", pkg)
+
+	lookup := func(name string) types.Type {
+		return pkg.types.Scope().Lookup(name).Type()
+	}
+
+	tests := []struct {
+		name string
+		got  []*types.Named
+		want []string
+	}{
+		{"with methods of I", x.withMethods(methodKeys(lookup("I"))), []string{"A", "B", "I", "J"}},
+		{"with methods of J", x.withMethods(methodKeys(lookup("J"))), []string{"B", "J"}},
+		{"with methods of K", x.withMethods(methodKeys(lookup("K"))), []string{"C", "K"}},
+		{"with methods of E", x.withMethods(methodKeys(lookup("E"))), nil},
+		{"interfaces within A", x.interfacesWithin(methodKeys(lookup("A"))), []string{"I"}},
+		{"interfaces within B", x.interfacesWithin(methodKeys(lookup("B"))), []string{"I", "J"}},
+		{"interfaces within D", x.interfacesWithin(methodKeys(lookup("D"))), nil},
+	}
+	for _, test := range tests {
+		if got := typeNames(test.got); !equalStrings(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	x.remove("p")
+	if len(x.types) != 0 || len(x.pkgTypes) != 0 {
+		t.Errorf("got %d methods and %d packages after removal, want none", len(x.types), len(x.pkgTypes))
+	}
+}
+
+func TestImplementationCandidates(t *testing.T) {
+	pkg := checkPackage(t, methodIndexSrc)
+	c := NewCache()
+	c.Put(pkg)
+
+	// An interface is related to the interfaces it implements as well as to
+	// the types implementing it.
+	for _, test := range []struct {
+		name string
+		want []string
+	}{
+		{"I", []string{"A", "B", "I", "J"}},
+		{"J", []string{"B", "I", "J"}},
+		{"B", []string{"I", "J"}},
+	} {
+		T := pkg.types.Scope().Lookup(test.name).Type()
+		if got := typeNames(c.ImplementationCandidates(T)); !equalStrings(got, test.want) {
+			t.Errorf("candidates of %s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
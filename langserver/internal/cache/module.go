@@ -4,11 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/saibing/bingo/langserver/internal/util"
-
 	"golang.org/x/tools/go/packages"
 )
 
@@ -20,6 +20,24 @@ type moduleInfo struct {
 	Version  string    `json:"Version"`
 	Time     time.Time `json:"Time"`
 	Indirect bool      `json:"Indirect"`
+
+	// Replace is set when go.mod replaces this module, either by a local
+	// directory (no Version) or by another module version.
+	Replace *moduleInfo `json:"Replace"`
+}
+
+// dir returns the directory holding the source of the module, which is
+// the directory of the replacement if there is one.
+func (mi *moduleInfo) dir() string {
+	if mi.Replace != nil && mi.Replace.Dir != "" {
+		return mi.Replace.Dir
+	}
+	return mi.Dir
+}
+
+// isLocalReplace reports whether the module is replaced by a local directory.
+func (mi *moduleInfo) isLocalReplace() bool {
+	return mi.Replace != nil && mi.Replace.Version == "" && mi.Replace.Dir != ""
 }
 
 type module struct {
@@ -74,16 +92,24 @@ func (m *module) readGoModule() (map[string]moduleInfo, error) {
 		modules = append(modules, module)
 	}
 
+	return newModuleMap(modules), nil
+}
+
+// newModuleMap indexes modules by the directory holding their source.
+func newModuleMap(modules []moduleInfo) map[string]moduleInfo {
 	moduleMap := map[string]moduleInfo{}
 	for _, module := range modules {
-		if module.Dir == "" {
+		dir := module.dir()
+		if dir == "" {
 			// module define in go.mod but not in ${GOMOD}
 			continue
 		}
-		moduleMap[util.LowerDriver(module.Dir)] = module
+		// Key by the effective directory, so that a replaced module never
+		// shares an entry with the copy in the module cache.
+		moduleMap[dirKey(dir)] = module
 	}
 
-	return moduleMap, nil
+	return moduleMap
 }
 
 func (m *module) initModule(moduleMap map[string]moduleInfo) {
@@ -98,6 +124,50 @@ func (m *module) initModule(moduleMap map[string]moduleInfo) {
 	m.moduleMap = moduleMap
 }
 
+// resolveDir returns the directory holding the source of the package
+// importPath, honoring the replace directives of go.mod.
+func (m *module) resolveDir(importPath string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *moduleInfo
+	for dir := range m.moduleMap {
+		mi := m.moduleMap[dir]
+		if importPath != mi.Path && !strings.HasPrefix(importPath, mi.Path+"/") {
+			continue
+		}
+		if found == nil || len(mi.Path) > len(found.Path) {
+			found = &mi
+		}
+	}
+
+	if found == nil {
+		return "", false
+	}
+
+	rel := strings.TrimPrefix(importPath[len(found.Path):], "/")
+	return dirKey(filepath.Join(found.dir(), filepath.FromSlash(rel))), true
+}
+
+// containsLocalReplace reports whether filename belongs to a module that
+// go.mod replaces by a local directory.
+func (m *module) containsLocalReplace(filename string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filename = dirKey(filename)
+	for dir, mi := range m.moduleMap {
+		if !mi.isLocalReplace() {
+			continue
+		}
+		if strings.HasPrefix(filename, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (m *module) checkModuleCache() (bool, error) {
 	moduleMap, err := m.readGoModule()
 	if err != nil {
@@ -141,16 +211,17 @@ func (m *module) buildCache() error {
 	m.project.view.mu.Lock()
 	defer m.project.view.mu.Unlock()
 
-	cfg := m.project.view.Config
+	cfg, overlaySeq := m.project.view.loadConfig()
 	cfg.Dir = m.rootDir
 	cfg.Mode = packages.LoadAllSyntax
 	pattern := cfg.Dir + "/..."
+	m.project.progress.track(&cfg, pattern)
 
 	pkgs, err := packages.Load(&cfg, pattern)
 	if err != nil {
 		return err
 	}
 
-	m.project.setCache(pkgs)
+	m.project.setCache(pkgs, overlaySeq)
 	return nil
 }
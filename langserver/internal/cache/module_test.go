@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalReplace(t *testing.T) {
+	m := &module{moduleMap: newModuleMap([]moduleInfo{
+		{Path: "example.com/m", Main: true, Dir: filepath.FromSlash("/w/m")},
+		{
+			Path:    "example.com/local",
+			Version: "v0.0.0",
+			Replace: &moduleInfo{Path: "../local", Dir: filepath.FromSlash("/w/local")},
+		},
+		{
+			Path:    "example.com/dep",
+			Version: "v1.0.0",
+			Dir:     filepath.FromSlash("/gopath/pkg/mod/example.com/dep@v1.0.0"),
+			Replace: &moduleInfo{
+				Path:    "example.com/fork",
+				Version: "v1.0.1",
+				Dir:     filepath.FromSlash("/gopath/pkg/mod/example.com/fork@v1.0.1"),
+			},
+		},
+	})}
+
+	// The directories go.mod resolves import paths to are those the files
+	// of the loaded packages are indexed by.
+	for importPath, file := range map[string]string{
+		"example.com/m/a":       "/w/m/a/a.go",
+		"example.com/local":     "/w/local/local.go",
+		"example.com/local/sub": "/w/local/sub/sub.go",
+		"example.com/dep/sub":   "/gopath/pkg/mod/example.com/fork@v1.0.1/sub/sub.go",
+	} {
+		dir, ok := m.resolveDir(importPath)
+		if want := packageDir([]string{filepath.FromSlash(file)}); !ok || dir != want {
+			t.Errorf("%s: got directory %q, %t, want %q", importPath, dir, ok, want)
+		}
+	}
+	if dir, ok := m.resolveDir("example.com/other"); ok {
+		t.Errorf("got directory %q for a package outside of the modules", dir)
+	}
+
+	for file, want := range map[string]bool{
+		"/w/local/local.go":   true,
+		"/w/local/sub/sub.go": true,
+		"/w/localother/a.go":  false,
+		"/w/m/a/a.go":         false,
+		"/gopath/pkg/mod/example.com/fork@v1.0.1/d.go": false,
+	} {
+		if got := m.containsLocalReplace(filepath.FromSlash(file)); got != want {
+			t.Errorf("%s: got %t, want %t", file, got, want)
+		}
+	}
+}
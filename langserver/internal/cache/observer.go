@@ -2,10 +2,13 @@ package cache
 
 import (
 	"context"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
 )
 
 type Observer interface {
 	update(event string)
+	fileChanged(filename string, change protocol.FileChangeType)
 	root() string
 	notifyLog(message string)
 	notifyError(message string)
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// overlayVersions tracks the changes of the content of the open files, so
+// that the packages type checked before the content of one of their files,
+// or the declarations of the packages they import, changed are known to be
+// stale, and those type checked before the bodies of the functions of the
+// packages they import changed to be outdated.
+type overlayVersions struct {
+	mu sync.Mutex
+	// seq counts the changes.
+	seq uint64
+	// changes holds the count at the last change of the declarations of
+	// the open files of each package directory.
+	changes map[string]uint64
+	// bodies holds the count at the last change of the open files of each
+	// package directory which only changed the bodies of their functions.
+	bodies map[string]uint64
+	// signatures holds the signature of the declarations of each open
+	// file. See declarations.
+	signatures map[string]string
+	// open holds the open files.
+	open map[string]bool
+}
+
+func newOverlayVersions() *overlayVersions {
+	return &overlayVersions{
+		changes:    make(map[string]uint64),
+		bodies:     make(map[string]uint64),
+		signatures: make(map[string]string),
+		open:       make(map[string]bool),
+	}
+}
+
+// setOpen records whether the file filename is open.
+func (o *overlayVersions) setOpen(filename string, open bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	filename = util.LowerDriver(filename)
+	if open {
+		o.open[filename] = true
+	} else {
+		delete(o.open, filename)
+	}
+}
+
+// isOpen reports whether a file of the package directory dir is open.
+func (o *overlayVersions) isOpen(dir string) bool {
+	if o == nil {
+		return false
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for filename := range o.open {
+		if dirKey(filepath.Dir(filename)) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// change records a change of the content of the file filename, whose
+// declarations have the signature signature.
+func (o *overlayVersions) change(filename, signature string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	filename = util.LowerDriver(filename)
+	bodyOnly := signature != "" && o.signatures[filename] == signature
+	if signature == "" {
+		delete(o.signatures, filename)
+	} else {
+		o.signatures[filename] = signature
+	}
+
+	o.seq++
+	dir := dirKey(filepath.Dir(filename))
+	if bodyOnly {
+		o.bodies[dir] = o.seq
+	} else {
+		o.changes[dir] = o.seq
+	}
+}
+
+// current returns the count of the changes so far, which the packages
+// loaded with the current overlay are type checked with.
+func (o *overlayVersions) current() uint64 {
+	if o == nil {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.seq
+}
+
+// isStale reports whether the content of an open file of pkg, or the
+// declarations of one of the packages it imports, changed since pkg was
+// type checked.
+func (o *overlayVersions) isStale(pkg *Package) bool {
+	if o == nil || pkg == nil {
+		return false
+	}
+
+	o.mu.Lock()
+	if o.bodies[packageDir(pkg.files)] > pkg.overlaySeq {
+		o.mu.Unlock()
+		return true
+	}
+	dirs := changedSince(o.changes, pkg.overlaySeq, nil)
+	o.mu.Unlock()
+
+	return dirs != nil && importsDir(pkg, dirs, make(map[*Package]bool))
+}
+
+// isOutdated reports whether pkg is stale, or the bodies of the functions
+// of one of the packages it imports changed since pkg was type checked.
+// An outdated package is still valid on its own, but it must not be
+// imported by a package type checked again, which would see two versions
+// of the types of the changed package.
+func (o *overlayVersions) isOutdated(pkg *Package) bool {
+	if o == nil || pkg == nil {
+		return false
+	}
+
+	o.mu.Lock()
+	dirs := changedSince(o.changes, pkg.overlaySeq, nil)
+	dirs = changedSince(o.bodies, pkg.overlaySeq, dirs)
+	o.mu.Unlock()
+
+	return dirs != nil && importsDir(pkg, dirs, make(map[*Package]bool))
+}
+
+// changedSince adds the directories of changes changed after the count seq
+// to dirs, which it allocates if needed, and returns it.
+func changedSince(changes map[string]uint64, seq uint64, dirs map[string]bool) map[string]bool {
+	for dir, s := range changes {
+		if s > seq {
+			if dirs == nil {
+				dirs = make(map[string]bool)
+			}
+			dirs[dir] = true
+		}
+	}
+	return dirs
+}
+
+// importsDir reports whether the files of pkg, or of one of the packages
+// it imports, are in one of dirs.
+func importsDir(pkg *Package, dirs map[string]bool, seen map[*Package]bool) bool {
+	if seen[pkg] {
+		return false
+	}
+	seen[pkg] = true
+
+	if dirs[packageDir(pkg.files)] {
+		return true
+	}
+	for _, imp := range pkg.imports {
+		if importsDir(imp, dirs, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package cache
+
+import "testing"
+
+func TestOverlayVersions(t *testing.T) {
+	o := newOverlayVersions()
+	a := &Package{id: "a", pkgPath: "a", files: []string{"/w/a/a.go"}}
+	b := &Package{id: "b", pkgPath: "b", files: []string{"/w/b/b.go"}, imports: map[string]*Package{"a": a}}
+	c := &Package{id: "c", pkgPath: "c", files: []string{"/w/c/c.go"}}
+
+	check := func(what string, pkg *Package, stale, outdated bool) {
+		t.Helper()
+		if got := o.isStale(pkg); got != stale {
+			t.Errorf("%s: %s: got stale %t, want %t", what, pkg.id, got, stale)
+		}
+		if got := o.isOutdated(pkg); got != outdated {
+			t.Errorf("%s: %s: got outdated %t, want %t", what, pkg.id, got, outdated)
+		}
+	}
+
+	o.change("/w/a/a.go", declarations("a.go", []byte("package a; func A() {}")))
+	check("open", a, true, true)
+	check("open", b, true, true)
+	check("open", c, false, false)
+
+	a.overlaySeq, b.overlaySeq, c.overlaySeq = o.current(), o.current(), o.current()
+	o.change("/w/a/a.go", declarations("a.go", []byte("package a; func A() { _ = 1 }")))
+	check("body", a, true, true)
+	check("body", b, false, true)
+	check("body", c, false, false)
+
+	o.change("/w/a/a.go", declarations("a.go", []byte("package a; func A(int) { _ = 1 }")))
+	check("declarations", b, true, true)
+}
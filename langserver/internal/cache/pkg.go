@@ -25,6 +25,14 @@ type Package struct {
 	typesInfo   *types.Info
 	fset        *token.FileSet
 
+	// overlaySeq is the count of the changes of the content of the open
+	// files the package was type checked with.
+	overlaySeq uint64
+
+	// syntaxMemory and typesMemory are the estimated memory of the syntax
+	// and of the types of the package, set once it is cached.
+	syntaxMemory, typesMemory int64
+
 	// The analysis cache holds analysis information for all the packages in a view.
 	// Each graph node (action) is one unit of analysis.
 	// Edges express package-to-package (vertical) dependencies,
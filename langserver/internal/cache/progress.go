@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	loadingPackages = "Loading packages"
+
+	// logProgressInterval is the interval of the log messages reporting
+	// the progress to the clients without work done progress.
+	logProgressInterval = 5 * time.Second
+)
+
+// progressTokens numbers the work done progress tokens created by the
+// server.
+var progressTokens int64
+
+// progress reports the progress of the loads of the packages of the
+// project to the client, once the first of them starts: with $/progress
+// notifications if the client supports the work done progress, or with log
+// messages every few seconds otherwise. The packages are counted as the
+// files of their directories are parsed.
+type progress struct {
+	project *Project
+
+	// token is the work done progress token. It is the one of the
+	// initialize request, if the client provided it, until the progress
+	// begins with a token created with the client, if any.
+	token protocol.ProgressToken
+
+	mu         sync.Mutex
+	begun      bool
+	dirs       map[string]bool // whether the directories to load were parsed
+	loaded     int
+	percentage uint32
+	logged     time.Time
+}
+
+// SetWorkDoneProgress makes the loads of the packages report their progress
+// with the work done progress of the client, which either supports it or
+// provided the token of the initialize request, the initial load reports
+// its progress on.
+func (p *Project) SetWorkDoneProgress(supported bool, token protocol.ProgressToken) {
+	p.workDoneProgress = supported || token != nil
+	p.initToken = token
+}
+
+// startProgress returns the progress of a new cycle of loads of the
+// packages, on the token of the initialize request for the initial one.
+// It must be ended.
+func (p *Project) startProgress() *progress {
+	pr := &progress{project: p, token: p.initToken, dirs: map[string]bool{}}
+	p.initToken = nil
+	p.progress = pr
+	return pr
+}
+
+// track counts the packages matching patterns, which cfg is about to load,
+// and makes the parse of their files report the progress, which begins
+// with the first load of the cycle.
+func (pr *progress) track(cfg *packages.Config, patterns ...string) {
+	if pr == nil {
+		return
+	}
+
+	pr.begin()
+
+	// Listing the files of the packages is quick compared to their type
+	// checking, and gives the number of packages to load.
+	listCfg := *cfg
+	listCfg.Mode = packages.LoadFiles
+	pkgs, err := packages.Load(&listCfg, patterns...)
+	if err != nil {
+		pr.project.notify(err)
+	}
+	pr.mu.Lock()
+	for _, pkg := range pkgs {
+		for _, filename := range pkg.GoFiles {
+			dir := filepath.Dir(filename)
+			if _, ok := pr.dirs[dir]; !ok {
+				pr.dirs[dir] = false
+			}
+		}
+	}
+	pr.mu.Unlock()
+
+	parse := cfg.ParseFile
+	if parse == nil {
+		parse = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		}
+	}
+	cfg.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		pr.parsed(filepath.Dir(filename))
+		return parse(fset, filename, src)
+	}
+}
+
+// begin begins the progress, with a token created with the client if the
+// client supports the work done progress and did not provide one.
+func (pr *progress) begin() {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if pr.begun {
+		return
+	}
+	pr.begun = true
+
+	p := pr.project
+	if !p.workDoneProgress {
+		p.notifyLog(loadingPackages + "…")
+		return
+	}
+
+	if pr.token == nil {
+		token := fmt.Sprintf("bingo/loadPackages/%d", atomic.AddInt64(&progressTokens, 1))
+		if err := p.conn.Call(p.context, "window/workDoneProgress/create", &protocol.WorkDoneProgressCreateParams{Token: token}, nil); err != nil {
+			// The progress is logged instead.
+			p.notify(err)
+			p.notifyLog(loadingPackages + "…")
+			return
+		}
+		pr.token = token
+	}
+
+	percentage := uint32(0)
+	pr.notifyProgress(&protocol.WorkDoneProgressBegin{Kind: "begin", Title: loadingPackages, Percentage: &percentage})
+}
+
+// parsed counts the package of the directory dir as loaded, once its first
+// file is parsed, and reports the progress.
+func (pr *progress) parsed(dir string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if parsed, ok := pr.dirs[dir]; !ok || parsed {
+		return
+	}
+	pr.dirs[dir] = true
+	pr.loaded++
+
+	// The packages of the next loads of the cycle add to the total, but
+	// the percentage keeps rising.
+	percentage := uint32(pr.loaded * 100 / len(pr.dirs))
+	if percentage < pr.percentage {
+		percentage = pr.percentage
+	}
+	message := fmt.Sprintf("%s… %d/%d", loadingPackages, pr.loaded, len(pr.dirs))
+
+	if pr.token == nil {
+		if time.Since(pr.logged) < logProgressInterval {
+			return
+		}
+		pr.logged = time.Now()
+		pr.project.notifyLog(fmt.Sprintf("%s (%d%%)", message, percentage))
+		return
+	}
+
+	// Only the changes of the percentage are reported, which bounds the
+	// number of notifications.
+	if percentage == pr.percentage && pr.loaded > 1 {
+		return
+	}
+	pr.percentage = percentage
+	pr.notifyProgress(&protocol.WorkDoneProgressReport{Kind: "report", Message: message, Percentage: &percentage})
+}
+
+// end ends the progress, if it began and was not ended yet, with the
+// outcome of the cycle, which failed if err is not nil.
+func (pr *progress) end(err error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	p := pr.project
+	if p.progress == pr {
+		p.progress = nil
+	}
+	if !pr.begun {
+		return
+	}
+	pr.begun = false
+
+	message := fmt.Sprintf("Loaded %d packages", pr.loaded)
+	if err != nil {
+		message = fmt.Sprintf("Loading packages failed: %s", err)
+	}
+
+	if pr.token == nil {
+		p.notifyLog(message)
+		return
+	}
+	pr.notifyProgress(&protocol.WorkDoneProgressEnd{Kind: "end", Message: message})
+}
+
+func (pr *progress) notifyProgress(value interface{}) {
+	p := pr.project
+	_ = p.conn.Notify(p.context, "$/progress", &protocol.ProgressParams{Token: pr.token, Value: value})
+}
@@ -6,14 +6,18 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/saibing/bingo/langserver/internal/util"
@@ -29,6 +33,8 @@ const (
 	vendor          = "vendor"
 	gopathEnv       = "GOPATH"
 	go111module     = "GO111MODULE"
+	cgoEnabled      = "CGO_ENABLED"
+	gosum           = "go.sum"
 	emacsLockPrefix = ".#"
 )
 
@@ -58,27 +64,54 @@ func isFileInsideGomod(path string) bool {
 	return strings.HasPrefix(path, gomodpath)
 }
 
+// IsInModuleCache reports whether filename is inside the module cache.
+func IsInModuleCache(filename string) bool {
+	return isFileInsideGomod(filename)
+}
+
+// IsInGoroot reports whether filename is inside the source tree of GOROOT.
+func IsInGoroot(filename string) bool {
+	return strings.HasPrefix(util.LowerDriver(filepath.ToSlash(filename)), goroot+"/")
+}
+
 // FindPackageFunc matches the signature of loader.Config.FindPackage, except
 // also takes a context.Context.
 type FindPackageFunc func(project *Project, importPath string) (source.Package, error)
 
 // Project project struct
 type Project struct {
-	context       context.Context
-	conn          jsonrpc2.JSONRPC2
-	view          *View
-	rootDir       string
-	vendorDir     string
-	modules       []*module
-	gopath        *gopath
-	cached        bool
+	context   context.Context
+	conn      jsonrpc2.JSONRPC2
+	view      *View
+	rootDir   string
+	vendorDir string
+	modules   []*module
+	gopath    *gopath
+	cached    bool
+
+	// mu guards the builds of the global cache, and the state of the last
+	// one.
+	mu            sync.Mutex
 	newCache      *GlobalCache
 	changedCount  int
 	lastBuildTime time.Time
+
+	// workDoneProgress is set if the client supports the work done
+	// progress, and initToken is the token of the initialize request.
+	workDoneProgress bool
+	initToken        protocol.ProgressToken
+
+	// progress is the progress of the loads of the packages in progress.
+	progress *progress
+
+	// watched is called with the changes of the files of the project
+	// reported by the file system, if the client does not watch them.
+	watched func(protocol.FileEvent)
 }
 
-// NewProject new project
-func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, buildFlags []string) *Project {
+// NewProject new project. Its packages are loaded with buildFlags and env,
+// the environment of the server if it is nil.
+func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, buildFlags, env []string) *Project {
 	cfg := &packages.Config{
 		Context: ctx,
 		Dir:     rootPath,
@@ -90,6 +123,7 @@ func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, bu
 		},
 		Tests:      true,
 		BuildFlags: buildFlags,
+		Env:        cgoEnv(rootPath, env),
 	}
 	view := NewView(cfg)
 
@@ -103,6 +137,15 @@ func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, bu
 	return p
 }
 
+// SetMaxCacheMemory sets the memory budget of the cache of the packages in
+// megabytes, 0 for no budget. The least recently used packages are evicted
+// beyond it.
+func (p *Project) SetMaxCacheMemory(mb int) {
+	maxMemory := int64(mb) << 20
+	atomic.StoreInt64(&p.view.maxCacheMemory, maxMemory)
+	p.getCache().SetMaxMemory(maxMemory)
+}
+
 func (p *Project) View() source.View {
 	return p.getView()
 }
@@ -111,6 +154,12 @@ func (p *Project) getView() *View {
 	return p.view
 }
 
+// Sizes returns the sizes of types the packages of the project are type
+// checked with.
+func (p *Project) Sizes() types.Sizes {
+	return p.getView().sizes()
+}
+
 func (p *Project) notify(err error) {
 	if err != nil {
 		p.notifyLog(fmt.Sprintf("notify: %s\n", err))
@@ -127,11 +176,16 @@ func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
 			p.rootDir, elapsedTime, p.cached, len(p.modules) > 0))
 	}()
 
+	// The files are watched once the project is loaded.
+	defer p.fsnotify()
+
 	if globalCacheStyle == None {
 		return nil
 	}
 
-	p.newCache = NewCache()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.newCache = p.view.newCache()
 	p.getView().gcache = p.newCache
 	err := p.createBuiltin()
 	if err != nil {
@@ -142,16 +196,23 @@ func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
 		return nil
 	}
 
+	progress := p.startProgress()
+	defer func() {
+		// The progress ends even if the load panics.
+		if r := recover(); r != nil {
+			progress.end(fmt.Errorf("%v", r))
+			panic(r)
+		}
+	}()
 	err = p.createProject()
+	progress.end(err)
 	p.notify(err)
 	p.lastBuildTime = time.Now()
-
-	p.fsnotify()
 	return nil
 }
 
 func (p *Project) fsnotify() {
-	if !p.cached {
+	if !p.cached && p.watched == nil {
 		return
 	}
 
@@ -259,16 +320,18 @@ func (p *Project) createGoPath(importPath string, underGoroot bool) error {
 }
 
 func (p *Project) createBuiltin() error {
-	value := os.Getenv(go111module)
+	bulitin := newGopath(p, filepath.ToSlash(filepath.Join(goroot, BuiltinPkg)), "", true)
 
-	if value == "on" {
-		_ = os.Setenv(go111module, "auto")
-		defer func() {
-			_ = os.Setenv(go111module, value)
-		}()
+	// The environment of the process is left alone, since the projects of
+	// the other connections load their packages with it.
+	if p.getView().getenv(go111module) == "on" {
+		env := p.getView().Config.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		bulitin.env = append(env[:len(env):len(env)], go111module+"=auto")
 	}
 
-	bulitin := newGopath(p, filepath.ToSlash(filepath.Join(goroot, BuiltinPkg)), "", true)
 	return bulitin.init()
 }
 
@@ -341,6 +404,22 @@ func (p *Project) GetFromURI(uri lsp.DocumentURI) source.Package {
 	return pkg
 }
 
+// ParseFile returns the syntax of the file at uri, preferring the syntax of
+// a cached package. See View.ParseFile.
+func (p *Project) ParseFile(ctx context.Context, uri lsp.DocumentURI) (*token.FileSet, *ast.File, error) {
+	filename, err := source.FromDocumentURI(uri).Filename()
+	if err != nil {
+		return nil, nil, err
+	}
+	if pkg := p.getCache().GetByURI(filename); pkg != nil {
+		if file := source.GetSyntaxFile(pkg, filename); file != nil {
+			return pkg.GetFileSet(), file, nil
+		}
+	}
+
+	return p.getView().ParseFile(ctx, span.FromDocumentURI(uri))
+}
+
 func (p *Project) getCache() *GlobalCache {
 	p.view.mu.Lock()
 	cache := p.view.gcache
@@ -357,19 +436,148 @@ func (p *Project) GetFromPkgPath(pkgPath string) source.Package {
 	return pkg.Package()
 }
 
+// GetFromImportPath get package from import path. Within a go module the
+// package is looked up by the directory go.mod resolves it to, so that
+// replaced modules resolve to their replacement rather than to another
+// copy of the same import path.
+func (p *Project) GetFromImportPath(importPath string) source.Package {
+	for _, m := range p.modules {
+		dir, ok := m.resolveDir(importPath)
+		if !ok {
+			continue
+		}
+
+		if pkg := p.getCache().GetByDir(dir, importPath); pkg != nil {
+			return pkg.Package()
+		}
+	}
+
+	return p.GetFromPkgPath(importPath)
+}
+
 func (p *Project) update(eventName string) {
+	// Only the global cache of the whole project is rebuilt.
+	if !p.cached {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.needRebuild(eventName) {
 		p.notifyLog("fsnotify " + eventName)
-		p.newCache = NewCache()
-		p.newCache.Put(p.GetBuiltinPackage().(*Package))
-		p.rebuildGopapthCache(eventName)
-		p.rebuildModuleCache(eventName)
-		p.lastBuildTime = time.Now()
+		// The packages reloaded for a change of go.mod report their own
+		// progress.
+		p.rebuild(func() error {
+			p.rebuildGopapthCache(eventName)
+			return p.rebuildModuleCache(eventName)
+		})
+	}
+}
+
+// rebuild replaces the global cache with a new one, holding the builtin
+// package and the packages loaded by build. It assumes that the caller is
+// holding p.mu.
+func (p *Project) rebuild(build func() error) error {
+	p.newCache = p.view.newCache()
+	p.newCache.Put(p.GetBuiltinPackage().(*Package))
+
+	progress := p.startProgress()
+	err := build()
+	progress.end(err)
+	p.lastBuildTime = time.Now()
+
+	p.view.mu.Lock()
+	p.view.gcache = p.newCache
+	p.view.mu.Unlock()
+
+	p.view.pcache.mu.Lock()
+	p.view.forgetWorkspace()
+	p.view.pcache.mu.Unlock()
+	return err
+}
+
+// Invalidate forgets the packages of the directory dir, and those importing
+// them, after files were added to it or removed from it, eg. by go generate.
+// They are loaded again with the files of dir when requested.
+func (p *Project) Invalidate(dir string) {
+	p.getView().invalidateDir(dir)
+}
+
+// InvalidateFile forgets the packages of the file filename, and those
+// importing them, after it was changed outside of the editor. They are
+// loaded again with the content of the disk when requested.
+func (p *Project) InvalidateFile(filename string) {
+	p.getView().invalidateFile(filename)
+}
+
+// Reload forgets all the packages, after a go.mod or go.sum file was
+// changed outside of the editor, eg. by a checkout, which may change the
+// versions of the modules they import. They are loaded again when
+// requested.
+func (p *Project) Reload() {
+	p.getView().reload()
+}
 
-		p.view.mu.Lock()
-		p.view.gcache = p.newCache
-		p.view.mu.Unlock()
+// WatchFiles makes the project watch its files with the notifications of
+// the file system, and call changed with the changes of its Go files and of
+// its go.mod and go.sum files, for the clients which cannot watch them. It
+// must be called before Init.
+func (p *Project) WatchFiles(changed func(protocol.FileEvent)) {
+	p.watched = changed
+}
+
+// fileChanged passes the change of the file filename reported by the file
+// system to the function given to WatchFiles.
+func (p *Project) fileChanged(filename string, change protocol.FileChangeType) {
+	if p.watched == nil {
+		return
+	}
+	base := filepath.Base(filename)
+	if strings.HasPrefix(base, emacsLockPrefix) {
+		return
+	}
+	if !strings.HasSuffix(base, goext) && base != gomod && base != gosum {
+		return
 	}
+	p.watched(protocol.FileEvent{URI: lsp.DocumentURI(span.FileURI(filename)), Type: change})
+}
+
+// Configure sets the build flags and the environment the packages are
+// loaded with, eg. after the build tags changed in the settings of the
+// client. All the packages are forgotten, and loaded again with them when
+// requested, while the global cache is rebuilt.
+func (p *Project) Configure(buildFlags, env []string) {
+	p.getView().configure(buildFlags, env)
+	if !p.cached {
+		return
+	}
+
+	p.notifyLog("rebuild the cache for the new build configuration")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notify(p.rebuild(func() error {
+		var err error
+		if p.gopath != nil {
+			err = p.gopath.buildCache()
+		}
+		for _, m := range p.modules {
+			if merr := m.buildCache(); merr != nil {
+				err = merr
+			}
+		}
+		return err
+	}))
+}
+
+// Env returns the environment the go command runs with in the directory
+// dir, the one its packages are loaded with. It is nil for the environment
+// of the server.
+func (p *Project) Env(dir string) []string {
+	v := p.getView()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+
+	return v.loadEnv(dir)
 }
 
 func (p *Project) needRebuild(eventName string) bool {
@@ -414,9 +622,9 @@ func (p *Project) rebuildGopapthCache(eventName string) {
 	}
 }
 
-func (p *Project) rebuildModuleCache(eventName string) {
+func (p *Project) rebuildModuleCache(eventName string) error {
 	if len(p.modules) == 0 {
-		return
+		return nil
 	}
 
 	for _, m := range p.modules {
@@ -424,16 +632,18 @@ func (p *Project) rebuildModuleCache(eventName string) {
 			rebuild, err := m.rebuildCache()
 			if err != nil {
 				p.notifyError(err.Error())
-				return
+				return err
 			}
 
 			if rebuild {
 				p.notifyInfo(fmt.Sprintf("rebuild module cache for %s changed", eventName))
 			}
 
-			return
+			return nil
 		}
 	}
+
+	return nil
 }
 
 // NotifyError notify error to lsp client
@@ -446,6 +656,11 @@ func (p *Project) notifyInfo(message string) {
 	_ = p.conn.Notify(p.context, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: message})
 }
 
+// notifyWarning notify warning to lsp client
+func (p *Project) notifyWarning(message string) {
+	_ = p.conn.Notify(p.context, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.MTWarning, Message: message})
+}
+
 // NotifyLog notify log to lsp client
 func (p *Project) notifyLog(message string) {
 	_ = p.conn.Notify(p.context, "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: message})
@@ -461,6 +676,32 @@ func (p *Project) getContext() context.Context {
 
 // Search serach package cache
 func (p *Project) Search(walkFunc source.WalkFunc) error {
+	return p.search(func(string) bool { return true }, walkFunc)
+}
+
+// search walks the cached packages whose path is included, in the order of
+// Walk. The packages whose syntax was evicted cannot be searched, so the
+// user is warned that the results may be incomplete.
+func (p *Project) search(include func(pkgPath string) bool, walkFunc source.WalkFunc) error {
+	evicted, err := p.getCache().walkSyntax(include, walkFunc, p.ranks())
+	if evicted > 0 {
+		p.notifyWarning(fmt.Sprintf("%d packages evicted from the cache were not searched, the results may be incomplete. Raise maxCacheMemoryMB to keep them.", evicted))
+	}
+	return err
+}
+
+// SearchSymbols calls walkFunc with the symbols of each cached package,
+// in the order of Search. The packages of the open files changed since
+// they were last type checked are checked first, so that their symbols
+// are up to date.
+func (p *Project) SearchSymbols(ctx context.Context, walkFunc func(pkg source.Package, symbols []Symbol) error) error {
+	p.getView().checkActive(ctx)
+	return p.getCache().WalkSymbols(walkFunc, p.ranks())
+}
+
+// ranks returns the paths of the main modules of the project, whose
+// packages are searched first.
+func (p *Project) ranks() []string {
 	var ranks []string
 	for _, module := range p.modules {
 		if module.mainModulePath == "." || module.mainModulePath == "" {
@@ -468,13 +709,12 @@ func (p *Project) Search(walkFunc source.WalkFunc) error {
 		}
 		ranks = append(ranks, module.mainModulePath)
 	}
-
-	return p.getCache().Walk(walkFunc, ranks)
+	return ranks
 }
 
-func (p *Project) setCache(pkgs []*packages.Package) {
+func (p *Project) setCache(pkgs []*packages.Package, overlaySeq uint64) {
 	for _, pkg := range pkgs {
-		p.newCache.Add(pkg)
+		p.newCache.Add(pkg, overlaySeq)
 	}
 }
 
@@ -513,8 +753,41 @@ func (p *Project) TypeCheck(ctx context.Context, fileURI lsp.DocumentURI) (sourc
 	return pkg, f, nil
 }
 
+// FileContent returns the content of the file at fileURI, which is the
+// unsaved content if the file is open in the editor.
+func (p *Project) FileContent(ctx context.Context, fileURI lsp.DocumentURI) ([]byte, error) {
+	uri := span.FromDocumentURI(fileURI)
+
+	v := p.getView()
+	v.mu.Lock()
+	f := v.files[uri]
+	v.mu.Unlock()
+
+	if f != nil {
+		return f.GetContent(ctx), nil
+	}
+
+	filename, err := uri.Filename()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filename)
+}
+
 func (p *Project) isInsideProject(path string) bool {
-	return strings.HasPrefix(filepath.ToSlash(path), p.rootDir)
+	if strings.HasPrefix(filepath.ToSlash(path), p.rootDir) {
+		return true
+	}
+
+	// Local replacements are edited like project files, so they are
+	// type-checked on demand rather than served from the global cache.
+	for _, m := range p.modules {
+		if m.containsLocalReplace(path) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func newSubject(observer Observer) Subject {
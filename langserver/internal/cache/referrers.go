@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"golang.org/x/tools/go/packages"
+)
+
+// Dependents returns the paths of the cached packages importing pkgPath,
+// directly or indirectly, including pkgPath itself, along with those of
+// the packages importing it in the reverse import graphs importedBy. Test
+// variants share the path of their package, so they are included as well.
+func (c *GlobalCache) Dependents(pkgPath string, importedBy ...map[string]map[string]bool) map[string]bool {
+	graph := make(map[string][]string)
+	for _, g := range importedBy {
+		for path, importers := range g {
+			for importer := range importers {
+				graph[path] = append(graph[path], importer)
+			}
+		}
+	}
+	if c != nil {
+		c.RLock()
+		for _, p := range c.idMap {
+			for path := range p.pkg.imports {
+				graph[path] = append(graph[path], p.pkg.pkgPath)
+			}
+		}
+		c.RUnlock()
+	}
+
+	deps := map[string]bool{pkgPath: true}
+	queue := []string{pkgPath}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for _, parent := range graph[path] {
+			if !deps[parent] {
+				deps[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return deps
+}
+
+// SearchReferrers walks the packages which may refer to the objects of the
+// package pkgPath: the packages importing it if exported is set, otherwise
+// only the package itself and its test variants. Workspace packages
+// importing pkgPath which are not cached yet are loaded first, and so are
+// the packages searched whose syntax was evicted.
+func (p *Project) SearchReferrers(ctx context.Context, pkgPath string, exported bool, walkFunc source.WalkFunc) error {
+	deps := map[string]bool{pkgPath: true}
+	var err error
+	if exported {
+		deps, err = p.loadDependents(ctx, pkgPath)
+	} else {
+		err = p.reload(ctx, deps)
+	}
+	if err != nil {
+		return err
+	}
+
+	return p.search(func(pkgPath string) bool { return deps[pkgPath] }, func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return walkFunc(pkg)
+	})
+}
+
+// reload loads and caches again the cached packages of paths which are
+// stale, or whose syntax was evicted.
+func (p *Project) reload(ctx context.Context, paths map[string]bool) error {
+	c := p.getCache()
+	if c == nil {
+		return nil
+	}
+
+	v := p.getView()
+	v.mu.Lock()
+	cfg, overlaySeq := v.loadConfig()
+	v.mu.Unlock()
+
+	cfg.Context = ctx
+	cfg.Dir = p.rootDir
+	return c.load(&cfg, overlaySeq, c.missingPatterns(paths, nil))
+}
+
+// workspaceGraph is the import graph of the packages of the workspace.
+type workspaceGraph struct {
+	// packages holds the paths of the packages of the workspace.
+	packages map[string]bool
+
+	// importedBy maps the path of a package to the paths of the packages
+	// of the workspace importing it.
+	importedBy map[string]map[string]bool
+}
+
+// loadDependents returns the paths of the packages importing pkgPath,
+// directly or indirectly, including pkgPath itself, and loads and caches
+// those of the workspace which are not cached yet, or were type checked
+// before a change of the content of the open files they depend on, or
+// whose syntax was evicted. The importers are found in the global cache,
+// in the reverse import graph of the packages of the open files, and,
+// unless the whole workspace was cached on startup and none of its
+// packages was evicted since, in the import graph of the workspace.
+func (p *Project) loadDependents(ctx context.Context, pkgPath string) (map[string]bool, error) {
+	c := p.getCache()
+	if c == nil {
+		return map[string]bool{pkgPath: true}, nil
+	}
+
+	v := p.getView()
+	v.mu.Lock()
+	cfg, overlaySeq := v.loadConfig()
+	v.mu.Unlock()
+
+	cfg.Context = ctx
+	cfg.Dir = p.rootDir
+	importedBy := []map[string]map[string]bool{v.importedBy()}
+	var workspace *workspaceGraph
+	if !p.cached || c.evictedPackages() {
+		var err error
+		if workspace, err = v.workspaceGraph(cfg, p.rootDir); err != nil {
+			return nil, err
+		}
+		importedBy = append(importedBy, workspace.importedBy)
+	}
+
+	deps := c.Dependents(pkgPath, importedBy...)
+	return deps, c.load(&cfg, overlaySeq, c.missingPatterns(deps, workspace))
+}
+
+// importedBy returns a copy of the reverse import graph of the type checked
+// packages of the view.
+func (v *View) importedBy() map[string]map[string]bool {
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	graph := make(map[string]map[string]bool, len(v.pcache.importedBy))
+	for path, importers := range v.pcache.importedBy {
+		graph[path] = make(map[string]bool, len(importers))
+		for importer := range importers {
+			graph[path][importer] = true
+		}
+	}
+	return graph
+}
+
+// workspaceGraph returns the import graph of the packages of the workspace
+// rootDir, loaded with cfg. It is listed once, and kept until files of the
+// workspace change on disk or its packages are reloaded.
+func (v *View) workspaceGraph(cfg packages.Config, rootDir string) (*workspaceGraph, error) {
+	v.pcache.mu.Lock()
+	workspace, version := v.pcache.workspace, v.pcache.workspaceVersion
+	v.pcache.mu.Unlock()
+	if workspace != nil {
+		return workspace, nil
+	}
+
+	cfg.Mode = packages.LoadImports
+	pkgs, err := packages.Load(&cfg, rootDir+"/...")
+	if err != nil {
+		return nil, err
+	}
+	workspace = &workspaceGraph{
+		packages:   make(map[string]bool),
+		importedBy: make(map[string]map[string]bool),
+	}
+	for _, pkg := range pkgs {
+		if isTestMain(pkg) {
+			continue
+		}
+		workspace.packages[pkg.PkgPath] = true
+		for _, imp := range pkg.Imports {
+			importers, ok := workspace.importedBy[imp.PkgPath]
+			if !ok {
+				importers = make(map[string]bool)
+				workspace.importedBy[imp.PkgPath] = importers
+			}
+			importers[pkg.PkgPath] = true
+		}
+	}
+
+	// The graph is only kept if the workspace did not change meanwhile.
+	v.pcache.mu.Lock()
+	if v.pcache.workspaceVersion == version {
+		v.pcache.workspace = workspace
+	}
+	v.pcache.mu.Unlock()
+	return workspace, nil
+}
+
+// forgetWorkspace forgets the import graph of the workspace, after its
+// files changed on disk or its packages were reloaded. It assumes that the
+// caller is holding the mutex of the pcache.
+func (v *View) forgetWorkspace() {
+	v.pcache.workspace = nil
+	v.pcache.workspaceVersion++
+}
+
+// load loads and caches the packages of patterns with cfg, whose overlay
+// has the count overlaySeq of changes of the content of the open files.
+func (c *GlobalCache) load(cfg *packages.Config, overlaySeq uint64, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	cfg.Mode = packages.LoadAllSyntax
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		c.Add(pkg, overlaySeq)
+	}
+	return nil
+}
+
+// missingPatterns returns the patterns loading the packages of paths
+// which are cached but stale, or whose syntax was evicted, and those of the
+// packages of workspace, if it is not nil, which are not cached at all.
+func (c *GlobalCache) missingPatterns(paths map[string]bool, workspace *workspaceGraph) []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	var patterns []string
+	seen := make(map[string]bool)
+	add := func(pkgPath string) {
+		// External test packages are loaded along with their package.
+		pattern := strings.TrimSuffix(pkgPath, "_test")
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	for _, p := range c.idMap {
+		if paths[p.pkg.pkgPath] && !c.usable(p) {
+			add(p.pkg.pkgPath)
+		}
+	}
+	if workspace != nil {
+		for pkgPath := range paths {
+			if workspace.packages[pkgPath] && c.pathMap[pkgPath] == nil {
+				add(pkgPath)
+			}
+		}
+	}
+	sort.Strings(patterns)
+	return patterns
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// declarations returns the signature of the top-level declarations of the
+// Go source src of the file filename: their text, including their doc
+// comments, with the bodies of the functions elided, along with their
+// positions. Two versions of a file with the same signature only differ in
+// the bodies of their functions, so the packages importing the file's
+// package, which only see its API, and the positions of its declarations,
+// do not need to be type checked again. An empty signature is returned if
+// src does not parse, which never matches.
+func declarations(filename string, src []byte) string {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return ""
+	}
+	tok := fset.File(f.Pos())
+	text := func(from, to token.Pos) []byte {
+		return src[tok.Offset(from):tok.Offset(to)]
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n", f.Name.Name)
+	for _, decl := range f.Decls {
+		var doc *ast.CommentGroup
+		end := decl.End()
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			doc = decl.Doc
+			if decl.Body != nil {
+				end = decl.Body.Lbrace
+			}
+		case *ast.GenDecl:
+			doc = decl.Doc
+		}
+		pos := fset.Position(decl.Pos())
+		fmt.Fprintf(&buf, "%d:%d ", pos.Line, pos.Column)
+		if doc != nil {
+			buf.Write(text(doc.Pos(), doc.End()))
+			buf.WriteByte('\n')
+		}
+		buf.Write(text(decl.Pos(), end))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
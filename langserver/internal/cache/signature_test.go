@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeclarations(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+// A prints x.
+func A(x int) {
+	fmt.Println(x)
+}
+
+type T struct{ F int }
+
+func (t T) M() int { return t.F }
+`
+	for _, test := range []struct {
+		name     string
+		src      string
+		bodyOnly bool
+	}{
+		{"same", src, true},
+		{"body", strings.Replace(src, "fmt.Println(x)", "fmt.Println(x, x)", 1), true},
+		{"method body", strings.Replace(src, "return t.F", "return t.F + 1", 1), true},
+		{"body comment", strings.Replace(src, "fmt.Println(x)", "fmt.Println(x) // x", 1), true},
+		{"body line", strings.Replace(src, "fmt.Println(x)", "fmt.Println(x)\n\tfmt.Println(x)", 1), false},
+		{"signature", strings.Replace(src, "A(x int)", "A(x int64)", 1), false},
+		{"doc", strings.Replace(src, "// A prints x.", "// A prints its argument.", 1), false},
+		{"type", strings.Replace(src, "F int", "F, G int", 1), false},
+		{"import", strings.Replace(src, `"fmt"`, `fmt "fmt"`, 1), false},
+		{"syntax error", strings.Replace(src, "func A", "func", 1), false},
+	} {
+		old, new := declarations("p.go", []byte(src)), declarations("p.go", []byte(test.src))
+		if bodyOnly := new != "" && new == old; bodyOnly != test.bodyOnly {
+			t.Errorf("%s: got body only %v, want %v", test.name, bodyOnly, test.bodyOnly)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Symbol is a symbol declared by a package: a type, function, constant or
+// variable of the package scope, or a field or method of one of its types.
+type Symbol struct {
+	Name string
+	Kind lsp.SymbolKind
+
+	// Container is the type declaring a field or method.
+	Container string
+
+	// Recv is the receiver type of a method, eg. "*T".
+	Recv string
+
+	Location lsp.Location
+}
+
+// symbolIndex holds the symbols of the cached packages, so that workspace
+// symbol queries scan them rather than the syntax of every package.
+type symbolIndex struct {
+	// symbols holds the symbols of the package of each cache key.
+	symbols map[string][]Symbol
+}
+
+func newSymbolIndex() *symbolIndex {
+	return &symbolIndex{symbols: make(map[string][]Symbol)}
+}
+
+// add indexes the symbols of the syntax of pkg under key.
+func (x *symbolIndex) add(key string, pkg *Package) {
+	if pkg.fset == nil {
+		return
+	}
+
+	var symbols []Symbol
+	for _, file := range pkg.syntax {
+		symbols = append(symbols, FileSymbols(pkg.fset, file)...)
+	}
+	if len(symbols) > 0 {
+		x.symbols[key] = symbols
+	}
+}
+
+// remove removes the symbols indexed under key.
+func (x *symbolIndex) remove(key string) {
+	delete(x.symbols, key)
+}
+
+// FileSymbols returns the symbols declared in file, in declaration order.
+// The fields and methods of an interface or struct precede it.
+func FileSymbols(fset *token.FileSet, file *ast.File) []Symbol {
+	c := &symbolCollector{fset: fset}
+	ast.Walk(c, file)
+	return c.symbols
+}
+
+// symbolCollector collects the symbols of a syntax tree.
+type symbolCollector struct {
+	fset    *token.FileSet
+	symbols []Symbol
+
+	// filename and uri are those of the last symbol, which most likely
+	// belongs to the same file as the next one.
+	filename string
+	uri      lsp.DocumentURI
+}
+
+func (c *symbolCollector) Visit(n ast.Node) ast.Visitor {
+	switch t := n.(type) {
+	case *ast.TypeSpec:
+		if t.Name.Name != "_" {
+			switch term := t.Type.(type) {
+			case *ast.StructType:
+				c.addContainer(t.Name, term.Fields, lsp.SKClass)
+			case *ast.InterfaceType:
+				c.addContainer(t.Name, term.Methods, lsp.SKInterface)
+			default:
+				c.add(t.Name, "", "", lsp.SKClass)
+			}
+		}
+	case *ast.GenDecl:
+		switch t.Tok {
+		case token.CONST:
+			c.addValues(t.Specs, lsp.SKConstant)
+		case token.VAR:
+			c.addValues(t.Specs, lsp.SKVariable)
+		}
+	case *ast.FuncDecl:
+		if t.Recv == nil {
+			c.add(t.Name, "", "", lsp.SKFunction)
+			break
+		}
+		var typ ast.Expr
+		if list := t.Recv.List; len(list) == 1 {
+			typ = list[0].Type
+		}
+		recv := recvString(typ)
+		c.add(t.Name, recv, recv, lsp.SKMethod)
+	}
+	return c
+}
+
+// addContainer adds the symbols of the type named name and of its fields,
+// or methods if it is an interface.
+func (c *symbolCollector) addContainer(name *ast.Ident, fields *ast.FieldList, kind lsp.SymbolKind) {
+	for _, field := range fields.List {
+		for _, fieldName := range field.Names {
+			c.add(fieldName, name.Name, "", lsp.SKField)
+		}
+	}
+	c.add(name, "", "", kind)
+}
+
+// addValues adds the symbols of the constants or variables of specs.
+func (c *symbolCollector) addValues(specs []ast.Spec, kind lsp.SymbolKind) {
+	for _, spec := range specs {
+		for _, name := range spec.(*ast.ValueSpec).Names {
+			if name.Name != "_" || kind == lsp.SKConstant {
+				c.add(name, "", "", kind)
+			}
+		}
+	}
+}
+
+func (c *symbolCollector) add(name *ast.Ident, container, recv string, kind lsp.SymbolKind) {
+	start := c.fset.Position(name.Pos())
+	end := c.fset.Position(name.End())
+	if start.Filename != c.filename {
+		c.filename = start.Filename
+		c.uri = lsp.DocumentURI(source.ToURI(start.Filename))
+	}
+
+	c.symbols = append(c.symbols, Symbol{
+		Name:      name.Name,
+		Kind:      kind,
+		Container: container,
+		Recv:      recv,
+		Location: lsp.Location{
+			URI: c.uri,
+			Range: lsp.Range{
+				Start: lsp.Position{Line: start.Line - 1, Character: start.Column - 1},
+				End:   lsp.Position{Line: end.Line - 1, Character: end.Column - 1},
+			},
+		},
+	})
+}
+
+// recvString returns the name of the receiver type recv, eg. "*T".
+func recvString(recv ast.Expr) string {
+	switch t := recv.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + recvString(t.X)
+	}
+	return "BADRECV"
+}
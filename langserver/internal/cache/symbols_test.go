@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+)
+
+func parsePackage(t *testing.T, pkgPath, src string) *Package {
+	t.Helper()
+	filename := "/src/" + pkgPath + "/p.go"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Package{id: pkgPath, pkgPath: pkgPath, files: []string{filename}, syntax: []*ast.File{f}, fset: fset}
+}
+
+func indexedSymbols(t *testing.T, c *GlobalCache) []string {
+	t.Helper()
+	var names []string
+	err := c.WalkSymbols(func(pkg source.Package, symbols []Symbol) error {
+		for _, s := range symbols {
+			name := s.Name
+			if s.Container != "" {
+				name = s.Container + "." + name
+			}
+			names = append(names, pkg.GetPkgPath()+"."+name)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSymbolIndex(t *testing.T) {
+	c := NewCache()
+	c.Put(parsePackage(t, "p", `package p
+
+type T struct{ F int }
+
+func (*T) M() {}
+
+func A() {}
+
+const B = 1
+`))
+	c.Put(parsePackage(t, "q", `package q
+
+var V, _ = 1, 2
+`))
+
+	want := []string{"p.*T.M", "p.A", "p.B", "p.T", "p.T.F", "q.V"}
+	if got := indexedSymbols(t, c); !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A new version of a package replaces its symbols.
+	c.Put(parsePackage(t, "p", `package p
+
+type T struct{ G int }
+
+func C() {}
+`))
+	want = []string{"p.C", "p.T", "p.T.G", "q.V"}
+	if got := indexedSymbols(t, c); !equalStrings(got, want) {
+		t.Errorf("after update: got %q, want %q", got, want)
+	}
+
+	// The symbols of the other packages survive the eviction of one.
+	c.clean([]string{cacheKey("p", []string{"/src/p/p.go"})})
+	want = []string{"q.V"}
+	if got := indexedSymbols(t, c); !equalStrings(got, want) {
+		t.Errorf("after eviction: got %q, want %q", got, want)
+	}
+	if len(c.symbols.symbols) != 1 {
+		t.Errorf("got %d indexed packages, want 1", len(c.symbols.symbols))
+	}
+}
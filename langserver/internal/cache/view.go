@@ -6,8 +6,15 @@ package cache
 
 import (
 	"context"
+	"go/ast"
+	"go/build"
+	"go/parser"
 	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
@@ -48,11 +55,27 @@ type View struct {
 
 	// gcache caches all package for project
 	gcache *GlobalCache
+
+	// versions tracks the changes of the content of the open files, which
+	// make the cached packages type checked before them stale.
+	versions *overlayVersions
+
+	// maxCacheMemory is the memory budget of the global caches in bytes, 0
+	// for no budget. It is accessed atomically.
+	maxCacheMemory int64
 }
 
 type metadataCache struct {
 	mu       sync.Mutex
 	packages map[string]*metadata
+
+	// gopathRoots caches the root of the GOPATH workspace the packages of
+	// a directory are loaded in, "" for module mode. See View.loadEnv.
+	gopathRoots map[string]string
+
+	// modErrors holds the errors of the module system the last loads of
+	// packages failed with, by go.mod file.
+	modErrors map[string]*ModuleError
 }
 
 type metadata struct {
@@ -64,12 +87,36 @@ type metadata struct {
 type packageCache struct {
 	mu       sync.Mutex
 	packages map[string]*entry
+
+	// importedBy is the reverse import graph of the type checked packages:
+	// it maps the path of a package to the paths of the packages which
+	// were type checked against it.
+	importedBy map[string]map[string]bool
+
+	// workspace is the import graph of the packages of the workspace, nil
+	// until it is listed, and workspaceVersion counts the times it was
+	// forgotten. See View.workspaceGraph.
+	workspace        *workspaceGraph
+	workspaceVersion int
+
+	// asm caches the assembly functions of a package directory.
+	asm map[string]*asmEntry
+
+	// docs caches the doc synopsis of a package directory.
+	docs map[string]*docEntry
 }
 
 type entry struct {
 	pkg   *Package
 	err   error
 	ready chan struct{} // closed to broadcast ready condition
+
+	// outdated is set once a package the package imports, directly or
+	// not, is type checked again after only the bodies of its functions
+	// changed. The package is still valid on its own, but is type checked
+	// again when imported, so that the importing package does not see two
+	// versions of the types of the changed package.
+	outdated bool
 }
 
 func NewView(config *packages.Config) *View {
@@ -82,11 +129,17 @@ func NewView(config *packages.Config) *View {
 		files:          make(map[span.URI]*File),
 		contentChanges: make(map[span.URI]func()),
 		mcache: &metadataCache{
-			packages: make(map[string]*metadata),
+			packages:    make(map[string]*metadata),
+			gopathRoots: make(map[string]string),
+			modErrors:   make(map[string]*ModuleError),
 		},
 		pcache: &packageCache{
-			packages: make(map[string]*entry),
+			packages:   make(map[string]*entry),
+			importedBy: make(map[string]map[string]bool),
+			asm:        make(map[string]*asmEntry),
+			docs:       make(map[string]*docEntry),
 		},
+		versions: newOverlayVersions(),
 	}
 }
 
@@ -101,8 +154,29 @@ func (v *View) FileSet() *token.FileSet {
 	return v.Config.Fset
 }
 
+// sizes returns the sizes of types for the GOARCH the packages of the view
+// are loaded for.
+func (v *View) sizes() types.Sizes {
+	goarch := v.getenv("GOARCH")
+	if goarch == "" {
+		goarch = build.Default.GOARCH
+	}
+	if sizes := types.SizesFor("gc", goarch); sizes != nil {
+		return sizes
+	}
+	return types.SizesFor("gc", "amd64")
+}
+
 // SetContent sets the overlay contents for a file.
 func (v *View) SetContent(ctx context.Context, uri span.URI, content []byte) error {
+	// The declarations are parsed before locking the view, which would
+	// block every other access to it.
+	filename, err := uri.Filename()
+	var signature string
+	if err == nil && content != nil {
+		signature = declarations(filename, content)
+	}
+
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
@@ -111,13 +185,50 @@ func (v *View) SetContent(ctx context.Context, uri span.URI, content []byte) err
 	v.cancel()
 	v.backgroundCtx, v.cancel = context.WithCancel(context.Background())
 
+	if err == nil {
+		v.versions.change(filename, signature)
+		v.versions.setOpen(filename, content != nil)
+	}
 	v.contentChanges[uri] = func() {
-		v.applyContentChange(uri, content)
+		v.applyContentChange(uri, content, signature)
 	}
 
 	return nil
 }
 
+// loadConfig returns the configuration the packages are loaded with, with
+// the unsaved content of the open files as its overlay, along with the
+// count of the changes of the content the loaded packages are type checked
+// with. It assumes that the caller is holding the view's mutex.
+func (v *View) loadConfig() (packages.Config, uint64) {
+	v.mcache.mu.Lock()
+	_ = v.applyContentChanges(context.Background())
+	v.mcache.mu.Unlock()
+	return v.overlayConfig()
+}
+
+// overlayConfig is like loadConfig, but it assumes that the caller is
+// holding the mutexes of both the view and the mcache, and has applied the
+// content changes.
+func (v *View) overlayConfig() (packages.Config, uint64) {
+	cfg := v.Config
+	cfg.Overlay = make(map[string][]byte, len(v.Config.Overlay))
+	for filename, content := range v.Config.Overlay {
+		cfg.Overlay[filename] = content
+	}
+	return cfg, v.versions.current()
+}
+
+// newCache returns an empty global cache, whose packages are stale once
+// the content of the open files they depend on changes, and which evicts
+// its packages beyond the memory budget of the view.
+func (v *View) newCache() *GlobalCache {
+	c := NewCache()
+	c.versions = v.versions
+	c.maxMemory = atomic.LoadInt64(&v.maxCacheMemory)
+	return c
+}
+
 // applyContentChanges applies all of the changed content stored in the view.
 // It is assumed that the caller has locked both the view's and the mcache's
 // mutexes.
@@ -137,9 +248,10 @@ func (v *View) applyContentChanges(ctx context.Context) error {
 	return nil
 }
 
-// setContent applies a content update for a given file. It assumes that the
-// caller is holding the view's mutex.
-func (v *View) applyContentChange(uri span.URI, content []byte) {
+// setContent applies a content update for a given file, whose declarations
+// have the signature signature. It assumes that the caller is holding the
+// view's mutex.
+func (v *View) applyContentChange(uri span.URI, content []byte, signature string) {
 	f := v.getFile(uri)
 	f.content = content
 
@@ -147,10 +259,20 @@ func (v *View) applyContentChange(uri span.URI, content []byte) {
 	f.ast = nil
 	f.token = nil
 
-	// Remove the package and all of its reverse dependencies from the cache.
+	// Remove the package and all of its reverse dependencies from the cache,
+	// unless only the bodies of the functions of the file changed, which
+	// leaves the API of the package as its reverse dependencies see it:
+	// they are only outdated.
 	if f.pkg != nil {
-		v.remove(f.pkg.pkgPath, map[string]bool{})
+		pkgPath := f.pkg.pkgPath
+		if signature != "" && signature == f.signature {
+			v.removePackage(pkgPath)
+			v.outdateImporters(pkgPath, map[string]bool{})
+		} else {
+			v.remove(pkgPath, map[string]bool{})
+		}
 	}
+	f.signature = signature
 
 	switch {
 	case f.active && content == nil:
@@ -161,14 +283,40 @@ func (v *View) applyContentChange(uri span.URI, content []byte) {
 		}
 		f.content = nil
 	case content != nil:
-		// This is an active overlay, so we update the map.
+		// This is an active overlay, so we update the map. The files of the
+		// module cache are read-only, and go list refuses to load them
+		// from an overlay, so they are always loaded from disk.
 		f.active = true
-		if filename, err := f.uri.Filename(); err == nil {
+		if filename, err := f.uri.Filename(); err == nil && !IsInModuleCache(filename) {
 			f.view.Config.Overlay[filename] = f.content
 		}
 	}
 }
 
+// checkActive type checks the packages of the open files invalidated by a
+// change of their content since they were last checked, which puts the
+// new packages in the global cache.
+func (v *View) checkActive(ctx context.Context) {
+	v.mu.Lock()
+	var files []*File
+	for uri := range v.contentChanges {
+		files = append(files, v.getFile(uri))
+	}
+	for uri, f := range v.files {
+		if _, changed := v.contentChanges[uri]; !changed && f.active && f.pkg == nil {
+			files = append(files, f)
+		}
+	}
+	v.mu.Unlock()
+
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		f.GetPackage(ctx)
+	}
+}
+
 // remove invalidates a package and its reverse dependencies in the view's
 // package cache. It is assumed that the caller has locked both the mutexes
 // of both the mcache and the pcache.
@@ -178,13 +326,46 @@ func (v *View) remove(pkgPath string, seen map[string]bool) {
 	}
 	seen[pkgPath] = true
 
+	for parentPkgPath := range v.pcache.importedBy[pkgPath] {
+		v.remove(parentPkgPath, seen)
+	}
+	// The reverse dependencies link themselves again when they are type
+	// checked.
+	delete(v.pcache.importedBy, pkgPath)
+	if m, ok := v.mcache.packages[pkgPath]; ok {
+		for importPath := range m.children {
+			delete(v.pcache.importedBy[importPath], pkgPath)
+		}
+	}
+	v.removePackage(pkgPath)
+}
+
+// outdateImporters marks the packages importing the package pkgPath,
+// directly or not, as outdated. It is assumed that the caller has locked
+// the mutex of the pcache.
+func (v *View) outdateImporters(pkgPath string, seen map[string]bool) {
+	for importer := range v.pcache.importedBy[pkgPath] {
+		if seen[importer] {
+			continue
+		}
+		seen[importer] = true
+		if e, ok := v.pcache.packages[importer]; ok {
+			e.outdated = true
+		}
+		v.outdateImporters(importer, seen)
+	}
+}
+
+// removePackage invalidates a package, but not its reverse dependencies, in
+// the view's package cache. It is assumed that the caller has locked both
+// the mutexes of both the mcache and the pcache.
+func (v *View) removePackage(pkgPath string) {
+	delete(v.pcache.packages, pkgPath)
+
 	m, ok := v.mcache.packages[pkgPath]
 	if !ok {
 		return
 	}
-	for parentPkgPath := range m.parents {
-		v.remove(parentPkgPath, seen)
-	}
 	// All of the files in the package may also be holding a pointer to the
 	// invalidated package.
 	for _, filename := range m.files {
@@ -192,7 +373,170 @@ func (v *View) remove(pkgPath string, seen map[string]bool) {
 			f.pkg = nil
 		}
 	}
-	delete(v.pcache.packages, pkgPath)
+}
+
+// linkImports records in the reverse import graph of the view's package cache that
+// the package pkgPath was type checked against the packages of imports. It
+// is assumed that the caller has locked the mutex of the pcache.
+func (v *View) linkImports(pkgPath string, imports map[string]bool) {
+	for importPath := range imports {
+		importers, ok := v.pcache.importedBy[importPath]
+		if !ok {
+			importers = make(map[string]bool)
+			v.pcache.importedBy[importPath] = importers
+		}
+		importers[pkgPath] = true
+	}
+}
+
+// invalidateDir forgets the metadata and the type information of the
+// packages of dir and of their reverse dependencies, so that they are loaded
+// again with the files added to dir, eg. by go generate.
+func (v *View) invalidateDir(dir string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	v.forgetWorkspace()
+	dir = filepath.Clean(dir)
+	seen := make(map[string]bool)
+	for pkgPath, m := range v.mcache.packages {
+		for _, filename := range m.files {
+			if filepath.Dir(filename) == dir {
+				v.remove(pkgPath, seen)
+				break
+			}
+		}
+	}
+	for pkgPath := range seen {
+		m, ok := v.mcache.packages[pkgPath]
+		if !ok {
+			continue
+		}
+		for _, filename := range m.files {
+			if f, ok := v.files[span.FileURI(filename)]; ok {
+				f.meta = nil
+			}
+		}
+	}
+	// The open files of dir may belong to no package yet.
+	for uri, f := range v.files {
+		if filename, err := uri.Filename(); err == nil && filepath.Dir(filename) == dir {
+			f.meta = nil
+			f.pkg = nil
+		}
+	}
+}
+
+// invalidateFile forgets the metadata and the type information of the
+// packages of the file filename and of their reverse dependencies, after it
+// was changed outside of the editor, eg. by a checkout. Its content is read
+// again from the disk unless it is open.
+func (v *View) invalidateFile(filename string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	v.forgetWorkspace()
+	filename = filepath.Clean(filename)
+	seen := make(map[string]bool)
+	var pkgPaths []string
+	for pkgPath, m := range v.mcache.packages {
+		for _, name := range m.files {
+			if filepath.Clean(name) == filename {
+				pkgPaths = append(pkgPaths, pkgPath)
+				v.remove(pkgPath, seen)
+				break
+			}
+		}
+	}
+	// The imports of the file may have changed.
+	for _, pkgPath := range pkgPaths {
+		for _, name := range v.mcache.packages[pkgPath].files {
+			if f, ok := v.files[span.FileURI(name)]; ok {
+				f.meta = nil
+			}
+		}
+	}
+	if f, ok := v.files[span.FileURI(filename)]; ok && !f.active {
+		f.content = nil
+		f.ast = nil
+		f.token = nil
+	}
+}
+
+// configure sets the build flags and the environment the packages of the
+// view are loaded with, and forgets them, as they may be different with
+// them.
+func (v *View) configure(buildFlags, env []string) {
+	env = cgoEnv(v.Config.Dir, env)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	v.Config.BuildFlags = buildFlags
+	v.Config.Env = env
+	for _, f := range v.files {
+		f.meta = nil
+		f.pkg = nil
+	}
+	v.mcache.packages = make(map[string]*metadata)
+	v.mcache.gopathRoots = make(map[string]string)
+	v.pcache.packages = make(map[string]*entry)
+	v.pcache.importedBy = make(map[string]map[string]bool)
+	v.forgetWorkspace()
+}
+
+// ParseFile returns the syntax of the file at uri without type checking
+// it. The cached AST is used if the file has one, otherwise the file is
+// parsed on its own, e.g. for files of dependencies which were loaded
+// without syntax.
+func (v *View) ParseFile(ctx context.Context, uri span.URI) (*token.FileSet, *ast.File, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	filename, err := uri.Filename()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v.mu.Lock()
+	if f, ok := v.files[uri]; ok && f.ast != nil {
+		v.mu.Unlock()
+		return v.Config.Fset, f.ast, nil
+	}
+	var src []byte
+	for name, contents := range v.Config.Overlay {
+		if sameFile(name, filename) {
+			src = contents
+		}
+	}
+	v.mu.Unlock()
+
+	if src == nil {
+		src, err = ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if file == nil {
+		return nil, nil, err
+	}
+	return fset, file, nil
 }
 
 // GetFile returns a File for the given URI. It will always succeed because it
@@ -0,0 +1,114 @@
+// Package fuzzy matches completion candidates against the text typed by
+// the user.
+package fuzzy
+
+import (
+	"math"
+	"unicode"
+)
+
+// The scores of a match. Every matched character scores matchScore, and
+// more if it starts a word or follows the previous match, while the
+// characters skipped between two matches cost gapPenalty each.
+const (
+	matchScore       = 1.0
+	wordStartBonus   = 2.0
+	startBonus       = 1.0
+	consecutiveBonus = 1.5
+	gapPenalty       = 0.5
+)
+
+// Score reports whether candidate matches pattern, and the score of the
+// best match: the higher the better. The characters of pattern must appear
+// in candidate in the same order, the first one at the start of a word of
+// candidate. A lower case character of pattern matches both cases, while
+// an upper case one only matches itself. The words of candidate start
+// after a '_', a '.' or at an upper case letter, like the humps of a camel
+// case name. An empty pattern matches any candidate with a zero score.
+func Score(pattern, candidate string) (float64, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p, c := []rune(pattern), []rune(candidate)
+	if len(p) > len(c) {
+		return 0, false
+	}
+
+	// prev[j] is the best score of the match of the pattern up to the
+	// previous character, matched at c[j], and cur[j] the one up to the
+	// current character.
+	noMatch := math.Inf(-1)
+	prev, cur := make([]float64, len(c)), make([]float64, len(c))
+	for i := range p {
+		// gap is the best score of the previous character matched before
+		// c[j-1], minus the penalty of the characters skipped up to c[j].
+		gap := noMatch
+		for j := range c {
+			cur[j] = noMatch
+			if i > 0 && j >= 2 {
+				gap = math.Max(gap, prev[j-2]) - gapPenalty
+			}
+			if !matches(p[i], c[j]) {
+				continue
+			}
+
+			score := matchScore
+			if isWordStart(c, j) {
+				score += wordStartBonus
+			}
+			if j == 0 {
+				score += startBonus
+			}
+
+			if i == 0 {
+				if isWordStart(c, j) {
+					cur[j] = score
+				}
+				continue
+			}
+			best := gap
+			if j >= 1 {
+				best = math.Max(best, prev[j-1]+consecutiveBonus)
+			}
+			if !math.IsInf(best, -1) {
+				cur[j] = best + score
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	best := noMatch
+	for _, score := range prev {
+		best = math.Max(best, score)
+	}
+	if math.IsInf(best, -1) {
+		return 0, false
+	}
+	return best, true
+}
+
+// matches reports whether the character p of a pattern matches the
+// character c of a candidate.
+func matches(p, c rune) bool {
+	if unicode.IsUpper(p) {
+		return p == c
+	}
+	return p == unicode.ToLower(c)
+}
+
+// isWordStart reports whether c[j] starts a word of c.
+func isWordStart(c []rune, j int) bool {
+	if j == 0 {
+		return true
+	}
+	prev, cur := c[j-1], c[j]
+	switch {
+	case prev == '_' || prev == '.':
+		return cur != '_' && cur != '.'
+	case unicode.IsUpper(cur):
+		// The last upper case letter of an acronym followed by a lower
+		// case one starts a word, like the S of HTTPServer.
+		return !unicode.IsUpper(prev) || j+1 < len(c) && unicode.IsLower(c[j+1])
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package fuzzy
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	for _, test := range []struct {
+		pattern, candidate string
+		match              bool
+	}{
+		{"", "Anything", true},
+		{"pr", "Println", true},
+		{"Pr", "Println", true},
+		{"pR", "Println", false},
+		{"Pr", "println", false},
+		{"hrw", "http.ResponseWriter", true},
+		{"tdpp", "TextDocumentPositionParams", true},
+		{"ub", "unmarshalBody", true},
+		{"ub", "Unbind", true},
+		{"nb", "Unbind", false},
+		{"s", "HTTPServer", true},
+		{"tps", "HTTPServer", false},
+		{"ns", "new_server", true},
+		{"abc", "ab", false},
+		{"xyz", "x", false},
+	} {
+		if _, match := Score(test.pattern, test.candidate); match != test.match {
+			t.Errorf("Score(%q, %q) matches: %t, want %t", test.pattern, test.candidate, match, test.match)
+		}
+	}
+}
+
+func TestScoreRanking(t *testing.T) {
+	for _, test := range []struct {
+		pattern, better, worse string
+	}{
+		// A short gap beats a long one, even ending at a camel hump.
+		{"ub", "Unbind", "unmarshalBody"},
+		// A prefix beats a scattered match.
+		{"pri", "Print", "PanicRecoverInit"},
+		// A match at the start beats one in the middle.
+		{"buf", "Buffer", "NewBuffer"},
+		// Camel humps beat letters in the middle of words.
+		{"tdpp", "TextDocumentPositionParams", "TextDocumentPositionsParsedPlainly"},
+	} {
+		better, ok := Score(test.pattern, test.better)
+		if !ok {
+			t.Errorf("%q does not match %q", test.pattern, test.better)
+			continue
+		}
+		worse, ok := Score(test.pattern, test.worse)
+		if !ok {
+			t.Errorf("%q does not match %q", test.pattern, test.worse)
+			continue
+		}
+		if better <= worse {
+			t.Errorf("Score(%q, %q) = %v, want more than Score(%q, %q) = %v", test.pattern, test.better, better, test.pattern, test.worse, worse)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package markdown converts Go doc comments to Markdown.
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type blockKind int
+
+const (
+	paraBlock blockKind = iota
+	headingBlock
+	codeBlock
+)
+
+type block struct {
+	kind  blockKind
+	lines []string
+}
+
+// FromDoc converts the text of a doc comment, as returned by
+// ast.CommentGroup.Text, to Markdown. It follows the rules of go/doc:
+// paragraphs are separated by blank lines, indented lines are
+// preformatted and become fenced code blocks, and a single capitalized
+// line without punctuation between two paragraphs is a heading.
+func FromDoc(text string) string {
+	var b strings.Builder
+	for i, blk := range blocks(text) {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		switch blk.kind {
+		case paraBlock:
+			for j, line := range blk.lines {
+				if j > 0 {
+					b.WriteByte('\n')
+				}
+				b.WriteString(escape(strings.TrimSpace(line)))
+			}
+		case headingBlock:
+			b.WriteString("### ")
+			b.WriteString(escape(blk.lines[0]))
+		case codeBlock:
+			b.WriteString("```\n")
+			for _, line := range blk.lines {
+				b.WriteString(strings.TrimRightFunc(line, unicode.IsSpace))
+				b.WriteByte('\n')
+			}
+			b.WriteString("```")
+		}
+	}
+	return b.String()
+}
+
+func blocks(text string) []block {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	unindent(lines)
+
+	var (
+		out            []block
+		para           []string
+		lastWasBlank   bool
+		lastWasHeading bool
+	)
+	closePara := func() {
+		if para != nil {
+			out = append(out, block{paraBlock, para})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			closePara()
+			lastWasBlank = true
+			i++
+			continue
+		}
+
+		if indentLen(line) > 0 {
+			closePara()
+			// Blank lines belong to the code block, unless they trail it.
+			j := i + 1
+			for j < len(lines) && (isBlank(lines[j]) || indentLen(lines[j]) > 0) {
+				j++
+			}
+			for j > i && isBlank(lines[j-1]) {
+				j--
+			}
+			code := lines[i:j]
+			unindent(code)
+			out = append(out, block{codeBlock, code})
+			lastWasHeading = false
+			i = j
+			continue
+		}
+
+		if lastWasBlank && !lastWasHeading && i+2 < len(lines) &&
+			isBlank(lines[i+1]) && !isBlank(lines[i+2]) && indentLen(lines[i+2]) == 0 {
+			if head := heading(line); head != "" {
+				closePara()
+				out = append(out, block{headingBlock, []string{head}})
+				lastWasHeading = true
+				i += 2
+				continue
+			}
+		}
+
+		lastWasBlank = false
+		lastWasHeading = false
+		para = append(para, line)
+		i++
+	}
+	closePara()
+	return out
+}
+
+// heading returns line if it is a go/doc heading: it starts with an upper
+// case letter, ends with a letter or digit and contains no punctuation
+// other than parentheses, commas, possessive "'s" and periods which are
+// not followed by a space.
+func heading(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return ""
+	}
+
+	r, _ := utf8.DecodeRuneInString(line)
+	if !unicode.IsLetter(r) || !unicode.IsUpper(r) {
+		return ""
+	}
+	r, _ = utf8.DecodeLastRuneInString(line)
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return ""
+	}
+	if strings.ContainsAny(line, ";:!?+*/=[]{}_^°&§~%#@<\">\\") {
+		return ""
+	}
+
+	for i, c := range line {
+		switch c {
+		case '\'':
+			if !strings.HasPrefix(line[i:], "'s") || (i+2 < len(line) && line[i+2] != ' ') {
+				return ""
+			}
+		case '.':
+			if i+1 >= len(line) || line[i+1] == ' ' {
+				return ""
+			}
+		}
+	}
+	return line
+}
+
+// escape escapes the characters of line which Markdown would interpret.
+func escape(line string) string {
+	var b strings.Builder
+	for i, c := range line {
+		switch {
+		case strings.ContainsRune("\\`*_[]<", c):
+			b.WriteByte('\\')
+		case i == 0 && strings.ContainsRune("#+->", c):
+			b.WriteByte('\\')
+		case (c == '.' || c == ')') && i > 0 && isDigits(line[:i]):
+			// "1. " at the start of a line would begin a list.
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentLen(line string) int {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// unindent removes the longest common indentation of the non-blank lines.
+func unindent(lines []string) {
+	var prefix string
+	first := true
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		indent := line[:indentLen(line)]
+		if first {
+			prefix = indent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	for i, line := range lines {
+		if isBlank(line) {
+			lines[i] = ""
+		} else {
+			lines[i] = line[len(prefix):]
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package markdown
+
+import "testing"
+
+func TestFromDoc(t *testing.T) {
+	for _, test := range []struct {
+		name, doc, markdown string
+	}{
+		{
+			name:     "strings.Fields",
+			doc:      "Fields splits the string s around each instance of one or more consecutive white space\ncharacters, as defined by unicode.IsSpace, returning a slice of substrings of s or an\nempty slice if s contains only white space.\n",
+			markdown: "Fields splits the string s around each instance of one or more consecutive white space\ncharacters, as defined by unicode.IsSpace, returning a slice of substrings of s or an\nempty slice if s contains only white space.",
+		},
+		{
+			name:     "os/signal headings",
+			doc:      "Package signal implements access to incoming signals.\n\nTypes of signals\n\nThe signals SIGKILL and SIGSTOP may not be caught by a program.\n\nSIGPIPE\n\nWhen a Go program writes to a broken pipe, the kernel will raise a\nSIGPIPE signal.\n",
+			markdown: "Package signal implements access to incoming signals.\n\n### Types of signals\n\nThe signals SIGKILL and SIGSTOP may not be caught by a program.\n\n### SIGPIPE\n\nWhen a Go program writes to a broken pipe, the kernel will raise a\nSIGPIPE signal.",
+		},
+		{
+			name:     "sort.Sort example",
+			doc:      "Reverse returns the reverse order for data.\nFor example:\n\n\tsort.Sort(sort.Reverse(data))\n\n\tfor _, v := range data {\n\t\tfmt.Println(v)\n\t}\n\nIt does not copy data.\n",
+			markdown: "Reverse returns the reverse order for data.\nFor example:\n\n```\nsort.Sort(sort.Reverse(data))\n\nfor _, v := range data {\n\tfmt.Println(v)\n}\n```\n\nIt does not copy data.",
+		},
+		{
+			name:     "no heading before code",
+			doc:      "Use it like this.\n\nExample\n\n\tx := f()\n",
+			markdown: "Use it like this.\n\nExample\n\n```\nx := f()\n```",
+		},
+		{
+			name:     "punctuation is no heading",
+			doc:      "Intro.\n\nThis is a sentence.\n\nMore text.\n",
+			markdown: "Intro.\n\nThis is a sentence.\n\nMore text.",
+		},
+		{
+			name:     "escaping",
+			doc:      "Glob matches *.go files in [dir] like a_b.\n# is not a heading\n1. is not a list\n",
+			markdown: "Glob matches \\*.go files in \\[dir\\] like a\\_b.\n\\# is not a heading\n1\\. is not a list",
+		},
+	} {
+		if got := FromDoc(test.doc); got != test.markdown {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.name, got, test.markdown)
+		}
+	}
+}
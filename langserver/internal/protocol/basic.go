@@ -22,3 +22,205 @@ type Command struct {
 	 */
 	Arguments []interface{} `json:"arguments,omitempty"`
 }
+
+/**
+ * Describes the content type that a client supports in various
+ * result literals like `Hover`, `ParameterInfo` or `CompletionItem`.
+ */
+type MarkupKind string
+
+const (
+	/**
+	 * Plain text is supported as a content format
+	 */
+	PlainText MarkupKind = "plaintext"
+
+	/**
+	 * Markdown is supported as a content format
+	 */
+	Markdown MarkupKind = "markdown"
+)
+
+/**
+ * A `MarkupContent` literal represents a string value which content is interpreted base on its
+ * kind flag. Currently the protocol supports `plaintext` and `markdown` as markup kinds.
+ */
+type MarkupContent struct {
+	/**
+	 * The type of the Markup
+	 */
+	Kind MarkupKind `json:"kind"`
+
+	/**
+	 * The content itself
+	 */
+	Value string `json:"value"`
+}
+
+/**
+ * A token used to report progress, either a number or a string.
+ */
+type ProgressToken interface{}
+
+/**
+ * A parameter literal used to pass a partial result token.
+ */
+type PartialResultParams struct {
+	/**
+	 * An optional token that a server can use to report partial results (e.g.
+	 * streaming) to the client.
+	 */
+	PartialResultToken ProgressToken `json:"partialResultToken,omitempty"`
+}
+
+/**
+ * The parameters of a `$/progress` notification.
+ */
+type ProgressParams struct {
+	/**
+	 * The progress token provided by the client or server.
+	 */
+	Token ProgressToken `json:"token"`
+
+	/**
+	 * The progress data.
+	 */
+	Value interface{} `json:"value"`
+}
+
+/**
+ * A parameter literal used to pass a work done progress token.
+ */
+type WorkDoneProgressParams struct {
+	/**
+	 * An optional token that a server can use to report work done progress.
+	 */
+	WorkDoneToken ProgressToken `json:"workDoneToken,omitempty"`
+}
+
+/**
+ * The parameters of a `window/workDoneProgress/create` request.
+ */
+type WorkDoneProgressCreateParams struct {
+	/**
+	 * The token to be used to report progress.
+	 */
+	Token ProgressToken `json:"token"`
+}
+
+/**
+ * The value of the `$/progress` notification beginning a work done
+ * progress.
+ */
+type WorkDoneProgressBegin struct {
+	Kind string `json:"kind"`
+
+	/**
+	 * Mandatory title of the progress operation. Used to briefly inform about
+	 * the kind of operation being performed.
+	 *
+	 * Examples: "Indexing" or "Linking dependencies".
+	 */
+	Title string `json:"title"`
+
+	/**
+	 * Controls if a cancel button should show to allow the user to cancel the
+	 * long running operation. Clients that don't support cancellation are
+	 * allowed to ignore the setting.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message. Contains
+	 * complementary information to the `title`.
+	 *
+	 * Examples: "3/25 files", "project/src/module2", "node_modules/some_dep".
+	 * If unset, the previous progress message (if any) is still valid.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 * If not provided infinite progress is assumed and clients are allowed
+	 * to ignore the `percentage` value in subsequent report notifications.
+	 *
+	 * The value should be steadily rising. Clients are free to ignore values
+	 * that are not following this rule.
+	 */
+	Percentage *uint32 `json:"percentage,omitempty"`
+}
+
+/**
+ * The value of the `$/progress` notifications reporting a work done
+ * progress.
+ */
+type WorkDoneProgressReport struct {
+	Kind string `json:"kind"`
+
+	/**
+	 * Controls enablement state of a cancel button. This property is only
+	 * valid if a cancel button got requested in the `WorkDoneProgressBegin`
+	 * payload.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message. Contains
+	 * complementary information to the `title`.
+	 *
+	 * Examples: "3/25 files", "project/src/module2", "node_modules/some_dep".
+	 * If unset, the previous progress message (if any) is still valid.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 * If not provided infinite progress is assumed and clients are allowed
+	 * to ignore the `percentage` value in subsequent report notifications.
+	 *
+	 * The value should be steadily rising. Clients are free to ignore values
+	 * that are not following this rule.
+	 */
+	Percentage *uint32 `json:"percentage,omitempty"`
+}
+
+/**
+ * The value of the `$/progress` notification ending a work done progress.
+ */
+type WorkDoneProgressEnd struct {
+	Kind string `json:"kind"`
+
+	/**
+	 * Optional, a final message indicating to for example indicate the outcome
+	 * of the operation.
+	 */
+	Message string `json:"message,omitempty"`
+}
+
+/**
+ * General parameters to register for a capability.
+ */
+type Registration struct {
+	/**
+	 * The id used to register the request. The id can be used to deregister
+	 * the request again.
+	 */
+	ID string `json:"id"`
+
+	/**
+	 * The method / capability to register for.
+	 */
+	Method string `json:"method"`
+
+	/**
+	 * Options necessary for the registration.
+	 */
+	RegisterOptions interface{} `json:"registerOptions,omitempty"`
+}
+
+/**
+ * The parameters of a `client/registerCapability` request.
+ */
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
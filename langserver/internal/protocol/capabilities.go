@@ -0,0 +1,352 @@
+package protocol
+
+import "github.com/sourcegraph/go-lsp"
+
+// ClientCapabilities are the client capabilities which are not covered by
+// lsp.ClientCapabilities yet. They are read from the same "capabilities"
+// object of the initialize request.
+type ClientCapabilities struct {
+	/**
+	 * Workspace specific client capabilities.
+	 */
+	Workspace WorkspaceClientCapabilities `json:"workspace,omitempty"`
+
+	/**
+	 * Text document specific client capabilities.
+	 */
+	TextDocument TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+
+	/**
+	 * Window specific client capabilities.
+	 */
+	Window WindowClientCapabilities `json:"window,omitempty"`
+}
+
+/**
+ * Workspace specific client capabilities.
+ */
+type WorkspaceClientCapabilities struct {
+	/**
+	 * Capabilities specific to `WorkspaceEdit`s
+	 */
+	WorkspaceEdit WorkspaceEditClientCapabilities `json:"workspaceEdit,omitempty"`
+
+	/**
+	 * Capabilities specific to the `workspace/didChangeWatchedFiles` notification.
+	 */
+	DidChangeWatchedFiles DidChangeWatchedFilesClientCapabilities `json:"didChangeWatchedFiles,omitempty"`
+
+	/**
+	 * The client supports `workspace/configuration` requests.
+	 */
+	Configuration bool `json:"configuration,omitempty"`
+}
+
+/**
+ * Capabilities specific to `WorkspaceEdit`s
+ */
+type WorkspaceEditClientCapabilities struct {
+	/**
+	 * The client supports versioned document changes in `WorkspaceEdit`s
+	 */
+	DocumentChanges bool `json:"documentChanges,omitempty"`
+}
+
+/**
+ * Capabilities specific to the `workspace/didChangeWatchedFiles` notification.
+ */
+type DidChangeWatchedFilesClientCapabilities struct {
+	/**
+	 * Did change watched files notification supports dynamic registration.
+	 * Please note that the current protocol doesn't support static
+	 * configuration for file changes from the server side.
+	 */
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+}
+
+/**
+ * Window specific client capabilities.
+ */
+type WindowClientCapabilities struct {
+	/**
+	 * Whether client supports handling progress notifications. If set
+	 * servers are allowed to report in `workDoneProgress` property in the
+	 * request specific server capabilities.
+	 */
+	WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+}
+
+/**
+ * Text document specific client capabilities.
+ */
+type TextDocumentClientCapabilities struct {
+	/**
+	 * Capabilities specific to the `textDocument/definition` request.
+	 */
+	Definition LinkClientCapabilities `json:"definition,omitempty"`
+
+	/**
+	 * Capabilities specific to the `textDocument/declaration` request.
+	 */
+	Declaration LinkClientCapabilities `json:"declaration,omitempty"`
+
+	/**
+	 * Capabilities specific to the `textDocument/typeDefinition` request.
+	 */
+	TypeDefinition LinkClientCapabilities `json:"typeDefinition,omitempty"`
+
+	/**
+	 * Capabilities specific to the `textDocument/hover` request.
+	 */
+	Hover HoverClientCapabilities `json:"hover,omitempty"`
+
+	/**
+	 * Capabilities specific to the `textDocument/rename` request.
+	 */
+	Rename RenameClientCapabilities `json:"rename,omitempty"`
+
+	/**
+	 * Capabilities specific to `textDocument/publishDiagnostics`.
+	 */
+	PublishDiagnostics PublishDiagnosticsClientCapabilities `json:"publishDiagnostics,omitempty"`
+
+	/**
+	 * Capabilities specific to the `textDocument/documentSymbol` request.
+	 */
+	DocumentSymbol DocumentSymbolClientCapabilities `json:"documentSymbol,omitempty"`
+
+	/**
+	 * Capabilities specific to the various semantic token requests. It is
+	 * nil if the client does not support them.
+	 */
+	SemanticTokens *SemanticTokensClientCapabilities `json:"semanticTokens,omitempty"`
+}
+
+/**
+ * Capabilities of requests which may return `LocationLink`s.
+ */
+type LinkClientCapabilities struct {
+	/**
+	 * Whether the request supports dynamic registration.
+	 */
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+
+	/**
+	 * The client supports additional metadata in the form of links.
+	 */
+	LinkSupport bool `json:"linkSupport,omitempty"`
+}
+
+/**
+ * Capabilities specific to the `textDocument/hover` request.
+ */
+type HoverClientCapabilities struct {
+	/**
+	 * Whether hover supports dynamic registration.
+	 */
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+
+	/**
+	 * Client supports the follow content formats for the content
+	 * property. The order describes the preferred format of the client.
+	 */
+	ContentFormat []MarkupKind `json:"contentFormat,omitempty"`
+}
+
+/**
+ * Capabilities specific to the `textDocument/rename` request.
+ */
+type RenameClientCapabilities struct {
+	/**
+	 * Whether rename supports dynamic registration.
+	 */
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+
+	/**
+	 * Client supports testing for validity of rename operations
+	 * before execution.
+	 */
+	PrepareSupport bool `json:"prepareSupport,omitempty"`
+}
+
+/**
+ * Capabilities specific to `textDocument/publishDiagnostics`.
+ */
+type PublishDiagnosticsClientCapabilities struct {
+	/**
+	 * Whether the clients accepts diagnostics with related information.
+	 */
+	RelatedInformation bool `json:"relatedInformation,omitempty"`
+}
+
+/**
+ * Capabilities specific to the `textDocument/documentSymbol` request.
+ */
+type DocumentSymbolClientCapabilities struct {
+	/**
+	 * The client supports hierarchical document symbols.
+	 */
+	HierarchicalDocumentSymbolSupport bool `json:"hierarchicalDocumentSymbolSupport,omitempty"`
+}
+
+/**
+ * Capabilities specific to the various semantic token requests.
+ */
+type SemanticTokensClientCapabilities struct {
+	/**
+	 * Whether implementation supports dynamic registration.
+	 */
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+
+	/**
+	 * The token types that the client supports.
+	 */
+	TokenTypes []string `json:"tokenTypes,omitempty"`
+
+	/**
+	 * The token modifiers that the client supports.
+	 */
+	TokenModifiers []string `json:"tokenModifiers,omitempty"`
+
+	/**
+	 * The formats the clients supports.
+	 */
+	Formats []string `json:"formats,omitempty"`
+}
+
+// InitializeResult is lsp.InitializeResult with the server capabilities
+// which are not covered by lsp.ServerCapabilities yet.
+type InitializeResult struct {
+	/**
+	 * The capabilities the language server provides.
+	 */
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
+// ServerCapabilities are lsp.ServerCapabilities extended by the
+// capabilities which are not covered by it yet.
+type ServerCapabilities struct {
+	lsp.ServerCapabilities
+
+	/**
+	 * The server provides go to declaration support.
+	 */
+	DeclarationProvider bool `json:"declarationProvider,omitempty"`
+
+	/**
+	 * The server provides rename support. RenameOptions may only be
+	 * specified if the client states that it supports
+	 * `prepareSupport` in its initial `initialize` request.
+	 *
+	 * It is either a bool or RenameOptions, and overrides the
+	 * RenameProvider of lsp.ServerCapabilities.
+	 */
+	RenameProvider interface{} `json:"renameProvider,omitempty"`
+
+	/**
+	 * The server provides document formatting on typing.
+	 */
+	DocumentOnTypeFormattingProvider *DocumentOnTypeFormattingOptions `json:"documentOnTypeFormattingProvider,omitempty"`
+
+	/**
+	 * The server provides call hierarchy support.
+	 */
+	CallHierarchyProvider bool `json:"callHierarchyProvider,omitempty"`
+
+	/**
+	 * The server provides folding provider support.
+	 */
+	FoldingRangeProvider bool `json:"foldingRangeProvider,omitempty"`
+
+	/**
+	 * The server provides selection range support.
+	 */
+	SelectionRangeProvider bool `json:"selectionRangeProvider,omitempty"`
+
+	/**
+	 * The server provides semantic tokens support.
+	 */
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+
+	/**
+	 * The server provides document link support.
+	 */
+	DocumentLinkProvider *DocumentLinkOptions `json:"documentLinkProvider,omitempty"`
+
+	/**
+	 * The server provides linked editing range support.
+	 */
+	LinkedEditingRangeProvider bool `json:"linkedEditingRangeProvider,omitempty"`
+}
+
+/**
+ * Format document on type options
+ */
+type DocumentOnTypeFormattingOptions struct {
+	/**
+	 * A character on which formatting should be triggered, like `}`.
+	 */
+	FirstTriggerCharacter string `json:"firstTriggerCharacter"`
+
+	/**
+	 * More trigger characters.
+	 */
+	MoreTriggerCharacter []string `json:"moreTriggerCharacter,omitempty"`
+}
+
+/**
+ * Rename options
+ */
+type RenameOptions struct {
+	/**
+	 * Renames should be checked and tested before being executed.
+	 */
+	PrepareProvider bool `json:"prepareProvider,omitempty"`
+}
+
+/**
+ * Document link options
+ */
+type DocumentLinkOptions struct {
+	/**
+	 * Document links have a resolve provider as well.
+	 */
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+/**
+ * The legend of the semantic tokens, whose types and modifiers are indexes
+ * of its token types and bits of its token modifiers.
+ */
+type SemanticTokensLegend struct {
+	/**
+	 * The token types a server uses.
+	 */
+	TokenTypes []string `json:"tokenTypes"`
+
+	/**
+	 * The token modifiers a server uses.
+	 */
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+/**
+ * Semantic tokens options
+ */
+type SemanticTokensOptions struct {
+	/**
+	 * The legend used by the server
+	 */
+	Legend SemanticTokensLegend `json:"legend"`
+
+	/**
+	 * Server supports providing semantic tokens for a specific range
+	 * of a document.
+	 */
+	Range bool `json:"range,omitempty"`
+
+	/**
+	 * Server supports providing semantic tokens for a full document.
+	 */
+	Full bool `json:"full,omitempty"`
+}
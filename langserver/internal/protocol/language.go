@@ -108,7 +108,7 @@ type CodeAction struct {
 	/**
 	 * The workspace edit this code action performs.
 	 */
-	Edit lsp.WorkspaceEdit `json:"edit,omitempty"`
+	Edit WorkspaceEdit `json:"edit,omitempty"`
 
 	/**
 	 * A command this code action executes. If a code action
@@ -117,3 +117,568 @@ type CodeAction struct {
 	 */
 	Command Command `json:"command,omitempty"`
 }
+
+/**
+ * Represents the connection of two locations. Provides additional metadata over normal locations,
+ * including an origin range.
+ */
+type LocationLink struct {
+
+	/**
+	 * Span of the origin of this link.
+	 *
+	 * Used as the underlined span for mouse definition hover. Defaults to the word range at
+	 * the definition position.
+	 */
+	OriginSelectionRange *lsp.Range `json:"originSelectionRange,omitempty"`
+
+	/**
+	 * The target resource identifier of this link.
+	 */
+	TargetURI lsp.DocumentURI `json:"targetUri"`
+
+	/**
+	 * The full target range of this link. If the target for example is a symbol then target range is the
+	 * range enclosing this symbol not including leading/trailing whitespace but everything else
+	 * like comments. This information is typically used to highlight the range in the editor.
+	 */
+	TargetRange lsp.Range `json:"targetRange"`
+
+	/**
+	 * The range that should be selected and revealed when this link is being followed, e.g the name of a function.
+	 * Must be contained by the the `targetRange`. See also `DocumentSymbol#range`
+	 */
+	TargetSelectionRange lsp.Range `json:"targetSelectionRange"`
+}
+
+/**
+ * The result of a hover request.
+ */
+type Hover struct {
+	/**
+	 * The hover's content
+	 */
+	Contents MarkupContent `json:"contents"`
+
+	/**
+	 * An optional range is a range inside a text document
+	 * that is used to visualize a hover, e.g. by changing the background color.
+	 */
+	Range *lsp.Range `json:"range,omitempty"`
+}
+
+/**
+ * A document highlight kind.
+ */
+type DocumentHighlightKind int
+
+const (
+	/**
+	 * A textual occurrence.
+	 */
+	HighlightText DocumentHighlightKind = 1
+
+	/**
+	 * Read-access of a symbol, like reading a variable.
+	 */
+	HighlightRead DocumentHighlightKind = 2
+
+	/**
+	 * Write-access of a symbol, like writing to a variable.
+	 */
+	HighlightWrite DocumentHighlightKind = 3
+)
+
+/**
+ * A document highlight is a range inside a text document which deserves
+ * special attention. Usually a document highlight is visualized by changing
+ * the background color of its range.
+ */
+type DocumentHighlight struct {
+	/**
+	 * The range this highlight applies to.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The highlight kind, default is DocumentHighlightKind.Text.
+	 */
+	Kind DocumentHighlightKind `json:"kind,omitempty"`
+}
+
+/**
+ * The result of a `textDocument/prepareRename` request.
+ */
+type PrepareRenameResult struct {
+	/**
+	 * The range of the string to rename.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * A placeholder text of the string content to be renamed.
+	 */
+	Placeholder string `json:"placeholder"`
+}
+
+/**
+ * A completion item, along with the fields missing from lsp.CompletionItem.
+ */
+type CompletionItem struct {
+	lsp.CompletionItem
+
+	/**
+	 * An optional array of additional text edits that are applied when
+	 * selecting this completion. Edits must not overlap (including the same insert position)
+	 * with the main edit nor with themselves.
+	 *
+	 * Additional text edits should be used to change text unrelated to the current cursor position
+	 * (for example adding an import statement at the top of the file if the completion item will
+	 * insert an unqualified type).
+	 */
+	AdditionalTextEdits []lsp.TextEdit `json:"additionalTextEdits,omitempty"`
+}
+
+/**
+ * Represents a collection of completion items to be presented
+ * in the editor.
+ */
+type CompletionList struct {
+	/**
+	 * This list it not complete. Further typing should result in recomputing
+	 * this list.
+	 */
+	IsIncomplete bool `json:"isIncomplete"`
+
+	/**
+	 * The completion items.
+	 */
+	Items []CompletionItem `json:"items"`
+}
+
+/**
+ * Represents a related message and source code location for a diagnostic. This should be
+ * used to point to code locations that cause or related to a diagnostics, e.g when duplicating
+ * a symbol in a scope.
+ */
+type DiagnosticRelatedInformation struct {
+	/**
+	 * The location of this related diagnostic information.
+	 */
+	Location lsp.Location `json:"location"`
+
+	/**
+	 * The message of this related diagnostic information.
+	 */
+	Message string `json:"message"`
+}
+
+/**
+ * A diagnostic, along with the fields missing from lsp.Diagnostic.
+ */
+type Diagnostic struct {
+	lsp.Diagnostic
+
+	/**
+	 * An array of related diagnostic information, e.g. when symbol-names within
+	 * a scope collide all definitions can be marked via this property.
+	 */
+	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+/**
+ * The parameters of a `textDocument/publishDiagnostics` notification.
+ */
+type PublishDiagnosticsParams struct {
+	/**
+	 * The URI for which diagnostic information is reported.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * An array of diagnostic information items.
+	 */
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+/**
+ * Represents programming constructs like variables, classes, interfaces etc.
+ * that appear in a document. Document symbols can be hierarchical and they
+ * have two ranges: one that encloses its definition and one that points to
+ * its most interesting range, e.g. the range of an identifier.
+ */
+type DocumentSymbol struct {
+	/**
+	 * The name of this symbol.
+	 */
+	Name string `json:"name"`
+
+	/**
+	 * More detail for this symbol, e.g the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+
+	/**
+	 * The kind of this symbol.
+	 */
+	Kind lsp.SymbolKind `json:"kind"`
+
+	/**
+	 * Indicates if this symbol is deprecated.
+	 */
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	/**
+	 * The range enclosing this symbol not including leading/trailing whitespace
+	 * but everything else like comments. This information is typically used to
+	 * determine if the clients cursor is inside the symbol to reveal in the
+	 * symbol in the UI.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The range that should be selected and revealed when this symbol is being
+	 * picked, e.g the name of a function. Must be contained by the `range`.
+	 */
+	SelectionRange lsp.Range `json:"selectionRange"`
+
+	/**
+	 * Children of this symbol, e.g. properties of a class.
+	 */
+	Children []DocumentSymbol `json:"children,omitempty"`
+}
+
+/**
+ * The parameters of a `textDocument/onTypeFormatting` request.
+ */
+type DocumentOnTypeFormattingParams struct {
+	/**
+	 * The document to format.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The position at which this request was sent.
+	 */
+	Position lsp.Position `json:"position"`
+
+	/**
+	 * The character that has been typed.
+	 */
+	Ch string `json:"ch"`
+
+	/**
+	 * The format options.
+	 */
+	Options lsp.FormattingOptions `json:"options"`
+}
+
+/**
+ * A code lens represents a command that should be shown along with
+ * source text, like the number of references, a way to run tests, etc.
+ *
+ * A code lens is _unresolved_ when no command is associated to it. For performance
+ * reasons the creation of a code lens and resolving should be done in two stages.
+ *
+ * It is lsp.CodeLens whose command is optional.
+ */
+type CodeLens struct {
+	/**
+	 * The range in which this code lens is valid. Should only span a single line.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The command this code lens represents.
+	 */
+	Command *lsp.Command `json:"command,omitempty"`
+
+	/**
+	 * A data entry field that is preserved on a code lens item between
+	 * a code lens and a code lens resolve request.
+	 */
+	Data interface{} `json:"data,omitempty"`
+}
+
+/**
+ * Represents programming constructs like functions or constructors in the
+ * context of call hierarchy.
+ */
+type CallHierarchyItem struct {
+	/**
+	 * The name of this item.
+	 */
+	Name string `json:"name"`
+
+	/**
+	 * The kind of this item.
+	 */
+	Kind lsp.SymbolKind `json:"kind"`
+
+	/**
+	 * More detail for this item, e.g. the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+
+	/**
+	 * The resource identifier of this item.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The range enclosing this symbol not including leading/trailing whitespace
+	 * but everything else, e.g. comments and code.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The range that should be selected and revealed when this symbol is being
+	 * picked, e.g. the name of a function. Must be contained by the `range`.
+	 */
+	SelectionRange lsp.Range `json:"selectionRange"`
+
+	/**
+	 * A data entry field that is preserved between a call hierarchy prepare
+	 * and incoming calls or outgoing calls requests.
+	 */
+	Data interface{} `json:"data,omitempty"`
+}
+
+/**
+ * The parameter of a `callHierarchy/incomingCalls` request.
+ */
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+/**
+ * Represents an incoming call, e.g. a caller of a method or constructor.
+ */
+type CallHierarchyIncomingCall struct {
+	/**
+	 * The item that makes the call.
+	 */
+	From CallHierarchyItem `json:"from"`
+
+	/**
+	 * The ranges at which the calls appear. This is relative to the caller
+	 * denoted by `this.from`.
+	 */
+	FromRanges []lsp.Range `json:"fromRanges"`
+}
+
+/**
+ * The parameter of a `callHierarchy/outgoingCalls` request.
+ */
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+/**
+ * Represents an outgoing call, e.g. calling a getter from a method or a
+ * method from a constructor etc.
+ */
+type CallHierarchyOutgoingCall struct {
+	/**
+	 * The item that is called.
+	 */
+	To CallHierarchyItem `json:"to"`
+
+	/**
+	 * The range at which this item is called. This is the range relative to
+	 * the caller, e.g the item passed to `callHierarchy/outgoingCalls` request.
+	 */
+	FromRanges []lsp.Range `json:"fromRanges"`
+}
+
+/**
+ * The parameters of a `textDocument/foldingRange` request.
+ */
+type FoldingRangeParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+/**
+ * Enum of known range kinds
+ */
+type FoldingRangeKind string
+
+const (
+	/**
+	 * Folding range for a comment
+	 */
+	CommentFoldingRange FoldingRangeKind = "comment"
+
+	/**
+	 * Folding range for a imports or includes
+	 */
+	ImportsFoldingRange FoldingRangeKind = "imports"
+
+	/**
+	 * Folding range for a region (e.g. `#region`)
+	 */
+	RegionFoldingRange FoldingRangeKind = "region"
+)
+
+/**
+ * Represents a folding range.
+ */
+type FoldingRange struct {
+	/**
+	 * The zero-based line number from where the folded range starts.
+	 */
+	StartLine int `json:"startLine"`
+
+	/**
+	 * The zero-based character offset from where the folded range starts.
+	 * If not defined, defaults to the length of the start line.
+	 */
+	StartCharacter *int `json:"startCharacter,omitempty"`
+
+	/**
+	 * The zero-based line number where the folded range ends.
+	 */
+	EndLine int `json:"endLine"`
+
+	/**
+	 * The zero-based character offset before the folded range ends. If not
+	 * defined, defaults to the length of the end line.
+	 */
+	EndCharacter *int `json:"endCharacter,omitempty"`
+
+	/**
+	 * Describes the kind of the folding range such as `comment` or
+	 * `region`. The kind is used to categorize folding ranges and used by
+	 * commands like 'Fold all comments'.
+	 */
+	Kind FoldingRangeKind `json:"kind,omitempty"`
+}
+
+/**
+ * The parameters of a `textDocument/selectionRange` request.
+ */
+type SelectionRangeParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The positions inside the text document.
+	 */
+	Positions []lsp.Position `json:"positions"`
+}
+
+/**
+ * A selection range represents a part of a selection hierarchy. A selection
+ * range may have a parent selection range that contains it.
+ */
+type SelectionRange struct {
+	/**
+	 * The range of this selection range.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The parent selection range containing this range. Therefore
+	 * `parent.range` must contain `this.range`.
+	 */
+	Parent *SelectionRange `json:"parent,omitempty"`
+}
+
+/**
+ * The parameters of a `textDocument/semanticTokens/full` request.
+ */
+type SemanticTokensParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+/**
+ * The parameters of a `textDocument/semanticTokens/range` request.
+ */
+type SemanticTokensRangeParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The range the semantic tokens are requested for.
+	 */
+	Range lsp.Range `json:"range"`
+}
+
+/**
+ * The semantic tokens of a document or of a range of it.
+ */
+type SemanticTokens struct {
+	/**
+	 * An optional result id.
+	 */
+	ResultID string `json:"resultId,omitempty"`
+
+	/**
+	 * The actual tokens, five integers per token: the delta of its line,
+	 * the delta of its start character, its length, its type and its
+	 * modifiers.
+	 */
+	Data []uint32 `json:"data"`
+}
+
+/**
+ * The parameters of a `textDocument/documentLink` request.
+ */
+type DocumentLinkParams struct {
+	/**
+	 * The document to provide document links for.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+/**
+ * A document link is a range in a text document that links to an internal
+ * or external resource, like another text document or a web site.
+ */
+type DocumentLink struct {
+	/**
+	 * The range this link applies to.
+	 */
+	Range lsp.Range `json:"range"`
+
+	/**
+	 * The uri this link points to. If missing a resolve request is sent
+	 * later.
+	 */
+	Target string `json:"target,omitempty"`
+
+	/**
+	 * A data entry field that is preserved on a document link between a
+	 * DocumentLinkRequest and a DocumentLinkResolveRequest.
+	 */
+	Data interface{} `json:"data,omitempty"`
+}
+
+/**
+ * The result of a linked editing range request.
+ */
+type LinkedEditingRanges struct {
+	/**
+	 * A list of ranges that can be edited together. The ranges must have
+	 * identical length and contain identical text content. The ranges
+	 * cannot overlap.
+	 */
+	Ranges []lsp.Range `json:"ranges"`
+
+	/**
+	 * An optional word pattern (regular expression) that describes valid
+	 * contents for the given ranges. If no pattern is provided, the client
+	 * configuration's word pattern will be used.
+	 */
+	WordPattern string `json:"wordPattern,omitempty"`
+}
@@ -0,0 +1,43 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Represents reasons why a text document is saved.
+ */
+type TextDocumentSaveReason int
+
+const (
+	/**
+	 * Manually triggered, e.g. by the user pressing save, by starting debugging,
+	 * or by an API call.
+	 */
+	Manual TextDocumentSaveReason = 1
+
+	/**
+	 * Automatic after a delay.
+	 */
+	AfterDelay TextDocumentSaveReason = 2
+
+	/**
+	 * When the editor lost focus.
+	 */
+	FocusOut TextDocumentSaveReason = 3
+)
+
+/**
+ * The parameters send in a will save text document notification.
+ */
+type WillSaveTextDocumentParams struct {
+	/**
+	 * The document that will be saved.
+	 */
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The 'TextDocumentSaveReason'.
+	 */
+	Reason TextDocumentSaveReason `json:"reason"`
+}
@@ -0,0 +1,193 @@
+package protocol
+
+import "github.com/sourcegraph/go-lsp"
+
+/**
+ * A workspace edit represents changes to many resources managed in the workspace. The edit
+ * should either provide `changes` or `documentChanges`. If the client can handle versioned document
+ * edits and if `documentChanges` are present, the latter are preferred over `changes`.
+ */
+type WorkspaceEdit struct {
+	/**
+	 * Holds changes to existing resources.
+	 */
+	Changes map[string][]lsp.TextEdit `json:"changes,omitempty"`
+
+	/**
+	 * An array of `TextDocumentEdit`s to express changes to n different text documents
+	 * where each text document edit addresses a specific version of a text document.
+	 *
+	 * Whether a client supports versioned document edits is expressed via
+	 * `WorkspaceClientCapabilities.workspaceEdit.documentChanges`.
+	 */
+	DocumentChanges []TextDocumentEdit `json:"documentChanges,omitempty"`
+}
+
+/**
+ * Describes textual changes on a single text document. The text document is referred to as a
+ * `VersionedTextDocumentIdentifier` to allow clients to check the text document version before an
+ * edit is applied.
+ */
+type TextDocumentEdit struct {
+	/**
+	 * The text document to change.
+	 */
+	TextDocument VersionedTextDocumentIdentifier `json:"textDocument"`
+
+	/**
+	 * The edits to be applied.
+	 */
+	Edits []lsp.TextEdit `json:"edits"`
+}
+
+/**
+ * An identifier to denote a specific version of a text document.
+ */
+type VersionedTextDocumentIdentifier struct {
+	/**
+	 * The text document's URI.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The version number of this document. If a versioned text document identifier
+	 * is sent from the server to the client and the file is not open in the editor
+	 * (the server has not received an open notification before) the server can send
+	 * `null` to indicate that the version is known and the content on disk is the
+	 * truth (as speced with document content ownership).
+	 */
+	Version *int `json:"version"`
+}
+
+/**
+ * The file event type.
+ */
+type FileChangeType int
+
+const (
+	/**
+	 * The file got created.
+	 */
+	Created FileChangeType = 1
+
+	/**
+	 * The file got changed.
+	 */
+	Changed FileChangeType = 2
+
+	/**
+	 * The file got deleted.
+	 */
+	Deleted FileChangeType = 3
+)
+
+/**
+ * An event describing a file change.
+ */
+type FileEvent struct {
+	/**
+	 * The file's URI.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The change type.
+	 */
+	Type FileChangeType `json:"type"`
+}
+
+/**
+ * The watched files change notification's parameters.
+ */
+type DidChangeWatchedFilesParams struct {
+	/**
+	 * The actual file events.
+	 */
+	Changes []FileEvent `json:"changes"`
+}
+
+/**
+ * Describe options to be used when registering for file system change events.
+ */
+type DidChangeWatchedFilesRegistrationOptions struct {
+	/**
+	 * The watchers to register.
+	 */
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+/**
+ * A watcher of the files matching a glob pattern.
+ */
+type FileSystemWatcher struct {
+	/**
+	 * The glob pattern to watch.
+	 *
+	 * Glob patterns can have the following syntax:
+	 * - `*` to match one or more characters in a path segment
+	 * - `?` to match on one character in a path segment
+	 * - `**` to match any number of path segments, including none
+	 * - `{}` to group conditions (e.g. `*.{ts,js}` matches the TypeScript and JavaScript files)
+	 * - `[]` to declare a range of characters to match in a path segment (e.g., `example.[0-9]` to match on `example.0`, `example.1`, …)
+	 * - `[!...]` to negate a range of characters to match in a path segment (e.g., `example.[!0-9]` to match on `example.a`, `example.b`, but not `example.0`)
+	 */
+	GlobPattern string `json:"globPattern"`
+
+	/**
+	 * The kind of events of interest. If omitted it defaults
+	 * to WatchKind.Create | WatchKind.Change | WatchKind.Delete
+	 * which is 7.
+	 */
+	Kind WatchKind `json:"kind,omitempty"`
+}
+
+/**
+ * The kind of events of interest of a file system watcher.
+ */
+type WatchKind int
+
+const (
+	/**
+	 * Interested in create events.
+	 */
+	WatchCreate WatchKind = 1
+
+	/**
+	 * Interested in change events
+	 */
+	WatchChange WatchKind = 2
+
+	/**
+	 * Interested in delete events
+	 */
+	WatchDelete WatchKind = 4
+)
+
+/**
+ * The parameters of a `workspace/didChangeConfiguration` notification.
+ */
+type DidChangeConfigurationParams struct {
+	/**
+	 * The actual changed settings
+	 */
+	Settings interface{} `json:"settings"`
+}
+
+/**
+ * The parameters of a `workspace/configuration` request.
+ */
+type ConfigurationParams struct {
+	Items []ConfigurationItem `json:"items"`
+}
+
+type ConfigurationItem struct {
+	/**
+	 * The scope to get the configuration section for.
+	 */
+	ScopeURI lsp.DocumentURI `json:"scopeUri,omitempty"`
+
+	/**
+	 * The configuration section asked for.
+	 */
+	Section string `json:"section,omitempty"`
+}
@@ -239,6 +239,9 @@ func DefInfo(pkg *types.Package, info *types.Info, nodes []ast.Node, pos token.P
 			return objectString(obj), nil
 		} else if types.Universe.Lookup(identX.Name) == obj {
 			return &Def{ImportPath: "builtin", PackageName: "builtin", Path: obj.Name()}, nil
+		} else if obj.Pkg() != nil && obj.Pkg() != pkg && obj.Pkg().Scope().Lookup(obj.Name()) == obj {
+			// Top-level definition of a dot-imported package.
+			return objectString(obj), nil
 		}
 		t := dereferenceType(obj.Type())
 		if pkg, pkgName, name, ok := typeName(t); ok {
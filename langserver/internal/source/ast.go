@@ -57,26 +57,24 @@ type dereferencable interface {
 	Elem() types.Type
 }
 
-// TypeLookup looks for a named type, but will search through
-// any number of type qualifiers (chan/array/slice/pointer)
-// which have an unambiguous base type. If no named type is
-// found, we are not interested, because this is only used
-// for finding a type's definition.
-func TypeLookup(typ types.Type) *types.TypeName {
+// TypeLookup looks for the named types of typ, searching through any
+// number of type qualifiers (chan/array/slice/pointer/map). A map yields
+// the named types of both its key and its element. If no named type is
+// found, we are not interested, because this is only used for finding a
+// type's definition.
+func TypeLookup(typ types.Type) []*types.TypeName {
 	if typ == nil {
 		return nil
 	}
-	for {
-		switch t := typ.(type) {
-		case *types.Named:
-			return t.Obj()
-		case *types.Map:
-			return nil
-		case dereferencable:
-			typ = t.Elem()
-		default:
-			return nil
-		}
+	switch t := typ.(type) {
+	case *types.Named:
+		return []*types.TypeName{t.Obj()}
+	case *types.Map:
+		return append(TypeLookup(t.Key()), TypeLookup(t.Elem())...)
+	case dereferencable:
+		return TypeLookup(t.Elem())
+	default:
+		return nil
 	}
 }
 
@@ -155,9 +153,24 @@ func GetSyntaxFile(pkg Package, filename string) *ast.File {
 		}
 	}
 
+	// Files importing "C" are compiled from the output of cgo, which
+	// refers back to the original source by //line directives.
+	for _, f := range pkg.GetSyntax() {
+		if util.PathEqual(OriginalFilename(pkg.GetFileSet(), f), filename) {
+			return f
+		}
+	}
+
 	return nil
 }
 
+// OriginalFilename returns the name of the source file f was generated
+// from, according to its //line directives. For most files this is the
+// name of the file itself.
+func OriginalFilename(fset *token.FileSet, f *ast.File) string {
+	return fset.Position(f.Package).Filename
+}
+
 func FindIdentObject(pkg Package, ident *ast.Ident) types.Object {
 	return pkg.GetTypesInfo().ObjectOf(ident)
 }
@@ -179,6 +192,10 @@ func CallExpr(fset *token.FileSet, nodes []ast.Node) *ast.CallExpr {
 
 // FindObject find object
 func FindObject(pkg Package, o types.Object) types.Object {
+	if obj := pkg.GetTypes().Scope().Lookup(o.Name()); obj != nil {
+		return obj
+	}
+
 	for _, def := range pkg.GetTypesInfo().Defs {
 		if def == nil {
 			continue
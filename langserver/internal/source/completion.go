@@ -8,9 +8,11 @@ import (
 	"go/token"
 	"go/types"
 	"log"
+	"regexp"
 	"strings"
 	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/util"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
@@ -19,6 +21,27 @@ type CompletionItem struct {
 	Kind          CompletionItemKind
 	Score         float64
 	Documentation string
+
+	// Locality tells how close to the completed position the item is
+	// declared.
+	Locality Locality
+
+	// InsertText is the text inserted by the item, if it is not its name.
+	InsertText string
+
+	// Snippet is the text inserted by the item instead of InsertText for
+	// the clients supporting snippets, if any.
+	Snippet string
+
+	// ImportPath is the path of the package of a member completed after
+	// the name of a package the file may not import yet.
+	ImportPath string
+
+	// Object is the object completed by the item, if any, declared at
+	// Declaration. They tell ResolveCompletionItem which object to
+	// document, since documenting all the items would be too slow.
+	Object      types.Object
+	Declaration token.Position
 }
 
 type CompletionItemKind int
@@ -35,11 +58,27 @@ const (
 	FunctionCompletionItem
 	MethodCompletionItem
 	PackageCompletionItem
+	KeywordCompletionItem
+)
+
+// Locality ranks the declarations of completion items by their distance
+// to the completed position, the closest first.
+type Locality int
+
+const (
+	FunctionLocality Locality = iota // declared in the enclosing function
+	FileLocality                     // declared in the file
+	PackageLocality                  // declared in the package
+	ImportedLocality                 // declared in another package
+	UniverseLocality                 // predeclared, or a keyword
 )
 
 // stdScore is the base score value set for all completion items.
 const stdScore float64 = 1.0
 
+// keywordScore is the score of keywords, ranked below all identifiers.
+const keywordScore float64 = stdScore * 0.01
+
 // finder is a function used to record a completion candidate item in a list of
 // completion items.
 type finder func(types.Object, float64, []CompletionItem) []CompletionItem
@@ -50,6 +89,14 @@ type finder func(types.Object, float64, []CompletionItem) []CompletionItem
 // completion. For instance, some clients may tolerate imperfect matches as
 // valid completion results, since users may make typos.
 func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items []CompletionItem, prefix string, err error) {
+	items, prefix, err = completion(ctx, f, pos, cache)
+	if err != nil {
+		return nil, "", err
+	}
+	return filterByContext(items, pos, f.GetPackage(ctx), f.GetToken(ctx), f.GetContent(ctx)), prefix, nil
+}
+
+func completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items []CompletionItem, prefix string, err error) {
 	file := f.GetAST(ctx)
 	pkg := f.GetPackage(ctx)
 	if pkg.IsIllTyped() {
@@ -113,19 +160,19 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 			item := formatCompletion(obj, pkgStringer, weight, func(v *types.Var) bool {
 				return isParameter(sig, v)
 			})
-
-			// TODO(mbana): figure out how to get `golang.org/x/tools/go/packages.Packages` from `go/types.Package`.
-			// pkg, ok := obj.Pkg().(pkg.GetTypes())
-			ok := true
-			if ok {
-				// FindComments(obj.Pkg(), obj, "")
-			}
+			item.Locality = locality(obj, file, pkg.GetTypes())
+			item.Object, item.Declaration = obj, pkg.GetFileSet().Position(obj.Pos())
 
 			items = append(items, item)
 		}
 		return items
 	}
 
+	// The position follows the receiver of a method declaration.
+	if items, prefix, ok := methodStubs(pos, pkg, file, f.GetToken(ctx), f.GetContent(ctx)); ok {
+		return items, prefix, nil
+	}
+
 	// The position is within a composite literal.
 	if items, prefix, ok := complit(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache); ok {
 		return items, prefix, nil
@@ -137,7 +184,7 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 
 		// Is this the Sel part of a selector?
 		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == n {
-			items, err = selector(sel, pos, pkg.GetTypesInfo(), found, cache)
+			items, err = selector(sel, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cache)
 			return items, prefix, err
 		}
 		// reject defining identifiers
@@ -155,29 +202,53 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 		}
 
 		items = append(items, lexical(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache)...)
+		items = append(items, deepCompletions(items, typ, pkg.GetTypes(), pkg.GetFileSet(), pkgStringer)...)
+		items = append(items, enumConstants(typ, pkg.GetTypes(), pkg.GetFileSet(), pkgStringer)...)
+		items = append(items, keywords(path, pos, f.GetToken(ctx), f.GetContent(ctx))...)
 
 	// The function name hasn't been typed yet, but the parens are there:
 	//   recv.‸(arg)
 	case *ast.TypeAssertExpr:
 		// Create a fake selector expression.
-		items, err = selector(&ast.SelectorExpr{X: n.X}, pos, pkg.GetTypesInfo(), found, cache)
+		items, err = selector(&ast.SelectorExpr{X: n.X}, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cache)
 		return items, prefix, err
 
 	case *ast.SelectorExpr:
-		items, err = selector(n, pos, pkg.GetTypesInfo(), found, cache)
+		items, err = selector(n, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cache)
 		return items, prefix, err
 
 	default:
 		// fallback to lexical completions
-		return lexical(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache), getPrefix(cursorIdent), nil
+		items = lexical(path, pos, pkg.GetTypes(), pkg.GetTypesInfo(), found, cursorIdent, cache)
+		items = append(items, deepCompletions(items, typ, pkg.GetTypes(), pkg.GetFileSet(), pkgStringer)...)
+		items = append(items, enumConstants(typ, pkg.GetTypes(), pkg.GetFileSet(), pkgStringer)...)
+		items = append(items, keywords(path, pos, f.GetToken(ctx), f.GetContent(ctx))...)
+		return items, getPrefix(cursorIdent), nil
 	}
 	return items, prefix, nil
 }
 
+// locality returns the locality of the declaration of obj, relative to a
+// position of file, in the package pkg.
+func locality(obj types.Object, file *ast.File, pkg *types.Package) Locality {
+	switch {
+	case obj.Pkg() == nil:
+		return UniverseLocality
+	case obj.Pkg() != pkg:
+		return ImportedLocality
+	case obj.Parent() != nil && obj.Parent() != pkg.Scope() && obj.Parent().Parent() != pkg.Scope():
+		// The scopes of the functions are children of the file scopes.
+		return FunctionLocality
+	case file.Pos() <= obj.Pos() && obj.Pos() <= file.End():
+		return FileLocality
+	}
+	return PackageLocality
+}
+
 // selector finds completions for
 // the specified selector expression.
 // TODO(rstambler): Set the prefix filter correctly for selectors.
-func selector(sel *ast.SelectorExpr, pos token.Pos, info *types.Info, found finder, cache Cache) (items []CompletionItem, err error) {
+func selector(sel *ast.SelectorExpr, pos token.Pos, pkg *types.Package, info *types.Info, found finder, cache Cache) (items []CompletionItem, err error) {
 	// Is sel a qualified identifier?
 	if id, ok := sel.X.(*ast.Ident); ok {
 		if pkgname, ok := info.Uses[id].(*types.PkgName); ok {
@@ -193,19 +264,7 @@ func selector(sel *ast.SelectorExpr, pos token.Pos, info *types.Info, found find
 
 		_, ok := info.Types[sel.X]
 		if !ok {
-			f := func(p Package) error {
-				if p.GetName() == id.Name {
-					scope := p.GetTypes().Scope()
-					for _, name := range scope.Names() {
-						items = found(scope.Lookup(name), stdScore, items)
-					}
-				}
-
-				return nil
-			}
-
-			cache.Walk(f, []string{})
-			return items, nil
+			return unimportedMembers(id.Name, pkg, stdScore, found, cache, items), nil
 		}
 	}
 
@@ -325,24 +384,7 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 
 	score := stdScore * 2
 	visit1 := func(prefix string) {
-		f := func(p Package) error {
-			if p.GetName() == prefix && p.GetPkgPath() != pkg.Path() {
-				scope := p.GetTypes().Scope()
-				for _, name := range scope.Names() {
-					obj := scope.Lookup(name)
-					items = found(obj, score, items)
-
-					itemIndex := len(items) - 1
-					comments, err := FindComments(p, p.GetFileSet(), obj, prefix)
-					if err == nil && len(items) > 1 {
-						items[itemIndex].Documentation = comments
-					}
-				}
-			}
-			return nil
-		}
-
-		cache.Walk(f, []string{})
+		items = unimportedMembers(prefix, pkg, score, found, cache, items)
 	}
 
 	visit2 := func(prefix string) {
@@ -352,10 +394,11 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 			}
 
 			item := CompletionItem{
-				Label:  p.GetName(),
-				Detail: p.GetPkgPath(),
-				Kind:   PackageCompletionItem,
-				Score:  score,
+				Label:    p.GetName(),
+				Detail:   p.GetPkgPath(),
+				Kind:     PackageCompletionItem,
+				Score:    score,
+				Locality: ImportedLocality,
 			}
 			items = append(items, item)
 			return nil
@@ -380,6 +423,364 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 	return items
 }
 
+var (
+	declKeywords = []string{"const", "func", "type", "var"}
+	stmtKeywords = []string{"const", "defer", "for", "func", "go", "if", "return", "select", "switch", "type", "var"}
+	caseKeywords = []string{"case", "default"}
+
+	// rangeClause matches the text of a line preceding the word at the
+	// cursor, if the word is the start of the range clause of a for
+	// statement.
+	rangeClause = regexp.MustCompile(`^\s*for\s+[\w\s,]+:?=\s*$`)
+)
+
+// keywords returns the completions of the keywords which may start at pos,
+// enclosed by path: the declaration keywords at the top level, the
+// statement keywords at the start of a statement, case and default in the
+// body of a switch or select statement, else after the block of an if
+// statement and range after the assignment of a for statement. tok and
+// content are the token file and the content of the file.
+func keywords(path []ast.Node, pos token.Pos, tok *token.File, content []byte) []CompletionItem {
+	if file, ok := path[0].(*ast.File); ok {
+		// The parser may skip the end of a block after an error, like a
+		// word following the block of an if statement, so the block ends
+		// at its last statement.
+		if block := unterminatedBlock(file, pos); block != nil {
+			path, _ = astutil.PathEnclosingInterval(file, block.Lbrace, block.Lbrace+1)
+		}
+	}
+
+	var names []string
+	switch {
+	case inRangeClause(path, pos, tok, content):
+		names = []string{"range"}
+	case isTopLevel(path):
+		names = declKeywords
+	default:
+		body, stmt := enclosingStmtList(path, pos)
+		if body == nil {
+			return nil
+		}
+		switch body := body.(type) {
+		case *ast.BlockStmt:
+			if isSwitchBody(body, path) {
+				// The statements of a clause may follow its colon.
+				names = caseKeywords
+				if len(body.List) > 0 && clauseColon(body.List[0]) < pos {
+					names = append(append([]string{}, caseKeywords...), stmtKeywords...)
+				}
+				break
+			}
+			names = stmtKeywords
+			if elseAllowed(body.List, stmt, pos, tok) {
+				names = append([]string{"else"}, names...)
+			}
+		case *ast.CaseClause:
+			names = append(append([]string{}, caseKeywords...), stmtKeywords...)
+			if elseAllowed(body.Body, stmt, pos, tok) {
+				names = append([]string{"else"}, names...)
+			}
+		case *ast.CommClause:
+			names = append(append([]string{}, caseKeywords...), stmtKeywords...)
+			if elseAllowed(body.Body, stmt, pos, tok) {
+				names = append([]string{"else"}, names...)
+			}
+		}
+	}
+
+	var items []CompletionItem
+	for _, name := range names {
+		items = append(items, CompletionItem{
+			Label:    name,
+			Kind:     KeywordCompletionItem,
+			Score:    keywordScore,
+			Locality: UniverseLocality,
+		})
+	}
+	return items
+}
+
+// inRangeClause reports whether pos is right after the assignment of the
+// header of a for statement.
+func inRangeClause(path []ast.Node, pos token.Pos, tok *token.File, content []byte) bool {
+	line, _, ok := linePrefix(pos, tok, content)
+	return ok && rangeClause.MatchString(line)
+}
+
+// linePrefix returns the text of the line of pos before it, split into the
+// text preceding the identifier ending at pos and this identifier, which
+// may be empty.
+func linePrefix(pos token.Pos, tok *token.File, content []byte) (line, word string, ok bool) {
+	if tok == nil || int(pos) < tok.Base() || int(pos) > tok.Base()+tok.Size() {
+		return "", "", false
+	}
+	offset := tok.Offset(pos)
+	if offset > len(content) {
+		return "", "", false
+	}
+	start := offset
+	for start > 0 && content[start-1] != '\n' {
+		start--
+	}
+	w := offset
+	for w > start && isIdentRune(rune(content[w-1])) {
+		w--
+	}
+	return string(content[start:w]), string(content[w:offset]), true
+}
+
+// isTopLevel reports whether the innermost node of path is the file, or a
+// bad declaration of the file, like an incomplete keyword.
+func isTopLevel(path []ast.Node) bool {
+	switch path[0].(type) {
+	case *ast.File, *ast.BadDecl:
+		return true
+	}
+	return false
+}
+
+// unterminatedBlock returns the innermost block of file without a closing
+// brace which starts before pos.
+func unterminatedBlock(file *ast.File, pos token.Pos) *ast.BlockStmt {
+	var block *ast.BlockStmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || n.Pos() > pos {
+			return false
+		}
+		if b, ok := n.(*ast.BlockStmt); ok && !b.Rbrace.IsValid() && b.Lbrace < pos {
+			block = b
+		}
+		return true
+	})
+	return block
+}
+
+// enclosingStmtList returns the block or the clause whose statements
+// enclose pos, if a statement may start at pos, along with the statement
+// being typed at pos, which is nil if there is none.
+func enclosingStmtList(path []ast.Node, pos token.Pos) (ast.Node, ast.Stmt) {
+	var stmt ast.Stmt
+	if id, ok := path[0].(*ast.Ident); ok {
+		if len(path) < 3 {
+			return nil, nil
+		}
+		expr, ok := path[1].(*ast.ExprStmt)
+		if !ok || expr.X != id {
+			return nil, nil
+		}
+		stmt, path = expr, path[2:]
+	}
+
+	switch n := path[0].(type) {
+	case *ast.BlockStmt:
+		if n.Lbrace < pos {
+			return n, stmt
+		}
+	case *ast.CaseClause:
+		if n.Colon < pos {
+			return n, stmt
+		}
+	case *ast.CommClause:
+		if n.Colon < pos {
+			return n, stmt
+		}
+	}
+	return nil, nil
+}
+
+// isSwitchBody reports whether body, the innermost block of path or the
+// block of its innermost statement, is the body of a switch or select
+// statement, which is a list of clauses.
+func isSwitchBody(body *ast.BlockStmt, path []ast.Node) bool {
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.SwitchStmt:
+			return n.Body == body
+		case *ast.TypeSwitchStmt:
+			return n.Body == body
+		case *ast.SelectStmt:
+			return n.Body == body
+		case *ast.BlockStmt:
+			if n != body {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// elseAllowed reports whether else may be typed at pos in the statements
+// list: if the statement preceding stmt, or pos if stmt is nil, is an if
+// statement without an else branch, ending on the line of pos.
+func elseAllowed(list []ast.Stmt, stmt ast.Stmt, pos token.Pos, tok *token.File) bool {
+	if tok == nil {
+		return false
+	}
+	var prev ast.Stmt
+	for _, s := range list {
+		if s == stmt || s.Pos() >= pos {
+			break
+		}
+		prev = s
+	}
+	ifStmt, ok := prev.(*ast.IfStmt)
+	if !ok {
+		return false
+	}
+	for ifStmt.Else != nil {
+		next, ok := ifStmt.Else.(*ast.IfStmt)
+		if !ok {
+			return false
+		}
+		ifStmt = next
+	}
+	return ifStmt.Body.Rbrace.IsValid() && tok.Line(ifStmt.Body.Rbrace) == tok.Line(pos)
+}
+
+// clauseColon returns the position of the colon of the case or comm
+// clause stmt.
+func clauseColon(stmt ast.Stmt) token.Pos {
+	switch c := stmt.(type) {
+	case *ast.CaseClause:
+		return c.Colon
+	case *ast.CommClause:
+		return c.Colon
+	}
+	return token.NoPos
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// unimportedMembers adds the exported members of the packages of the cache
+// named name to items, along with their import path. The package pkg being
+// completed and the packages it cannot import are skipped, and the variants
+// of a package, like its test variant, are only visited once.
+func unimportedMembers(name string, pkg *types.Package, score float64, found finder, cache Cache, items []CompletionItem) []CompletionItem {
+	visited := make(map[string]bool)
+	f := func(p Package) error {
+		path := p.GetPkgPath()
+		if p.GetName() != name || p.GetTypes() == nil || visited[path] || path == pkg.Path() || !canImport(pkg.Path(), path) {
+			return nil
+		}
+		visited[path] = true
+
+		scope := p.GetTypes().Scope()
+		for _, member := range scope.Names() {
+			obj := scope.Lookup(member)
+			n := len(items)
+			items = found(obj, score, items)
+			if len(items) == n {
+				continue
+			}
+			items[n].ImportPath = path
+			items[n].Declaration = p.GetFileSet().Position(obj.Pos())
+		}
+		return nil
+	}
+
+	cache.Walk(f, []string{})
+	return items
+}
+
+// ResolveCompletionItem returns the detail and the documentation of the
+// completion item of the object name declared at pos in the package path,
+// completed in the file f. The path is empty for predeclared objects. The
+// packages f may not import yet are looked up in cache.
+func ResolveCompletionItem(ctx context.Context, f File, path, name string, pos token.Position, cache Cache) (detail, doc string, err error) {
+	pkg := f.GetPackage(ctx)
+	qual := qualifier(f.GetAST(ctx), pkg.GetTypes(), pkg.GetTypesInfo())
+	notParam := func(*types.Var) bool { return false }
+	if path == "" {
+		obj := types.Universe.Lookup(name)
+		if obj == nil {
+			return "", "", fmt.Errorf("no predeclared object %s", name)
+		}
+		return formatCompletion(obj, qual, stdScore, notParam).Detail, "", nil
+	}
+
+	declPkg := importedPackage(pkg, path)
+	if declPkg == nil && cache != nil {
+		cache.Walk(func(p Package) error {
+			if declPkg == nil && p.GetPkgPath() == path {
+				declPkg = p
+			}
+			return nil
+		}, []string{})
+	}
+	if declPkg == nil || declPkg.GetTypesInfo() == nil {
+		return "", "", fmt.Errorf("cannot find package %s", path)
+	}
+	obj := declaredObject(declPkg, name, pos)
+	if obj == nil {
+		return "", "", fmt.Errorf("cannot find %s declared at %s", name, pos)
+	}
+
+	detail = formatCompletion(obj, qual, stdScore, notParam).Detail
+	doc, err = FindComments(declPkg, declPkg.GetFileSet(), obj, name)
+	return detail, doc, err
+}
+
+// importedPackage returns pkg or the package path it imports, directly or
+// not, or nil if there is none.
+func importedPackage(pkg Package, path string) Package {
+	seen := map[string]bool{pkg.GetPkgPath(): true}
+	queue := []Package{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p.GetPkgPath() == path {
+			return p
+		}
+		if p.GetTypes() == nil {
+			continue
+		}
+		for _, imp := range p.GetTypes().Imports() {
+			if seen[imp.Path()] {
+				continue
+			}
+			seen[imp.Path()] = true
+			if ip := p.GetImport(imp.Path()); ip != nil {
+				queue = append(queue, ip)
+			}
+		}
+	}
+	return nil
+}
+
+// declaredObject returns the object name of pkg declared at pos.
+func declaredObject(pkg Package, name string, pos token.Position) types.Object {
+	fset := pkg.GetFileSet()
+	for id, obj := range pkg.GetTypesInfo().Defs {
+		if obj == nil || id.Name != name {
+			continue
+		}
+		if p := fset.Position(id.Pos()); p.Offset == pos.Offset && util.PathEqual(p.Filename, pos.Filename) {
+			return obj
+		}
+	}
+	return nil
+}
+
+// canImport reports whether the package importer may import the package
+// path, which is not the case of the internal packages of other trees.
+func canImport(importer, path string) bool {
+	var parent string
+	switch i := strings.LastIndex(path, "/internal/"); {
+	case i >= 0:
+		parent = path[:i+1]
+	case strings.HasSuffix(path, "/internal"):
+		parent = path[:len(path)-len("internal")]
+	case path == "internal" || strings.HasPrefix(path, "internal/"):
+		// The internal packages of the standard library.
+		return !strings.Contains(strings.Split(importer, "/")[0], ".")
+	default:
+		return true
+	}
+	return strings.HasPrefix(importer+"/", parent)
+}
+
 // inComment checks if given token position is inside ast.Comment node.
 func inComment(pos token.Pos, commentGroups []*ast.CommentGroup) bool {
 	for _, g := range commentGroups {
@@ -478,18 +879,32 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 			}
 		}
 	}
+	// Fields are completed along with their colon, unless the key of a
+	// key-value expression is being edited.
+	inKey := false
+	for i := 0; i < 2 && i < len(path); i++ {
+		if kv, ok := path[i].(*ast.KeyValueExpr); ok && pos <= kv.Colon {
+			inKey = true
+		}
+	}
 	// If the underlying type of the composite literal is a struct,
-	// collect completions for the fields of this struct.
+	// collect completions for the fields of this struct. The type of a
+	// literal whose type is elided in a literal of pointers, like
+	// []*T{{...}}, is a pointer.
 	if tv, ok := info.Types[lit]; ok {
 		var structPkg *types.Package // package containing the struct type declaration
-		if s, ok := tv.Type.Underlying().(*types.Struct); ok {
+		if s, ok := deref(tv.Type).Underlying().(*types.Struct); ok {
 			for i := 0; i < s.NumFields(); i++ {
 				field := s.Field(i)
 				if i == 0 {
 					structPkg = field.Pkg()
 				}
 				if !addedFields[field] {
+					n := len(items)
 					items = found(field, 10.0, items)
+					if len(items) > n && !inKey {
+						items[n].InsertText = field.Name() + ": "
+					}
 				}
 			}
 			// Add lexical completions if the user hasn't typed a key value expression
@@ -694,6 +1109,36 @@ func expectedType(path []ast.Node, pos token.Pos, info *types.Info) types.Type {
 			if tv, ok := info.Types[expr.Lhs[i]]; ok {
 				return tv.Type
 			}
+		case *ast.ValueSpec:
+			if expr.Type != nil && len(expr.Values) > 0 && pos >= expr.Values[0].Pos() {
+				return info.TypeOf(expr.Type)
+			}
+		case *ast.KeyValueExpr:
+			if pos > expr.Colon && i+1 < len(path) {
+				if lit, ok := path[i+1].(*ast.CompositeLit); ok {
+					return elementType(lit, expr, pos, info)
+				}
+			}
+		case *ast.CompositeLit:
+			if expr.Lbrace < pos {
+				var elt ast.Expr
+				for _, e := range expr.Elts {
+					if e.Pos() <= pos && pos <= e.End() {
+						elt = e
+					}
+				}
+				return elementType(expr, elt, pos, info)
+			}
+		case *ast.ReturnStmt:
+			sig := enclosingFunction(path, pos, info)
+			if sig == nil || sig.Results().Len() == 0 {
+				return nil
+			}
+			i := exprAtPos(pos, expr.Results)
+			if i >= sig.Results().Len() {
+				i = sig.Results().Len() - 1
+			}
+			return sig.Results().At(i).Type()
 		case *ast.CallExpr:
 			if tv, ok := info.Types[expr.Fun]; ok {
 				if sig, ok := tv.Type.(*types.Signature); ok {
@@ -713,8 +1158,49 @@ func expectedType(path []ast.Node, pos token.Pos, info *types.Info) types.Type {
 	return nil
 }
 
+// elementType returns the type expected at pos in the element elt of the
+// composite literal lit, which is nil between elements: the type of a
+// field of a struct, or the type of the elements or of the keys.
+func elementType(lit *ast.CompositeLit, elt ast.Expr, pos token.Pos, info *types.Info) types.Type {
+	tv, ok := info.Types[lit]
+	if !ok {
+		return nil
+	}
+	kv, isKeyValue := elt.(*ast.KeyValueExpr)
+	inValue := isKeyValue && pos > kv.Colon
+	switch t := deref(tv.Type).Underlying().(type) {
+	case *types.Struct:
+		if isKeyValue {
+			if key, ok := kv.Key.(*ast.Ident); ok && inValue {
+				for i := 0; i < t.NumFields(); i++ {
+					if t.Field(i).Name() == key.Name {
+						return t.Field(i).Type()
+					}
+				}
+			}
+			return nil
+		}
+		for i, e := range lit.Elts {
+			if e == elt && i < t.NumFields() {
+				return t.Field(i).Type()
+			}
+		}
+	case *types.Slice:
+		return t.Elem()
+	case *types.Array:
+		return t.Elem()
+	case *types.Map:
+		if inValue {
+			return t.Elem()
+		}
+		return t.Key()
+	}
+	return nil
+}
+
 // matchingTypes reports whether actual is a good candidate type
-// for a completion in a context of the expected type.
+// for a completion in a context of the expected type: if values of type
+// actual are assignable to it, unless any value is.
 func matchingTypes(expected, actual types.Type) bool {
 	// Use a function's return type as its type.
 	if sig, ok := actual.(*types.Signature); ok {
@@ -722,7 +1208,46 @@ func matchingTypes(expected, actual types.Type) bool {
 			actual = sig.Results().At(0).Type()
 		}
 	}
-	return types.Identical(types.Default(expected), types.Default(actual))
+	if b, ok := actual.(*types.Basic); ok && (b.Kind() == types.Invalid || b.Kind() == types.UntypedNil) {
+		// Builtin functions have no type, and nil would match any pointer.
+		return false
+	}
+	if iface, ok := expected.Underlying().(*types.Interface); ok && iface.NumMethods() == 0 {
+		return types.Identical(expected, actual)
+	}
+	return types.Identical(types.Default(expected), types.Default(actual)) || types.AssignableTo(actual, expected)
+}
+
+// enumConstants returns the exported constants of the named type typ
+// declared in its package, unless it is pkg, like time.Second for a
+// time.Duration. They are qualified by the name qual gives their package,
+// which they import if needed.
+func enumConstants(typ types.Type, pkg *types.Package, fset *token.FileSet, qual types.Qualifier) (items []CompletionItem) {
+	named, ok := typ.(*types.Named)
+	if !ok || types.IsInterface(named) {
+		return nil
+	}
+	declPkg := named.Obj().Pkg()
+	if declPkg == nil || declPkg == pkg {
+		return nil
+	}
+
+	name := qual(declPkg)
+	scope := declPkg.Scope()
+	for _, n := range scope.Names() {
+		c, ok := scope.Lookup(n).(*types.Const)
+		if !ok || !c.Exported() || !types.Identical(c.Type(), named) {
+			continue
+		}
+		item := formatCompletion(c, qual, stdScore*10, nil)
+		item.Label = name + "." + item.Label
+		item.InsertText = name + "." + c.Name()
+		item.Locality = ImportedLocality
+		item.ImportPath = declPkg.Path()
+		item.Object, item.Declaration = c, fset.Position(c.Pos())
+		items = append(items, item)
+	}
+	return items
 }
 
 // exprAtPos returns the index of the expression containing pos.
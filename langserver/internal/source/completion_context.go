@@ -0,0 +1,85 @@
+package source
+
+import (
+	"go/token"
+	"go/types"
+	"regexp"
+)
+
+var (
+	// addressOf matches the text of a line preceding the word at the
+	// cursor, if the word follows the unary & operator, possibly qualified
+	// by the name of a package.
+	addressOf = regexp.MustCompile(`(^|[=(,{:;\[]|\breturn)\s*&\s*(\w+\.)?$`)
+
+	// makeArg matches the text of a line preceding the word at the cursor,
+	// if the word is the first argument of a call of make, possibly
+	// qualified by the name of a package.
+	makeArg = regexp.MustCompile(`(^|[^\w.])make\(\s*(\w+\.)?$`)
+)
+
+// filterByContext adapts the candidates items completed at pos in pkg to
+// the operator or the builtin call preceding pos. After the & operator,
+// the struct types are completed as composite literals ranked first. As
+// the first argument of make, only the slice, map and channel types are
+// completed, along with the packages which may declare them. tok and
+// content are the token file and the content of the completed file.
+func filterByContext(items []CompletionItem, pos token.Pos, pkg Package, tok *token.File, content []byte) []CompletionItem {
+	line, _, ok := linePrefix(pos, tok, content)
+	if !ok {
+		return items
+	}
+
+	switch {
+	case addressOf.MatchString(line):
+		for i, item := range items {
+			if item.Kind != StructCompletionItem || item.InsertText != "" {
+				continue
+			}
+			if _, ok := item.Object.(*types.TypeName); !ok {
+				continue
+			}
+			name := item.Object.Name()
+			items[i].Label = "&" + name + "{}"
+			items[i].InsertText = name + "{}"
+			items[i].Snippet = name + "{$0}"
+			items[i].Score *= 10
+		}
+
+	case makeArg.MatchString(line) && isBuiltin(pkg, pos, "make"):
+		var kept []CompletionItem
+		for _, item := range items {
+			if item.Kind == PackageCompletionItem || isMakeable(item.Object) {
+				kept = append(kept, item)
+			}
+		}
+		return kept
+	}
+	return items
+}
+
+// isBuiltin reports whether name refers to the builtin function of this
+// name at pos in pkg.
+func isBuiltin(pkg Package, pos token.Pos, name string) bool {
+	scope := pkg.GetTypes().Scope()
+	if inner := scope.Innermost(pos); inner != nil {
+		scope = inner
+	}
+	_, obj := scope.LookupParent(name, pos)
+	_, ok := obj.(*types.Builtin)
+	return ok
+}
+
+// isMakeable reports whether obj is a type which make can create values
+// of: a slice, map or channel type.
+func isMakeable(obj types.Object) bool {
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return false
+	}
+	switch tn.Type().Underlying().(type) {
+	case *types.Slice, *types.Map, *types.Chan:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// deepScore is the score of deep completions, ranked below the candidates
+// of the expected type and above the others.
+const deepScore float64 = stdScore * 2
+
+// maxDeepCompletions caps the number of deep completions, which grows with
+// the number of variables in scope.
+const maxDeepCompletions = 20
+
+// deepCompletions returns the completions of the fields and the niladic
+// methods of the variables of items, declared in pkg, whose type matches
+// the expected type typ, like buf.String() for a string. They insert the
+// whole selector expression.
+func deepCompletions(items []CompletionItem, typ types.Type, pkg *types.Package, fset *token.FileSet, qual types.Qualifier) []CompletionItem {
+	if typ == nil {
+		return nil
+	}
+
+	var deep []CompletionItem
+	add := func(v *types.Var, member types.Object, memberType types.Type, locality Locality) bool {
+		if !member.Exported() && member.Pkg() != pkg {
+			return true
+		}
+		if !matchingTypes(typ, memberType) {
+			return true
+		}
+		item := CompletionItem{
+			Label:       v.Name() + "." + member.Name(),
+			Detail:      types.TypeString(memberType, qual),
+			Kind:        FieldCompletionItem,
+			Score:       deepScore,
+			Locality:    locality,
+			Object:      member,
+			Declaration: fset.Position(member.Pos()),
+		}
+		if _, ok := member.(*types.Func); ok {
+			item.Label += "()"
+			item.Kind = MethodCompletionItem
+		}
+		item.InsertText = item.Label
+		deep = append(deep, item)
+		return len(deep) < maxDeepCompletions
+	}
+
+	for _, item := range items {
+		v, ok := item.Object.(*types.Var)
+		if !ok || v.IsField() || v.Pkg() != pkg || matchingTypes(typ, v.Type()) {
+			continue
+		}
+
+		for _, f := range fieldSelections(v.Type()) {
+			if !add(v, f, f.Type(), item.Locality) {
+				return deep
+			}
+		}
+
+		T := v.Type()
+		if !types.IsInterface(T) && !isPointer(T) {
+			// Variables are addressable.
+			T = types.NewPointer(T)
+		}
+		mset := types.NewMethodSet(T)
+		for i := 0; i < mset.Len(); i++ {
+			sig, ok := mset.At(i).Type().(*types.Signature)
+			if !ok || sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+				continue
+			}
+			if !add(v, mset.At(i).Obj(), sig.Results().At(0).Type(), item.Locality) {
+				return deep
+			}
+		}
+	}
+	return deep
+}
@@ -0,0 +1,750 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ExtractVariable returns the refactoring replacing the expression
+// selected from start to end with a new variable, declared before the
+// statement enclosing it. The error tells why the selection cannot be
+// extracted.
+func ExtractVariable(ctx context.Context, f File, start, end token.Pos) (QuickFix, error) {
+	file, tok, pkg := f.GetAST(ctx), f.GetToken(ctx), f.GetPackage(ctx)
+	if file == nil || tok == nil || pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return QuickFix{}, errors.New("no type information")
+	}
+	content := f.GetContent(ctx)
+	info := pkg.GetTypesInfo()
+	start, end = trimSelection(tok, content, start, end)
+
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	if len(path) < 2 {
+		return QuickFix{}, errors.New("no expression selected")
+	}
+	expr, ok := path[0].(ast.Expr)
+	if !ok || expr.Pos() != start || expr.End() != end {
+		return QuickFix{}, errors.New("selection is not an expression")
+	}
+	if err := checkExtractable(path); err != nil {
+		return QuickFix{}, err
+	}
+	tv, ok := info.Types[expr]
+	if !ok || !tv.IsValue() || tv.IsNil() {
+		return QuickFix{}, errors.New("selection is not a value")
+	}
+	if _, ok := tv.Type.(*types.Tuple); ok {
+		return QuickFix{}, errors.New("selection has several values")
+	}
+
+	stmt, err := enclosingListStmt(path)
+	if err != nil {
+		return QuickFix{}, err
+	}
+	var usesLocal bool
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := info.Uses[id]; obj != nil && stmt.Pos() <= obj.Pos() && obj.Pos() < stmt.End() {
+				usesLocal = true
+			}
+		}
+		return !usesLocal
+	})
+	if usesLocal {
+		return QuickFix{}, errors.New("selection uses a variable declared by the enclosing statement")
+	}
+	indent, ok := lineIndent(tok, content, stmt.Pos())
+	if !ok {
+		return QuickFix{}, errors.New("enclosing statement does not start its line")
+	}
+
+	scope := pkg.GetTypes().Scope().Innermost(stmt.Pos())
+	name := freeName("x", scope, stmt.Pos(), enclosingFunc(path))
+
+	text := string(content[tok.Offset(start):tok.Offset(end)])
+	qual := qualifier(file, pkg.GetTypes(), info)
+	if kind, ok := untypedKind(info, expr); ok && tv.Value != nil && !types.Identical(tv.Type, types.Default(types.Typ[kind])) {
+		// Keep the type the constant is converted to by its context.
+		text = types.TypeString(tv.Type, qual) + "(" + text + ")"
+	}
+
+	fset := f.GetFileSet(ctx)
+	decl, err := newTextEdit(fset, stmt.Pos(), stmt.Pos(), name+" := "+text+"\n"+indent)
+	if err != nil {
+		return QuickFix{}, err
+	}
+	ref, err := newTextEdit(fset, start, end, name)
+	if err != nil {
+		return QuickFix{}, err
+	}
+	return QuickFix{
+		Title: "Extract variable",
+		Edits: []TextEdit{decl, ref},
+	}, nil
+}
+
+// checkExtractable checks that the expression path[0] is not assigned, or
+// otherwise needs to be the expression it is rather than a variable.
+func checkExtractable(path []ast.Node) error {
+	child := path[0]
+	for _, n := range path[1:] {
+		switch n := n.(type) {
+		case *ast.ParenExpr:
+			child = n
+			continue
+		case *ast.SelectorExpr:
+			if n.Sel == child {
+				return errors.New("selection is the name of a field or method")
+			}
+			child = n
+			continue
+		case *ast.IndexExpr:
+			if n.X == child {
+				child = n
+				continue
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if lhs == child {
+					return errors.New("selection is assigned")
+				}
+			}
+		case *ast.IncDecStmt:
+			return errors.New("selection is assigned")
+		case *ast.RangeStmt:
+			if n.Key == child || n.Value == child {
+				return errors.New("selection is assigned")
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				return errors.New("the address of the selection is taken")
+			}
+		case *ast.KeyValueExpr:
+			if n.Key == child {
+				return errors.New("selection is a key")
+			}
+		case *ast.ExprStmt, *ast.GoStmt, *ast.DeferStmt:
+			if child == path[0] {
+				return errors.New("selection is a statement")
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// enclosingListStmt returns the statement of a block or clause enclosing
+// the expression path[0], which the expression is evaluated along with.
+func enclosingListStmt(path []ast.Node) (ast.Stmt, error) {
+	for i, n := range path[:len(path)-1] {
+		// A conditionally evaluated operand can't be evaluated before the
+		// statement.
+		if bin, ok := path[i+1].(*ast.BinaryExpr); ok && (bin.Op == token.LAND || bin.Op == token.LOR) && within(path[0], bin.Y) {
+			return nil, errors.New("selection is evaluated conditionally")
+		}
+		stmt, ok := n.(ast.Stmt)
+		if !ok {
+			continue
+		}
+		var list []ast.Stmt
+		switch parent := path[i+1].(type) {
+		case *ast.BlockStmt:
+			list = parent.List
+		case *ast.CaseClause:
+			list = parent.Body
+		case *ast.CommClause:
+			list = parent.Body
+		}
+		for _, s := range list {
+			if s != stmt {
+				continue
+			}
+			switch stmt := stmt.(type) {
+			case *ast.CaseClause, *ast.CommClause:
+				return nil, errors.New("selection is a case of a switch or select statement")
+			case *ast.ForStmt:
+				if within(path[0], stmt.Cond) || within(path[0], stmt.Post) {
+					return nil, errors.New("selection is evaluated at each iteration of a loop")
+				}
+			case *ast.IfStmt:
+				if within(path[0], stmt.Else) {
+					return nil, errors.New("selection is evaluated by an else branch")
+				}
+			}
+			return stmt, nil
+		}
+	}
+	return nil, errors.New("selection is not in a function body")
+}
+
+// within reports whether the node inner is within the node outer.
+func within(inner, outer ast.Node) bool {
+	return outer != nil && outer.Pos() <= inner.Pos() && inner.End() <= outer.End()
+}
+
+// enclosingFunc returns the innermost function of the path, nil if there
+// is none.
+func enclosingFunc(path []ast.Node) ast.Node {
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return n
+		}
+	}
+	return nil
+}
+
+// freeName returns a name based on base which is neither visible in scope
+// at pos nor used in the node n, eg. base1 if base is taken.
+func freeName(base string, scope *types.Scope, pos token.Pos, n ast.Node) string {
+	used := make(map[string]bool)
+	if n != nil {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+			return true
+		})
+	}
+	name := base
+	for i := 1; ; i++ {
+		taken := used[name]
+		if scope != nil {
+			if _, obj := scope.LookupParent(name, pos); obj != nil {
+				taken = true
+			}
+		}
+		if !taken {
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+// untypedKind returns the kind of the constant expression expr if it is
+// untyped, so that a variable it is assigned to gets its default type.
+func untypedKind(info *types.Info, expr ast.Expr) (types.BasicKind, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		switch expr.Kind {
+		case token.INT:
+			return types.UntypedInt, true
+		case token.CHAR:
+			return types.UntypedRune, true
+		case token.FLOAT:
+			return types.UntypedFloat, true
+		case token.IMAG:
+			return types.UntypedComplex, true
+		case token.STRING:
+			return types.UntypedString, true
+		}
+	case *ast.Ident:
+		return untypedConst(info.Uses[expr])
+	case *ast.SelectorExpr:
+		return untypedConst(info.Uses[expr.Sel])
+	case *ast.ParenExpr:
+		return untypedKind(info, expr.X)
+	case *ast.UnaryExpr:
+		return untypedKind(info, expr.X)
+	case *ast.BinaryExpr:
+		switch expr.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return types.UntypedBool, true
+		case token.SHL, token.SHR:
+			return untypedKind(info, expr.X)
+		}
+		x, ok := untypedKind(info, expr.X)
+		if !ok {
+			return 0, false
+		}
+		y, ok := untypedKind(info, expr.Y)
+		if !ok {
+			return 0, false
+		}
+		// The numeric kinds are ordered like the operands of mixed
+		// operations convert.
+		if y > x {
+			x = y
+		}
+		return x, true
+	}
+	return 0, false
+}
+
+// untypedConst returns the kind of obj if it is an untyped constant.
+func untypedConst(obj types.Object) (types.BasicKind, bool) {
+	c, ok := obj.(*types.Const)
+	if !ok {
+		return 0, false
+	}
+	basic, ok := c.Type().(*types.Basic)
+	if !ok || basic.Info()&types.IsUntyped == 0 {
+		return 0, false
+	}
+	return basic.Kind(), true
+}
+
+// ExtractFunction returns the refactoring moving the statements selected
+// from start to end into a new function declared after the enclosing
+// declaration, and replacing them with a call of the function. The local
+// variables the statements use are passed as parameters, and those they
+// assign and are used after them are returned, the errors last. The error
+// tells why the selection cannot be extracted.
+func ExtractFunction(ctx context.Context, f File, start, end token.Pos) (QuickFix, error) {
+	file, tok, pkg := f.GetAST(ctx), f.GetToken(ctx), f.GetPackage(ctx)
+	if file == nil || tok == nil || pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return QuickFix{}, errors.New("no type information")
+	}
+	content := f.GetContent(ctx)
+	info := pkg.GetTypesInfo()
+	start, end = trimSelection(tok, content, start, end)
+
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	var block ast.Node
+	var list []ast.Stmt
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		default:
+			continue
+		}
+		block = n
+		break
+	}
+	if block == nil || enclosingFunc(path) == nil {
+		return QuickFix{}, errors.New("selection is not in a function body")
+	}
+	var stmts []ast.Stmt
+	for _, stmt := range list {
+		if start <= stmt.Pos() && stmt.End() <= end {
+			stmts = append(stmts, stmt)
+		}
+	}
+	if len(stmts) == 0 || stmts[0].Pos() != start || stmts[len(stmts)-1].End() != end {
+		return QuickFix{}, errors.New("selection does not cover whole statements of a block")
+	}
+	var err error
+	for _, stmt := range stmts {
+		ast.Walk(branchChecker{info: info, start: start, end: end, err: &err}, stmt)
+	}
+	if err != nil {
+		return QuickFix{}, err
+	}
+
+	var top ast.Decl
+	for _, decl := range file.Decls {
+		if decl.Pos() <= start && end <= decl.End() {
+			top = decl
+		}
+	}
+	if top == nil {
+		return QuickFix{}, errors.New("selection is not in a declaration")
+	}
+	vars, err := extractedVars(info, pkg.GetTypes(), path, top, block, start, end)
+	if err != nil {
+		return QuickFix{}, err
+	}
+	qual, imported := fileQualifier(file, pkg.GetTypes())
+	var imports []*types.Package
+	seen := make(map[*types.Package]bool)
+	typeString := func(typ types.Type) string {
+		return types.TypeString(typ, func(p *types.Package) string {
+			if !imported(p) && !seen[p] {
+				seen[p] = true
+				imports = append(imports, p)
+			}
+			return qual(p)
+		})
+	}
+
+	name := freeName("newFunction", pkg.GetTypes().Scope().Innermost(start), start, top)
+	var params, args, results, resultTypes []string
+	for _, v := range vars.params {
+		params = append(params, v.Name()+" "+typeString(v.Type()))
+		args = append(args, v.Name())
+	}
+	for _, v := range vars.results {
+		results = append(results, v.Name())
+		resultTypes = append(resultTypes, typeString(v.Type()))
+	}
+
+	var fn strings.Builder
+	fmt.Fprintf(&fn, "func %s(%s)", name, strings.Join(params, ", "))
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		fn.WriteString(" " + resultTypes[0])
+	default:
+		fmt.Fprintf(&fn, " (%s)", strings.Join(resultTypes, ", "))
+	}
+	fn.WriteString(" {\n" + string(content[tok.Offset(start):tok.Offset(end)]) + "\n")
+	if len(results) > 0 {
+		fn.WriteString("return " + strings.Join(results, ", ") + "\n")
+	}
+	fn.WriteString("}\n")
+	const prefix = "package p\n\n"
+	formatted, err := format.Source([]byte(prefix + fn.String()))
+	if err != nil {
+		return QuickFix{}, err
+	}
+
+	call := name + "(" + strings.Join(args, ", ") + ")"
+	switch {
+	case len(results) == 0:
+	case len(vars.declared) == 0:
+		call = strings.Join(results, ", ") + " = " + call
+	case vars.define:
+		call = strings.Join(results, ", ") + " := " + call
+	default:
+		// Some of the results are variables of an enclosing block, which
+		// := would shadow.
+		indent, ok := lineIndent(tok, content, start)
+		if !ok {
+			return QuickFix{}, errors.New("selection does not start its line")
+		}
+		var decls string
+		for _, v := range vars.declared {
+			decls += "var " + v.Name() + " " + typeString(v.Type()) + "\n" + indent
+		}
+		call = decls + strings.Join(results, ", ") + " = " + call
+	}
+
+	fset := f.GetFileSet(ctx)
+	callEdit, err := newTextEdit(fset, start, end, call)
+	if err != nil {
+		return QuickFix{}, err
+	}
+	// The function follows the comments at the end of the declaration.
+	after := tok.Offset(top.End())
+	for after < len(content) && content[after] != '\n' {
+		after++
+	}
+	fnEdit, err := newTextEdit(fset, tok.Pos(after), tok.Pos(after), "\n\n"+strings.TrimSuffix(strings.TrimPrefix(string(formatted), prefix), "\n"))
+	if err != nil {
+		return QuickFix{}, err
+	}
+	edits, err := importEdits(fset, file, content, imports)
+	if err != nil {
+		return QuickFix{}, err
+	}
+	return QuickFix{
+		Title: "Extract function",
+		Edits: append(edits, callEdit, fnEdit),
+	}, nil
+}
+
+// branchChecker checks that the statements it walks do not leave the
+// selection from start to end other than by completing.
+type branchChecker struct {
+	info       *types.Info
+	start, end token.Pos
+	err        *error
+
+	// loops and breakables are the numbers of loops, and of statements
+	// break applies to, enclosing the walked node within the selection.
+	loops, breakables int
+}
+
+func (c branchChecker) Visit(n ast.Node) ast.Visitor {
+	if n == nil || *c.err != nil {
+		return nil
+	}
+	switch n := n.(type) {
+	case *ast.FuncLit:
+		return nil
+	case *ast.ReturnStmt:
+		*c.err = errors.New("selection contains a return statement")
+	case *ast.DeferStmt:
+		*c.err = errors.New("selection contains a defer statement")
+	case *ast.ForStmt, *ast.RangeStmt:
+		c.loops++
+		c.breakables++
+	case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+		c.breakables++
+	case *ast.BranchStmt:
+		switch {
+		case n.Tok == token.FALLTHROUGH:
+			*c.err = errors.New("selection contains a fallthrough statement")
+		case n.Label != nil:
+			if obj := c.info.Uses[n.Label]; obj == nil || obj.Pos() < c.start || c.end <= obj.Pos() {
+				*c.err = fmt.Errorf("selection branches to the label %s outside of it", n.Label.Name)
+			}
+		case n.Tok == token.BREAK && c.breakables == 0:
+			*c.err = errors.New("selection breaks out of it")
+		case n.Tok == token.CONTINUE && c.loops == 0:
+			*c.err = errors.New("selection continues a loop outside of it")
+		}
+	}
+	return c
+}
+
+// extractedVarSet holds the variables of extracted statements.
+type extractedVarSet struct {
+	// params are the variables declared before the statements which they
+	// use, in the order of their first use.
+	params []*types.Var
+
+	// results are the variables the statements assign which are used
+	// after them, the errors last.
+	results []*types.Var
+
+	// declared are the results declared by the statements.
+	declared []*types.Var
+
+	// define reports whether the results can be defined with :=, because
+	// the results which are not declared by the statements belong to the
+	// block of the statements.
+	define bool
+}
+
+// extractedVars returns the variables of the statements from start to end
+// of block, enclosed by the path and the top-level declaration top.
+func extractedVars(info *types.Info, pkg *types.Package, path []ast.Node, top ast.Decl, block ast.Node, start, end token.Pos) (*extractedVarSet, error) {
+	inSelection := func(pos token.Pos) bool { return start <= pos && pos < end }
+	isLocal := func(v *types.Var) bool {
+		return v.Pkg() == pkg && !v.IsField() && v.Parent() != nil && v.Parent() != pkg.Scope() &&
+			top.Pos() <= v.Pos() && v.Pos() < top.End()
+	}
+
+	// uses holds the positions of the uses of the local variables in the
+	// top-level declaration.
+	uses := make(map[*types.Var][]token.Pos)
+	vars := &extractedVarSet{define: true}
+	seen := make(map[*types.Var]bool)
+	var err error
+	ast.Inspect(top, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		switch obj := info.Uses[id].(type) {
+		case *types.Var:
+			if !isLocal(obj) {
+				break
+			}
+			uses[obj] = append(uses[obj], id.Pos())
+			if inSelection(id.Pos()) && !inSelection(obj.Pos()) && !seen[obj] {
+				seen[obj] = true
+				vars.params = append(vars.params, obj)
+			}
+		case *types.TypeName, *types.Const:
+			local := obj.Parent() != nil && obj.Parent() != pkg.Scope() && obj.Parent() != types.Universe
+			if local && inSelection(id.Pos()) && !inSelection(obj.Pos()) && err == nil {
+				err = fmt.Errorf("selection uses %s, declared in the function", obj.Name())
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The uses of a variable declared outside of a loop enclosing the
+	// statements which precede them are made after them by the next
+	// iterations.
+	var loop ast.Node
+	for _, n := range path {
+		if n == top {
+			break
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			break
+		}
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			if loop == nil {
+				loop = n
+			}
+		}
+	}
+	usedAfter := func(v *types.Var) bool {
+		for _, pos := range uses[v] {
+			if pos >= end {
+				return true
+			}
+			if loop != nil && v.Pos() < loop.Pos() && loop.Pos() <= pos && !inSelection(pos) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The variables the statements assign, in order.
+	var assigned []*types.Var
+	assign := func(v *types.Var) {
+		if v != nil && isLocal(v) && !containsVar(assigned, v) {
+			assigned = append(assigned, v)
+		}
+	}
+	ast.Inspect(block, func(n ast.Node) bool {
+		if n == nil || n.End() <= start || end <= n.Pos() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					if v, ok := info.Defs[id].(*types.Var); ok {
+						assign(v)
+						continue
+					}
+				}
+				assign(rootVar(info, lhs))
+			}
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				if v, ok := info.Defs[id].(*types.Var); ok {
+					assign(v)
+				}
+			}
+		case *ast.IncDecStmt:
+			assign(rootVar(info, n.X))
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				assign(rootVar(info, n.Key))
+				assign(rootVar(info, n.Value))
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				assign(rootVar(info, n.X))
+			}
+		case *ast.CallExpr:
+			// A method with a pointer receiver called on a variable may
+			// change it.
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+				if s := info.Selections[sel]; s != nil && s.Kind() == types.MethodVal {
+					recv := s.Obj().Type().(*types.Signature).Recv()
+					if _, ok := recv.Type().(*types.Pointer); ok {
+						if _, ok := info.TypeOf(sel.X).Underlying().(*types.Pointer); !ok {
+							assign(rootVar(info, sel.X))
+						}
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	// Named results are used by the returns of the function.
+	var namedResults *types.Tuple
+	if fn, ok := enclosingFunc(path).(*ast.FuncDecl); ok {
+		if obj, ok := info.Defs[fn.Name].(*types.Func); ok {
+			namedResults = obj.Type().(*types.Signature).Results()
+		}
+	}
+	isNamedResult := func(v *types.Var) bool {
+		for i := 0; namedResults != nil && i < namedResults.Len(); i++ {
+			if namedResults.At(i) == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	blockScope := info.Scopes[block]
+	if blockScope == nil {
+		// The body of a function has the scope of its signature.
+		switch fn := enclosingFunc(path).(type) {
+		case *ast.FuncDecl:
+			blockScope = info.Scopes[fn.Type]
+		case *ast.FuncLit:
+			blockScope = info.Scopes[fn.Type]
+		}
+	}
+	for _, v := range assigned {
+		if !usedAfter(v) && !isNamedResult(v) {
+			continue
+		}
+		vars.results = append(vars.results, v)
+		if inSelection(v.Pos()) {
+			vars.declared = append(vars.declared, v)
+		} else if v.Parent() != blockScope {
+			vars.define = false
+		}
+	}
+	errorType := types.Universe.Lookup("error").Type()
+	sort.SliceStable(vars.results, func(i, j int) bool {
+		return !types.Identical(vars.results[i].Type(), errorType) && types.Identical(vars.results[j].Type(), errorType)
+	})
+	return vars, nil
+}
+
+// rootVar returns the variable an assignment to expr changes, nil if it
+// changes a variable only through a pointer, slice or map.
+func rootVar(info *types.Info, expr ast.Expr) *types.Var {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			v, _ := info.Uses[e].(*types.Var)
+			return v
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			if _, ok := info.TypeOf(e.X).Underlying().(*types.Pointer); ok {
+				return nil
+			}
+			expr = e.X
+		case *ast.IndexExpr:
+			if _, ok := info.TypeOf(e.X).Underlying().(*types.Array); !ok {
+				return nil
+			}
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// containsVar reports whether vars contains v.
+func containsVar(vars []*types.Var, v *types.Var) bool {
+	for _, w := range vars {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// trimSelection returns the selection from start to end without the
+// white space around it.
+func trimSelection(tok *token.File, content []byte, start, end token.Pos) (token.Pos, token.Pos) {
+	s, e := tok.Offset(start), tok.Offset(end)
+	for s < e && isSpace(content[s]) {
+		s++
+	}
+	for e > s && isSpace(content[e-1]) {
+		e--
+	}
+	return tok.Pos(s), tok.Pos(e)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// lineIndent returns the white space preceding pos on its line. It is not
+// ok if something else precedes pos.
+func lineIndent(tok *token.File, content []byte, pos token.Pos) (string, bool) {
+	offset := tok.Offset(pos)
+	line := offset
+	for line > 0 && content[line-1] != '\n' {
+		line--
+	}
+	indent := string(content[line:offset])
+	return indent, strings.TrimLeft(indent, " \t") == ""
+}
@@ -0,0 +1,199 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// FillStruct returns the refactoring adding the missing fields of the
+// struct composite literal enclosing pos, with their zero values. The
+// unexported fields are only added within the package of the struct, and
+// the fields of nested structs are not filled. A field whose zero value
+// names a type of a package the file does not import is left out.
+func FillStruct(ctx context.Context, f File, pos token.Pos) []QuickFix {
+	file, tok := f.GetAST(ctx), f.GetToken(ctx)
+	pkg := f.GetPackage(ctx)
+	if file == nil || tok == nil || pkg == nil || pkg.GetTypesInfo() == nil {
+		return nil
+	}
+	content := f.GetContent(ctx)
+
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var lit *ast.CompositeLit
+	for _, n := range path {
+		if n, ok := n.(*ast.CompositeLit); ok {
+			lit = n
+			break
+		}
+	}
+	if lit == nil || !lit.Lbrace.IsValid() || !lit.Rbrace.IsValid() {
+		return nil
+	}
+	typ := pkg.GetTypesInfo().TypeOf(lit)
+	if typ == nil {
+		return nil
+	}
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+
+	present := make(map[string]bool)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			// The fields of a literal without keys are all present.
+			return nil
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok {
+			present[key.Name] = true
+		}
+	}
+
+	qual, imported := fileQualifier(file, pkg.GetTypes())
+	var fields []string
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if present[field.Name()] || field.Name() == "_" || !field.Exported() && field.Pkg() != pkg.GetTypes() {
+			continue
+		}
+		value, ok := zeroValue(field.Type(), qual, imported)
+		if !ok {
+			continue
+		}
+		fields = append(fields, field.Name()+": "+value+",")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	text, err := filledLiteral(tok, content, lit, fields)
+	if err != nil {
+		return nil
+	}
+	edit, err := newTextEdit(f.GetFileSet(ctx), lit.Lbrace, lit.Rbrace+1, text)
+	if err != nil {
+		return nil
+	}
+
+	name := "struct"
+	if named, ok := typ.(*types.Named); ok {
+		name = named.Obj().Name()
+	}
+	return []QuickFix{{
+		Title: fmt.Sprintf("Fill %s", name),
+		Edits: []TextEdit{edit},
+	}}
+}
+
+// filledLiteral returns the braces of the composite literal lit with the
+// fields added after its elements, one per line, formatted like gofmt
+// does at the indentation of the line the literal starts on.
+func filledLiteral(tok *token.File, content []byte, lit *ast.CompositeLit, fields []string) (string, error) {
+	lbrace, rbrace := tok.Offset(lit.Lbrace), tok.Offset(lit.Rbrace)
+	elts := string(content[lbrace+1 : rbrace])
+	if n := len(lit.Elts); n > 0 {
+		end := tok.Offset(lit.Elts[n-1].End()) - lbrace - 1
+		if !strings.HasPrefix(strings.TrimLeft(elts[end:], " \t"), ",") {
+			elts = elts[:end] + "," + elts[end:]
+		}
+	}
+	// The fields of a literal on a single line move to their own lines.
+	if trimmed := strings.TrimLeft(elts, " \t"); !strings.HasPrefix(trimmed, "\n") {
+		elts = "\n" + trimmed
+	}
+	elts = strings.TrimRight(elts, " \t\n")
+
+	const prefix = "package p\n\nvar _ = T"
+	src := prefix + "{" + elts + "\n" + strings.Join(fields, "\n") + "\n}\n"
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(string(formatted), prefix), "\n")
+
+	line := lbrace
+	for line > 0 && content[line-1] != '\n' {
+		line--
+	}
+	indent := line
+	for indent < len(content) && (content[indent] == ' ' || content[indent] == '\t') {
+		indent++
+	}
+	return strings.Replace(text, "\n", "\n"+string(content[line:indent]), -1), nil
+}
+
+// fileQualifier returns the qualifier of the types named in file of the
+// package pkg, by the names file imports their package with, along with
+// the function reporting whether file imports a package.
+func fileQualifier(file *ast.File, pkg *types.Package) (types.Qualifier, func(*types.Package) bool) {
+	names := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := assumedPackageName(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[path] = name
+	}
+	qual := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		switch name, ok := names[p.Path()]; {
+		case !ok || name == "_":
+			return p.Name()
+		case name == ".":
+			return ""
+		default:
+			return name
+		}
+	}
+	imported := func(p *types.Package) bool {
+		if p == nil || p == pkg {
+			return true
+		}
+		name, ok := names[p.Path()]
+		return ok && name != "_"
+	}
+	return qual, imported
+}
+
+// zeroValue returns the expression of the zero value of typ, as qualified
+// by qual. It is not ok if the expression names a type of a package which
+// is not imported.
+func zeroValue(typ types.Type, qual types.Qualifier, imported func(*types.Package) bool) (string, bool) {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return "false", true
+		case t.Info()&types.IsNumeric != 0:
+			return "0", true
+		case t.Info()&types.IsString != 0:
+			return `""`, true
+		}
+		return "nil", true
+	case *types.Struct, *types.Array:
+		var buf bytes.Buffer
+		ok := true
+		types.WriteType(&buf, typ, func(p *types.Package) string {
+			ok = ok && imported(p)
+			return qual(p)
+		})
+		return buf.String() + "{}", ok
+	}
+	return "nil", true
+}
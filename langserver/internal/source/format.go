@@ -8,9 +8,14 @@ package source
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/saibing/bingo/langserver/internal/diff"
@@ -19,51 +24,228 @@ import (
 	"golang.org/x/tools/imports"
 )
 
-// Format formats a file with a given range.
+// Format formats a file with a given range. If the range does not cover the
+// whole file, only the top-level declarations it overlaps are formatted, so
+// a range inside a declaration formats the whole declaration. There are no
+// edits if the file has syntax errors.
 func Format(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
-	fAST := f.GetAST(ctx)
-	path, exact := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
-	if !exact || len(path) == 0 {
-		return nil, fmt.Errorf("no exact AST node matching the specified range")
-	}
-	node := path[0]
-	// format.Node can fail when the AST contains a bad expression or
-	// statement. For now, we preemptively check for one.
-	// TODO(rstambler): This should really return an error from format.Node.
-	var isBad bool
-	ast.Inspect(node, func(n ast.Node) bool {
-		switch n.(type) {
-		case *ast.BadDecl, *ast.BadExpr, *ast.BadStmt:
-			isBad = true
-			return false
-		default:
-			return true
-		}
-	})
-	if isBad {
-		return nil, fmt.Errorf("unable to format file due to a badly formatted AST")
+	tok := f.GetToken(ctx)
+	content := f.GetContent(ctx)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, tok.Name(), content, parser.ParseComments)
+	if err != nil {
+		return nil, nil
 	}
 	// format.Node changes slightly from one release to another, so the version
 	// of Go used to build the LSP server will determine how it formats code.
 	// This should be acceptable for all users, who likely be prompted to rebuild
 	// the LSP server on each Go release.
-	fset := f.GetFileSet(ctx)
-	buf := &bytes.Buffer{}
-	if err := format.Node(buf, fset, node); err != nil {
+	start, end := tok.Offset(rng.Start), tok.Offset(rng.End)
+	if start <= 0 && end >= len(content) {
+		buf := &bytes.Buffer{}
+		if err := format.Node(buf, fset, file); err != nil {
+			return nil, err
+		}
+		return computeTextEdits(ctx, f, buf.String()), nil
+	}
+	formatted, err := formatDecls(fset, file, content, start, end)
+	if err != nil {
 		return nil, err
 	}
-	return computeTextEdits(ctx, f, buf.String()), nil
+	return computeTextEdits(ctx, f, string(formatted)), nil
+}
+
+// formatDecls formats the top-level declarations of file overlapping the
+// range start:end of its content, with their comments, and leaves the rest
+// of the content as is.
+func formatDecls(fset *token.FileSet, file *ast.File, content []byte, start, end int) ([]byte, error) {
+	tok := fset.File(file.Pos())
+	buf := &bytes.Buffer{}
+	last := 0
+	for _, decl := range file.Decls {
+		from, to := declPos(decl)
+		declStart, declEnd := tok.Offset(from), tok.Offset(to)
+		overlaps := start < declEnd && end > declStart
+		if start == end {
+			overlaps = declStart <= start && start <= declEnd
+		}
+		if !overlaps {
+			continue
+		}
+
+		var comments []*ast.CommentGroup
+		for _, c := range file.Comments {
+			if from <= c.Pos() && c.End() <= to {
+				comments = append(comments, c)
+			}
+		}
+		buf.Write(content[last:declStart])
+		if err := format.Node(buf, fset, &printer.CommentedNode{Node: decl, Comments: comments}); err != nil {
+			return nil, err
+		}
+		last = declEnd
+	}
+	buf.Write(content[last:])
+	return buf.Bytes(), nil
 }
 
-// Imports formats a file using the goimports tool.
-func Imports(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
-	formatted, err := imports.Process(f.GetToken(ctx).Name(), f.GetContent(ctx), nil)
+// declPos returns the extent of a top-level declaration, from its doc
+// comment. The comments following it on its last line are not part of it,
+// since the printer would drop them.
+func declPos(decl ast.Decl) (from, to token.Pos) {
+	from, to = decl.Pos(), decl.End()
+	switch decl := decl.(type) {
+	case *ast.GenDecl:
+		if decl.Doc != nil {
+			from = decl.Doc.Pos()
+		}
+	case *ast.FuncDecl:
+		if decl.Doc != nil {
+			from = decl.Doc.Pos()
+		}
+	}
+	return from, to
+}
+
+// PackageIndex resolves the imports of a file formatted like goimports
+// does, instead of scanning GOPATH for the packages it refers to.
+type PackageIndex interface {
+	// PackageName returns the name of the package importPath, "" if it
+	// is unknown.
+	PackageName(importPath string) string
+
+	// FindPackage returns the path of a package named name which exports
+	// all of exports and may be imported by the package pkgPath, "" if
+	// there is none.
+	FindPackage(pkgPath, name string, exports []string) string
+}
+
+// Imports formats a file using the goimports tool. If index is not nil,
+// the imports are added and removed with the packages it knows rather
+// than those goimports finds. The imports are then merged into one
+// declaration, and grouped into the standard library, the other packages
+// and the local packages whose path starts with one of the prefixes of
+// local. There are no edits if the file has syntax errors.
+func Imports(ctx context.Context, f File, rng span.Range, index PackageIndex, local []string) ([]TextEdit, error) {
+	filename, content := f.GetToken(ctx).Name(), f.GetContent(ctx)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if err != nil {
+		return nil, nil
+	}
+
+	opt := &imports.Options{Comments: true, TabIndent: true, TabWidth: 8}
+	if index != nil {
+		var pkgPath string
+		var scope *types.Scope
+		if pkg := f.GetPackage(ctx); pkg != nil && pkg.GetTypes() != nil {
+			pkgPath, scope = pkg.GetPkgPath(), pkg.GetTypes().Scope()
+		}
+		if fixImports(fset, file, pkgPath, scope, index) {
+			buf := &bytes.Buffer{}
+			if err := format.Node(buf, fset, file); err != nil {
+				return nil, err
+			}
+			content = buf.Bytes()
+		}
+		opt.FormatOnly = true
+	}
+
+	formatted, err := imports.Process(filename, content, opt)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err = groupImports(filename, formatted, local)
 	if err != nil {
 		return nil, err
 	}
 	return computeTextEdits(ctx, f, string(formatted)), nil
 }
 
+// fixImports adds to file the imports of the packages of index its
+// unresolved selectors refer to, and removes the imports of the packages
+// of index it does not use. The names declared by the other files of the
+// package pkgPath are looked up in scope. fixImports reports whether it
+// changed the imports.
+func fixImports(fset *token.FileSet, file *ast.File, pkgPath string, scope *types.Scope, index PackageIndex) bool {
+	// refs holds the selected names of the identifiers which may refer to
+	// an imported package.
+	refs := make(map[string][]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+			refs[x.Name] = append(refs[x.Name], sel.Sel.Name)
+		}
+		return true
+	})
+
+	imported := make(map[string]bool)
+	var unused []*ast.ImportSpec
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			imported[spec.Name.Name] = true
+			if _, used := refs[spec.Name.Name]; !used && spec.Name.Name != "_" && spec.Name.Name != "." {
+				unused = append(unused, spec)
+			}
+			continue
+		}
+		name := index.PackageName(importPath)
+		if name == "" {
+			// The package is unknown, so it is kept.
+			imported[assumedPackageName(importPath)] = true
+			continue
+		}
+		imported[name] = true
+		if _, used := refs[name]; !used {
+			unused = append(unused, spec)
+		}
+	}
+
+	changed := false
+	for _, spec := range unused {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		var name string
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		changed = astutil.DeleteNamedImport(fset, file, name, importPath) || changed
+	}
+
+	for name, exports := range refs {
+		if imported[name] || types.Universe.Lookup(name) != nil || scope != nil && scope.Lookup(name) != nil {
+			continue
+		}
+		importPath := index.FindPackage(pkgPath, name, exports)
+		if importPath == "" {
+			continue
+		}
+		var explicit string
+		if assumedPackageName(importPath) != name {
+			explicit = name
+		}
+		changed = astutil.AddNamedImport(fset, file, explicit, importPath) || changed
+	}
+	return changed
+}
+
+// assumedPackageName returns the name goimports assumes the package
+// importPath has: the last element of the path, without its extension and
+// "go-" prefix, eg. "yaml" for "gopkg.in/go-yaml.v2".
+func assumedPackageName(importPath string) string {
+	name := path.Base(importPath)
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimPrefix(name, "go-")
+}
+
 func computeTextEdits(ctx context.Context, file File, formatted string) (edits []TextEdit) {
 	u := strings.SplitAfter(string(file.GetContent(ctx)), "\n")
 	f := strings.SplitAfter(formatted, "\n")
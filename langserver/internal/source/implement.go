@@ -0,0 +1,366 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ImplementFixes returns the fix of the error of a value of type typ, eg.
+// "*Server", which does not implement the interface iface, eg.
+// "net/http.Handler" or "http.Handler", as the errors of the type checker
+// and compiler name them. The fix adds the stubs of the missing methods
+// after the declaration of the type, which must be declared in the package
+// of f.
+func ImplementFixes(ctx context.Context, v View, f File, typ, iface string) []QuickFix {
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return nil
+	}
+
+	pointer := strings.HasPrefix(typ, "*")
+	tn, ok := pkg.GetTypes().Scope().Lookup(strings.TrimPrefix(typ, "*")).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	in := lookupInterface(pkg.GetTypes(), iface)
+	if in == nil {
+		return nil
+	}
+	fix, ok := implement(ctx, v, f, pkg, named, in, pointer)
+	if !ok {
+		return nil
+	}
+	return []QuickFix{fix}
+}
+
+// ImplementInterfaces returns the refactorings adding to the type declared
+// at pos the stubs of the methods it misses to implement the interfaces it
+// is related to, see relatedInterfaces.
+func ImplementInterfaces(ctx context.Context, v View, f File, pos token.Pos) []QuickFix {
+	file, pkg := f.GetAST(ctx), f.GetPackage(ctx)
+	if file == nil || pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return nil
+	}
+
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var spec *ast.TypeSpec
+	for _, n := range path {
+		if n, ok := n.(*ast.TypeSpec); ok {
+			spec = n
+			break
+		}
+	}
+	if spec == nil {
+		return nil
+	}
+	tn, ok := pkg.GetTypesInfo().Defs[spec.Name].(*types.TypeName)
+	if !ok || types.IsInterface(tn.Type()) {
+		return nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+
+	var fixes []QuickFix
+	for _, iface := range relatedInterfaces(pkg, named) {
+		if fix, ok := implement(ctx, v, f, pkg, named, iface, true); ok {
+			fixes = append(fixes, fix)
+		}
+	}
+	return fixes
+}
+
+// lookupInterface returns the named interface of a package imported by
+// pkg, or of pkg if name is not qualified, nil if there is none.
+func lookupInterface(pkg *types.Package, name string) *types.Named {
+	scope := pkg.Scope()
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		qual := name[:i]
+		name = name[i+1:]
+		scope = nil
+		for _, imp := range pkg.Imports() {
+			if imp.Path() == qual || imp.Name() == qual {
+				scope = imp.Scope()
+				break
+			}
+		}
+		if scope == nil {
+			return nil
+		}
+	}
+	tn, ok := scope.Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok || !types.IsInterface(named) {
+		return nil
+	}
+	return named
+}
+
+// implement returns the fix adding to the declaration of the type named
+// the stubs of the methods it misses to implement iface, with receivers
+// like those of its methods: pointers if it has none and pointer is set.
+func implement(ctx context.Context, v View, f File, pkg Package, named *types.Named, iface *types.Named, pointer bool) (QuickFix, bool) {
+	obj := named.Obj()
+	if obj.Pkg() != pkg.GetTypes() {
+		return QuickFix{}, false
+	}
+	missing := MissingMethods(named, iface.Underlying().(*types.Interface), pkg.GetTypes())
+	if len(missing) == 0 {
+		return QuickFix{}, false
+	}
+
+	// The type may be declared in another file of the package.
+	fset := f.GetFileSet(ctx)
+	var file *ast.File
+	for _, syntax := range pkg.GetSyntax() {
+		if syntax.Pos() <= obj.Pos() && obj.Pos() <= syntax.End() {
+			file = syntax
+			break
+		}
+	}
+	if file == nil {
+		return QuickFix{}, false
+	}
+	var decl ast.Decl
+	for _, d := range file.Decls {
+		if d.Pos() <= obj.Pos() && obj.Pos() <= d.End() {
+			decl = d
+			break
+		}
+	}
+	if decl == nil {
+		return QuickFix{}, false
+	}
+	declFile, err := v.GetFile(ctx, span.FileURI(fset.Position(obj.Pos()).Filename))
+	if err != nil {
+		return QuickFix{}, false
+	}
+	content := declFile.GetContent(ctx)
+	tok := fset.File(obj.Pos())
+
+	qual, imported := fileQualifier(file, pkg.GetTypes())
+	var imports []*types.Package
+	seen := make(map[*types.Package]bool)
+	stubQual := func(p *types.Package) string {
+		if !imported(p) && !seen[p] {
+			seen[p] = true
+			imports = append(imports, p)
+		}
+		return qual(p)
+	}
+
+	recv, ptr, ok := receiver(pkg, obj)
+	if !ok {
+		ptr = pointer
+	}
+	if recv == "" {
+		recv = string(unicode.ToLower([]rune(obj.Name())[0]))
+	}
+	recvType := obj.Name()
+	if ptr {
+		recvType = "*" + recvType
+	}
+	var stubs strings.Builder
+	for _, m := range missing {
+		fmt.Fprintf(&stubs, "\n\nfunc (%s %s) %s", recv, recvType, MethodStub(m, stubQual))
+	}
+
+	// The stubs follow the comments at the end of the declaration.
+	end := tok.Offset(decl.End())
+	for end < len(content) && content[end] != '\n' {
+		end++
+	}
+	edit, err := newTextEdit(fset, tok.Pos(end), tok.Pos(end), stubs.String())
+	if err != nil {
+		return QuickFix{}, false
+	}
+	edits, err := importEdits(fset, file, content, imports)
+	if err != nil {
+		return QuickFix{}, false
+	}
+
+	fileQual := qualifier(f.GetAST(ctx), pkg.GetTypes(), pkg.GetTypesInfo())
+	return QuickFix{
+		Title: fmt.Sprintf("Implement %s", types.TypeString(iface, fileQual)),
+		Edits: append(edits, edit),
+	}, true
+}
+
+// receiver returns the name of the receiver of the methods of the type
+// obj declared in pkg, "" if it is unnamed, and whether it is a pointer.
+// It is not ok if the type has no methods.
+func receiver(pkg Package, obj *types.TypeName) (name string, pointer, ok bool) {
+	for _, file := range pkg.GetSyntax() {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+				continue
+			}
+			field := fn.Recv.List[0]
+			typ, ptr := field.Type, false
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ, ptr = star.X, true
+			}
+			if id, ok := typ.(*ast.Ident); !ok || id.Name != obj.Name() {
+				continue
+			}
+			if len(field.Names) == 1 && field.Names[0].Name != "_" {
+				name = field.Names[0].Name
+			}
+			return name, ptr, true
+		}
+	}
+	return "", false, false
+}
+
+// importEdits returns the edits adding the imports of pkgs to file, with
+// the given content. They are merged into the first import declaration,
+// which gets parentheses if needed, in the sorted order gofmt keeps: the
+// packages of the standard library join the first group of imports, the
+// others the last one.
+func importEdits(fset *token.FileSet, file *ast.File, content []byte, pkgs []*types.Package) ([]TextEdit, error) {
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	tok := fset.File(file.Pos())
+
+	type newImport struct {
+		path, text string
+	}
+	var imports []newImport
+	for _, p := range pkgs {
+		text := strconv.Quote(p.Path())
+		if assumedPackageName(p.Path()) != p.Name() {
+			text = p.Name() + " " + text
+		}
+		imports = append(imports, newImport{p.Path(), text})
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		return imports[i].path < imports[j].path
+	})
+	texts := func(specs []ast.Spec) string {
+		var lines []string
+		for _, spec := range specs {
+			lines = append(lines, string(content[tok.Offset(spec.Pos()):tok.Offset(spec.End())]))
+		}
+		for _, imp := range imports {
+			lines = append(lines, imp.text)
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			return importPath(lines[i]) < importPath(lines[j])
+		})
+		return "import (\n\t" + strings.Join(lines, "\n\t") + "\n)"
+	}
+
+	var decl *ast.GenDecl
+	for _, d := range file.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			decl = d
+			break
+		}
+	}
+
+	var edits []TextEdit
+	add := func(pos, end token.Pos, text string) error {
+		edit, err := newTextEdit(fset, pos, end, text)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, edit)
+		return nil
+	}
+	switch {
+	case decl == nil:
+		text := "import " + imports[0].text
+		if len(imports) > 1 {
+			text = texts(nil)
+		}
+		return edits, add(file.Name.End(), file.Name.End(), "\n\n"+text)
+	case !decl.Lparen.IsValid() || len(decl.Specs) == 0:
+		return edits, add(decl.Pos(), decl.End(), texts(decl.Specs))
+	}
+
+	// The groups of imports are separated by blank lines.
+	var groups [][]ast.Spec
+	for i, spec := range decl.Specs {
+		start := spec.Pos()
+		if doc := spec.(*ast.ImportSpec).Doc; doc != nil {
+			start = doc.Pos()
+		}
+		if i == 0 || tok.Line(start) > tok.Line(decl.Specs[i-1].End())+1 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], spec)
+	}
+	lineStart := func(pos token.Pos) token.Pos {
+		offset := tok.Offset(pos)
+		for offset > 0 && content[offset-1] != '\n' {
+			offset--
+		}
+		return tok.Pos(offset)
+	}
+	for _, imp := range imports {
+		group := groups[len(groups)-1]
+		if importGroup(imp.path, nil) == stdlibGroup {
+			group = groups[0]
+		}
+		var at token.Pos
+		for _, spec := range group {
+			if importPath(string(content[tok.Offset(spec.Pos()):tok.Offset(spec.End())])) > imp.path {
+				at = spec.Pos()
+				if doc := spec.(*ast.ImportSpec).Doc; doc != nil {
+					at = doc.Pos()
+				}
+				break
+			}
+		}
+		if !at.IsValid() {
+			// After the last import of the group, at the start of the
+			// next line.
+			last := group[len(group)-1]
+			offset := tok.Offset(last.End())
+			for offset < len(content) && content[offset] != '\n' {
+				offset++
+			}
+			if offset < len(content) {
+				offset++
+			}
+			at = tok.Pos(offset)
+		}
+		if err := add(lineStart(at), lineStart(at), "\t"+imp.text+"\n"); err != nil {
+			return nil, err
+		}
+	}
+	return edits, nil
+}
+
+// importPath returns the path of the text of an import spec, eg. "io" for
+// `myio "io"`.
+func importPath(spec string) string {
+	if i := strings.IndexAny(spec, "\"`"); i >= 0 {
+		spec = spec[i:]
+	}
+	path, err := strconv.Unquote(spec)
+	if err != nil {
+		return spec
+	}
+	return path
+}
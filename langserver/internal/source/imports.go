@@ -0,0 +1,153 @@
+package source
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Import groups, in the order groupImports sorts them.
+const (
+	stdlibGroup = iota
+	externalGroup
+	localGroup
+)
+
+// groupImports merges the import declarations of the formatted source src
+// into one without duplicates, and sorts its imports into groups separated
+// by a blank line: the standard library, the other packages, then the
+// local packages whose path starts with one of the prefixes of local. src
+// is returned as is if it imports "C", whose declaration holds the cgo
+// preamble, or if its imports have comments which do not belong to one of
+// them.
+func groupImports(filename string, src []byte, local []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var decls []*ast.GenDecl
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			break
+		}
+		decls = append(decls, d)
+	}
+	if len(decls) == 0 {
+		return src, nil
+	}
+	tok := fset.File(file.Pos())
+	start, end := decls[0].Pos(), decls[len(decls)-1].End()
+
+	type importLine struct {
+		group int
+		path  string
+		text  string
+	}
+	var lines []importLine
+	// attached holds the comments of the imports, which move along with
+	// them.
+	attached := make(map[*ast.CommentGroup]bool)
+	seen := make(map[string]bool)
+	parens := false
+	for _, d := range decls {
+		parens = parens || d.Lparen.IsValid()
+		for _, spec := range d.Specs {
+			spec := spec.(*ast.ImportSpec)
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || path == "C" {
+				return src, nil
+			}
+
+			from, to := spec.Pos(), spec.End()
+			if spec.Doc != nil {
+				from = spec.Doc.Pos()
+				attached[spec.Doc] = true
+			}
+			if spec.Comment != nil {
+				to = spec.Comment.End()
+				attached[spec.Comment] = true
+			}
+
+			var name string
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			key := name + " " + path
+			if seen[key] {
+				if spec.Doc != nil || spec.Comment != nil {
+					return src, nil
+				}
+				continue
+			}
+			seen[key] = true
+
+			var text []string
+			for _, line := range strings.Split(string(src[tok.Offset(from):tok.Offset(to)]), "\n") {
+				text = append(text, strings.TrimSpace(line))
+			}
+			lines = append(lines, importLine{
+				group: importGroup(path, local),
+				path:  path,
+				text:  strings.Join(text, "\n\t"),
+			})
+		}
+	}
+	if len(lines) == 0 {
+		return src, nil
+	}
+	for _, c := range file.Comments {
+		if start <= c.Pos() && c.End() <= end && !attached[c] {
+			return src, nil
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].group != lines[j].group {
+			return lines[i].group < lines[j].group
+		}
+		return lines[i].path < lines[j].path
+	})
+
+	buf := &bytes.Buffer{}
+	buf.Write(src[:tok.Offset(start)])
+	if len(lines) == 1 && !parens {
+		buf.WriteString("import " + lines[0].text)
+	} else {
+		buf.WriteString("import (\n")
+		for i, line := range lines {
+			if i > 0 && line.group != lines[i-1].group {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\t" + line.text + "\n")
+		}
+		buf.WriteString(")")
+	}
+	buf.Write(src[tok.Offset(end):])
+	return format.Source(buf.Bytes())
+}
+
+// importGroup returns the group of the import path among the standard
+// library, the local packages whose path starts with one of the prefixes
+// of local, and the other packages.
+func importGroup(path string, local []string) int {
+	for _, prefix := range local {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix != "" && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			return localGroup
+		}
+	}
+	// Like goimports, assume that the packages whose first path element
+	// is not a domain name belong to the standard library.
+	if first := strings.Split(path, "/")[0]; !strings.Contains(first, ".") {
+		return stdlibGroup
+	}
+	return externalGroup
+}
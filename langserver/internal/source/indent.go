@@ -0,0 +1,223 @@
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// OnTypeFormat re-indents the lines affected by typing ch just before pos:
+// the lines of the block closed by "}", or the line started by "\n". The
+// indentation of a line is the depth of the blocks, composite literals,
+// field lists and parenthesized lists enclosing it. There are no edits if
+// the file has syntax errors.
+func OnTypeFormat(ctx context.Context, f File, pos token.Pos, ch string) ([]TextEdit, error) {
+	tok := f.GetToken(ctx)
+	content := f.GetContent(ctx)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, tok.Name(), content, parser.ParseComments)
+	if err != nil {
+		return nil, nil
+	}
+	ftok := fset.File(file.Pos())
+	offset := tok.Offset(pos)
+	line := ftok.Line(ftok.Pos(offset))
+
+	first := line
+	switch ch {
+	case "}":
+		if offset == 0 || content[offset-1] != '}' {
+			return nil, nil
+		}
+		if lbrace := openingBrace(file, ftok.Pos(offset-1)); lbrace.IsValid() {
+			first = ftok.Line(lbrace) + 1
+		}
+	case "\n":
+	default:
+		return nil, nil
+	}
+
+	lines := lineOffsets(content)
+	var edits []TextEdit
+	for l := first; l <= line; l++ {
+		start := lines[l-1]
+		ws := start
+		for ws < len(content) && (content[ws] == ' ' || content[ws] == '\t') {
+			ws++
+		}
+		p := ftok.Pos(ws)
+		if inLiteralOrComment(file, p) {
+			continue
+		}
+		indent := strings.Repeat("\t", indentDepth(ftok, file, p))
+		if string(content[start:ws]) == indent {
+			continue
+		}
+		edits = append(edits, TextEdit{
+			Span:    span.New(f.URI(), span.NewPoint(l, 1, start), span.NewPoint(l, ws-start+1, ws)),
+			NewText: indent,
+		})
+	}
+	return edits, nil
+}
+
+// openingBrace returns the position of the brace matching the closing
+// brace at rbrace, or NoPos if there is none.
+func openingBrace(file *ast.File, rbrace token.Pos) token.Pos {
+	lbrace := token.NoPos
+	ast.Inspect(file, func(n ast.Node) bool {
+		if lbrace.IsValid() || n == nil || n.Pos() > rbrace || n.End() <= rbrace {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			if n.Rbrace == rbrace {
+				lbrace = n.Lbrace
+			}
+		case *ast.CompositeLit:
+			if n.Rbrace == rbrace {
+				lbrace = n.Lbrace
+			}
+		case *ast.FieldList:
+			if n.Closing == rbrace {
+				lbrace = n.Opening
+			}
+		}
+		return true
+	})
+	return lbrace
+}
+
+// indentDepth returns the indentation depth of the line whose first token,
+// or end if it is blank, is at p.
+func indentDepth(tok *token.File, file *ast.File, p token.Pos) int {
+	line := tok.Line(p)
+
+	// opens reports whether the list delimited by open and close encloses
+	// the line.
+	opens := func(open, close token.Pos) bool {
+		return open.IsValid() && open < p && p < close && tok.Line(open) < line
+	}
+
+	// clauses holds the bodies of the switch and select statements, whose
+	// clauses are not indented.
+	clauses := make(map[*ast.BlockStmt]bool)
+
+	depth := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if _, ok := n.(*ast.File); !ok && (p < n.Pos() || n.End() < p) {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			if clauses[n] {
+				depth += clauseDepth(tok, n, p)
+			} else if opens(n.Lbrace, n.Rbrace) {
+				depth++
+			}
+		case *ast.SwitchStmt:
+			clauses[n.Body] = true
+		case *ast.TypeSwitchStmt:
+			clauses[n.Body] = true
+		case *ast.SelectStmt:
+			clauses[n.Body] = true
+		case *ast.CompositeLit:
+			if opens(n.Lbrace, n.Rbrace) {
+				depth++
+			}
+		case *ast.FieldList:
+			if opens(n.Opening, n.Closing) {
+				depth++
+			}
+		case *ast.CallExpr:
+			if opens(n.Lparen, n.Rparen) {
+				depth++
+			}
+		case *ast.GenDecl:
+			if opens(n.Lparen, n.Rparen) {
+				depth++
+			}
+		case *ast.LabeledStmt:
+			// Labels are outdented.
+			if n.Pos() == p {
+				depth--
+			}
+		}
+		return true
+	})
+	if depth < 0 {
+		depth = 0
+	}
+	return depth
+}
+
+// clauseDepth returns the indentation depth the body of a switch or
+// select statement adds to the line at p: the clauses are indented like
+// the statement, and their statements once more.
+func clauseDepth(tok *token.File, body *ast.BlockStmt, p token.Pos) int {
+	if p <= body.Lbrace || body.Rbrace <= p {
+		return 0
+	}
+	var colon token.Pos
+	for _, stmt := range body.List {
+		var keyword, clauseColon token.Pos
+		switch clause := stmt.(type) {
+		case *ast.CaseClause:
+			keyword, clauseColon = clause.Case, clause.Colon
+		case *ast.CommClause:
+			keyword, clauseColon = clause.Case, clause.Colon
+		}
+		if keyword == p {
+			return 0
+		}
+		if keyword > p {
+			break
+		}
+		colon = clauseColon
+	}
+	if colon.IsValid() && colon < p && tok.Line(colon) < tok.Line(p) {
+		return 1
+	}
+	return 0
+}
+
+// inLiteralOrComment reports whether p is inside a string literal or a
+// comment of file, whose lines must be left as they are.
+func inLiteralOrComment(file *ast.File, p token.Pos) bool {
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if c.Pos() < p && p < c.End() {
+				return true
+			}
+		}
+	}
+	inside := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if inside || n == nil {
+			return false
+		}
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING && lit.Pos() < p && p < lit.End() {
+			inside = true
+		}
+		return true
+	})
+	return inside
+}
+
+// lineOffsets returns the offsets of the starts of the lines of content.
+func lineOffsets(content []byte) []int {
+	offsets := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,409 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// QuickFix is a change of a file fixing one of its errors, or refactoring
+// its code.
+type QuickFix struct {
+	Title string
+	Edits []TextEdit
+}
+
+// UnusedImportFixes returns the fixes of the unused import at pos, which
+// remove it. An import declaration left with a single import loses its
+// parentheses.
+func UnusedImportFixes(ctx context.Context, f File, pos token.Pos) []QuickFix {
+	file, tok := f.GetAST(ctx), f.GetToken(ctx)
+	if file == nil || tok == nil {
+		return nil
+	}
+	content := f.GetContent(ctx)
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for i, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			if pos < imp.Pos() || pos > imp.End() {
+				continue
+			}
+
+			var start, end token.Pos
+			var text string
+			switch {
+			case !gen.Lparen.IsValid() || len(gen.Specs) == 1:
+				start, end = lineRange(tok, content, gen.Pos(), gen.End())
+			case len(gen.Specs) == 2:
+				other := gen.Specs[1-i]
+				start, end = gen.Pos(), gen.End()
+				text = "import " + string(content[tok.Offset(other.Pos()):tok.Offset(other.End())])
+			default:
+				start, end = imp.Pos(), imp.End()
+				if imp.Doc != nil {
+					start = imp.Doc.Pos()
+				}
+				if imp.Comment != nil {
+					end = imp.Comment.End()
+				}
+				start, end = lineRange(tok, content, start, end)
+			}
+			edit, err := newTextEdit(f.GetFileSet(ctx), start, end, text)
+			if err != nil {
+				return nil
+			}
+			return []QuickFix{{
+				Title: fmt.Sprintf("Remove unused import %s", imp.Path.Value),
+				Edits: []TextEdit{edit},
+			}}
+		}
+	}
+	return nil
+}
+
+// UnusedVariableFixes returns the fixes of the unused variable name
+// declared at pos: renaming it to _, and removing its declaration if this
+// has no side effects.
+func UnusedVariableFixes(ctx context.Context, f File, pos token.Pos, name string) []QuickFix {
+	file, tok := f.GetAST(ctx), f.GetToken(ctx)
+	pkg := f.GetPackage(ctx)
+	if file == nil || tok == nil || pkg == nil {
+		return nil
+	}
+	content := f.GetContent(ctx)
+	fset := f.GetFileSet(ctx)
+	info := pkg.GetTypesInfo()
+
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 3 {
+		return nil
+	}
+	ident, ok := path[0].(*ast.Ident)
+	if !ok || ident.Name != name {
+		return nil
+	}
+
+	var fixes []QuickFix
+	add := func(title string, edits ...TextEdit) {
+		fixes = append(fixes, QuickFix{Title: title, Edits: edits})
+	}
+	edit := func(start, end token.Pos, text string) TextEdit {
+		// The positions are those of the file, so this cannot fail.
+		e, _ := newTextEdit(fset, start, end, text)
+		return e
+	}
+	rename := fmt.Sprintf("Rename %s to _", name)
+	remove := fmt.Sprintf("Remove unused variable %s", name)
+
+	switch parent := path[1].(type) {
+	case *ast.AssignStmt:
+		if parent.Tok != token.DEFINE {
+			return nil
+		}
+		if ts, ok := path[2].(*ast.TypeSwitchStmt); ok && ts.Assign == parent {
+			add(remove, edit(ident.Pos(), parent.Rhs[0].Pos(), ""))
+			return fixes
+		}
+		// With every other variable blank, := would declare none.
+		othersBlank := true
+		for _, lhs := range parent.Lhs {
+			if id, ok := lhs.(*ast.Ident); lhs != ident && (!ok || id.Name != "_") {
+				othersBlank = false
+			}
+		}
+		if !othersBlank {
+			add(rename, edit(ident.Pos(), ident.End(), "_"))
+			return fixes
+		}
+		add(rename,
+			edit(ident.Pos(), ident.End(), "_"),
+			edit(parent.TokPos, parent.TokPos+token.Pos(len(token.DEFINE.String())), "="))
+		if isStatement(path[2]) && !hasSideEffects(info, parent.Rhs) {
+			start, end := lineRange(tok, content, parent.Pos(), parent.End())
+			add(remove, edit(start, end, ""))
+		}
+
+	case *ast.ValueSpec:
+		if len(parent.Values) > 0 || len(parent.Names) > 1 {
+			add(rename, edit(ident.Pos(), ident.End(), "_"))
+		}
+		gen, ok := path[2].(*ast.GenDecl)
+		if !ok || len(parent.Names) != 1 || hasSideEffects(info, parent.Values) {
+			return fixes
+		}
+		var start, end token.Pos
+		if len(gen.Specs) == 1 {
+			start, end = gen.Pos(), gen.End()
+		} else {
+			start, end = parent.Pos(), parent.End()
+			if parent.Doc != nil {
+				start = parent.Doc.Pos()
+			}
+			if parent.Comment != nil {
+				end = parent.Comment.End()
+			}
+		}
+		start, end = lineRange(tok, content, start, end)
+		add(remove, edit(start, end, ""))
+
+	case *ast.RangeStmt:
+		switch {
+		case parent.Key == ident && parent.Value == nil:
+			add(remove, edit(ident.Pos(), parent.X.Pos(), "range "))
+		case parent.Key == ident:
+			add(rename, edit(ident.Pos(), ident.End(), "_"))
+		case parent.Value == ident:
+			add(remove, edit(parent.Key.End(), ident.End(), ""))
+		}
+	}
+	return fixes
+}
+
+// ErrorCheckFixes returns the fixes adding the check of the error which
+// the assignment at pos gets from a call, if the next statement does not
+// check it. The check returns the zero values of the results of the
+// enclosing function along with the error, passed through or wrapped by
+// fmt.Errorf, or returns without values if the function has no results.
+// There are none if the function has results but no error.
+func ErrorCheckFixes(ctx context.Context, f File, pos token.Pos) []QuickFix {
+	file, tok, pkg := f.GetAST(ctx), f.GetToken(ctx), f.GetPackage(ctx)
+	if file == nil || tok == nil || pkg == nil || pkg.GetTypes() == nil || pkg.GetTypesInfo() == nil {
+		return nil
+	}
+	content := f.GetContent(ctx)
+	info := pkg.GetTypesInfo()
+
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var assign *ast.AssignStmt
+	var next ast.Stmt
+	for i, n := range path {
+		stmt, ok := n.(ast.Stmt)
+		if !ok {
+			continue
+		}
+		if assign, ok = stmt.(*ast.AssignStmt); !ok || i+1 == len(path) {
+			return nil
+		}
+		var list []ast.Stmt
+		switch parent := path[i+1].(type) {
+		case *ast.BlockStmt:
+			list = parent.List
+		case *ast.CaseClause:
+			list = parent.Body
+		case *ast.CommClause:
+			list = parent.Body
+		default:
+			return nil
+		}
+		for j, s := range list {
+			if s == stmt && j+1 < len(list) {
+				next = list[j+1]
+			}
+		}
+		break
+	}
+	if assign == nil || len(assign.Rhs) != 1 {
+		return nil
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	id, ok := assign.Lhs[len(assign.Lhs)-1].(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return nil
+	}
+	errorType := types.Universe.Lookup("error").Type()
+	obj := info.ObjectOf(id)
+	if obj == nil || !types.Identical(obj.Type(), errorType) {
+		return nil
+	}
+	if ifStmt, ok := next.(*ast.IfStmt); ok && usesObject(info, ifStmt.Cond, obj) {
+		return nil
+	}
+
+	var sig *types.Signature
+	switch fn := enclosingFunc(path).(type) {
+	case *ast.FuncDecl:
+		if obj, ok := info.Defs[fn.Name].(*types.Func); ok {
+			sig = obj.Type().(*types.Signature)
+		}
+	case *ast.FuncLit:
+		sig, _ = info.TypeOf(fn).(*types.Signature)
+	}
+	if sig == nil {
+		return nil
+	}
+
+	// The error is returned as the last error result.
+	results := sig.Results()
+	last := -1
+	for i := 0; i < results.Len(); i++ {
+		if types.Identical(results.At(i).Type(), errorType) {
+			last = i
+		}
+	}
+	if results.Len() > 0 && last < 0 {
+		return nil
+	}
+
+	qual, imported := fileQualifier(file, pkg.GetTypes())
+	var imports []*types.Package
+	seen := make(map[*types.Package]bool)
+	needs := func(p *types.Package) bool {
+		if !imported(p) && !seen[p] {
+			seen[p] = true
+			imports = append(imports, p)
+		}
+		return true
+	}
+	values := make([]string, results.Len())
+	for i := range values {
+		if i != last {
+			values[i], _ = zeroValue(results.At(i).Type(), qual, needs)
+		}
+	}
+
+	indent, ok := lineIndent(tok, content, assign.Pos())
+	if !ok {
+		return nil
+	}
+	// The check follows the comments at the end of the assignment.
+	end := tok.Offset(assign.End())
+	for end < len(content) && content[end] != '\n' {
+		end++
+	}
+	fset := f.GetFileSet(ctx)
+	fix := func(title string, imports []*types.Package) (QuickFix, bool) {
+		ret := "return"
+		if len(values) > 0 {
+			ret += " " + strings.Join(values, ", ")
+		}
+		text := fmt.Sprintf("\n%sif %s != nil {\n%s\t%s\n%s}", indent, id.Name, indent, ret, indent)
+		edit, err := newTextEdit(fset, tok.Pos(end), tok.Pos(end), text)
+		if err != nil {
+			return QuickFix{}, false
+		}
+		edits, err := importEdits(fset, file, content, imports)
+		if err != nil {
+			return QuickFix{}, false
+		}
+		return QuickFix{Title: title, Edits: append(edits, edit)}, true
+	}
+
+	if last < 0 {
+		if fix, ok := fix(fmt.Sprintf("Check %s and return", id.Name), imports); ok {
+			return []QuickFix{fix}
+		}
+		return nil
+	}
+	var fixes []QuickFix
+	values[last] = id.Name
+	if fix, ok := fix(fmt.Sprintf("Check %s and return it", id.Name), imports); ok {
+		fixes = append(fixes, fix)
+	}
+
+	fmtPkg := types.NewPackage("fmt", "fmt")
+	for _, imp := range pkg.GetTypes().Imports() {
+		if imp.Path() == "fmt" {
+			fmtPkg = imp
+		}
+	}
+	callee := string(content[tok.Offset(call.Fun.Pos()):tok.Offset(call.Fun.End())])
+	values[last] = fmt.Sprintf("%s.Errorf(%q, %s)", qual(fmtPkg), callee+": %w", id.Name)
+	needs(fmtPkg)
+	if fix, ok := fix(fmt.Sprintf("Check %s and return it wrapped", id.Name), imports); ok {
+		fixes = append(fixes, fix)
+	}
+	return fixes
+}
+
+// usesObject reports whether the expression expr uses obj.
+func usesObject(info *types.Info, expr ast.Expr, obj types.Object) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// isStatement reports whether n holds a list of statements.
+func isStatement(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+		return true
+	}
+	return false
+}
+
+// hasSideEffects reports whether evaluating the expressions may have side
+// effects: whether they call a function or receive from a channel.
+// Conversions have none.
+func hasSideEffects(info *types.Info, exprs []ast.Expr) bool {
+	found := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.CallExpr:
+				if info == nil || !info.Types[n.Fun].IsType() {
+					found = true
+				}
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// lineRange extends the range from start to end to the whole lines it is
+// on, including the line terminator, if nothing else is on these lines.
+func lineRange(tok *token.File, content []byte, start, end token.Pos) (token.Pos, token.Pos) {
+	s, e := tok.Offset(start), tok.Offset(end)
+	for s > 0 && (content[s-1] == ' ' || content[s-1] == '\t') {
+		s--
+	}
+	if s > 0 && content[s-1] != '\n' {
+		return start, end
+	}
+	for e < len(content) && (content[e] == ' ' || content[e] == '\t' || content[e] == '\r') {
+		e++
+	}
+	switch {
+	case e == len(content):
+	case content[e] == '\n':
+		e++
+	default:
+		return start, end
+	}
+	return tok.Pos(s), tok.Pos(e)
+}
+
+// newTextEdit returns the edit replacing the text from start to end by
+// text.
+func newTextEdit(fset *token.FileSet, start, end token.Pos, text string) (TextEdit, error) {
+	s, err := span.NewRange(fset, start, end).Span()
+	if err != nil {
+		return TextEdit{}, err
+	}
+	return TextEdit{Span: s, NewText: text}, nil
+}
@@ -11,6 +11,8 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"strings"
+	"unicode"
 
 	"golang.org/x/tools/go/ast/astutil"
 )
@@ -22,7 +24,8 @@ type SignatureInformation struct {
 }
 
 type ParameterInformation struct {
-	Label string
+	Label         string
+	Documentation string
 }
 
 func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Package, enhance bool) (*SignatureInformation, error) {
@@ -38,16 +41,34 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 	if path == nil {
 		return nil, fmt.Errorf("cannot find node enclosing position")
 	}
+FindCall:
 	for _, node := range path {
-		if c, ok := node.(*ast.CallExpr); ok {
-			callExpr = c
-			break
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			// The cursor must be inside the parentheses of the call, so that
+			// the function name of a nested call belongs to the outer call.
+			if node.Lparen < pos && pos <= node.Rparen {
+				callExpr = node
+				break FindCall
+			}
+		case *ast.CompositeLit:
+			// The elements of a composite literal are no arguments.
+			if node.Lbrace < pos && pos <= node.Rbrace {
+				return nil, nil
+			}
 		}
 	}
 	if callExpr == nil || callExpr.Fun == nil {
 		return nil, nil
 	}
 
+	pkgStringer := qualifier(fAST, pkg.GetTypes(), pkg.GetTypesInfo())
+
+	// A conversion looks like a call, but its operand is a type.
+	if tv, ok := pkg.GetTypesInfo().Types[callExpr.Fun]; ok && tv.IsType() {
+		return conversionSignature(callExpr, tv.Type, pkgStringer), nil
+	}
+
 	// Get the type information for the function corresponding to the call expression.
 	var obj types.Object
 	switch t := callExpr.Fun.(type) {
@@ -55,41 +76,80 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 		obj = pkg.GetTypesInfo().ObjectOf(t)
 	case *ast.SelectorExpr:
 		obj = pkg.GetTypesInfo().ObjectOf(t.Sel)
-	default:
-		return nil, fmt.Errorf("the enclosing function is malformed")
-	}
-	if obj == nil {
-		return nil, fmt.Errorf("cannot resolve %s", callExpr.Fun)
 	}
-	// Find the signature corresponding to the object.
+	// Find the signature corresponding to the object, and the package
+	// to look up its doc comment in.
 	var sig *types.Signature
+	docPkg := pkg
 	switch obj.(type) {
-	case *types.Var:
-		if underlying, ok := obj.Type().Underlying().(*types.Signature); ok {
-			sig = underlying
-		}
 	case *types.Func:
 		sig = obj.Type().(*types.Signature)
 
 	case *types.Builtin:
-		obj = FindObject(builtinPkg, obj)
+		docPkg = builtinPkg
+		builtin := FindObject(builtinPkg, obj)
+		if builtin == nil {
+			return nil, fmt.Errorf("no function signatures found for %s", obj.Name())
+		}
+		obj = builtin
 		if _, ok := obj.(*types.Func); ok {
 			sig = obj.Type().(*types.Signature)
 		}
 	}
+
+	// Label for function, qualified by package name.
+	var label string
+	if obj != nil {
+		label = obj.Name()
+		if pkg := pkgStringer(obj.Pkg()); pkg != "" {
+			label = pkg + "." + label
+		}
+	}
+
+	// The signature of a method expression has the receiver as its
+	// first parameter.
+	if sel, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
+		if s, ok := pkg.GetTypesInfo().Selections[sel]; ok && s.Kind() == types.MethodExpr {
+			sig, _ = pkg.GetTypesInfo().TypeOf(sel).(*types.Signature)
+			label = types.ExprString(sel.X) + "." + label
+		}
+	}
+
+	// Calls through variables, struct fields, map values or returned
+	// closures use the function type of the called expression.
 	if sig == nil {
-		return nil, fmt.Errorf("no function signatures found for %s", obj.Name())
+		if typ := pkg.GetTypesInfo().TypeOf(callExpr.Fun); typ != nil {
+			sig, _ = typ.Underlying().(*types.Signature)
+		}
+		if obj == nil {
+			label = types.ExprString(callExpr.Fun)
+		}
+	}
+	if sig == nil {
+		return nil, fmt.Errorf("no function signatures found for %s", types.ExprString(callExpr.Fun))
+	}
+	var doc string
+	if obj != nil {
+		doc, _ = FindComments(docPkg, docPkg.GetFileSet(), obj, obj.Name())
 	}
-	pkgStringer := qualifier(fAST, pkg.GetTypes(), pkg.GetTypesInfo())
 	var paramInfo []ParameterInformation
+	var paramLabels []string
 	for i := 0; i < sig.Params().Len(); i++ {
 		param := sig.Params().At(i)
-		label := types.TypeString(param.Type(), pkgStringer)
-		if param.Name() != "" {
-			label = fmt.Sprintf("%s %s", param.Name(), label)
+		paramLabel := types.TypeString(param.Type(), pkgStringer)
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			paramLabel = "..." + types.TypeString(param.Type().(*types.Slice).Elem(), pkgStringer)
+		}
+		// Function types may omit the names of their parameters.
+		name := param.Name()
+		if name == "" {
+			name = "_"
 		}
+		paramLabel = name + " " + paramLabel
+		paramLabels = append(paramLabels, paramLabel)
 		paramInfo = append(paramInfo, ParameterInformation{
-			Label: label,
+			Label:         paramLabel,
+			Documentation: paramDoc(doc, param.Name()),
 		})
 	}
 	// Determine the query position relative to the number of parameters in the function.
@@ -100,13 +160,12 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 			break
 		}
 	}
-	// Label for function, qualified by package name.
-	label := obj.Name()
-	if pkg := pkgStringer(obj.Pkg()); pkg != "" {
-		label = pkg + "." + label
+	// All arguments past the last declared parameter of a variadic
+	// function belong to the variadic parameter.
+	if n := sig.Params().Len(); sig.Variadic() && activeParam >= n {
+		activeParam = n - 1
 	}
-
-	label += formatParams(sig.Params(), sig.Variadic(), pkgStringer)
+	label += "(" + strings.Join(paramLabels, ", ") + ")"
 	if enhance {
 		label += formatResults(sig.Results(), pkgStringer)
 	}
@@ -118,6 +177,71 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 	}, nil
 }
 
+// conversionSignature returns a pseudo-signature for the conversion of a
+// value to typ, which takes a single value of the underlying type.
+func conversionSignature(callExpr *ast.CallExpr, typ types.Type, qualifier types.Qualifier) *SignatureInformation {
+	param := "value " + types.TypeString(typ.Underlying(), qualifier)
+	return &SignatureInformation{
+		Label:      types.ExprString(callExpr.Fun) + "(" + param + ")",
+		Parameters: []ParameterInformation{{Label: param}},
+	}
+}
+
+// paramDoc returns the documentation of the parameter name in the doc
+// comment of its function, following the convention of a line starting
+// with "name:" or "name -". The documentation continues on the following
+// lines up to a blank line or the documentation of another parameter.
+func paramDoc(doc, name string) string {
+	if name == "" || name == "_" {
+		return ""
+	}
+
+	var lines []string
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if lines == nil {
+			if rest, ok := cutParamPrefix(line, name); ok {
+				lines = []string{rest}
+			}
+			continue
+		}
+		if line == "" || isParamLine(line) {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, " "))
+}
+
+// cutParamPrefix returns the rest of line if it documents the parameter
+// name.
+func cutParamPrefix(line, name string) (string, bool) {
+	if !strings.HasPrefix(line, name) {
+		return "", false
+	}
+	rest := strings.TrimLeft(line[len(name):], " \t")
+	switch {
+	case strings.HasPrefix(rest, ":"):
+		return rest[1:], true
+	case strings.HasPrefix(rest, "- "), rest == "-":
+		return rest[1:], true
+	}
+	return "", false
+}
+
+// isParamLine reports whether line starts the documentation of a
+// parameter.
+func isParamLine(line string) bool {
+	i := strings.IndexFunc(line, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if i <= 0 {
+		return false
+	}
+	_, ok := cutParamPrefix(line, line[:i])
+	return ok
+}
+
 func formatResults(t *types.Tuple, qualifier types.Qualifier) string {
 	if t.Len() == 0 {
 		return ""
@@ -0,0 +1,344 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// tagKeys are the keys of the tags StructTagFixes adds to the fields.
+var tagKeys = []string{"json", "yaml"}
+
+// StructTagFixes returns the fixes adding the json and yaml tags of the
+// fields of a struct type at the selection from start to end, adding the
+// omitempty option to those tags, and removing the tags of each key the
+// fields have. The fields are those of the selection, or all the fields of
+// the struct if it is on its declaration. The names of the tags are those
+// of the fields in the case tagCase, see tagName. The other tags of the
+// fields are kept as they are.
+func StructTagFixes(ctx context.Context, f File, start, end token.Pos, tagCase string) []QuickFix {
+	file := f.GetAST(ctx)
+	if file == nil {
+		return nil
+	}
+	fields := selectedFields(file, start, end)
+	if len(fields) == 0 {
+		return nil
+	}
+	fset := f.GetFileSet(ctx)
+
+	var fixes []QuickFix
+	add := func(title string, rewrite func(field *ast.Field, tags structTags) (structTags, bool)) {
+		var edits []TextEdit
+		for _, field := range fields {
+			tags, ok := fieldTags(field)
+			if !ok {
+				// The tag is not in the conventional format.
+				continue
+			}
+			tags, changed := rewrite(field, tags)
+			if !changed {
+				continue
+			}
+			edit, err := tagEdit(fset, field, tags)
+			if err != nil {
+				continue
+			}
+			edits = append(edits, edit)
+		}
+		if len(edits) > 0 {
+			fixes = append(fixes, QuickFix{Title: title, Edits: edits})
+		}
+	}
+
+	for _, key := range tagKeys {
+		key := key
+		add(fmt.Sprintf("Add %s tags", key), func(field *ast.Field, tags structTags) (structTags, bool) {
+			// The encoders ignore the unexported fields, and name
+			// the embedded ones after their type.
+			if len(field.Names) != 1 || !field.Names[0].IsExported() || tags.lookup(key) >= 0 {
+				return tags, false
+			}
+			return tags.set(key, tagName(field.Names[0].Name, tagCase)), true
+		})
+	}
+	for _, key := range tagKeys {
+		key := key
+		add(fmt.Sprintf("Add omitempty to %s tags", key), func(field *ast.Field, tags structTags) (structTags, bool) {
+			i := tags.lookup(key)
+			if i < 0 || tags[i].value == "-" {
+				return tags, false
+			}
+			options := strings.Split(tags[i].value, ",")
+			for _, option := range options[1:] {
+				if option == "omitempty" {
+					return tags, false
+				}
+			}
+			return tags.set(key, tags[i].value+",omitempty"), true
+		})
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		tags, _ := fieldTags(field)
+		for _, tag := range tags {
+			if !seen[tag.key] {
+				seen[tag.key] = true
+				keys = append(keys, tag.key)
+			}
+		}
+	}
+	for _, key := range keys {
+		key := key
+		add(fmt.Sprintf("Remove %s tags", key), func(field *ast.Field, tags structTags) (structTags, bool) {
+			if tags.lookup(key) < 0 {
+				return tags, false
+			}
+			return tags.remove(key), true
+		})
+	}
+	return fixes
+}
+
+// selectedFields returns the fields of the struct type of file the
+// selection from start to end is in: the field it is in, the fields it
+// overlaps, or all the fields if it is on the declaration of the type or
+// the struct keyword.
+func selectedFields(file *ast.File, start, end token.Pos) []*ast.Field {
+	path, _ := astutil.PathEnclosingInterval(file, start, end)
+	for i, n := range path {
+		switch n := n.(type) {
+		case *ast.Field:
+			// The fields of the parameters and results of a func
+			// type are not struct fields.
+			if i+2 < len(path) {
+				if _, ok := path[i+2].(*ast.StructType); ok {
+					return []*ast.Field{n}
+				}
+			}
+		case *ast.StructType:
+			if start == end {
+				return n.Fields.List
+			}
+			var fields []*ast.Field
+			for _, field := range n.Fields.List {
+				if field.Pos() < end && start < field.End() {
+					fields = append(fields, field)
+				}
+			}
+			return fields
+		case *ast.TypeSpec:
+			if st, ok := n.Type.(*ast.StructType); ok {
+				return st.Fields.List
+			}
+			return nil
+		case *ast.GenDecl:
+			if n.Tok != token.TYPE || len(n.Specs) != 1 {
+				return nil
+			}
+			if st, ok := n.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType); ok {
+				return st.Fields.List
+			}
+			return nil
+		case *ast.FuncDecl, *ast.BlockStmt:
+			return nil
+		}
+	}
+	return nil
+}
+
+// structTag is a key:"value" pair of a struct tag.
+type structTag struct {
+	key, value string
+
+	// raw is the pair as it is written in the tag.
+	raw string
+}
+
+// structTags are the pairs of a struct tag, in order.
+type structTags []structTag
+
+// fieldTags returns the pairs of the tag of field, and whether it is in
+// the conventional format of a struct tag, see reflect.StructTag.
+func fieldTags(field *ast.Field) (structTags, bool) {
+	if field.Tag == nil {
+		return nil, true
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return nil, false
+	}
+
+	// This is the parser of reflect.StructTag.Lookup.
+	var tags structTags
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil, false
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return nil, false
+		}
+		quoted := tag[:i+1]
+		tag = tag[i+1:]
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, false
+		}
+		tags = append(tags, structTag{key: key, value: value, raw: key + ":" + quoted})
+	}
+	return tags, true
+}
+
+// lookup returns the index of the pair of key, or -1.
+func (tags structTags) lookup(key string) int {
+	for i, tag := range tags {
+		if tag.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// set returns the tags with the value of key, which is added after the
+// others if it is not there yet.
+func (tags structTags) set(key, value string) structTags {
+	tag := structTag{key: key, value: value, raw: key + ":" + strconv.Quote(value)}
+	result := append(structTags(nil), tags...)
+	if i := result.lookup(key); i >= 0 {
+		result[i] = tag
+		return result
+	}
+	return append(result, tag)
+}
+
+// remove returns the tags without the pair of key.
+func (tags structTags) remove(key string) structTags {
+	var result structTags
+	for _, tag := range tags {
+		if tag.key != key {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// tagEdit returns the edit replacing the tag of field by tags, quoted like
+// it was, or removing it if there are no tags. The tags of a field without
+// one are raw strings.
+func tagEdit(fset *token.FileSet, field *ast.Field, tags structTags) (TextEdit, error) {
+	var pairs []string
+	for _, tag := range tags {
+		pairs = append(pairs, tag.raw)
+	}
+	text := strings.Join(pairs, " ")
+
+	if len(tags) == 0 {
+		if field.Tag == nil {
+			return TextEdit{}, fmt.Errorf("field has no tag")
+		}
+		return newTextEdit(fset, field.Type.End(), field.Tag.End(), "")
+	}
+	literal := "`" + text + "`"
+	if field.Tag != nil && field.Tag.Value[0] == '"' || strings.ContainsRune(text, '`') {
+		literal = strconv.Quote(text)
+	}
+	if field.Tag == nil {
+		return newTextEdit(fset, field.Type.End(), field.Type.End(), " "+literal)
+	}
+	return newTextEdit(fset, field.Tag.Pos(), field.Tag.End(), literal)
+}
+
+// tagName returns the name of the field name in a tag, in the case
+// tagCase: "snakecase", the default, "camelcase", "lispcase",
+// "pascalcase", or "keep".
+func tagName(name, tagCase string) string {
+	words := splitName(name)
+	switch tagCase {
+	case "keep":
+		return name
+	case "camelcase":
+		words[0] = strings.ToLower(words[0])
+		return strings.Join(words, "")
+	case "pascalcase":
+		for i, word := range words {
+			r, size := utf8.DecodeRuneInString(word)
+			words[i] = string(unicode.ToUpper(r)) + word[size:]
+		}
+		return strings.Join(words, "")
+	case "lispcase":
+		return strings.ToLower(strings.Join(words, "-"))
+	default:
+		return strings.ToLower(strings.Join(words, "_"))
+	}
+}
+
+// splitName splits the identifier name into the words of its mixed caps
+// and underscores, keeping the initialisms whole, eg. "HTTPServerID" into
+// "HTTP", "Server" and "ID".
+func splitName(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			// A word starts after a lower case letter or a digit, and
+			// at the last upper case letter of an initialism followed
+			// by a lower case letter, but for a plural, eg. "IDs".
+			if !unicode.IsUpper(prev) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && !isPlural(runes[i+1:]) {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 || len(words) == 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// isPlural reports whether the runes following an initialism are the s of
+// its plural, which ends the word.
+func isPlural(runes []rune) bool {
+	return runes[0] == 's' && (len(runes) == 1 || !unicode.IsLower(runes[1]))
+}
@@ -0,0 +1,189 @@
+package source
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// stubBody is the statement of the body of method stubs.
+const stubBody = `panic("unimplemented")`
+
+// methodReceiver matches the text of a line preceding the name of a method
+// being declared, capturing the name of the receiver type.
+var methodReceiver = regexp.MustCompile(`^func\s*\(\s*(?:\w+\s+)?\*?\s*(\w+)\s*\)\s*$`)
+
+// snippetEscaper escapes the characters of a text inserted as a snippet.
+var snippetEscaper = strings.NewReplacer(`\`, `\\`, `}`, `\}`, `$`, `\$`)
+
+// MethodStub returns the declaration of a method implementing the
+// interface method m, without the func keyword and its receiver: its name,
+// its signature and a body panicking. qual qualifies the types of the
+// signature.
+func MethodStub(m *types.Func, qual types.Qualifier) string {
+	return methodSignature(m, qual) + " {\n\t" + stubBody + "\n}"
+}
+
+// methodSignature returns the name and the signature of the method m.
+func methodSignature(m *types.Func, qual types.Qualifier) string {
+	var b bytes.Buffer
+	b.WriteString(m.Name())
+	types.WriteSignature(&b, m.Type().(*types.Signature), qual)
+	return b.String()
+}
+
+// MissingMethods returns the methods of the interface iface which the type
+// typ, or a pointer to it, does not have, in the package pkg. They are nil
+// if typ cannot implement iface, because it has one of the methods with
+// another signature, or some methods of iface are unexported methods of
+// another package.
+func MissingMethods(typ types.Type, iface *types.Interface, pkg *types.Package) []*types.Func {
+	if _, ok := typ.(*types.Pointer); !ok {
+		typ = types.NewPointer(typ)
+	}
+	var missing []*types.Func
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if !m.Exported() && m.Pkg() != pkg {
+			return nil
+		}
+		obj, _, _ := types.LookupFieldOrMethod(typ, false, pkg, m.Name())
+		switch {
+		case obj == nil:
+			missing = append(missing, m)
+		case !types.Identical(obj.Type(), m.Type()):
+			return nil
+		}
+	}
+	return missing
+}
+
+// methodStubs returns the stubs of the methods missing for the receiver
+// type of a method declaration to implement the interfaces it is related
+// to, if pos is the position of the name of the method.
+func methodStubs(pos token.Pos, pkg Package, file *ast.File, tok *token.File, content []byte) (items []CompletionItem, prefix string, ok bool) {
+	line, prefix, ok := linePrefix(pos, tok, content)
+	if !ok {
+		return nil, "", false
+	}
+	m := methodReceiver.FindStringSubmatch(line)
+	if m == nil {
+		return nil, "", false
+	}
+	tn, ok := pkg.GetTypes().Scope().Lookup(m[1]).(*types.TypeName)
+	if !ok || types.IsInterface(tn.Type()) {
+		return nil, prefix, true
+	}
+
+	qual := qualifier(file, pkg.GetTypes(), pkg.GetTypesInfo())
+	seen := make(map[string]bool)
+	for _, iface := range relatedInterfaces(pkg, tn.Type()) {
+		for _, method := range MissingMethods(tn.Type(), iface.Underlying().(*types.Interface), pkg.GetTypes()) {
+			if seen[method.Name()] {
+				continue
+			}
+			seen[method.Name()] = true
+			sig := methodSignature(method, qual)
+			items = append(items, CompletionItem{
+				Label:      sig,
+				Detail:     types.TypeString(iface, qual),
+				Kind:       MethodCompletionItem,
+				Score:      stdScore,
+				Locality:   locality(method, file, pkg.GetTypes()),
+				InsertText: MethodStub(method, qual),
+				Snippet:    snippetEscaper.Replace(sig) + " {\n\t${1:" + snippetEscaper.Replace(stubBody) + "}\n}",
+			})
+		}
+	}
+	return items, prefix, true
+}
+
+// relatedInterfaces returns the named interfaces used in pkg which the
+// type typ partially implements, having at least one of their methods, or
+// which values of type typ, or of a pointer to it, are assigned to.
+func relatedInterfaces(pkg Package, typ types.Type) []*types.Named {
+	info := pkg.GetTypesInfo()
+	isRecv := func(t types.Type) bool {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		return t != nil && types.Identical(t, typ)
+	}
+
+	related := make(map[*types.Named]bool)
+	assigned := func(to types.Type, value ast.Expr) {
+		if named, ok := to.(*types.Named); ok && types.IsInterface(named) && isRecv(info.TypeOf(value)) {
+			related[named] = true
+		}
+	}
+	for _, f := range pkg.GetSyntax() {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				if n.Type != nil {
+					for _, value := range n.Values {
+						assigned(info.TypeOf(n.Type), value)
+					}
+				}
+			case *ast.AssignStmt:
+				if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+					for i, value := range n.Rhs {
+						assigned(info.TypeOf(n.Lhs[i]), value)
+					}
+				}
+			case *ast.CallExpr:
+				sig, ok := info.TypeOf(n.Fun).(*types.Signature)
+				if !ok {
+					break
+				}
+				for i, arg := range n.Args {
+					switch {
+					case sig.Variadic() && i >= sig.Params().Len()-1:
+						if s, ok := sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice); ok {
+							assigned(s.Elem(), arg)
+						}
+					case i < sig.Params().Len():
+						assigned(sig.Params().At(i).Type(), arg)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	addPartial := func(obj types.Object) {
+		tn, ok := obj.(*types.TypeName)
+		if !ok {
+			return
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || related[named] {
+			return
+		}
+		if iface, ok := named.Underlying().(*types.Interface); ok {
+			n := len(MissingMethods(typ, iface, pkg.GetTypes()))
+			related[named] = n > 0 && n < iface.NumMethods()
+		}
+	}
+	for _, obj := range info.Uses {
+		addPartial(obj)
+	}
+	for _, obj := range info.Defs {
+		addPartial(obj)
+	}
+
+	var ifaces []*types.Named
+	for named, ok := range related {
+		if ok {
+			ifaces = append(ifaces, named)
+		}
+	}
+	sort.Slice(ifaces, func(i, j int) bool {
+		return types.TypeString(ifaces[i], nil) < types.TypeString(ifaces[j], nil)
+	})
+	return ifaces
+}
@@ -10,20 +10,20 @@ import (
 	"strings"
 	"testing"
 
-	"golang.org/x/tools/internal/span"
+	"github.com/saibing/bingo/langserver/internal/span"
 )
 
 var (
 	formats = []string{"%v", "%#v", "%+v"}
 	tests   = [][]string{
-		{"C:/file_a", "C:/file_a", "file:///C:/file_a:1:1#0"},
-		{"C:/file_b:1:2", "C:/file_b:#1", "file:///C:/file_b:1:2#1"},
-		{"C:/file_c:1000", "C:/file_c:#9990", "file:///C:/file_c:1000:1#9990"},
-		{"C:/file_d:14:9", "C:/file_d:#138", "file:///C:/file_d:14:9#138"},
-		{"C:/file_e:1:2-7", "C:/file_e:#1-#6", "file:///C:/file_e:1:2#1-1:7#6"},
-		{"C:/file_f:500-502", "C:/file_f:#4990-#5010", "file:///C:/file_f:500:1#4990-502:1#5010"},
-		{"C:/file_g:3:7-8", "C:/file_g:#26-#27", "file:///C:/file_g:3:7#26-3:8#27"},
-		{"C:/file_h:3:7-4:8", "C:/file_h:#26-#37", "file:///C:/file_h:3:7#26-4:8#37"},
+		{`c:\file_a`, `c:\file_a`, "file:///c:/file_a:1:1#0"},
+		{`c:\file_b:1:2`, `c:\file_b:#1`, "file:///c:/file_b:1:2#1"},
+		{`c:\file_c:1000`, `c:\file_c:#9990`, "file:///c:/file_c:1000:1#9990"},
+		{`c:\file_d:14:9`, `c:\file_d:#138`, "file:///c:/file_d:14:9#138"},
+		{`c:\file_e:1:2-7`, `c:\file_e:#1-#6`, "file:///c:/file_e:1:2#1-1:7#6"},
+		{`c:\file_f:500-502`, `c:\file_f:#4990-#5010`, "file:///c:/file_f:500:1#4990-502:1#5010"},
+		{`c:\file_g:3:7-8`, `c:\file_g:#26-#27`, "file:///c:/file_g:3:7#26-3:8#27"},
+		{`c:\file_h:3:7-4:8`, `c:\file_h:#26-#37`, "file:///c:/file_h:3:7#26-4:8#37"},
 	}
 )
 
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 )
 
@@ -23,32 +24,21 @@ type URI string
 // Filename returns the file path for the given URI. It will return an error if
 // the URI is invalid, or if the URI does not have the file scheme.
 func (uri URI) Filename() (string, error) {
-	filename, err := filename(uri)
-	if err != nil {
-		return "", err
-	}
-	return filepath.FromSlash(filename), nil
-}
-
-func filename(uri URI) (string, error) {
 	u, err := url.ParseRequestURI(string(uri))
 	if err != nil {
 		return "", err
 	}
-	if u.Scheme != fileScheme {
+	if !strings.EqualFold(u.Scheme, fileScheme) {
 		return "", fmt.Errorf("only file URIs are supported, got %v", u.Scheme)
 	}
-	if isWindowsDriveURI(u.Path) {
-		u.Path = u.Path[1:]
-	}
-	return u.Path, nil
+	return util.UriToRealPath(lsp.DocumentURI(uri)), nil
 }
 
 // NewURI returns a span URI for the string.
 // It will attempt to detect if the string is a file path or uri.
 func NewURI(s string) URI {
-	if strings.HasPrefix(s, fileScheme+"://") {
-		return URI(s)
+	if util.IsURI(lsp.DocumentURI(s)) {
+		return URI(util.CanonicalURI(lsp.DocumentURI(s)))
 	}
 	return FileURI(s)
 }
@@ -68,16 +58,7 @@ func FileURI(path string) URI {
 			path = abs
 		}
 	}
-	// Check the file path again, in case it became absolute.
-	if isWindowsDrivePath(path) {
-		path = "/" + path
-	}
-	path = filepath.ToSlash(path)
-	u := url.URL{
-		Scheme: fileScheme,
-		Path:   path,
-	}
-	return URI(u.String())
+	return URI(util.PathToURI(path))
 }
 
 // isWindowsDrivePath returns true if the file path is of the form used by
@@ -89,19 +70,7 @@ func isWindowsDrivePath(path string) bool {
 	return unicode.IsLetter(rune(path[0])) && path[1] == ':'
 }
 
-// isWindowsDriveURI returns true if the file URI is of the format used by
-// Windows URIs. The url.Parse package does not specially handle Windows paths
-// (see https://golang.org/issue/6027). We check if the URI path has
-// a drive prefix (e.g. "/C:"). If so, we trim the leading "/".
-func isWindowsDriveURI(uri string) bool {
-	if len(uri) < 4 {
-		return false
-	}
-	return uri[0] == '/' && unicode.IsLetter(rune(uri[1])) && uri[2] == ':'
-}
-
 // FromDocumentURI create a URI from lsp.DocumentURI
 func FromDocumentURI(uri lsp.DocumentURI) URI {
-	s, _ := URI(string(uri)).Filename()
-	return FileURI(s)
+	return URI(util.CanonicalURI(uri))
 }
@@ -8,33 +8,38 @@ import (
 	"path/filepath"
 	"testing"
 
-	"golang.org/x/tools/internal/span"
+	"github.com/saibing/bingo/langserver/internal/span"
 )
 
 // TestURI tests the conversion between URIs and filenames. The test cases
 // include Windows-style URIs and filepaths, but we avoid having OS-specific
 // tests by using only forward slashes, assuming that the standard library
 // functions filepath.ToSlash and filepath.FromSlash do not need testing.
+// Paths with a drive letter have a canonical form on every platform, with a
+// lower case drive letter and back slashes.
 func TestURI(t *testing.T) {
-	for _, test := range []string{
-		`C:/Windows/System32`,
-		`C:/Go/src/bob.go`,
-		`c:/Go/src/bob.go`,
-		`/path/to/dir`,
-		`/a/b/c/src/bob.go`,
+	for _, test := range []struct {
+		path, uri, filename string
+	}{
+		{`C:/Windows/System32`, `file:///c:/Windows/System32`, `c:\Windows\System32`},
+		{`C:/Go/src/bob.go`, `file:///c:/Go/src/bob.go`, `c:\Go\src\bob.go`},
+		{`c:/Go/src/bob.go`, `file:///c:/Go/src/bob.go`, `c:\Go\src\bob.go`},
+		{`/path/to/dir`, "", ""},
+		{`/a/b/c/src/bob.go`, "", ""},
 	} {
-		testPath := filepath.FromSlash(test)
-		expectPath := testPath
-		if test[0] == '/' {
+		testPath := filepath.FromSlash(test.path)
+		expectPath, expectURI := test.filename, test.uri
+		if expectURI == "" {
+			expectPath = testPath
 			if abs, err := filepath.Abs(expectPath); err == nil {
 				expectPath = abs
 			}
+			expectURI = filepath.ToSlash(expectPath)
+			if expectURI[0] != '/' {
+				expectURI = "/" + expectURI
+			}
+			expectURI = "file://" + expectURI
 		}
-		expectURI := filepath.ToSlash(expectPath)
-		if expectURI[0] != '/' {
-			expectURI = "/" + expectURI
-		}
-		expectURI = "file://" + expectURI
 		uri := span.FileURI(testPath)
 		if expectURI != string(uri) {
 			t.Errorf("ToURI: expected %s, got %s", expectURI, uri)
@@ -2,11 +2,9 @@ package util
 
 import (
 	"fmt"
-	"github.com/saibing/bingo/langserver/internal/sys"
 	"log"
 	"net/url"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"strings"
 
@@ -52,52 +50,127 @@ func IsURI(s lsp.DocumentURI) bool {
 }
 
 func isURI(s string) bool {
-	return strings.HasPrefix(s, "file://")
+	const prefix = "file://"
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
 }
 
-// PathToURI converts given absolute path to file URI
+// PathToURI converts given absolute path to file URI. Windows paths get the
+// canonical form of the URIs of Go files: the drive letter is lower case and
+// only the path separator is a slash, as in "file:///c:/Users/me/main.go".
 func PathToURI(path string) lsp.DocumentURI {
-	path = filepath.ToSlash(path)
-	parts := strings.SplitN(path, "/", 2)
+	if isDrivePath(path) {
+		path = "/" + strings.Replace(LowerDriver(path), `\`, "/", -1)
+	} else {
+		path = filepath.ToSlash(path)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+
+	return lsp.DocumentURI("file://" + EscapeURIPath(path))
+}
 
-	// If the first segment is a Windows drive letter, prefix with a slash and skip encoding
-	head := parts[0]
-	if head != "" {
-		head = "/" + head
+// CanonicalURI returns the canonical form of the file URI uri, as returned
+// by PathToURI, so that the URIs of a file sent by the clients, which may
+// escape the colon of the drive letter or upper case it, compare equal.
+func CanonicalURI(uri lsp.DocumentURI) lsp.DocumentURI {
+	if !IsURI(uri) {
+		return uri
 	}
+	return PathToURI(UriToPath(uri))
+}
 
-	rest := ""
-	if len(parts) > 1 {
-		rest = "/" + parts[1]
+// URIEqual reports whether the file URIs a and b denote the same file. The
+// paths of Windows, whose file systems are case insensitive, are compared
+// regardless of case.
+func URIEqual(a, b lsp.DocumentURI) bool {
+	a, b = CanonicalURI(a), CanonicalURI(b)
+	if isDriveURIPath(UriToPath(a)) {
+		return strings.EqualFold(string(a), string(b))
 	}
+	return a == b
+}
 
-	return lsp.DocumentURI("file://" + head + rest)
+// EscapeURIPath percent-encodes the bytes of a slash separated path which
+// are not allowed in the path of a URI. Unlike url.PathEscape it keeps the
+// sub-delimiters, so module cache paths like "!burnt!sushi/toml@v0.3.1"
+// stay readable.
+func EscapeURIPath(path string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if shouldEscapeURIPath(c) {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
 }
 
-// UriToPath converts given file URI to path
+func shouldEscapeURIPath(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return false
+	}
+	// unreserved, sub-delims, ':', '@' and the separator '/'
+	return !strings.ContainsRune("-._~!$&'()*+,;=:@/", rune(c))
+}
+
+// UriToPath converts given file URI to a slash separated path. The path of
+// a Windows file starts with a slash and its lower case drive letter, as in
+// "/c:/Users/me/main.go".
 func UriToPath(uri lsp.DocumentURI) string {
 	u, err := url.Parse(string(uri))
 	if err != nil {
 		return trimFilePrefix(string(uri))
 	}
-	return u.Path
-}
 
-var regDriveLetter = regexp.MustCompile("^/[a-zA-Z]:")
+	path := u.Path
+	if isDrivePath(u.Host) {
+		// "file://C:/Users" has the drive letter parsed as the host.
+		path = "/" + u.Host + path
+	}
+	if isDriveURIPath(path) {
+		path = "/" + LowerDriver(path[1:])
+	}
+	return path
+}
 
-// UriToRealPath converts the given file URI to the platform specific path
+// UriToRealPath converts the given file URI to the platform specific path.
+// Windows paths have their drive letter in lower case and back slashes.
 func UriToRealPath(uri lsp.DocumentURI) string {
 	path := UriToPath(uri)
 
-	if regDriveLetter.MatchString(path) {
+	if isDriveURIPath(path) {
 		// remove the leading slash if it starts with a drive letter
 		// and convert to back slashes
-		path = filepath.FromSlash(path[1:])
+		path = strings.Replace(path[1:], "/", `\`, -1)
 	}
 
 	return path
 }
 
+// isDrivePath reports whether path starts with a Windows drive letter, as
+// in "C:\Users" or "c:/Users".
+func isDrivePath(path string) bool {
+	return len(path) >= 2 && isLetter(path[0]) && path[1] == ':' &&
+		(len(path) == 2 || path[2] == '/' || path[2] == '\\')
+}
+
+// isDriveURIPath reports whether the path of a file URI starts with a
+// Windows drive letter, as in "/C:/Users".
+func isDriveURIPath(path string) bool {
+	return strings.HasPrefix(path, "/") && isDrivePath(path[1:])
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 // IsAbs returns true if the given path is absolute
 func IsAbs(path string) bool {
 	// Windows implementation accepts path-like and filepath-like arguments
@@ -121,20 +194,10 @@ func Panicf(r interface{}, format string, v ...interface{}) error {
 	return nil
 }
 
-func GetRealPath(filename string) string {
-	if !sys.IsWindows() {
-		return filename
-	}
-
-	if filename[0] == '/' {
-		return filename[1:]
-	}
-
-	return filename
-}
-
+// LowerDriver lower cases the drive letter of a Windows path, so that the
+// paths of a file compare equal whatever the case the client used.
 func LowerDriver(path string) string {
-	if !sys.IsWindows() {
+	if !isDrivePath(path) {
 		return path
 	}
 
@@ -0,0 +1,103 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestPathToURI(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		uri  lsp.DocumentURI
+	}{
+		{`/home/me/go/src/p/main.go`, "file:///home/me/go/src/p/main.go"},
+		{`/home/me/my dir/main.go`, "file:///home/me/my%20dir/main.go"},
+		{`/home/me/pkg/mod/github.com/!burnt!sushi/toml@v0.3.1`, "file:///home/me/pkg/mod/github.com/!burnt!sushi/toml@v0.3.1"},
+		{`C:\Users\me\go\src\p\main.go`, "file:///c:/Users/me/go/src/p/main.go"},
+		{`c:\Users\me\go\src\p\main.go`, "file:///c:/Users/me/go/src/p/main.go"},
+		{`C:/Users/me/go/src/p/main.go`, "file:///c:/Users/me/go/src/p/main.go"},
+		{`D:\My Projects\p#1\main.go`, "file:///d:/My%20Projects/p%231/main.go"},
+	} {
+		if uri := PathToURI(test.path); uri != test.uri {
+			t.Errorf("PathToURI(%q) = %q, want %q", test.path, uri, test.uri)
+		}
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		path, uri string
+	}{
+		{"/home/user/go/pkg/mod/github.com/!burnt!sushi/toml@v0.3.1/decode.go", "file:///home/user/go/pkg/mod/github.com/!burnt!sushi/toml@v0.3.1/decode.go"},
+		{"/home/user/go/pkg/mod/github.com/!sirupsen/logrus@v1.0.6+incompatible/a.go", "file:///home/user/go/pkg/mod/github.com/!sirupsen/logrus@v1.0.6+incompatible/a.go"},
+		{"/home/user/my project/#1/a.go", "file:///home/user/my%20project/%231/a.go"},
+	} {
+		uri := PathToURI(test.path)
+		if string(uri) != test.uri {
+			t.Errorf("PathToURI(%q) = %q, want %q", test.path, uri, test.uri)
+		}
+		if path := UriToPath(uri); path != test.path {
+			t.Errorf("UriToPath(%q) = %q, want %q", uri, path, test.path)
+		}
+	}
+}
+
+func TestUriToRealPath(t *testing.T) {
+	for _, test := range []struct {
+		uri  lsp.DocumentURI
+		path string
+	}{
+		{"file:///home/me/go/src/p/main.go", `/home/me/go/src/p/main.go`},
+		{"file:///home/me/my%20dir/main.go", `/home/me/my dir/main.go`},
+		{"file:///c:/Users/me/main.go", `c:\Users\me\main.go`},
+		{"file:///C:/Users/me/main.go", `c:\Users\me\main.go`},
+		{"file:///c%3A/Users/me/main.go", `c:\Users\me\main.go`},
+		{"file:///C%3a/Users/me/main.go", `c:\Users\me\main.go`},
+		{"file://C:/Users/me/main.go", `c:\Users\me\main.go`},
+		{"file:///d:/My%20Projects/p%231/main.go", `d:\My Projects\p#1\main.go`},
+	} {
+		if path := UriToRealPath(test.uri); path != test.path {
+			t.Errorf("UriToRealPath(%q) = %q, want %q", test.uri, path, test.path)
+		}
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	for _, test := range []struct {
+		uri, canonical lsp.DocumentURI
+	}{
+		{"file:///home/me/main.go", "file:///home/me/main.go"},
+		{"file:///home/me/my%20dir/main.go", "file:///home/me/my%20dir/main.go"},
+		{"file:///c%3A/Users/me/main.go", "file:///c:/Users/me/main.go"},
+		{"file:///C%3A/Users/me/main.go", "file:///c:/Users/me/main.go"},
+		{"file:///C:/Users/me/main.go", "file:///c:/Users/me/main.go"},
+		{"FILE:///C:/Users/me/main.go", "file:///c:/Users/me/main.go"},
+		{"untitled:Untitled-1", "untitled:Untitled-1"},
+	} {
+		if canonical := CanonicalURI(test.uri); canonical != test.canonical {
+			t.Errorf("CanonicalURI(%q) = %q, want %q", test.uri, canonical, test.canonical)
+		}
+		// The canonical form round-trips through the path.
+		if uri := PathToURI(UriToRealPath(test.canonical)); IsURI(test.uri) && uri != test.canonical {
+			t.Errorf("PathToURI(UriToRealPath(%q)) = %q", test.canonical, uri)
+		}
+	}
+}
+
+func TestURIEqual(t *testing.T) {
+	for _, test := range []struct {
+		a, b  lsp.DocumentURI
+		equal bool
+	}{
+		{"file:///home/me/main.go", "file:///home/me/main.go", true},
+		{"file:///home/me/main.go", "file:///home/me/Main.go", false},
+		{"file:///c%3A/Users/me/main.go", "file:///C:/Users/me/main.go", true},
+		{"file:///c:/Users/me/main.go", "file:///C:/users/ME/main.go", true},
+		{"file:///c:/Users/me/main.go", "file:///d:/Users/me/main.go", false},
+	} {
+		if equal := URIEqual(test.a, test.b); equal != test.equal {
+			t.Errorf("URIEqual(%q, %q) = %v, want %v", test.a, test.b, equal, test.equal)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// identifierPattern is the word pattern of the linked editing ranges, which
+// restricts the edits to the characters of an identifier.
+const identifierPattern = `[A-Za-z_][A-Za-z0-9_]*`
+
+// handleTextDocumentLinkedEditingRange returns the ranges of the receiver or
+// parameter at the position, declared or used there, to be edited together:
+// its name in the declaration and its uses in the function. It returns nil
+// for the other identifiers.
+func (h *LangHandler) handleTextDocumentLinkedEditingRange(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (*protocol.LinkedEditingRanges, error) {
+	ranges, err := h.doHandleTextDocumentLinkedEditingRange(ctx, params)
+	if (err != nil || ranges == nil) && params.Position.Character > 0 {
+		// The cursor may be right after an identifier, while it is
+		// typed.
+		params.Position.Character--
+		ranges, err = h.doHandleTextDocumentLinkedEditingRange(ctx, params)
+	}
+
+	if isEmptyResult(err) {
+		return nil, nil
+	}
+	return ranges, err
+}
+
+// doHandleTextDocumentLinkedEditingRange returns the linked editing ranges
+// of the identifier at the position of params. Like for the highlights,
+// only the function declaring it is walked.
+func (h *LangHandler) doHandleTextDocumentLinkedEditingRange(ctx context.Context, params lsp.TextDocumentPositionParams) (*protocol.LinkedEditingRanges, error) {
+	pkg, pathNodes, err := h.pathNodesAt(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	ident, ok := pathNodes[0].(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+
+	info := pkg.GetTypesInfo()
+	v, ok := info.ObjectOf(ident).(*types.Var)
+	if !ok || v.Name() == "_" {
+		return nil, nil
+	}
+	name, body := paramDecl(info, pathNodes, v)
+	if name == nil || body == nil {
+		return nil, nil
+	}
+
+	content, contentErr := h.project.FileContent(ctx, params.TextDocument.URI)
+	ranges := &protocol.LinkedEditingRanges{WordPattern: identifierPattern}
+	add := func(id *ast.Ident) {
+		// The character offsets of the client count UTF-16 code units.
+		r := rangeForNode(pkg.GetFileSet(), id)
+		if contentErr == nil {
+			r = toUTF16Range(content, r)
+		}
+		ranges.Ranges = append(ranges.Ranges, r)
+	}
+	add(name)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == v {
+			add(id)
+		}
+		return true
+	})
+	return ranges, nil
+}
+
+// paramDecl returns the name declaring the receiver or parameter v, and the
+// body of its function, if it is one of the functions enclosing the
+// innermost node of pathNodes. The named results are left out.
+func paramDecl(info *types.Info, pathNodes []ast.Node, v *types.Var) (*ast.Ident, *ast.BlockStmt) {
+	find := func(fields ...*ast.FieldList) *ast.Ident {
+		for _, list := range fields {
+			if list == nil {
+				continue
+			}
+			for _, field := range list.List {
+				for _, name := range field.Names {
+					if info.Defs[name] == v {
+						return name
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, n := range pathNodes {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if name := find(n.Recv, n.Type.Params); name != nil {
+				return name, n.Body
+			}
+		case *ast.FuncLit:
+			if name := find(n.Type.Params); name != nil {
+				return name, n.Body
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,134 @@
+package langserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ListenUnix listens on the Unix domain socket at path, which only the user
+// may connect to. A socket left at path by a server which is not running
+// anymore is removed first. The socket is not removed when the listener is
+// closed, so that the clients can be shut down first: the caller removes
+// it.
+func ListenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("a server is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// The socket is created in a directory only the user may enter, and
+	// moved to path once it has its permissions, so that no other user can
+	// connect to it in between.
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".bingo")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	lis.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+// IdleListener is a net.Listener reporting when none of the connections
+// it accepted has been open for a timeout, so that a server spawned for
+// a client does not outlive it.
+type IdleListener struct {
+	net.Listener
+
+	timeout  time.Duration
+	idle     chan struct{}
+	idleOnce sync.Once
+
+	mu    sync.Mutex
+	conns int // the number of open connections
+	timer *time.Timer
+}
+
+// NewIdleListener returns a listener accepting the connections of lis,
+// which becomes idle once it has had no open connection for timeout.
+func NewIdleListener(lis net.Listener, timeout time.Duration) *IdleListener {
+	l := &IdleListener{Listener: lis, timeout: timeout, idle: make(chan struct{})}
+	l.timer = time.AfterFunc(timeout, l.expire)
+	return l
+}
+
+// Accept implements net.Listener.
+func (l *IdleListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conns++
+	l.timer.Stop()
+	return &idleConn{Conn: conn, listener: l}, nil
+}
+
+// Idle returns a channel which is closed once the listener has had no open
+// connection for its timeout.
+func (l *IdleListener) Idle() <-chan struct{} {
+	return l.idle
+}
+
+func (l *IdleListener) closed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conns--
+	if l.conns == 0 {
+		l.timer.Reset(l.timeout)
+	}
+}
+
+func (l *IdleListener) expire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A connection may have been accepted as the timer fired.
+	if l.conns > 0 {
+		return
+	}
+	l.idleOnce.Do(func() {
+		close(l.idle)
+	})
+}
+
+// idleConn is a connection accepted by an IdleListener, which is told when
+// it is closed.
+type idleConn struct {
+	net.Conn
+	listener  *IdleListener
+	closeOnce sync.Once
+}
+
+func (c *idleConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.listener.closed)
+	return err
+}
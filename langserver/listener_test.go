@@ -0,0 +1,124 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets")
+	}
+
+	dir, err := ioutil.TempDir("", "bingo-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bingo.sock")
+
+	// A stale socket is left by a listener which does not remove it.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	if err := stale.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := ListenUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("got permissions %o, want 0600", perm)
+	}
+
+	if _, err := ListenUnix(path); err == nil {
+		t.Error("listened on the socket of a running server")
+	}
+
+	// The socket is created elsewhere, and nothing is left there.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d entries in the directory of the socket, want only the socket", len(entries))
+	}
+
+	// The socket is left for the caller to remove once the clients are
+	// shut down.
+	if err := lis.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("got error %v, want the socket left", err)
+	}
+	if lis, err := ListenUnix(path); err != nil {
+		t.Errorf("got error %v, want the socket of the closed listener replaced", err)
+	} else {
+		_ = lis.Close()
+	}
+
+	regular := filepath.Join(dir, "regular")
+	if err := ioutil.WriteFile(regular, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(regular); err == nil {
+		t.Error("listened on a regular file")
+	}
+}
+
+func TestIdleListener(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := NewIdleListener(tcp, 500*time.Millisecond)
+	defer lis.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	client, err := net.Dial("tcp", tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := <-accepted
+
+	// The listener is not idle while a connection is open.
+	select {
+	case <-lis.Idle():
+		t.Fatal("the listener is idle with an open connection")
+	case <-time.After(time.Second):
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lis.Idle():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the listener is not idle after its connection was closed")
+	}
+}
@@ -2,6 +2,7 @@ package langserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
@@ -12,6 +13,31 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// Error codes of requests on files which could not be type checked. They
+// are taken from the range JSON-RPC reserves for server errors.
+const (
+	// codeNoPackage means that no package was found for the file.
+	codeNoPackage int64 = -32010
+
+	// codeIllTypedPackage means that the package of the file has errors
+	// which prevent type checking it.
+	codeIllTypedPackage int64 = -32011
+)
+
+// errNoPosition is returned by typeCheck when the requested position is
+// not inside of the file, e.g. past the end of its last line.
+var errNoPosition = errors.New("position is outside of the file")
+
+// isEmptyResult reports whether err means that there is nothing at the
+// requested position, which requests should answer with an empty result
+// rather than an error.
+func isEmptyResult(err error) bool {
+	if _, ok := err.(*source.InvalidNodeError); ok {
+		return true
+	}
+	return err == errNoPosition
+}
+
 func checkFileURI(fileURI lsp.DocumentURI) error {
 	if !util.IsURI(fileURI) {
 		err := &jsonrpc2.Error{
@@ -33,15 +59,21 @@ func (h *LangHandler) typeCheck(ctx context.Context, fileURI lsp.DocumentURI, po
 
 	pkg, f, err := h.project.TypeCheck(ctx, fileURI)
 	if err != nil {
-		return nil, pos, err
+		return nil, pos, &jsonrpc2.Error{Code: codeNoPackage, Message: err.Error()}
 	}
 
 	if pkg == nil {
-		return nil, pos, fmt.Errorf("package for %s is null", fileURI)
+		return nil, pos, &jsonrpc2.Error{Code: codeNoPackage, Message: fmt.Sprintf("package for %s is null", fileURI)}
 	}
 
 	if pkg.IsIllTyped() {
-		return nil, pos, fmt.Errorf("package for %s is ill typed", fileURI)
+		return nil, pos, &jsonrpc2.Error{Code: codeIllTypedPackage, Message: fmt.Sprintf("package for %s is ill typed", fileURI)}
+	}
+
+	// The character offsets of the client count UTF-16 code units, the
+	// token positions count bytes.
+	if content, err := h.project.FileContent(ctx, fileURI); err == nil {
+		position = fromUTF16Position(content, position)
 	}
 
 	if f == nil {
@@ -49,11 +81,24 @@ func (h *LangHandler) typeCheck(ctx context.Context, fileURI lsp.DocumentURI, po
 	} else {
 		pos, err = h.getPosFromFile(ctx, pkg, f, position)
 	}
+	if err == nil && !pos.IsValid() {
+		err = errNoPosition
+	}
 	return pkg, pos, err
 }
 
 func (h *LangHandler) getPosFromFile(ctx context.Context, pkg source.Package, f source.File, position lsp.Position) (token.Pos, error) {
 	tok := f.GetToken(ctx)
+	if tok == nil {
+		return token.NoPos, fmt.Errorf("%s token file does not exist", f.URI())
+	}
+
+	filename, _ := f.URI().Filename()
+	if fAST := f.GetAST(ctx); fAST != nil && isGeneratedFrom(pkg.GetFileSet(), tok, fAST, filename) {
+		// The file imports "C" and was replaced by the output of cgo.
+		return fromAdjustedPosition(pkg.GetFileSet(), fAST, position), nil
+	}
+
 	pos := fromProtocolPosition(tok, position)
 	return pos, nil
 }
@@ -70,10 +115,22 @@ func (h *LangHandler) getPosFromPkg(pkg source.Package, fileURI lsp.DocumentURI,
 		return pos, fmt.Errorf("%s token file does not exist", fileURI)
 	}
 
+	if isGeneratedFrom(pkg.GetFileSet(), fToken, fAST, util.UriToRealPath(fileURI)) {
+		// The file imports "C" and was replaced by the output of cgo.
+		return fromAdjustedPosition(pkg.GetFileSet(), fAST, position), nil
+	}
+
 	pos = fromProtocolPosition(fToken, position)
 	return pos, nil
 }
 
+// isGeneratedFrom reports whether fAST, parsed from tok, was generated from
+// filename, which its //line directives refer to.
+func isGeneratedFrom(fset *token.FileSet, tok *token.File, fAST *ast.File, filename string) bool {
+	original := source.OriginalFilename(fset, fAST)
+	return original != tok.Name() && util.PathEqual(original, filename)
+}
+
 func (h *LangHandler) loadPackageAndAst(ctx context.Context, fileURI lsp.DocumentURI) (source.Package, *ast.File, error) {
 	if err := checkFileURI(fileURI); err != nil {
 		return nil, nil, err
@@ -0,0 +1,145 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var callHierarchyContext = newTestContext(cache.Always)
+
+func TestCallHierarchy(t *testing.T) {
+	t.Parallel()
+
+	callHierarchyContext.setup(t)
+
+	const pkgPath = "github.com/saibing/bingo/langserver/test/pkg/callhierarchy"
+
+	t.Run("prepare function", func(t *testing.T) {
+		item := prepareCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:26:6")
+		if got, want := formatCallHierarchyItem(item), "C 25:0-25:11 25:5-25:6 "+pkgPath; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if item.Kind != lsp.SKFunction {
+			t.Errorf("got kind %v, want function", item.Kind)
+		}
+	})
+
+	t.Run("prepare method call", func(t *testing.T) {
+		item := prepareCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:15:4")
+		if got, want := formatCallHierarchyItem(item), "M 6:0-6:22 6:16-6:17 "+pkgPath+".Embedded"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if item.Kind != lsp.SKMethod {
+			t.Errorf("got kind %v, want method", item.Kind)
+		}
+	})
+
+	t.Run("outgoing calls", func(t *testing.T) {
+		// Method values are calls, unlike function values and the calls
+		// through interfaces.
+		testCallHierarchyCalls(t, callHierarchyContext, "callHierarchy/outgoingCalls", "callhierarchy/a.go:11:6",
+			"B 11:1-11:2 12:1-12:2",
+			"M 14:3-14:4 15:8-15:9")
+	})
+
+	t.Run("outgoing calls through interfaces", func(t *testing.T) {
+		defer callHierarchyContext.configure(t, map[string]interface{}{"callHierarchyInterfaceCalls": true})()
+
+		testCallHierarchyCalls(t, callHierarchyContext, "callHierarchy/outgoingCalls", "callhierarchy/a.go:11:6",
+			"B 11:1-11:2 12:1-12:2",
+			"M 14:3-14:4 15:8-15:9",
+			"M 18:3-18:4")
+	})
+
+	t.Run("incoming calls", func(t *testing.T) {
+		testCallHierarchyCalls(t, callHierarchyContext, "callHierarchy/incomingCalls", "callhierarchy/a.go:26:6",
+			"B 23:11-23:12")
+	})
+
+	t.Run("incoming calls of promoted method", func(t *testing.T) {
+		testCallHierarchyCalls(t, callHierarchyContext, "callHierarchy/incomingCalls", "callhierarchy/a.go:7:17",
+			"A 14:3-14:4 15:8-15:9")
+	})
+
+	t.Run("incoming calls through interfaces", func(t *testing.T) {
+		defer callHierarchyContext.configure(t, map[string]interface{}{"callHierarchyInterfaceCalls": true})()
+
+		testCallHierarchyCalls(t, callHierarchyContext, "callHierarchy/incomingCalls", "callhierarchy/a.go:7:17",
+			"A 14:3-14:4 15:8-15:9 18:3-18:4")
+	})
+}
+
+// prepareCallHierarchy returns the single call hierarchy item at pos.
+func prepareCallHierarchy(t *testing.T, tx *TestContext, pos string) protocol.CallHierarchyItem {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}
+	var items []protocol.CallHierarchyItem
+	if err := tx.conn.Call(tx.ctx, "textDocument/prepareCallHierarchy", params, &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got items %v, want one", items)
+	}
+	return items[0]
+}
+
+// testCallHierarchyCalls checks the calls method returns for the item at
+// pos, formatted as the name of the other item followed by the ranges of
+// the calls.
+func testCallHierarchyCalls(t *testing.T, tx *TestContext, method, pos string, want ...string) {
+	t.Helper()
+	item := prepareCallHierarchy(t, tx, pos)
+
+	var got []string
+	format := func(other protocol.CallHierarchyItem, ranges []lsp.Range) {
+		s := other.Name
+		for _, r := range ranges {
+			s += " " + rangeString(r)
+		}
+		got = append(got, s)
+	}
+	switch method {
+	case "callHierarchy/incomingCalls":
+		var calls []protocol.CallHierarchyIncomingCall
+		if err := tx.conn.Call(tx.ctx, method, protocol.CallHierarchyIncomingCallsParams{Item: item}, &calls); err != nil {
+			t.Fatal(err)
+		}
+		for _, call := range calls {
+			format(call.From, call.FromRanges)
+		}
+	case "callHierarchy/outgoingCalls":
+		var calls []protocol.CallHierarchyOutgoingCall
+		if err := tx.conn.Call(tx.ctx, method, protocol.CallHierarchyOutgoingCallsParams{Item: item}, &calls); err != nil {
+			t.Fatal(err)
+		}
+		for _, call := range calls {
+			format(call.To, call.FromRanges)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("%s %s: got\n%s\nwant\n%s", method, pos, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func formatCallHierarchyItem(item protocol.CallHierarchyItem) string {
+	return fmt.Sprintf("%s %s %s %s", item.Name, rangeString(item.Range), rangeString(item.SelectionRange), item.Detail)
+}
+
+func rangeString(r lsp.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
@@ -0,0 +1,173 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var cancelContext = newTestContext(cache.None)
+
+func TestCancelRequest(t *testing.T) {
+	t.Parallel()
+
+	cancelContext.setup(t)
+
+	t.Run("execute command", func(t *testing.T) {
+		// The test of the package sleeps for a minute, so the command runs
+		// until it is cancelled.
+		req := newCancelTestRequest(t, 1, "workspace/executeCommand", lsp.ExecuteCommandParams{
+			Command:   testCommand,
+			Arguments: []interface{}{filepath.Join(cancelContext.root(), "cancel")},
+		})
+
+		// The request is cancelled once it is tracked.
+		tracked := func() bool {
+			c := cancelContext.handler.cancel
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			_, ok := c.m[req.ID]
+			return ok
+		}
+		testCancelRequest(t, &progressConn{}, req, func() {
+			for !tracked() {
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	})
+
+	t.Run("references", func(t *testing.T) {
+		req := newCancelTestRequest(t, 2, "textDocument/references", newCancelReferenceParams(t, "refs/a/a.go:1:17"))
+
+		// The search is cancelled while it sends its first batch of
+		// references, which is only sent once the request is cancelled.
+		conn := &blockingProgressConn{sending: make(chan struct{}, 1)}
+		testCancelRequest(t, conn, req, func() {
+			select {
+			case <-conn.sending:
+			case <-time.After(30 * time.Second):
+				t.Fatal("the references were never sent")
+			}
+		})
+	})
+
+	t.Run("no references after cancellation", func(t *testing.T) {
+		// The position is inside the identifier, so that looking it up
+		// again right before it would find it too.
+		req := newCancelTestRequest(t, 3, "textDocument/references", newCancelReferenceParams(t, "shadow/a.go:5:12"))
+
+		// Once the request returns, the batch it was cancelled during is
+		// the only one sent: the search is neither carried on nor started
+		// again.
+		conn := &blockingProgressConn{sending: make(chan struct{}, 1)}
+		testCancelRequest(t, conn, req, func() {
+			select {
+			case <-conn.sending:
+			case <-time.After(30 * time.Second):
+				t.Fatal("the references were never sent")
+			}
+		})
+		if n := atomic.LoadInt32(&conn.sent); n != 1 {
+			t.Errorf("got %d batches of references, want 1", n)
+		}
+	})
+}
+
+// newCancelReferenceParams returns the params of a references request for
+// the position pos, whose references are sent in batches.
+func newCancelReferenceParams(t *testing.T, pos string) interface{} {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootURI := util.PathToURI(filepath.ToSlash(cancelContext.root()))
+	return struct {
+		lsp.ReferenceParams
+		protocol.PartialResultParams
+	}{
+		ReferenceParams: lsp.ReferenceParams{
+			Context: lsp.ReferenceContext{IncludeDeclaration: true},
+			TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+				Position:     lsp.Position{Line: line, Character: char},
+			},
+		},
+		PartialResultParams: protocol.PartialResultParams{PartialResultToken: "refs"},
+	}
+}
+
+// newCancelTestRequest returns the request of method with params,
+// identified by id.
+func newCancelTestRequest(t *testing.T, id uint64, method string, params interface{}) *jsonrpc2.Request {
+	t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := json.RawMessage(data)
+	return &jsonrpc2.Request{Method: method, Params: &raw, ID: jsonrpc2.ID{Num: id}}
+}
+
+// testCancelRequest handles req, cancels it with $/cancelRequest once wait
+// returns, and checks that the request returns the RequestCancelled error.
+func testCancelRequest(t *testing.T, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, wait func()) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := cancelContext.handler.Handle(cancelContext.ctx, conn, req)
+		errc <- err
+	}()
+
+	wait()
+	cancelParams, err := json.Marshal(lsp.CancelParams{ID: lsp.ID{Num: req.ID.Num}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelRaw := json.RawMessage(cancelParams)
+	notif := &jsonrpc2.Request{Method: "$/cancelRequest", Params: &cancelRaw, Notif: true}
+	if _, err := cancelContext.handler.Handle(cancelContext.ctx, &progressConn{}, notif); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errc:
+		e, ok := err.(*jsonrpc2.Error)
+		if !ok || e.Code != codeRequestCancelled {
+			t.Errorf("got error %v, want code %d", err, codeRequestCancelled)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("the cancelled request did not return")
+	}
+}
+
+// blockingProgressConn blocks the $/progress notifications until the
+// context of the request sending them is cancelled.
+type blockingProgressConn struct {
+	progressConn
+	sending chan struct{}
+	// sent counts the notifications.
+	sent int32
+}
+
+func (c *blockingProgressConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	if method != "$/progress" {
+		return nil
+	}
+	atomic.AddInt32(&c.sent, 1)
+	select {
+	case c.sending <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
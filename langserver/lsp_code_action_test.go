@@ -0,0 +1,440 @@
+package langserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var codeActionContext = newTestContext(cache.None)
+
+func TestQuickFixes(t *testing.T) {
+	t.Parallel()
+
+	codeActionContext.setup(t)
+
+	test := func(t *testing.T, pos, message string, want ...string) {
+		t.Helper()
+		if got := callQuickFixes(t, pos, message); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s %q: got\n%s\nwant\n%s", pos, message, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	t.Run("unused imports", func(t *testing.T) {
+		test(t, "quickfix/a.go:5:2", `"os" imported but not used`,
+			`Remove unused import "os": 3:1-6:2 "import \"fmt\""`)
+		test(t, "quickfix/b.go:5:2", `"os" imported but not used`,
+			`Remove unused import "os": 5:1-6:1 ""`)
+		test(t, "quickfix/c.go:3:8", `"strings" imported and not used`,
+			`Remove unused import "strings": 3:1-4:1 ""`)
+		test(t, "quickfix/d.go:4:2", `"bytes" imported but not used`,
+			`Remove unused import "bytes": 3:1-6:1 ""`)
+	})
+
+	t.Run("unused variables", func(t *testing.T) {
+		test(t, "quickfix/vars.go:6:6", "a declared but not used",
+			`Rename a to _: 6:6-6:7 "_"`)
+		test(t, "quickfix/vars.go:7:2", "c declared but not used",
+			`Rename c to _: 7:2-7:3 "_", 7:4-7:6 "="`,
+			`Remove unused variable c: 7:1-8:1 ""`)
+		test(t, "quickfix/vars.go:8:2", "declared and not used: d",
+			`Rename d to _: 8:2-8:3 "_"`)
+		test(t, "quickfix/vars.go:9:6", "f declared but not used",
+			`Rename f to _: 9:6-9:7 "_"`)
+		test(t, "quickfix/vars.go:10:9", "v declared but not used",
+			`Remove unused variable v: 10:7-10:10 ""`)
+		test(t, "quickfix/vars.go:13:6", "k declared but not used",
+			`Remove unused variable k: 13:6-13:17 "range "`)
+	})
+
+	t.Run("missing methods", func(t *testing.T) {
+		stub := `Implement iface.Writer: 4:1-4:1 "\t\"bytes\"\n", 9:21-9:21 "\n\nfunc (s *Server) WriteTo(b *bytes.Buffer) error {\n\tpanic(\"unimplemented\")\n}"`
+		test(t, "implement/a.go:13:22", "cannot use &Server{} (value of type *Server) as github.com/saibing/bingo/langserver/test/pkg/implement/iface.Writer value in variable declaration: missing method WriteTo", stub)
+		test(t, "implement/a.go:13:22", "cannot use &Server{} (type *Server) as type iface.Writer in assignment:\n\t*Server does not implement iface.Writer (missing WriteTo method)", stub)
+	})
+
+	t.Run("error checks", func(t *testing.T) {
+		test(t, "errcheck/a.go:10:5", "err declared but not used",
+			`Rename err to _: 10:5-10:8 "_"`,
+			`Check err and return it: 10:22-10:22 "\n\tif err != nil {\n\t\treturn nil, 0, T{}, err\n\t}"`,
+			`Check err and return it wrapped: 3:1-3:12 "import (\n\t\"fmt\"\n\t\"os\"\n)", 10:22-10:22 "\n\tif err != nil {\n\t\treturn nil, 0, T{}, fmt.Errorf(\"os.Open: %w\", err)\n\t}"`)
+		// Without a diagnostic, at the statement.
+		test(t, "errcheck/a.go:15:2", "",
+			`Check err and return: 15:28-15:28 "\n\tif err != nil {\n\t\treturn\n\t}"`)
+		test(t, "errcheck/a.go:20:2", "")
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		test(t, "quickfix/vars.go:6:6", "undeclared name: a")
+	})
+}
+
+var organizeImportsContext = newTestContext(cache.None)
+
+func TestOrganizeImports(t *testing.T) {
+	t.Parallel()
+
+	organizeImportsContext.setup(t)
+
+	const file = "organize/a.go"
+	want := `package organize
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/saibing/bingo/langserver/test/pkg/organize/lib"
+)
+
+var _ = fmt.Sprint(lib.X, os.Args)
+`
+
+	t.Run("code action", func(t *testing.T) {
+		tx := organizeImportsContext
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)
+		params := lsp.CodeActionParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+		var actions []protocol.CodeAction
+		if err := tx.conn.Call(tx.ctx, "textDocument/codeAction", params, &actions); err != nil {
+			t.Fatal(err)
+		}
+		for _, action := range actions {
+			if action.Kind != protocol.SourceOrganizeImports {
+				continue
+			}
+			if got := applyTextEdits(readFile(t, tx, file), action.Edit.Changes[string(uri)]); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			return
+		}
+		t.Errorf("no %s action in %v", protocol.SourceOrganizeImports, actions)
+	})
+
+	t.Run("will save", func(t *testing.T) {
+		tx := organizeImportsContext
+		defer tx.configure(t, map[string]interface{}{"organizeImportsOnSave": true})()
+
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)
+		params := protocol.WillSaveTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}, Reason: protocol.Manual}
+		var edits []lsp.TextEdit
+		if err := tx.conn.Call(tx.ctx, "textDocument/willSaveWaitUntil", params, &edits); err != nil {
+			t.Fatal(err)
+		}
+		if got := applyTextEdits(readFile(t, tx, file), edits); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+var fillStructContext = newTestContext(cache.None)
+
+func TestFillStruct(t *testing.T) {
+	t.Parallel()
+
+	fillStructContext.setup(t)
+
+	test := func(t *testing.T, pos, title, want string) {
+		t.Helper()
+		actions, text := callRefactorings(t, fillStructContext, pos, "")
+		if title == "" {
+			if len(actions) != 0 {
+				t.Errorf("%s: got actions %v, want none", pos, actions)
+			}
+			return
+		}
+		if len(actions) != 1 || actions[0].Title != title || actions[0].Kind != protocol.RefactorRewrite {
+			t.Fatalf("%s: got actions %v, want %s", pos, actions, title)
+		}
+		if !strings.Contains(text, want) {
+			t.Errorf("%s: got\n%s\nwant it to contain\n%s", pos, text, want)
+		}
+	}
+
+	test(t, "fillstruct/a.go:6:19", "Fill Location", `
+	_ = lsp.Location{
+		URI:   "",
+		Range: lsp.Range{},
+	}
+	_ = T{A: 1}
+`)
+	test(t, "fillstruct/a.go:7:9", "Fill T", `
+	_ = T{
+		A: 1,
+		b: "",
+		P: nil,
+		S: lsp.Range{},
+	}
+`)
+	test(t, "fillstruct/a.go:8:12", "", "")
+}
+
+var implementInterfaceContext = newTestContext(cache.None)
+
+func TestImplementInterface(t *testing.T) {
+	t.Parallel()
+
+	implementInterfaceContext.setup(t)
+
+	actions, text := callRefactorings(t, implementInterfaceContext, "implement/a.go:9:7", "")
+	if len(actions) != 1 || actions[0].Title != "Implement iface.Writer" {
+		t.Fatalf("got actions %v, want Implement iface.Writer", actions)
+	}
+	want := `package implement
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/saibing/bingo/langserver/test/pkg/implement/iface"
+)
+
+type Server struct{}
+
+func (s *Server) WriteTo(b *bytes.Buffer) error {
+	panic("unimplemented")
+}
+
+func (s *Server) String() string { return fmt.Sprint("server") }
+`
+	if !strings.HasPrefix(text, want) {
+		t.Errorf("got\n%s\nwant it to start with\n%s", text, want)
+	}
+}
+
+var extractContext = newTestContext(cache.None)
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	extractContext.setup(t)
+
+	test := func(t *testing.T, pos, end, title, want string) {
+		t.Helper()
+		actions, text := callRefactorings(t, extractContext, pos, end)
+		if title == "" {
+			if len(actions) != 0 {
+				t.Errorf("%s-%s: got actions %v, want none", pos, end, actions)
+			}
+			return
+		}
+		if len(actions) != 1 || actions[0].Title != title || actions[0].Kind != protocol.RefactorExtract {
+			t.Fatalf("%s-%s: got actions %v, want %s", pos, end, actions, title)
+		}
+		if !strings.Contains(text, want) {
+			t.Errorf("%s-%s: got\n%s\nwant it to contain\n%s", pos, end, text, want)
+		}
+	}
+
+	test(t, "extract/a.go:6:32", "extract/a.go:6:38", "Extract variable", `
+	x := base*2
+	n, err := strconv.ParseInt(s, x, 64)
+`)
+	test(t, "extract/a.go:10:2", "extract/a.go:11:12", "Extract function", `
+	total := newFunction(n)
+	return total + base, nil
+}
+
+func newFunction(n int64) int {
+	total := int(n)
+	total *= 2
+	return total
+}
+`)
+	// The selection returns from the function.
+	test(t, "extract/a.go:6:2", "extract/a.go:9:3", "", "")
+}
+
+var structTagsContext = newTestContext(cache.None)
+
+func TestStructTags(t *testing.T) {
+	t.Parallel()
+
+	structTagsContext.setup(t)
+
+	// actions returns the titles of the refactorings at pos, and the
+	// file once the refactoring title is applied.
+	actions := func(t *testing.T, tx *TestContext, pos, title string) ([]string, string) {
+		t.Helper()
+		file, line, char, err := parsePos(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)
+		params := lsp.CodeActionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Range: lsp.Range{
+				Start: lsp.Position{Line: line, Character: char},
+				End:   lsp.Position{Line: line, Character: char},
+			},
+		}
+		var actions []protocol.CodeAction
+		if err := tx.conn.Call(tx.ctx, "textDocument/codeAction", params, &actions); err != nil {
+			t.Fatal(err)
+		}
+
+		var titles []string
+		text := readFile(t, tx, file)
+		for _, action := range actions {
+			if action.Kind != protocol.RefactorRewrite {
+				continue
+			}
+			titles = append(titles, action.Title)
+			if action.Title == title {
+				text = applyTextEdits(text, action.Edit.Changes[string(uri)])
+			}
+		}
+		return titles, text
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		titles, text := actions(t, structTagsContext, "structtag/a.go:3:6", "Add json tags")
+		want := []string{
+			"Add json tags",
+			"Add yaml tags",
+			"Add omitempty to json tags",
+			"Add omitempty to yaml tags",
+			"Remove xml tags",
+			"Remove json tags",
+			"Remove yaml tags",
+		}
+		if strings.Join(titles, "\n") != strings.Join(want, "\n") {
+			t.Errorf("got actions\n%s\nwant\n%s", strings.Join(titles, "\n"), strings.Join(want, "\n"))
+		}
+		wantText := `type T struct {
+	Name   string ` + "`json:\"name\"`" + `
+	UserID int    ` + "`xml:\"user\" json:\"user_id\"`" + `
+	Raw    []byte "yaml:\"raw\" json:\"raw\""
+	hidden bool
+}
+`
+		if !strings.HasSuffix(text, wantText) {
+			t.Errorf("got\n%s\nwant it to end with\n%s", text, wantText)
+		}
+	})
+
+	t.Run("field", func(t *testing.T) {
+		titles, text := actions(t, structTagsContext, "structtag/a.go:5:2", "Remove xml tags")
+		want := []string{
+			"Add yaml tags",
+			"Add omitempty to json tags",
+			"Remove xml tags",
+			"Remove json tags",
+		}
+		if strings.Join(titles, "\n") != strings.Join(want, "\n") {
+			t.Errorf("got actions\n%s\nwant\n%s", strings.Join(titles, "\n"), strings.Join(want, "\n"))
+		}
+		if wantLine := "\tUserID int    `json:\"user_id\"`\n"; !strings.Contains(text, wantLine) {
+			t.Errorf("got\n%s\nwant it to contain %q", text, wantLine)
+		}
+	})
+
+	t.Run("case", func(t *testing.T) {
+		defer structTagsContext.configure(t, map[string]interface{}{"structTagCase": "camelcase"})()
+
+		_, text := actions(t, structTagsContext, "structtag/a.go:5:2", "Add yaml tags")
+		if wantLine := "\tUserID int    `xml:\"user\" json:\"user_id\" yaml:\"userID\"`\n"; !strings.Contains(text, wantLine) {
+			t.Errorf("got\n%s\nwant it to contain %q", text, wantLine)
+		}
+	})
+
+	t.Run("unexported field", func(t *testing.T) {
+		if titles, _ := actions(t, structTagsContext, "structtag/a.go:7:2", ""); len(titles) != 0 {
+			t.Errorf("got actions %v, want none", titles)
+		}
+	})
+}
+
+// callRefactorings returns the refactoring code actions of the server of tx
+// for the range from pos to end, empty if end is "", along with the text of
+// its file edited by the first one.
+func callRefactorings(t *testing.T, tx *TestContext, pos, end string) ([]protocol.CodeAction, string) {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)
+	rng := lsp.Range{
+		Start: lsp.Position{Line: line, Character: char},
+		End:   lsp.Position{Line: line, Character: char},
+	}
+	if end != "" {
+		_, line, char, err := parsePos(end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rng.End = lsp.Position{Line: line, Character: char}
+	}
+	params := lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Range:        rng,
+	}
+	var actions []protocol.CodeAction
+	if err := tx.conn.Call(tx.ctx, "textDocument/codeAction", params, &actions); err != nil {
+		t.Fatal(err)
+	}
+
+	var refactorings []protocol.CodeAction
+	for _, action := range actions {
+		if strings.HasPrefix(string(action.Kind), string(protocol.Refactor)) {
+			refactorings = append(refactorings, action)
+		}
+	}
+	text := readFile(t, tx, file)
+	if len(refactorings) > 0 {
+		text = applyTextEdits(text, refactorings[0].Edit.Changes[string(uri)])
+	}
+	return refactorings, text
+}
+
+// readFile returns the content of the file of the test context.
+func readFile(t *testing.T, tx *TestContext, file string) string {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join(tx.root(), file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// callQuickFixes returns the quick fixes of the diagnostic with the given
+// message at pos, as "title: edit, ..." with 1-based positions.
+func callQuickFixes(t *testing.T, pos, message string) []string {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootURI := util.PathToURI(filepath.ToSlash(codeActionContext.root()))
+	uri := uriJoin(rootURI, file)
+	position := lsp.Position{Line: line, Character: char}
+	d := lsp.Diagnostic{Range: lsp.Range{Start: position, End: position}, Message: message}
+	params := lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Range:        d.Range,
+		Context:      lsp.CodeActionContext{Diagnostics: []lsp.Diagnostic{d}},
+	}
+	var actions []protocol.CodeAction
+	if err := codeActionContext.conn.Call(codeActionContext.ctx, "textDocument/codeAction", params, &actions); err != nil {
+		t.Fatal(err)
+	}
+
+	var fixes []string
+	for _, action := range actions {
+		if action.Kind != protocol.QuickFix {
+			continue
+		}
+		var edits []string
+		for _, e := range action.Edit.Changes[string(uri)] {
+			edits = append(edits, fmt.Sprintf("%d:%d-%d:%d %q", e.Range.Start.Line+1, e.Range.Start.Character+1, e.Range.End.Line+1, e.Range.End.Character+1, e.NewText))
+		}
+		fixes = append(fixes, fmt.Sprintf("%s: %s", action.Title, strings.Join(edits, ", ")))
+	}
+	return fixes
+}
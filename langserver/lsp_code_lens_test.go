@@ -0,0 +1,137 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var codeLensContext = newTestContext(cache.Always)
+
+func TestCodeLens(t *testing.T) {
+	t.Parallel()
+
+	codeLensContext.setup(t)
+
+	test := func(t *testing.T, file string, want ...string) {
+		t.Helper()
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(codeLensContext.root())), file)
+		var lenses []protocol.CodeLens
+		params := lsp.CodeLensParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+		if err := codeLensContext.conn.Call(codeLensContext.ctx, "textDocument/codeLens", params, &lenses); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, lens := range lenses {
+			if lens.Command == nil || lens.Command.Command != testCommand && lens.Command.Command != generateCommand || len(lens.Command.Arguments) == 0 {
+				t.Fatalf("%s: got lens %v, want a %s or %s command", file, lens, testCommand, generateCommand)
+			}
+			args := lens.Command.Arguments
+			if dir, _ := args[0].(string); filepath.Base(dir) != filepath.Dir(file) {
+				t.Errorf("%s: got directory %v, want that of the file", file, args[0])
+			}
+			r := lens.Range
+			s := fmt.Sprintf("%d:%d-%d:%d %s", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character, lens.Command.Title)
+			for _, arg := range args[1:] {
+				// The files are absolute.
+				if name, ok := arg.(string); ok && filepath.IsAbs(name) {
+					arg = filepath.Base(name)
+				}
+				s += fmt.Sprintf(" %v", arg)
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", file, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	t.Run("test file", func(t *testing.T) {
+		test(t, "codelens/a_test.go",
+			"0:0-0:16 run package tests",
+			"4:5-4:10 run test -run ^TestA$",
+			"8:5-8:15 run benchmark -bench ^BenchmarkA$",
+			"10:5-10:13 run example -run ^ExampleA$",
+			"14:5-14:9 run test -run ^Test$")
+	})
+
+	t.Run("other file", func(t *testing.T) {
+		test(t, "codelens/a.go")
+	})
+
+	t.Run("generate", func(t *testing.T) {
+		test(t, "codelens/gen.go",
+			"2:0-2:25 run go generate gen.go",
+			"2:0-2:25 run go generate for package",
+			"3:0-3:26 run go generate gen.go",
+			"3:0-3:26 run go generate for package")
+	})
+
+	t.Run("generate file of another directory", func(t *testing.T) {
+		root := codeLensContext.root()
+		params := lsp.ExecuteCommandParams{
+			Command:   generateCommand,
+			Arguments: []interface{}{filepath.Join(root, "codelens"), filepath.Join(root, "gen.go")},
+		}
+		if err := codeLensContext.conn.Call(codeLensContext.ctx, "workspace/executeCommand", params, nil); err == nil {
+			t.Error("got no error, want one")
+		}
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		params := lsp.ExecuteCommandParams{Command: "bingo.unknown"}
+		if err := codeLensContext.conn.Call(codeLensContext.ctx, "workspace/executeCommand", params, nil); err == nil {
+			t.Error("got no error, want one")
+		}
+	})
+
+	t.Run("references", func(t *testing.T) {
+		tx := codeLensContext
+		defer tx.configure(t, map[string]interface{}{"codeLensReferences": true})()
+
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), "codelens/refs.go")
+		var lenses []protocol.CodeLens
+		params := lsp.CodeLensParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+		if err := tx.conn.Call(tx.ctx, "textDocument/codeLens", params, &lenses); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, lens := range lenses {
+			if lens.Command != nil {
+				t.Fatalf("got resolved lens %v, want it unresolved", lens)
+			}
+			// The lens is resolved as the client sends it back.
+			data, err := json.Marshal(lens)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var resolved protocol.CodeLens
+			if err := tx.conn.Call(tx.ctx, "codeLens/resolve", json.RawMessage(data), &resolved); err != nil {
+				t.Fatal(err)
+			}
+			if resolved.Command == nil || resolved.Command.Command != showReferencesCommand || len(resolved.Command.Arguments) != 3 {
+				t.Fatalf("got lens %v, want a %s command", resolved, showReferencesCommand)
+			}
+			r := resolved.Range
+			got = append(got, fmt.Sprintf("%d:%d-%d:%d %s", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character, resolved.Command.Title))
+		}
+		want := []string{
+			"2:5-2:13 3 references",
+			"4:16-4:22 1 reference",
+			"6:5-6:8 1 reference",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
@@ -61,12 +64,114 @@ func TestCompletion(t *testing.T) {
 	})
 
 	t.Run("completion", func(t *testing.T) {
-		test(t, "completion/a.go:6:7", "6:6-6:7 strings module \"strings\", s1 = 42 constant int, s2() function , s3 variable int, s4 variable func(), string typeParameter ")
+		test(t, "completion/a.go:6:7", "6:6-6:7 s1 = 42 constant int, s2() function , s3 variable int, s4 variable func(), strings module \"strings\", string typeParameter ")
 		test(t, "completion/a.go:7:7", "7:6-7:7 new(T) function *T, nil variable ")
 		test(t, "completion/a.go:12:11", "12:8-12:11 int typeParameter , int16 typeParameter , int32 typeParameter , int64 typeParameter , int8 typeParameter ")
 		test(t, "completion/b.go:1:44", "1:38-1:44 Println(a ...interface{}) function n int, err error")
+		test(t, "completion/rank/a.go:6:6", "6:2-6:6 valueC variable int, valueB variable int, valueA variable int")
+		test(t, "completion/rank/a.go:7:4", "7:2-7:4 valueB variable int")
+		test(t, "completion/deep/a.go:8:11", "8:9-8:11 buf.String() method string, buf variable bytes.Buffer")
+		test(t, "completion/deep/a.go:12:11", "12:9-12:11 t.Name field string")
+		test(t, "completion/expected/a.go:8:26", "8:24-8:26 time.Second = 1000000000 constant time.Duration")
+		test(t, "completion/expected/a.go:9:12", "9:11-9:12 dy variable time.Duration, dx variable int, delete(m map[K]V, key K) function ")
+		test(t, "completion/expected/a.go:10:14", "10:13-10:14 dy variable time.Duration, dx variable int, delete(m map[K]V, key K) function ")
 		test(t, "completion/c.go:8:11", "8:6-8:11 Print(a ...interface{}) function n int, err error, Printf(format string, a ...interface{}) function n int, err error, Println(a ...interface{}) function n int, err error")
 	})
+
+	t.Run("utf16", func(t *testing.T) {
+		test(t, "utf16/b.go:3:21", "3:18-3:21 日本語 struct struct{...}")
+		test(t, "utf16/b.go:3:24", "3:24-3:24 F field int")
+	})
+
+	t.Run("composite literal fields", func(t *testing.T) {
+		root, err := filepath.Abs(completionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootURI := util.PathToURI(root)
+		for _, c := range []struct {
+			pos, want string
+		}{
+			{"completion/complit/a.go:10:32", `Range "Range: "`},
+			{"completion/complit/a.go:11:39", `End "End: ", Start "Start: "`},
+			{"completion/complit/a.go:12:12", `A "A: ", B "B: ", p "p: "`},
+			{"completion/complit/a.go:13:21", `B "B: ", p "p: "`},
+			{"completion/complit/a.go:14:12", `A "A", B "B", p "p"`},
+		} {
+			doFieldCompletionTest(t, completionContext.ctx, completionContext.conn, rootURI, c.pos, c.want)
+		}
+	})
+
+	t.Run("keywords", func(t *testing.T) {
+		root, err := filepath.Abs(completionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootURI := util.PathToURI(root)
+		const stmts = "const, defer, for, func, go, if, return, select, switch, type, var"
+		for _, c := range []struct {
+			pos, want string
+		}{
+			{"completion/keywords/decl.go:3:3", "func"},
+			{"completion/keywords/else.go:5:6", "else"},
+			{"completion/keywords/range.go:4:13", "range"},
+			{"completion/keywords/stmt.go:4:2", stmts},
+			{"completion/keywords/switch.go:6:3", "case, const, default, defer, for, func, go, if, return, select, switch, type, var"},
+			{"completion/keywords/switch.go:9:2", "case, default"},
+		} {
+			doKeywordCompletionTest(t, completionContext.ctx, completionContext.conn, rootURI, c.pos, c.want)
+		}
+	})
+
+	t.Run("operator and builtin contexts", func(t *testing.T) {
+		test(t, "completion/ctxt/addr.go:10:9", "10:8-10:9 &Point{} struct struct{...}, Points typeParameter []Point, Pt() function Point")
+		test(t, "completion/ctxt/make.go:4:13", "4:12-4:13 Points typeParameter []Point")
+
+		root, err := filepath.Abs(completionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := completionItems(t, completionContext.ctx, completionContext.conn, util.PathToURI(root), "completion/ctxt/addr.go:10:9")
+		if len(items) == 0 || items[0].TextEdit.NewText != "Point{}" {
+			t.Errorf("got %v, want a first item inserting %q", items, "Point{}")
+		}
+	})
+
+	t.Run("method stubs", func(t *testing.T) {
+		test(t, "completion/stubs/a.go:15:18", "15:18-15:18 Close() error method Closer, Write(p []byte) (n int, err error) method io.ReadWriter")
+
+		root, err := filepath.Abs(completionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := completionItems(t, completionContext.ctx, completionContext.conn, util.PathToURI(root), "completion/stubs/a.go:15:18")
+		want := "Close() error {\n\tpanic(\"unimplemented\")\n}"
+		if len(items) == 0 || items[0].TextEdit.NewText != want {
+			t.Errorf("got %v, want a first item inserting %q", items, want)
+		}
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		root, err := filepath.Abs(completionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := completionItems(t, completionContext.ctx, completionContext.conn, util.PathToURI(root), "completion/resolve/a.go:7:4")
+		if len(items) != 1 {
+			t.Fatalf("got %d items, want 1", len(items))
+		}
+		it := items[0]
+		if it.Detail != "" || it.Documentation != "" || it.Data == nil {
+			t.Fatalf("got detail %q, documentation %q and data %v before resolving, want only data", it.Detail, it.Documentation, it.Data)
+		}
+		it, err = resolveCompletionItem(completionContext.ctx, completionContext.conn, it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Sum returns the sum of a and b.\n"; it.Label != "Sum(a int, b int)" || it.Detail != "int" || it.Documentation != want {
+			t.Errorf("got %q %q %q, want %q %q %q", it.Label, it.Detail, it.Documentation, "Sum(a int, b int)", "int", want)
+		}
+	})
 }
 
 type completionTestCase struct {
@@ -98,6 +203,49 @@ func doCompletionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 	}
 }
 
+// doFieldCompletionTest checks the fields completed at pos, along with
+// the text they insert.
+func doFieldCompletionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	var fields []string
+	for _, it := range completionItems(t, ctx, c, rootURI, pos) {
+		if it.Kind == lsp.CIKField {
+			fields = append(fields, fmt.Sprintf("%s %q", it.Label, it.TextEdit.NewText))
+		}
+	}
+	if got := strings.Join(fields, ", "); got != want {
+		t.Errorf("%s: got %q, want %q", pos, got, want)
+	}
+}
+
+// doKeywordCompletionTest checks the keywords completed at pos.
+func doKeywordCompletionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	var keywords []string
+	for _, it := range completionItems(t, ctx, c, rootURI, pos) {
+		if it.Kind == lsp.CIKKeyword {
+			keywords = append(keywords, it.Label)
+		}
+	}
+	if got := strings.Join(keywords, ", "); got != want {
+		t.Errorf("%s: got %q, want %q", pos, got, want)
+	}
+}
+
+func completionItems(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos string) []lsp.CompletionItem {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res lsp.CompletionList
+	err = c.Call(ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Items
+}
+
 func callCompletion(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) (string, error) {
 	var res lsp.CompletionList
 	err := c.Call(ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
@@ -109,6 +257,9 @@ func callCompletion(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI,
 	}
 	var str string
 	for i, it := range res.Items {
+		if it, err = resolveCompletionItem(ctx, c, it); err != nil {
+			return "", err
+		}
 		if i != 0 {
 			str += ", "
 		} else {
@@ -119,3 +270,113 @@ func callCompletion(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI,
 	}
 	return str, nil
 }
+
+// resolveCompletionItem returns the item completed by
+// completionItem/resolve.
+func resolveCompletionItem(ctx context.Context, c *jsonrpc2.Conn, item lsp.CompletionItem) (lsp.CompletionItem, error) {
+	var res lsp.CompletionItem
+	err := c.Call(ctx, "completionItem/resolve", item, &res)
+	return res, err
+}
+
+var importPathCompletionContext = newTestContext(cache.Always)
+
+func TestImportPathCompletion(t *testing.T) {
+	t.Parallel()
+
+	tx := importPathCompletionContext
+	tx.setup(t)
+	rootURI := util.PathToURI(filepath.ToSlash(tx.root()))
+
+	const docPath = "github.com/saibing/bingo/langserver/test/pkg/completion/imports/doc"
+	for _, c := range []struct {
+		pos, want, detail string
+	}{
+		{"completion/imports/a.go:4:9", "4:3-4:9 encoding/json", ""},
+		{"completion/imports/a.go:5:7", "5:3-5:7 strings", ""},
+		{"completion/imports/a.go:6:68", "6:3-6:68 " + docPath, "Package doc is documented."},
+	} {
+		t.Run(c.pos, func(t *testing.T) {
+			// The best match comes first, followed by the paths matching
+			// from another segment, which depend on the dependencies.
+			items := completionItems(t, tx.ctx, tx.conn, rootURI, c.pos)
+			if len(items) == 0 {
+				t.Fatalf("got no items, want %q", c.want)
+			}
+			e := items[0].TextEdit.Range
+			got := fmt.Sprintf("%d:%d-%d:%d %s", e.Start.Line+1, e.Start.Character+1, e.End.Line+1, e.End.Character+1, items[0].Label)
+			if got != c.want {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+			// The synopses of the standard library vary with the version
+			// of Go.
+			if c.detail != "" && items[0].Detail != c.detail {
+				t.Errorf("got detail %q, want %q", items[0].Detail, c.detail)
+			}
+		})
+	}
+}
+
+var unimportedCompletionContext = newTestContext(cache.Always)
+
+func TestUnimportedCompletion(t *testing.T) {
+	t.Parallel()
+
+	tx := unimportedCompletionContext
+	tx.setup(t)
+	rootURI := util.PathToURI(filepath.ToSlash(tx.root()))
+
+	const errorsPath = "github.com/saibing/bingo/langserver/test/pkg/unimported/errors"
+	for _, c := range []struct {
+		pos, label string
+		want       []string
+	}{
+		{"unimported/a.go:4:14", "Itoa(i int)", []string{
+			`string (from "strconv") 0:18-0:18 "\n\nimport (\n\t\"strconv\"\n)"`,
+		}},
+		{"unimported/b.go:6:21", "New(text string)", []string{
+			`error (from "errors") 2:0-2:12 "import (\n\t\"errors\"\n\t\"fmt\"\n)"`,
+			`error (from "` + errorsPath + `") 2:0-2:12 "import (\n\t\"fmt\"\n\t\"` + errorsPath + `\"\n)"`,
+		}},
+		{"unimported/c.go:9:38", "Itoa(i int)", []string{
+			`string (from "strconv") 4:0-4:0 "\t\"strconv\"\n"`,
+		}},
+	} {
+		t.Run(c.pos, func(t *testing.T) {
+			file, line, char, err := parsePos(c.pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var res protocol.CompletionList
+			err = tx.conn.Call(tx.ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+				Position:     lsp.Position{Line: line, Character: char},
+			}}, &res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, it := range res.Items {
+				if it.Label != c.label {
+					continue
+				}
+				if len(it.AdditionalTextEdits) != 0 {
+					t.Errorf("%s: got additional edits before resolving the item", it.Label)
+				}
+				if err := tx.conn.Call(tx.ctx, "completionItem/resolve", it, &it); err != nil {
+					t.Fatal(err)
+				}
+				s := it.Detail
+				for _, e := range it.AdditionalTextEdits {
+					s += fmt.Sprintf(" %s %q", e.Range, e.NewText)
+				}
+				got = append(got, s)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var configurationContext, configurationClient = newConfigurationTestContext()
+
+// configurationRecorder is a client answering workspace/configuration with
+// its settings, and recording the messages and the diagnostics of the
+// server.
+type configurationRecorder struct {
+	mu       sync.Mutex
+	settings map[string]interface{}
+
+	messages  chan lsp.ShowMessageParams
+	published chan protocol.PublishDiagnosticsParams
+}
+
+func (r *configurationRecorder) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	switch req.Method {
+	case "workspace/configuration":
+		r.mu.Lock()
+		settings := r.settings
+		r.mu.Unlock()
+		_ = conn.Reply(ctx, req.ID, []interface{}{settings})
+	case "window/showMessage":
+		var params lsp.ShowMessageParams
+		if req.Params != nil && json.Unmarshal(*req.Params, &params) == nil {
+			r.messages <- params
+		}
+	case "textDocument/publishDiagnostics":
+		var params protocol.PublishDiagnosticsParams
+		if req.Params != nil && json.Unmarshal(*req.Params, &params) == nil {
+			r.published <- params
+		}
+	}
+}
+
+func (r *configurationRecorder) setSettings(settings map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = settings
+}
+
+func newConfigurationTestContext() (*TestContext, *configurationRecorder) {
+	tx := newTestContextWith(cache.None, func(cfg *Config) {
+		cfg.DiagnosticsStyle = string(instantDiagnostics)
+		cfg.VetOnSave = false
+	}, &protocol.ClientCapabilities{
+		Workspace: protocol.WorkspaceClientCapabilities{Configuration: true},
+	})
+
+	client := &configurationRecorder{
+		settings:  map[string]interface{}{},
+		messages:  make(chan lsp.ShowMessageParams, 100),
+		published: make(chan protocol.PublishDiagnosticsParams, 100),
+	}
+	tx.client = client
+	return tx, client
+}
+
+func TestDidChangeConfiguration(t *testing.T) {
+	t.Parallel()
+
+	tx := configurationContext
+	tx.setup(t)
+	if err := tx.conn.Notify(tx.ctx, "initialized", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join(tx.root(), "configuration/a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), "configuration/a.go")
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: string(text)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Tagged is only declared with the special build tag.
+	waitConfigurationDiagnostics(t, uri, false)
+
+	changeSettings := func(t *testing.T, settings map[string]interface{}) {
+		t.Helper()
+		configurationClient.setSettings(settings)
+		if err := tx.conn.Notify(tx.ctx, "workspace/didChangeConfiguration", protocol.DidChangeConfigurationParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("build tags", func(t *testing.T) {
+		changeSettings(t, map[string]interface{}{"buildTags": []string{"special"}})
+		waitConfigurationDiagnostics(t, uri, true)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		changeSettings(t, map[string]interface{}{"buildTags": []string{"special"}, "formatTool": "clang-format"})
+		timeout := time.After(30 * time.Second)
+		for {
+			select {
+			case params := <-configurationClient.messages:
+				if params.Type == lsp.MTWarning && strings.Contains(params.Message, "formatTool") {
+					return
+				}
+			case <-timeout:
+				t.Fatal("the invalid settings were not rejected")
+			}
+		}
+	})
+}
+
+// waitConfigurationDiagnostics waits for diagnostics to be published for
+// the document uri, an empty set of them if empty is set.
+func waitConfigurationDiagnostics(t *testing.T, uri lsp.DocumentURI, empty bool) {
+	t.Helper()
+	want := makePath(util.UriToRealPath(uri))
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case params := <-configurationClient.published:
+			if makePath(util.UriToRealPath(params.URI)) == want && (len(params.Diagnostics) == 0) == empty {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no diagnostics published for %s (empty %t)", uri, empty)
+		}
+	}
+}
@@ -6,13 +6,85 @@ var testdata = []packagestest.Module{
 	{
 		Name: "github.com/saibing/bingo/langserver/test/pkg",
 		Files: map[string]interface{}{
+			"asm/a.go":             `package asm; func Add(x, y int) int; func Sub(x, y int) int; var _ = Add(1, 2); var _ = Sub(1, 2)`,
+			"asm/add.s":            "#include \"textflag.h\"\n\nTEXT ·Add(SB),NOSPLIT,$0-24\n\tRET\n",
+			"asm/sub_plan9_mips.s": "#include \"textflag.h\"\n\nTEXT ·Sub(SB),NOSPLIT,$0-24\n\tRET\n",
+
 			"basic/a.go": `package p; func A() { A() }`,
 			"basic/b.go": `package p; func B() { A() }`,
 
+			"symbolindex/a.go": `package p; func Old() {}`,
+
 			"builtin/a.go": `package p; func A() { println("hello") }`,
+			"builtin/b.go": `package p; var _ = len(""); const c = iota; var e error = nil`,
+			"builtin/c.go": `package p; var m = make([]int, 0); var n = new(int); var t = true`,
+
+			"cgo/a.go": `package cgo
+
+// int add(int a, int b) { return a + b; }
+import "C"
+
+func Add(a, b int) int { return int(C.add(C.int(a), C.int(b))) }
+
+var _ = Add(1, 2)
+`,
+
+			"complit/a.go": `package p; type T struct { A int; B }; type B struct{}; var _ = T{A: 1, B: B{}}; var k = "k"; var _ = map[string]int{k: 1}`,
+
+			"constant/a.go": `package p; const size = 4 << 10; const pi = 3.25; const r = 'a'; const s = "hello"; const f float64 = 1.5; type Weekday int; const ( Sunday Weekday = iota; Monday ); const long = "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"; var _ = Monday`,
+
+			"declaration/a.go": `package p; import "io"; type Fooer interface{ Foo() }; type S struct{}; func (S) Foo() {}; func (S) Bar() {}; func (*S) Close() error { return nil }; func F(s S) { s.Foo(); s.Bar(); var c io.Closer = &s; c.Close(); var x int; _ = x }`,
+
+			"highlight/a.go": `package p; type T struct{ f int }; func F(s []int, t *T) int { x := 0; x = 1; x++; (x)--; for x = range s {}; q := &x; t.f = *q; t.f += x; return x + t.f }`,
+
+			"hoverrange/a.go": `package p
+
+type T struct{ F int }
+
+var 日本語変数 = T{}
+
+var x = 日本語変数.F
+
+var 𝒜 = "😀" + "a"
+
+var s, 𝒜2 = "😀", 𝒜
+`,
+			"utf16/a.go": `package utf16
+
+type 日本語 struct{ F int }
+
+// 😀😀 comment
+/* 😀😀 */ var A = 日本語{F: 1}
+
+var B = /* 😀 */ A.F + len("日本語")
+`,
+			"utf16/b.go": `package utf16
+
+var C = /* 😀 */ 日本語{}.F
+`,
+			"deprecated/a.go": `package p
+
+// Foo does foo.
+//
+// Deprecated: Use Bar instead.
+func Foo() {}
+
+// T is a type.
+//
+// Deprecated: T is replaced by
+// Bar.
+type T struct{}
+
+// Bar is not deprecated. Deprecated: in the middle of a paragraph does not count.
+func Bar() {}`,
 
 			"detailed/a.go": `package p; type T struct { F string }`,
 
+			"dotimport/a.go":   `package p; import . "math"; import . "github.com/saibing/bingo/langserver/test/pkg/dotimport/d"; var _ = Sqrt(2); var _ = D()`,
+			"dotimport/d/d.go": `package d; func D() int { return 0 }`,
+
+			"embedded/a.go": `package p; import ("bytes"; "io"); type A struct { io.Reader }; type B struct { *bytes.Buffer }; type C struct { B }; var a A; var _ = a.Reader; var _ = a.Read; var c C; var _ = c.Buffer; var _ = c.B.Buffer; var _ = c.Len`,
+
 			"exported_on_unexported/a.go": `package p; type t struct { F string }`,
 
 			"gomodule/a.go": `package a; import "github.com/saibing/dep"; var _ = dep.D; var _ = dep.D`,
@@ -22,8 +94,17 @@ var testdata = []packagestest.Module{
 			"goproject/a/a.go": `package a; func A() {}`,
 			"goproject/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/goproject/a"; var _ = a.A`,
 
+			"bodyonly/a/a.go": `package a; type T struct{}; func F() T { return T{} }`,
+			"bodyonly/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/bodyonly/a"; func G() a.T { return a.F() }`,
+			"bodyonly/c/c.go": `package c; import "github.com/saibing/bingo/langserver/test/pkg/bodyonly/b"; var _ = b.G()`,
+			"bodyonly/d/d.go": `package d; import ("github.com/saibing/bingo/langserver/test/pkg/bodyonly/a"; "github.com/saibing/bingo/langserver/test/pkg/bodyonly/b"); var _ a.T = b.G()`,
+
 			"goroot/a.go": `package p; import "fmt"; var _ = fmt.Println; var x int`,
 
+			"pkgname/a.go": `package p; import f "fmt"; var _ = f.Println`,
+			"pkgname/b.go": `package p; import . "fmt"; var _ = Println`,
+			"pkgname/c.go": `package p; import d "github.com/saibing/dep/pkg2"; var _ = d.X`,
+
 			"implementations/i0.go":    `package p; type I0 interface { M0() }`,
 			"implementations/i1.go":    `package p; type I1 interface { M1() }`,
 			"implementations/i2.go":    `package p; type I2 interface { M1(); M2() }`,
@@ -32,11 +113,28 @@ var testdata = []packagestest.Module{
 			"implementations/t1e.go":   `package p; type T1E struct { T1 }; var _ = (T1E{}).M1`,
 			"implementations/t1p.go":   `package p; type T1P struct {}; func (*T1P) M1() {}`,
 			"implementations/p2/p2.go": `package p2; type T2 struct{}; func (T2) M1() {}`,
+			"implementations/u/u.go":   `package u; type U interface { m() }; type V struct{}; func (V) m() {}`,
+			"implementations/u2/u2.go": `package u2; type W struct{}; func (W) m() {}`,
+
+			"implementations/embedded/e1.go": `package embedded; type E1 interface { N1() }`,
+			"implementations/embedded/e3.go": `package embedded; type E3 interface { E1; N3() }`,
+			"implementations/embedded/t3.go": `package embedded; type T3 struct{}; func (T3) N1() {}; func (T3) N3() {}`,
+
+			"labels/a.go": `package p; func F() { outer: for { switch { case true: break outer } }; retry: for { select { default: continue retry } }; goto end; end: return }`,
+
+			"linedirective/a.go": `package p
+
+//line a.y:10
+func Y() {}
+
+var _ = Y
+`,
 
 			"lookup/a/a.go": `package a; type A int; func A1() A { var A A = 1; return A }`,
 			"lookup/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/lookup/a"; func Dummy() a.A { x := a.A1(); return x }`,
 			"lookup/c/c.go": `package c; import "github.com/saibing/bingo/langserver/test/pkg/lookup/a"; func Dummy() **a.A { var x **a.A; return x }`,
 			"lookup/d/d.go": `package d; import "github.com/saibing/bingo/langserver/test/pkg/lookup/a"; func Dummy() map[string]a.A { var x map[string]a.A; return x }`,
+			"lookup/e/e.go": `package e; type K int; type V struct{}; var m map[K]V; var s []*V; var c chan [2]K; var x struct{}; var n int`,
 
 			"multiple/a.go": `package p; func A() { A() }`,
 			"multiple/main.go": `// +build ignore
@@ -47,18 +145,398 @@ package main;  func B() { p.A(); B() }`,
 			"workspace_multiple/b.go": `package p; import "fmt"; var _ = fmt.Println; var y int`,
 			"workspace_multiple/c.go": `package p; import "fmt"; var _ = fmt.Println; var z int`,
 
+			"shadow/a.go": `package p
+
+func g() error { return nil }
+
+func f() (err error) {
+	if err := g(); err != nil {
+		return err
+	}
+	for err := g(); err != nil; err = g() {
+		_ = err
+	}
+	func() {
+		err := g()
+		_ = err
+	}()
+	return err
+}
+`,
+
 			"subdirectory/a.go":    `package d; func A() { A() }`,
 			"subdirectory/d2/b.go": `package d2; import "github.com/saibing/bingo/langserver/test/pkg/subdirectory"; func B() { d.A(); B() }`,
 
+			"structinfo/a.go": `package p; type S struct { A bool; B int64; C int32 }; type E struct { S }; var s S; var e E; var _ = s.B; var _ = e.C`,
+
 			"typealias/a.go": `package p; type A struct{ a int }`,
 			"typealias/b.go": `package p; type B = A`,
 
+			"typeformat/a.go": `package p
+
+import "io"
+
+type ID int64
+
+type Handler func(ID) error
+
+type Set map[string]struct{}
+
+type Reader = io.Reader
+
+type Local = ID
+
+type Point = struct{ X, Y int }
+
+type Pair struct{ A, B ID }
+`,
+
+			"typeswitch/a.go": `package p; type I interface{ Bar() }; type Foo struct{}; func (*Foo) Bar() {}; func F(x I) { switch v := x.(type) { case *Foo: v.Bar(); default: v.Bar() }; if f, ok := x.(*Foo); ok { f.Bar() } }`,
+
+			"typedetails/a.go": `package p; import "io"; type T struct { A int "json:\"a\""; b string }; func (T) Exported() {}; func (*T) unexported() {}; func (*T) Another() int { return 0 }; type I interface { M(); io.Reader }; type Big struct { F0 int; F1 int; F2 int; F3 int; F4 int; F5 int; F6 int; F7 int; F8 int; F9 int; F10 int; F11 int; F12 int; F13 int; F14 int; F15 int; F16 int; F17 int; F18 int; F19 int; F20 int; F21 int; F22 int; F23 int; F24 int; F25 int; F26 int; F27 int; F28 int; F29 int; F30 int; F31 int; F32 int; F33 int; F34 int; F35 int; F36 int; F37 int; F38 int; F39 int }`,
+
 			"unexpected_paths/a.go": `package p; func A() { A() }`,
 
+			"formatting/minimal.go": "package formatting\n\nfunc A() {}\n\nvar  x = 1\n",
+			"formatting/broken.go":  "package formatting\n\nfunc  B( {\n",
+			"formatting/decls.go":   "package formatting\n\nfunc  C() {\nreturn\n}\n\nvar  y = 1\n",
+			"formatting/ontype.go":  "package formatting\n\nfunc D() {\nif true {\nD()\n}\n\n}\n",
+
+			"fillstruct/a.go": `package fillstruct
+
+import "github.com/saibing/bingo/langserver/test/pkg/fillstruct/lsp"
+
+func f() {
+	_ = lsp.Location{}
+	_ = T{A: 1}
+	_ = []int{}
+}
+
+type T struct {
+	A int
+	b string
+	P *T
+	S lsp.Range
+}
+`,
+			"fillstruct/lsp/lsp.go": `package lsp; type Position struct { Line, Character int }; type Range struct { Start, End Position }; type Location struct { URI string; Range Range; internal int }`,
+
+			"implement/a.go": `package implement
+
+import (
+	"fmt"
+
+	"github.com/saibing/bingo/langserver/test/pkg/implement/iface"
+)
+
+type Server struct{}
+
+func (s *Server) String() string { return fmt.Sprint("server") }
+
+var _ iface.Writer = &Server{}
+`,
+			"implement/iface/iface.go": `package iface; import "bytes"; type Writer interface { WriteTo(b *bytes.Buffer) error; String() string }`,
+
+			"cancel/a.go": `package cancel`,
+			"cancel/a_test.go": `package cancel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlow(t *testing.T) {
+	time.Sleep(time.Minute)
+}
+`,
+			"codelens/a.go": "package codelens\n",
+			"codelens/a_test.go": `package codelens
+
+import "testing"
+
+func TestA(t *testing.T) {}
+
+func Testb(t *testing.T) {}
+
+func BenchmarkA(b *testing.B) {}
+
+func ExampleA() {}
+
+func TestMain(m *testing.M) {}
+
+func Test(t *testing.T) {}
+
+func helper(t *testing.T) {}
+`,
+
+			"codelens/refs.go": `package codelens
+
+type Exported struct{}
+
+func (Exported) Method() {}
+
+func New() Exported { return Exported{} }
+
+func unexported() {}
+
+var _ = New().Method
+`,
+			"codelens/gen.go": `package codelens
+
+//go:generate echo gen.go
+//go:generate	echo package
+
+func generated() {
+	//go:generate echo indented
+}
+
+var _ = 1 //go:generate echo trailing
+
+//go:generated
+`,
+
+			"callhierarchy/a.go": `package callhierarchy
+
+type T struct{ Embedded }
+
+type Embedded struct{}
+
+func (Embedded) M() {}
+
+type I interface{ M() }
+
+func A() {
+	B()
+	B()
+	var t T
+	t.M()
+	f := t.M
+	f()
+	var i I = t
+	i.M()
+	g := C
+	_ = g
+}
+
+func B() { C() }
+
+func C() {}
+`,
+
+			"linkedediting/a.go": `package linkedediting
+
+type T struct{ n int }
+
+func (t *T) Add(delta int) int {
+	t.n += delta
+	f := func() int { return delta * t.n }
+	return f()
+}
+
+func F(s string) (n int) {
+	n = len(s)
+	return n
+}
+`,
+
+			"links/a.go": `package links
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+//go:generate cp data.txt "copy.txt"
+//go:generate cat "data.txt" missing.txt
+
+//go:embed data.txt
+var text string
+
+var _ = fmt.Sprint(text, exec.ErrNotFound)
+`,
+			"links/data.txt": "data\n",
+
+			"folding/a.go": `// Package folding has
+// a doc comment.
+package folding
+
+import (
+	"fmt"
+	"os"
+)
+
+type T struct {
+	A int
+	B int
+}
+
+type I interface{ M() }
+
+var v = []int{
+	1,
+	2,
+}
+
+func F() {
+	if true {
+		fmt.Println(os.Args)
+	}
+	_ = T{A: 1}
+}
+
+/*
+block
+*/
+`,
+			"folding/illtyped/a.go": `package illtyped
+
+func F() int {
+	return "not an int"
+}
+`,
+
+			"selection/a.go": `package selection
+
+import "fmt"
+
+func F(s string) {
+	// Print the
+	// greeting.
+	fmt.Println("héllo", s)
+	/* done */
+}
+`,
+
+			"semantic/a.go": `package semantic
+
+import "fmt"
+
+const greeting = "héllo"
+
+type T struct {
+	Name string
+}
+
+type I interface {
+	M(n int) string
+}
+
+func (t T) M(n int) string {
+	s := fmt.Sprint("é", t.Name, n, greeting, nil)
+	return s
+}
+
+var _ I = T{Name: "t"}
+`,
+			"semantic/illtyped/a.go": `package illtyped
+
+import "fmt"
+
+type T struct {
+	Name string
+}
+
+func F(t T, n int) string {
+	fmt.Println(t.Name, n)
+	return n
+}
+`,
+
+			"structtag/a.go": `package structtag
+
+type T struct {
+	Name   string
+	UserID int    ` + "`xml:\"user\" json:\"user_id\"`" + `
+	Raw    []byte "yaml:\"raw\""
+	hidden bool
+}
+`,
+
+			"errcheck/a.go": `package errcheck
+
+import "os"
+
+type Kind int
+
+type T struct{ A int }
+
+func Open(p string) (*os.File, Kind, T, error) {
+	f, err := os.Open(p)
+	return f, 0, T{}, err
+}
+
+func Close(f *os.File) {
+	err := f.Close() // closed
+	_ = err
+}
+
+func Checked(p string) error {
+	_, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+`,
+
+			"extract/a.go": `package extract
+
+import "strconv"
+
+func Parse(s string, base int) (int, error) {
+	n, err := strconv.ParseInt(s, base*2, 64)
+	if err != nil {
+		return 0, err
+	}
+	total := int(n)
+	total *= 2
+	return total + base, nil
+}
+`,
+
+			"organize/a.go":       "package organize\n\nimport (\n\t\"github.com/saibing/bingo/langserver/test/pkg/organize/lib\"\n\t\"os\"\n\t\"strings\"\n)\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint(lib.X, os.Args)\n",
+			"organize/lib/lib.go": "package lib\n\nvar X = 1\n",
+
+			"goimports/a.go": `package goimports
+
+import "github.com/saibing/bingo/langserver/test/pkg/goimports/unused"
+
+var Name = lib.Name()
+`,
+			"goimports/lib/lib.go":       `package lib; func Name() string { return "" }`,
+			"goimports/unused/unused.go": `package unused; func Unused() {}`,
+
+			"syntaxerror/a.go": `package syntaxerror
+
+type Before struct{}
+
+func Broken() {
+	Before{}.
+}
+
+func After() {}
+`,
+
 			"xreferences/a.go": `package p; import "fmt"; var _ = fmt.Println; var x int`,
 			"xreferences/b.go": `package p; import "fmt"; var _ = fmt.Println; var y int`,
 			"xreferences/c.go": `package p; import "fmt"; var _ = fmt.Println; var z int`,
 
+			"ifacerefs/a.go": `package p; type I interface{ K() }; type T struct{}; func (T) K() {}; func F(i I, t T) { i.K(); t.K() }`,
+			"ifacerefs/b.go": `package p; func K() {}`,
+
+			"declrefs/a.go": `package p; var ( A, B = 1, 2 ); type S struct{ F int }; var _ = S{F: A}; var _ = B + S{}.F`,
+
+			"embedrefs/a.go":   `package p; type Base struct{ ID int }; func (Base) M() {}; type User struct{ Base }; type Admin struct{ *User }; func F(u User, a Admin, pa *Admin) { _ = u.ID; _ = a.ID; _ = pa.ID; u.M(); a.M(); pa.M() }`,
+			"embedrefs/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/embedrefs"; func G(u *p.Admin) int { u.M(); return u.ID }`,
+
+			"samefields/a.go":      `package p; type A struct{ Name string }; type B struct{ Name string }; func F() { _ = A{Name: ""}.Name; _ = B{Name: ""}.Name }`,
+			"samefields/a_test.go": `package p; var _ = B{}.Name`,
+			"samefields/b/b.go":    `package b; import "github.com/saibing/bingo/langserver/test/pkg/samefields"; var _ = p.A{}.Name; var _ = p.B{Name: ""}`,
+
+			"refs/a/a.go": `package a; type T struct{}; func (T) M() {}; func (T) m() {}; func G() { T{}.m() }`,
+			"refs/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/refs/a"; type U struct{ a.T }`,
+			"refs/c/c.go": `package c; import "github.com/saibing/bingo/langserver/test/pkg/refs/b"; func F(u b.U) { u.M() }`,
+
 			"test/a.go":      `package p; var A int`,
 			"test/a_test.go": `package p; import "testing"; import "github.com/saibing/bingo/langserver/test/pkg/test/b"; var X = b.B; func TestB(t *testing.T) {}`,
 			"test/b/b.go":    `package b; var B int; func C() int { return B };`,
@@ -99,6 +577,49 @@ func A() string {
 	return "test"
 }`,
 
+			"renaming/cross/a/a.go": `package a; type I interface{ Q() }; type T struct{}; func (T) Q() {}; func F() {}; var v int; func g() int { return v }`,
+			"renaming/cross/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/renaming/cross/a"; type U struct{}; func (*U) Q() {}; var _ a.I = &U{}; func G() { a.F(); a.T{}.Q() }`,
+
+			"renaming/stringer/a.go": `package stringer; import "fmt"; type T struct{}; func (T) String() string { return "" }; var _ fmt.Stringer = T{}`,
+
+			"renaming/conflict/a.go": `package conflict
+
+import "fmt"
+
+type T struct{ f, g int }
+
+func (T) M() {}
+func (T) N() {}
+
+func F() {
+	count := 1
+	err := fmt.Errorf("")
+	fmt.Println(count, err)
+}
+
+func G(x int) int {
+	y := 2
+	{
+		z := 3
+		x += z
+	}
+	return x + y
+}
+
+var v int
+
+func H() int {
+	w := 1
+	return v + w + len("")
+}`,
+
+			"renaming/pkg/p/p.go":      `package p; func F() {}`,
+			"renaming/pkg/p/p_test.go": `package p; func TestF() { F() }`,
+			"renaming/pkg/p/x_test.go": `package p_test; import "github.com/saibing/bingo/langserver/test/pkg/renaming/pkg/p"; var _ = p.F`,
+			"renaming/pkg/q/q.go":      `package q; import "github.com/saibing/bingo/langserver/test/pkg/renaming/pkg/p"; var _ = p.F; func G() { p.F() }`,
+			"renaming/pkg/r/r.go":      `package r; import pp "github.com/saibing/bingo/langserver/test/pkg/renaming/pkg/p"; var _ = pp.F`,
+			"renaming/pkg/s/s.go":      `package s; import "github.com/saibing/bingo/langserver/test/pkg/renaming/pkg/p"; var n int; var _ = p.F; var _ = n`,
+
 			"symbols/abc.go": `package a
 
 type XYZ struct {}
@@ -121,6 +642,33 @@ type (
 type UVW interface {}
 
 type T string`,
+			"outline/tree.go": `package outline
+
+// Tree is a binary tree.
+type Tree struct {
+	Left, Right *Tree
+	value       int
+}
+
+type Walker interface {
+	Walk(t *Tree)
+}
+
+// Walk walks the tree.
+func (t *Tree) Walk(w Walker) {}
+
+func Build() *Tree {
+	type node struct{ t *Tree }
+	return nil
+}
+
+const (
+	// Small is small.
+	Small = 1
+	Large = 2
+)
+
+var single int`,
 			"symbols/bcd.go": `package a
 
 type YZA struct {}
@@ -155,6 +703,58 @@ func C(x int, y int) int {
 			"signature/c.go": `package p; import "fmt"; func test1() { fmt.Printf("%s",)}`,
 			"signature/d.go": `package p; import "fmt"; func test2() { fmt.Printf()}`,
 			"signature/e.go": `package p; import "fmt"; func test3() { append()}`,
+			"signature/f.go": `package p
+
+// Join joins the elements.
+//
+// sep: the separator placed between
+// the elements.
+// elems - the elements to join.
+func Join(sep string, elems ...string) string { return "" }
+
+func Len(s string) int { return len(s) }
+
+func f() {
+	Join(",", "a", "b", "c")
+	Join(",", Len("x"))
+}
+`,
+			"signature/g.go": `package p
+
+type MyType int
+
+type T struct{}
+
+func (t T) Method(x int) string { return "" }
+
+func g() {
+	_ = MyType(1)
+	_ = Join(",", []string{"a"}...)
+	f := T{}.Method
+	_ = f(1)
+	_ = T.Method(T{}, 2)
+	_ = []byte("x")
+}
+`,
+			"signature/h.go": `package p
+
+import "context"
+
+type S struct {
+	cb func(ctx context.Context, n int) error
+}
+
+func mk() func(int, string) bool { return nil }
+
+func h(s S) {
+	var cb func(ctx context.Context, n int) error
+	_ = cb(nil, 1)
+	_ = s.cb(nil, 2)
+	m := map[string]func(x int){}
+	m["k"](3)
+	_ = mk()(4, "")
+}
+`,
 
 			"issue/223.go": `package main
 
@@ -260,6 +860,252 @@ func main() {
 	fmt.Println("hahah")
 	defer fmt.
 }`,
+
+			"completion/complit/a.go": `package complit
+
+import "github.com/saibing/bingo/langserver/test/pkg/completion/complit/loc"
+
+type T struct {
+	A, B int
+	p    *T
+}
+
+var _ = loc.Location{URI: "a", }
+var _ = loc.Location{Range: loc.Range{}}
+var _ = &T{}
+var _ = []*T{{A: 1, }}
+var _ = &T{p: nil}`,
+			"completion/complit/loc/loc.go": `package loc
+
+type Position struct{ Line, Character int }
+
+type Range struct{ Start, End Position }
+
+type Location struct {
+	URI   string
+	Range Range
+	uri   string
+}`,
+
+			"completion/keywords/decl.go": `package keywords
+
+fu`,
+			"completion/keywords/else.go": `package keywords
+
+func H(x int) {
+	if x > 0 {
+	} el
+}`,
+			"completion/keywords/range.go": `package keywords
+
+func G(s []int) {
+	for i := ra {
+	}
+}`,
+			"completion/keywords/stmt.go": `package keywords
+
+func F() {
+	
+}`,
+			"completion/keywords/switch.go": `package keywords
+
+func S(x int, c chan int) {
+	switch x {
+	case 1:
+		
+	}
+	select {
+	
+	}
+}`,
+
+			"completion/rank/a.go": `package rank
+
+var valueB int
+
+func F(valueC int) {
+	valu
+	vB
+}`,
+			"completion/rank/b.go": `package rank; var valueA int`,
+			"completion/stubs/a.go": `package stubs
+
+import "io"
+
+type Server struct{}
+
+func (s *Server) Read(p []byte) (n int, err error) { return 0, nil }
+
+type Closer interface{ Close() error }
+
+var _ Closer = &Server{}
+
+var _ io.ReadWriter
+
+func (s *Server) 
+`,
+			"completion/ctxt/addr.go": `package ctxt
+
+type Point struct{ X, Y int }
+
+type Points []Point
+
+func Pt() Point { return Point{} }
+
+func F() {
+	p := &P
+}`,
+			"completion/ctxt/make.go": `package ctxt
+
+func G() {
+	m := make(P
+}`,
+			"quickfix/a.go": `package quickfix
+
+import (
+	"fmt"
+	"os"
+)
+
+func A() { fmt.Println() }`,
+			"quickfix/b.go": `package quickfix
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func B() { fmt.Println(strings.ToUpper("b")) }`,
+			"quickfix/c.go": `package quickfix
+
+import "strings"
+
+var C = 1
+`,
+			"quickfix/d.go": `package quickfix
+
+import (
+	"bytes"
+)
+
+var D = 1
+`,
+			"quickfix/vars.go": `package quickfix
+
+func pair() (int, int) { return 1, 2 }
+
+func Vars(ch chan int) {
+	var a, b = pair()
+	c := 1
+	d, e := pair()
+	var f = <-ch
+	for i, v := range []int{} {
+		_ = i
+	}
+	for k := range []int{} {
+	}
+	println(b, e)
+}`,
+			"lifecycle/a.go": `package lifecycle
+
+func A() {}`,
+			"lifecycle/gone.go": `package lifecycle
+
+var Gone int = "gone"`,
+			"lifecycle/tagged.go": `package lifecycle
+
+var Tagged int = "tagged"`,
+			"configuration/a.go": `package configuration
+
+var A int = Tagged`,
+			"configuration/tagged.go": `// +build special
+
+package configuration
+
+const Tagged = 1`,
+			"watched/a.go": `package watched
+
+func A() int {
+	return B() + C()
+}`,
+			"watched/b.go": `package watched
+
+func B() int { return 1 }`,
+			"vet/a.go": `package vet
+
+import "fmt"
+
+func F() {
+	fmt.Printf("%d\n", "x")
+	return
+	fmt.Println("unreachable")
+}`,
+			"completion/deep/a.go": `package deep
+
+import "bytes"
+
+type T struct{ Name string; n int }
+
+func F(buf bytes.Buffer) string {
+	return bu
+}
+
+func G(t T) string {
+	return na
+}`,
+			"completion/expected/a.go": `package expected
+
+import "time"
+
+type T struct{ D time.Duration }
+
+func F(dx int, dy time.Duration) {
+	var _ time.Duration = Se
+	_ = T{D: d}
+	time.Sleep(d)
+}`,
+			"completion/imports/a.go": `package imports
+
+import (
+	"enc/js"
+	"stri"
+	"github.com/saibing/bingo/langserver/test/pkg/completion/imports/d"
+)`,
+			"completion/imports/doc/doc.go": `// Package doc is documented.
+package doc`,
+			"completion/resolve/a.go": `package resolve
+
+// Sum returns the sum of a and b.
+func Sum(a, b int) int { return a + b }
+
+func _() {
+	Su
+}`,
+
+			"unimported/a.go": `package unimported
+
+func F() {
+	_ = strconv.Itoa(1)
+}`,
+			"unimported/b.go": `package unimported
+
+import "fmt"
+
+func G() {
+	fmt.Println(errors.New("x"))
+}`,
+			"unimported/c.go": `package unimported
+
+import (
+	"fmt"
+	"strings"
+)
+
+func H() {
+	fmt.Println(strings.ToUpper(strconv.Itoa(1)))
+}`,
+			"unimported/errors/errors.go": `package errors; func New(text string) error { return nil }`,
 		},
 	},
 }
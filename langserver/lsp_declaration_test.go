@@ -0,0 +1,106 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var declarationContext = newTestContext(cache.None)
+
+func TestDeclaration(t *testing.T) {
+	t.Parallel()
+
+	declarationContext.setup(t)
+
+	test := func(t *testing.T, input string, output string) {
+		testDeclaration(t, &definitionTestCase{input: input, output: output})
+	}
+
+	t.Run("interface method declaration", func(t *testing.T) {
+		test(t, "declaration/a.go:1:82", "declaration/a.go:1:47-1:50")
+		test(t, "declaration/a.go:1:167", "declaration/a.go:1:47-1:50")
+		test(t, "declaration/a.go:1:121", "goroot/src/io/io.go")
+		test(t, "declaration/a.go:1:192", "goroot/src/io/io.go")
+	})
+
+	t.Run("same as definition", func(t *testing.T) {
+		test(t, "declaration/a.go:1:47", "declaration/a.go:1:47-1:50")
+		test(t, "declaration/a.go:1:101", "declaration/a.go:1:101-1:104")
+		test(t, "declaration/a.go:1:176", "declaration/a.go:1:101-1:104")
+		test(t, "declaration/a.go:1:231", "declaration/a.go:1:220-1:221")
+		test(t, "declaration/a.go:1:207", "goroot/src/io/io.go")
+	})
+}
+
+func testDeclaration(tb testing.TB, c *definitionTestCase) {
+	tbRun(tb, fmt.Sprintf("declaration-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		dir, err := filepath.Abs(declarationContext.root())
+		if err != nil {
+			log.Fatal("testDeclaration", err)
+		}
+		doDeclarationTest(t, declarationContext.ctx, declarationContext.conn, util.PathToURI(dir), c.input, c.output)
+	})
+}
+
+func doDeclarationTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaration, err := callDeclaration(ctx, c, uriJoin(rootURI, file), line, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declaration != "" {
+		declarations := strings.Split(declaration, ", ")
+		for i, d := range declarations {
+			declarations[i] = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(d)))
+		}
+		declaration = strings.Join(declarations, ", ")
+	}
+	if want != "" && !strings.Contains(path.Base(want), ":") {
+		// our want is just a path, so we only check that matches.
+		dir := path.Dir(declaration)
+		base := strings.Split(path.Base(declaration), ":")[0]
+		declaration = path.Join(dir, base)
+	}
+
+	if strings.HasPrefix(want, goroot) {
+		want = makePath(runtime.GOROOT(), want[len(goroot):])
+	} else if want != "" {
+		want = makePath(declarationContext.root(), want)
+	}
+	if declaration != want {
+		t.Errorf("\n%s\ngot %q, \nwant %q", pos, declaration, want)
+	}
+}
+
+func callDeclaration(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) (string, error) {
+	var res locations
+	err := c.Call(ctx, "textDocument/declaration", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+	var str string
+	for i, loc := range res {
+		if i != 0 {
+			str += ", "
+		}
+		str += fmt.Sprintf("%s:%d:%d-%d:%d", loc.URI, loc.Range.Start.Line+1, loc.Range.Start.Character+1, loc.Range.End.Line+1, loc.Range.End.Character+1)
+	}
+	return str, nil
+}
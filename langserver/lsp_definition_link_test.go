@@ -0,0 +1,92 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var definitionLinkContext = newTestContextWith(cache.Ondemand, nil, &protocol.ClientCapabilities{
+	TextDocument: protocol.TextDocumentClientCapabilities{
+		Definition:     protocol.LinkClientCapabilities{LinkSupport: true},
+		TypeDefinition: protocol.LinkClientCapabilities{LinkSupport: true},
+	},
+})
+
+func TestDefinitionLink(t *testing.T) {
+	t.Parallel()
+
+	definitionLinkContext.setup(t)
+
+	test := func(t *testing.T, method, input, output string) {
+		testDefinitionLink(t, method, &definitionTestCase{input: input, output: output})
+	}
+
+	t.Run("definition link", func(t *testing.T) {
+		test(t, "textDocument/definition", "basic/a.go:1:23", "1:23-1:24 -> basic/a.go:1:12-1:28 1:17-1:18")
+		test(t, "textDocument/definition", "basic/b.go:1:23", "1:23-1:24 -> basic/a.go:1:12-1:28 1:17-1:18")
+		test(t, "textDocument/definition", "embedded/a.go:1:138", "1:138-1:144 -> embedded/a.go:1:52-1:61 1:55-1:61")
+		test(t, "textDocument/definition", "goroot/a.go:1:20", "1:19-1:24 -> goroot/src/fmt/doc.go:1:1-1:1 1:1-1:1")
+	})
+
+	t.Run("type definition link", func(t *testing.T) {
+		test(t, "textDocument/typeDefinition", "lookup/b/b.go:1:115", "1:115-1:116 -> lookup/a/a.go:1:12-1:22 1:17-1:18")
+	})
+}
+
+func testDefinitionLink(tb testing.TB, method string, c *definitionTestCase) {
+	tbRun(tb, fmt.Sprintf("%s-%s", method, strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		dir, err := filepath.Abs(definitionLinkContext.root())
+		if err != nil {
+			log.Fatal("testDefinitionLink", err)
+		}
+		doDefinitionLinkTest(t, definitionLinkContext.ctx, definitionLinkContext.conn, util.PathToURI(dir), method, c.input, c.output)
+	})
+}
+
+func doDefinitionLinkTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, method, pos, want string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []protocol.LocationLink
+	err = c.Call(ctx, method, lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &links)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, link := range links {
+		target := filepath.ToSlash(util.UriToRealPath(link.TargetURI))
+		if strings.HasPrefix(target, makePath(runtime.GOROOT())) {
+			target = goroot + strings.TrimPrefix(target, makePath(runtime.GOROOT()))
+		} else {
+			target = strings.TrimPrefix(target, makePath(definitionLinkContext.root())+"/")
+		}
+		got = append(got, fmt.Sprintf("%s -> %s:%s %s", formatLinkRange(link.OriginSelectionRange), target, formatLinkRange(&link.TargetRange), formatLinkRange(&link.TargetSelectionRange)))
+	}
+	if s := strings.Join(got, ", "); s != want {
+		t.Errorf("\n%s\ngot  %q\nwant %q", pos, s, want)
+	}
+}
+
+func formatLinkRange(r *lsp.Range) string {
+	if r == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line+1, r.Start.Character+1, r.End.Line+1, r.End.Character+1)
+}
@@ -3,6 +3,7 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"path"
 	"path/filepath"
@@ -35,8 +36,89 @@ func TestDefinition(t *testing.T) {
 		test(t, "basic/b.go:1:23", "basic/a.go:1:17-1:18")
 	})
 
+	t.Run("assembly definition", func(t *testing.T) {
+		// The middle dot of the TEXT directive is a single UTF-16 code unit.
+		test(t, "asm/a.go:1:70", "asm/a.go:1:19-1:22, asm/add.s:3:7-3:10")
+		test(t, "asm/a.go:1:89", "asm/a.go:1:43-1:46")
+	})
+
+	t.Run("empty definition", func(t *testing.T) {
+		test(t, "builtin/b.go:1:16", "")
+		test(t, "basic/a.go:1:20", "")
+		test(t, "basic/a.go:1:100", "")
+		test(t, "basic/a.go:2:1", "")
+	})
+
 	t.Run("builtin definition", func(t *testing.T) {
 		test(t, "builtin/a.go:1:26", "goroot/src/builtin/builtin.go:257:6-257:13")
+		test(t, "builtin/b.go:1:20", "goroot/src/builtin/builtin.go")
+		test(t, "builtin/b.go:1:39", "goroot/src/builtin/builtin.go")
+		test(t, "builtin/b.go:1:51", "goroot/src/builtin/builtin.go")
+		test(t, "builtin/b.go:1:59", "goroot/src/builtin/builtin.go")
+	})
+
+	t.Run("cgo definition", func(t *testing.T) {
+		test(t, "cgo/a.go:8:9", "cgo/a.go:6:6-6:9")
+		test(t, "cgo/a.go:6:39", "")
+	})
+
+	t.Run("composite literal key definition", func(t *testing.T) {
+		test(t, "complit/a.go:1:67", "complit/a.go:1:28-1:29")
+		test(t, "complit/a.go:1:73", "complit/a.go:1:35-1:36")
+		test(t, "complit/a.go:1:118", "complit/a.go:1:86-1:87")
+	})
+
+	t.Run("dot import definition", func(t *testing.T) {
+		test(t, "dotimport/a.go:1:106", "goroot/src/math/sqrt.go")
+		test(t, "dotimport/a.go:1:123", "dotimport/d/d.go:1:17-1:18")
+	})
+
+	t.Run("embedded field definition", func(t *testing.T) {
+		test(t, "embedded/a.go:1:138", "embedded/a.go:1:55-1:61")
+		test(t, "embedded/a.go:1:156", "goroot/src/io/io.go")
+		test(t, "embedded/a.go:1:181", "embedded/a.go:1:88-1:94")
+		test(t, "embedded/a.go:1:201", "embedded/a.go:1:88-1:94")
+		test(t, "embedded/a.go:1:219", "goroot/src/bytes/buffer.go")
+	})
+
+	t.Run("label definition", func(t *testing.T) {
+		test(t, "labels/a.go:1:23", "labels/a.go:1:23-1:28")
+		test(t, "labels/a.go:1:62", "labels/a.go:1:23-1:28")
+		test(t, "labels/a.go:1:113", "labels/a.go:1:73-1:78")
+		test(t, "labels/a.go:1:129", "labels/a.go:1:134-1:137")
+	})
+
+	t.Run("xtest definition", func(t *testing.T) {
+		test(t, "xtest/x_test.go:1:88", "xtest/a.go:1:16-1:17")
+		test(t, "xtest/y_test.go:1:39", "xtest/x_test.go:1:82-1:83")
+		test(t, "xtest/b_test.go:1:34", "xtest/a_test.go:1:16-1:17")
+		test(t, "xtest/a_test.go:1:20", "xtest/a.go:1:16-1:17")
+	})
+
+	t.Run("line directive definition", func(t *testing.T) {
+		test(t, "linedirective/a.go:6:9", "linedirective/a.go:4:6-4:7")
+	})
+
+	t.Run("type switch definition", func(t *testing.T) {
+		test(t, "typeswitch/a.go:1:101", "typeswitch/a.go:1:101-1:102")
+		test(t, "typeswitch/a.go:1:128", "typeswitch/a.go:1:101-1:102")
+		test(t, "typeswitch/a.go:1:130", "typeswitch/a.go:1:70-1:73")
+		test(t, "typeswitch/a.go:1:146", "typeswitch/a.go:1:101-1:102")
+		test(t, "typeswitch/a.go:1:148", "typeswitch/a.go:1:30-1:33")
+		test(t, "typeswitch/a.go:1:184", "typeswitch/a.go:1:160-1:161")
+		test(t, "typeswitch/a.go:1:186", "typeswitch/a.go:1:70-1:73")
+	})
+
+	t.Run("shadowed variable definition", func(t *testing.T) {
+		test(t, "shadow/a.go:5:11", "shadow/a.go:5:11-5:14")
+		test(t, "shadow/a.go:6:5", "shadow/a.go:6:5-6:8")
+		test(t, "shadow/a.go:6:17", "shadow/a.go:6:5-6:8")
+		test(t, "shadow/a.go:7:10", "shadow/a.go:6:5-6:8")
+		test(t, "shadow/a.go:9:18", "shadow/a.go:9:6-9:9")
+		test(t, "shadow/a.go:9:30", "shadow/a.go:9:6-9:9")
+		test(t, "shadow/a.go:10:7", "shadow/a.go:9:6-9:9")
+		test(t, "shadow/a.go:14:7", "shadow/a.go:13:3-13:6")
+		test(t, "shadow/a.go:16:9", "shadow/a.go:5:11-5:14")
 	})
 
 	t.Run("subdirectory definition", func(t *testing.T) {
@@ -56,6 +138,18 @@ func TestDefinition(t *testing.T) {
 		test(t, "goroot/a.go:1:40", "goroot/src/fmt/print.go:274:6-274:13")
 	})
 
+	t.Run("import path definition", func(t *testing.T) {
+		test(t, "goroot/a.go:1:20", "goroot/src/fmt/doc.go:1:1-1:1")
+		test(t, "goproject/b/b.go:1:30", "goproject/a/a.go:1:1-1:1")
+		test(t, "gomodule/a.go:1:25", "gomodule/d.go")
+	})
+
+	t.Run("package name definition", func(t *testing.T) {
+		test(t, "pkgname/a.go:1:19", "pkgname/a.go:1:19-1:26, goroot/src/fmt/doc.go:1:1-1:1")
+		test(t, "pkgname/a.go:1:36", "pkgname/a.go:1:19-1:26, goroot/src/fmt/doc.go:1:1-1:1")
+		test(t, "pkgname/b.go:1:19", "pkgname/b.go:1:19-1:26, goroot/src/fmt/doc.go:1:1-1:1")
+	})
+
 	t.Run("go project", func(t *testing.T) {
 		test(t, "goproject/a/a.go:1:17", "goproject/a/a.go:1:17-1:18")
 		test(t, "goproject/b/b.go:1:89", "goproject/a/a.go:1:17-1:18")
@@ -68,6 +162,32 @@ func TestDefinition(t *testing.T) {
 		test(t, "gomodule/c.go:1:68", "gomodule/dep2/d2.go:1:32-1:34")
 	})
 
+	t.Run("open go module file", func(t *testing.T) {
+		// Files of the module cache are opened by the URIs the definitions
+		// into them return.
+		moduleURI := util.PathToURI(filepath.ToSlash(gomoduleDir))
+		content, err := ioutil.ReadFile(filepath.Join(gomoduleDir, "dep1", "d1.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(moduleURI, "dep1/d1.go")
+		if err := definitionContext.conn.Notify(definitionContext.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: string(content)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := definitionContext.conn.Notify(definitionContext.ctx, "textDocument/didClose", lsp.DidCloseTextDocumentParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}()
+
+		doDefinitionTest(t, definitionContext.ctx, definitionContext.conn, moduleURI, "dep1/d1.go:1:58", "gomodule/dep1/d1.go:1:58-1:60", "")
+		doDefinitionTest(t, definitionContext.ctx, definitionContext.conn, moduleURI, "dep1/d1.go:1:25", "gomodule/dep2/d2.go", "")
+	})
+
 	t.Run("type definition lookup", func(t *testing.T) {
 		test(t, "lookup/b/b.go:1:115", "lookup/b/b.go:1:95-1:96")
 	})
@@ -78,6 +198,14 @@ func TestDefinition(t *testing.T) {
 		test(t, "typealias/b.go:1:20", "typealias/b.go:1:17-1:18")
 		test(t, "typealias/b.go:1:21", "typealias/a.go:1:17-1:18")
 	})
+
+	t.Run("utf16 definition", func(t *testing.T) {
+		test(t, "utf16/a.go:8:18", "utf16/a.go:6:16-6:17")
+		test(t, "utf16/a.go:8:20", "utf16/a.go:3:18-3:19")
+		test(t, "utf16/a.go:6:20", "utf16/a.go:3:6-3:9")
+		test(t, "utf16/a.go:6:22", "utf16/a.go:3:6-3:9")
+		test(t, "utf16/a.go:6:24", "utf16/a.go:3:18-3:19")
+	})
 }
 
 type definitionTestCase struct {
@@ -105,10 +233,15 @@ func doDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 		t.Fatal(err)
 	}
 	if definition != "" {
-		definition = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(definition)))
-		if trimPrefix != "" {
-			definition = strings.TrimPrefix(definition, util.UriToPath(util.PathToURI(trimPrefix)))
+		definitions := strings.Split(definition, ", ")
+		for i, d := range definitions {
+			d = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(d)))
+			if trimPrefix != "" {
+				d = strings.TrimPrefix(d, util.UriToPath(util.PathToURI(trimPrefix)))
+			}
+			definitions[i] = d
 		}
+		definition = strings.Join(definitions, ", ")
 	}
 	if want != "" && !strings.Contains(path.Base(want), ":") {
 		// our want is just a path, so we only check that matches. This is
@@ -119,13 +252,17 @@ func doDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 		definition = path.Join(dir, base)
 	}
 
-	if strings.HasPrefix(want, goroot) {
-		want = makePath(runtime.GOROOT(), want[len(goroot):])
-	} else if strings.HasPrefix(want, gomodule) {
-		want = makePath(gomoduleDir, want[len(gomodule):])
-	} else if want != "" {
-		want = makePath(definitionContext.root(), want)
+	wants := strings.Split(want, ", ")
+	for i, w := range wants {
+		if strings.HasPrefix(w, goroot) {
+			wants[i] = makePath(runtime.GOROOT(), w[len(goroot):])
+		} else if strings.HasPrefix(w, gomodule) {
+			wants[i] = makePath(gomoduleDir, w[len(gomodule):])
+		} else if w != "" {
+			wants[i] = makePath(definitionContext.root(), w)
+		}
 	}
+	want = strings.Join(wants, ", ")
 
 	if definition != want {
 		t.Errorf("\n%s\ngot %q, \nwant %q", pos, definition, want)
@@ -0,0 +1,119 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var diagnosticsContext, diagnosticsClient = newDiagnosticsTestContext(cache.None)
+
+// diagnosticsRecorder is a client receiving the diagnostics published by
+// the server.
+type diagnosticsRecorder struct {
+	published chan protocol.PublishDiagnosticsParams
+}
+
+func (r *diagnosticsRecorder) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Method != "textDocument/publishDiagnostics" || req.Params == nil {
+		return
+	}
+	var params protocol.PublishDiagnosticsParams
+	if err := json.Unmarshal(*req.Params, &params); err == nil {
+		r.published <- params
+	}
+}
+
+func newDiagnosticsTestContext(style cache.CacheStyle) (*TestContext, *diagnosticsRecorder) {
+	tx := newTestContextWith(style, func(cfg *Config) {
+		cfg.DiagnosticsStyle = string(instantDiagnostics)
+		cfg.VetOnSave = false
+	}, nil)
+
+	client := &diagnosticsRecorder{published: make(chan protocol.PublishDiagnosticsParams, 100)}
+	tx.client = client
+	return tx, client
+}
+
+func TestDiagnosticsLifecycle(t *testing.T) {
+	t.Parallel()
+
+	tx := diagnosticsContext
+	tx.setup(t)
+
+	rootURI := util.PathToURI(filepath.ToSlash(tx.root()))
+	open := func(t *testing.T, file string) lsp.DocumentURI {
+		t.Helper()
+		text, err := ioutil.ReadFile(filepath.Join(tx.root(), file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(rootURI, file)
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: string(text)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return uri
+	}
+
+	t.Run("deleted file", func(t *testing.T) {
+		uri := open(t, "lifecycle/gone.go")
+		waitDiagnostics(t, uri, false)
+
+		if err := os.Remove(filepath.Join(tx.root(), "lifecycle/gone.go")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.conn.Notify(tx.ctx, "workspace/didChangeWatchedFiles", protocol.DidChangeWatchedFilesParams{
+			Changes: []protocol.FileEvent{{URI: uri, Type: protocol.Deleted}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		waitDiagnostics(t, uri, true)
+	})
+
+	t.Run("excluded file", func(t *testing.T) {
+		uri := open(t, "lifecycle/tagged.go")
+		waitDiagnostics(t, uri, false)
+
+		text := "// +build ignore\n\npackage lifecycle\n\nvar Tagged int = \"tagged\"\n"
+		if err := ioutil.WriteFile(filepath.Join(tx.root(), "lifecycle/tagged.go"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		waitDiagnostics(t, uri, true)
+	})
+}
+
+// waitDiagnostics waits for diagnostics to be published for the document
+// uri, an empty set of them if empty is set.
+func waitDiagnostics(t *testing.T, uri lsp.DocumentURI, empty bool) {
+	t.Helper()
+	want := makePath(util.UriToRealPath(uri))
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case params := <-diagnosticsClient.published:
+			if makePath(util.UriToRealPath(params.URI)) == want && (len(params.Diagnostics) == 0) == empty {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no diagnostics published for %s (empty %t)", uri, empty)
+		}
+	}
+}
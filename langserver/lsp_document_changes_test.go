@@ -0,0 +1,96 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var documentChangesContext = newTestContextWith(cache.Always, nil, &protocol.ClientCapabilities{
+	Workspace: protocol.WorkspaceClientCapabilities{
+		WorkspaceEdit: protocol.WorkspaceEditClientCapabilities{DocumentChanges: true},
+	},
+})
+
+func TestDocumentChanges(t *testing.T) {
+	t.Parallel()
+
+	tx := documentChangesContext
+	tx.setup(t)
+
+	rootURI := util.PathToURI(filepath.ToSlash(tx.root()))
+	aURI := uriJoin(rootURI, "renaming/cross/a/a.go")
+
+	text, err := ioutil.ReadFile(filepath.Join(tx.root(), "renaming/cross/a/a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rename returns the versions of the documents changed by renaming F.
+	rename := func(t *testing.T) map[string]interface{} {
+		t.Helper()
+		var edit protocol.WorkspaceEdit
+		err := tx.conn.Call(tx.ctx, "textDocument/rename", lsp.RenameParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: aURI},
+			Position:     lsp.Position{Line: 0, Character: 75},
+			NewName:      "Renamed",
+		}, &edit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edit.Changes != nil {
+			t.Errorf("got changes %v, want only document changes", edit.Changes)
+		}
+
+		root := makePath(tx.root())
+		versions := map[string]interface{}{}
+		for _, change := range edit.DocumentChanges {
+			path := strings.TrimPrefix(makePath(util.UriToRealPath(change.TextDocument.URI)), root+"/")
+			if change.TextDocument.Version == nil {
+				versions[path] = nil
+			} else {
+				versions[path] = *change.TextDocument.Version
+			}
+		}
+		return versions
+	}
+
+	t.Run("open document", func(t *testing.T) {
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: aURI, LanguageID: "go", Version: 3, Text: string(text)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: aURI}, Version: 4},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: string(text)}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]interface{}{"renaming/cross/a/a.go": 4, "renaming/cross/b/b.go": nil}
+		if got := rename(t); !reflect.DeepEqual(got, want) {
+			t.Errorf("got versions %v, want %v", got, want)
+		}
+	})
+
+	t.Run("closed document", func(t *testing.T) {
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didClose", lsp.DidCloseTextDocumentParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: aURI},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]interface{}{"renaming/cross/a/a.go": nil, "renaming/cross/b/b.go": nil}
+		if got := rename(t); !reflect.DeepEqual(got, want) {
+			t.Errorf("got versions %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var documentLinkContext = newTestContext(cache.None)
+
+func TestDocumentLink(t *testing.T) {
+	t.Parallel()
+
+	documentLinkContext.setup(t)
+
+	// links returns the links of file, resolved, as "range target", with
+	// the targets of the files relative to the root.
+	links := func(t *testing.T, file string) []string {
+		t.Helper()
+		tx := documentLinkContext
+		root := util.PathToURI(filepath.ToSlash(tx.root()))
+		params := protocol.DocumentLinkParams{TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(root, file)}}
+		var links []protocol.DocumentLink
+		if err := tx.conn.Call(tx.ctx, "textDocument/documentLink", params, &links); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, link := range links {
+			if link.Target == "" {
+				if err := tx.conn.Call(tx.ctx, "documentLink/resolve", link, &link); err != nil {
+					t.Fatal(err)
+				}
+			}
+			target := strings.TrimPrefix(link.Target, string(root)+"/")
+			got = append(got, fmt.Sprintf("%s %s", rangeString(link.Range), target))
+		}
+		return got
+	}
+
+	want := func(target string) []string {
+		return []string{
+			"3:2-3:5 " + target + "fmt",
+			"4:2-4:9 " + target + "os/exec",
+			"7:17-7:25 links/data.txt",
+			"8:19-8:27 links/data.txt",
+			"10:11-10:19 links/data.txt",
+		}
+	}
+
+	t.Run("links", func(t *testing.T) {
+		got := links(t, "links/a.go")
+		if w := want("https://pkg.go.dev/"); strings.Join(got, "\n") != strings.Join(w, "\n") {
+			t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(w, "\n"))
+		}
+	})
+
+	t.Run("target", func(t *testing.T) {
+		defer documentLinkContext.configure(t, map[string]interface{}{"documentLinkTarget": "https://godoc.example.com/pkg/"})()
+
+		got := links(t, "links/a.go")
+		if w := want("https://godoc.example.com/pkg/"); strings.Join(got, "\n") != strings.Join(w, "\n") {
+			t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(w, "\n"))
+		}
+	})
+}
@@ -13,11 +13,18 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
 var symbolContext = newTestContext(cache.None)
 
+var hierarchicalSymbolContext = newTestContextWith(cache.None, nil, &protocol.ClientCapabilities{
+	TextDocument: protocol.TextDocumentClientCapabilities{
+		DocumentSymbol: protocol.DocumentSymbolClientCapabilities{HierarchicalDocumentSymbolSupport: true},
+	},
+})
+
 func TestDocumentSymbol(t *testing.T) {
 	t.Parallel()
 
@@ -109,6 +116,15 @@ func TestDocumentSymbol(t *testing.T) {
 		})
 	})
 
+	t.Run("syntax error", func(t *testing.T) {
+		test(t, map[string][]string{
+			"syntaxerror/a.go": {
+				"syntaxerror/a.go:class:Before:3:6",
+				"syntaxerror/a.go:function:Broken:5:6",
+				"syntaxerror/a.go:function:After:9:6"},
+		})
+	})
+
 	t.Run("recv in different file", func(t *testing.T) {
 		test(t, map[string][]string{
 			"different/abc.go": {"different/abc.go:class:XYZ:2:6"},
@@ -117,6 +133,57 @@ func TestDocumentSymbol(t *testing.T) {
 	})
 }
 
+func TestHierarchicalDocumentSymbol(t *testing.T) {
+	t.Parallel()
+
+	tx := hierarchicalSymbolContext
+	tx.setup(t)
+
+	rootURI := util.PathToURI(filepath.ToSlash(tx.root()))
+	var symbols []protocol.DocumentSymbol
+	err := tx.conn.Call(tx.ctx, "textDocument/documentSymbol", lsp.DocumentSymbolParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, "outline/tree.go")},
+	}, &symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var format func(symbols []protocol.DocumentSymbol, indent string)
+	format = func(symbols []protocol.DocumentSymbol, indent string) {
+		for _, s := range symbols {
+			got = append(got, fmt.Sprintf("%s%s %s %s %s", indent, strings.ToLower(s.Kind.String()), s.Name, symbolRange(s.Range), symbolRange(s.SelectionRange)))
+			format(s.Children, indent+"  ")
+		}
+	}
+	format(symbols, "")
+
+	want := []string{
+		"class Tree 3:1-7:2 4:6-4:10",
+		"  field Left 5:2-5:19 5:2-5:6",
+		"  field Right 5:2-5:19 5:8-5:13",
+		"  field value 6:2-6:17 6:2-6:7",
+		"  method Walk 13:1-14:33 14:16-14:20",
+		"interface Walker 9:1-11:2 9:6-9:12",
+		"  method Walk 10:2-10:15 10:2-10:6",
+		"function Build 16:1-19:2 16:6-16:11",
+		"  class node 17:2-17:29 17:7-17:11",
+		"    field t 17:20-17:27 17:20-17:21",
+		"constant const 21:1-25:2 21:1-21:6",
+		"  constant Small 22:2-23:11 23:2-23:7",
+		"  constant Large 24:2-24:11 24:2-24:7",
+		"variable single 27:1-27:15 27:5-27:11",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// symbolRange returns r as "line:column-line:column", 1-based.
+func symbolRange(r lsp.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line+1, r.Start.Character+1, r.End.Line+1, r.End.Character+1)
+}
+
 type documentSymbolTestCase struct {
 	input  string
 	output []string
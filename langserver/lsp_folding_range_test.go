@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var foldingRangeContext = newTestContext(cache.None)
+
+func TestFoldingRange(t *testing.T) {
+	t.Parallel()
+
+	foldingRangeContext.setup(t)
+
+	test := func(t *testing.T, file string, want ...string) {
+		t.Helper()
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(foldingRangeContext.root())), file)
+		params := protocol.FoldingRangeParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+		var ranges []protocol.FoldingRange
+		if err := foldingRangeContext.conn.Call(foldingRangeContext.ctx, "textDocument/foldingRange", params, &ranges); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, r := range ranges {
+			s := fmt.Sprintf("%d-%d", r.StartLine, r.EndLine)
+			if r.Kind != "" {
+				s += " " + string(r.Kind)
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", file, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	t.Run("declarations", func(t *testing.T) {
+		test(t, "folding/a.go",
+			"0-1 comment",
+			"4-6 imports",
+			"9-11",
+			"16-18",
+			"21-25",
+			"22-23",
+			"28-30 comment")
+	})
+
+	t.Run("ill typed package", func(t *testing.T) {
+		test(t, "folding/illtyped/a.go", "2-3")
+	})
+}
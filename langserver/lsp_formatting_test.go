@@ -3,9 +3,11 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
@@ -32,6 +35,138 @@ func TestFormatting(t *testing.T) {
 			"0:0-1:0": "package p\n\nfunc A() { A() }\n",
 		})
 	})
+
+	t.Run("minimal edits", func(t *testing.T) {
+		edits, text := formatDocument(t, formatContext, "formatting/minimal.go")
+		for _, edit := range edits {
+			if edit.Range.Start.Line < 4 {
+				t.Errorf("edit %v of a formatted line", edit)
+			}
+		}
+		if want := "package formatting\n\nfunc A() {}\n\nvar x = 1\n"; text != want {
+			t.Errorf("got %q, want %q", text, want)
+		}
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		if edits, _ := formatDocument(t, formatContext, "formatting/broken.go"); len(edits) != 0 {
+			t.Errorf("got edits %v, want none", edits)
+		}
+	})
+
+	t.Run("range in declaration", func(t *testing.T) {
+		rng := lsp.Range{Start: lsp.Position{Line: 3, Character: 0}, End: lsp.Position{Line: 3, Character: 3}}
+		text := editDocument(t, formatContext, "formatting/decls.go", "textDocument/rangeFormatting", lsp.DocumentRangeFormattingParams{Range: rng})
+		if want := "package formatting\n\nfunc C() {\n\treturn\n}\n\nvar  y = 1\n"; text != want {
+			t.Errorf("got %q, want %q", text, want)
+		}
+	})
+
+	t.Run("on type", func(t *testing.T) {
+		tests := []struct {
+			ch   string
+			pos  lsp.Position
+			want string
+		}{
+			{"}", lsp.Position{Line: 5, Character: 1}, "package formatting\n\nfunc D() {\nif true {\n\t\tD()\n\t}\n\n}\n"},
+			{"\n", lsp.Position{Line: 6, Character: 0}, "package formatting\n\nfunc D() {\nif true {\nD()\n}\n\t\n}\n"},
+		}
+		for _, test := range tests {
+			text := editDocument(t, formatContext, "formatting/ontype.go", "textDocument/onTypeFormatting", protocol.DocumentOnTypeFormattingParams{Position: test.pos, Ch: test.ch})
+			if text != test.want {
+				t.Errorf("%q: got %q, want %q", test.ch, text, test.want)
+			}
+		}
+	})
+
+	t.Run("goimports", func(t *testing.T) {
+		defer formatContext.configure(t, map[string]interface{}{"formatTool": goimportsStyle})()
+
+		_, text := formatDocument(t, formatContext, "goimports/a.go")
+		want := `package goimports
+
+import "github.com/saibing/bingo/langserver/test/pkg/goimports/lib"
+
+var Name = lib.Name()
+`
+		if text != want {
+			t.Errorf("got %q, want %q", text, want)
+		}
+
+		if edits, _ := formatDocument(t, formatContext, "formatting/broken.go"); len(edits) != 0 {
+			t.Errorf("got edits %v of a file with syntax errors, want none", edits)
+		}
+	})
+}
+
+// formatDocument formats the file of the test context, and returns the
+// edits along with the formatted text.
+func formatDocument(t *testing.T, tx *TestContext, file string) ([]lsp.TextEdit, string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join(tx.root(), file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := callFormatting(tx.ctx, tx.conn, uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return edits, applyTextEdits(string(content), edits)
+}
+
+// editDocument sends a request of method for the edits of the file of the
+// test context, whose document is set in params, and returns the edited
+// text.
+func editDocument(t *testing.T, tx *TestContext, file, method string, params interface{}) string {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join(tx.root(), file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)}
+	switch p := params.(type) {
+	case lsp.DocumentRangeFormattingParams:
+		p.TextDocument = doc
+		params = p
+	case protocol.DocumentOnTypeFormattingParams:
+		p.TextDocument = doc
+		params = p
+	}
+	var edits []lsp.TextEdit
+	if err := tx.conn.Call(tx.ctx, method, params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	return applyTextEdits(string(content), edits)
+}
+
+// applyTextEdits applies the edits to text, which is ASCII. The ranges of
+// the edits are those of the original text.
+func applyTextEdits(text string, edits []lsp.TextEdit) string {
+	lines := strings.SplitAfter(text, "\n")
+	offset := func(p lsp.Position) int {
+		n := 0
+		for _, line := range lines[:p.Line] {
+			n += len(line)
+		}
+		return n + p.Character
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return offset(edits[i].Range.Start) < offset(edits[j].Range.Start)
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, edit := range edits {
+		if start := offset(edit.Range.Start); start > last {
+			b.WriteString(text[last:start])
+		}
+		b.WriteString(edit.NewText)
+		if end := offset(edit.Range.End); end > last {
+			last = end
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
 }
 
 type formattingTestCase struct {
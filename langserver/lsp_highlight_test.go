@@ -0,0 +1,78 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var highlightContext = newTestContext(cache.Always)
+
+func TestDocumentHighlight(t *testing.T) {
+	t.Parallel()
+
+	highlightContext.setup(t)
+
+	test := func(t *testing.T, input string, output []string) {
+		t.Helper()
+		doDocumentHighlightTest(t, highlightContext.ctx, highlightContext.conn, util.PathToURI(filepath.ToSlash(highlightContext.root())), input, output)
+	}
+
+	t.Run("variable", func(t *testing.T) {
+		want := []string{"1:64:write", "1:72:write", "1:79:write", "1:85:write", "1:95:write", "1:117:read", "1:137:read", "1:147:read"}
+		test(t, "highlight/a.go:1:64", want)
+		test(t, "highlight/a.go:1:117", want)
+	})
+
+	t.Run("field", func(t *testing.T) {
+		test(t, "highlight/a.go:1:122", []string{"1:27:write", "1:122:write", "1:132:write", "1:153:read"})
+	})
+
+	t.Run("no identifier", func(t *testing.T) {
+		test(t, "highlight/a.go:1:1", []string{})
+	})
+}
+
+func doDocumentHighlightTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos string, want []string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	highlights, err := callDocumentHighlight(ctx, c, uriJoin(rootURI, file), line, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(highlights, want) {
+		t.Errorf("got %q, want %q", highlights, want)
+	}
+}
+
+func callDocumentHighlight(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) ([]string, error) {
+	var res []protocol.DocumentHighlight
+	err := c.Call(ctx, "textDocument/documentHighlight", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := map[protocol.DocumentHighlightKind]string{
+		protocol.HighlightText:  "text",
+		protocol.HighlightRead:  "read",
+		protocol.HighlightWrite: "write",
+	}
+	str := make([]string, len(res))
+	for i, h := range res {
+		str[i] = fmt.Sprintf("%d:%d:%s", h.Range.Start.Line+1, h.Range.Start.Character+1, kinds[h.Kind])
+	}
+	return str, nil
+}
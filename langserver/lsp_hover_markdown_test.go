@@ -0,0 +1,75 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var hoverMarkdownContext = newTestContextWith(cache.Ondemand, nil, &protocol.ClientCapabilities{
+	TextDocument: protocol.TextDocumentClientCapabilities{
+		Hover: protocol.HoverClientCapabilities{ContentFormat: []protocol.MarkupKind{protocol.Markdown, protocol.PlainText}},
+	},
+})
+
+func TestHoverMarkdown(t *testing.T) {
+	t.Parallel()
+
+	hoverMarkdownContext.setup(t)
+
+	test := func(t *testing.T, input string, output string) {
+		t.Helper()
+		testHoverMarkdown(t, &hoverTestCase{input: input, output: output})
+	}
+
+	t.Run("markdown hover", func(t *testing.T) {
+		test(t, "basic/a.go:1:17", "```go\nfunc A()\n```")
+		test(t, "docs/a.go:24:5", "```go\nvar Foo string\n```\n\nFoo is the best string.")
+		test(t, "typealias/a.go:1:17", "```go\ntype A struct\n```\n\n```go\nstruct {\n    a int\n}\n```")
+	})
+}
+
+func testHoverMarkdown(tb testing.TB, c *hoverTestCase) {
+	tb.Helper()
+	tbRun(tb, fmt.Sprintf("hoverMarkdown-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		t.Helper()
+
+		dir, err := filepath.Abs(hoverMarkdownContext.root())
+		if err != nil {
+			log.Fatal("testHoverMarkdown", err)
+		}
+		doHoverMarkdownTest(t, hoverMarkdownContext.ctx, hoverMarkdownContext.conn, util.PathToURI(dir), c.input, c.output)
+	})
+}
+
+func doHoverMarkdownTest(t testing.TB, ctx context.Context, conn *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hover protocol.Hover
+	err = conn.Call(ctx, "textDocument/hover", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &hover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hover.Contents.Kind != protocol.Markdown {
+		t.Fatalf("got kind %q, want %q", hover.Contents.Kind, protocol.Markdown)
+	}
+	if hover.Contents.Value != want {
+		t.Fatalf("\ngot %q, \nwant %q", hover.Contents.Value, want)
+	}
+}
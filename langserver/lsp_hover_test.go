@@ -39,6 +39,37 @@ func TestHover(t *testing.T) {
 		test(t, "builtin/a.go:1:26", "func println(args ...Type); The println built-in function formats its arguments in an implementation-specific way and writes the result to standard error. Spaces are always added between arguments and a newline is appended. Println is useful for bootstrapping and debugging; it is not guaranteed to stay in the language. \n\n")
 	})
 
+	t.Run("builtin signature hover", func(t *testing.T) {
+		// The docs of the builtins vary with GOROOT, so only the
+		// signatures are checked.
+		test := func(t *testing.T, input string, signature string) {
+			t.Helper()
+			dir, err := filepath.Abs(hoverContext.root())
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, line, char, err := parsePos(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hover, err := callHover(hoverContext.ctx, hoverContext.conn, uriJoin(util.PathToURI(dir), file), line, char)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(hover, signature+"; ") {
+				t.Fatalf("\ngot %q, \nwant prefix %q", hover, signature+"; ")
+			}
+		}
+
+		test(t, "builtin/b.go:1:20", "func len(v Type) int")
+		test(t, "builtin/b.go:1:39", "const iota untyped int")
+		test(t, "builtin/b.go:1:51", "type error interface {\n\tError() string\n}")
+		test(t, "builtin/b.go:1:59", "var nil Type")
+		test(t, "builtin/c.go:1:20", "func make(t Type, size ...IntegerType) Type")
+		test(t, "builtin/c.go:1:44", "func new(Type) *Type")
+		test(t, "builtin/c.go:1:62", "const true untyped bool")
+	})
+
 	t.Run("detailed hover", func(t *testing.T) {
 		test(t, "detailed/a.go:1:28", "struct field F string")
 		test(t, "detailed/a.go:1:17", `type T struct; struct {
@@ -93,12 +124,13 @@ func TestHover(t *testing.T) {
 		test(t, "docs/a.go:7:9", "package p; Package p is a package with lots of great things. \n\n")
 		//"a.go:9:9": "", TODO: handle hovering on import statements (ast.BasicLit)
 		test(t, "docs/a.go:12:5", "var logit func(); logit is pkg2.X \n\n")
-		test(t, "docs/a.go:12:13", "package pkg2 (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\nHow to \n\n```\nExample Code!\n\n```\n")
+		test(t, "docs/a.go:12:13", "package pkg2 (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\n")
 		test(t, "docs/a.go:12:18", "func X(); X does the unknown. \n\n")
 		test(t, "docs/a.go:15:6", "type T struct; T is a struct. \n\n; struct {\n    F string\n    H Header\n}")
 		test(t, "docs/a.go:17:2", "struct field F string; F is a string field. \n\n")
 		test(t, "docs/a.go:20:2", "struct field H github.com/saibing/dep/pkg2.Header; H is a header. \n\n")
-		test(t, "docs/a.go:20:4", "package pkg2 (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\nHow to \n\n```\nExample Code!\n\n```\n")
+		test(t, "docs/a.go:20:4", "package pkg2 (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\n")
+		test(t, "pkgname/c.go:1:60", "package d (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\n")
 		test(t, "docs/a.go:24:5", "var Foo string; Foo is the best string. \n\n")
 		test(t, "docs/a.go:31:2", "var I2 int; I2 is an int \n\n")
 
@@ -106,6 +138,58 @@ func TestHover(t *testing.T) {
 		test(t, "docs/q.go:5:2", "struct field X int; X is documented. \n\nX has comments. \n\n")
 	})
 
+	t.Run("constant hover", func(t *testing.T) {
+		test(t, "constant/a.go:1:18", "const size = 4096")
+		test(t, "constant/a.go:1:40", "const pi = 3.25")
+		test(t, "constant/a.go:1:57", "const r = 'a'")
+		test(t, "constant/a.go:1:72", "const s = \"hello\"")
+		test(t, "constant/a.go:1:91", "const f float64 = 1.5")
+		test(t, "constant/a.go:1:134", "const Sunday Weekday = 0")
+		test(t, "constant/a.go:1:157", "const Monday Weekday = 1")
+		test(t, "constant/a.go:1:292", "const Monday Weekday = 1")
+		test(t, "constant/a.go:1:173", "const long = \"xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx…\"")
+	})
+
+	t.Run("type details hover", func(t *testing.T) {
+		test(t, "typedetails/a.go:1:30", "type T struct; struct {\n    A int `json:\"a\"`\n    b string\n}\n\nfunc (*T).Another() int\nfunc (T).Exported()\nfunc (*T).unexported()")
+		test(t, "typedetails/a.go:1:167", "type I interface; interface {\n    M()\n    Read(p []byte) (n int, err error)\n}")
+		test(t, "typedetails/a.go:1:204", "type Big struct; struct {\n    F0 int\n    F1 int\n    F2 int\n    F3 int\n    F4 int\n    F5 int\n    F6 int\n    F7 int\n    F8 int\n    F9 int\n    F10 int\n    F11 int\n    F12 int\n    F13 int\n    F14 int\n    F15 int\n    F16 int\n    F17 int\n    F18 int\n    F19 int\n    F20 int\n    F21 int\n    F22 int\n    F23 int\n    F24 int\n    F25 int\n    F26 int\n    F27 int\n    F28 int\n// … 12 more")
+	})
+
+	t.Run("hover range", func(t *testing.T) {
+		test := func(t *testing.T, input string, output string, rng string) {
+			t.Helper()
+			test(t, input, output)
+			dir, err := filepath.Abs(hoverContext.root())
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, line, char, err := parsePos(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := callHoverRange(hoverContext.ctx, hoverContext.conn, uriJoin(util.PathToURI(dir), file), line, char)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%d:%d-%d:%d", r.Start.Line+1, r.Start.Character+1, r.End.Line+1, r.End.Character+1); got != rng {
+				t.Fatalf("\ngot range %s, \nwant %s", got, rng)
+			}
+		}
+
+		test(t, "hoverrange/a.go:5:5", "var 日本語変数 T", "5:5-5:10")
+		test(t, "hoverrange/a.go:7:9", "var 日本語変数 T", "7:9-7:14")
+		test(t, "hoverrange/a.go:7:15", "struct field F int", "7:9-7:16")
+		test(t, "hoverrange/a.go:11:8", "var 𝒜2 string", "11:8-11:11")
+		test(t, "hoverrange/a.go:11:20", "var 𝒜 string", "11:20-11:22")
+	})
+
+	t.Run("deprecated hover", func(t *testing.T) {
+		test(t, "deprecated/a.go:6:6", "func Foo(); **Deprecated:** Use Bar instead.; Foo does foo. \n\nDeprecated: Use Bar instead. \n\n")
+		test(t, "deprecated/a.go:12:6", "type T struct; **Deprecated:** T is replaced by Bar.; T is a type. \n\nDeprecated: T is replaced by Bar. \n\n")
+		test(t, "deprecated/a.go:15:6", "func Bar(); Bar is not deprecated. Deprecated: in the middle of a paragraph does not count. \n\n")
+	})
+
 	t.Run("hover issue", func(t *testing.T) {
 		test(t, "issue/223.go:13:17", "func (*Hello).Bye() int")
 		test(t, "issue/261.go:11:15", "var t T")
@@ -113,14 +197,33 @@ func TestHover(t *testing.T) {
 
 	t.Run("go1.9 type alias", func(t *testing.T) {
 		test(t, "typealias/a.go:1:17", "type A struct; struct {\n    a int\n}")
-		test(t, "typealias/b.go:1:17", "type B struct; struct {\n    a int\n}")
-		test(t, "typealias/b.go:1:20", "type B struct; struct {\n    a int\n}")
+		test(t, "typealias/b.go:1:17", "type B = A; struct {\n    a int\n}")
+		test(t, "typealias/b.go:1:20", "type B = A; struct {\n    a int\n}")
 		test(t, "typealias/b.go:1:21", "type A struct; struct {\n    a int\n}")
 	})
 
+	t.Run("underlying type hover", func(t *testing.T) {
+		test(t, "typeformat/a.go:5:6", "type ID int64")
+		test(t, "typeformat/a.go:7:6", "type Handler func(ID) error")
+		test(t, "typeformat/a.go:9:6", "type Set map[string]struct{}")
+		test(t, "typeformat/a.go:11:6", "type Reader = io.Reader; interface {\n    Read(p []byte) (n int, err error)\n}")
+		test(t, "typeformat/a.go:13:6", "type Local = ID")
+		test(t, "typeformat/a.go:15:6", "type Point = struct{...}; struct {\n    X int\n    Y int\n}")
+		test(t, "typeformat/a.go:17:6", "type Pair struct; struct {\n    A ID\n    B ID\n}")
+	})
+
 	t.Run("unexpected paths hover", func(t *testing.T) {
 		test(t, "unexpected_paths/a.go:1:17", "func A()")
 	})
+
+	t.Run("struct info hover", func(t *testing.T) {
+		defer hoverContext.configure(t, map[string]interface{}{"hoverShowStructInfo": true})()
+
+		test(t, "structinfo/a.go:1:17", "type S struct; struct {\n    A bool\n    B int64\n    C int32\n}; // size 24, align 8\n// A: offset 0, size 1, padding 7\n// B: offset 8, size 8\n// C: offset 16, size 4, padding 4")
+		test(t, "structinfo/a.go:1:28", "struct field A bool; // offset 0, size 1, padding 7")
+		test(t, "structinfo/a.go:1:105", "struct field B int64; // offset 8, size 8")
+		test(t, "structinfo/a.go:1:118", "struct field C int32; // offset 16, size 4, padding 4")
+	})
 }
 
 type hoverTestCase struct {
@@ -177,3 +280,15 @@ func callHover(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line,
 	}
 	return str, nil
 }
+
+func callHoverRange(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) (lsp.Range, error) {
+	var res struct {
+		Contents markedStrings `json:"contents"`
+		Range    lsp.Range     `json:"range"`
+	}
+	err := c.Call(ctx, "textDocument/hover", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &res)
+	return res.Range, err
+}
@@ -0,0 +1,99 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+const hybridModule = "github.com/saibing/bingo/langserver/test/hybrid"
+
+var hybridContext = newTestContext(cache.Ondemand)
+
+var hybridTestdata = []packagestest.Module{
+	{
+		Name: hybridModule,
+		Files: map[string]interface{}{
+			"a.go": `package hybrid; func M() {}; var _ = M`,
+		},
+	},
+}
+
+// hybridGopathFiles are a GOPATH-style project inside the module, rooted
+// at the gopath directory of the workspace.
+var hybridGopathFiles = map[string]string{
+	"src/example.com/old/old.go":       `package old; import "example.com/old/util"; var _ = util.U`,
+	"src/example.com/old/util/util.go": `package util; func U() {}`,
+}
+
+func (tx *TestContext) setupHybrid(t *testing.T) {
+	t.Helper()
+	tx.exported = packagestest.Export(t, packagestest.Modules, hybridTestdata)
+
+	gopathDir := filepath.Join(tx.root(), "gopath")
+	for name, content := range hybridGopathFiles {
+		filename := filepath.Join(gopathDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx.initServer(t)
+}
+
+func TestHybrid(t *testing.T) {
+	t.Parallel()
+
+	hybridContext.setupHybrid(t)
+	rootURI := util.PathToURI(filepath.ToSlash(hybridContext.root()))
+
+	oldDir := "gopath/src/example.com/old/"
+	t.Run("module definition", func(t *testing.T) {
+		testHybridDefinition(t, rootURI, "a.go:1:38", "a.go:1:22-1:23")
+	})
+
+	t.Run("gopath definition", func(t *testing.T) {
+		testHybridDefinition(t, rootURI, oldDir+"old.go:1:58", oldDir+"util/util.go:1:20-1:21")
+		testHybridDefinition(t, rootURI, oldDir+"old.go:1:22", oldDir+"util/util.go")
+	})
+
+	t.Run("module hover", func(t *testing.T) {
+		doHoverTest(t, hybridContext.ctx, hybridContext.conn, rootURI, "a.go:1:38", "func M()")
+	})
+
+	t.Run("gopath hover", func(t *testing.T) {
+		doHoverTest(t, hybridContext.ctx, hybridContext.conn, rootURI, oldDir+"old.go:1:58", "func U()")
+	})
+}
+
+func testHybridDefinition(t *testing.T, rootURI lsp.DocumentURI, pos, want string) {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition, err := callDefinition(hybridContext.ctx, hybridContext.conn, uriJoin(rootURI, file), line, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(definition)))
+	if !strings.Contains(path.Base(want), ":") {
+		definition = path.Join(path.Dir(definition), strings.Split(path.Base(definition), ":")[0])
+	}
+	want = makePath(hybridContext.root(), want)
+	if definition != want {
+		t.Errorf("\n%s\ngot %q, \nwant %q", pos, definition, want)
+	}
+}
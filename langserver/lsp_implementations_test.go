@@ -3,6 +3,7 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"go/types"
 	"log"
 	"path/filepath"
 	"reflect"
@@ -15,6 +16,7 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
@@ -57,6 +59,64 @@ func TestImplementations(t *testing.T) {
 		test(t, "implementations/t1p.go:1:44", []string{"implementations/i1.go:1:32:from:method"})
 	})
 
+	t.Run("embedded interfaces", func(t *testing.T) {
+		test(t, "implementations/embedded/e1.go:1:39", []string{"implementations/embedded/t3.go:1:47:to:method"})
+		test(t, "implementations/embedded/e3.go:1:24", []string{
+			"implementations/embedded/e1.go:1:24:from",
+			"implementations/embedded/t3.go:1:24:to",
+		})
+		test(t, "implementations/embedded/e3.go:1:43", []string{"implementations/embedded/t3.go:1:66:to:method"})
+		test(t, "implementations/embedded/t3.go:1:66", []string{"implementations/embedded/e3.go:1:43:from:method"})
+	})
+
+	t.Run("unexported methods", func(t *testing.T) {
+		test(t, "implementations/u/u.go:1:17", []string{"implementations/u/u.go:1:43:to"})
+		test(t, "implementations/u/u.go:1:31", []string{"implementations/u/u.go:1:64:to:method"})
+		test(t, "implementations/u/u.go:1:43", []string{"implementations/u/u.go:1:17:from"})
+		test(t, "implementations/u2/u2.go:1:18", []string{})
+		test(t, "implementations/u2/u2.go:1:39", []string{})
+	})
+
+}
+
+func BenchmarkImplementations(b *testing.B) {
+	tx := newTestContext(cache.Always)
+	tx.setup(b)
+	defer tx.tearDown()
+	h := tx.handler
+
+	file, line, char, err := parsePos("implementations/i1.go:1:17")
+	if err != nil {
+		b.Fatal(err)
+	}
+	uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)
+	pkg, pos, err := h.typeCheck(tx.ctx, uri, lsp.Position{Line: line, Character: char})
+	if err != nil {
+		b.Fatal(err)
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pathNodes, action := findInterestingNode(pkg, pathNodes)
+
+	bench := func(b *testing.B, candidates func(T types.Type) []*types.Named) {
+		for i := 0; i < b.N; i++ {
+			if _, err := implements(pkg, pathNodes, action, candidates); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("naive", func(b *testing.B) {
+		bench(b, func(types.Type) []*types.Named {
+			return allNamedTypes(h.project)
+		})
+	})
+
+	b.Run("indexed", func(b *testing.B) {
+		bench(b, h.project.Cache().ImplementationCandidates)
+	})
 }
 
 type implementationsTestCase struct {
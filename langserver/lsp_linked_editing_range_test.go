@@ -0,0 +1,75 @@
+package langserver
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var linkedEditingRangeContext = newTestContext(cache.None)
+
+func TestLinkedEditingRange(t *testing.T) {
+	t.Parallel()
+
+	linkedEditingRangeContext.setup(t)
+
+	test := func(t *testing.T, pos string, want ...string) {
+		t.Helper()
+		file, line, char, err := parsePos(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(linkedEditingRangeContext.root())), file)
+		params := lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     lsp.Position{Line: line, Character: char},
+		}
+		var ranges *protocol.LinkedEditingRanges
+		if err := linkedEditingRangeContext.conn.Call(linkedEditingRangeContext.ctx, "textDocument/linkedEditingRange", params, &ranges); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		if ranges != nil {
+			if ranges.WordPattern != identifierPattern {
+				t.Errorf("%s: got word pattern %q, want %q", pos, ranges.WordPattern, identifierPattern)
+			}
+			for _, r := range ranges.Ranges {
+				got = append(got, rangeString(r))
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: got %q, want %q", pos, strings.Join(got, " "), strings.Join(want, " "))
+		}
+	}
+
+	t.Run("receiver", func(t *testing.T) {
+		test(t, "linkedediting/a.go:5:7", "4:6-4:7", "5:1-5:2", "6:34-6:35")
+	})
+
+	t.Run("parameter used in a closure", func(t *testing.T) {
+		test(t, "linkedediting/a.go:7:28", "4:16-4:21", "5:8-5:13", "6:26-6:31")
+	})
+
+	t.Run("after a parameter", func(t *testing.T) {
+		test(t, "linkedediting/a.go:12:11", "10:7-10:8", "11:9-11:10")
+	})
+
+	t.Run("field", func(t *testing.T) {
+		test(t, "linkedediting/a.go:6:4")
+	})
+
+	t.Run("named result", func(t *testing.T) {
+		test(t, "linkedediting/a.go:12:2")
+	})
+
+	t.Run("local variable", func(t *testing.T) {
+		test(t, "linkedediting/a.go:7:2")
+	})
+}
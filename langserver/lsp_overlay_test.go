@@ -0,0 +1,158 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var overlayContext = newTestContext(cache.Always)
+
+// TestOverlay checks that the packages importing an open file are type
+// checked with its unsaved content rather than the one on disk.
+func TestOverlay(t *testing.T) {
+	t.Parallel()
+
+	tx := overlayContext
+	tx.setup(t)
+	rootURI := util.PathToURI(tx.root())
+
+	aURI := uriJoin(rootURI, "goproject/a/a.go")
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: aURI, LanguageID: "go", Version: 1, Text: "package a\n\n// A takes an argument now.\nfunc A(x int) int { return x }\n"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didClose", lsp.DidCloseTextDocumentParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: aURI},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	t.Run("definition", func(t *testing.T) {
+		definition, err := callDefinition(tx.ctx, tx.conn, uriJoin(rootURI, "goproject/b/b.go"), 0, 88)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "goproject/a/a.go:4:6-4:7"
+		if got := strings.Replace(definition, string(rootURI)+"/", "", -1); got != want {
+			t.Errorf("got definition %q, want %q", got, want)
+		}
+	})
+
+	t.Run("references", func(t *testing.T) {
+		doReferencesTest(t, tx, "goproject/b/b.go:1:89", []string{"goproject/a/a.go:4:6", "goproject/b/b.go:1:89"})
+	})
+}
+
+var bodyOnlyContext, bodyOnlyClient = newDiagnosticsTestContext(cache.Always)
+
+// TestBodyOnlyChange checks that a package importing both an open file
+// whose functions only changed in their bodies, and a package type checked
+// against its previous content, sees a single version of its types.
+func TestBodyOnlyChange(t *testing.T) {
+	t.Parallel()
+
+	tx := bodyOnlyContext
+	tx.setup(t)
+	rootURI := util.PathToURI(tx.root())
+
+	open := func(file string) lsp.DocumentURI {
+		t.Helper()
+		text, err := ioutil.ReadFile(filepath.Join(tx.root(), file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(rootURI, file)
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: string(text)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return uri
+	}
+	diagnostics := func(uri lsp.DocumentURI) []protocol.Diagnostic {
+		t.Helper()
+		want := makePath(util.UriToRealPath(uri))
+		timeout := time.After(30 * time.Second)
+		for {
+			select {
+			case params := <-bodyOnlyClient.published:
+				if makePath(util.UriToRealPath(params.URI)) == want {
+					return params.Diagnostics
+				}
+			case <-timeout:
+				t.Fatalf("no diagnostics published for %s", uri)
+			}
+		}
+	}
+
+	// The package b is type checked as an import of c.
+	aURI := open("bodyonly/a/a.go")
+	diagnostics(aURI)
+	diagnostics(open("bodyonly/c/c.go"))
+
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: aURI}, Version: 2},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: "package a; type T struct{}; func F() T { t := T{}; return t }"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	diagnostics(aURI)
+
+	if got := diagnostics(open("bodyonly/d/d.go")); len(got) != 0 {
+		t.Errorf("got diagnostics %+v, want none", got)
+	}
+}
+
+// BenchmarkDidChange measures a hover in a package importing an open file
+// right after a change of the file. The importing package stays in the
+// global cache if only the bodies of the functions of the file changed,
+// and is type checked again if its declarations changed.
+func BenchmarkDidChange(b *testing.B) {
+	tx := newTestContext(cache.Always)
+	tx.setup(b)
+	defer tx.tearDown()
+	rootURI := util.PathToURI(tx.root())
+	aURI := uriJoin(rootURI, "goproject/a/a.go")
+	bURI := uriJoin(rootURI, "goproject/b/b.go")
+
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: aURI, LanguageID: "go", Version: 1, Text: "package a; func A() {}"},
+	}); err != nil {
+		b.Fatal(err)
+	}
+	version := 1
+
+	bench := func(b *testing.B, texts ...string) {
+		for i := 0; i < b.N; i++ {
+			version++
+			if err := tx.conn.Notify(tx.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+				TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: aURI}, Version: version},
+				ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: texts[i%len(texts)]}},
+			}); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := callHover(tx.ctx, tx.conn, bURI, 0, 88); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("body", func(b *testing.B) {
+		bench(b, "package a; func A() { _ = 1 }", "package a; func A() { _ = 2 }")
+	})
+
+	b.Run("declarations", func(b *testing.B) {
+		bench(b, "package a; func A(...int) {}", "package a; func A(...string) {}")
+	})
+}
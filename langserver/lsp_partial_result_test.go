@@ -0,0 +1,211 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/go-lsp/lspext"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var partialResultContext = newTestContext(cache.Always)
+
+// progressConn is a fake connection recording the values of the
+// $/progress notifications sent by the server.
+type progressConn struct {
+	mu     sync.Mutex
+	tokens []protocol.ProgressToken
+	values []json.RawMessage
+}
+
+func (c *progressConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	return nil
+}
+
+func (c *progressConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	if method != "$/progress" {
+		return nil
+	}
+	p := params.(protocol.ProgressParams)
+	value, err := json.Marshal(p.Value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = append(c.tokens, p.Token)
+	c.values = append(c.values, value)
+	return nil
+}
+
+func (c *progressConn) Close() error {
+	return nil
+}
+
+func TestPartialResults(t *testing.T) {
+	t.Parallel()
+
+	partialResultContext.setup(t)
+
+	rootURI := util.PathToURI(filepath.ToSlash(partialResultContext.root()))
+
+	t.Run("references", func(t *testing.T) {
+		file, line, char, err := parsePos("refs/a/a.go:1:17")
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := lsp.ReferenceParams{
+			Context: lsp.ReferenceContext{IncludeDeclaration: true},
+			TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+				Position:     lsp.Position{Line: line, Character: char},
+			},
+		}
+
+		conn := &progressConn{}
+		result := handleWithPartialResults(t, conn, "textDocument/references", params, "refs")
+		if got := string(result); got != "[]" {
+			t.Errorf("got final result %s, want []", got)
+		}
+
+		var batches [][]string
+		for i, value := range conn.values {
+			if conn.tokens[i] != "refs" {
+				t.Errorf("got token %v, want refs", conn.tokens[i])
+			}
+			var locs []lsp.Location
+			if err := json.Unmarshal(value, &locs); err != nil {
+				t.Fatal(err)
+			}
+			var batch []string
+			for _, loc := range locs {
+				path := util.UriToRealPath(loc.URI)
+				rel, err := filepath.Rel(partialResultContext.root(), path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				batch = append(batch, fmt.Sprintf("%s:%d:%d", filepath.ToSlash(rel), loc.Range.Start.Line+1, loc.Range.Start.Character+1))
+			}
+			batches = append(batches, batch)
+		}
+
+		want := [][]string{
+			{"refs/a/a.go:1:17"},
+			{"refs/a/a.go:1:35", "refs/a/a.go:1:52", "refs/a/a.go:1:74"},
+			{"refs/b/b.go:1:91"},
+		}
+		if !reflect.DeepEqual(batches, want) {
+			t.Errorf("got batches %q, want %q", batches, want)
+		}
+	})
+
+	t.Run("workspace symbol", func(t *testing.T) {
+		params := lspext.WorkspaceSymbolParams{Query: "dir:refs/a", Limit: 100}
+
+		var symbols []lsp.SymbolInformation
+		if err := partialResultContext.conn.Call(partialResultContext.ctx, "workspace/symbol", params, &symbols); err != nil {
+			t.Fatal(err)
+		}
+		if len(symbols) == 0 {
+			t.Fatal("got no symbols")
+		}
+
+		conn := &progressConn{}
+		result := handleWithPartialResults(t, conn, "workspace/symbol", params, 1)
+		if got := string(result); got != "[]" {
+			t.Errorf("got final result %s, want []", got)
+		}
+
+		var batches [][]lsp.SymbolInformation
+		for i, value := range conn.values {
+			if conn.tokens[i] != float64(1) {
+				t.Errorf("got token %v, want 1", conn.tokens[i])
+			}
+			var batch []lsp.SymbolInformation
+			if err := json.Unmarshal(value, &batch); err != nil {
+				t.Fatal(err)
+			}
+			batches = append(batches, batch)
+		}
+
+		want := [][]lsp.SymbolInformation{symbols}
+		if !reflect.DeepEqual(batches, want) {
+			t.Errorf("got batches %v, want %v", batches, want)
+		}
+	})
+
+	t.Run("ranked workspace symbol", func(t *testing.T) {
+		// The symbols streamed are the best ranked ones of all the
+		// packages, not the first ones found.
+		symbolBatches := func(limit int) [][]lsp.SymbolInformation {
+			conn := &progressConn{}
+			handleWithPartialResults(t, conn, "workspace/symbol", lspext.WorkspaceSymbolParams{Query: "t", Limit: limit}, 1)
+			var batches [][]lsp.SymbolInformation
+			for _, value := range conn.values {
+				var batch []lsp.SymbolInformation
+				if err := json.Unmarshal(value, &batch); err != nil {
+					t.Fatal(err)
+				}
+				batches = append(batches, batch)
+			}
+			return batches
+		}
+
+		var all []lsp.SymbolInformation
+		for _, batch := range symbolBatches(10000) {
+			all = append(all, batch...)
+		}
+		if len(all) <= symbolBatchSize {
+			t.Fatalf("got %d symbols, want more than %d", len(all), symbolBatchSize)
+		}
+
+		batches := symbolBatches(symbolBatchSize + 1)
+		if len(batches) != 2 || len(batches[0]) != symbolBatchSize || len(batches[1]) != 1 {
+			t.Fatalf("got %d batches, want batches of %d and 1 symbols", len(batches), symbolBatchSize)
+		}
+		if got := append(batches[0], batches[1]...); !reflect.DeepEqual(got, all[:symbolBatchSize+1]) {
+			t.Errorf("got symbols %v, want %v", got, all[:symbolBatchSize+1])
+		}
+	})
+}
+
+// handleWithPartialResults handles the request method with params and the
+// partial result token, and returns the final result of the request.
+func handleWithPartialResults(t *testing.T, conn *progressConn, method string, params interface{}, token protocol.ProgressToken) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	m["partialResultToken"] = token
+	data, err = json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := json.RawMessage(data)
+	req := &jsonrpc2.Request{Method: method, Params: &raw}
+	result, err := partialResultContext.handler.Handle(partialResultContext.ctx, conn, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
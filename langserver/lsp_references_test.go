@@ -3,7 +3,9 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
@@ -11,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 
 	"github.com/sourcegraph/go-lsp"
@@ -59,6 +62,13 @@ func TestReferences(t *testing.T) {
 		test(t, "goproject/b/b.go:1:87", []string{"goproject/b/b.go:1:19", "goproject/b/b.go:1:87"})
 	})
 
+	t.Run("utf16", func(t *testing.T) {
+		test(t, "utf16/a.go:6:16", []string{"utf16/a.go:6:16", "utf16/a.go:8:18"})
+		test(t, "utf16/a.go:8:18", []string{"utf16/a.go:6:16", "utf16/a.go:8:18"})
+		test(t, "utf16/a.go:3:6", []string{"utf16/a.go:3:6", "utf16/a.go:6:20", "utf16/b.go:3:18"})
+		test(t, "utf16/a.go:3:18", []string{"utf16/a.go:3:18", "utf16/a.go:6:24", "utf16/a.go:8:20", "utf16/b.go:3:24"})
+	})
+
 	t.Run("go module", func(t *testing.T) {
 		test(t, "gomodule/a.go:1:57", []string{"gomodule/a.go:1:57", "gomodule/a.go:1:72", githubModule + "/d.go:1:19", githubModule + "/d.go:1:35"})
 	})
@@ -66,6 +76,109 @@ func TestReferences(t *testing.T) {
 	t.Run("unexpected paths", func(t *testing.T) {
 		test(t, "unexpected_paths/a.go:1:17", []string{"unexpected_paths/a.go:1:17", "unexpected_paths/a.go:1:23"})
 	})
+
+	t.Run("shadowed variables", func(t *testing.T) {
+		test(t, "shadow/a.go:5:11", []string{"shadow/a.go:5:11", "shadow/a.go:16:9"})
+		test(t, "shadow/a.go:6:17", []string{"shadow/a.go:6:5", "shadow/a.go:6:17", "shadow/a.go:7:10"})
+		test(t, "shadow/a.go:9:30", []string{"shadow/a.go:9:6", "shadow/a.go:9:18", "shadow/a.go:9:30", "shadow/a.go:10:7"})
+		test(t, "shadow/a.go:14:7", []string{"shadow/a.go:13:3", "shadow/a.go:14:7"})
+	})
+
+	t.Run("indirect dependents", func(t *testing.T) {
+		test(t, "refs/a/a.go:1:38", []string{"refs/a/a.go:1:38", "refs/c/c.go:1:92"})
+		test(t, "refs/a/a.go:1:55", []string{"refs/a/a.go:1:55", "refs/a/a.go:1:78"})
+	})
+
+	t.Run("promoted through embedding", func(t *testing.T) {
+		fields := func() []string {
+			return []string{"embedrefs/a.go:1:30", "embedrefs/a.go:1:157", "embedrefs/a.go:1:167", "embedrefs/a.go:1:178", "embedrefs/b/b.go:1:118"}
+		}
+		test(t, "embedrefs/a.go:1:30", fields())
+		test(t, "embedrefs/a.go:1:178", fields())
+		test(t, "embedrefs/b/b.go:1:118", fields())
+
+		methods := func() []string {
+			return []string{"embedrefs/a.go:1:52", "embedrefs/a.go:1:184", "embedrefs/a.go:1:191", "embedrefs/a.go:1:199", "embedrefs/b/b.go:1:104"}
+		}
+		test(t, "embedrefs/a.go:1:52", methods())
+		test(t, "embedrefs/a.go:1:199", methods())
+	})
+
+	t.Run("fields of the same name", func(t *testing.T) {
+		test(t, "samefields/a.go:1:27", []string{"samefields/a.go:1:27", "samefields/a.go:1:89", "samefields/a.go:1:99", "samefields/b/b.go:1:92"})
+		test(t, "samefields/a.go:1:111", []string{"samefields/a.go:1:57", "samefields/a.go:1:111", "samefields/a.go:1:121", "samefields/a_test.go:1:24", "samefields/b/b.go:1:110"})
+	})
+
+	t.Run("include declaration", func(t *testing.T) {
+		test(t, "declrefs/a.go:1:21", []string{"declrefs/a.go:1:21", "declrefs/a.go:1:82"})
+		test(t, "declrefs/a.go:1:67", []string{"declrefs/a.go:1:48", "declrefs/a.go:1:67", "declrefs/a.go:1:90"})
+	})
+
+	t.Run("exclude declaration", func(t *testing.T) {
+		test := func(t *testing.T, input string, output []string) {
+			doReferencesContextTest(t, referencesContext, input, false, output)
+		}
+
+		test(t, "basic/a.go:1:17", []string{"basic/a.go:1:23", "basic/b.go:1:23"})
+		test(t, "basic/b.go:1:17", nil)
+		test(t, "declrefs/a.go:1:18", []string{"declrefs/a.go:1:70"})
+		test(t, "declrefs/a.go:1:82", []string{"declrefs/a.go:1:82"})
+		test(t, "declrefs/a.go:1:48", []string{"declrefs/a.go:1:67", "declrefs/a.go:1:90"})
+		test(t, "xtest/a.go:1:16", []string{"xtest/a_test.go:1:20", "xtest/x_test.go:1:88"})
+	})
+
+	t.Run("interface references", func(t *testing.T) {
+		defer referencesContext.configure(t, map[string]interface{}{"interfaceReferences": true})()
+
+		methods := func() []string {
+			return []string{"ifacerefs/a.go:1:30", "ifacerefs/a.go:1:63", "ifacerefs/a.go:1:92", "ifacerefs/a.go:1:99"}
+		}
+		test(t, "ifacerefs/a.go:1:30", methods())
+		test(t, "ifacerefs/a.go:1:63", methods())
+		doReferencesContextTest(t, referencesContext, "ifacerefs/a.go:1:30", false, []string{"ifacerefs/a.go:1:92", "ifacerefs/a.go:1:99"})
+		test(t, "ifacerefs/b.go:1:17", []string{"ifacerefs/b.go:1:17"})
+	})
+
+	t.Run("max references", func(t *testing.T) {
+		defer referencesContext.configure(t, map[string]interface{}{"maxReferences": 2})()
+
+		// The references are truncated to the first ones found.
+		test(t, "basic/a.go:1:17", []string{"basic/a.go:1:17", "basic/a.go:1:23"})
+		doReferencesContextTest(t, referencesContext, "basic/a.go:1:17", false, []string{"basic/a.go:1:23", "basic/b.go:1:23"})
+		test(t, "refs/a/a.go:1:38", []string{"refs/a/a.go:1:38", "refs/c/c.go:1:92"})
+	})
+}
+
+var referencesOnDemandContext = newTestContext(cache.Ondemand)
+
+func TestReferencesOnDemand(t *testing.T) {
+	t.Parallel()
+
+	referencesOnDemandContext.setup(t)
+
+	t.Run("unloaded dependents", func(t *testing.T) {
+		doReferencesTest(t, referencesOnDemandContext, "refs/a/a.go:1:38", []string{"refs/a/a.go:1:38", "refs/c/c.go:1:92"})
+	})
+
+	t.Run("created dependents", func(t *testing.T) {
+		// The import graph of the workspace is listed again once a file is
+		// created.
+		tx := referencesOnDemandContext
+		filename := filepath.Join(tx.root(), "refs", "d", "d.go")
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		text := `package d; import "github.com/saibing/bingo/langserver/test/pkg/refs/a"; func F() { a.T{}.M() }`
+		if err := ioutil.WriteFile(filename, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.conn.Notify(tx.ctx, "workspace/didChangeWatchedFiles", protocol.DidChangeWatchedFilesParams{
+			Changes: []protocol.FileEvent{{URI: util.PathToURI(filepath.ToSlash(filename)), Type: protocol.Created}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		doReferencesTest(t, tx, "refs/a/a.go:1:38", []string{"refs/a/a.go:1:38", "refs/c/c.go:1:92", "refs/d/d.go:1:91"})
+	})
 }
 
 type referencesTestCase struct {
@@ -75,20 +188,24 @@ type referencesTestCase struct {
 
 func testReferences(tb testing.TB, c *referencesTestCase) {
 	tbRun(tb, fmt.Sprintf("references-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
-		dir, err := filepath.Abs(referencesContext.root())
-		if err != nil {
-			log.Fatal("testReferences", err)
-		}
-		doReferencesTest(t, referencesContext.ctx, referencesContext.conn, util.PathToURI(dir), c.input, c.output)
+		doReferencesTest(t, referencesContext, c.input, c.output)
 	})
 }
 
-func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos string, want []string) {
+func doReferencesTest(t testing.TB, tx *TestContext, pos string, want []string) {
+	doReferencesContextTest(t, tx, pos, true, want)
+}
+
+func doReferencesContextTest(t testing.TB, tx *TestContext, pos string, includeDeclaration bool, want []string) {
+	dir, err := filepath.Abs(tx.root())
+	if err != nil {
+		log.Fatal("doReferencesTest", err)
+	}
 	file, line, char, err := parsePos(pos)
 	if err != nil {
 		t.Fatal(err)
 	}
-	references, err := callReferences(ctx, c, uriJoin(rootURI, file), line, char)
+	references, err := callReferences(tx.ctx, tx.conn, uriJoin(util.PathToURI(dir), file), line, char, includeDeclaration)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -110,7 +227,7 @@ func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 		if strings.HasPrefix(want[i], githubModule) {
 			want[i] = makePath(gopathDir, want[i])
 		} else {
-			want[i] = makePath(referencesContext.root(), want[i])
+			want[i] = makePath(tx.root(), want[i])
 		}
 	}
 	sort.Strings(results)
@@ -120,10 +237,10 @@ func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 	}
 }
 
-func callReferences(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) ([]string, error) {
+func callReferences(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int, includeDeclaration bool) ([]string, error) {
 	var res locations
 	err := c.Call(ctx, "textDocument/references", lsp.ReferenceParams{
-		Context: lsp.ReferenceContext{IncludeDeclaration: true},
+		Context: lsp.ReferenceContext{IncludeDeclaration: includeDeclaration},
 		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
 			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
 			Position:     lsp.Position{Line: line, Character: char},
@@ -13,6 +13,7 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
@@ -48,6 +49,149 @@ func TestRenaming(t *testing.T) {
 			"13:5-13:6": "renaming/cgo/a.go",
 		})
 	})
+
+	t.Run("renaming across packages", func(t *testing.T) {
+		test(t, "renaming/cross/a/a.go:1:76", map[string]string{
+			"0:75-0:76":   "renaming/cross/a/a.go",
+			"0:149-0:150": "renaming/cross/b/b.go",
+		})
+
+		test(t, "renaming/cross/a/a.go:1:117", map[string]string{
+			"0:87-0:88":   "renaming/cross/a/a.go",
+			"0:116-0:117": "renaming/cross/a/a.go",
+		})
+	})
+
+	t.Run("renaming fields of the same name", func(t *testing.T) {
+		test(t, "samefields/a.go:1:27", map[string]string{
+			"0:26-0:30":  "samefields/a.go",
+			"0:88-0:92":  "samefields/a.go",
+			"0:98-0:102": "samefields/a.go",
+			"0:91-0:95":  "samefields/b/b.go",
+		})
+
+		test(t, "samefields/b/b.go:1:110", map[string]string{
+			"0:56-0:60":   "samefields/a.go",
+			"0:110-0:114": "samefields/a.go",
+			"0:120-0:124": "samefields/a.go",
+			"0:23-0:27":   "samefields/a_test.go",
+			"0:109-0:113": "samefields/b/b.go",
+		})
+	})
+
+	t.Run("renaming utf16", func(t *testing.T) {
+		test(t, "utf16/a.go:3:6", map[string]string{
+			"2:5-2:8":   "utf16/a.go",
+			"5:19-5:22": "utf16/a.go",
+			"2:17-2:20": "utf16/b.go",
+		})
+
+		test(t, "utf16/b.go:3:24", map[string]string{
+			"2:17-2:18": "utf16/a.go",
+			"5:23-5:24": "utf16/a.go",
+			"7:19-7:20": "utf16/a.go",
+			"2:23-2:24": "utf16/b.go",
+		})
+	})
+
+	t.Run("renaming interface methods", func(t *testing.T) {
+		want := map[string]string{
+			"0:29-0:30":   "renaming/cross/a/a.go",
+			"0:62-0:63":   "renaming/cross/a/a.go",
+			"0:110-0:111": "renaming/cross/b/b.go",
+			"0:160-0:161": "renaming/cross/b/b.go",
+		}
+		for _, pos := range []string{"renaming/cross/a/a.go:1:30", "renaming/cross/a/a.go:1:63", "renaming/cross/b/b.go:1:111"} {
+			output := map[string]string{}
+			for k, v := range want {
+				output[k] = v
+			}
+			test(t, pos, output)
+		}
+	})
+
+	t.Run("rejected renamings", func(t *testing.T) {
+		rootURI := util.PathToURI(filepath.ToSlash(renameContext.root()))
+		for _, c := range []struct {
+			pos, newName, want string
+		}{
+			{"renaming/cross/a/a.go:1:76", "1x", `"1x" is not a valid identifier`},
+			{"renaming/cross/a/a.go:1:76", "func", `"func" is not a valid identifier`},
+			{"renaming/cross/b/b.go:1:125", "x", "cannot rename the package name a"},
+			{"renaming/cross/a/a.go:1:90", "x", "cannot rename builtin int"},
+			{"renaming/a.go:6:6", "x", "cannot rename symbol in dependency: Println is declared in the standard library"},
+			{"renaming/stringer/a.go:1:59", "x", "fmt.Stringer is declared in the standard library"},
+		} {
+			doRenamingErrorTest(t, renameContext.ctx, renameContext.conn, rootURI, c.pos, c.newName, c.want)
+		}
+	})
+
+	t.Run("renaming conflicts", func(t *testing.T) {
+		test(t, "renaming/conflict/a.go:17:2", map[string]string{
+			"16:1-16:2":   "renaming/conflict/a.go",
+			"21:12-21:13": "renaming/conflict/a.go",
+		})
+
+		rootURI := util.PathToURI(filepath.ToSlash(renameContext.root()))
+		for _, c := range []struct {
+			pos, newName, want string
+		}{
+			{"renaming/conflict/a.go:11:2", "err", "renaming/conflict/a.go:11:2: count conflicts with err declared at"},
+			{"renaming/conflict/a.go:5:16", "g", "renaming/conflict/a.go:5:16: field f conflicts with g declared at"},
+			{"renaming/conflict/a.go:7:10", "N", "renaming/conflict/a.go:7:10: method M conflicts with N declared at"},
+			{"renaming/conflict/a.go:19:3", "x", "renaming/conflict/a.go:20:3: the reference to x would refer to the renamed z declared at"},
+			{"renaming/conflict/a.go:25:5", "len", "renaming/conflict/a.go:29:17: the reference to len would refer to the renamed v declared at"},
+			{"renaming/conflict/a.go:25:5", "w", "renaming/conflict/a.go:29:9: the reference to v would refer to w declared at"},
+			{"renaming/conflict/a.go:25:5", "fmt", "renaming/conflict/a.go:25:5: v conflicts with the import fmt at"},
+		} {
+			doRenamingErrorTest(t, renameContext.ctx, renameContext.conn, rootURI, c.pos, c.newName, c.want)
+		}
+	})
+
+	t.Run("renaming packages", func(t *testing.T) {
+		rootURI := util.PathToURI(filepath.ToSlash(renameContext.root()))
+		doRenamingEditsTest(t, renameContext.ctx, renameContext.conn, rootURI, "renaming/pkg/p/p.go:1:9", "n", map[string]string{
+			"renaming/pkg/p/p.go:0:8-0:9":        "n",
+			"renaming/pkg/p/p_test.go:0:8-0:9":   "n",
+			"renaming/pkg/p/x_test.go:0:8-0:14":  "n_test",
+			"renaming/pkg/p/x_test.go:0:94-0:95": "n",
+			"renaming/pkg/q/q.go:0:89-0:90":      "n",
+			"renaming/pkg/q/q.go:0:105-0:106":    "n",
+			"renaming/pkg/s/s.go:0:18-0:18":      "p ",
+		})
+
+		doRenamingEditsTest(t, renameContext.ctx, renameContext.conn, rootURI, "renaming/pkg/p/p_test.go:1:9", "pp", map[string]string{
+			"renaming/pkg/p/p.go:0:8-0:9":        "pp",
+			"renaming/pkg/p/p_test.go:0:8-0:9":   "pp",
+			"renaming/pkg/p/x_test.go:0:8-0:14":  "pp_test",
+			"renaming/pkg/p/x_test.go:0:94-0:95": "pp",
+			"renaming/pkg/q/q.go:0:89-0:90":      "pp",
+			"renaming/pkg/q/q.go:0:105-0:106":    "pp",
+			"renaming/pkg/r/r.go:0:18-0:21":      "",
+			"renaming/pkg/s/s.go:0:100-0:101":    "pp",
+		})
+
+		doRenamingErrorTest(t, renameContext.ctx, renameContext.conn, rootURI, "renaming/pkg/p/p.go:1:9", "_", "cannot rename package p to _")
+		doRenamingErrorTest(t, renameContext.ctx, renameContext.conn, rootURI, "renaming/pkg/q/q.go:1:90", "n", "cannot rename the package name p, rename its package clause instead")
+	})
+
+	t.Run("prepare renaming", func(t *testing.T) {
+		rootURI := util.PathToURI(filepath.ToSlash(renameContext.root()))
+		for _, c := range []struct {
+			pos, want string
+		}{
+			{"renaming/cross/a/a.go:1:76", "0:75-0:76 F"},
+			{"renaming/cross/a/a.go:1:77", "0:75-0:76 F"},
+			{"renaming/cross/b/b.go:1:111", "0:110-0:111 Q"},
+			{"renaming/pkg/p/p.go:1:9", "0:8-0:9 p"},
+			{"renaming/cross/b/b.go:1:30", "error: cannot rename import path"},
+			{"renaming/cross/b/b.go:1:125", "error: cannot rename the package name a"},
+			{"renaming/cross/a/a.go:1:90", "error: cannot rename builtin int"},
+			{"renaming/a.go:6:6", "error: cannot rename symbol in dependency"},
+		} {
+			doPrepareRenameTest(t, renameContext.ctx, renameContext.conn, rootURI, c.pos, c.want)
+		}
+	})
 }
 
 type renamingTestCase struct {
@@ -71,7 +215,7 @@ func doRenamingTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI
 		t.Fatal(err)
 	}
 
-	workspaceEdit, err := callRenaming(ctx, c, uriJoin(rootURI, file), line, char, "")
+	workspaceEdit, err := callRenaming(ctx, c, uriJoin(rootURI, file), line, char, "renamed")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,6 +236,73 @@ func doRenamingTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI
 	}
 }
 
+// doRenamingEditsTest checks the new texts of the edits of renaming the
+// identifier at pos to newName, by file and range.
+func doRenamingEditsTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, newName string, want map[string]string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workspaceEdit, err := callRenaming(ctx, c, uriJoin(rootURI, file), line, char, newName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := makePath(renameContext.root())
+	got := map[string]string{}
+	for uri, edits := range workspaceEdit.Changes {
+		path := strings.TrimPrefix(makePath(util.UriToRealPath(lsp.DocumentURI(uri))), root+"/")
+		for _, edit := range edits {
+			got[fmt.Sprintf("%s:%s", path, edit.Range)] = edit.NewText
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("renaming %s to %s:\ngot %v,\nwant: %v", pos, newName, got, want)
+	}
+}
+
+func doRenamingErrorTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, newName, want string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = callRenaming(ctx, c, uriJoin(rootURI, file), line, char, newName)
+	if err == nil {
+		t.Errorf("renaming %s to %s: got no error, want %q", pos, newName, want)
+	} else if !strings.Contains(err.Error(), want) {
+		t.Errorf("renaming %s to %s: got error %q, want %q", pos, newName, err, want)
+	}
+}
+
+func doPrepareRenameTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result protocol.PrepareRenameResult
+	err = c.Call(ctx, "textDocument/prepareRename", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &result)
+
+	var got string
+	if err != nil {
+		got = "error: " + err.Error()
+	} else {
+		got = fmt.Sprintf("%s %s", result.Range, result.Placeholder)
+	}
+	if strings.HasPrefix(want, "error: ") {
+		if !strings.HasPrefix(got, "error: ") || !strings.Contains(got, strings.TrimPrefix(want, "error: ")) {
+			t.Errorf("prepare renaming %s: got %q, want %q", pos, got, want)
+		}
+	} else if got != want {
+		t.Errorf("prepare renaming %s: got %q, want %q", pos, got, want)
+	}
+}
+
 func callRenaming(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int, newName string) (lsp.WorkspaceEdit, error) {
 	var edit lsp.WorkspaceEdit
 	err := c.Call(ctx, "textDocument/rename", lsp.RenameParams{
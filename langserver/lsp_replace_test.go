@@ -0,0 +1,212 @@
+package langserver
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+const replaceModule = "github.com/saibing/bingo/langserver/test/replace"
+
+var replaceContext = newTestContext(cache.Ondemand)
+
+var replaceTestdata = []packagestest.Module{
+	{
+		Name: replaceModule,
+		Files: map[string]interface{}{
+			"a.go": `package a; import "github.com/saibing/local"; var _ = local.L; var _ = local.L`,
+			"b.go": `package a; import "github.com/saibing/dep"; var _ = dep.D`,
+		},
+	},
+}
+
+// replaceGoMod replaces github.com/saibing/local by a local directory and
+// github.com/saibing/dep by a version of another module, replaceFork.
+const replaceGoMod = `module ` + replaceModule + `
+
+require (
+	github.com/saibing/dep v1.0.2
+	github.com/saibing/local v0.0.0
+)
+
+replace github.com/saibing/local => ../local
+
+replace github.com/saibing/dep => ` + replaceFork + ` ` + replaceForkVersion + `
+`
+
+var replaceLocalFiles = map[string]string{
+	"go.mod":   "module github.com/saibing/local\n",
+	"local.go": `package local; func L() {}`,
+}
+
+// replaceFork stands for github.com/saibing/dep under a module path of its
+// own, served by a module proxy of the test. Its D has a signature of its
+// own, so that hover tells the fork from the original.
+const (
+	replaceFork        = "github.com/saibing/fork"
+	replaceForkVersion = "v1.0.0"
+)
+
+var replaceForkFiles = map[string]string{
+	"go.mod": "module " + replaceFork + "\n",
+	"d.go":   `package dep; func D(forked bool) {}`,
+}
+
+// setupReplace exports the replace module and the local directory it
+// replaces github.com/saibing/local with, and initializes the server with
+// replaceFork served by a module proxy and downloaded to a GOPATH of its
+// own. It returns the directories of the local and of the fork modules.
+func (tx *TestContext) setupReplace(t *testing.T) (string, string) {
+	t.Helper()
+	tx.exported = packagestest.Export(t, packagestest.Modules, replaceTestdata)
+	temp := filepath.Dir(tx.root())
+
+	localDir := filepath.Join(temp, "local")
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range replaceLocalFiles {
+		if err := ioutil.WriteFile(filepath.Join(localDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tx.root(), "go.mod"), []byte(replaceGoMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	proxyDir := filepath.Join(temp, "proxy")
+	writeModuleProxy(t, proxyDir, replaceFork, replaceForkVersion, replaceForkFiles)
+	gopath := filepath.Join(temp, "gopath")
+	tx.options = &InitializationOptions{Env: map[string]string{
+		"GOPATH":     gopath,
+		"GOMODCACHE": "",
+		"GOPROXY":    "file://" + filepath.ToSlash(proxyDir),
+		"GOSUMDB":    "off",
+	}}
+
+	tx.initServer(t)
+	return localDir, filepath.Join(gopath, "pkg", "mod", filepath.FromSlash(replaceFork+"@"+replaceForkVersion))
+}
+
+// writeModuleProxy writes the files of the module at version to the module
+// proxy directory dir.
+func writeModuleProxy(t *testing.T, dir, module, version string, files map[string]string) {
+	t.Helper()
+	versionDir := filepath.Join(dir, filepath.FromSlash(module), "@v")
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"list":            version + "\n",
+		version + ".info": `{"Version":"` + version + `"}`,
+		version + ".mod":  files["go.mod"],
+	} {
+		if err := ioutil.WriteFile(filepath.Join(versionDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Create(filepath.Join(versionDir, version+".zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := z.Create(module + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	t.Parallel()
+
+	localDir, forkDir := replaceContext.setupReplace(t)
+	rootURI := util.PathToURI(filepath.ToSlash(replaceContext.root()))
+
+	local := func(pos string) string {
+		return makePath(localDir, pos)
+	}
+	root := func(pos string) string {
+		return makePath(replaceContext.root(), pos)
+	}
+
+	t.Run("local replace definition", func(t *testing.T) {
+		testReplaceDefinition(t, rootURI, "a.go:1:61", local("local.go:1:21-1:22"))
+		testReplaceDefinition(t, rootURI, "a.go:1:26", local("local.go"))
+	})
+
+	t.Run("version replace definition", func(t *testing.T) {
+		testReplaceDefinition(t, rootURI, "b.go:1:57", makePath(forkDir, "d.go:1:19-1:20"))
+	})
+
+	t.Run("local replace hover", func(t *testing.T) {
+		doHoverTest(t, replaceContext.ctx, replaceContext.conn, rootURI, "a.go:1:61", "func L()")
+	})
+
+	t.Run("version replace hover", func(t *testing.T) {
+		doHoverTest(t, replaceContext.ctx, replaceContext.conn, rootURI, "b.go:1:57", "func D(forked bool)")
+	})
+
+	t.Run("local replace references", func(t *testing.T) {
+		testReplaceReferences(t, rootURI, "a.go:1:61", []string{local("local.go:1:21"), root("a.go:1:61"), root("a.go:1:78")})
+	})
+}
+
+func testReplaceDefinition(t *testing.T, rootURI lsp.DocumentURI, pos, want string) {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition, err := callDefinition(replaceContext.ctx, replaceContext.conn, uriJoin(rootURI, file), line, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(definition)))
+	if !strings.Contains(path.Base(want), ":") {
+		definition = path.Join(path.Dir(definition), strings.Split(path.Base(definition), ":")[0])
+	}
+	if definition != want {
+		t.Errorf("\n%s\ngot %q, \nwant %q", pos, definition, want)
+	}
+}
+
+func testReplaceReferences(t *testing.T, rootURI lsp.DocumentURI, pos string, want []string) {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	references, err := callReferences(replaceContext.ctx, replaceContext.conn, uriJoin(rootURI, file), line, char, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, r := range references {
+		got[filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(r)))] = true
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("\n%s\nmissing reference %q in %q", pos, w, references)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package langserver
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var selectionRangeContext = newTestContext(cache.None)
+
+func TestSelectionRange(t *testing.T) {
+	t.Parallel()
+
+	selectionRangeContext.setup(t)
+
+	uri := uriJoin(util.PathToURI(filepath.ToSlash(selectionRangeContext.root())), "selection/a.go")
+	params := protocol.SelectionRangeParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Positions: []lsp.Position{
+			{Line: 7, Character: 6},
+			{Line: 7, Character: 16},
+			{Line: 5, Character: 5},
+			{Line: 8, Character: 5},
+			{Line: 20, Character: 0},
+		},
+	}
+	var ranges []protocol.SelectionRange
+	if err := selectionRangeContext.conn.Call(selectionRangeContext.ctx, "textDocument/selectionRange", params, &ranges); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		// The identifier of the method, the selector and the call.
+		"7:5-7:12 7:1-7:12 7:1-7:24 4:17-9:1 4:0-9:1 0:0-9:1",
+		// The text of the string, counting UTF-16 code units.
+		"7:14-7:19 7:13-7:20 7:1-7:24 4:17-9:1 4:0-9:1 0:0-9:1",
+		// The text of the line comment, the comment and its group.
+		"5:3-5:13 5:1-5:13 5:1-6:13 4:17-9:1 4:0-9:1 0:0-9:1",
+		// The text of the block comment.
+		"8:3-8:9 8:1-8:11 4:17-9:1 4:0-9:1 0:0-9:1",
+		// A position past the end of the document.
+		"20:0-20:0",
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("got %d selection ranges, want %d", len(ranges), len(want))
+	}
+	for i, r := range ranges {
+		var got []string
+		for s := &r; s != nil; s = s.Parent {
+			got = append(got, rangeString(s.Range))
+		}
+		if strings.Join(got, " ") != want[i] {
+			t.Errorf("%d: got %q, want %q", i, strings.Join(got, " "), want[i])
+		}
+	}
+}
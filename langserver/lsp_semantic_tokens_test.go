@@ -0,0 +1,138 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var semanticTokensContext = newTestContextWith(cache.None, nil, &protocol.ClientCapabilities{
+	TextDocument: protocol.TextDocumentClientCapabilities{
+		SemanticTokens: &protocol.SemanticTokensClientCapabilities{},
+	},
+})
+
+func TestSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	semanticTokensContext.setup(t)
+
+	test := func(t *testing.T, method, file string, rng *lsp.Range, want ...string) {
+		t.Helper()
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(semanticTokensContext.root())), file)
+		var params interface{} = protocol.SemanticTokensParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+		if rng != nil {
+			params = protocol.SemanticTokensRangeParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}, Range: *rng}
+		}
+		var tokens protocol.SemanticTokens
+		if err := semanticTokensContext.conn.Call(semanticTokensContext.ctx, method, params, &tokens); err != nil {
+			t.Fatal(err)
+		}
+
+		got := decodeSemanticTokens(t, tokens.Data)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", file, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	t.Run("full", func(t *testing.T) {
+		test(t, "textDocument/semanticTokens/full", "semantic/a.go", nil,
+			"0:8-8 namespace declaration",
+			"4:6-8 variable declaration,readonly",
+			"6:5-1 struct declaration",
+			"7:1-4 property declaration",
+			"7:6-6 type defaultLibrary",
+			"10:5-1 interface declaration",
+			"11:1-1 method declaration",
+			"11:3-1 parameter declaration",
+			"11:5-3 type defaultLibrary",
+			"11:10-6 type defaultLibrary",
+			"14:6-1 parameter declaration",
+			"14:8-1 struct",
+			"14:11-1 method declaration",
+			"14:13-1 parameter declaration",
+			"14:15-3 type defaultLibrary",
+			"14:20-6 type defaultLibrary",
+			"15:1-1 variable declaration",
+			"15:6-3 namespace defaultLibrary",
+			"15:10-6 function defaultLibrary",
+			// The characters count UTF-16 code units.
+			"15:22-1 parameter",
+			"15:24-4 property",
+			"15:30-1 parameter",
+			"15:33-8 variable readonly",
+			"15:43-3 variable readonly,defaultLibrary",
+			"16:8-1 variable",
+			"19:6-1 interface",
+			"19:10-1 struct",
+			"19:12-4 property")
+	})
+
+	t.Run("ill typed package", func(t *testing.T) {
+		test(t, "textDocument/semanticTokens/full", "semantic/illtyped/a.go", nil,
+			"0:8-8 namespace declaration",
+			"4:5-1 struct declaration",
+			"5:1-4 property declaration",
+			"5:6-6 type defaultLibrary",
+			"8:5-1 function declaration",
+			"8:7-1 parameter declaration",
+			"8:9-1 struct",
+			"8:12-1 parameter declaration",
+			"8:14-3 type defaultLibrary",
+			"8:19-6 type defaultLibrary",
+			"9:1-3 namespace defaultLibrary",
+			"9:5-7 function defaultLibrary",
+			"9:13-1 parameter",
+			"9:15-4 property",
+			"9:21-1 parameter",
+			"10:8-1 parameter")
+	})
+
+	t.Run("range", func(t *testing.T) {
+		rng := &lsp.Range{Start: lsp.Position{Line: 7, Character: 0}, End: lsp.Position{Line: 9, Character: 0}}
+		test(t, "textDocument/semanticTokens/range", "semantic/illtyped/a.go", rng,
+			"8:5-1 function declaration",
+			"8:7-1 parameter declaration",
+			"8:9-1 struct",
+			"8:12-1 parameter declaration",
+			"8:14-3 type defaultLibrary",
+			"8:19-6 type defaultLibrary")
+	})
+}
+
+// decodeSemanticTokens returns the tokens of data, in the relative format
+// of the protocol, as "line:character-length type modifiers".
+func decodeSemanticTokens(t *testing.T, data []uint32) []string {
+	t.Helper()
+	if len(data)%5 != 0 {
+		t.Fatalf("got %d integers, want 5 per token", len(data))
+	}
+	var tokens []string
+	line, character := 0, 0
+	for i := 0; i < len(data); i += 5 {
+		if data[i] != 0 {
+			character = 0
+		}
+		line += int(data[i])
+		character += int(data[i+1])
+		var modifiers []string
+		for j, modifier := range semanticTokensLegend.TokenModifiers {
+			if data[i+4]&(1<<uint(j)) != 0 {
+				modifiers = append(modifiers, modifier)
+			}
+		}
+		token := fmt.Sprintf("%d:%d-%d %s", line, character, data[i+2], semanticTokensLegend.TokenTypes[data[i+3]])
+		if len(modifiers) > 0 {
+			token += " " + strings.Join(modifiers, ",")
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
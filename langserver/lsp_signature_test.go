@@ -43,6 +43,49 @@ func TestSignature(t *testing.T) {
 			"signature/e.go:1:48": "builtin.append(slice []builtin.Type, elems ...builtin.Type) 0",
 		})
 	})
+
+	t.Run("enhanced signature help", func(t *testing.T) {
+		defer signatureContext.configure(t, map[string]interface{}{"enhanceSignatureHelp": true})()
+
+		test(t, map[string]string{
+			"signature/b.go:1:28": "B() 0",
+			"signature/b.go:1:33": "A(foo int, bar func(baz int) int) int 0",
+			"signature/b.go:1:51": "C(x int, y int) int 0",
+			"signature/c.go:1:57": "fmt.Printf(format string, a ...interface{}) (n int, err error) 1",
+			"signature/e.go:1:48": "builtin.append(slice []builtin.Type, elems ...builtin.Type) []builtin.Type 0",
+			"signature/f.go:13:8": "Join(sep string, elems ...string) string 0 the separator placed between the elements.",
+		})
+	})
+
+	t.Run("parameter documentation", func(t *testing.T) {
+		test(t, map[string]string{
+			"signature/f.go:13:8":  "Join(sep string, elems ...string) 0 the separator placed between the elements.",
+			"signature/f.go:13:23": "Join(sep string, elems ...string) 1 the elements to join.",
+			"signature/f.go:14:13": "Join(sep string, elems ...string) 1 the elements to join.",
+			"signature/f.go:14:17": "Len(s string) 0",
+		})
+	})
+
+	t.Run("conversions, composite literals and method values", func(t *testing.T) {
+		test(t, map[string]string{
+			"signature/g.go:10:13": "MyType(value int) 0",
+			"signature/g.go:11:26": " 0",
+			"signature/g.go:11:12": "Join(sep string, elems ...string) 0 the separator placed between the elements.",
+			"signature/g.go:13:8":  "f(x int) 0",
+			"signature/g.go:14:20": "T.Method(t T, x int) 1",
+			"signature/g.go:15:14": "[]byte(value []byte) 0",
+			"signature/g.go:1:1":   " 0",
+		})
+	})
+
+	t.Run("function-typed values", func(t *testing.T) {
+		test(t, map[string]string{
+			"signature/h.go:13:14": "cb(ctx context.Context, n int) 1",
+			"signature/h.go:14:16": "cb(ctx context.Context, n int) 1",
+			"signature/h.go:16:9":  "m[\"k\"](x int) 0",
+			"signature/h.go:17:11": "mk()(_ int, _ string) 0",
+		})
+	})
 }
 
 type signatureTestCase struct {
@@ -94,5 +137,10 @@ func callSignature(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, l
 		}
 	}
 	str += fmt.Sprintf(" %d", res.ActiveParameter)
+	if len(res.Signatures) > 0 {
+		if params := res.Signatures[0].Parameters; res.ActiveParameter < len(params) && params[res.ActiveParameter].Documentation != "" {
+			str += " " + params[res.ActiveParameter].Documentation
+		}
+	}
 	return str, nil
 }
@@ -0,0 +1,78 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var symlinkContext = newTestContext(cache.None)
+
+// TestSymlink checks that the results of a workspace whose root, or one of
+// its directories, is a symbolic link have the paths the client sent.
+func TestSymlink(t *testing.T) {
+	t.Parallel()
+
+	tx := symlinkContext
+	tx.exported = packagestest.Export(t, packagestest.Modules, testdata)
+	real := tx.root()
+	link := filepath.Join(filepath.Dir(real), "symlinked")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	if err := os.Symlink("complit", filepath.Join(real, "complitlink")); err != nil {
+		t.Fatal(err)
+	}
+	tx.exported.Config.Dir = link
+	tx.initServer(t)
+	rootURI := util.PathToURI(link)
+
+	test := func(t *testing.T, pos, want string) {
+		t.Helper()
+		file, line, char, err := parsePos(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		definition, err := callDefinition(tx.ctx, tx.conn, uriJoin(rootURI, file), line, char)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Replace(definition, string(rootURI)+"/", "", -1); got != want {
+			t.Errorf("%s: got definition %q, want %q", pos, got, want)
+		}
+	}
+
+	t.Run("symlinked root", func(t *testing.T) {
+		test(t, "basic/b.go:1:23", "basic/a.go:1:17-1:18")
+	})
+
+	t.Run("symlinked root overlay", func(t *testing.T) {
+		aURI := uriJoin(rootURI, "basic/a.go")
+		if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: aURI, LanguageID: "go", Version: 1, Text: "package p\n\nfunc A() { A() }"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := tx.conn.Notify(tx.ctx, "textDocument/didClose", lsp.DidCloseTextDocumentParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: aURI},
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}()
+
+		test(t, "basic/b.go:1:23", "basic/a.go:3:6-3:7")
+	})
+
+	t.Run("symlinked subdirectory", func(t *testing.T) {
+		test(t, "complitlink/a.go:1:67", "complitlink/a.go:1:28-1:29")
+		test(t, "complit/a.go:1:67", "complit/a.go:1:28-1:29")
+	})
+}
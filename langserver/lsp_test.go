@@ -17,6 +17,7 @@ import (
 	"golang.org/x/tools/go/packages/packagestest"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 
 	"github.com/sourcegraph/go-lsp"
@@ -55,16 +56,52 @@ func TestMain(m *testing.M) {
 }
 
 func tearDown() {
+	codeActionContext.tearDown()
+	callHierarchyContext.tearDown()
+	bodyOnlyContext.tearDown()
+	cancelContext.tearDown()
+	codeLensContext.tearDown()
 	completionContext.tearDown()
+	configurationContext.tearDown()
+	declarationContext.tearDown()
 	definitionContext.tearDown()
+	definitionLinkContext.tearDown()
+	diagnosticsContext.tearDown()
+	documentLinkContext.tearDown()
+	documentChangesContext.tearDown()
+	extractContext.tearDown()
 	symbolContext.tearDown()
+	fillStructContext.tearDown()
+	foldingRangeContext.tearDown()
 	formatContext.tearDown()
+	hierarchicalSymbolContext.tearDown()
 	hoverContext.tearDown()
+	hoverMarkdownContext.tearDown()
+	highlightContext.tearDown()
+	hybridContext.tearDown()
 	implementationContext.tearDown()
+	implementInterfaceContext.tearDown()
+	linkedEditingRangeContext.tearDown()
+	logProgressContext.tearDown()
+	organizeImportsContext.tearDown()
+	overlayContext.tearDown()
+	partialResultContext.tearDown()
 	referencesContext.tearDown()
+	referencesOnDemandContext.tearDown()
 	renameContext.tearDown()
+	replaceContext.tearDown()
+	selectionRangeContext.tearDown()
+	semanticTokensContext.tearDown()
 	signatureContext.tearDown()
+	structTagsContext.tearDown()
+	symlinkContext.tearDown()
 	typeDefinitionContext.tearDown()
+	unimportedCompletionContext.tearDown()
+	vetContext.tearDown()
+	watchFilesContext.tearDown()
+	watchedFilesContext.tearDown()
+	importPathCompletionContext.tearDown()
+	workDoneProgressContext.tearDown()
 	workspaceReferencesContext.tearDown()
 	workspaceSymbolContext.tearDown()
 	xDefinitionContext.tearDown()
@@ -72,26 +109,47 @@ func tearDown() {
 
 type TestContext struct {
 	h          jsonrpc2.Handler
+	handler    *LangHandler
 	conn       *jsonrpc2.Conn
 	connServer *jsonrpc2.Conn
 	ctx        context.Context
 	exported   *packagestest.Exported
+
+	// capabilities are sent in addition to the lsp.ClientCapabilities.
+	capabilities *protocol.ClientCapabilities
+
+	// options are sent as the initialization options, if set.
+	options *InitializationOptions
+
+	// client handles the requests and notifications of the server, if set.
+	client jsonrpc2.Handler
 }
 
 func newTestContext(style cache.CacheStyle) *TestContext {
+	return newTestContextWith(style, nil, nil)
+}
+
+// newTestContextWith returns a test context whose server configuration is
+// modified by configure, if set, and whose client has the capabilities,
+// if set, in addition to those of all the test contexts.
+func newTestContextWith(style cache.CacheStyle, configure func(*Config), capabilities *protocol.ClientCapabilities) *TestContext {
 	cfg := NewDefaultConfig()
 	cfg.DisableFuncSnippet = false
 	cfg.GlobalCacheStyle = string(style)
+	if configure != nil {
+		configure(&cfg)
+	}
 
-	h := NewHandler(cfg)
-	ctx := context.Background()
+	h := newLangHandler(cfg)
 	return &TestContext{
-		h:   h,
-		ctx: ctx,
+		h:            lspHandler{jsonrpc2.HandlerWithError(h.handle)},
+		handler:      h,
+		ctx:          context.Background(),
+		capabilities: capabilities,
 	}
 }
 
-func (tx *TestContext) setup(t *testing.T) {
+func (tx *TestContext) setup(t testing.TB) {
 	t.Helper()
 	tx.exported = packagestest.Export(t, packagestest.Modules, testdata)
 	tx.initServer(t)
@@ -116,11 +174,29 @@ func (tx *TestContext) tearDown() {
 	}
 }
 
+// configure changes the settings of the server of tx like a client sending
+// them, and returns the function restoring those of the initialization. The
+// requests depending on the settings must not run meanwhile.
+func (tx *TestContext) configure(t testing.TB, settings map[string]interface{}) (restore func()) {
+	t.Helper()
+	apply := func(settings map[string]interface{}) {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.handler.applySettings(tx.ctx, data)
+	}
+	apply(settings)
+	return func() {
+		apply(nil)
+	}
+}
+
 func (tx *TestContext) root() string {
 	return tx.exported.Config.Dir
 }
 
-func (tx *TestContext) initServer(t *testing.T) {
+func (tx *TestContext) initServer(t testing.TB) {
 	t.Helper()
 	rootDir := tx.root()
 	os.Chdir(rootDir)
@@ -130,7 +206,11 @@ func (tx *TestContext) initServer(t *testing.T) {
 	// Prepare the connection.
 	client, server := net.Pipe()
 	tx.connServer = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), tx.h)
-	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), tx.h)
+	clientHandler := tx.h
+	if tx.client != nil {
+		clientHandler = tx.client
+	}
+	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), clientHandler)
 
 	tdCap := lsp.TextDocumentClientCapabilities{}
 	tdCap.Completion.CompletionItemKind.ValueSet = []lsp.CompletionItemKind{lsp.CIKConstant}
@@ -140,9 +220,47 @@ func (tx *TestContext) initServer(t *testing.T) {
 			Capabilities: lsp.ClientCapabilities{TextDocument: tdCap},
 		},
 
-		RootImportPath: rootImportPath,
+		InitializationOptions: tx.options,
+		RootImportPath:        rootImportPath,
+	}
+	var rawParams interface{} = params
+	if tx.capabilities != nil {
+		// The capabilities lsp.ClientCapabilities does not know about are
+		// merged into the "capabilities" object of the params.
+		toMap := func(v interface{}) map[string]interface{} {
+			data, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := map[string]interface{}{}
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}
+		var merge func(dst, src map[string]interface{})
+		merge = func(dst, src map[string]interface{}) {
+			for k, v := range src {
+				dv, ok1 := dst[k].(map[string]interface{})
+				sv, ok2 := v.(map[string]interface{})
+				if ok1 && ok2 {
+					merge(dv, sv)
+				} else {
+					dst[k] = v
+				}
+			}
+		}
+
+		m := toMap(params)
+		caps, _ := m["capabilities"].(map[string]interface{})
+		if caps == nil {
+			caps = map[string]interface{}{}
+			m["capabilities"] = caps
+		}
+		merge(caps, toMap(tx.capabilities))
+		rawParams = m
 	}
-	if err := tx.conn.Call(tx.ctx, "initialize", params, nil); err != nil {
+	if err := tx.conn.Call(tx.ctx, "initialize", rawParams, nil); err != nil {
 		t.Fatal("conn.Call initialize:", err)
 	}
 }
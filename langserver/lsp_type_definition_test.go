@@ -6,6 +6,7 @@ import (
 	"log"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -30,7 +31,29 @@ func TestTypeDefinition(t *testing.T) {
 		test(t, "lookup/a/a.go:1:58", "lookup/a/a.go:1:17-1:18")
 		test(t, "lookup/b/b.go:1:115", "lookup/a/a.go:1:17-1:18")
 		test(t, "lookup/c/c.go:1:117", "lookup/a/a.go:1:17-1:18")
-		test(t, "lookup/d/d.go:1:135", "")
+		test(t, "lookup/d/d.go:1:135", "lookup/a/a.go:1:17-1:18")
+		test(t, "lookup/e/e.go:1:45", "lookup/e/e.go:1:17-1:18, lookup/e/e.go:1:29-1:30")
+		test(t, "lookup/e/e.go:1:60", "lookup/e/e.go:1:29-1:30")
+		test(t, "lookup/e/e.go:1:72", "lookup/e/e.go:1:17-1:18")
+		test(t, "lookup/e/e.go:1:89", "")
+		test(t, "lookup/e/e.go:1:105", "")
+	})
+
+	t.Run("embedded field type definition", func(t *testing.T) {
+		test(t, "embedded/a.go:1:138", "goroot/src/io/io.go")
+		test(t, "embedded/a.go:1:181", "goroot/src/bytes/buffer.go")
+	})
+
+	t.Run("type switch type definition", func(t *testing.T) {
+		test(t, "typeswitch/a.go:1:101", "typeswitch/a.go:1:44-1:47, typeswitch/a.go:1:17-1:18")
+		test(t, "typeswitch/a.go:1:128", "typeswitch/a.go:1:44-1:47")
+		test(t, "typeswitch/a.go:1:146", "typeswitch/a.go:1:17-1:18")
+		test(t, "typeswitch/a.go:1:184", "typeswitch/a.go:1:44-1:47")
+	})
+
+	t.Run("builtin type definition", func(t *testing.T) {
+		test(t, "builtin/b.go:1:51", "goroot/src/builtin/builtin.go")
+		test(t, "builtin/b.go:1:49", "goroot/src/builtin/builtin.go")
 	})
 }
 
@@ -54,10 +77,15 @@ func doTypeDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, r
 		t.Fatal(err)
 	}
 	if definition != "" {
-		definition = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(definition)))
-		if trimPrefix != "" {
-			definition = strings.TrimPrefix(definition, util.UriToPath(util.PathToURI(trimPrefix)))
+		definitions := strings.Split(definition, ", ")
+		for i, d := range definitions {
+			d = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(d)))
+			if trimPrefix != "" {
+				d = strings.TrimPrefix(d, util.UriToPath(util.PathToURI(trimPrefix)))
+			}
+			definitions[i] = d
 		}
+		definition = strings.Join(definitions, ", ")
 	}
 	if want != "" && !strings.Contains(path.Base(want), ":") {
 		// our want is just a path, so we only check that matches. This is
@@ -68,9 +96,15 @@ func doTypeDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, r
 		definition = path.Join(dir, base)
 	}
 
-	if want != "" {
-		want = makePath(typeDefinitionContext.root(), want)
+	wants := strings.Split(want, ", ")
+	for i, w := range wants {
+		if strings.HasPrefix(w, goroot) {
+			wants[i] = makePath(runtime.GOROOT(), w[len(goroot):])
+		} else if w != "" {
+			wants[i] = makePath(typeDefinitionContext.root(), w)
+		}
 	}
+	want = strings.Join(wants, ", ")
 	if definition != want {
 		t.Errorf("got %q, want %q", definition, want)
 	}
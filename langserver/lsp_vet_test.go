@@ -0,0 +1,91 @@
+package langserver
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/analysis"
+)
+
+var vetContext = newTestContext(cache.None)
+
+func TestVetDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	vetContext.setup(t)
+
+	filename := filepath.Join(vetContext.root(), "vet", "a.go")
+	f, err := vetContext.handler.project.View().GetFile(vetContext.ctx, span.FileURI(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := f.GetPackage(vetContext.ctx)
+	if pkg == nil {
+		t.Fatal("no package for vet/a.go")
+	}
+
+	analyzers, unknown := source.AnalyzersByName([]string{"printf", "unreachable", "nosuchanalyzer"})
+	if len(analyzers) != 2 || len(unknown) != 1 || unknown[0] != "nosuchanalyzer" {
+		t.Fatalf("got analyzers %v and unknown names %v", analyzers, unknown)
+	}
+
+	reports, _ := vetDiagnostics(vetContext.ctx, vetContext.handler.project.View(), pkg, analyzers)
+	var got []string
+	for _, d := range reports[filename] {
+		got = append(got, fmt.Sprintf("%d:%d-%d:%d %s %s", d.Range.Start.Line+1, d.Range.Start.Character+1, d.Range.End.Line+1, d.Range.End.Character+1, d.Code, d.Source))
+	}
+	sort.Strings(got)
+	want := []string{
+		"6:2-6:5 printf printf",
+		"8:2-8:5 unreachable unreachable",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, fixes := vetDiagnostics(vetContext.ctx, vetContext.handler.project.View(), pkg, []*analysis.Analyzer{bareReturnAnalyzer})
+	got = nil
+	for _, fix := range fixes[filename] {
+		for uri, edits := range fix.edits {
+			for _, e := range edits {
+				got = append(got, fmt.Sprintf("%s %q: %s %d:%d-%d:%d %q", fix.diagnostic.Source, fix.title, filepath.Base(uri),
+					e.Range.Start.Line+1, e.Range.Start.Character+1, e.Range.End.Line+1, e.Range.End.Character+1, e.NewText))
+			}
+		}
+	}
+	want = []string{`bareReturn "Remove return": a.go 7:2-7:8 ""`}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got fixes %q, want %q", got, want)
+	}
+}
+
+// bareReturnAnalyzer reports the return statements without results, and
+// suggests to remove them.
+var bareReturnAnalyzer = &analysis.Analyzer{
+	Name: "bareReturn",
+	Doc:  "report return statements without results",
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		for _, f := range pass.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				if ret, ok := n.(*ast.ReturnStmt); ok && len(ret.Results) == 0 {
+					pass.Report(analysis.Diagnostic{
+						Pos:     ret.Pos(),
+						Message: "return without results",
+						SuggestedFixes: []analysis.SuggestedFix{{
+							Message:   "Remove return",
+							TextEdits: []analysis.TextEdit{{Pos: ret.Pos(), End: ret.End()}},
+						}},
+					})
+				}
+				return true
+			})
+		}
+		return nil, nil
+	},
+}
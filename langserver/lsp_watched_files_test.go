@@ -0,0 +1,205 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var watchedFilesContext, watchedFilesClient = newWatchedFilesTestContext(true)
+
+var watchFilesContext, watchFilesClient = newWatchedFilesTestContext(false)
+
+// watchedFilesRecorder is a client recording the capabilities registered
+// by the server and the diagnostics it publishes.
+type watchedFilesRecorder struct {
+	registered chan protocol.RegistrationParams
+	published  chan protocol.PublishDiagnosticsParams
+}
+
+func (r *watchedFilesRecorder) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Params == nil {
+		return
+	}
+
+	switch req.Method {
+	case "client/registerCapability":
+		var params protocol.RegistrationParams
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			r.registered <- params
+		}
+		_ = conn.Reply(ctx, req.ID, nil)
+	case "textDocument/publishDiagnostics":
+		var params protocol.PublishDiagnosticsParams
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			r.published <- params
+		}
+	}
+}
+
+// newWatchedFilesTestContext returns a test context whose client watches
+// the files if dynamicRegistration is set, or whose server watches them
+// otherwise.
+func newWatchedFilesTestContext(dynamicRegistration bool) (*TestContext, *watchedFilesRecorder) {
+	tx := newTestContextWith(cache.None, func(cfg *Config) {
+		cfg.DiagnosticsStyle = string(instantDiagnostics)
+		cfg.VetOnSave = false
+		cfg.WatchFiles = !dynamicRegistration
+	}, &protocol.ClientCapabilities{
+		Workspace: protocol.WorkspaceClientCapabilities{
+			DidChangeWatchedFiles: protocol.DidChangeWatchedFilesClientCapabilities{DynamicRegistration: dynamicRegistration},
+		},
+	})
+
+	client := &watchedFilesRecorder{
+		registered: make(chan protocol.RegistrationParams, 1),
+		published:  make(chan protocol.PublishDiagnosticsParams, 100),
+	}
+	tx.client = client
+	return tx, client
+}
+
+func TestWatchedFiles(t *testing.T) {
+	t.Parallel()
+
+	tx := watchedFilesContext
+	tx.setup(t)
+
+	if err := tx.conn.Notify(tx.ctx, "initialized", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case params := <-watchedFilesClient.registered:
+		if len(params.Registrations) != 1 || params.Registrations[0].Method != "workspace/didChangeWatchedFiles" {
+			t.Fatalf("got registrations %+v, want workspace/didChangeWatchedFiles", params.Registrations)
+		}
+		options, _ := json.Marshal(params.Registrations[0].RegisterOptions)
+		var registered protocol.DidChangeWatchedFilesRegistrationOptions
+		if err := json.Unmarshal(options, &registered); err != nil {
+			t.Fatal(err)
+		}
+		var globs []string
+		for _, watcher := range registered.Watchers {
+			globs = append(globs, watcher.GlobPattern)
+		}
+		if want := []string{"**/*.go", "**/go.mod", "**/go.sum"}; !reflect.DeepEqual(globs, want) {
+			t.Errorf("got globs %q, want %q", globs, want)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("the watched files were not registered")
+	}
+
+	rootURI := util.PathToURI(filepath.ToSlash(tx.root()))
+	uri := openWatchedFile(t, tx)
+	waitWatchedDiagnostics(t, watchedFilesClient, uri, false)
+
+	notify := func(t *testing.T, file string, change protocol.FileChangeType) {
+		t.Helper()
+		if err := tx.conn.Notify(tx.ctx, "workspace/didChangeWatchedFiles", protocol.DidChangeWatchedFilesParams{
+			Changes: []protocol.FileEvent{{URI: uriJoin(rootURI, file), Type: change}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("created", func(t *testing.T) {
+		writeWatchedFile(t, tx, "watched/c.go", "package watched\n\nfunc C() int { return 2 }\n")
+		notify(t, "watched/c.go", protocol.Created)
+		waitWatchedDiagnostics(t, watchedFilesClient, uri, true)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		writeWatchedFile(t, tx, "watched/b.go", "package watched\n\nfunc B() string { return \"\" }\n")
+		notify(t, "watched/b.go", protocol.Changed)
+		waitWatchedDiagnostics(t, watchedFilesClient, uri, false)
+	})
+}
+
+func TestWatchFiles(t *testing.T) {
+	t.Parallel()
+
+	tx := watchFilesContext
+	tx.setup(t)
+
+	uri := openWatchedFile(t, tx)
+	waitWatchedDiagnostics(t, watchFilesClient, uri, false)
+
+	// The server watches the files once the project is loaded, so the file
+	// is created again until its creation is noticed.
+	filename := filepath.Join(tx.root(), "watched/c.go")
+	timeout := time.After(30 * time.Second)
+	for {
+		_ = os.Remove(filename)
+		writeWatchedFile(t, tx, "watched/c.go", "package watched\n\nfunc C() int { return 2 }\n")
+		if publishedWithin(watchFilesClient, uri, true, time.Second) {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatal("the created file was not noticed")
+		default:
+		}
+	}
+}
+
+// openWatchedFile opens watched/a.go, which uses the functions of the other
+// files of its package.
+func openWatchedFile(t *testing.T, tx *TestContext) lsp.DocumentURI {
+	t.Helper()
+	text, err := ioutil.ReadFile(filepath.Join(tx.root(), "watched/a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), "watched/a.go")
+	if err := tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: string(text)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return uri
+}
+
+// writeWatchedFile writes the file outside of the editor.
+func writeWatchedFile(t *testing.T, tx *TestContext, file, text string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(tx.root(), file), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitWatchedDiagnostics waits for diagnostics to be published for the
+// document uri, an empty set of them if empty is set.
+func waitWatchedDiagnostics(t *testing.T, client *watchedFilesRecorder, uri lsp.DocumentURI, empty bool) {
+	t.Helper()
+	if !publishedWithin(client, uri, empty, 30*time.Second) {
+		t.Fatalf("no diagnostics published for %s (empty %t)", uri, empty)
+	}
+}
+
+// publishedWithin reports whether diagnostics are published for the document uri
+// within timeout, an empty set of them if empty is set.
+func publishedWithin(client *watchedFilesRecorder, uri lsp.DocumentURI, empty bool, timeout time.Duration) bool {
+	want := makePath(util.UriToRealPath(uri))
+	expired := time.After(timeout)
+	for {
+		select {
+		case params := <-client.published:
+			if makePath(util.UriToRealPath(params.URI)) == want && (len(params.Diagnostics) == 0) == empty {
+				return true
+			}
+		case <-expired:
+			return false
+		}
+	}
+}
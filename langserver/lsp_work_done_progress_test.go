@@ -0,0 +1,158 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var workDoneProgressContext, workDoneProgressClient = newWorkDoneProgressTestContext(true)
+
+var logProgressContext, logProgressClient = newWorkDoneProgressTestContext(false)
+
+// progressRecorder is a client recording the progress reported by the
+// server, with the work done progress or with log messages.
+type progressRecorder struct {
+	mu       sync.Mutex
+	created  []protocol.ProgressToken
+	progress []protocol.ProgressParams
+	logs     []string
+}
+
+func (r *progressRecorder) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Params == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch req.Method {
+	case "window/workDoneProgress/create":
+		var params protocol.WorkDoneProgressCreateParams
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			r.created = append(r.created, params.Token)
+		}
+		_ = conn.Reply(ctx, req.ID, nil)
+	case "$/progress":
+		var params protocol.ProgressParams
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			r.progress = append(r.progress, params)
+		}
+	case "window/logMessage":
+		var params lsp.LogMessageParams
+		if err := json.Unmarshal(*req.Params, &params); err == nil {
+			r.logs = append(r.logs, params.Message)
+		}
+	}
+}
+
+func newWorkDoneProgressTestContext(workDoneProgress bool) (*TestContext, *progressRecorder) {
+	tx := newTestContextWith(cache.Always, nil, &protocol.ClientCapabilities{
+		Window: protocol.WindowClientCapabilities{WorkDoneProgress: workDoneProgress},
+	})
+
+	client := &progressRecorder{}
+	tx.client = client
+	return tx, client
+}
+
+// loadingPackagesRegexp matches the progress of the loaded packages.
+var loadingPackagesRegexp = regexp.MustCompile(`^Loading packages… (\d+)/(\d+)`)
+
+func TestWorkDoneProgress(t *testing.T) {
+	t.Parallel()
+
+	// The progress of the initial load is reported before the response of
+	// the initialize request.
+	workDoneProgressContext.setup(t)
+
+	r := workDoneProgressClient
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.created) != 1 {
+		t.Fatalf("got %d created tokens, want 1", len(r.created))
+	}
+	if len(r.progress) < 3 {
+		t.Fatalf("got %d progress notifications, want a begin, reports and an end", len(r.progress))
+	}
+
+	var percentage float64
+	for i, params := range r.progress {
+		if params.Token != r.created[0] {
+			t.Errorf("got token %v, want %v", params.Token, r.created[0])
+		}
+		value, _ := params.Value.(map[string]interface{})
+		kind, _ := value["kind"].(string)
+		message, _ := value["message"].(string)
+
+		switch {
+		case i == 0:
+			if kind != "begin" || value["title"] != "Loading packages" {
+				t.Errorf("got first progress %v, want a begin titled Loading packages", value)
+			}
+		case i == len(r.progress)-1:
+			if kind != "end" || !strings.HasPrefix(message, "Loaded ") {
+				t.Errorf("got last progress %v, want an end of the loaded packages", value)
+			}
+		default:
+			if kind != "report" {
+				t.Errorf("got progress %v, want a report", value)
+				continue
+			}
+			m := loadingPackagesRegexp.FindStringSubmatch(message)
+			if m == nil {
+				t.Errorf("got report message %q, want the loaded packages", message)
+				continue
+			}
+			loaded, _ := strconv.Atoi(m[1])
+			total, _ := strconv.Atoi(m[2])
+			if loaded == 0 || loaded > total {
+				t.Errorf("got %d/%d loaded packages", loaded, total)
+			}
+			p, _ := value["percentage"].(float64)
+			if p < percentage || p > 100 {
+				t.Errorf("got percentage %v after %v", p, percentage)
+			}
+			percentage = p
+		}
+	}
+}
+
+func TestLogProgress(t *testing.T) {
+	t.Parallel()
+
+	logProgressContext.setup(t)
+
+	r := logProgressClient
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.created) != 0 || len(r.progress) != 0 {
+		t.Errorf("got %d created tokens and %d progress notifications, want none", len(r.created), len(r.progress))
+	}
+
+	var begun, reported, ended bool
+	for _, message := range r.logs {
+		switch {
+		case message == "Loading packages…":
+			begun = true
+		case loadingPackagesRegexp.MatchString(message):
+			reported = begun
+		case strings.HasPrefix(message, "Loaded "):
+			ended = reported
+		}
+	}
+	if !ended {
+		t.Errorf("got log messages %q, want the beginning, the progress and the end of the load", r.logs)
+	}
+}
@@ -2,6 +2,7 @@ package langserver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"path/filepath"
@@ -134,6 +135,21 @@ func TestWorkspaceReferences(t *testing.T) {
 		})
 	})
 
+	t.Run("round trip", func(t *testing.T) {
+		doWorkspaceReferencesRoundTripTest(t, "goproject/b/b.go:1:89", []string{"goproject/b/b.go:1:89-1:90"})
+		doWorkspaceReferencesRoundTripTest(t, "gomodule/a.go:1:72", []string{"gomodule/a.go:1:57-1:58", "gomodule/a.go:1:72-1:73"})
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		references, err := callWorkspaceReferences(workspaceReferencesContext.ctx, workspaceReferencesContext.conn, lspext.WorkspaceReferencesParams{Query: lspext.SymbolDescriptor{}, Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(references) != 1 {
+			t.Errorf("got %d references, want 1", len(references))
+		}
+	})
+
 	t.Run("workspace references multiple files", func(t *testing.T) {
 		test(t, map[*lspext.WorkspaceReferencesParams][]string{
 			{Query: lspext.SymbolDescriptor{}}: {
@@ -148,6 +164,55 @@ func TestWorkspaceReferences(t *testing.T) {
 	})
 }
 
+// doWorkspaceReferencesRoundTripTest looks up the symbol descriptor of
+// the definition at pos and checks that its workspace references are want.
+func doWorkspaceReferencesRoundTripTest(t *testing.T, pos string, want []string) {
+	t.Helper()
+	tx := workspaceReferencesContext
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var defs []symbolLocationInformation
+	err = tx.conn.Call(tx.ctx, "textDocument/xdefinition", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(filepath.ToSlash(tx.root())), file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].Symbol == nil {
+		t.Fatalf("got definitions %v, want a single symbol", defs)
+	}
+
+	query := lspext.SymbolDescriptor{}
+	data, err := json.Marshal(defs[0].Symbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &query); err != nil {
+		t.Fatal(err)
+	}
+
+	references, err := callWorkspaceReferences(tx.ctx, tx.conn, lspext.WorkspaceReferencesParams{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range references {
+		got = append(got, strings.Split(r, " -> ")[0])
+	}
+	for i := range want {
+		want[i] = makePath(tx.root(), want[i])
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot  %q\nwant %q", got, want)
+	}
+}
+
 type workspaceReferencesTestCase struct {
 	input  *lspext.WorkspaceReferencesParams
 	output []string
@@ -124,12 +124,34 @@ func TestWorkspaceSymbol(t *testing.T) {
 		})
 	})
 
+	t.Run("symbols of changed file", func(t *testing.T) {
+		uri := uriJoin(util.PathToURI(filepath.ToSlash(workspaceSymbolContext.root())), "symbolindex/a.go")
+		if err := workspaceSymbolContext.conn.Notify(workspaceSymbolContext.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: "package p; func Old() {}"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		test(t, map[*lspext.WorkspaceSymbolParams][]string{
+			{Query: ""}: {"symbolindex/a.go:function:Old:1:17"},
+		})
+
+		if err := workspaceSymbolContext.conn.Notify(workspaceSymbolContext.ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+			TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+			ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: "package p; func New() {}; func Newer() {}"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		test(t, map[*lspext.WorkspaceSymbolParams][]string{
+			{Query: ""}: {"symbolindex/a.go:function:New:1:17", "symbolindex/a.go:function:Newer:1:32"},
+		})
+	})
+
 	t.Run("go symbols", func(t *testing.T) {
 		test(t, map[*lspext.WorkspaceSymbolParams][]string{
 			{Query: ""}:            {"symbols/abc.go:variable:A:8:2", "symbols/abc.go:constant:B:12:2", "symbols/abc.go:class:C:17:2", "symbols/abc.go:class:T:22:6", "symbols/abc.go:interface:UVW:20:6", "symbols/abc.go:class:XYZ:3:6", "symbols/bcd.go:class:YZA:3:6", "symbols/cde.go:variable:a:4:2", "symbols/cde.go:variable:b:4:5", "symbols/cde.go:variable:c:5:2", "symbols/xyz.go:function:yza:3:6", "symbols/abc.go:method:XYZ.ABC:5:14", "symbols/bcd.go:method:YZA.BCD:5:14"},
 			{Query: "xyz"}:         {"symbols/abc.go:class:XYZ:3:6", "symbols/abc.go:method:XYZ.ABC:5:14", "symbols/xyz.go:function:yza:3:6"},
 			{Query: "yza"}:         {"symbols/bcd.go:class:YZA:3:6", "symbols/xyz.go:function:yza:3:6", "symbols/bcd.go:method:YZA.BCD:5:14"},
-			{Query: "abc"}:         {"symbols/abc.go:method:XYZ.ABC:5:14", "symbols/abc.go:variable:A:8:2", "symbols/abc.go:constant:B:12:2", "symbols/abc.go:class:C:17:2", "symbols/abc.go:class:T:22:6", "symbols/abc.go:interface:UVW:20:6", "symbols/abc.go:class:XYZ:3:6"},
+			{Query: "abc"}:         {"symbols/abc.go:method:XYZ.ABC:5:14", "symbols/abc.go:class:C:17:2", "symbols/abc.go:class:T:22:6", "symbols/abc.go:interface:UVW:20:6", "symbols/abc.go:class:XYZ:3:6", "symbols/abc.go:variable:A:8:2", "symbols/abc.go:constant:B:12:2"},
 			{Query: "bcd"}:         {"symbols/bcd.go:method:YZA.BCD:5:14", "symbols/bcd.go:class:YZA:3:6"},
 			{Query: "cde"}:         {"symbols/cde.go:variable:a:4:2", "symbols/cde.go:variable:b:4:5", "symbols/cde.go:variable:c:5:2"},
 			{Query: "is:exported"}: {"symbols/abc.go:variable:A:8:2", "symbols/abc.go:constant:B:12:2", "symbols/abc.go:class:C:17:2", "symbols/abc.go:class:T:22:6", "symbols/abc.go:interface:UVW:20:6", "symbols/abc.go:class:XYZ:3:6", "symbols/bcd.go:class:YZA:3:6", "symbols/abc.go:method:XYZ.ABC:5:14", "symbols/bcd.go:method:YZA.BCD:5:14"},
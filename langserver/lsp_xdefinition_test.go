@@ -51,6 +51,10 @@ func TestXDefinition(t *testing.T) {
 		test(t, "goroot/a.go:1:40", "goroot/src/fmt/print.go:274:6 id:fmt/-/Println name:Println package:fmt packageName:fmt recv: vendor:false")
 	})
 
+	t.Run("dot import", func(t *testing.T) {
+		test(t, "dotimport/a.go:1:123", "dotimport/d/d.go:1:17 id:github.com/saibing/bingo/langserver/test/pkg/dotimport/d/-/D name:D package:github.com/saibing/bingo/langserver/test/pkg/dotimport/d packageName:d recv: vendor:false")
+	})
+
 	t.Run("go project", func(t *testing.T) {
 		test(t, "goproject/a/a.go:1:17", "goproject/a/a.go:1:17 id:github.com/saibing/bingo/langserver/test/pkg/goproject/a/-/A name:A package:github.com/saibing/bingo/langserver/test/pkg/goproject/a packageName:a recv: vendor:false")
 		test(t, "goproject/b/b.go:1:89", "goproject/a/a.go:1:17 id:github.com/saibing/bingo/langserver/test/pkg/goproject/a/-/A name:A package:github.com/saibing/bingo/langserver/test/pkg/goproject/a packageName:a recv: vendor:false")
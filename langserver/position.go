@@ -5,9 +5,14 @@
 package langserver
 
 import (
+	"bytes"
+	"context"
+	"go/ast"
 	"go/token"
 	"net/url"
+	"unicode/utf8"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
 )
@@ -35,6 +40,10 @@ func fromProtocolRange(f *token.File, r lsp.Range) span.Range {
 		end = token.NoPos
 	default:
 		end = fromProtocolPosition(f, r.End)
+		if !end.IsValid() {
+			// Ranges may extend past the end of the file.
+			end = token.Pos(f.Base() + f.Size())
+		}
 	}
 	return span.Range{
 		Start: start,
@@ -43,11 +52,48 @@ func fromProtocolRange(f *token.File, r lsp.Range) span.Range {
 }
 
 // fromProtocolPosition converts a protocol position (0-based line and column
-// number) to a token.Pos (byte offset value).
+// number) to a token.Pos (byte offset value). The character offset of pos
+// counts bytes: the positions of the client are converted with
+// fromUTF16Position first.
 // It requires the token file the pos belongs to in order to do this.
 func fromProtocolPosition(f *token.File, pos lsp.Position) token.Pos {
 	line := lineStart(f, int(pos.Line)+1)
-	return line + token.Pos(pos.Character) // TODO: this is wrong, bytes not characters
+	if !line.IsValid() || pos.Character < 0 {
+		return token.NoPos
+	}
+	p := line + token.Pos(pos.Character)
+	if int(p) > f.Base()+f.Size() {
+		// Past the end of the last line.
+		return token.NoPos
+	}
+	return p
+}
+
+// fromAdjustedPosition converts a protocol position in the original source
+// of a file generated with //line directives, such as the output of cgo,
+// to a token.Pos in the generated file. It returns NoPos if there is no
+// identifier at the position.
+func fromAdjustedPosition(fset *token.FileSet, file *ast.File, pos lsp.Position) token.Pos {
+	line, column := int(pos.Line)+1, int(pos.Character)+1
+	result := token.NoPos
+	ast.Inspect(file, func(n ast.Node) bool {
+		if result.IsValid() {
+			return false
+		}
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		p := fset.Position(ident.Pos())
+		if p.Line == line && p.Column <= column && column <= p.Column+len(ident.Name) {
+			result = ident.Pos() + token.Pos(column-p.Column)
+			if result > ident.End() {
+				result = ident.End()
+			}
+		}
+		return true
+	})
+	return result
 }
 
 // toProtocolPosition converts from a token pos (byte offset) to a protocol
@@ -73,7 +119,9 @@ func lineStart(f *token.File, line int) token.Pos {
 	for {
 		offset := (min + max) / 2
 		pos := f.Pos(offset)
-		posn := f.Position(pos)
+		// The lines of the protocol are those of the file itself, not
+		// those its //line directives refer to.
+		posn := f.PositionFor(pos, false)
 		if posn.Line == line {
 			return pos - (token.Pos(posn.Column) - 1)
 		}
@@ -89,3 +137,146 @@ func lineStart(f *token.File, line int) token.Pos {
 		}
 	}
 }
+
+// fromUTF16Position converts a protocol position, whose character offset
+// counts UTF-16 code units, to a position whose character offset counts
+// the bytes of its line in content.
+func fromUTF16Position(content []byte, pos lsp.Position) lsp.Position {
+	line, ok := lineContent(content, pos.Line)
+	if !ok {
+		return pos
+	}
+
+	offset, units := 0, 0
+	for offset < len(line) && units < pos.Character {
+		r, size := utf8.DecodeRune(line[offset:])
+		units += utf16Len(r)
+		offset += size
+	}
+	pos.Character = offset + (pos.Character - units)
+	return pos
+}
+
+// toUTF16Position converts a position whose character offset counts the
+// bytes of its line in content to a protocol position, whose character
+// offset counts UTF-16 code units.
+func toUTF16Position(content []byte, pos lsp.Position) lsp.Position {
+	line, ok := lineContent(content, pos.Line)
+	if !ok {
+		return pos
+	}
+
+	offset, units := 0, 0
+	for offset < len(line) && offset < pos.Character {
+		r, size := utf8.DecodeRune(line[offset:])
+		units += utf16Len(r)
+		offset += size
+	}
+	pos.Character = units + (pos.Character - offset)
+	return pos
+}
+
+// fromUTF16Range converts both positions of r with fromUTF16Position.
+func fromUTF16Range(content []byte, r lsp.Range) lsp.Range {
+	return lsp.Range{
+		Start: fromUTF16Position(content, r.Start),
+		End:   fromUTF16Position(content, r.End),
+	}
+}
+
+// toUTF16Range converts both positions of r with toUTF16Position.
+func toUTF16Range(content []byte, r lsp.Range) lsp.Range {
+	return lsp.Range{
+		Start: toUTF16Position(content, r.Start),
+		End:   toUTF16Position(content, r.End),
+	}
+}
+
+// utf16Ranges returns the function converting a range of a document, whose
+// character offsets count bytes, to a protocol range, whose character
+// offsets count UTF-16 code units. The content of each document is read
+// once.
+func (h *LangHandler) utf16Ranges(ctx context.Context) func(uri lsp.DocumentURI, r lsp.Range) lsp.Range {
+	contents := make(map[lsp.DocumentURI][]byte)
+	return func(uri lsp.DocumentURI, r lsp.Range) lsp.Range {
+		content, ok := contents[uri]
+		if !ok {
+			content, _ = h.project.FileContent(ctx, uri)
+			contents[uri] = content
+		}
+		if content == nil {
+			return r
+		}
+		return toUTF16Range(content, r)
+	}
+}
+
+// utf16Location converts the range of loc with toUTF16.
+func utf16Location(toUTF16 func(uri lsp.DocumentURI, r lsp.Range) lsp.Range, loc lsp.Location) lsp.Location {
+	loc.Range = toUTF16(loc.URI, loc.Range)
+	return loc
+}
+
+// utf16Locations converts the ranges of locs with toUTF16, in place.
+func utf16Locations(toUTF16 func(uri lsp.DocumentURI, r lsp.Range) lsp.Range, locs []lsp.Location) []lsp.Location {
+	for i := range locs {
+		locs[i] = utf16Location(toUTF16, locs[i])
+	}
+	return locs
+}
+
+// utf16Edits converts the ranges of the edits of changes, by document URI,
+// with toUTF16, in place.
+func utf16Edits(toUTF16 func(uri lsp.DocumentURI, r lsp.Range) lsp.Range, changes map[string][]lsp.TextEdit) map[string][]lsp.TextEdit {
+	for uri, edits := range changes {
+		for i := range edits {
+			edits[i].Range = toUTF16(lsp.DocumentURI(uri), edits[i].Range)
+		}
+	}
+	return changes
+}
+
+// utf16LocationLink converts the ranges of link with toUTF16. Its origin
+// range is a range of the document originURI.
+func utf16LocationLink(toUTF16 func(uri lsp.DocumentURI, r lsp.Range) lsp.Range, originURI lsp.DocumentURI, link protocol.LocationLink) protocol.LocationLink {
+	if link.OriginSelectionRange != nil {
+		origin := toUTF16(originURI, *link.OriginSelectionRange)
+		link.OriginSelectionRange = &origin
+	}
+	link.TargetRange = toUTF16(link.TargetURI, link.TargetRange)
+	link.TargetSelectionRange = toUTF16(link.TargetURI, link.TargetSelectionRange)
+	return link
+}
+
+// lineContent returns the 0-based line of content, without its line
+// terminator.
+func lineContent(content []byte, line int) ([]byte, bool) {
+	for ; line > 0; line-- {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			return nil, false
+		}
+		content = content[i+1:]
+	}
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		content = content[:i]
+	}
+	return content, true
+}
+
+// utf16Count returns the number of UTF-16 code units encoding s.
+func utf16Count(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
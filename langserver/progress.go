@@ -0,0 +1,30 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// partialResultToken returns the partialResultToken of the params of req,
+// or nil if the client did not ask for partial results.
+func partialResultToken(req *jsonrpc2.Request) protocol.ProgressToken {
+	if req == nil || req.Params == nil {
+		return nil
+	}
+
+	var params protocol.PartialResultParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil
+	}
+	return params.PartialResultToken
+}
+
+// sendPartialResult sends a batch of results of the request identified by
+// token to the client in a $/progress notification. The client appends the
+// batches in the order they are received.
+func (h *LangHandler) sendPartialResult(ctx context.Context, conn jsonrpc2.JSONRPC2, token protocol.ProgressToken, batch interface{}) error {
+	return conn.Notify(ctx, "$/progress", protocol.ProgressParams{Token: token, Value: h.symlinks.outgoing(batch)})
+}
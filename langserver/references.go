@@ -7,31 +7,45 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"sort"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (h *LangHandler) handleTextDocumentReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ReferenceParams) ([]lsp.Location, error) {
-	locs, err := h.doHandleTextDocumentReferences(ctx, conn, req, params)
-	if err != nil {
+	// Other requests, like rename, need the complete result.
+	var resultToken protocol.ProgressToken
+	if req != nil && req.Method == "textDocument/references" {
+		resultToken = partialResultToken(req)
+	}
+
+	locs, err := h.doHandleTextDocumentReferences(ctx, conn, resultToken, params)
+	if isEmptyResult(err) && ctx.Err() == nil && params.Position.Character > 0 {
+		// The cursor may be right after the identifier. No references were
+		// searched for, let alone sent, so the search starts afresh.
 		// fix https://github.com/saibing/bingo/issues/32
 		params.Position.Character--
-		locs, err = h.doHandleTextDocumentReferences(ctx, conn, req, params)
+		locs, err = h.doHandleTextDocumentReferences(ctx, conn, resultToken, params)
+	}
+	if isEmptyResult(err) {
+		// Invalid nodes means we tried to click on something which is
+		// not an ident (eg comment/string/etc). Return no information.
+		return []lsp.Location{}, nil
 	}
 	return locs, err
 }
 
-func (h *LangHandler) doHandleTextDocumentReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ReferenceParams) ([]lsp.Location, error) {
+// doHandleTextDocumentReferences returns the references of the object at
+// the position of params. If resultToken is not nil, the references are
+// sent to the client in batches as each package is searched, and the
+// returned result is empty.
+func (h *LangHandler) doHandleTextDocumentReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, resultToken protocol.ProgressToken, params lsp.ReferenceParams) ([]lsp.Location, error) {
 	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
 	if err != nil {
-		// Invalid nodes means we tried to click on something which is
-		// not an ident (eg comment/string/etc). Return no information.
-		if _, ok := err.(*source.InvalidNodeError); ok {
-			return []lsp.Location{}, nil
-		}
 		return nil, err
 	}
 
@@ -64,64 +78,171 @@ func (h *LangHandler) doHandleTextDocumentReferences(ctx context.Context, conn j
 		}
 	}
 
-	refs, err := h.findReferences(ctx, obj)
+	// The other declarations, like the ones of the test variants of the
+	// package, are found along with the references.
+	includeDecl := params.Context.IncludeDeclaration
+	var decl []*ast.Ident
+	if includeDecl {
+		decl = append(decl, &ast.Ident{NamePos: obj.Pos(), Name: obj.Name()})
+	}
+
+	fset := pkg.GetFileSet()
+	toUTF16 := h.utf16Ranges(ctx)
+	maxRefs := h.getConfig().MaxReferences
+	if resultToken != nil {
+		// Stop searching as soon as the client has enough locations.
+		limit := maxRefs
+		if xlimit := params.Context.XLimit; xlimit > 0 && (limit == 0 || xlimit < limit) {
+			limit = xlimit
+		}
+		truncated, err := h.searchReferences(ctx, fset, obj, decl, includeDecl, limit, func(locs []lsp.Location) error {
+			return h.sendPartialResult(ctx, conn, resultToken, utf16Locations(toUTF16, locs))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if truncated && limit == maxRefs {
+			h.notifyReferencesTruncated(maxRefs)
+		}
+		return []lsp.Location{}, nil
+	}
+
+	locs := []lsp.Location{}
+	truncated, err := h.searchReferences(ctx, fset, obj, decl, includeDecl, maxRefs, func(batch []lsp.Location) error {
+		locs = append(locs, utf16Locations(toUTF16, batch)...)
+		return nil
+	})
 	if err != nil {
 		// If we are canceled, cancel loop early
 		return nil, err
 	}
-
-	if params.Context.IncludeDeclaration {
-		refs = append(refs, &ast.Ident{NamePos: obj.Pos(), Name: obj.Name()})
+	if truncated {
+		h.notifyReferencesTruncated(maxRefs)
 	}
 
-	return refStreamAndCollect(pkg.GetFileSet(), refs, params.Context.XLimit), nil
+	sort.Slice(locs, func(i, j int) bool {
+		return lessLocation(locs[i], locs[j])
+	})
+	if limit := params.Context.XLimit; limit > 0 && limit < len(locs) {
+		locs = locs[:limit]
+	}
+	return locs, nil
 }
 
-// refStreamAndCollect returns all refs read in from chan until it is
-// closed. While it is reading, it will also occasionally stream out updates of
-// the refs received so far.
-func refStreamAndCollect(fset *token.FileSet, refs []*ast.Ident, limit int) []lsp.Location {
-	if limit == 0 {
-		// If we don't have a limit, just set it to a value we should never exceed
-		limit = len(refs)
+// errReferencesTruncated stops the search for references once the maximum
+// number of locations is found.
+var errReferencesTruncated = errors.New("too many references")
+
+// searchReferences calls emit with the new locations of the declaration
+// decl, if any, and then of the references to obj found in each package.
+// If max is not 0, the search stops once max locations are emitted and
+// truncated reports whether some locations were left out.
+func (h *LangHandler) searchReferences(ctx context.Context, fset *token.FileSet, obj types.Object, decl []*ast.Ident, includeDecl bool, max int, emit func(locs []lsp.Location) error) (truncated bool, err error) {
+	c := newLocationCollector(fset)
+	found := 0
+	collect := func(refs []*ast.Ident) error {
+		locs := c.collect(refs)
+		if max > 0 && found+len(locs) > max {
+			locs = locs[:max-found]
+			truncated = true
+		}
+		found += len(locs)
+		if len(locs) > 0 {
+			if err := emit(locs); err != nil {
+				return err
+			}
+		}
+		if truncated {
+			return errReferencesTruncated
+		}
+		return nil
 	}
 
-	l := len(refs)
-	if limit < l {
-		l = limit
+	if err := collect(decl); err != nil && err != errReferencesTruncated {
+		return false, err
+	}
+	if truncated {
+		return true, nil
 	}
 
-	var locs []lsp.Location
+	err = h.findReferences(ctx, obj, includeDecl, func(pkg source.Package, refs []*ast.Ident) error {
+		return collect(refs)
+	})
+	if err == errReferencesTruncated {
+		err = nil
+	}
+	return truncated, err
+}
 
-	seen := map[string]bool{}
-	for i := 0; i < l; i++ {
-		n := refs[i]
-		loc := goRangeToLSPLocation(fset, n.Pos(), n.Name)
+// notifyReferencesTruncated warns the user that only the first max
+// references are returned.
+func (h *LangHandler) notifyReferencesTruncated(max int) {
+	h.notifyWarning(fmt.Sprintf("Too many references, only the first %d are returned. Increase maxReferences to see more.", max))
+}
+
+// locationCollector converts identifiers to locations, skipping the
+// locations it has already returned.
+type locationCollector struct {
+	fset *token.FileSet
+	seen map[string]bool
+}
+
+func newLocationCollector(fset *token.FileSet) *locationCollector {
+	return &locationCollector{fset: fset, seen: map[string]bool{}}
+}
+
+// collect returns the new locations of refs, ordered by file and offset.
+func (c *locationCollector) collect(refs []*ast.Ident) []lsp.Location {
+	var locs []lsp.Location
+	for _, n := range refs {
+		loc := goRangeToLSPLocation(c.fset, n.Pos(), n.Name)
 		if loc.URI == "" {
 			continue
 		}
 
 		// remove duplicate results because they contain uses of the xtest package
 		locStr := formatLocation(loc)
-		if seen[locStr] {
+		if c.seen[locStr] {
 			continue
 		}
-		seen[locStr] = true
+		c.seen[locStr] = true
 		locs = append(locs, loc)
 	}
 
+	sort.Slice(locs, func(i, j int) bool {
+		return lessLocation(locs[i], locs[j])
+	})
 	return locs
 }
 
+func lessLocation(a, b lsp.Location) bool {
+	if a.URI != b.URI {
+		return a.URI < b.URI
+	}
+	if a.Range.Start.Line != b.Range.Start.Line {
+		return a.Range.Start.Line < b.Range.Start.Line
+	}
+	return a.Range.Start.Character < b.Range.Start.Character
+}
+
 func formatLocation(loc lsp.Location) string {
 	return fmt.Sprintf("%s:%s", loc.URI, loc.Range)
 }
 
-// findReferences will find all references to obj. It will only return
-// references from packages in pkg.Imports.
-func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object) ([]*ast.Ident, error) {
-	// Bail out early if the context is canceled
-	var refs []*ast.Ident
+// findReferences will find all references to obj. Exported objects are
+// searched in all packages importing the package of obj, unexported ones
+// only in the package of obj and its test variants. batch is called with
+// each package and the references found in it. If includeDecl is set, the
+// declarations of obj, and of the methods related to it through
+// interfaces, are passed to batch as well.
+func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object, includeDecl bool, batch func(pkg source.Package, refs []*ast.Ident) error) error {
+	return h.findRelatedReferences(ctx, queryObj, includeDecl, h.getConfig().InterfaceReferences, batch)
+}
+
+// findRelatedReferences is like findReferences, but the references of the
+// methods related to a queried method through interfaces are found if
+// interfaces is set, rather than if the InterfaceReferences option is.
+func (h *LangHandler) findRelatedReferences(ctx context.Context, queryObj types.Object, includeDecl, interfaces bool, batch func(pkg source.Package, refs []*ast.Ident) error) error {
 	var defPkgPath string
 	if queryObj.Pkg() != nil {
 		defPkgPath = queryObj.Pkg().Path()
@@ -129,53 +250,122 @@ func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object)
 		defPkgPath = cache.BuiltinPkg
 	}
 
+	queryMethod, _ := queryObj.(*types.Func)
+	interfaceRefs := interfaces && queryMethod != nil && queryMethod.Type().(*types.Signature).Recv() != nil
+	related := make(map[*types.Func]bool)
+	isQueryObj := func(fset *token.FileSet, obj types.Object) bool {
+		if sameObj(fset, queryObj, obj) {
+			return true
+		}
+
+		m, ok := obj.(*types.Func)
+		if !ok || !interfaceRefs || m.Name() != queryMethod.Name() {
+			return false
+		}
+		isRelated, ok := related[m]
+		if !ok {
+			isRelated = relatedMethods(queryMethod, m)
+			related[m] = isRelated
+		}
+		return isRelated
+	}
+
 	f := func(pkg source.Package) error {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
-		if defPkgPath != cache.BuiltinPkg {
-			if p := pkg.GetImport(defPkgPath); p == nil && pkg.GetPkgPath() != defPkgPath {
-				return nil
-			}
-		}
-
 		if pkg.GetTypesInfo() == nil {
 			return nil
 		}
 
+		// Keys of struct literals are recorded as uses of their fields,
+		// and the selectors of promoted fields and methods as uses of
+		// the objects they select.
+		fset := pkg.GetFileSet()
+		var pkgRefs []*ast.Ident
 		for id, obj := range pkg.GetTypesInfo().Uses {
-			if sameObj(queryObj, obj) {
-				refs = append(refs, id)
+			if isQueryObj(fset, obj) {
+				pkgRefs = append(pkgRefs, id)
 			}
 		}
 
-		return nil
+		if includeDecl {
+			for id, obj := range pkg.GetTypesInfo().Defs {
+				if obj != nil && isQueryObj(fset, obj) {
+					pkgRefs = append(pkgRefs, id)
+				}
+			}
+		}
+
+		return batch(pkg, pkgRefs)
 	}
 
-	err := h.project.Search(f)
-	if err != nil {
-		return nil, err
+	if defPkgPath == cache.BuiltinPkg || interfaceRefs {
+		return h.project.Search(f)
 	}
+	return h.project.SearchReferrers(ctx, defPkgPath, queryObj.Exported(), f)
+}
 
-	return refs, nil
+// relatedMethods reports whether one of the methods x and y is an
+// interface method and the receiver type of the other one implements its
+// interface.
+func relatedMethods(x, y *types.Func) bool {
+	xIface, xRecv := methodReceiver(x)
+	yIface, yRecv := methodReceiver(y)
+	switch {
+	case xIface != nil && yIface == nil:
+		return implementsInterface(yRecv, xIface)
+	case xIface == nil && yIface != nil:
+		return implementsInterface(xRecv, yIface)
+	}
+	return false
+}
+
+// methodReceiver returns the receiver type of the method fn, along with its
+// interface if fn is an interface method.
+func methodReceiver(fn *types.Func) (*types.Interface, types.Type) {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil, nil
+	}
+	iface, _ := recv.Type().Underlying().(*types.Interface)
+	return iface, recv.Type()
 }
 
-// same reports whether x and y are identical, or both are PkgNames
-// that import the same Package.
-func sameObj(x, y types.Object) bool {
+// implementsInterface reports whether the value or pointer type of the
+// receiver type recv implements iface.
+func implementsInterface(recv types.Type, iface *types.Interface) bool {
+	if recv == nil || iface.Empty() {
+		return false
+	}
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	if m, _ := types.MissingMethod(recv, iface, true); m == nil {
+		return true
+	}
+	return types.Implements(types.NewPointer(recv), iface)
+}
+
+// sameObj reports whether x and y are identical, or are declared at the
+// same offset of the same file, as the objects of the test variants of a
+// package are, or are both PkgNames that import the same Package. The
+// positions of x and y are those of fset.
+func sameObj(fset *token.FileSet, x, y types.Object) bool {
 	if x == y {
 		return true
 	}
 
 	if x.Pkg() != nil &&
 		y.Pkg() != nil &&
-		x.Pkg().Path() == y.Pkg().Path() &&
 		x.Name() == y.Name() &&
-		x.Exported() &&
-		y.Exported() {
-		// enable find the xtest pakcage's uses, but this will product some duplicate results
-		return true
+		x.Pos().IsValid() &&
+		y.Pos().IsValid() {
+		xPos, yPos := fset.Position(x.Pos()), fset.Position(y.Pos())
+		if xPos.Filename == yPos.Filename && xPos.Offset == yPos.Offset {
+			return true
+		}
 	}
 
 	// builtin package symbol
@@ -2,44 +2,290 @@ package langserver
 
 import (
 	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// handleRename renames the object at the position of params and all its
+// references. Exported objects are renamed in every package of the
+// workspace importing them, unexported ones only in their package. The
+// rename fails as a whole if it would make a declaration collide with
+// another one, or a reference refer to another object. Renaming the name
+// of a package clause renames the package, see renamePackage.
+//
+// Renaming a method related to interfaces renames all of them: the
+// interface methods it implements or declares, along with all their other
+// implementations, since renaming only one of them would break the
+// assignments of its type to the interfaces. Interfaces declared in the
+// module cache or the standard library, like fmt.Stringer, are left out:
+// renaming their methods is rejected, so types just stop implementing them.
 func (h *LangHandler) handleRename(ctx context.Context, conn jsonrpc2.JSONRPC2,
-	req *jsonrpc2.Request, params lsp.RenameParams) (lsp.WorkspaceEdit, error) {
-	rp := lsp.ReferenceParams{
-		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
-			TextDocument: params.TextDocument,
-			Position:     params.Position,
-		},
-		Context: lsp.ReferenceContext{
-			IncludeDeclaration: true,
-			XLimit:             0,
-		},
-	}
-
-	references, err := h.handleTextDocumentReferences(ctx, conn, req, rp)
+	req *jsonrpc2.Request, params lsp.RenameParams) (protocol.WorkspaceEdit, error) {
+	if !isIdentifier(params.NewName) {
+		return protocol.WorkspaceEdit{}, renameError("%q is not a valid identifier", params.NewName)
+	}
+
+	target, err := h.findRenameTarget(ctx, lsp.TextDocumentPositionParams{TextDocument: params.TextDocument, Position: params.Position})
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	if target.obj == nil {
+		return h.renamePackage(ctx, target.pkg, params.NewName)
+	}
+
+	objs := []types.Object{target.obj}
+	fset := target.pkg.GetFileSet()
+	if fn, ok := target.obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+		if objs, err = h.relatedMethodGroup(ctx, fset, fn); err != nil {
+			return protocol.WorkspaceEdit{}, err
+		}
+	}
+
+	c := newLocationCollector(fset)
+	checker := newRenameChecker(fset, params.NewName)
+	var locs []lsp.Location
+	for _, obj := range objs {
+		locs = append(locs, c.collect([]*ast.Ident{{NamePos: obj.Pos(), Name: obj.Name()}})...)
+		err := h.findReferences(ctx, obj, true, func(pkg source.Package, refs []*ast.Ident) error {
+			checker.checkRefs(pkg, refs)
+			locs = append(locs, c.collect(refs)...)
+			return nil
+		})
+		if err != nil {
+			return protocol.WorkspaceEdit{}, err
+		}
+	}
+
+	// A rename changing the meaning of the code is not done at all.
+	if err := checker.err(); err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+
+	sort.Slice(locs, func(i, j int) bool {
+		return lessLocation(locs[i], locs[j])
+	})
+	changes := make(map[string][]lsp.TextEdit)
+	for _, loc := range locs {
+		uri := string(loc.URI)
+		changes[uri] = append(changes[uri], lsp.TextEdit{Range: loc.Range, NewText: params.NewName})
+	}
+	return h.workspaceEdit(utf16Edits(h.utf16Ranges(ctx), changes)), nil
+}
+
+// handlePrepareRename returns the range and the name of the identifier at
+// the position of params if it can be renamed. It fails with the same
+// errors as handleRename otherwise.
+func (h *LangHandler) handlePrepareRename(ctx context.Context, conn jsonrpc2.JSONRPC2,
+	req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (*protocol.PrepareRenameResult, error) {
+	target, err := h.findRenameTarget(ctx, params)
 	if err != nil {
-		return lsp.WorkspaceEdit{}, err
+		return nil, err
+	}
+
+	// The character offsets of the client count UTF-16 code units.
+	r := rangeForNode(target.pkg.GetFileSet(), target.ident)
+	if content, err := h.project.FileContent(ctx, params.TextDocument.URI); err == nil {
+		r = toUTF16Range(content, r)
 	}
+	return &protocol.PrepareRenameResult{Range: r, Placeholder: target.ident.Name}, nil
+}
+
+// renameTarget is the identifier to rename and the object it declares or
+// uses. The object is nil if the identifier is the name of the package
+// clause.
+type renameTarget struct {
+	pkg   source.Package
+	ident *ast.Ident
+	obj   types.Object
+}
+
+// findRenameTarget returns the target of a rename at the position of
+// params, or an error with a message meant to be shown to the user if
+// there is nothing to rename there.
+func (h *LangHandler) findRenameTarget(ctx context.Context, params lsp.TextDocumentPositionParams) (*renameTarget, error) {
+	target, err := h.doFindRenameTarget(ctx, params)
+	if err != nil && params.Position.Character > 0 {
+		if _, ok := err.(*jsonrpc2.Error); !ok {
+			// The cursor may be right after an identifier.
+			params.Position.Character--
+			target, err = h.doFindRenameTarget(ctx, params)
+		}
+	}
+	if isEmptyResult(err) {
+		return nil, renameError("no identifier found to rename")
+	}
+	return target, err
+}
 
-	result := lsp.WorkspaceEdit{}
-	if result.Changes == nil {
-		result.Changes = make(map[string][]lsp.TextEdit)
+func (h *LangHandler) doFindRenameTarget(ctx context.Context, params lsp.TextDocumentPositionParams) (*renameTarget, error) {
+	pkg, pathNodes, err := h.pathNodesAt(ctx, params)
+	if err != nil {
+		return nil, err
 	}
-	for _, ref := range references {
-		edit := lsp.TextEdit{
-			Range:   ref.Range,
-			NewText: params.NewName,
+
+	if _, ok := pathNodes[0].(*ast.BasicLit); ok && len(pathNodes) > 1 {
+		if _, ok := pathNodes[1].(*ast.ImportSpec); ok {
+			return nil, renameError("cannot rename import path")
 		}
-		edits := result.Changes[string(ref.URI)]
-		if edits == nil {
-			edits = []lsp.TextEdit{}
+	}
+
+	ident, err := identFromPathNodes(pkg, pathNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pathNodes) > 1 {
+		if file, ok := pathNodes[1].(*ast.File); ok && file.Name == ident {
+			filename := pkg.GetFileSet().Position(ident.Pos()).Filename
+			if err := checkRenameFile(filename, "package "+ident.Name); err != nil {
+				return nil, err
+			}
+			return &renameTarget{pkg: pkg, ident: ident}, nil
 		}
-		edits = append(edits, edit)
-		result.Changes[string(ref.URI)] = edits
 	}
-	return result, nil
+
+	obj, err := renamedObject(pkg, pathNodes, ident)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRenameLocation(pkg.GetFileSet(), obj); err != nil {
+		return nil, err
+	}
+	return &renameTarget{pkg: pkg, ident: ident, obj: obj}, nil
+}
+
+// renamedObject returns the object declared or used by ident, or an error
+// if it cannot be renamed.
+func renamedObject(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) (types.Object, error) {
+	obj := source.FindIdentObject(pkg, ident)
+	if obj == nil {
+		obj = compositeLitField(pkg, pathNodes, ident)
+	}
+	switch obj := obj.(type) {
+	case nil:
+		return nil, renameError("no object found to rename for %s", ident.Name)
+	case *types.PkgName:
+		return nil, renameError("cannot rename the package name %s, rename its package clause instead", obj.Name())
+	case *types.Label:
+		return nil, renameError("renaming labels is not supported")
+	}
+
+	if obj.Pkg() == nil {
+		return nil, renameError("cannot rename builtin %s", obj.Name())
+	}
+	if isCgoObject(pkg, obj) {
+		return nil, renameError("cannot rename the cgo symbol %s", obj.Name())
+	}
+	return obj, nil
+}
+
+// checkRenameLocation returns an error if obj is declared in a file the
+// user is not supposed to edit.
+func checkRenameLocation(fset *token.FileSet, obj types.Object) error {
+	return checkRenameFile(fset.Position(obj.Pos()).Filename, obj.Name())
+}
+
+// checkRenameFile returns an error if the file filename, declaring name,
+// is not supposed to be edited by the user.
+func checkRenameFile(filename, name string) error {
+	switch {
+	case cache.IsInModuleCache(filename):
+		return renameError("cannot rename symbol in dependency: %s is declared in the module cache", name)
+	case cache.IsInGoroot(filename):
+		return renameError("cannot rename symbol in dependency: %s is declared in the standard library", name)
+	}
+	return nil
+}
+
+// relatedMethodGroup returns the method fn along with the methods related
+// to it through interfaces, transitively: the interface methods it
+// implements or declares, and their other implementations. The
+// implementations which cannot be renamed are skipped, but fn cannot be
+// renamed if it implements an exported interface which cannot be.
+func (h *LangHandler) relatedMethodGroup(ctx context.Context, fset *token.FileSet, fn *types.Func) ([]types.Object, error) {
+	var candidates []*types.Func
+	err := h.project.Search(func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pkg.GetTypesInfo() == nil {
+			return nil
+		}
+		for _, obj := range pkg.GetTypesInfo().Defs {
+			m, ok := obj.(*types.Func)
+			if ok && m.Name() == fn.Name() && m.Type().(*types.Signature).Recv() != nil {
+				candidates = append(candidates, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	group := []types.Object{fn}
+	inGroup := make([]bool, len(candidates))
+	for changed := true; changed; {
+		changed = false
+		for i, m := range candidates {
+			if inGroup[i] || !relatedToGroup(fset, group, m) {
+				continue
+			}
+			inGroup[i] = true
+			if checkRenameLocation(fset, m) != nil {
+				// Only the exported interfaces of the dependencies can be
+				// implemented by the types of the workspace on purpose.
+				if iface, recv := methodReceiver(m); iface != nil {
+					if named, ok := recv.(*types.Named); ok && named.Obj().Exported() {
+						return nil, checkRenameFile(fset.Position(m.Pos()).Filename, types.TypeString(recv, (*types.Package).Name))
+					}
+				}
+				continue
+			}
+			changed = true
+			group = append(group, m)
+		}
+	}
+	return group, nil
+}
+
+// relatedToGroup reports whether the method m is one of the methods of
+// group, or is related to one of them through an interface.
+func relatedToGroup(fset *token.FileSet, group []types.Object, m *types.Func) bool {
+	for _, g := range group {
+		g := g.(*types.Func)
+		if sameObj(fset, g, m) || relatedMethods(g, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdentifier reports whether name is a valid Go identifier.
+func isIdentifier(name string) bool {
+	if name == "" || token.Lookup(name).IsKeyword() {
+		return false
+	}
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// renameError returns the error of a rename request which cannot be done,
+// with a message meant to be shown to the user.
+func renameError(format string, args ...interface{}) error {
+	return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
 }
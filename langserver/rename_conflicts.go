@@ -0,0 +1,196 @@
+package langserver
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+)
+
+// renameConflict is a declaration or a reference which would change
+// meaning if an object was renamed.
+type renameConflict struct {
+	pos token.Pos
+	msg string
+}
+
+// renameChecker finds the conflicts of the renaming of objects to newName.
+// The objects are checked along with their references, package by package.
+type renameChecker struct {
+	fset      *token.FileSet
+	newName   string
+	checked   map[types.Object]bool
+	conflicts []renameConflict
+}
+
+func newRenameChecker(fset *token.FileSet, newName string) *renameChecker {
+	return &renameChecker{fset: fset, newName: newName, checked: make(map[types.Object]bool)}
+}
+
+func (c *renameChecker) conflict(pos token.Pos, format string, args ...interface{}) {
+	c.conflicts = append(c.conflicts, renameConflict{pos: pos, msg: fmt.Sprintf(format, args...)})
+}
+
+// err returns the error of the first conflict, by position, or nil if
+// there is none.
+func (c *renameChecker) err() error {
+	if len(c.conflicts) == 0 {
+		return nil
+	}
+
+	sort.Slice(c.conflicts, func(i, j int) bool {
+		pi, pj := c.fset.Position(c.conflicts[i].pos), c.fset.Position(c.conflicts[j].pos)
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	first := c.conflicts[0]
+	return renameError("%s: %s", c.fset.Position(first.pos), first.msg)
+}
+
+// checkRefs checks the identifiers refs of pkg, which declare or refer to
+// the renamed objects.
+func (c *renameChecker) checkRefs(pkg source.Package, refs []*ast.Ident) {
+	info, tpkg := pkg.GetTypesInfo(), pkg.GetTypes()
+	if info == nil || tpkg == nil {
+		return
+	}
+
+	for _, id := range refs {
+		obj := info.ObjectOf(id)
+		if obj == nil {
+			continue
+		}
+		if !c.checked[obj] {
+			c.checked[obj] = true
+			c.checkObject(pkg, obj)
+		}
+
+		// Fields and methods are selected, and objects of other packages
+		// are qualified, so they can't be shadowed.
+		if _, isDef := info.Defs[id]; isDef || obj.Parent() == nil || obj.Pkg() == nil || obj.Pkg().Path() != tpkg.Path() {
+			continue
+		}
+
+		// The reference must not refer to another object declared
+		// between it and the declaration of obj.
+		scope := tpkg.Scope().Innermost(id.Pos())
+		if scope == nil {
+			continue
+		}
+		if s, other := scope.LookupParent(c.newName, id.Pos()); other != nil && encloses(obj.Parent(), s) {
+			c.conflict(id.Pos(), "the reference to %s would refer to %s declared at %s", obj.Name(), c.newName, c.fset.Position(other.Pos()))
+		}
+	}
+}
+
+// checkObject checks the declaration of obj in pkg.
+func (c *renameChecker) checkObject(pkg source.Package, obj types.Object) {
+	switch {
+	case obj.Parent() != nil:
+		c.checkScoped(pkg, obj)
+	case isMethod(obj):
+		recv := obj.Type().(*types.Signature).Recv().Type()
+		if other, _, _ := types.LookupFieldOrMethod(recv, true, obj.Pkg(), c.newName); other != nil {
+			c.conflict(obj.Pos(), "method %s conflicts with %s declared at %s", obj.Name(), c.newName, c.fset.Position(other.Pos()))
+		}
+	case isField(obj):
+		c.checkField(pkg, obj.(*types.Var))
+	}
+}
+
+// checkScoped checks the declaration of the object obj declared in a
+// scope: newName must not be declared in the same scope, nor refer to an
+// object of an enclosing scope inside of the scope of obj.
+func (c *renameChecker) checkScoped(pkg source.Package, obj types.Object) {
+	parent := obj.Parent()
+	if other := parent.Lookup(c.newName); other != nil {
+		c.conflict(obj.Pos(), "%s conflicts with %s declared at %s", obj.Name(), c.newName, c.fset.Position(other.Pos()))
+	}
+
+	tpkg := pkg.GetTypes()
+	atPackageLevel := parent == tpkg.Scope()
+	if atPackageLevel {
+		// The names of the imports are declared in the file scopes.
+		for i := 0; i < parent.NumChildren(); i++ {
+			if other := parent.Child(i).Lookup(c.newName); other != nil {
+				c.conflict(obj.Pos(), "%s conflicts with the import %s at %s", obj.Name(), c.newName, c.fset.Position(other.Pos()))
+			}
+		}
+	}
+
+	for id, other := range pkg.GetTypesInfo().Uses {
+		if id.Name != c.newName || other.Parent() == nil || other.Parent() == parent || !encloses(other.Parent(), parent) {
+			continue
+		}
+		if !atPackageLevel && id.Pos() < obj.Pos() {
+			continue
+		}
+		if scope := tpkg.Scope().Innermost(id.Pos()); scope != nil && encloses(parent, scope) {
+			c.conflict(id.Pos(), "the reference to %s would refer to the renamed %s declared at %s", c.newName, obj.Name(), c.fset.Position(obj.Pos()))
+		}
+	}
+}
+
+// checkField checks that the struct of the field obj, and the named types
+// of this struct, have no field or method named newName.
+func (c *renameChecker) checkField(pkg source.Package, obj *types.Var) {
+	info := pkg.GetTypesInfo()
+	for _, tv := range info.Types {
+		st, ok := tv.Type.(*types.Struct)
+		if !ok || !hasField(st, obj) {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if other := st.Field(i); other.Name() == c.newName {
+				c.conflict(obj.Pos(), "field %s conflicts with %s declared at %s", obj.Name(), c.newName, c.fset.Position(other.Pos()))
+			}
+		}
+	}
+
+	for _, def := range info.Defs {
+		tn, ok := def.(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if st, ok := tn.Type().Underlying().(*types.Struct); !ok || !hasField(st, obj) {
+			continue
+		}
+		if other, _, _ := types.LookupFieldOrMethod(tn.Type(), true, obj.Pkg(), c.newName); other != nil {
+			c.conflict(obj.Pos(), "field %s conflicts with %s declared at %s", obj.Name(), c.newName, c.fset.Position(other.Pos()))
+		}
+	}
+}
+
+// encloses reports whether the scope inner is outer or is nested in it.
+func encloses(outer, inner *types.Scope) bool {
+	for s := inner; s != nil; s = s.Parent() {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+func isMethod(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	return ok && fn.Type().(*types.Signature).Recv() != nil
+}
+
+func isField(obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return ok && v.IsField()
+}
+
+func hasField(st *types.Struct, field *types.Var) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i) == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// renamePackage renames the package pkg to newName: the package clauses of
+// its files, including its external tests, and the qualified references of
+// the files importing it without a name. When newName is already used in
+// an importing file, the import is given the old name instead. Imports
+// named newName lose their now redundant name. The directory of the
+// package is not renamed.
+func (h *LangHandler) renamePackage(ctx context.Context, pkg source.Package, newName string) (protocol.WorkspaceEdit, error) {
+	oldName := pkg.GetName()
+	switch {
+	case oldName == "main":
+		return protocol.WorkspaceEdit{}, renameError("cannot rename package main")
+	case newName == "main" || newName == "_":
+		return protocol.WorkspaceEdit{}, renameError("cannot rename package %s to %s", oldName, newName)
+	}
+
+	fset := pkg.GetFileSet()
+	pkgPath := pkg.GetPkgPath()
+	e := newEditCollector(fset)
+	err := h.project.SearchReferrers(ctx, pkgPath, true, func(p source.Package) error {
+		for _, file := range p.GetSyntax() {
+			switch {
+			case p.GetPkgPath() == pkgPath:
+				e.add(file.Name.Pos(), file.Name.End(), newName)
+			case p.GetPkgPath() == pkgPath+"_test" && file.Name.Name == oldName+"_test":
+				e.add(file.Name.Pos(), file.Name.End(), newName+"_test")
+			}
+
+			for _, spec := range file.Imports {
+				if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == pkgPath {
+					renameImport(e, p, file, spec, oldName, newName)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	return h.workspaceEdit(utf16Edits(h.utf16Ranges(ctx), e.changes())), nil
+}
+
+// renameImport adds the edits of the import spec of file, importing the
+// renamed package, to e.
+func renameImport(e *editCollector, pkg source.Package, file *ast.File, spec *ast.ImportSpec, oldName, newName string) {
+	if spec.Name != nil {
+		if spec.Name.Name == newName {
+			e.add(spec.Name.Pos(), spec.Path.Pos(), "")
+		}
+		return
+	}
+
+	info := pkg.GetTypesInfo()
+	if info == nil || pkg.GetTypes() == nil {
+		return
+	}
+	pkgName, ok := info.Implicits[spec].(*types.PkgName)
+	if !ok {
+		return
+	}
+	var uses []*ast.Ident
+	for id, obj := range info.Uses {
+		if obj == pkgName {
+			uses = append(uses, id)
+		}
+	}
+
+	if importNameConflicts(pkg, file, uses, newName) {
+		e.add(spec.Path.Pos(), spec.Path.Pos(), oldName+" ")
+		return
+	}
+	for _, id := range uses {
+		e.add(id.Pos(), id.End(), newName)
+	}
+}
+
+// importNameConflicts reports whether naming the import of file newName
+// would change the meaning of file: if newName is already declared in the
+// file or its package, visible at one of the uses of the import, or
+// refers to a predeclared identifier in the file.
+func importNameConflicts(pkg source.Package, file *ast.File, uses []*ast.Ident, newName string) bool {
+	info, tpkg := pkg.GetTypesInfo(), pkg.GetTypes()
+	if tpkg.Scope().Lookup(newName) != nil {
+		return true
+	}
+	if scope := info.Scopes[file]; scope != nil && scope.Lookup(newName) != nil {
+		return true
+	}
+
+	for _, id := range uses {
+		scope := tpkg.Scope().Innermost(id.Pos())
+		if scope == nil {
+			continue
+		}
+		if _, obj := scope.LookupParent(newName, id.Pos()); obj != nil {
+			return true
+		}
+	}
+
+	for id, obj := range info.Uses {
+		if id.Name == newName && obj.Parent() == types.Universe && file.Pos() <= id.Pos() && id.Pos() <= file.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// editCollector collects the text edits of a workspace edit, skipping the
+// edits of files shared by several packages, like the test variants of a
+// package, which were already added.
+type editCollector struct {
+	fset  *token.FileSet
+	seen  map[string]bool
+	edits map[string][]lsp.TextEdit
+}
+
+func newEditCollector(fset *token.FileSet) *editCollector {
+	return &editCollector{fset: fset, seen: make(map[string]bool), edits: make(map[string][]lsp.TextEdit)}
+}
+
+// add adds the edit replacing the text between pos and end by newText.
+func (e *editCollector) add(pos, end token.Pos, newText string) {
+	loc := createLocationFromRange(e.fset, pos, end)
+	key := formatLocation(loc)
+	if e.seen[key] {
+		return
+	}
+	e.seen[key] = true
+	uri := string(loc.URI)
+	e.edits[uri] = append(e.edits[uri], lsp.TextEdit{Range: loc.Range, NewText: newText})
+}
+
+// changes returns the collected edits by document URI, ordered by position
+// in each document.
+func (e *editCollector) changes() map[string][]lsp.TextEdit {
+	for _, edits := range e.edits {
+		sort.Slice(edits, func(i, j int) bool {
+			a, b := edits[i].Range.Start, edits[j].Range.Start
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Character < b.Character
+		})
+	}
+	return e.edits
+}
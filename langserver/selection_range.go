@@ -0,0 +1,109 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// handleTextDocumentSelectionRange returns the selection ranges of each
+// position of the document, from the innermost node of its syntax tree
+// containing the position to the file. The document is parsed on its own,
+// like for the folding ranges.
+func (h *LangHandler) handleTextDocumentSelectionRange(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.SelectionRangeParams) ([]protocol.SelectionRange, error) {
+	uri := params.TextDocument.URI
+	if err := checkFileURI(uri); err != nil {
+		return nil, err
+	}
+
+	content, err := h.project.FileContent(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, util.UriToRealPath(uri), content, parser.ParseComments)
+	if file == nil {
+		return nil, err
+	}
+	tok := fset.File(file.Pos())
+
+	ranges := make([]protocol.SelectionRange, 0, len(params.Positions))
+	for _, position := range params.Positions {
+		pos := fromProtocolPosition(tok, fromUTF16Position(content, position))
+		ranges = append(ranges, selectionRange(fset, file, content, pos, position))
+	}
+	return ranges, nil
+}
+
+// selectionRange returns the selection range of the position pos of file,
+// whose protocol position is position, and its parents. The ranges are
+// those of the nodes enclosing pos, and of the text inside the quotes of a
+// string literal or the markers of a comment. A position outside of file
+// only has its empty range.
+func selectionRange(fset *token.FileSet, file *ast.File, content []byte, pos token.Pos, position lsp.Position) protocol.SelectionRange {
+	var nodes []ast.Node
+	add := func(start, end token.Pos) {
+		// Each range contains the previous one, and the nodes with the
+		// same range, eg. an expression statement and its expression,
+		// only have one.
+		if start > pos || end < pos {
+			return
+		}
+		if len(nodes) > 0 {
+			last := nodes[len(nodes)-1]
+			if start > last.Pos() || end < last.End() || start == last.Pos() && end == last.End() {
+				return
+			}
+		}
+		nodes = append(nodes, fakeNode{start, end})
+	}
+
+	if pos.IsValid() {
+		for _, group := range file.Comments {
+			if pos < group.Pos() || group.End() < pos {
+				continue
+			}
+			for _, c := range group.List {
+				if pos < c.Pos() || c.End() < pos {
+					continue
+				}
+				end := c.End()
+				if strings.HasPrefix(c.Text, "/*") {
+					end -= 2
+				}
+				add(c.Pos()+2, end)
+				add(c.Pos(), c.End())
+				break
+			}
+			add(group.Pos(), group.End())
+		}
+
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+		for _, n := range path {
+			if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				add(lit.Pos()+1, lit.End()-1)
+			}
+			add(n.Pos(), n.End())
+		}
+	}
+
+	var result *protocol.SelectionRange
+	for i := len(nodes) - 1; i >= 0; i-- {
+		result = &protocol.SelectionRange{
+			Range:  toUTF16Range(content, rangeForNode(fset, nodes[i])),
+			Parent: result,
+		}
+	}
+	if result == nil {
+		return protocol.SelectionRange{Range: lsp.Range{Start: position, End: position}}
+	}
+	return *result
+}
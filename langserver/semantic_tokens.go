@@ -0,0 +1,443 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// semanticTokenType is the type of a semantic token, the index of its name
+// in the legend.
+type semanticTokenType uint32
+
+const (
+	namespaceToken semanticTokenType = iota
+	typeToken
+	structToken
+	interfaceToken
+	functionToken
+	methodToken
+	parameterToken
+	variableToken
+	propertyToken
+)
+
+// The modifiers of a semantic token are the bits of their index in the
+// legend.
+const (
+	declarationModifier uint32 = 1 << iota
+	readonlyModifier
+	defaultLibraryModifier
+)
+
+// semanticTokensLegend is the legend of the semantic tokens. The keywords,
+// literals and comments are left to the syntax highlighting of the clients.
+var semanticTokensLegend = protocol.SemanticTokensLegend{
+	TokenTypes: []string{
+		"namespace",
+		"type",
+		"struct",
+		"interface",
+		"function",
+		"method",
+		"parameter",
+		"variable",
+		"property",
+	},
+	TokenModifiers: []string{
+		"declaration",
+		"readonly",
+		"defaultLibrary",
+	},
+}
+
+// semanticToken is the semantic token of an identifier.
+type semanticToken struct {
+	typ       semanticTokenType
+	modifiers uint32
+}
+
+// handleTextDocumentSemanticTokensFull returns the semantic tokens of the
+// identifiers of the document.
+func (h *LangHandler) handleTextDocumentSemanticTokensFull(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	return h.semanticTokens(ctx, params.TextDocument.URI, nil)
+}
+
+// handleTextDocumentSemanticTokensRange returns the semantic tokens of the
+// identifiers of the document overlapping the range.
+func (h *LangHandler) handleTextDocumentSemanticTokensRange(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	return h.semanticTokens(ctx, params.TextDocument.URI, &params.Range)
+}
+
+// semanticTokens returns the semantic tokens of the identifiers of the
+// document uri overlapping rng, or of all of them if rng is nil. They are
+// classified by the objects they denote if the package of the document is
+// well typed, and by their syntax otherwise.
+func (h *LangHandler) semanticTokens(ctx context.Context, uri lsp.DocumentURI, rng *lsp.Range) (*protocol.SemanticTokens, error) {
+	if err := checkFileURI(uri); err != nil {
+		return nil, err
+	}
+	content, err := h.project.FileContent(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var fset *token.FileSet
+	var tokens map[*ast.Ident]semanticToken
+	pkg, file, err := h.loadPackageAndAst(ctx, uri)
+	if err == nil && pkg != nil && file != nil && !pkg.IsIllTyped() {
+		fset = pkg.GetFileSet()
+		tokens = typedSemanticTokens(pkg.GetTypesInfo(), file)
+	} else {
+		fset = token.NewFileSet()
+		file, err = parser.ParseFile(fset, util.UriToRealPath(uri), content, 0)
+		if file == nil {
+			return nil, err
+		}
+		tokens = syntacticSemanticTokens(file)
+	}
+	return &protocol.SemanticTokens{Data: encodeSemanticTokens(fset, content, tokens, rng)}, nil
+}
+
+// typedSemanticTokens returns the semantic tokens of the identifiers of
+// file, classified by the objects of info they define or use.
+func typedSemanticTokens(info *types.Info, file *ast.File) map[*ast.Ident]semanticToken {
+	params := make(map[types.Object]bool)
+	addParams := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				if obj := info.Defs[name]; obj != nil {
+					params[obj] = true
+				}
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			addParams(n.Recv)
+		case *ast.FuncType:
+			addParams(n.Params)
+			addParams(n.Results)
+		}
+		return true
+	})
+
+	tokens := make(map[*ast.Ident]semanticToken)
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			return true
+		}
+		obj, modifiers := info.Uses[id], uint32(0)
+		if def, ok := info.Defs[id]; ok {
+			obj, modifiers = def, declarationModifier
+			if def == nil {
+				// The package name of the package clause, or the
+				// symbolic variable of a type switch, which is
+				// defined in each of its clauses.
+				typ := variableToken
+				if id == file.Name {
+					typ = namespaceToken
+				}
+				tokens[id] = semanticToken{typ, modifiers}
+				return true
+			}
+		}
+		if obj == nil {
+			return true
+		}
+		if tok, ok := objectSemanticToken(obj, params); ok {
+			tok.modifiers |= modifiers
+			tokens[id] = tok
+		}
+		return true
+	})
+	return tokens
+}
+
+// objectSemanticToken returns the semantic token of the identifiers
+// denoting obj, and whether it has one. params are the parameters of the
+// functions.
+func objectSemanticToken(obj types.Object, params map[types.Object]bool) (semanticToken, bool) {
+	var modifiers uint32
+	if obj.Pkg() == nil || isStandardPackage(obj.Pkg().Path()) {
+		modifiers |= defaultLibraryModifier
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		modifiers = 0
+		if isStandardPackage(obj.Imported().Path()) {
+			modifiers |= defaultLibraryModifier
+		}
+		return semanticToken{namespaceToken, modifiers}, true
+	case *types.TypeName:
+		switch obj.Type().Underlying().(type) {
+		case *types.Struct:
+			return semanticToken{structToken, modifiers}, true
+		case *types.Interface:
+			return semanticToken{interfaceToken, modifiers}, true
+		}
+		return semanticToken{typeToken, modifiers}, true
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return semanticToken{methodToken, modifiers}, true
+		}
+		return semanticToken{functionToken, modifiers}, true
+	case *types.Builtin:
+		return semanticToken{functionToken, modifiers}, true
+	case *types.Var:
+		if obj.IsField() {
+			return semanticToken{propertyToken, modifiers}, true
+		}
+		if params[obj] {
+			return semanticToken{parameterToken, modifiers}, true
+		}
+		return semanticToken{variableToken, modifiers}, true
+	case *types.Const, *types.Nil:
+		return semanticToken{variableToken, modifiers | readonlyModifier}, true
+	}
+	// The labels have no semantic token.
+	return semanticToken{}, false
+}
+
+// syntacticSemanticTokens returns the semantic tokens of the identifiers of
+// file, classified by their declarations in file, as resolved by the parser,
+// and by the way they are used. The identifiers declared in the other files
+// of the package are left out, but for those which are called.
+func syntacticSemanticTokens(file *ast.File) map[*ast.Ident]semanticToken {
+	tokens := make(map[*ast.Ident]semanticToken)
+	declare := func(typ semanticTokenType, modifiers uint32, ids ...*ast.Ident) {
+		for _, id := range ids {
+			if id != nil && id.Name != "_" {
+				tokens[id] = semanticToken{typ, modifiers | declarationModifier}
+			}
+		}
+	}
+	declareFields := func(typ semanticTokenType, fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			declare(typ, 0, field.Names...)
+		}
+	}
+	declareExprs := func(exprs ...ast.Expr) {
+		for _, expr := range exprs {
+			if id, ok := expr.(*ast.Ident); ok {
+				declare(variableToken, 0, id)
+			}
+		}
+	}
+
+	// imported are the modifiers of the names of the imported packages,
+	// which are assumed to be the last elements of their paths if they
+	// are not renamed.
+	imported := make(map[string]uint32)
+	declare(namespaceToken, 0, file.Name)
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var modifiers uint32
+		if isStandardPackage(importPath) {
+			modifiers = defaultLibraryModifier
+		}
+		if spec.Name != nil {
+			imported[spec.Name.Name] = modifiers
+			declare(namespaceToken, modifiers, spec.Name)
+		} else {
+			imported[path.Base(importPath)] = modifiers
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.TypeSpec:
+			declare(typeSpecSemanticToken(n), 0, n.Name)
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				declare(methodToken, 0, n.Name)
+			} else {
+				declare(functionToken, 0, n.Name)
+			}
+			declareFields(parameterToken, n.Recv)
+		case *ast.FuncType:
+			declareFields(parameterToken, n.Params)
+			declareFields(parameterToken, n.Results)
+		case *ast.StructType:
+			declareFields(propertyToken, n.Fields)
+		case *ast.InterfaceType:
+			declareFields(methodToken, n.Methods)
+		case *ast.GenDecl:
+			for _, spec := range n.Specs {
+				spec, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				if n.Tok == token.CONST {
+					declare(variableToken, readonlyModifier, spec.Names...)
+				} else {
+					declare(variableToken, 0, spec.Names...)
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				declareExprs(n.Lhs...)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				declareExprs(n.Key, n.Value)
+			}
+		}
+		return true
+	})
+
+	// The selected identifiers are not resolved, those of the imported
+	// packages are only classified if they are called.
+	called := calledIdents(file)
+	selected := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		selected[sel.Sel] = true
+		if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+			if modifiers, ok := imported[x.Name]; ok {
+				tokens[x] = semanticToken{namespaceToken, modifiers}
+				if called[sel.Sel] {
+					tokens[sel.Sel] = semanticToken{functionToken, modifiers}
+				}
+				return true
+			}
+		}
+		if called[sel.Sel] {
+			tokens[sel.Sel] = semanticToken{typ: methodToken}
+		} else {
+			tokens[sel.Sel] = semanticToken{typ: propertyToken}
+		}
+		return true
+	})
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name == "_" || selected[id] {
+			return true
+		}
+		if _, ok := tokens[id]; ok {
+			return true
+		}
+		if id.Obj == nil {
+			if obj := types.Universe.Lookup(id.Name); obj != nil {
+				if tok, ok := objectSemanticToken(obj, nil); ok {
+					tokens[id] = tok
+				}
+			} else if called[id] {
+				tokens[id] = semanticToken{typ: functionToken}
+			}
+			return true
+		}
+		switch id.Obj.Kind {
+		case ast.Pkg:
+			tokens[id] = semanticToken{typ: namespaceToken}
+		case ast.Con:
+			tokens[id] = semanticToken{variableToken, readonlyModifier}
+		case ast.Typ:
+			if spec, ok := id.Obj.Decl.(*ast.TypeSpec); ok {
+				tokens[id] = semanticToken{typ: typeSpecSemanticToken(spec)}
+			}
+		case ast.Fun:
+			tokens[id] = semanticToken{typ: functionToken}
+		case ast.Var:
+			// Only the parameters and results are declared by fields,
+			// the struct fields are not resolved.
+			if _, ok := id.Obj.Decl.(*ast.Field); ok {
+				tokens[id] = semanticToken{typ: parameterToken}
+			} else {
+				tokens[id] = semanticToken{typ: variableToken}
+			}
+		}
+		return true
+	})
+	return tokens
+}
+
+// typeSpecSemanticToken returns the type of the semantic token of the type
+// declared by spec.
+func typeSpecSemanticToken(spec *ast.TypeSpec) semanticTokenType {
+	switch spec.Type.(type) {
+	case *ast.StructType:
+		return structToken
+	case *ast.InterfaceType:
+		return interfaceToken
+	}
+	return typeToken
+}
+
+// encodeSemanticTokens returns the semantic tokens of the identifiers
+// overlapping rng, or of all of them if rng is nil, in the relative format
+// of the protocol: the line of each token is relative to that of the
+// previous one, and so is its start character on the same line. The
+// characters count UTF-16 code units.
+func encodeSemanticTokens(fset *token.FileSet, content []byte, tokens map[*ast.Ident]semanticToken, rng *lsp.Range) []uint32 {
+	ids := make([]*ast.Ident, 0, len(tokens))
+	for id := range tokens {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Pos() < ids[j].Pos()
+	})
+
+	data := []uint32{}
+	line, character := 0, 0
+	for _, id := range ids {
+		r := toUTF16Range(content, rangeForNode(fset, id))
+		if rng != nil && (!positionBefore(rng.Start, r.End) || !positionBefore(r.Start, rng.End)) {
+			continue
+		}
+		if r.Start.Line != line {
+			character = 0
+		}
+		tok := tokens[id]
+		data = append(data,
+			uint32(r.Start.Line-line),
+			uint32(r.Start.Character-character),
+			uint32(r.End.Character-r.Start.Character),
+			uint32(tok.typ),
+			tok.modifiers)
+		line, character = r.Start.Line, r.Start.Character
+	}
+	return data
+}
+
+// positionBefore reports whether the position a is before b.
+func positionBefore(a, b lsp.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
+}
+
+// isStandardPackage reports whether the package of the import path belongs
+// to the standard library. Like goimports, it assumes that the packages
+// whose first path element is not a domain name do.
+func isStandardPackage(importPath string) bool {
+	first := strings.Split(importPath, "/")[0]
+	return !strings.Contains(first, ".")
+}
@@ -0,0 +1,142 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Serve accepts the connections of lis and serves each client with a
+// handler of its own, created with cfg, so that their initializations,
+// documents and diagnostics are isolated. It returns when lis fails or is
+// closed.
+func Serve(lis net.Listener, cfg Config, opts ...jsonrpc2.ConnOpt) error {
+	return NewServer(cfg, opts...).Serve(lis)
+}
+
+// Server serves the clients connecting to its listeners, or upgraded by
+// its WebSocket handler, each with a handler of its own. It keeps track of
+// them, so that it can be shut down gracefully.
+type Server struct {
+	newHandler func() *LangHandler
+	opts       []jsonrpc2.ConnOpt
+
+	mu       sync.Mutex
+	clients  map[*jsonrpc2.Conn]*LangHandler
+	shutdown bool
+	served   sync.WaitGroup // the clients being served
+}
+
+// NewServer returns a server creating the handlers of its clients with
+// cfg.
+func NewServer(cfg Config, opts ...jsonrpc2.ConnOpt) *Server {
+	return newServer(func() *LangHandler {
+		return newLangHandler(cfg)
+	}, opts...)
+}
+
+func newServer(newHandler func() *LangHandler, opts ...jsonrpc2.ConnOpt) *Server {
+	return &Server{
+		newHandler: newHandler,
+		opts:       opts,
+		clients:    make(map[*jsonrpc2.Conn]*LangHandler),
+	}
+}
+
+// Serve accepts the connections of lis and serves their clients. It
+// returns when lis fails or is closed, and leaves the clients being served.
+func (s *Server) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveStream(jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}), conn.RemoteAddr())
+	}
+}
+
+// Shutdown shuts the server down gracefully. The handlers of the clients
+// refuse the new requests, and the connection of each client is closed
+// once its pending requests are answered. Once ctx is done, the requests
+// still pending are cancelled. Shutdown returns when no client is served
+// anymore, and the clients connecting afterwards are dropped: the listeners
+// should be closed first.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.mu.Lock()
+	s.shutdown = true
+	clients := make(map[*jsonrpc2.Conn]*LangHandler, len(s.clients))
+	for c, h := range s.clients {
+		clients[c] = h
+	}
+	s.mu.Unlock()
+
+	for c, h := range clients {
+		go func(c *jsonrpc2.Conn, h *LangHandler) {
+			h.drain(ctx)
+			_ = c.Close()
+		}(c, h)
+	}
+	s.served.Wait()
+}
+
+// serveStream serves the client at addr with a handler of its own until
+// the connection of stream is dropped. The handler is then shut down, and
+// its context is cancelled, which stops the loads of packages, the commands
+// and the file watching of its project.
+func (s *Server) serveStream(stream jsonrpc2.ObjectStream, addr net.Addr) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := s.newHandler()
+	c := jsonrpc2.NewConn(ctx, stream, lspHandler{jsonrpc2.HandlerWithError(h.handle)}, s.opts...)
+	if !s.add(c, h) {
+		_ = c.Close()
+	}
+	defer s.remove(c)
+	<-c.DisconnectNotify()
+
+	// The client may have shut the server down already.
+	h.HandlerCommon.mu.Lock()
+	h.shutdown = true
+	h.HandlerCommon.mu.Unlock()
+	log.Printf("langserver-go: connection from %s closed", addr)
+}
+
+// add tracks the client of c, served by h, unless the server is shut down.
+func (s *Server) add(c *jsonrpc2.Conn, h *LangHandler) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return false
+	}
+	s.clients[c] = h
+	s.served.Add(1)
+	return true
+}
+
+func (s *Server) remove(c *jsonrpc2.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		s.served.Done()
+	}
+}
+
+// drain refuses the new requests of h, and waits for its pending requests
+// to be answered, or cancels them once ctx is done.
+func (h *LangHandler) drain(ctx context.Context) {
+	h.HandlerCommon.mu.Lock()
+	h.shutdown = true
+	h.HandlerCommon.mu.Unlock()
+
+	h.mu.Lock()
+	cancel := h.cancel
+	h.mu.Unlock()
+	if cancel != nil {
+		cancel.wait(ctx)
+	}
+}
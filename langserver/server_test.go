@@ -0,0 +1,218 @@
+package langserver
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/go-lsp/lspext"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/packages/packagestest"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+func TestServeConcurrentClients(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	var mu sync.Mutex
+	var handlers []*LangHandler
+	go func() {
+		_ = newServer(func() *LangHandler {
+			cfg := NewDefaultConfig()
+			cfg.GlobalCacheStyle = string(cache.Always)
+			h := &LangHandler{DefaultConfig: cfg, HandlerShared: &HandlerShared{}}
+			mu.Lock()
+			handlers = append(handlers, h)
+			mu.Unlock()
+			return h
+		}).Serve(lis)
+	}()
+
+	// The clients work on different workspaces, each declaring a function.
+	type client struct {
+		conn     *jsonrpc2.Conn
+		exported *packagestest.Exported
+	}
+	ctx := context.Background()
+	connect := func(module, file, content string) *client {
+		exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+			Name:  module,
+			Files: map[string]interface{}{file: content},
+		}})
+		netConn, err := net.Dial("tcp", lis.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) {
+			return nil, nil
+		}))
+		return &client{conn: conn, exported: exported}
+	}
+	alpha := connect("example.com/alpha", "alpha.go", "package alpha\n\nfunc Alpha() {}\n")
+	defer alpha.exported.Cleanup()
+	bravo := connect("example.com/bravo", "bravo.go", "package bravo\n\nfunc Bravo() {}\n")
+	defer bravo.exported.Cleanup()
+	defer bravo.conn.Close()
+
+	// The clients initialize and query their workspaces concurrently.
+	symbols := func(c *client, query string) []lsp.SymbolInformation {
+		var symbols []lsp.SymbolInformation
+		if err := c.conn.Call(ctx, "workspace/symbol", lspext.WorkspaceSymbolParams{Query: query}, &symbols); err != nil {
+			t.Error(err)
+		}
+		return symbols
+	}
+	var wg sync.WaitGroup
+	for _, c := range []*client{alpha, bravo} {
+		wg.Add(1)
+		go func(c *client) {
+			defer wg.Done()
+			root := util.PathToURI(filepath.ToSlash(c.exported.Config.Dir))
+			params := InitializeParams{InitializeParams: lsp.InitializeParams{RootURI: root}}
+			if err := c.conn.Call(ctx, "initialize", params, nil); err != nil {
+				t.Error(err)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	check := func(c *client, query string, want int) {
+		t.Helper()
+		if got := symbols(c, query); len(got) != want {
+			t.Errorf("got %d symbols %v for %q, want %d", len(got), got, query, want)
+		}
+	}
+	check(alpha, "Alpha", 1)
+	check(alpha, "Bravo", 0)
+	check(bravo, "Bravo", 1)
+	check(bravo, "Alpha", 0)
+
+	// Dropping a connection shuts its handler down, and only its handler.
+	if err := alpha.conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(handlers) != 2 {
+		t.Fatalf("got %d handlers, want one per connection", len(handlers))
+	}
+	mu.Unlock()
+	ready := func(c *client) bool {
+		for _, h := range handlers {
+			h.mu.Lock()
+			init := h.init
+			h.mu.Unlock()
+			if init != nil && util.UriToRealPath(init.Root()) == util.UriToRealPath(util.PathToURI(filepath.ToSlash(c.exported.Config.Dir))) {
+				return h.CheckReady() == nil
+			}
+		}
+		t.Fatal("no handler initialized for the workspace")
+		return false
+	}
+	timeout := time.After(30 * time.Second)
+	for ready(alpha) {
+		select {
+		case <-timeout:
+			t.Fatal("the handler of the dropped connection was not shut down")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if !ready(bravo) {
+		t.Error("the handler of the other connection was shut down")
+	}
+	check(bravo, "Bravo", 1)
+}
+
+func TestServerShutdown(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	handlers := make(chan *LangHandler, 1)
+	server := newServer(func() *LangHandler {
+		h := &LangHandler{DefaultConfig: NewDefaultConfig(), HandlerShared: &HandlerShared{}, cancel: NewCancel()}
+		handlers <- h
+		return h
+	})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	netConn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) {
+		return nil, nil
+	}))
+	defer conn.Close()
+	h := <-handlers
+
+	// A request of the client is running.
+	reqCtx, done := h.cancel.WithCancel(context.Background(), jsonrpc2.ID{Num: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown := make(chan struct{})
+	go func() {
+		server.Shutdown(ctx)
+		close(shutdown)
+	}()
+
+	// The handler refuses the new requests, and the running one is waited
+	// for until the context of the shutdown is done.
+	timeout := time.After(30 * time.Second)
+	for h.CheckReady() == nil {
+		select {
+		case <-timeout:
+			t.Fatal("the handler was not shut down")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	select {
+	case <-shutdown:
+		t.Fatal("the server shut down before the running request was done")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if reqCtx.Err() != nil {
+		t.Fatal("the running request was cancelled before the context of the shutdown was done")
+	}
+
+	cancel()
+	select {
+	case <-reqCtx.Done():
+	case <-time.After(30 * time.Second):
+		t.Fatal("the running request was not cancelled")
+	}
+	done()
+	select {
+	case <-shutdown:
+	case <-time.After(30 * time.Second):
+		t.Fatal("the server did not shut down")
+	}
+	select {
+	case <-conn.DisconnectNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatal("the connection of the client was not closed")
+	}
+
+	// The clients connecting afterwards are dropped.
+	netConn, err = net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netConn.Close()
+	_ = netConn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	if _, err := netConn.Read(make([]byte, 1)); err == nil {
+		t.Error("read from a connection accepted after the shutdown")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"go/ast"
+	"go/parser"
 	"go/token"
 	"log"
 	"path"
@@ -11,6 +12,9 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/fuzzy"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
@@ -26,6 +30,14 @@ type Query struct {
 	File, Dir string
 	Tokens    []string
 
+	// Package restricts the results to the packages with this name, as
+	// queried by a trailing period, eg. "lsp.".
+	Package string
+
+	// Qualifier is the package or type qualifying the name of a query
+	// like "lsp.loc", which ranks the symbols it qualifies first.
+	Qualifier string
+
 	Symbol lspext.SymbolDescriptor
 }
 
@@ -49,6 +61,9 @@ func (q Query) String() string {
 			}
 		}
 	}
+	if q.Package != "" {
+		s = queryJoin(s, q.Package+".")
+	}
 	for _, token := range q.Tokens {
 		s = queryJoin(s, token)
 	}
@@ -62,7 +77,8 @@ func queryJoin(s, e string) string {
 }
 
 // ParseQuery parses a user's raw query string and returns a
-// structured representation of the query.
+// structured representation of the query. A token starting with a quote,
+// eg. "'handler", only matches the symbols with exactly this name.
 func ParseQuery(q string) (qu Query) {
 	// All queries are case insensitive.
 	q = strings.ToLower(q)
@@ -79,6 +95,13 @@ func ParseQuery(q string) (qu Query) {
 			qu.Filter = FilterExported
 			continue
 		}
+		if strings.HasSuffix(field, ".") && !strings.ContainsAny(field[:len(field)-1], "./") {
+			qu.Package = strings.TrimSuffix(field, ".")
+			continue
+		}
+		if i := strings.LastIndex(field, "."); i > 0 {
+			qu.Qualifier = field[:i]
+		}
 
 		// Each field is split into tokens, delimited by periods or slashes.
 		tokens := strings.FieldsFunc(field, func(c rune) bool {
@@ -177,7 +200,9 @@ func (s *resultSorter) Results() []lsp.SymbolInformation {
 }
 
 // score returns 0 for results that aren't matches. Results that are matches are assigned
-// a positive score, which should be used for ranking purposes.
+// a positive score, which should be used for ranking purposes. The names of
+// the symbols fuzzily match the tokens of the query, like the candidates of
+// a completion.
 func score(q Query, s symbolPair) (scor int) {
 	if q.Kind != 0 {
 		if q.Kind != s.Kind {
@@ -201,17 +226,30 @@ func score(q Query, s symbolPair) (scor int) {
 		// We're restricting results to a single file, and this isn't it.
 		return 0
 	}
+	if q.Package != "" && strings.ToLower(s.desc.PackageName) != q.Package {
+		return 0
+	}
 	if len(q.Tokens) == 0 { // early return if empty query
 		return 2
 	}
 	for i, tok := range q.Tokens {
 		tok := strings.ToLower(tok)
+		if strings.HasPrefix(tok, "'") {
+			if name != tok[1:] {
+				return 0
+			}
+			scor += 50
+			continue
+		}
 		if strings.HasPrefix(container, tok) {
 			scor += 2
 		}
 		if strings.HasPrefix(name, tok) {
 			scor += 3
 		}
+		if fuzzyScore, ok := fuzzy.Score(tok, s.Name); ok {
+			scor += 1 + int(fuzzyScore)
+		}
 		if strings.Contains(filename, tok) && len(tok) >= 3 {
 			scor++
 		}
@@ -229,58 +267,104 @@ func score(q Query, s symbolPair) (scor int) {
 			scor += 3
 		}
 	}
+	if scor > 0 && q.Qualifier != "" && (q.Qualifier == strings.ToLower(s.desc.PackageName) || q.Qualifier == container) {
+		scor += 10
+	}
 	if scor > 0 && !(strings.HasPrefix(filename, "vendor/") || strings.Contains(filename, "/vendor/")) {
 		// boost for non-vendor symbols
 		scor += 5
 	}
+	if scor > 0 && !cache.IsInGoroot(filename) && !cache.IsInModuleCache(filename) {
+		// boost for the symbols of the workspace over its dependencies
+		scor += 5
+	}
 	if scor > 0 && ast.IsExported(s.Name) {
 		// boost for exported symbols
 		scor++
 	}
+	if scor > 0 {
+		scor += kindScore(s.Kind)
+	}
 	return scor
 }
 
-// toSym returns a SymbolInformation value derived from values we get
-// from visiting the Go ast.
-func toSym(name string, pkg source.Package, container string, recv string, kind lsp.SymbolKind, fs *token.FileSet, pos token.Pos) symbolPair {
+// kindScore returns the boost of the symbols of the given kind: types and
+// functions rank above the methods, which rank above fields, variables and
+// constants.
+func kindScore(kind lsp.SymbolKind) int {
+	switch kind {
+	case lsp.SKClass, lsp.SKInterface, lsp.SKFunction:
+		return 2
+	case lsp.SKMethod:
+		return 1
+	}
+	return 0
+}
+
+// toSym returns the symbolPair of the symbol of pkg.
+func toSym(pkg source.Package, symbol cache.Symbol) symbolPair {
 	var id string
-	if container == "" {
-		id = fmt.Sprintf("%s/-/%s", path.Clean(pkg.GetPkgPath()), name)
+	if symbol.Container == "" {
+		id = fmt.Sprintf("%s/-/%s", path.Clean(pkg.GetPkgPath()), symbol.Name)
 	} else {
-		id = fmt.Sprintf("%s/-/%s/%s", path.Clean(pkg.GetPkgPath()), container, name)
+		id = fmt.Sprintf("%s/-/%s/%s", path.Clean(pkg.GetPkgPath()), symbol.Container, symbol.Name)
 	}
 
 	return symbolPair{
 		SymbolInformation: lsp.SymbolInformation{
-			Name:          name,
-			Kind:          kind,
-			Location:      goRangeToLSPLocation(fs, pos, name),
-			ContainerName: container,
+			Name:          symbol.Name,
+			Kind:          symbol.Kind,
+			Location:      symbol.Location,
+			ContainerName: symbol.Container,
 		},
 		// NOTE: fields must be kept in sync with workspace_refs.go:defSymbolDescriptor
 		desc: symbolDescriptor{
 			Vendor:      false,
 			Package:     path.Clean(pkg.GetPkgPath()),
 			PackageName: pkg.GetName(),
-			Recv:        recv,
-			Name:        name,
+			Recv:        symbol.Recv,
+			Name:        symbol.Name,
 			ID:          id,
 		},
 	}
 }
 
 // handleTextDocumentSymbol handles `textDocument/documentSymbol` requests for
-// the Go language server.
-func (h *LangHandler) handleTextDocumentSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentSymbolParams) ([]lsp.SymbolInformation, error) {
-	pkg, astFile, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+// the Go language server. The symbols are returned as a tree of
+// DocumentSymbols if the client supports them, as a flat list of
+// SymbolInformation otherwise.
+//
+// The file is parsed on its own rather than type checked with its package,
+// so that the declarations parsed before and after a syntax error are still
+// outlined while the file is edited.
+func (h *LangHandler) handleTextDocumentSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentSymbolParams) (interface{}, error) {
+	uri := params.TextDocument.URI
+	if err := checkFileURI(uri); err != nil {
+		return nil, err
+	}
+	content, err := h.project.FileContent(ctx, uri)
 	if err != nil {
 		return nil, err
 	}
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, util.UriToRealPath(uri), content, parser.ParseComments)
+	if astFile == nil {
+		return nil, err
+	}
 
-	symbols := astFileToSymbols(pkg, astFile)
+	if h.init.ClientCapabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport {
+		return utf16DocumentSymbols(content, documentSymbols(fset, astFile)), nil
+	}
+
+	symbols := cache.FileSymbols(fset, astFile)
 	res := make([]lsp.SymbolInformation, len(symbols))
 	for i, s := range symbols {
-		res[i] = s.SymbolInformation
+		res[i] = lsp.SymbolInformation{
+			Name:          s.Name,
+			Kind:          s.Kind,
+			Location:      lsp.Location{URI: s.Location.URI, Range: toUTF16Range(content, s.Location.Range)},
+			ContainerName: s.Container,
+		}
 	}
 	return res, nil
 }
@@ -303,15 +387,23 @@ func (h *LangHandler) handleWorkspaceSymbol(ctx context.Context, conn jsonrpc2.J
 		// If no limit is specified, default to a reasonable number
 		// for a user to look at. If they want more, they should
 		// refine the query.
-		params.Limit = 50
+		params.Limit = h.getConfig().MaxWorkspaceSymbols
 	}
-	return h.handleSymbol(ctx, conn, req, q, params.Limit)
+	return h.handleSymbol(ctx, conn, partialResultToken(req), q, params.Limit)
 }
 
-func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, query Query, limit int) ([]lsp.SymbolInformation, error) {
+// symbolBatchSize is the number of symbols sent to the client in each
+// partial result of a workspace/symbol request.
+const symbolBatchSize = 100
+
+// handleSymbol returns the symbols matching query. If resultToken is not
+// nil, the best ranked symbols of all the packages are sent to the client
+// in batches, and the returned result is empty.
+func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2, resultToken protocol.ProgressToken, query Query, limit int) ([]lsp.SymbolInformation, error) {
 	results := resultSorter{Query: query, results: make([]scoredSymbol, 0)}
+	toUTF16 := h.utf16Ranges(ctx)
 
-	f := func(pkg source.Package) error {
+	f := func(pkg source.Package, symbols []cache.Symbol) error {
 		// If the context is cancelled, breaking the loop here
 		// will allow us to return partial results, and
 		// avoiding starting new computations.
@@ -337,16 +429,17 @@ func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2,
 			return nil
 		}
 
-		if len(results.results) >= limit {
+		// The symbols streamed to the client are ranked among the matches
+		// of all the packages, since the batches sent cannot be taken back.
+		if resultToken == nil && len(results.results) >= limit {
 			return nil
 		}
 
-		h.collectFromPkg(pkg, &results)
-
+		collectFromPkg(pkg, symbols, &results)
 		return nil
 	}
 
-	err := h.project.Search(f)
+	err := h.project.SearchSymbols(ctx, f)
 	if err != nil {
 		return nil, err
 	}
@@ -355,20 +448,37 @@ func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2,
 	if len(results.results) > limit && limit > 0 {
 		results.results = results.results[:limit]
 	}
+	symbols := utf16Symbols(toUTF16, results.Results())
 
-	return results.Results(), nil
+	if resultToken == nil {
+		return symbols, nil
+	}
+	for len(symbols) > 0 {
+		n := symbolBatchSize
+		if n > len(symbols) {
+			n = len(symbols)
+		}
+		if err := h.sendPartialResult(ctx, conn, resultToken, symbols[:n]); err != nil {
+			return nil, err
+		}
+		symbols = symbols[n:]
+	}
+	return []lsp.SymbolInformation{}, nil
 }
 
-// collectFromPkg collects all the symbols from the specified package
-// into the results. It uses LangHandler's package symbol cache to
-// speed up repeated calls.
-func (h *LangHandler) collectFromPkg(pkg source.Package, results *resultSorter) {
-	symbols := astPkgToSymbols(pkg)
-	if symbols == nil {
-		return
+// utf16Symbols converts the locations of symbols with toUTF16, in place.
+func utf16Symbols(toUTF16 func(uri lsp.DocumentURI, r lsp.Range) lsp.Range, symbols []lsp.SymbolInformation) []lsp.SymbolInformation {
+	for i := range symbols {
+		symbols[i].Location = utf16Location(toUTF16, symbols[i].Location)
 	}
+	return symbols
+}
 
-	for _, sym := range symbols {
+// collectFromPkg collects the indexed symbols of the specified package
+// into the results.
+func collectFromPkg(pkg source.Package, symbols []cache.Symbol, results *resultSorter) {
+	for _, symbol := range symbols {
+		sym := toSym(pkg, symbol)
 		if results.Query.Filter == FilterExported && !isExported(&sym) {
 			continue
 		}
@@ -376,13 +486,6 @@ func (h *LangHandler) collectFromPkg(pkg source.Package, results *resultSorter)
 	}
 }
 
-// SymbolCollector stores symbol information for an AST
-type SymbolCollector struct {
-	pkgSyms []symbolPair
-	pkg     source.Package
-	fs      *token.FileSet
-}
-
 func recvString(recv ast.Expr) string {
 	switch t := recv.(type) {
 	case *ast.Ident:
@@ -393,125 +496,6 @@ func recvString(recv ast.Expr) string {
 	return "BADRECV"
 }
 
-func specNames(specs []ast.Spec) []string {
-	names := make([]string, 0, len(specs))
-	for _, s := range specs {
-		// s guaranteed to be an *ast.ValueSpec by readValue
-		for _, ident := range s.(*ast.ValueSpec).Names {
-			names = append(names, ident.Name)
-		}
-	}
-	return names
-}
-
-func (c *SymbolCollector) addSymbol(name string, recv string, container string, kind lsp.SymbolKind, pos token.Pos) {
-	c.pkgSyms = append(c.pkgSyms, toSym(name, c.pkg, recv, container, kind, c.fs, pos))
-}
-
-func (c *SymbolCollector) addFuncDecl(fun *ast.FuncDecl) {
-	if fun.Recv != nil {
-		// methods
-		recvTypeName := ""
-		var typ ast.Expr
-		if list := fun.Recv.List; len(list) == 1 {
-			typ = list[0].Type
-		}
-		recvTypeName = recvString(typ)
-		c.addSymbol(fun.Name.Name, recvTypeName, recvTypeName, lsp.SKMethod, fun.Name.NamePos)
-		return
-	}
-	// ordinary function
-	c.addSymbol(fun.Name.Name, "", "", lsp.SKFunction, fun.Name.NamePos)
-	return
-}
-
-func (c *SymbolCollector) addContainer(containerName string, fields *ast.FieldList, containerKind lsp.SymbolKind, containerPos token.Pos) {
-	if fields.List != nil {
-		for _, field := range fields.List {
-			if field.Names != nil {
-				for _, fieldName := range field.Names {
-					c.addSymbol(fieldName.Name, containerName, "", lsp.SKField, fieldName.NamePos)
-				}
-			}
-		}
-	}
-	c.addSymbol(containerName, "", "", containerKind, containerPos)
-}
-
-// Visit visits AST nodes and collects symbol information
-func (c *SymbolCollector) Visit(n ast.Node) (w ast.Visitor) {
-	switch t := n.(type) {
-	case *ast.TypeSpec:
-		if t.Name.Name != "_" {
-			switch term := t.Type.(type) {
-			case *ast.StructType:
-				c.addContainer(t.Name.Name, term.Fields, lsp.SKClass, t.Name.NamePos)
-			case *ast.InterfaceType:
-				c.addContainer(t.Name.Name, term.Methods, lsp.SKInterface, t.Name.NamePos)
-			default:
-				c.addSymbol(t.Name.Name, "", "", lsp.SKClass, t.Name.NamePos)
-			}
-		}
-	case *ast.GenDecl:
-		switch t.Tok {
-		case token.CONST:
-			names := specNames(t.Specs)
-			for _, name := range names {
-				c.addSymbol(name, "", "", lsp.SKConstant, declNamePos(t, name))
-			}
-		case token.VAR:
-			names := specNames(t.Specs)
-			for _, name := range names {
-				if name != "_" {
-					c.addSymbol(name, "", "", lsp.SKVariable, declNamePos(t, name))
-				}
-			}
-		}
-	case *ast.FuncDecl:
-		c.addFuncDecl(t)
-	}
-	return c
-}
-
-func astPkgToSymbols(pkg source.Package) []symbolPair {
-	var pkgSyms []symbolPair
-	symbolCollector := &SymbolCollector{pkgSyms, pkg, pkg.GetFileSet()}
-
-	for _, src := range pkg.GetSyntax() {
-		ast.Walk(symbolCollector, src)
-	}
-
-	return symbolCollector.pkgSyms
-}
-
-func astFileToSymbols(pkg source.Package, astFile *ast.File) []symbolPair {
-	var pkgSymbols []symbolPair
-	symbolCollector := &SymbolCollector{pkgSymbols, pkg, pkg.GetFileSet()}
-	ast.Walk(symbolCollector, astFile)
-	return symbolCollector.pkgSyms
-}
-
-func declNamePos(decl *ast.GenDecl, name string) token.Pos {
-	for _, spec := range decl.Specs {
-		switch spec := spec.(type) {
-		case *ast.ImportSpec:
-			if spec.Name != nil {
-				return spec.Name.Pos()
-			}
-			return spec.Path.Pos()
-		case *ast.ValueSpec:
-			for _, specName := range spec.Names {
-				if specName.Name == name {
-					return specName.NamePos
-				}
-			}
-		case *ast.TypeSpec:
-			return spec.Name.Pos()
-		}
-	}
-	return decl.TokPos
-}
-
 func isExported(sym *symbolPair) bool {
 	if sym.ContainerName == "" {
 		return ast.IsExported(sym.Name)
@@ -62,8 +62,11 @@ type symbolLocationInformation struct {
 	Location lsp.Location `json:"location,omitempty"`
 	// Metadata about the definition.
 	Symbol *symbolDescriptor `json:"symbol"`
-	// the location of a type declaration, if one is available
-	TypeLocation lsp.Location `json:"-"`
+	// the locations of the type declarations, if any are available
+	TypeLocations []lsp.Location `json:"-"`
+	// the range of the origin of the lookup, if it differs from the
+	// identifier under the cursor
+	OriginRange *lsp.Range `json:"-"`
 }
 
 // referenceInformation is lspext.ReferenceInformation using our custom symbolDescriptor
@@ -90,7 +90,7 @@ func Test_resultSorter(t *testing.T) {
 		}},
 	}, {
 		// Just tests that 'is:exported' does not affect resultSorter
-		// results, as filtering is done elsewhere in (*LangHandler).collectFromPkg
+		// results, as filtering is done elsewhere in collectFromPkg
 		rawQuery: "is:exported",
 		allSymbols: []lsp.SymbolInformation{{
 			ContainerName: "foo", Name: "bar",
@@ -160,3 +160,58 @@ func TestQueryString(t *testing.T) {
 		})
 	}
 }
+
+func TestSymbolScore(t *testing.T) {
+	t.Parallel()
+
+	symbol := func(pkgName, container, name string, kind lsp.SymbolKind) symbolPair {
+		return symbolPair{
+			SymbolInformation: lsp.SymbolInformation{
+				ContainerName: container, Name: name, Kind: kind,
+				Location: lsp.Location{URI: lsp.DocumentURI("file:///src/" + pkgName + "/file.go")},
+			},
+			desc: symbolDescriptor{PackageName: pkgName, Name: name},
+		}
+	}
+	symbols := []symbolPair{
+		symbol("langserver", "", "LangHandler", lsp.SKClass),
+		symbol("langserver", "T", "handler", lsp.SKField),
+		symbol("langserver", "", "Handler", lsp.SKInterface),
+		symbol("lsp", "", "Location", lsp.SKClass),
+		symbol("protocol", "", "LocationLink", lsp.SKClass),
+		symbol("protocol", "", "Locate", lsp.SKFunction),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Fuzzy matching of the words of the names.
+		{"lhdl", []string{"LangHandler"}},
+		// Types rank above fields.
+		{"handler", []string{"Handler", "T.handler", "LangHandler"}},
+		// Exact match.
+		{"'locate", []string{"Locate"}},
+		// Package qualified search.
+		{"lsp.", []string{"Location"}},
+		{"lsp.loc", []string{"Location", "Locate", "LocationLink"}},
+	}
+	for _, test := range tests {
+		results := resultSorter{Query: ParseQuery(test.query)}
+		for _, s := range symbols {
+			results.Collect(s)
+		}
+		sort.Sort(&results)
+		var got []string
+		for _, s := range results.Results() {
+			name := s.Name
+			if s.ContainerName != "" {
+				name = s.ContainerName + "." + name
+			}
+			got = append(got, name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.query, got, test.want)
+		}
+	}
+}
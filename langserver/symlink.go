@@ -0,0 +1,182 @@
+package langserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// symlinks maps the paths of the files as the client sees them, which may
+// go through symbolic links, to their real paths, which the packages are
+// loaded, cached and overlaid with, and back. Each handler has a mapping of
+// its own, as the clients of the connections may reach the same directories
+// through other links. The client path of a real directory is the one it was
+// last reached with, starting with the root of the workspace.
+type symlinks struct {
+	mu sync.Mutex
+	// client maps the real paths of the directories reached through a
+	// symbolic link to their client paths. It holds at most one entry per
+	// directory of the client.
+	client map[string]string
+}
+
+func newSymlinks() *symlinks {
+	return &symlinks{client: make(map[string]string)}
+}
+
+// realPath returns the path of the file or directory path with the
+// symbolic links of its directories evaluated. A file which is a symbolic
+// link itself is left alone, as the go command lists it under its own name.
+func (s *symlinks) realPath(path string) string {
+	if s == nil {
+		return path
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return s.realDir(path)
+	}
+	return filepath.Join(s.realDir(filepath.Dir(path)), filepath.Base(path))
+}
+
+// realDir returns the path of the directory dir with its symbolic links
+// evaluated. They are evaluated every time, so that a link pointed at
+// another directory is followed there.
+func (s *symlinks) realDir(dir string) string {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		// The directory may not be created yet.
+		return dir
+	}
+	real := util.LowerDriver(resolved)
+	if real != dir {
+		s.mu.Lock()
+		s.client[real] = dir
+		s.mu.Unlock()
+	}
+	return real
+}
+
+// clientPath returns the path the client sees the file or directory path
+// with, by replacing its closest directory which was reached through a
+// symbolic link.
+func (s *symlinks) clientPath(path string) string {
+	if s == nil {
+		return path
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for dir := path; ; {
+		if client, ok := s.client[dir]; ok {
+			return client + path[len(dir):]
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
+
+// aliased reports whether any directory of the client was reached through
+// a symbolic link.
+func (s *symlinks) aliased() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.client) > 0
+}
+
+// toReal converts the file URI of the client uri to the URI of the real
+// path of the file.
+func (s *symlinks) toReal(uri lsp.DocumentURI) lsp.DocumentURI {
+	if !util.IsURI(uri) {
+		return uri
+	}
+	return util.PathToURI(s.realPath(util.UriToRealPath(uri)))
+}
+
+// toClient converts the file URI of a real path to the URI the client sees
+// the file with.
+func (s *symlinks) toClient(uri lsp.DocumentURI) lsp.DocumentURI {
+	if !util.IsURI(uri) {
+		return uri
+	}
+	return util.PathToURI(s.clientPath(util.UriToRealPath(uri)))
+}
+
+// incoming converts the file URIs of the params of a request of the client
+// to the URIs of the real paths.
+func (s *symlinks) incoming(params *json.RawMessage) *json.RawMessage {
+	if s == nil || params == nil || !bytes.Contains(*params, []byte("file://")) {
+		return params
+	}
+
+	raw, err := mapJSONURIs(*params, s.toReal)
+	if err != nil {
+		return params
+	}
+	return &raw
+}
+
+// outgoing converts the file URIs of a result sent to the client to the
+// URIs the client sees the files with. The result is returned unchanged if
+// no directory was reached through a symbolic link.
+func (s *symlinks) outgoing(result interface{}) interface{} {
+	if result == nil || !s.aliased() {
+		return result
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return result
+	}
+	raw, err := mapJSONURIs(data, s.toClient)
+	if err != nil {
+		return result
+	}
+	return raw
+}
+
+// mapJSONURIs returns the JSON value data with f applied to its file URIs.
+func mapJSONURIs(data []byte, f func(lsp.DocumentURI) lsp.DocumentURI) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mapURIs(v, f))
+}
+
+// mapURIs applies f to the file URIs of the decoded JSON value v, both the
+// values and the keys of the objects, such as the changes of a workspace
+// edit.
+func mapURIs(v interface{}, f func(lsp.DocumentURI) lsp.DocumentURI) interface{} {
+	switch v := v.(type) {
+	case string:
+		if util.IsURI(lsp.DocumentURI(v)) {
+			return string(f(lsp.DocumentURI(v)))
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = mapURIs(v[i], f)
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if util.IsURI(lsp.DocumentURI(key)) {
+				key = string(f(lsp.DocumentURI(key)))
+			}
+			m[key] = mapURIs(value, f)
+		}
+		return m
+	}
+	return v
+}
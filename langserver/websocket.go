@@ -0,0 +1,142 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// webSocketPingPeriod is the interval of the pings keeping the idle
+	// connections alive through the proxies.
+	webSocketPingPeriod = 30 * time.Second
+
+	// webSocketPongWait is how long the client may not answer the pings
+	// before its connection is considered dead.
+	webSocketPongWait = 2 * webSocketPingPeriod
+
+	// webSocketWriteWait is the time allowed to write a control message.
+	webSocketWriteWait = 10 * time.Second
+)
+
+// WebSocketOptions configures the WebSocket transport.
+type WebSocketOptions struct {
+	// Origins are the origins of the browsers allowed to connect, "*"
+	// allowing any of them. If it is empty, only the origin of the host of
+	// the server is allowed.
+	Origins []string
+}
+
+// NewWebSocketHandler returns an HTTP handler upgrading its requests to
+// WebSocket connections and serving each client like Serve, with a handler
+// of its own created with cfg.
+func NewWebSocketHandler(cfg Config, options WebSocketOptions, opts ...jsonrpc2.ConnOpt) http.Handler {
+	return NewServer(cfg, opts...).WebSocketHandler(options)
+}
+
+// WebSocketHandler returns an HTTP handler upgrading its requests to
+// WebSocket connections and serving their clients.
+func (s *Server) WebSocketHandler(options WebSocketOptions) http.Handler {
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin(options.Origins)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader replied with the error.
+			log.Printf("langserver-go: websocket upgrade from %s failed: %s", r.RemoteAddr, err)
+			return
+		}
+		s.serveStream(newWebSocketStream(conn), conn.RemoteAddr())
+	})
+}
+
+// checkOrigin returns the check of the origin of the upgraded requests
+// allowing origins, or nil for the default check of the upgrader if there
+// are none. The requests without origin do not come from a browser, and
+// are allowed.
+func checkOrigin(origins []string) func(r *http.Request) bool {
+	if len(origins) == 0 {
+		return nil
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// webSocketStream is a jsonrpc2.ObjectStream sending each object in a text
+// message of a WebSocket connection, which it keeps alive with pings. The
+// objects are written by one goroutine at a time, the jsonrpc2.Conn
+// sending them.
+type webSocketStream struct {
+	conn      *websocket.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWebSocketStream(conn *websocket.Conn) *webSocketStream {
+	s := &webSocketStream{conn: conn, done: make(chan struct{})}
+	_ = conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	})
+	go s.ping()
+	return s
+}
+
+func (s *webSocketStream) ping() {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// WriteObject implements jsonrpc2.ObjectStream.
+func (s *webSocketStream) WriteObject(obj interface{}) error {
+	return s.conn.WriteJSON(obj)
+}
+
+// ReadObject implements jsonrpc2.ObjectStream. A connection closed by the
+// client reads io.EOF, like the end of the standard input.
+func (s *webSocketStream) ReadObject(v interface{}) error {
+	err := s.conn.ReadJSON(v)
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+		return io.EOF
+	}
+	return err
+}
+
+// Close implements jsonrpc2.ObjectStream. It closes the connection with
+// a close message, once.
+func (s *webSocketStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		_ = s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(webSocketWriteWait))
+		err = s.conn.Close()
+	})
+	return err
+}
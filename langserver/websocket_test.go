@@ -0,0 +1,100 @@
+package langserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/packages/packagestest"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+func TestWebSocket(t *testing.T) {
+	exported := packagestest.Export(t, packagestest.Modules, []packagestest.Module{{
+		Name:  "example.com/web",
+		Files: map[string]interface{}{"web.go": "package web\n\nfunc Web() {}\n"},
+	}})
+	defer exported.Cleanup()
+
+	var mu sync.Mutex
+	var handlers []*LangHandler
+	options := WebSocketOptions{Origins: []string{"http://editor.example.com"}}
+	server := httptest.NewServer(newServer(func() *LangHandler {
+		cfg := NewDefaultConfig()
+		cfg.GlobalCacheStyle = string(cache.None)
+		h := newLangHandler(cfg)
+		mu.Lock()
+		handlers = append(handlers, h)
+		mu.Unlock()
+		return h
+	}).WebSocketHandler(options))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	dial := func(origin string) (*websocket.Conn, *http.Response, error) {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		return websocket.DefaultDialer.Dial(url, header)
+	}
+
+	t.Run("origin", func(t *testing.T) {
+		if _, resp, err := dial("http://evil.example.com"); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("got response %v and error %v, want the origin forbidden", resp, err)
+		}
+	})
+
+	t.Run("session", func(t *testing.T) {
+		ws, _, err := dial("http://editor.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		conn := jsonrpc2.NewConn(ctx, newWebSocketStream(ws), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) {
+			return nil, nil
+		}))
+
+		root := util.PathToURI(filepath.ToSlash(exported.Config.Dir))
+		params := InitializeParams{InitializeParams: lsp.InitializeParams{RootURI: root}}
+		var result protocol.InitializeResult
+		if err := conn.Call(ctx, "initialize", params, &result); err != nil {
+			t.Fatal(err)
+		}
+		if !result.Capabilities.HoverProvider {
+			t.Errorf("got capabilities %+v, want hover", result.Capabilities)
+		}
+
+		// Closing the connection shuts its handler down, like the end of
+		// the standard input.
+		if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		if len(handlers) != 1 {
+			mu.Unlock()
+			t.Fatalf("got %d handlers, want one per connection", len(handlers))
+		}
+		h := handlers[0]
+		mu.Unlock()
+		timeout := time.After(30 * time.Second)
+		for h.CheckReady() == nil {
+			select {
+			case <-timeout:
+				t.Fatal("the handler of the closed connection was not shut down")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"math"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -18,36 +17,37 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-// workspaceReferencesTimeout is the timeout used for workspace/xreferences
-// calls.
-const workspaceReferencesTimeout = time.Minute
-
 func (h *LangHandler) handleWorkspaceReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lspext.WorkspaceReferencesParams) ([]referenceInformation, error) {
-	// TODO: Add support for the cancelRequest LSP method instead of using
-	// hard-coded timeouts like this here.
-	//
-	// See: https://github.com/Microsoft/language-server-protocol/blob/master/protocol.md#cancelRequest
-	ctx, cancel := context.WithTimeout(ctx, workspaceReferencesTimeout)
-	defer cancel()
 	rootPath := h.FilePath(h.init.Root())
 
+	limit := params.Limit
+	if limit <= 0 {
+		// If we don't have a limit, just set it to a value we should never exceed
+		limit = math.MaxInt32
+	}
+
+	hintDirs := workspaceReferencesDirs(params.Hints)
 	var results = refResult{results: make([]referenceInformation, 0)}
 	f := func(pkg source.Package) error {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
+		// Stop searching once we have enough results.
+		if len(results.results) >= limit {
+			return nil
+		}
+
 		// If a dirs hint is present, only look for references created in those
 		// directories.
 		pkgDir := ""
 		if len(pkg.GetFilenames()) > 0 {
 			pkgDir = filepath.ToSlash(filepath.Dir(pkg.GetFilenames()[0]))
 		}
-		dirs, ok := params.Hints["dirs"]
-		if ok {
+		if hintDirs != nil {
 			found := false
-			for _, dir := range dirs.([]interface{}) {
-				hintDir := h.FilePath(lsp.DocumentURI(dir.(string)))
+			for _, dir := range hintDirs {
+				hintDir := h.FilePath(lsp.DocumentURI(dir))
 				if util.PathEqual(pkgDir, hintDir) {
 					found = true
 					break
@@ -71,20 +71,41 @@ func (h *LangHandler) handleWorkspaceReferences(ctx context.Context, conn jsonrp
 		return nil, err
 	}
 
-	limit := params.Limit
-	if limit <= 0 {
-		// If we don't have a limit, just set it to a value we should never exceed
-		limit = math.MaxInt32
-	}
-
 	r := results.results
 	if len(r) > limit {
 		r = r[:limit]
 	}
+	toUTF16 := h.utf16Ranges(ctx)
+	for i := range r {
+		r[i].Reference = utf16Location(toUTF16, r[i].Reference)
+	}
 
 	return r, nil
 }
 
+// workspaceReferencesDirs returns the directory URIs of the "dirs" hint of
+// a workspace/xreferences request, or nil if there is no such hint. Values
+// which are not strings are ignored.
+func workspaceReferencesDirs(hints map[string]interface{}) []string {
+	dirs, ok := hints["dirs"]
+	if !ok {
+		return nil
+	}
+
+	hintDirs := []string{}
+	switch dirs := dirs.(type) {
+	case []string:
+		hintDirs = append(hintDirs, dirs...)
+	case []interface{}:
+		for _, dir := range dirs {
+			if dir, ok := dir.(string); ok {
+				hintDirs = append(hintDirs, dir)
+			}
+		}
+	}
+	return hintDirs
+}
+
 // workspaceRefsFromPkg collects all the references made to dependencies from
 // the specified package and returns the results.
 func (h *LangHandler) workspaceRefsFromPkg(ctx context.Context, conn jsonrpc2.JSONRPC2, params lspext.WorkspaceReferencesParams, pkg source.Package, rootPath string, results *refResult) (err error) {
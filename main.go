@@ -9,8 +9,10 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/saibing/bingo/langserver"
@@ -20,14 +22,18 @@ import (
 )
 
 var (
-	mode         = flag.String("mode", "stdio", "communication mode (stdio|tcp)")
-	addr         = flag.String("addr", ":4389", "server listen address (tcp)")
+	mode         = flag.String("mode", "stdio", "communication mode (stdio|tcp|unix|websocket)")
+	addr         = flag.String("addr", ":4389", "server listen address, the path of the socket in unix mode (tcp|unix|websocket)")
 	trace        = flag.Bool("trace", false, "print all requests and responses")
 	logfile      = flag.String("logfile", "", "also log to this file (in addition to stderr)")
 	printVersion = flag.Bool("version", false, "print version and exit")
 	freeosmemory = flag.Int("freeosmemory", 0, "the interval time that aggressively free memory back to the OS, unit is second, default value is 0, means no free memroy back to the OS")
 	pprof        = flag.String("pprof", "", "start a pprof http server (https://golang.org/pkg/net/http/pprof/)")
 
+	websocketPath    = flag.String("websocket-path", "/", "the path the connections are upgraded at (websocket)")
+	websocketOrigins = flag.String("websocket-origins", "", "the origins allowed to connect, separated by commas, * allows any. Only the origin of the server is allowed by default (websocket)")
+	idleTimeout      = flag.Duration("idle-timeout", 0, "shut the server down once no client has been connected for this long, 0 means never (tcp|unix|websocket)")
+
 	// Default Config, can be overridden by InitializationOptions
 	maxparallelism       = flag.Int("maxparallelism", 0, "use at max N parallel goroutines to fulfill requests. Can be overridden by InitializationOptions.")
 	diagnosticsStyle     = flag.String("diagnostics-style", "instant", "diagnostics style: none, instant, onsave. Can be overridden by InitializationOptions.")
@@ -37,6 +43,11 @@ var (
 	goimportsPrefix      = flag.String("goimports-prefix", "", "set '--local' flag for the goimports invocation. Can be overridden by InitializationOptions.")
 	enhanceSignatureHelp = flag.Bool("enhance-signature-help", false, "enhance signature help with return result. Can be overridden by InitializationOptions.")
 	buildTags            = flag.String("build-tags", "", "build tags, separated by spaces.")
+	followLineDirectives = flag.Bool("follow-line-directives", false, "navigate to the files //line directives refer to instead of the generated files. Can be overridden by InitializationOptions.")
+	hoverShowStructInfo  = flag.Bool("hover-show-struct-info", false, "show the size of structs and the offsets of their fields on hover. Can be overridden by InitializationOptions.")
+	interfaceReferences  = flag.Bool("interface-references", false, "include the references of methods related through interfaces. Can be overridden by InitializationOptions.")
+	maxReferences        = flag.Int("max-references", 5000, "maximum number of locations returned by a references request, 0 means unlimited. Can be overridden by InitializationOptions.")
+	watchFiles           = flag.Bool("watch-files", false, "watch the files of the workspace if the client cannot be asked to watch them. Can be overridden by InitializationOptions.")
 
 	// Compatible with sourcegraph/go-langserver, ensuring that ide-go can run, but no actual effect
 	// https://github.com/saibing/bingo/issues/163
@@ -53,6 +64,10 @@ var (
 // 4. Tag the commit created in (1) with the value of the version string
 const version = "v2-dev"
 
+// shutdownTimeout is how long the pending requests of the clients may take
+// to be answered when the server shuts down, before they are cancelled.
+const shutdownTimeout = 5 * time.Second
+
 func main() {
 	flag.Parse()
 	log.SetFlags(0)
@@ -75,6 +90,11 @@ func main() {
 	cfg.FormatStyle = *formatStyle
 	cfg.GoimportsLocalPrefix = *goimportsPrefix
 	cfg.EnhanceSignatureHelp = *enhanceSignatureHelp
+	cfg.FollowLineDirectives = *followLineDirectives
+	cfg.HoverShowStructInfo = *hoverShowStructInfo
+	cfg.InterfaceReferences = *interfaceReferences
+	cfg.MaxReferences = *maxReferences
+	cfg.WatchFiles = *watchFiles
 
 	if *buildTags != "" {
 		cfg.BuildTags = strings.Split(*buildTags, " ")
@@ -118,26 +138,58 @@ func run(cfg langserver.Config) error {
 		return langserver.NewHandler(cfg)
 	}
 
+	server := langserver.NewServer(cfg, connOpt...)
 	switch *mode {
-	case "tcp":
-		lis, err := net.Listen("tcp", *addr)
+	case "tcp", "unix":
+		var lis net.Listener
+		var err error
+		if *mode == "unix" {
+			lis, err = langserver.ListenUnix(*addr)
+		} else {
+			lis, err = net.Listen("tcp", *addr)
+		}
 		if err != nil {
 			return err
 		}
 		defer lis.Close()
+		if *mode == "unix" {
+			// The socket is removed once the clients are shut down.
+			defer os.Remove(*addr)
+		}
 
 		log.Println("langserver-go: listening on", *addr)
-		for {
-			conn, err := lis.Accept()
-			if err != nil {
-				return err
-			}
-			jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}), newHandler(), connOpt...)
+		return serveListener(lis, server, server.Serve)
+
+	case "websocket":
+		var options langserver.WebSocketOptions
+		if *websocketOrigins != "" {
+			options.Origins = strings.Split(*websocketOrigins, ",")
+		}
+
+		lis, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return err
 		}
+		defer lis.Close()
+
+		// The handlers of pprof are left out of the server.
+		mux := http.NewServeMux()
+		mux.Handle(*websocketPath, server.WebSocketHandler(options))
+		log.Printf("langserver-go: listening on %s for websocket connections at %s", *addr, *websocketPath)
+		return serveListener(lis, server, func(lis net.Listener) error {
+			return http.Serve(lis, mux)
+		})
 
 	case "stdio":
 		log.Println("langserver-go: reading on stdin, writing on stdout")
-		<-jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(stdrwc{}, jsonrpc2.VSCodeObjectCodec{}), newHandler(), connOpt...).DisconnectNotify()
+		conn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(stdrwc{}, jsonrpc2.VSCodeObjectCodec{}), newHandler(), connOpt...)
+		go func() {
+			// A signal closes the connection like the end of the
+			// standard input.
+			log.Printf("langserver-go: received %s, shutting down", <-shutdownSignals())
+			_ = conn.Close()
+		}()
+		<-conn.DisconnectNotify()
 		log.Println("connection closed")
 		return nil
 
@@ -146,6 +198,54 @@ func run(cfg langserver.Config) error {
 	}
 }
 
+// serveListener serves the connections of lis with serve until it fails,
+// or until the server shuts down: on SIGINT or SIGTERM, or after no client
+// has been connected for the idle timeout. lis is then closed, which stops
+// serve, and the clients of server are shut down gracefully: their pending
+// requests are given shutdownTimeout to be answered.
+func serveListener(lis net.Listener, server *langserver.Server, serve func(net.Listener) error) error {
+	var idle <-chan struct{}
+	if *idleTimeout > 0 {
+		idleLis := langserver.NewIdleListener(lis, *idleTimeout)
+		lis, idle = idleLis, idleLis.Idle()
+	}
+
+	shutdown := make(chan struct{})
+	signals := shutdownSignals()
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("langserver-go: received %s, shutting down", sig)
+		case <-idle:
+			log.Printf("langserver-go: no client connected for %s, shutting down", *idleTimeout)
+		}
+		close(shutdown)
+		_ = lis.Close()
+	}()
+
+	err := serve(lis)
+	_ = lis.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(ctx)
+	log.Println("langserver-go: clients shut down")
+
+	select {
+	case <-shutdown:
+		return nil
+	default:
+		return err
+	}
+}
+
+// shutdownSignals returns the channel receiving the signals shutting the
+// server down.
+func shutdownSignals() <-chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	return signals
+}
+
 type stdrwc struct{}
 
 func (stdrwc) Read(p []byte) (int, error) {